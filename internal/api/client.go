@@ -35,7 +35,9 @@ type ServiceInfo struct {
 	Repositories []string          `json:"repositories"`
 	StartTime    *time.Time        `json:"start_time,omitempty"`
 	Uptime       string            `json:"uptime,omitempty"`
+	Health       string            `json:"health,omitempty"`
 	HealthError  string            `json:"health_error,omitempty"`
+	Restarts     int               `json:"restarts,omitempty"`
 	Config       ServiceConfig     `json:"config"`
 }
 
@@ -4,17 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/user"
 	"strings"
 
+	"vibeman/internal/audit"
 	"vibeman/internal/cli"
 	"vibeman/internal/client"
 	"vibeman/internal/config"
+	"vibeman/internal/constants"
 	"vibeman/internal/container"
 	"vibeman/internal/db"
 	"vibeman/internal/git"
 	"vibeman/internal/logger"
 	"vibeman/internal/server"
 	"vibeman/internal/service"
+	"vibeman/internal/telemetry"
 )
 
 // App represents the main application
@@ -86,6 +90,13 @@ func (a *App) runLocal(ctx context.Context, args []string) error {
 	a.Container = container.New(cfg)
 	a.Git = git.New(cfg)
 	a.Service = service.New(cfg)
+	a.Container.SetManagers(a.Git, a.Service)
+
+	// Initialize tracing; a missing or invalid global config leaves it disabled
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil {
+		shutdown := initTelemetry(ctx, globalConfig.Telemetry, "vibeman-cli")
+		defer shutdown(context.Background())
+	}
 
 	// Initialize database with XDG-compliant path (needed for repo commands)
 	dbConfig := db.DefaultConfig()
@@ -100,6 +111,13 @@ func (a *App) runLocal(ctx context.Context, args []string) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Per-worktree service resources are recorded in the database
+	a.Service.SetDatabase(database)
+
+	// Record mutating commands in the audit log as the local user
+	audit.Init(database)
+	ctx = audit.WithActor(ctx, audit.Actor{Name: localUsername(), Source: db.AuditSourceCLI})
+
 	// Initialize CLI with local managers
 	a.CLI = cli.New(cfg)
 
@@ -155,6 +173,9 @@ func (a *App) runClient(ctx context.Context, args []string) error {
 	cfg := config.New() // Client-side config for preferences only
 	a.CLI = cli.New(cfg)
 
+	// Worktree operations run on the server and report progress back
+	a.CLI.SetOperationsClient(apiClient)
+
 	// Set the API client-based managers (no repo manager or database in client mode yet)
 	a.CLI.SetManagers(containerMgr, gitMgr, serviceMgr, nil, nil)
 
@@ -179,6 +200,7 @@ func (a *App) runServer(ctx context.Context, args []string) error {
 	a.Container = container.New(cfg)
 	a.Git = git.New(cfg)
 	a.Service = service.New(cfg)
+	a.Container.SetManagers(a.Git, a.Service)
 
 	// Initialize database with XDG-compliant path
 	dbConfig := db.DefaultConfig()
@@ -193,12 +215,22 @@ func (a *App) runServer(ctx context.Context, args []string) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Per-worktree service resources are recorded in the database
+	a.Service.SetDatabase(database)
+
 	// Load global config
 	globalConfig, err := config.LoadGlobalConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load global config: %w", err)
 	}
 
+	// Record mutating API calls in the audit log
+	audit.Init(database)
+
+	// Initialize tracing
+	shutdown := initTelemetry(ctx, globalConfig.Telemetry, "vibeman-server")
+	defer shutdown(context.Background())
+
 	// Parse server-specific arguments
 	var port int = globalConfig.Server.Port // Use config as default
 	var configPath string
@@ -220,6 +252,10 @@ func (a *App) runServer(ctx context.Context, args []string) error {
 	serverConfig := &server.Config{
 		Port:       port,
 		ConfigPath: configPath,
+		Auth:       globalConfig.Server.Auth,
+		Audit:      globalConfig.Audit,
+		Services:   globalConfig.Services,
+		AI:         globalConfig.AI,
 	}
 
 	// Create server with configuration manager
@@ -234,3 +270,32 @@ func (a *App) runServer(ctx context.Context, args []string) error {
 	}).Info("Starting Vibeman server")
 	return a.Server.Start(ctx)
 }
+
+// localUsername returns the name of the user running the CLI
+func localUsername() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// initTelemetry configures tracing and returns a function that flushes it.
+// Tracing failures are logged rather than treated as fatal.
+func initTelemetry(ctx context.Context, cfg config.TelemetryConfig, serviceName string) telemetry.ShutdownFunc {
+	shutdown, err := telemetry.Init(ctx, cfg, serviceName)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to initialize tracing")
+	}
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, constants.DefaultServerShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.WithError(err).Warn("Failed to flush traces")
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,326 @@
+// Package asciicast records terminal sessions to and plays them back from
+// files in the asciicast v2 format used by asciinema: a JSON header line
+// followed by one [time, type, data] line per event.
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Version is the asciicast format version written and read
+const Version = 2
+
+// Event types
+const (
+	EventOutput = "o" // Data written to the terminal
+	EventInput  = "i" // Data typed into the terminal
+	EventResize = "r" // New terminal size as COLSxROWS
+	EventMarker = "m" // A labelled point in the recording
+)
+
+// maxLine bounds the length of a recording line; output is recorded in
+// chunks of at most a few tens of kilobytes
+const maxLine = 4 << 20
+
+// Header is the first line of a recording
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"` // Unix time the recording started
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is something that happened in a recorded terminal, Time seconds
+// after the recording started
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+// MarshalJSON encodes the event as a [time, type, data] array
+func (e Event) MarshalJSON() ([]byte, error) {
+	// Microseconds are as precise as terminal timing gets
+	return json.Marshal([]any{math.Round(e.Time*1e6) / 1e6, e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes a [time, type, data] array
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("event has %d fields, want 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return fmt.Errorf("event time: %w", err)
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return fmt.Errorf("event type: %w", err)
+	}
+	if err := json.Unmarshal(fields[2], &e.Data); err != nil {
+		return fmt.Errorf("event data: %w", err)
+	}
+	return nil
+}
+
+// Recorder writes a terminal session to a recording as it happens. Its
+// methods may be called from several goroutines, and on a nil Recorder,
+// which records nothing.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	start   time.Time
+	pending map[string][]byte // Incomplete UTF-8 characters by event type
+	err     error             // The first write error, after which nothing is recorded
+}
+
+// NewRecorder writes the header of a recording to w and returns a Recorder
+// writing its events. Missing header fields are filled in.
+func NewRecorder(w io.WriteCloser, header Header) (*Recorder, error) {
+	start := time.Now()
+	header.Version = Version
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, start: start, pending: make(map[string][]byte)}, nil
+}
+
+// Output records data written to the terminal
+func (r *Recorder) Output(data []byte) {
+	r.record(EventOutput, data)
+}
+
+// Input records data typed into the terminal
+func (r *Recorder) Input(data []byte) {
+	r.record(EventInput, data)
+}
+
+// Resize records a new terminal size
+func (r *Recorder) Resize(cols, rows int) {
+	r.record(EventResize, []byte(fmt.Sprintf("%dx%d", cols, rows)))
+}
+
+// Marker records a labelled point in the recording
+func (r *Recorder) Marker(label string) {
+	r.record(EventMarker, []byte(label))
+}
+
+// Err returns the error that stopped recording, if any
+func (r *Recorder) Err() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close records what is left of incomplete characters and closes the
+// recording
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, eventType := range []string{EventOutput, EventInput} {
+		if rest := r.pending[eventType]; len(rest) > 0 {
+			r.write(eventType, string(rest))
+		}
+	}
+	if err := r.w.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// record writes an event for data. Output and input are written up to the
+// last complete UTF-8 character, since the format stores them as strings.
+func (r *Recorder) record(eventType string, data []byte) {
+	if r == nil || len(data) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if eventType == EventOutput || eventType == EventInput {
+		var complete []byte
+		complete, r.pending[eventType] = SplitUTF8(append(r.pending[eventType], data...))
+		data = complete
+	}
+	if len(data) > 0 {
+		r.write(eventType, string(data))
+	}
+}
+
+// write writes an event line, holding r.mu
+func (r *Recorder) write(eventType, data string) {
+	if r.err != nil {
+		return
+	}
+	line, err := json.Marshal(Event{Time: time.Since(r.start).Seconds(), Type: eventType, Data: data})
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	r.err = err
+}
+
+// SplitUTF8 splits b before a UTF-8 encoded character cut off at its end
+func SplitUTF8(b []byte) (complete, rest []byte) {
+	// A character is at most utf8.UTFMax bytes, so only its tail can be cut
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i], slices.Clone(b[i:])
+			}
+			break
+		}
+	}
+	return b, nil
+}
+
+// Decoder reads the events of a recording one at a time
+type Decoder struct {
+	scanner *bufio.Scanner
+	header  Header
+}
+
+// NewDecoder reads the header of a recording from r
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("recording is empty")
+	}
+
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("invalid recording header: %w", err)
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("unsupported asciicast version %d, want %d", header.Version, Version)
+	}
+	return &Decoder{scanner: scanner, header: header}, nil
+}
+
+// Header returns the recording's header
+func (d *Decoder) Header() Header {
+	return d.header
+}
+
+// Next returns the next event, or io.EOF after the last one
+func (d *Decoder) Next() (Event, error) {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return Event{}, fmt.Errorf("invalid recording event: %w", err)
+		}
+		return event, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// Duration returns the time of the last event of the recording in r, whose
+// size is size, reading only its end
+func Duration(r io.ReaderAt, size int64) (float64, error) {
+	const tail = 64 * 1024
+	offset := max(size-tail, 0)
+	buf := make([]byte, size-offset)
+	if _, err := r.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var event Event
+		if json.Unmarshal([]byte(lines[i]), &event) == nil {
+			return event.Time, nil
+		}
+	}
+	return 0, nil
+}
+
+// PlayOptions control how a recording is played back
+type PlayOptions struct {
+	Speed     float64       // Playback speed, 1 for real time
+	IdleLimit time.Duration // Pauses longer than this are shortened to it, when set
+}
+
+// Play writes the output of a recording to w with its original timing,
+// adjusted by opts, until the recording ends or ctx is cancelled
+func Play(ctx context.Context, w io.Writer, d *Decoder, opts PlayOptions) error {
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	previous := 0.0
+	for {
+		event, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event.Type != EventOutput {
+			continue
+		}
+
+		wait := time.Duration((event.Time - previous) * float64(time.Second))
+		previous = event.Time
+		if opts.IdleLimit > 0 && wait > opts.IdleLimit {
+			wait = opts.IdleLimit
+		}
+		wait = time.Duration(float64(wait) / opts.Speed)
+		if wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		if _, err := io.WriteString(w, event.Data); err != nil {
+			return err
+		}
+	}
+}
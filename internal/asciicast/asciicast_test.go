@@ -0,0 +1,153 @@
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buffer is a recording destination that remembers being closed
+type buffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *buffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	var out buffer
+	rec, err := NewRecorder(&out, Header{Width: 80, Height: 24, Command: "claude", Title: "agent"})
+	require.NoError(t, err)
+
+	euro := []byte("€") // Three bytes
+	rec.Output([]byte("hello "))
+	rec.Output(euro[:1])
+	rec.Output(euro[1:])
+	rec.Input([]byte("ls\r"))
+	rec.Resize(132, 50)
+	rec.Marker("done")
+	rec.Output([]byte("cut "))
+	rec.Output(euro[:2])
+	require.NoError(t, rec.Close())
+	assert.True(t, out.closed)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 8)
+	assert.Contains(t, lines[0], `"version":2`)
+	assert.Contains(t, lines[0], `"command":"claude"`)
+
+	d, err := NewDecoder(strings.NewReader(out.String()))
+	require.NoError(t, err)
+	assert.Equal(t, 80, d.Header().Width)
+	assert.Equal(t, "agent", d.Header().Title)
+	assert.NotZero(t, d.Header().Timestamp)
+
+	var events []Event
+	for {
+		event, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+	require.Len(t, events, 7)
+	for i := 1; i < len(events); i++ {
+		assert.GreaterOrEqual(t, events[i].Time, events[i-1].Time)
+	}
+
+	// Characters split across writes are recorded whole
+	assert.Equal(t, Event{Time: events[0].Time, Type: EventOutput, Data: "hello "}, events[0])
+	assert.Equal(t, "€", events[1].Data)
+	assert.Equal(t, Event{Time: events[2].Time, Type: EventInput, Data: "ls\r"}, events[2])
+	assert.Equal(t, Event{Time: events[3].Time, Type: EventResize, Data: "132x50"}, events[3])
+	assert.Equal(t, EventMarker, events[4].Type)
+	assert.Equal(t, EventOutput, events[5].Type)
+	assert.Equal(t, "cut ", events[5].Data)
+
+	// What is left of a cut-off character is still recorded on close
+	assert.Equal(t, EventOutput, events[6].Type)
+	assert.NotEmpty(t, events[6].Data)
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var rec *Recorder
+	rec.Output([]byte("ignored"))
+	rec.Resize(80, 24)
+	assert.NoError(t, rec.Err())
+	assert.NoError(t, rec.Close())
+}
+
+func TestSplitUTF8(t *testing.T) {
+	euro := []byte("€") // Three bytes
+
+	complete, rest := SplitUTF8(append([]byte("ab"), euro[:2]...))
+	assert.Equal(t, "ab", string(complete))
+	assert.Equal(t, euro[:2], rest)
+
+	complete, rest = SplitUTF8(append(rest, euro[2:]...))
+	assert.Equal(t, "€", string(complete))
+	assert.Empty(t, rest)
+
+	complete, rest = SplitUTF8([]byte("plain"))
+	assert.Equal(t, "plain", string(complete))
+	assert.Empty(t, rest)
+}
+
+func TestDecoder_Invalid(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader(""))
+	assert.Error(t, err)
+	_, err = NewDecoder(strings.NewReader(`{"version":1,"width":80,"height":24}`))
+	assert.ErrorContains(t, err, "unsupported")
+
+	d, err := NewDecoder(strings.NewReader("{\"version\":2}\n[1.5, \"o\"]\n"))
+	require.NoError(t, err)
+	_, err = d.Next()
+	assert.ErrorContains(t, err, "invalid recording event")
+}
+
+func TestDuration(t *testing.T) {
+	recording := "{\"version\":2,\"width\":80,\"height\":24}\n[0.5, \"o\", \"a\"]\n[12.25, \"i\", \"b\"]\n"
+	duration, err := Duration(strings.NewReader(recording), int64(len(recording)))
+	require.NoError(t, err)
+	assert.Equal(t, 12.25, duration)
+
+	header := "{\"version\":2,\"width\":80,\"height\":24}\n"
+	duration, err = Duration(strings.NewReader(header), int64(len(header)))
+	require.NoError(t, err)
+	assert.Zero(t, duration)
+}
+
+func TestPlay(t *testing.T) {
+	recording := "{\"version\":2,\"width\":80,\"height\":24}\n" +
+		"[0.1, \"o\", \"one \"]\n" +
+		"[0.2, \"i\", \"typed\"]\n" +
+		"[0.3, \"r\", \"100x40\"]\n" +
+		"[60, \"o\", \"two\"]\n"
+
+	d, err := NewDecoder(strings.NewReader(recording))
+	require.NoError(t, err)
+
+	// The minute-long pause is cut to the idle limit, and everything runs
+	// at ten times the recorded speed
+	var out bytes.Buffer
+	started := time.Now()
+	require.NoError(t, Play(context.Background(), &out, d, PlayOptions{Speed: 10, IdleLimit: time.Second}))
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assert.Equal(t, "one two", out.String())
+
+	d, err = NewDecoder(strings.NewReader(recording))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, Play(ctx, io.Discard, d, PlayOptions{}), context.DeadlineExceeded)
+}
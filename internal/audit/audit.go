@@ -0,0 +1,207 @@
+// Package audit records mutating actions — who did what to which resource,
+// from where, and whether it worked — in the audit_events table.
+//
+// Recording is disabled until Init is called with a database, so Record is
+// always safe to call.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	ActionRepositoryAdd    = "repository.add"
+	ActionRepositoryRemove = "repository.remove"
+	ActionRepositoryTeam   = "repository.set_team"
+	ActionWorktreeCreate   = "worktree.create"
+	ActionWorktreeDelete   = "worktree.delete"
+	ActionWorktreeStart    = "worktree.start"
+	ActionWorktreeStop     = "worktree.stop"
+	ActionWorktreeNotify   = "worktree.service_status" // A service the worktree uses changed state
+	ActionServiceStart     = "service.start"
+	ActionServiceStop      = "service.stop"
+	ActionServiceRestart   = "service.restart"
+	ActionServiceSnapshot  = "service.snapshot"
+	ActionServiceRestore   = "service.restore"
+	ActionServiceAdd       = "service.add"
+	ActionServiceEdit      = "service.edit"
+	ActionServiceRemove    = "service.remove"
+	ActionContainerCreate  = "container.create"
+	ActionContainerDelete  = "container.delete"
+	ActionContainerAction  = "container." // Suffixed with the container action, e.g. container.restart
+	ActionTeamCreate       = "team.create"
+	ActionTeamSetMember    = "team.set_member"
+	ActionTeamRemoveMember = "team.remove_member"
+	ActionUserCreate       = "user.create"
+	ActionLogin            = "auth.login" // Failed logins are recorded too
+	ActionLogout           = "auth.logout"
+	ActionTokenCreate      = "token.create"
+	ActionTokenRevoke      = "token.revoke"
+	ActionAIJobRun         = "ai_job.run"
+	ActionAIJobCancel      = "ai_job.cancel"
+	ActionAISessionShare   = "ai_session.share"
+	ActionAISessionUnshare = "ai_session.unshare"
+	ActionAISessionKill    = "ai_session.kill"
+	ActionAgentWaiting     = "agent.waiting"  // An agent stopped to wait for input
+	ActionAgentFinished    = "agent.finished" // An agent went quiet or exited
+)
+
+// Target types
+const (
+	TargetRepository = "repository"
+	TargetWorktree   = "worktree"
+	TargetService    = "service"
+	TargetContainer  = "container"
+	TargetTeam       = "team"
+	TargetUser       = "user"
+	TargetToken      = "token"
+)
+
+// SchedulerActor is recorded for actions taken without a user, such as
+// background jobs
+const SchedulerActor = "vibeman"
+
+// Actor identifies who performed an action and through which interface
+type Actor struct {
+	Name   string
+	Source db.AuditSource
+}
+
+// Target identifies the resource an action applied to
+type Target struct {
+	Type string
+	ID   string
+	Name string
+}
+
+// Params holds the parameters of an action
+type Params map[string]interface{}
+
+type actorKey struct{}
+
+// WithActor returns a context whose audited actions are attributed to actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor attached to ctx. Without one, actions
+// are attributed to the scheduler.
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: SchedulerActor, Source: db.AuditSourceScheduler}
+}
+
+var (
+	mu    sync.RWMutex
+	store *db.DB
+)
+
+// Init enables recording to database. Passing nil disables it.
+func Init(database *db.DB) {
+	mu.Lock()
+	defer mu.Unlock()
+	store = database
+}
+
+// Record writes an audit event for an action on target; err is the outcome
+// of the action. Failures to write are logged rather than returned, so
+// auditing never changes the result of the action itself.
+func Record(ctx context.Context, action string, target Target, params Params, err error) {
+	mu.RLock()
+	database := store
+	mu.RUnlock()
+	if database == nil {
+		return
+	}
+
+	actor := ActorFromContext(ctx)
+	event := &db.AuditEvent{
+		ID:         uuid.New().String(),
+		Actor:      actor.Name,
+		Source:     actor.Source,
+		Action:     action,
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		TargetName: target.Name,
+		Outcome:    db.AuditSuccess,
+	}
+	if len(params) > 0 {
+		event.Parameters = db.JSONB(params)
+	}
+	if err != nil {
+		event.Outcome = db.AuditFailure
+		event.Error = err.Error()
+	}
+
+	// Record even when the action was cancelled
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := db.NewAuditRepository(database).Create(writeCtx, event); err != nil {
+		logger.WithError(err).WithField("action", action).Warn("Failed to record audit event")
+	}
+}
+
+// Prune deletes audit events older than retention and returns how many were
+// removed. A zero retention keeps events forever.
+func Prune(ctx context.Context, database *db.DB, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	return db.NewAuditRepository(database).DeleteBefore(ctx, time.Now().Add(-retention))
+}
+
+// RunPruner prunes the audit log now and then every interval until ctx is
+// cancelled
+func RunPruner(ctx context.Context, database *db.DB, retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := Prune(ctx, database, retention)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to prune audit log")
+		} else if count > 0 {
+			logger.WithField("count", count).Info("Pruned audit log")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ParseSince parses a --since or ?since= value: either a duration ago, such
+// as "24h", or an RFC 3339 timestamp or date
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: use a duration such as 24h, a date or an RFC 3339 time", value)
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAudit enables recording to a test database for the duration of a test
+func setupAudit(t *testing.T) *db.DB {
+	t.Helper()
+	database := testutil.SetupTestDB(t)
+	Init(database)
+	t.Cleanup(func() { Init(nil) })
+	return database
+}
+
+func TestRecord(t *testing.T) {
+	database := setupAudit(t)
+	ctx := WithActor(context.Background(), Actor{Name: "alice", Source: db.AuditSourceAPI})
+
+	Record(ctx, ActionWorktreeStart, Target{Type: TargetWorktree, ID: "wt-1", Name: "feature"}, Params{"force": true}, nil)
+	Record(context.Background(), ActionWorktreeStop, Target{Type: TargetWorktree, ID: "wt-1", Name: "feature"}, nil, errors.New("container not running"))
+
+	events, err := db.NewAuditRepository(database).List(context.Background(), db.AuditFilter{Target: "feature"})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	// Newest first; actions without an actor are attributed to the scheduler
+	stop, start := events[0], events[1]
+	assert.Equal(t, ActionWorktreeStop, stop.Action)
+	assert.Equal(t, SchedulerActor, stop.Actor)
+	assert.Equal(t, db.AuditSourceScheduler, stop.Source)
+	assert.Equal(t, db.AuditFailure, stop.Outcome)
+	assert.Equal(t, "container not running", stop.Error)
+
+	assert.Equal(t, "alice", start.Actor)
+	assert.Equal(t, db.AuditSourceAPI, start.Source)
+	assert.Equal(t, db.AuditSuccess, start.Outcome)
+	assert.Equal(t, true, start.Parameters["force"])
+}
+
+func TestRecord_Disabled(t *testing.T) {
+	Init(nil)
+	assert.NotPanics(t, func() {
+		Record(context.Background(), ActionWorktreeStart, Target{Type: TargetWorktree, ID: "wt-1"}, nil, nil)
+	})
+}
+
+func TestPrune(t *testing.T) {
+	database := setupAudit(t)
+	ctx := context.Background()
+	repo := db.NewAuditRepository(database)
+
+	for id, age := range map[string]time.Duration{"old": 48 * time.Hour, "new": time.Hour} {
+		require.NoError(t, repo.Create(ctx, &db.AuditEvent{
+			ID:         id,
+			Actor:      "alice",
+			Source:     db.AuditSourceCLI,
+			Action:     ActionRepositoryAdd,
+			TargetType: TargetRepository,
+			Outcome:    db.AuditSuccess,
+			CreatedAt:  time.Now().Add(-age),
+		}))
+	}
+
+	// Zero retention keeps everything
+	count, err := Prune(ctx, database, 0)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	count, err = Prune(ctx, database, 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	events, err := repo.List(ctx, db.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "new", events[0].ID)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	since, err := ParseSince("24h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), since)
+
+	since, err = ParseSince("2025-03-01T08:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseSince("2025-03-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local), since)
+
+	since, err = ParseSince("", now)
+	require.NoError(t, err)
+	assert.True(t, since.IsZero())
+
+	_, err = ParseSince("last tuesday", now)
+	assert.Error(t, err)
+}
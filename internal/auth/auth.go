@@ -0,0 +1,369 @@
+// Package auth implements server authentication: local users with bcrypt
+// passwords, short-lived session tokens (JWTs) backed by refreshable login
+// sessions, and scoped personal API tokens.
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/errors"
+	"vibeman/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+// Default token lifetimes
+const (
+	DefaultSessionTTL = 15 * time.Minute
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+)
+
+// Config holds the settings used by the auth service
+type Config struct {
+	Secret     []byte        // Secret used to sign session tokens
+	SessionTTL time.Duration // Lifetime of a session token
+	RefreshTTL time.Duration // How long a login can be refreshed
+}
+
+// NewConfig builds the auth service configuration from the global settings.
+// The signing secret is only persisted when auth is enabled; otherwise a
+// per-process secret is used.
+func NewConfig(settings config.AuthConfig) (Config, error) {
+	cfg := Config{
+		SessionTTL: DefaultSessionTTL,
+		RefreshTTL: DefaultRefreshTTL,
+	}
+
+	if settings.SessionTTL != "" {
+		d, err := time.ParseDuration(settings.SessionTTL)
+		if err != nil {
+			return cfg, errors.Wrap(errors.ErrConfigInvalid, "invalid auth session_ttl", err)
+		}
+		cfg.SessionTTL = d
+	}
+	if settings.RefreshTTL != "" {
+		d, err := time.ParseDuration(settings.RefreshTTL)
+		if err != nil {
+			return cfg, errors.Wrap(errors.ErrConfigInvalid, "invalid auth refresh_ttl", err)
+		}
+		cfg.RefreshTTL = d
+	}
+
+	switch {
+	case settings.JWTSecret != "" || settings.Enabled:
+		secret, err := LoadOrCreateSecret(settings.JWTSecret)
+		if err != nil {
+			return cfg, errors.Wrap(errors.ErrConfigInvalid, "failed to load session secret", err)
+		}
+		cfg.Secret = secret
+	default:
+		secret, err := randomHex(32)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Secret = []byte(secret)
+	}
+
+	return cfg, nil
+}
+
+// Principal is the authenticated identity behind a request
+type Principal struct {
+	User      *db.User
+	Scopes    []string
+	SessionID string // Set when authenticated with a session token
+	TokenID   string // Set when authenticated with a personal API token
+}
+
+// HasScope reports whether the principal was granted the required scope
+func (p *Principal) HasScope(required string) bool {
+	return p != nil && HasScope(p.Scopes, required)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal, if any
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// SessionToken is an issued session token
+type SessionToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      *db.User  `json:"user"`
+}
+
+// Service authenticates users and tokens
+type Service struct {
+	db  *db.DB
+	cfg Config
+}
+
+// NewService creates a new auth service
+func NewService(database *db.DB, cfg Config) *Service {
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = DefaultSessionTTL
+	}
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = DefaultRefreshTTL
+	}
+	return &Service{db: database, cfg: cfg}
+}
+
+// roleScope returns the highest scope a user's role allows
+func roleScope(user *db.User) string {
+	if user.Role == db.RoleAdmin {
+		return ScopeAdmin
+	}
+	return ScopeWrite
+}
+
+// sessionScopes returns the scopes granted to a user's login session
+func sessionScopes(user *db.User) []string {
+	return []string{roleScope(user)}
+}
+
+// unauthorized returns a generic authentication error
+func unauthorized(msg string) error {
+	return errors.New(errors.ErrUnauthorized, msg)
+}
+
+// CreateUser creates a local user. The first user is always an admin.
+func (s *Service) CreateUser(ctx context.Context, username, email, password string, role db.UserRole) (*db.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New(errors.ErrInvalidInput, "username is required")
+	}
+	if role == "" {
+		role = db.RoleUser
+	}
+	if role != db.RoleAdmin && role != db.RoleUser {
+		return nil, errors.New(errors.ErrInvalidInput, "role must be admin or user").WithContext("role", role)
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalidInput, "invalid password", err)
+	}
+
+	users := db.NewUserRepository(s.db)
+	count, err := users.Count(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to count users", err)
+	}
+	if count == 0 {
+		role = db.RoleAdmin
+	}
+
+	if _, err := users.GetByUsername(ctx, username); err == nil {
+		return nil, errors.New(errors.ErrInvalidState, "user already exists").WithContext("username", username)
+	}
+
+	user := &db.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	if err := users.Create(ctx, user); err != nil {
+		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to create user", err)
+	}
+
+	return users.GetByID(ctx, user.ID)
+}
+
+// Login verifies a username and password and starts a new session
+func (s *Service) Login(ctx context.Context, username, password string) (*SessionToken, error) {
+	user, err := db.NewUserRepository(s.db).GetByUsername(ctx, username)
+	if err != nil {
+		// Take as long as a wrong password would, so timing does not
+		// tell which users exist
+		CheckPassword(dummyPasswordHash(), password)
+		return nil, errors.New(errors.ErrAuthFailed, "invalid username or password")
+	}
+	if !CheckPassword(user.PasswordHash, password) {
+		return nil, errors.New(errors.ErrAuthFailed, "invalid username or password")
+	}
+
+	session := &db.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.cfg.RefreshTTL),
+	}
+	if err := db.NewSessionRepository(s.db).Create(ctx, session); err != nil {
+		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to create session", err)
+	}
+
+	return s.issue(user, session.ID)
+}
+
+// Refresh issues a new session token for a still-valid login session. The
+// presented token may have expired.
+func (s *Service) Refresh(ctx context.Context, token string) (*SessionToken, error) {
+	claims, err := parseSession(s.cfg.Secret, token, true)
+	if err != nil {
+		return nil, unauthorized("invalid session token")
+	}
+
+	user, err := s.activeSessionUser(ctx, claims.ID, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, claims.ID)
+}
+
+// Logout revokes the session of a principal. API tokens are revoked
+// individually instead.
+func (s *Service) Logout(ctx context.Context, p *Principal) error {
+	if p == nil || p.SessionID == "" {
+		return nil
+	}
+	if err := db.NewSessionRepository(s.db).Revoke(ctx, p.SessionID); err != nil {
+		return errors.Wrap(errors.ErrDatabaseQuery, "failed to revoke session", err)
+	}
+	return nil
+}
+
+// Authenticate resolves a bearer token, either a session token or a personal
+// API token, into a principal
+func (s *Service) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if strings.HasPrefix(token, APITokenPrefix) {
+		return s.authenticateAPIToken(ctx, token)
+	}
+
+	claims, err := parseSession(s.cfg.Secret, token, false)
+	if err != nil {
+		return nil, unauthorized("invalid or expired session token")
+	}
+
+	user, err := s.activeSessionUser(ctx, claims.ID, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scopes never exceed what the user's current role allows, so a demoted
+	// user loses them before the session token expires
+	return &Principal{User: user, Scopes: LimitScopes(claims.Scopes, roleScope(user)), SessionID: claims.ID}, nil
+}
+
+// authenticateAPIToken resolves a personal API token
+func (s *Service) authenticateAPIToken(ctx context.Context, token string) (*Principal, error) {
+	tokens := db.NewAPITokenRepository(s.db)
+	apiToken, err := tokens.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, unauthorized("invalid API token")
+	}
+
+	now := time.Now()
+	if apiToken.ExpiresAt != nil && now.After(*apiToken.ExpiresAt) {
+		return nil, unauthorized("API token has expired")
+	}
+
+	user, err := db.NewUserRepository(s.db).GetByID(ctx, apiToken.UserID)
+	if err != nil {
+		return nil, unauthorized("invalid API token")
+	}
+
+	if err := tokens.TouchLastUsed(ctx, apiToken.ID, now); err != nil {
+		logger.WithError(err).WithField("token_id", apiToken.ID).Warn("Failed to record API token use")
+	}
+
+	// Tokens keep the scopes they were created with only as long as the
+	// user's role allows them
+	return &Principal{User: user, Scopes: LimitScopes(apiToken.Scopes, roleScope(user)), TokenID: apiToken.ID}, nil
+}
+
+// activeSessionUser returns the user of a session that is neither revoked nor expired
+func (s *Service) activeSessionUser(ctx context.Context, sessionID, userID string) (*db.User, error) {
+	session, err := db.NewSessionRepository(s.db).Get(ctx, sessionID)
+	if err != nil {
+		return nil, unauthorized("session not found")
+	}
+	if session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, unauthorized("session has expired, please log in again")
+	}
+
+	user, err := db.NewUserRepository(s.db).GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, unauthorized("session user no longer exists")
+	}
+	return user, nil
+}
+
+// issue signs a session token for a user session
+func (s *Service) issue(user *db.User, sessionID string) (*SessionToken, error) {
+	token, expiresAt, err := signSession(s.cfg.Secret, user.ID, sessionID, sessionScopes(user), s.cfg.SessionTTL)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInternal, "failed to issue session token", err)
+	}
+	return &SessionToken{Token: token, ExpiresAt: expiresAt, User: user}, nil
+}
+
+// CreateAPIToken creates a personal API token for the principal. The token
+// cannot be granted scopes the principal does not have. The plaintext token
+// is only returned here.
+func (s *Service) CreateAPIToken(ctx context.Context, p *Principal, name string, scopes []string, ttl time.Duration) (string, *db.APIToken, error) {
+	if p == nil || p.User == nil {
+		return "", nil, unauthorized("authentication required")
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", nil, errors.New(errors.ErrInvalidInput, "token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New(errors.ErrInvalidInput, "at least one scope is required")
+	}
+	if err := ValidateScopes(scopes); err != nil {
+		return "", nil, errors.Wrap(errors.ErrInvalidInput, "invalid scopes", err)
+	}
+	for _, scope := range scopes {
+		if !p.HasScope(scope) {
+			return "", nil, errors.New(errors.ErrForbidden, "cannot grant a scope you do not have").WithContext("scope", scope)
+		}
+	}
+
+	token, hash, err := generateAPIToken()
+	if err != nil {
+		return "", nil, errors.Wrap(errors.ErrInternal, "failed to generate token", err)
+	}
+
+	apiToken := &db.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    p.User.ID,
+		Name:      name,
+		TokenHash: hash,
+		Scopes:    db.StringList(scopes),
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	if err := db.NewAPITokenRepository(s.db).Create(ctx, apiToken); err != nil {
+		return "", nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to create API token", err)
+	}
+
+	return token, apiToken, nil
+}
+
+// ListAPITokens lists the personal API tokens of a user
+func (s *Service) ListAPITokens(ctx context.Context, userID string) ([]*db.APIToken, error) {
+	return db.NewAPITokenRepository(s.db).ListByUser(ctx, userID)
+}
+
+// RevokeAPIToken deletes a personal API token owned by a user
+func (s *Service) RevokeAPIToken(ctx context.Context, userID, tokenID string) error {
+	return db.NewAPITokenRepository(s.db).Delete(ctx, userID, tokenID)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, cfg Config) *Service {
+	t.Helper()
+	cfg.Secret = []byte("test-secret")
+	return NewService(testutil.SetupTestDB(t), cfg)
+}
+
+func TestCreateUser_FirstUserIsAdmin(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+
+	first, err := svc.CreateUser(ctx, "alice", "", "password123", db.RoleUser)
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleAdmin, first.Role)
+
+	second, err := svc.CreateUser(ctx, "bob", "", "password123", "")
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleUser, second.Role)
+
+	_, err = svc.CreateUser(ctx, "bob", "", "password123", "")
+	assert.Error(t, err, "duplicate usernames are rejected")
+
+	_, err = svc.CreateUser(ctx, "carol", "", "short", "")
+	assert.Error(t, err, "short passwords are rejected")
+}
+
+func TestLoginAndAuthenticate(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+
+	_, err := svc.CreateUser(ctx, "alice", "", "password123", "")
+	require.NoError(t, err)
+
+	_, err = svc.Login(ctx, "alice", "wrong-password")
+	assert.Error(t, err)
+
+	session, err := svc.Login(ctx, "alice", "password123")
+	require.NoError(t, err)
+	assert.NotEmpty(t, session.Token)
+
+	principal, err := svc.Authenticate(ctx, session.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", principal.User.Username)
+	assert.True(t, principal.HasScope(ScopeAdmin))
+	assert.NotEmpty(t, principal.SessionID)
+
+	// Logging out revokes the session and its tokens
+	require.NoError(t, svc.Logout(ctx, principal))
+	_, err = svc.Authenticate(ctx, session.Token)
+	assert.Error(t, err)
+	_, err = svc.Refresh(ctx, session.Token)
+	assert.Error(t, err)
+}
+
+func TestRefresh_AcceptsExpiredSessionToken(t *testing.T) {
+	svc := newTestService(t, Config{SessionTTL: time.Millisecond, RefreshTTL: time.Hour})
+	ctx := context.Background()
+
+	_, err := svc.CreateUser(ctx, "alice", "", "password123", "")
+	require.NoError(t, err)
+	session, err := svc.Login(ctx, "alice", "password123")
+	require.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond) // JWT expiry has second granularity
+	_, err = svc.Authenticate(ctx, session.Token)
+	assert.Error(t, err, "expired session tokens are rejected")
+
+	refreshed, err := svc.Refresh(ctx, session.Token)
+	require.NoError(t, err)
+	assert.NotEqual(t, session.Token, refreshed.Token)
+}
+
+func TestAPITokens(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+
+	_, err := svc.CreateUser(ctx, "admin", "", "password123", "")
+	require.NoError(t, err)
+	_, err = svc.CreateUser(ctx, "bob", "", "password123", "")
+	require.NoError(t, err)
+	session, err := svc.Login(ctx, "bob", "password123")
+	require.NoError(t, err)
+	principal, err := svc.Authenticate(ctx, session.Token)
+	require.NoError(t, err)
+
+	// Users cannot grant scopes they do not have
+	_, _, err = svc.CreateAPIToken(ctx, principal, "ci", []string{ScopeAdmin}, 0)
+	assert.Error(t, err)
+	_, _, err = svc.CreateAPIToken(ctx, principal, "ci", []string{"deploy"}, 0)
+	assert.Error(t, err)
+
+	token, apiToken, err := svc.CreateAPIToken(ctx, principal, "ci", []string{ScopeRead}, time.Hour)
+	require.NoError(t, err)
+	assert.Contains(t, token, APITokenPrefix)
+	assert.NotEqual(t, token, apiToken.TokenHash)
+
+	tokenPrincipal, err := svc.Authenticate(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, apiToken.ID, tokenPrincipal.TokenID)
+	assert.True(t, tokenPrincipal.HasScope(ScopeRead))
+	assert.False(t, tokenPrincipal.HasScope(ScopeWrite))
+
+	tokens, err := svc.ListAPITokens(ctx, principal.User.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	require.NoError(t, svc.RevokeAPIToken(ctx, principal.User.ID, apiToken.ID))
+	_, err = svc.Authenticate(ctx, token)
+	assert.Error(t, err)
+}
+
+func TestAuthenticate_LimitsScopesToCurrentRole(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+
+	_, err := svc.CreateUser(ctx, "alice", "", "password123", "")
+	require.NoError(t, err)
+	session, err := svc.Login(ctx, "alice", "password123")
+	require.NoError(t, err)
+	principal, err := svc.Authenticate(ctx, session.Token)
+	require.NoError(t, err)
+	token, _, err := svc.CreateAPIToken(ctx, principal, "ci", []string{ScopeAdmin}, 0)
+	require.NoError(t, err)
+
+	// Demoted admins keep neither their tokens' nor their sessions' admin scope
+	_, err = svc.db.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", db.RoleUser, principal.User.ID)
+	require.NoError(t, err)
+	for _, presented := range []string{token, session.Token} {
+		demoted, err := svc.Authenticate(ctx, presented)
+		require.NoError(t, err)
+		assert.False(t, demoted.HasScope(ScopeAdmin))
+		assert.True(t, demoted.HasScope(ScopeWrite))
+	}
+
+	_, err = svc.Login(ctx, "nobody", "password123")
+	assert.Error(t, err)
+}
+
+func TestRequiredScope(t *testing.T) {
+	assert.Equal(t, ScopeRead, RequiredScope("GET"))
+	assert.Equal(t, ScopeWrite, RequiredScope("POST"))
+	assert.Equal(t, ScopeWrite, RequiredScope("DELETE"))
+	assert.True(t, HasScope([]string{ScopeAdmin}, ScopeRead))
+	assert.False(t, HasScope([]string{ScopeRead}, ScopeWrite))
+	assert.Equal(t, []string{ScopeRead, ScopeWrite}, LimitScopes([]string{ScopeRead, ScopeAdmin}, ScopeWrite))
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vibeman/internal/constants"
+	"vibeman/internal/xdg"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// sessionIssuer is the issuer of session tokens
+const sessionIssuer = "vibeman"
+
+// sessionClaims are the claims carried by a session token
+type sessionClaims struct {
+	Scopes []string `json:"scopes"`
+	jwt.RegisteredClaims
+}
+
+// signSession issues a session token for a user session
+func signSession(secret []byte, userID, sessionID string, scopes []string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := sessionClaims{
+		Scopes: scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Subject:   userID,
+			ID:        sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign session token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// parseSession validates a session token. Expired tokens are accepted when
+// allowExpired is set, which is how a session is refreshed.
+func parseSession(secret []byte, token string, allowExpired bool) (*sessionClaims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(sessionIssuer),
+	}
+	if allowExpired {
+		opts = append(opts, jwt.WithoutClaimsValidation())
+	}
+
+	claims := &sessionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, fmt.Errorf("session token is missing subject or session ID")
+	}
+	return claims, nil
+}
+
+// LoadOrCreateSecret returns the configured session signing secret. When none
+// is configured, a secret is generated once and stored in the config directory.
+func LoadOrCreateSecret(configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+
+	configDir, err := xdg.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(configDir, "jwt_secret")
+
+	data, err := os.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return []byte(strings.TrimSpace(string(data))), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read session secret: %w", err)
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(configDir, constants.SecureDirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(secret), constants.SecureFilePermissions); err != nil {
+		return nil, fmt.Errorf("failed to write session secret: %w", err)
+	}
+	return []byte(secret), nil
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"vibeman/internal/db"
+	"vibeman/internal/errors"
+)
+
+// Action is an operation on a repository or worktree that is subject to team
+// permissions
+type Action string
+
+const (
+	ActionView   Action = "view"
+	ActionCreate Action = "create" // Create worktrees in a repository
+	ActionStart  Action = "start"
+	ActionStop   Action = "stop"
+	ActionAttach Action = "attach"
+	ActionDelete Action = "delete"
+)
+
+// requiredRole returns the minimum team role needed for an action
+func requiredRole(action Action) db.TeamRole {
+	switch action {
+	case ActionView:
+		return db.TeamRoleViewer
+	case ActionDelete:
+		return db.TeamRoleAdmin
+	default:
+		return db.TeamRoleDeveloper
+	}
+}
+
+// Access describes which repositories and worktrees a caller may see and
+// act on. Access without a principal (auth disabled) is unrestricted.
+type Access struct {
+	principal   *Principal
+	memberships map[string]db.TeamRole
+	team        string // Team selected by the caller; limits what is visible
+}
+
+// LoadAccess resolves the team memberships of the principal in ctx. When team
+// is set the caller must belong to it (global admins excepted), and only that
+// team's repositories are visible.
+func LoadAccess(ctx context.Context, database *db.DB, team string) (*Access, error) {
+	access := &Access{
+		principal:   PrincipalFromContext(ctx),
+		memberships: map[string]db.TeamRole{},
+		team:        team,
+	}
+	if access.principal == nil || database == nil {
+		return access, nil
+	}
+
+	memberships, err := db.NewTeamRepository(database).ListMemberships(ctx, access.principal.User.ID)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to load team memberships", err)
+	}
+	access.memberships = memberships
+
+	if team != "" && !access.Unrestricted() {
+		if _, ok := memberships[team]; !ok {
+			return nil, errors.New(errors.ErrPermissionDenied, "not a member of the selected team").WithContext("team_id", team)
+		}
+	}
+
+	return access, nil
+}
+
+// Unrestricted reports whether the caller bypasses team permissions, either
+// as a global admin or because auth is disabled
+func (a *Access) Unrestricted() bool {
+	return a.principal == nil || a.principal.User.Role == db.RoleAdmin
+}
+
+// UserID returns the ID of the caller, or "" without a principal
+func (a *Access) UserID() string {
+	if a.principal == nil {
+		return ""
+	}
+	return a.principal.User.ID
+}
+
+// Team returns the team selected by the caller, if any
+func (a *Access) Team() string {
+	return a.team
+}
+
+// MemberRole returns the caller's role in a team
+func (a *Access) MemberRole(teamID string) (db.TeamRole, bool) {
+	if a.Unrestricted() {
+		return db.TeamRoleAdmin, true
+	}
+	role, ok := a.memberships[teamID]
+	return role, ok
+}
+
+// RepositoryRole returns the caller's effective role on a repository. The
+// owner of a repository is its admin, team members get their team role, and
+// repositories with neither owner nor team are shared with every user as
+// developers.
+func (a *Access) RepositoryRole(repo *db.Repository) (db.TeamRole, bool) {
+	if a.team != "" && repo.TeamID != a.team {
+		return "", false
+	}
+
+	switch {
+	case a.Unrestricted():
+		return db.TeamRoleAdmin, true
+	case repo.OwnerID != "" && repo.OwnerID == a.principal.User.ID:
+		return db.TeamRoleAdmin, true
+	case repo.TeamID != "":
+		role, ok := a.memberships[repo.TeamID]
+		return role, ok
+	case repo.OwnerID == "":
+		return db.TeamRoleDeveloper, true
+	}
+	return "", false
+}
+
+// CanRepository reports whether the caller may perform action on a repository
+func (a *Access) CanRepository(repo *db.Repository, action Action) bool {
+	role, ok := a.RepositoryRole(repo)
+	return ok && role.AtLeast(requiredRole(action))
+}
+
+// CanWorktree reports whether the caller may perform action on a worktree of
+// repo. Developers may delete worktrees they created.
+func (a *Access) CanWorktree(repo *db.Repository, worktree *db.Worktree, action Action) bool {
+	role, ok := a.RepositoryRole(repo)
+	if !ok {
+		return false
+	}
+
+	required := requiredRole(action)
+	if action == ActionDelete && worktree.OwnerID != "" && worktree.OwnerID == a.UserID() {
+		required = db.TeamRoleDeveloper
+	}
+	return role.AtLeast(required)
+}
+
+// CheckRepository returns an error unless the caller may perform action on a
+// repository. Repositories the caller cannot see are reported as not found.
+func (a *Access) CheckRepository(repo *db.Repository, action Action) error {
+	if _, ok := a.RepositoryRole(repo); !ok {
+		return fmt.Errorf("repository not found")
+	}
+	if !a.CanRepository(repo, action) {
+		return permissionDenied(action, "repository", repo.ID)
+	}
+	return nil
+}
+
+// CheckWorktree returns an error unless the caller may perform action on a
+// worktree. Worktrees the caller cannot see are reported as not found.
+func (a *Access) CheckWorktree(repo *db.Repository, worktree *db.Worktree, action Action) error {
+	if _, ok := a.RepositoryRole(repo); !ok {
+		return fmt.Errorf("worktree not found")
+	}
+	if !a.CanWorktree(repo, worktree, action) {
+		return permissionDenied(action, "worktree", worktree.ID)
+	}
+	return nil
+}
+
+// permissionDenied returns the error for a forbidden action
+func permissionDenied(action Action, kind, id string) error {
+	return errors.New(errors.ErrPermissionDenied, fmt.Sprintf("not permitted to %s this %s", action, kind)).
+		WithContext(kind+"_id", id)
+}
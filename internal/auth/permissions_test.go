@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"testing"
+
+	"vibeman/internal/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccess_RepositoryRole(t *testing.T) {
+	bob := &Principal{User: &db.User{ID: "bob", Role: db.RoleUser}}
+	access := &Access{
+		principal:   bob,
+		memberships: map[string]db.TeamRole{"team-a": db.TeamRoleViewer},
+	}
+
+	tests := []struct {
+		name     string
+		repo     *db.Repository
+		wantRole db.TeamRole
+		wantOK   bool
+	}{
+		{"owner is admin", &db.Repository{OwnerID: "bob", TeamID: "team-b"}, db.TeamRoleAdmin, true},
+		{"team member gets team role", &db.Repository{OwnerID: "alice", TeamID: "team-a"}, db.TeamRoleViewer, true},
+		{"other team is hidden", &db.Repository{OwnerID: "alice", TeamID: "team-b"}, "", false},
+		{"private repository is hidden", &db.Repository{OwnerID: "alice"}, "", false},
+		{"legacy repository is shared", &db.Repository{}, db.TeamRoleDeveloper, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, ok := access.RepositoryRole(tt.repo)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantRole, role)
+		})
+	}
+}
+
+func TestAccess_Actions(t *testing.T) {
+	access := &Access{
+		principal: &Principal{User: &db.User{ID: "bob", Role: db.RoleUser}},
+		memberships: map[string]db.TeamRole{
+			"viewers":    db.TeamRoleViewer,
+			"developers": db.TeamRoleDeveloper,
+		},
+	}
+	viewed := &db.Repository{ID: "r1", TeamID: "viewers"}
+	developed := &db.Repository{ID: "r2", TeamID: "developers"}
+	own := &db.Worktree{ID: "w1", OwnerID: "bob"}
+	theirs := &db.Worktree{ID: "w2", OwnerID: "carol"}
+
+	assert.True(t, access.CanWorktree(viewed, theirs, ActionView))
+	assert.False(t, access.CanWorktree(viewed, theirs, ActionStart))
+	assert.False(t, access.CanWorktree(viewed, theirs, ActionAttach))
+
+	assert.True(t, access.CanWorktree(developed, theirs, ActionStart))
+	assert.True(t, access.CanWorktree(developed, theirs, ActionAttach))
+	assert.True(t, access.CanRepository(developed, ActionCreate))
+	assert.False(t, access.CanWorktree(developed, theirs, ActionDelete), "developers cannot delete other users' worktrees")
+	assert.True(t, access.CanWorktree(developed, own, ActionDelete), "developers can delete their own worktrees")
+	assert.False(t, access.CanRepository(developed, ActionDelete))
+
+	assert.EqualError(t, access.CheckWorktree(&db.Repository{OwnerID: "carol"}, theirs, ActionView), "worktree not found")
+	assert.Error(t, access.CheckWorktree(viewed, theirs, ActionStop))
+}
+
+func TestAccess_SelectedTeam(t *testing.T) {
+	access := &Access{
+		principal:   &Principal{User: &db.User{ID: "bob", Role: db.RoleUser}},
+		memberships: map[string]db.TeamRole{"team-a": db.TeamRoleAdmin},
+		team:        "team-a",
+	}
+
+	assert.True(t, access.CanRepository(&db.Repository{TeamID: "team-a"}, ActionView))
+	assert.False(t, access.CanRepository(&db.Repository{OwnerID: "bob"}, ActionView), "only the selected team's repositories are visible")
+	assert.False(t, access.CanRepository(&db.Repository{}, ActionView))
+}
+
+func TestAccess_Unrestricted(t *testing.T) {
+	private := &db.Repository{OwnerID: "carol", TeamID: "team-x"}
+
+	noAuth := &Access{}
+	assert.True(t, noAuth.CanRepository(private, ActionDelete))
+
+	admin := &Access{principal: &Principal{User: &db.User{ID: "alice", Role: db.RoleAdmin}}}
+	assert.True(t, admin.CanWorktree(private, &db.Worktree{}, ActionDelete))
+}
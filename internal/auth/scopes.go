@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Token scopes. Each scope implies the ones before it: write implies read,
+// and admin implies write.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// scopeLevels orders scopes by privilege
+var scopeLevels = map[string]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// ValidateScopes checks that all scopes are known
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if _, ok := scopeLevels[scope]; !ok {
+			return fmt.Errorf("unknown scope %q (valid scopes: read, write, admin)", scope)
+		}
+	}
+	return nil
+}
+
+// HasScope reports whether scopes grant the required scope
+func HasScope(scopes []string, required string) bool {
+	need := scopeLevels[required]
+	for _, scope := range scopes {
+		if scopeLevels[scope] >= need {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitScopes lowers scopes above the maximum scope to it, keeping what the
+// scopes and the maximum both grant
+func LimitScopes(scopes []string, max string) []string {
+	limited := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if scopeLevels[scope] > scopeLevels[max] {
+			scope = max
+		}
+		limited = append(limited, scope)
+	}
+	return limited
+}
+
+// RequiredScope returns the scope needed for a request with the given HTTP method
+func RequiredScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	default:
+		return ScopeWrite
+	}
+}
@@ -4,14 +4,16 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
+	"time"
 
 	"vibeman/internal/config"
 	"vibeman/internal/db"
 	"vibeman/internal/interfaces"
 	"vibeman/internal/logger"
+	"vibeman/internal/operations"
 	"vibeman/internal/types"
+	"vibeman/internal/validation"
 
 	"github.com/spf13/cobra"
 )
@@ -33,46 +35,94 @@ func CreateAICommand(cfg *config.Manager, containerMgr interfaces.ContainerManag
 		return result, nil
 	}
 
+	var agentName string
+	var askPermission bool
+	var sessionName string
+
 	cmd := &cobra.Command{
 		Use:   "ai [worktree]",
-		Short: "Start Claude CLI in AI container",
-		Long: `Start Claude CLI in the AI container for the current or specified worktree.
+		Short: "Start the AI agent in AI container",
+		Long: `Start the AI agent in the AI container for the current or specified worktree.
+
+The agent is set by agent in the repository's [repository.ai] section:
+claude (default), codex, aider, gemini or a custom one declared under
+[repository.ai.agents.<name>].
+
+The agent runs in a persistent tmux session in the container, named after
+the --agent given or "agent". Detaching (Ctrl-b d) or closing the terminal
+leaves it running; running the command again, or attaching from the web UI
+with the same session name, reattaches.
 
 Examples:
-  vibeman ai                    # Start Claude in current worktree's AI container
-  vibeman ai my-feature         # Start Claude in 'my-feature' worktree's AI container
+  vibeman ai                    # Start the agent in current worktree's AI container
+  vibeman ai my-feature         # Start the agent in 'my-feature' worktree's AI container
+  vibeman ai --agent codex      # Start Codex instead of the configured agent
 
 Subcommands:
-  attach    Attach to AI container shell
-  list      List all AI containers  
-  logs      Show AI container logs
-  claude    Start Claude CLI (same as default behavior)`,
+  attach     Attach to AI container shell
+  sessions   List, share and end persistent terminal sessions
+  recordings List recorded terminal sessions
+  replay     Play back a recorded terminal session
+  list       List all AI containers  
+  logs       Show AI container logs
+  claude     Start Claude CLI (same as --agent claude)
+  run        Queue a headless agent run
+  jobs       List, inspect and cancel headless agent runs
+  fanout     Run one prompt in several fresh worktrees and compare
+  mcp        Serve vibeman's MCP tools over stdio`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Default behavior: start Claude CLI in current worktree's AI container
-			return startClaudeInAIContainer(cmd.Context(), containerMgr, getWorktrees, args)
+			// Default behavior: start the agent in current worktree's AI container
+			return startAgentInAIContainer(cmd.Context(), containerMgr, getWorktrees, args, agentName, askPermission, sessionName)
 		},
 	}
 
+	cmd.Flags().StringVarP(&agentName, "agent", "a", "", "Agent to start instead of the configured one (claude, codex, aider, gemini or a custom agent)")
+	cmd.Flags().BoolVar(&askPermission, "ask", false, "Let the agent ask before acting instead of passing its permission flags")
+	cmd.Flags().StringVarP(&sessionName, "session", "s", "", "Terminal session to start or reattach to (default: the agent's name, or agent)")
+
 	// Add subcommands
 	cmd.AddCommand(createAIAttachCommand(containerMgr, getWorktrees))
 	cmd.AddCommand(createAIClaudeCommand(containerMgr, getWorktrees))
 	cmd.AddCommand(createAIListCommand(containerMgr))
 	cmd.AddCommand(createAILogsCommand(containerMgr, getWorktrees))
+	cmd.AddCommand(createAIRunCommand())
+	cmd.AddCommand(createAIJobsCommand())
+	cmd.AddCommand(createAIFanoutCommand())
+	cmd.AddCommand(createAISessionsCommand())
+	cmd.AddCommand(createAIRecordingsCommand())
+	cmd.AddCommand(createAIReplayCommand())
+	cmd.AddCommand(createAIMCPCommand())
 
 	return cmd
 }
 
 // createAIAttachCommand creates the 'ai attach' command
 func createAIAttachCommand(containerMgr interfaces.ContainerManager, getWorktrees func(context.Context) ([]*db.Worktree, error)) *cobra.Command {
+	var sessionName string
+	var readOnly bool
+	var shell string
+
 	cmd := &cobra.Command{
 		Use:   "attach [worktree-name]",
 		Short: "Attach to an AI container",
-		Long:  "Attach to the AI container associated with a worktree",
+		Long: `Attach to a shell in the AI container associated with a worktree.
+
+The shell runs in a persistent tmux session, "shell" unless --session names
+another, which keeps running after you detach (Ctrl-b d) or disconnect.
+Attaching again, from here or the web UI, reattaches to it; --read-only
+watches a session without typing in it.
+
+The shell is set by shell in the repository's [repository.ai] section,
+zsh by default; --shell opens another one in a new session.`,
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
+			if err := operations.ValidateAISessionName(sessionName); err != nil {
+				return err
+			}
+
 			// Get current worktree if not specified
 			worktreeName := ""
 			if len(args) > 0 {
@@ -108,110 +158,89 @@ func createAIAttachCommand(containerMgr interfaces.ContainerManager, getWorktree
 				return fmt.Errorf("failed to list containers: %w", err)
 			}
 
-			var aiContainerName string
+			var aiContainer *types.Container
 			for _, c := range containers {
 				if c.Type == "ai" && strings.Contains(c.Name, worktreeName) && c.Status == "running" {
-					aiContainerName = c.Name
+					aiContainer = c
 					break
 				}
 			}
 
-			if aiContainerName == "" {
+			if aiContainer == nil {
 				return fmt.Errorf("no running AI container found for worktree: %s", worktreeName)
 			}
 
 			// Attach to the container
 			logger.WithFields(logger.Fields{
-				"container": aiContainerName,
+				"container": aiContainer.Name,
 				"worktree":  worktreeName,
+				"session":   sessionName,
 			}).Info("Attaching to AI container")
 
-			// Use docker attach command
-			attachCmd := exec.Command("docker", "exec", "-it", aiContainerName, "/bin/zsh")
-			attachCmd.Stdin = os.Stdin
-			attachCmd.Stdout = os.Stdout
-			attachCmd.Stderr = os.Stderr
+			shellCommand, err := attachShell(ctx, getWorktrees, worktreeName, shell)
+			if err != nil {
+				return err
+			}
 
-			return attachCmd.Run()
+			// Attach to the session through docker exec, starting it if needed
+			command := operations.AISessionCommand(sessionName, shellCommand)
+			if readOnly {
+				command = operations.AISessionViewCommand(sessionName)
+			}
+			return runAITerminal(aiContainerRepository(aiContainer, worktreeName), worktreeName, sessionName, aiContainer.Name, command)
 		},
 	}
 
+	cmd.Flags().StringVarP(&sessionName, "session", "s", "shell", "Terminal session to start or reattach to")
+	cmd.Flags().BoolVarP(&readOnly, "read-only", "r", false, "Watch an existing session without typing in it")
+	cmd.Flags().StringVar(&shell, "shell", "", "Shell to start instead of the configured one (bash, sh, zsh, fish or dash)")
+
 	return cmd
 }
 
+// attachShell returns the shell 'ai attach' starts: the one given, or the one
+// the worktree's repository configures
+func attachShell(ctx context.Context, getWorktrees func(context.Context) ([]*db.Worktree, error), worktreeName, shell string) ([]string, error) {
+	if shell != "" {
+		if err := validation.ShellCommand(shell); err != nil {
+			return nil, err
+		}
+		return []string{shell}, nil
+	}
+
+	aiConfig := &config.AIConfig{}
+	if worktrees, err := getWorktrees(ctx); err == nil {
+		for _, wt := range worktrees {
+			if wt.Name != worktreeName {
+				continue
+			}
+			if repoConfig, err := config.ParseRepositoryConfig(wt.Path); err == nil {
+				aiConfig = &repoConfig.Repository.AI
+			}
+			break
+		}
+	}
+	return aiConfig.ShellCommand(), nil
+}
+
 // createAIClaudeCommand creates the 'ai claude' command
 func createAIClaudeCommand(containerMgr interfaces.ContainerManager, getWorktrees func(context.Context) ([]*db.Worktree, error)) *cobra.Command {
+	var askPermission bool
+	var sessionName string
+
 	cmd := &cobra.Command{
 		Use:   "claude [worktree-name]",
 		Short: "Start Claude CLI in an AI container",
-		Long:  "Start Claude CLI in the AI container associated with a worktree",
+		Long:  "Start Claude CLI in the AI container associated with a worktree, whatever agent the repository configures",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
-
-			// Get current worktree if not specified
-			worktreeName := ""
-			if len(args) > 0 {
-				worktreeName = args[0]
-			} else {
-				// Try to detect current worktree
-				cwd, err := os.Getwd()
-				if err != nil {
-					return fmt.Errorf("failed to get current directory: %w", err)
-				}
-
-				// List all worktrees and check if we're in one
-				worktrees, err := getWorktrees(ctx)
-				if err != nil {
-					return fmt.Errorf("failed to list worktrees: %w", err)
-				}
-
-				for _, wt := range worktrees {
-					if strings.HasPrefix(cwd, wt.Path) {
-						worktreeName = wt.Name
-						break
-					}
-				}
-
-				if worktreeName == "" {
-					return fmt.Errorf("not in a worktree directory, please specify worktree name")
-				}
-			}
-
-			// Find AI container for this worktree
-			containers, err := containerMgr.List(ctx)
-			if err != nil {
-				return fmt.Errorf("failed to list containers: %w", err)
-			}
-
-			var aiContainerName string
-			for _, c := range containers {
-				if c.Type == "ai" && strings.Contains(c.Name, worktreeName) && c.Status == "running" {
-					aiContainerName = c.Name
-					break
-				}
-			}
-
-			if aiContainerName == "" {
-				return fmt.Errorf("no running AI container found for worktree: %s", worktreeName)
-			}
-
-			// Start Claude CLI in the container
-			logger.WithFields(logger.Fields{
-				"container": aiContainerName,
-				"worktree":  worktreeName,
-			}).Info("Starting Claude CLI in AI container")
-
-			// Use docker exec to run claude
-			claudeCmd := exec.Command("docker", "exec", "-it", aiContainerName, "claude", "--dangerously-skip-permissions")
-			claudeCmd.Stdin = os.Stdin
-			claudeCmd.Stdout = os.Stdout
-			claudeCmd.Stderr = os.Stderr
-
-			return claudeCmd.Run()
+			return startAgentInAIContainer(context.Background(), containerMgr, getWorktrees, args, "claude", askPermission, sessionName)
 		},
 	}
 
+	cmd.Flags().BoolVar(&askPermission, "ask", false, "Let Claude ask before acting instead of skipping permission prompts")
+	cmd.Flags().StringVarP(&sessionName, "session", "s", "claude", "Terminal session to start or reattach to")
+
 	return cmd
 }
 
@@ -220,7 +249,7 @@ func createAIListCommand(containerMgr interfaces.ContainerManager) *cobra.Comman
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List AI containers",
-		Long:  "List all AI containers and their status",
+		Long:  "List all AI containers, their status and, when the server is running, whether the agents in them are working, waiting for input or finished",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
@@ -243,20 +272,28 @@ func createAIListCommand(containerMgr interfaces.ContainerManager) *cobra.Comman
 				return nil
 			}
 
+			// What the agents in them are doing is only known to the server
+			agents := listAgentActivity(ctx, cmd)
+
 			// Print header
-			fmt.Printf("%-20s %-30s %-15s %-20s %-15s\n", "CONTAINER ID", "NAME", "STATUS", "IMAGE", "WORKTREE")
-			fmt.Println(strings.Repeat("-", 100))
+			fmt.Printf("%-20s %-30s %-15s %-20s %-15s %s\n", "CONTAINER ID", "NAME", "STATUS", "IMAGE", "WORKTREE", "AGENTS")
+			fmt.Println(strings.Repeat("-", 120))
 
 			// Print containers
 			for _, c := range aiContainers {
 				// Extract worktree name from container name
 				worktreeName := extractWorktreeFromAIContainer(c.Name)
-				fmt.Printf("%-20s %-30s %-15s %-20s %-15s\n", 
+				agentList := "-"
+				if len(agents[c.Name]) > 0 {
+					agentList = strings.Join(agents[c.Name], ", ")
+				}
+				fmt.Printf("%-20s %-30s %-15s %-20s %-15s %s\n", 
 					truncateString(c.ID, 20), 
 					truncateString(c.Name, 30), 
 					c.Status, 
 					truncateString(c.Image, 20),
-					worktreeName)
+					worktreeName,
+					agentList)
 			}
 
 			return nil
@@ -266,6 +303,31 @@ func createAIListCommand(containerMgr interfaces.ContainerManager) *cobra.Comman
 	return cmd
 }
 
+// listAgentActivity asks the server what the agents in AI terminal sessions
+// are doing, returning "session: state age" descriptions keyed by container.
+// Without a reachable server nothing is known about them.
+func listAgentActivity(ctx context.Context, cmd *cobra.Command) map[string][]string {
+	c, err := newAuthClient(cmd)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	sessions, err := c.ListAISessions(ctx, "")
+	if err != nil {
+		logger.WithError(err).Debug("Failed to get agent activity from the server")
+		return nil
+	}
+
+	agents := map[string][]string{}
+	for _, session := range sessions {
+		if session.Agent != nil {
+			agents[session.Container] = append(agents[session.Container], session.Name+": "+formatAgentActivity(session.Agent))
+		}
+	}
+	return agents
+}
+
 // createAILogsCommand creates the 'ai logs' command
 func createAILogsCommand(containerMgr interfaces.ContainerManager, getWorktrees func(context.Context) ([]*db.Worktree, error)) *cobra.Command {
 	var follow bool
@@ -365,12 +427,36 @@ func truncateString(s string, length int) string {
 	return s[:length-3] + "..."
 }
 
-// startClaudeInAIContainer starts Claude CLI in the AI container for the current or specified worktree
-func startClaudeInAIContainer(ctx context.Context, containerMgr interfaces.ContainerManager, getWorktrees func(context.Context) ([]*db.Worktree, error), args []string) error {
+// startAgentInAIContainer starts an AI agent in a persistent session in the
+// AI container for the current or specified worktree, or reattaches to the
+// session if it is already running. An empty agentName runs the agent the
+// worktree's repository configures and an empty sessionName uses the same
+// default session as the web UI.
+func startAgentInAIContainer(ctx context.Context, containerMgr interfaces.ContainerManager, getWorktrees func(context.Context) ([]*db.Worktree, error), args []string, agentName string, askPermission bool, sessionName string) error {
+	if sessionName == "" {
+		sessionName = operations.DefaultAISessionName("agent", agentName)
+	}
+	if err := operations.ValidateAISessionName(sessionName); err != nil {
+		return err
+	}
+
+	// List all worktrees to find the current or named one
+	worktrees, err := getWorktrees(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list worktrees: %w", err)
+	}
+
 	// Get current worktree if not specified
+	var worktree *db.Worktree
 	worktreeName := ""
 	if len(args) > 0 {
 		worktreeName = args[0]
+		for _, wt := range worktrees {
+			if wt.Name == worktreeName {
+				worktree = wt
+				break
+			}
+		}
 	} else {
 		// Try to detect current worktree
 		cwd, err := os.Getwd()
@@ -378,14 +464,9 @@ func startClaudeInAIContainer(ctx context.Context, containerMgr interfaces.Conta
 			return fmt.Errorf("failed to get current directory: %w", err)
 		}
 
-		// List all worktrees and check if we're in one
-		worktrees, err := getWorktrees(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to list worktrees: %w", err)
-		}
-
 		for _, wt := range worktrees {
 			if strings.HasPrefix(cwd, wt.Path) {
+				worktree = wt
 				worktreeName = wt.Name
 				break
 			}
@@ -396,39 +477,59 @@ func startClaudeInAIContainer(ctx context.Context, containerMgr interfaces.Conta
 		}
 	}
 
+	// The agent and its settings come from the worktree's repository
+	// configuration
+	aiConfig := &config.AIConfig{}
+	if worktree != nil {
+		if repoConfig, err := config.ParseRepositoryConfig(worktree.Path); err == nil {
+			aiConfig = &repoConfig.Repository.AI
+		}
+	}
+	agentName, agent, err := aiConfig.ResolveAgent(agentName)
+	if err != nil {
+		return err
+	}
+
 	// Find AI container for this worktree
 	containers, err := containerMgr.List(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list containers: %w", err)
 	}
 
-	var aiContainerName string
+	var aiContainer *types.Container
 	for _, c := range containers {
 		if c.Type == "ai" && strings.Contains(c.Name, worktreeName) && c.Status != "exited" {
 			// Check if container is running (handle different status formats)
 			status := strings.ToLower(c.Status)
 			if strings.Contains(status, "running") || strings.Contains(status, "up") {
-				aiContainerName = c.Name
+				aiContainer = c
 				break
 			}
 		}
 	}
 
-	if aiContainerName == "" {
+	if aiContainer == nil {
 		return fmt.Errorf("no running AI container found for worktree: %s\n\nTry starting the worktree first with: vibeman start %s", worktreeName, worktreeName)
 	}
 
-	// Start Claude CLI in the container
+	// Start the agent in the container
 	logger.WithFields(logger.Fields{
-		"container": aiContainerName,
+		"container": aiContainer.Name,
 		"worktree":  worktreeName,
-	}).Info("Starting Claude CLI in AI container")
+		"agent":     agentName,
+		"session":   sessionName,
+	}).Info("Starting AI agent in AI container")
 
-	// Use docker exec to run claude
-	claudeCmd := exec.Command("docker", "exec", "-it", aiContainerName, "claude", "--dangerously-skip-permissions")
-	claudeCmd.Stdin = os.Stdin
-	claudeCmd.Stdout = os.Stdout
-	claudeCmd.Stderr = os.Stderr
+	// Use docker exec to run the agent in its session
+	command := operations.AISessionCommand(sessionName, agent.Launch(askPermission))
+	return runAITerminal(aiContainerRepository(aiContainer, worktreeName), worktreeName, sessionName, aiContainer.Name, command)
+}
 
-	return claudeCmd.Run()
-}
\ No newline at end of file
+// aiContainerRepository returns the name of the repository a worktree's AI
+// container belongs to
+func aiContainerRepository(container *types.Container, worktreeName string) string {
+	if repoName, ok := operations.AIContainerRepository(container.Name, worktreeName); ok {
+		return repoName
+	}
+	return container.Repository
+}
@@ -0,0 +1,519 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"vibeman/internal/client"
+	"vibeman/internal/db"
+	"vibeman/internal/operations"
+
+	"github.com/spf13/cobra"
+)
+
+// createAIRunCommand creates the 'ai run' command. Jobs are queued on the
+// server, which runs them and enforces the concurrency limit, so the command
+// always goes through the API.
+func createAIRunCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <worktree>",
+		Short: "Queue a headless agent run in a worktree's AI container",
+		Long: `Queue a non-interactive agent run (e.g. claude -p) in the AI container of a
+worktree. The prompt is read from --prompt-file ('-' for stdin) or --prompt.
+
+The server runs up to [ai] max_jobs jobs at once and records each job's
+transcript, exit code and the git diff it produced. Use 'vibeman ai jobs'
+to follow, inspect or cancel jobs.
+
+Examples:
+  vibeman ai run my-feature --prompt-file task.md
+  vibeman ai run my-feature --prompt "Fix the failing tests" --wait
+  vibeman ai run my-feature --prompt-file task.md --agent codex`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompt, err := readJobPrompt(cmd)
+			if err != nil {
+				return err
+			}
+			agent, _ := cmd.Flags().GetString("agent")
+			wait, _ := cmd.Flags().GetBool("wait")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			worktree, err := findAIJobWorktree(cmd.Context(), c, args[0])
+			if err != nil {
+				return err
+			}
+
+			job, err := c.CreateAIJob(cmd.Context(), worktree.ID, client.CreateAIJobRequest{
+				Prompt: prompt,
+				Agent:  agent,
+			})
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "✓ Queued AI job %s (%s) in worktree %s\n", job.ID, job.Agent, worktree.Name)
+			if !wait {
+				fmt.Fprintf(out, "Follow it with: vibeman ai jobs show %s\n", job.ID)
+				return nil
+			}
+
+			job, err = followAIJob(cmd.Context(), out, c, job.ID)
+			if err != nil {
+				return err
+			}
+			return aiJobError(job)
+		},
+	}
+
+	cmd.Flags().StringP("prompt-file", "f", "", "File containing the prompt, or '-' for stdin")
+	cmd.Flags().StringP("prompt", "p", "", "Prompt text")
+	cmd.Flags().StringP("agent", "a", "", "Agent to run instead of the configured one")
+	cmd.Flags().BoolP("wait", "w", false, "Wait for the job to finish, streaming its output")
+	cmd.MarkFlagsMutuallyExclusive("prompt-file", "prompt")
+
+	return cmd
+}
+
+// createAIJobsCommand creates the 'ai jobs' command and its subcommands
+func createAIJobsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "List headless AI jobs",
+		Long: `List headless AI jobs, newest first.
+
+Subcommands:
+  show      Show a job's status, transcript or diff
+  cancel    Cancel a queued or running job`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			worktreeName, _ := cmd.Flags().GetString("worktree")
+			status, _ := cmd.Flags().GetString("status")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			worktreeID := ""
+			names := make(map[string]string)
+			if worktreeName != "" {
+				worktree, err := findAIJobWorktree(cmd.Context(), c, worktreeName)
+				if err != nil {
+					return err
+				}
+				worktreeID = worktree.ID
+			}
+			if worktrees, err := c.ListRepositoryWorktrees(cmd.Context(), ""); err == nil {
+				for _, wt := range worktrees {
+					names[wt.ID] = wt.Name
+				}
+			}
+
+			jobs, err := c.ListAIJobs(cmd.Context(), worktreeID, status)
+			if err != nil {
+				return err
+			}
+			return printAIJobs(cmd.OutOrStdout(), jobs, names)
+		},
+	}
+	cmd.Flags().String("worktree", "", "Only show jobs of this worktree (name or ID)")
+	cmd.Flags().String("status", "", "Only show jobs in this status (queued, running, succeeded, failed, cancelled)")
+
+	showCmd := &cobra.Command{
+		Use:   "show <job-id>",
+		Short: "Show an AI job",
+		Long: `Show an AI job's status. --transcript prints the agent's output and --diff
+the changes it made, suitable for piping to git apply.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			showDiff, _ := cmd.Flags().GetBool("diff")
+			showTranscript, _ := cmd.Flags().GetBool("transcript")
+			follow, _ := cmd.Flags().GetBool("follow")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if follow {
+				job, err := followAIJob(cmd.Context(), out, c, args[0])
+				if err != nil {
+					return err
+				}
+				return aiJobError(job)
+			}
+
+			job, err := c.GetAIJob(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			switch {
+			case showDiff:
+				fmt.Fprint(out, job.Diff)
+			case showTranscript:
+				fmt.Fprint(out, job.Transcript)
+			default:
+				printAIJob(out, job)
+			}
+			return nil
+		},
+	}
+	showCmd.Flags().Bool("diff", false, "Print the diff the job produced")
+	showCmd.Flags().Bool("transcript", false, "Print the agent's output")
+	showCmd.Flags().Bool("follow", false, "Stream the agent's output until the job finishes")
+	showCmd.MarkFlagsMutuallyExclusive("diff", "transcript", "follow")
+	cmd.AddCommand(showCmd)
+
+	cancelCmd := &cobra.Command{
+		Use:   "cancel <job-id>",
+		Short: "Cancel a queued or running AI job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			if err := c.CancelAIJob(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Cancelled AI job %s\n", args[0])
+			return nil
+		},
+	}
+	cmd.AddCommand(cancelCmd)
+
+	return cmd
+}
+
+// createAIFanoutCommand creates the 'ai fanout' command
+func createAIFanoutCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fanout",
+		Short: "Run one prompt in several fresh worktrees and compare the results",
+		Long: `Create --count worktrees from the same base branch, run the same headless
+agent task in each and report how the attempts compare: the agent's exit
+status, how long it took, the size of its diff and, with --test, whether the
+test command passes against its changes. Keep the best attempt and remove
+the rest with 'vibeman worktree remove'.
+
+The repository is --repo, or the one the current directory belongs to.
+
+Examples:
+  vibeman ai fanout --count 3 --prompt-file task.md
+  vibeman ai fanout --count 2 --prompt-file task.md --test "go test ./..."
+  vibeman ai fanout --repo myapp --count 3 --prompt "Speed up the build" --name build-speed`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompt, err := readJobPrompt(cmd)
+			if err != nil {
+				return err
+			}
+			repoName, _ := cmd.Flags().GetString("repo")
+			req := client.AIFanoutRequest{Prompt: prompt}
+			req.Count, _ = cmd.Flags().GetInt("count")
+			req.Agent, _ = cmd.Flags().GetString("agent")
+			req.BaseBranch, _ = cmd.Flags().GetString("base-branch")
+			req.NamePrefix, _ = cmd.Flags().GetString("name")
+			req.TestCommand, _ = cmd.Flags().GetString("test")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			repo, err := findFanoutRepository(cmd.Context(), c, repoName)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Running %d attempts in %s...\n", req.Count, repo.Name)
+			accepted, err := c.CreateAIFanout(cmd.Context(), repo.ID, req)
+			if err != nil {
+				return err
+			}
+			op, err := followOperation(cmd.Context(), out, c, accepted.OperationID)
+			if err != nil {
+				return err
+			}
+
+			report, err := decodeFanoutReport(op)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+			if err := printFanoutReport(out, report); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "\nKeep the best attempt and remove the rest with: vibeman worktree remove %s <worktree-name>\n", repo.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntP("count", "n", 3, fmt.Sprintf("Number of worktrees to run the prompt in (at most %d)", operations.MaxAIFanout))
+	cmd.Flags().StringP("prompt-file", "f", "", "File containing the prompt, or '-' for stdin")
+	cmd.Flags().StringP("prompt", "p", "", "Prompt text")
+	cmd.Flags().StringP("agent", "a", "", "Agent to run instead of the configured one")
+	cmd.Flags().StringP("repo", "r", "", "Repository to fan out in (default: the current directory's)")
+	cmd.Flags().String("base-branch", "", "Branch the worktrees start from (default: the repository's default branch)")
+	cmd.Flags().String("name", "", "Name prefix of the worktrees, named <name>-1 to <name>-N (default: fanout-<id>)")
+	cmd.Flags().String("test", "", "Test command to run in each AI container once its agent finishes")
+	cmd.MarkFlagsMutuallyExclusive("prompt-file", "prompt")
+
+	return cmd
+}
+
+// findFanoutRepository finds a repository tracked by the server by name or ID,
+// or the one containing the current directory when nameOrID is empty
+func findFanoutRepository(ctx context.Context, c *client.Client, nameOrID string) (*db.Repository, error) {
+	repos, err := c.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if nameOrID != "" {
+		for _, repo := range repos {
+			if repo.ID == nameOrID || repo.Name == nameOrID {
+				return repo, nil
+			}
+		}
+		return nil, fmt.Errorf("repository not found: %s", nameOrID)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	worktrees, err := c.ListRepositoryWorktrees(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	repositoryID := ""
+	for _, wt := range worktrees {
+		if isWithin(cwd, wt.Path) {
+			repositoryID = wt.RepositoryID
+			break
+		}
+	}
+	for _, repo := range repos {
+		if repo.ID == repositoryID || (repositoryID == "" && isWithin(cwd, repo.Path)) {
+			return repo, nil
+		}
+	}
+	return nil, fmt.Errorf("not in a repository directory, please specify --repo")
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// decodeFanoutReport reads the report from a finished fan-out operation
+func decodeFanoutReport(op *db.Operation) (*operations.AIFanoutReport, error) {
+	data, err := json.Marshal(op.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fan-out report: %w", err)
+	}
+	var report operations.AIFanoutReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to read fan-out report: %w", err)
+	}
+	return &report, nil
+}
+
+// printFanoutReport writes the attempts of a fan-out as a table
+func printFanoutReport(out io.Writer, report *operations.AIFanoutReport) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKTREE\tSTATUS\tEXIT\tDURATION\tFILES\tLINES\tTESTS")
+	for _, attempt := range report.Attempts {
+		status := string(attempt.Status)
+		if status == "" {
+			status = "not run"
+		}
+		exitCode := "-"
+		if attempt.ExitCode != nil {
+			exitCode = fmt.Sprint(*attempt.ExitCode)
+		}
+		duration := "-"
+		if attempt.Duration > 0 {
+			duration = formatDuration(time.Duration(attempt.Duration * float64(time.Second)))
+		}
+		tests := "-"
+		switch {
+		case attempt.TestExitCode == nil:
+		case attempt.TestsPassed():
+			tests = "passed"
+		default:
+			tests = fmt.Sprintf("failed (%d)", *attempt.TestExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t+%d -%d\t%s\n",
+			attempt.Worktree, status, exitCode, duration, attempt.FilesChanged, attempt.Insertions, attempt.Deletions, tests)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, attempt := range report.Attempts {
+		if attempt.Error != "" {
+			fmt.Fprintf(out, "%s: %s\n", attempt.Worktree, attempt.Error)
+		}
+	}
+	return nil
+}
+
+// readJobPrompt returns the prompt given by --prompt-file or --prompt
+func readJobPrompt(cmd *cobra.Command) (string, error) {
+	file, _ := cmd.Flags().GetString("prompt-file")
+	prompt, _ := cmd.Flags().GetString("prompt")
+
+	switch file {
+	case "":
+	case "-":
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt from stdin: %w", err)
+		}
+		prompt = string(data)
+	default:
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		prompt = string(data)
+	}
+
+	if strings.TrimSpace(prompt) == "" {
+		return "", fmt.Errorf("a prompt is required, use --prompt-file or --prompt")
+	}
+	return prompt, nil
+}
+
+// findAIJobWorktree finds a worktree tracked by the server by ID or name
+func findAIJobWorktree(ctx context.Context, c *client.Client, nameOrID string) (*db.Worktree, error) {
+	worktrees, err := c.ListRepositoryWorktrees(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []db.Worktree
+	for _, wt := range worktrees {
+		if wt.ID == nameOrID {
+			return &wt, nil
+		}
+		if wt.Name == nameOrID {
+			matches = append(matches, wt)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("worktree not found: %s", nameOrID)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("worktree name %q is ambiguous, use its ID", nameOrID)
+	}
+}
+
+// followAIJob polls a job until it finishes, writing its output as it
+// arrives. Interrupting the wait cancels the job.
+func followAIJob(ctx context.Context, out io.Writer, c *client.Client, id string) (*db.AIJob, error) {
+	// Keep polling after ctx is cancelled so the final state is reported
+	pollCtx := context.WithoutCancel(ctx)
+	done := ctx.Done()
+
+	ticker := time.NewTicker(operationPollInterval)
+	defer ticker.Stop()
+
+	printed := ""
+	for {
+		job, err := c.GetAIJob(pollCtx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		// The stored transcript only keeps its tail once it grows long
+		if rest, ok := strings.CutPrefix(job.Transcript, printed); ok {
+			fmt.Fprint(out, rest)
+		}
+		printed = job.Transcript
+
+		if job.Status.IsFinished() {
+			return job, nil
+		}
+
+		select {
+		case <-done:
+			done = nil
+			fmt.Fprintln(out, "Cancelling AI job...")
+			if err := c.CancelAIJob(pollCtx, id); err != nil {
+				return job, err
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// aiJobError reports how a finished job ended
+func aiJobError(job *db.AIJob) error {
+	if job.Status == db.AIJobSucceeded {
+		return nil
+	}
+	if job.Error != "" {
+		return fmt.Errorf("AI job %s: %s", job.Status, job.Error)
+	}
+	return fmt.Errorf("AI job %s", job.Status)
+}
+
+// printAIJobs writes AI jobs as a table
+func printAIJobs(out io.Writer, jobs []*db.AIJob, worktreeNames map[string]string) error {
+	if len(jobs) == 0 {
+		fmt.Fprintln(out, "No AI jobs found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tWORKTREE\tAGENT\tSTATUS\tEXIT\tCREATED")
+	for _, job := range jobs {
+		worktree := worktreeNames[job.WorktreeID]
+		if worktree == "" {
+			worktree = job.WorktreeID
+		}
+		exitCode := "-"
+		if job.ExitCode != nil {
+			exitCode = fmt.Sprint(*job.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			job.ID, worktree, job.Agent, job.Status, exitCode, job.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// printAIJob writes the details of an AI job
+func printAIJob(out io.Writer, job *db.AIJob) {
+	fmt.Fprintf(out, "Job:       %s\n", job.ID)
+	fmt.Fprintf(out, "Worktree:  %s\n", job.WorktreeID)
+	fmt.Fprintf(out, "Agent:     %s\n", job.Agent)
+	fmt.Fprintf(out, "Status:    %s\n", job.Status)
+	if job.ExitCode != nil {
+		fmt.Fprintf(out, "Exit code: %d\n", *job.ExitCode)
+	}
+	if job.Error != "" {
+		fmt.Fprintf(out, "Error:     %s\n", job.Error)
+	}
+	fmt.Fprintf(out, "Created:   %s\n", job.CreatedAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(out, "Started:   %s\n", formatOptionalTime(job.StartedAt, "-"))
+	fmt.Fprintf(out, "Finished:  %s\n", formatOptionalTime(job.FinishedAt, "-"))
+	fmt.Fprintf(out, "Diff:      %d lines\n", strings.Count(job.Diff, "\n"))
+}
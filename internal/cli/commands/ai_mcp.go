@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"vibeman/internal/mcp"
+
+	"github.com/spf13/cobra"
+)
+
+// createAIMCPCommand creates the 'ai mcp' command
+func createAIMCPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve vibeman's MCP tools over stdio",
+		Long: `Serve vibeman's MCP tools to an agent over stdio, for agents that start
+their MCP servers as commands rather than calling them over HTTP.
+
+Messages are forwarded to the server's MCP endpoint with the worktree's MCP
+token. AI containers are given both as VIBEMAN_MCP_URL and VIBEMAN_MCP_TOKEN
+when they start, so inside one no flags are needed.
+
+Examples:
+  vibeman ai mcp
+  vibeman ai mcp --url http://localhost:8080/api/mcp --token <token>`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			url, _ := cmd.Flags().GetString("url")
+			token, _ := cmd.Flags().GetString("token")
+			if url == "" {
+				url = os.Getenv("VIBEMAN_MCP_URL")
+			}
+			if token == "" {
+				token = os.Getenv("VIBEMAN_MCP_TOKEN")
+			}
+			if url == "" || token == "" {
+				return fmt.Errorf("--url and --token are required outside AI containers")
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			forwarder := &mcp.Forwarder{URL: url, Token: token}
+			return forwarder.ServeStdio(ctx, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().String("url", "", "MCP endpoint of the vibeman server (default $VIBEMAN_MCP_URL)")
+	cmd.Flags().String("token", "", "MCP token of the worktree (default $VIBEMAN_MCP_TOKEN)")
+
+	return cmd
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"vibeman/internal/asciicast"
+	"vibeman/internal/client"
+
+	"github.com/spf13/cobra"
+)
+
+// createAIRecordingsCommand creates the 'ai recordings' command
+func createAIRecordingsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recordings",
+		Short: "List recorded AI terminal sessions",
+		Long: `List the recordings of AI terminal sessions, newest first.
+
+Every AI terminal session, from 'vibeman ai', 'vibeman ai attach' or the
+web UI, is recorded once in asciicast v2 format, including what it shows
+while nobody is attached. Play one back with 'vibeman ai replay <name>'.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			worktree, _ := cmd.Flags().GetString("worktree")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			recordings, err := c.ListAIRecordings(cmd.Context(), worktree)
+			if err != nil {
+				return err
+			}
+			return printAIRecordings(cmd.OutOrStdout(), recordings)
+		},
+	}
+	cmd.Flags().String("worktree", "", "Only show recordings of this worktree")
+
+	return cmd
+}
+
+// createAIReplayCommand creates the 'ai replay' command
+func createAIReplayCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay <recording>",
+		Short: "Play back a recorded AI terminal session",
+		Long: `Play back a recorded AI terminal session in this terminal with its
+original timing.
+
+The recording is a .cast file on disk, or the name of a recording listed by
+'vibeman ai recordings', which is downloaded from the server. Recordings are
+asciicast v2 files, so asciinema can play them as well.
+
+Examples:
+  vibeman ai replay 20261018-142447.120519-agent-web.cast
+  vibeman ai replay session.cast --speed 2 --idle-limit 2s`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			speed, _ := cmd.Flags().GetFloat64("speed")
+			idleLimit, _ := cmd.Flags().GetDuration("idle-limit")
+			worktree, _ := cmd.Flags().GetString("worktree")
+			if speed <= 0 {
+				return fmt.Errorf("--speed must be greater than 0")
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			recording, err := openAIRecording(ctx, cmd, args[0], worktree)
+			if err != nil {
+				return err
+			}
+			defer recording.Close()
+
+			decoder, err := asciicast.NewDecoder(recording)
+			if err != nil {
+				return err
+			}
+			err = asciicast.Play(ctx, cmd.OutOrStdout(), decoder, asciicast.PlayOptions{Speed: speed, IdleLimit: idleLimit})
+			if err == context.Canceled {
+				return nil
+			}
+			return err
+		},
+	}
+	cmd.Flags().Float64("speed", 1, "Playback speed, e.g. 2 for twice as fast")
+	cmd.Flags().Duration("idle-limit", 0, "Shorten pauses longer than this, e.g. 2s")
+	cmd.Flags().String("worktree", "", "Worktree the named recording belongs to")
+
+	return cmd
+}
+
+// openAIRecording opens the recording at path, or downloads the recording
+// with that name from the server
+func openAIRecording(ctx context.Context, cmd *cobra.Command, name, worktree string) (io.ReadCloser, error) {
+	if file, err := os.Open(name); err == nil {
+		return file, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	c, err := newAuthClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if worktree == "" {
+		recordings, err := c.ListAIRecordings(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, recording := range recordings {
+			if recording.Name == name {
+				worktree = recording.Worktree
+				break
+			}
+		}
+		if worktree == "" {
+			return nil, fmt.Errorf("recording %s not found", name)
+		}
+	}
+	return c.DownloadAIRecording(ctx, worktree, name)
+}
+
+// printAIRecordings writes recorded AI terminal sessions as a table
+func printAIRecordings(out io.Writer, recordings []client.AIRecording) error {
+	if len(recordings) == 0 {
+		fmt.Fprintln(out, "No AI recordings found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tWORKTREE\tSESSION\tSOURCE\tDURATION\tSIZE\tSTARTED")
+	for _, recording := range recordings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			recording.Name, recording.Worktree, recording.Session, recording.Source,
+			formatDuration(time.Duration(recording.Duration*float64(time.Second))), formatSize(recording.Size),
+			recording.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"vibeman/internal/client"
+
+	"github.com/spf13/cobra"
+)
+
+// createAISessionsCommand creates the 'ai sessions' command. Share links are
+// held by the server, so the command always goes through the API.
+func createAISessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List persistent AI terminal sessions",
+		Long: `List the persistent terminal sessions running in AI containers.
+
+Shells and agents started with 'vibeman ai', 'vibeman ai attach' or the web
+UI run in tmux sessions that keep going when you disconnect. Reattach with
+'vibeman ai attach <worktree> --session <name>'.
+
+Subcommands:
+  share     Create a read-only link to watch a session
+  unshare   Revoke a session's share links
+  kill      End a session and whatever runs in it`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			worktree, _ := cmd.Flags().GetString("worktree")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			sessions, err := c.ListAISessions(cmd.Context(), worktree)
+			if err != nil {
+				return err
+			}
+			return printAISessions(cmd.OutOrStdout(), sessions)
+		},
+	}
+	cmd.Flags().String("worktree", "", "Only show sessions of this worktree")
+
+	shareCmd := &cobra.Command{
+		Use:   "share <worktree> <session>",
+		Short: "Create a read-only share link to a session",
+		Long: `Create a link anyone can use to watch a session without logging in. The
+link cannot type in the session and stops working when it expires, is
+revoked with 'vibeman ai sessions unshare' or the server restarts.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ttl, _ := cmd.Flags().GetDuration("ttl")
+
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			share, err := c.ShareAISession(cmd.Context(), args[0], args[1], ttl)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "✓ Shared session %s of %s\n", args[1], args[0])
+			fmt.Fprintf(out, "  URL:     %s\n", share.URL)
+			fmt.Fprintf(out, "  Expires: %s\n", share.ExpiresAt.Local().Format(time.RFC3339))
+			return nil
+		},
+	}
+	shareCmd.Flags().Duration("ttl", 0, "How long the link works (default 24h, at most 168h)")
+	cmd.AddCommand(shareCmd)
+
+	unshareCmd := &cobra.Command{
+		Use:   "unshare <worktree> <session>",
+		Short: "Revoke the share links to a session",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			if err := c.UnshareAISession(cmd.Context(), args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Revoked share links to session %s of %s\n", args[1], args[0])
+			return nil
+		},
+	}
+	cmd.AddCommand(unshareCmd)
+
+	killCmd := &cobra.Command{
+		Use:   "kill <worktree> <session>",
+		Short: "End a session and whatever runs in it",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+			if err := c.KillAISession(cmd.Context(), args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Ended session %s of %s\n", args[1], args[0])
+			return nil
+		},
+	}
+	cmd.AddCommand(killCmd)
+
+	return cmd
+}
+
+// printAISessions writes AI terminal sessions as a table
+func printAISessions(out io.Writer, sessions []client.AISession) error {
+	if len(sessions) == 0 {
+		fmt.Fprintln(out, "No AI sessions found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKTREE\tSESSION\tCOMMAND\tAGENT\tCLIENTS\tWRITER\tSHARES\tAGE")
+	for _, session := range sessions {
+		writer := "-"
+		if session.HasWriter {
+			writer = "web"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
+			session.Worktree, session.Name, truncateString(session.Command, 30), formatAgentActivity(session.Agent),
+			session.Clients, writer, session.Shares, formatDuration(time.Since(session.CreatedAt)))
+	}
+	return w.Flush()
+}
+
+// formatAgentActivity describes what an agent is doing and for how long, or
+// "-" for sessions without an agent the server watches
+func formatAgentActivity(activity *client.AgentActivity) string {
+	if activity == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s %s", activity.State, formatDuration(time.Since(activity.Since)))
+}
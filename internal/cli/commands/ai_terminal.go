@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"vibeman/internal/logger"
+	"vibeman/internal/operations"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// aiTerminalDrainTimeout bounds how long output is copied after docker exec
+// exits, since processes left in the background may hold the terminal open
+const aiTerminalDrainTimeout = time.Second
+
+// recordFunc adapts a Recorder method to an io.Writer
+type recordFunc func([]byte)
+
+func (f recordFunc) Write(p []byte) (int, error) {
+	f(p)
+	return len(p), nil
+}
+
+// runAITerminal runs command in a session of an AI container on this
+// terminal, recording the session unless something else, such as the
+// server, already does. docker exec runs on a PTY that follows this
+// terminal's size, so the recording has the resizes too. Without a terminal
+// to attach to, the command runs as is and nothing is recorded.
+func runAITerminal(repoName, worktreeName, sessionName, container string, command []string) error {
+	cmd := exec.Command("docker", append([]string{"exec", "-it", container}, command...)...)
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return runAttached(cmd)
+	}
+	cols, rows, err := term.GetSize(fd)
+	if err != nil {
+		cols, rows = 80, 24
+	}
+
+	tty, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if errors.Is(err, pty.ErrUnsupported) {
+		return runAttached(cmd)
+	}
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	// The recording waits for the session, which the command may still be
+	// starting
+	var recording atomic.Pointer[operations.AISessionRecording]
+	recordCtx, stopRecording := context.WithCancel(context.Background())
+	recordingStarted := make(chan struct{})
+	go func() {
+		defer close(recordingStarted)
+		started, err := operations.RecordAISession(recordCtx, repoName, worktreeName, container, sessionName, operations.AIRecordingSourceCLI)
+		if err != nil {
+			// Not recording is no reason to keep anyone from the terminal
+			if recordCtx.Err() == nil {
+				logger.WithError(err).Warn("Failed to record AI terminal session")
+			}
+			return
+		}
+		if started != nil {
+			logger.WithField("path", started.Path).Debug("Recording AI terminal session")
+		}
+		recording.Store(started)
+	}()
+	defer func() {
+		// A recording still starting is given up on
+		select {
+		case <-recordingStarted:
+			recording.Load().Stop()
+		default:
+		}
+		stopRecording()
+	}()
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	stopResizes := notifyResize(func() {
+		cols, rows, err := term.GetSize(fd)
+		if err != nil {
+			return
+		}
+		if err := pty.Setsize(tty, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}); err == nil {
+			recording.Load().Resize(cols, rows)
+		}
+	})
+	defer stopResizes()
+
+	// Reading stdin blocks until the next key press, so that copy is left
+	// to end with the process
+	go io.Copy(tty, io.TeeReader(os.Stdin, recordFunc(func(p []byte) { recording.Load().Input(p) })))
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		io.Copy(os.Stdout, tty)
+	}()
+
+	err = cmd.Wait()
+	select {
+	case <-outputDone:
+	case <-time.After(aiTerminalDrainTimeout):
+	}
+	return err
+}
+
+// runAttached runs cmd on this process's standard streams
+func runAttached(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
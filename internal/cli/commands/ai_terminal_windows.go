@@ -0,0 +1,7 @@
+package commands
+
+// notifyResize does nothing on Windows, which has no signal for terminal
+// size changes and runs AI terminals without a PTY
+func notifyResize(resized func()) func() {
+	return func() {}
+}
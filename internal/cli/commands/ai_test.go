@@ -2,7 +2,10 @@ package commands
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"vibeman/internal/config"
 	"vibeman/internal/db"
@@ -143,11 +146,11 @@ func TestCreateAICommand(t *testing.T) {
 
 	assert.NotNil(t, cmd)
 	assert.Equal(t, "ai [worktree]", cmd.Use)
-	assert.Equal(t, "Start Claude CLI in AI container", cmd.Short)
+	assert.Equal(t, "Start the AI agent in AI container", cmd.Short)
 
 	// Check that subcommands are added
 	subcommands := cmd.Commands()
-	assert.Len(t, subcommands, 4) // attach, claude, list, logs
+	assert.Len(t, subcommands, 11) // attach, claude, list, logs, run, jobs, fanout, sessions, recordings, replay, mcp
 
 	commandNames := make([]string, len(subcommands))
 	for i, subcmd := range subcommands {
@@ -158,9 +161,16 @@ func TestCreateAICommand(t *testing.T) {
 	assert.Contains(t, commandNames, "claude [worktree-name]")
 	assert.Contains(t, commandNames, "list")
 	assert.Contains(t, commandNames, "logs [worktree-name]")
+	assert.Contains(t, commandNames, "run <worktree>")
+	assert.Contains(t, commandNames, "jobs")
+	assert.Contains(t, commandNames, "fanout")
+	assert.Contains(t, commandNames, "sessions")
+	assert.Contains(t, commandNames, "recordings")
+	assert.Contains(t, commandNames, "mcp")
+	assert.Contains(t, commandNames, "replay <recording>")
 }
 
-// Test default behavior of ai command (should start Claude)
+// Test default behavior of ai command (should start the configured agent)
 func TestAICommandDefaultBehavior(t *testing.T) {
 	cfg := &config.Manager{}
 	containerMgr := &MockContainerManager{}
@@ -183,7 +193,7 @@ func TestAICommandDefaultBehavior(t *testing.T) {
 	
 	// Verify the command has a RunE function (default behavior)
 	assert.NotNil(t, cmd.RunE)
-	assert.Equal(t, "Start Claude CLI in AI container", cmd.Short)
+	assert.Equal(t, "Start the AI agent in AI container", cmd.Short)
 	
 	// Note: We can't easily test the actual execution without mocking os.Getwd() and docker exec
 	// The command structure and setup is what we're primarily testing here
@@ -372,4 +382,30 @@ func TestTruncateString(t *testing.T) {
 			assert.Equal(t, tt.expected, result)
 		})
 	}
-}
\ No newline at end of file
+}
+
+// Test listAgentActivity reads what agents are doing from the server
+func TestListAgentActivity(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	since := time.Now().Add(-3 * time.Minute).UTC().Format(time.RFC3339)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/ai/sessions", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sessions":[
+			{"name":"claude","container":"repo-feature1-ai","agent":{"state":"waiting","reason":"prompt","since":"` + since + `"}},
+			{"name":"shell","container":"repo-feature1-ai"}
+		],"total":2}`))
+	}))
+	defer server.Close()
+	t.Setenv("VIBEMAN_SERVER", server.URL)
+
+	agents := listAgentActivity(context.Background(), createAIListCommand(&MockContainerManager{}))
+	assert.Len(t, agents, 1)
+	if assert.Len(t, agents["repo-feature1-ai"], 1) {
+		assert.Regexp(t, `^claude: waiting 3m`, agents["repo-feature1-ai"][0])
+	}
+
+	// Without a server nothing is known
+	server.Close()
+	assert.Empty(t, listAgentActivity(context.Background(), createAIListCommand(&MockContainerManager{})))
+}
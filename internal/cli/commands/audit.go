@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/client"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateAuditCommand creates the audit log commands. In local mode the local
+// database is queried directly; in client mode the server's log is queried.
+func CreateAuditCommand(database *db.DB) *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show the audit log of mutating actions",
+		Long: `Show who created, started, stopped or removed repositories, worktrees,
+services and containers, newest first.
+
+--since accepts a duration such as 24h, a date such as 2025-01-31 or an
+RFC 3339 time. --action matches exactly, or by prefix when it ends in a
+dot (e.g. worktree.).`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := client.AuditQuery{}
+			query.Target, _ = cmd.Flags().GetString("target")
+			query.Since, _ = cmd.Flags().GetString("since")
+			query.Actor, _ = cmd.Flags().GetString("actor")
+			query.Action, _ = cmd.Flags().GetString("action")
+			query.Limit, _ = cmd.Flags().GetInt("limit")
+
+			var events []*db.AuditEvent
+			if database != nil {
+				since, err := audit.ParseSince(query.Since, time.Now())
+				if err != nil {
+					return err
+				}
+				events, err = db.NewAuditRepository(database).List(cmd.Context(), db.AuditFilter{
+					Target: query.Target,
+					Actor:  query.Actor,
+					Action: query.Action,
+					Since:  since,
+					Limit:  query.Limit,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to list audit events: %w", err)
+				}
+			} else {
+				c, err := newAuthClient(cmd)
+				if err != nil {
+					return err
+				}
+				if events, err = c.ListAuditEvents(cmd.Context(), query); err != nil {
+					return err
+				}
+			}
+
+			return printAuditEvents(cmd.OutOrStdout(), events)
+		},
+	}
+	auditCmd.Flags().String("target", "", "Only show events for this target ID or name")
+	auditCmd.Flags().String("since", "", "Only show events after this time (e.g. 24h)")
+	auditCmd.Flags().String("actor", "", "Only show events by this actor")
+	auditCmd.Flags().String("action", "", "Only show this action, or action prefix ending in '.'")
+	auditCmd.Flags().Int("limit", 100, "Maximum number of events to show")
+
+	// vibeman audit prune
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete audit events past the retention period",
+		Long: `Delete audit events older than --older-than, or than the [audit] retention
+setting in the global config. The server also prunes the log daily.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if database == nil {
+				return fmt.Errorf("pruning the audit log requires local mode on the server host")
+			}
+
+			retention, _ := cmd.Flags().GetDuration("older-than")
+			if retention == 0 {
+				globalConfig, err := config.LoadGlobalConfig()
+				if err != nil {
+					return fmt.Errorf("failed to load global config: %w", err)
+				}
+				retention = globalConfig.Audit.RetentionDuration()
+				if retention == 0 {
+					fmt.Fprintln(cmd.OutOrStdout(), "Audit retention is disabled; nothing to prune")
+					return nil
+				}
+			}
+
+			count, err := audit.Prune(cmd.Context(), database, retention)
+			if err != nil {
+				return fmt.Errorf("failed to prune audit log: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Deleted %d audit events older than %s\n", count, retention)
+			return nil
+		},
+	}
+	pruneCmd.Flags().Duration("older-than", 0, "Delete events older than this, e.g. 720h (default: configured retention)")
+	auditCmd.AddCommand(pruneCmd)
+
+	return auditCmd
+}
+
+// printAuditEvents writes audit events as a table
+func printAuditEvents(out io.Writer, events []*db.AuditEvent) error {
+	if len(events) == 0 {
+		fmt.Fprintln(out, "No audit events found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTOR\tSOURCE\tACTION\tTARGET\tOUTCOME")
+	for _, e := range events {
+		target := e.TargetName
+		if target == "" {
+			target = e.TargetID
+		}
+		outcome := string(e.Outcome)
+		if e.Error != "" {
+			outcome += ": " + e.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s %s\t%s\n",
+			e.CreatedAt.Local().Format(time.DateTime), e.Actor, e.Source, e.Action, e.TargetType, target, outcome)
+	}
+	return w.Flush()
+}
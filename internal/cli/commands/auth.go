@@ -0,0 +1,340 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
+	"vibeman/internal/client"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// AuthCommands creates authentication commands
+func AuthCommands(database *db.DB) []*cobra.Command {
+	commands := []*cobra.Command{}
+
+	// vibeman auth login
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to a vibeman server",
+		Long: `Log in to a vibeman server and store the session token for later commands.
+
+The server is taken from --server, VIBEMAN_SERVER, or the local server port.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			in := bufio.NewReader(cmd.InOrStdin())
+			username, _ := cmd.Flags().GetString("username")
+			if username == "" {
+				if username, err = promptLine(cmd, in, "Username: "); err != nil {
+					return err
+				}
+			}
+
+			passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+			password, err := readPassword(cmd, in, "Password: ", passwordStdin)
+			if err != nil {
+				return err
+			}
+
+			resp, err := c.Login(cmd.Context(), username, password)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Logged in as %s\n", resp.User.Username)
+			return nil
+		},
+	}
+	loginCmd.Flags().StringP("username", "u", "", "Username to log in as")
+	loginCmd.Flags().Bool("password-stdin", false, "Read the password from stdin")
+	commands = append(commands, loginCmd)
+
+	// vibeman auth logout
+	logoutCmd := &cobra.Command{
+		Use:   "logout",
+		Short: "Log out and discard the stored session token",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := c.Logout(cmd.Context()); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "✓ Logged out")
+			return nil
+		},
+	}
+	commands = append(commands, logoutCmd)
+
+	// vibeman auth whoami
+	whoamiCmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the user you are logged in as",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			user, err := c.WhoAmI(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s (%s)\n", user.Username, user.Role)
+			return nil
+		},
+	}
+	commands = append(commands, whoamiCmd)
+
+	commands = append(commands, tokenCommand(), userCommand(database))
+
+	return commands
+}
+
+// tokenCommand creates the personal API token commands
+func tokenCommand() *cobra.Command {
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage personal API tokens",
+	}
+
+	// vibeman auth token create <name>
+	createCmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a personal API token",
+		Long: `Create a scoped personal API token for scripts and CI.
+
+Scopes are read (GET requests), write (all requests) and admin. A token
+cannot be granted a scope your own login does not have.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			scopes, _ := cmd.Flags().GetStringSlice("scope")
+			if err := auth.ValidateScopes(scopes); err != nil {
+				return err
+			}
+			expires, _ := cmd.Flags().GetDuration("expires")
+
+			req := client.CreateAPITokenRequest{Name: args[0], Scopes: scopes}
+			if expires > 0 {
+				req.ExpiresIn = expires.String()
+			}
+
+			token, apiToken, err := c.CreateAPIToken(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "✓ Created token %s (%s) with scopes %s\n", apiToken.Name, apiToken.ID, strings.Join(apiToken.Scopes, ", "))
+			fmt.Fprintln(out, "Copy the token now, it will not be shown again:")
+			fmt.Fprintln(out, token)
+			return nil
+		},
+	}
+	createCmd.Flags().StringSlice("scope", []string{auth.ScopeRead}, "Token scopes (read, write, admin)")
+	createCmd.Flags().Duration("expires", 0, "Token lifetime, e.g. 720h (default: never expires)")
+	tokenCmd.AddCommand(createCmd)
+
+	// vibeman auth token list
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List your personal API tokens",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			tokens, err := c.ListAPITokens(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tSCOPES\tEXPIRES\tLAST USED")
+			for _, t := range tokens {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(t.Scopes, ","), formatOptionalTime(t.ExpiresAt, "never"), formatOptionalTime(t.LastUsedAt, "-"))
+			}
+			return w.Flush()
+		},
+	}
+	tokenCmd.AddCommand(listCmd)
+
+	// vibeman auth token revoke <id>
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke a personal API token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAuthClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := c.RevokeAPIToken(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Revoked token %s\n", args[0])
+			return nil
+		},
+	}
+	tokenCmd.AddCommand(revokeCmd)
+
+	return tokenCmd
+}
+
+// userCommand creates the local user management commands. They operate on
+// the local database, so they must run on the server host.
+func userCommand(database *db.DB) *cobra.Command {
+	userCmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage local users (run on the server host)",
+	}
+
+	// vibeman auth user add <username>
+	addCmd := &cobra.Command{
+		Use:   "add <username>",
+		Short: "Create a local user",
+		Long:  "Create a local user. The first user created is always an admin.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if database == nil {
+				return fmt.Errorf("user management requires local mode on the server host")
+			}
+
+			email, _ := cmd.Flags().GetString("email")
+			admin, _ := cmd.Flags().GetBool("admin")
+			passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+
+			in := bufio.NewReader(cmd.InOrStdin())
+			password, err := readPassword(cmd, in, "Password: ", passwordStdin)
+			if err != nil {
+				return err
+			}
+			if !passwordStdin {
+				confirm, err := readPassword(cmd, in, "Confirm password: ", false)
+				if err != nil {
+					return err
+				}
+				if confirm != password {
+					return fmt.Errorf("passwords do not match")
+				}
+			}
+
+			role := db.RoleUser
+			if admin {
+				role = db.RoleAdmin
+			}
+
+			// Only the database is used here, so no session secret is needed
+			svc := auth.NewService(database, auth.Config{})
+			user, err := svc.CreateUser(cmd.Context(), args[0], email, password, role)
+			target := audit.Target{Type: audit.TargetUser, Name: args[0]}
+			if err == nil {
+				target.ID = user.ID
+			}
+			audit.Record(cmd.Context(), audit.ActionUserCreate, target, audit.Params{"role": role}, err)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Created %s user %s\n", user.Role, user.Username)
+			return nil
+		},
+	}
+	addCmd.Flags().String("email", "", "Email address")
+	addCmd.Flags().Bool("admin", false, "Grant the admin role")
+	addCmd.Flags().Bool("password-stdin", false, "Read the password from stdin")
+	userCmd.AddCommand(addCmd)
+
+	return userCmd
+}
+
+// newAuthClient creates an API client for the server selected by --server,
+// VIBEMAN_SERVER, or the configured local server port
+func newAuthClient(cmd *cobra.Command) (*client.Client, error) {
+	serverURL, _ := cmd.Flags().GetString("server")
+	if serverURL == "" {
+		serverURL = os.Getenv("VIBEMAN_SERVER")
+	}
+	if serverURL == "" {
+		port := 0
+		if globalConfig, err := config.LoadGlobalConfig(); err == nil {
+			port = globalConfig.Server.Port
+		}
+		if port == 0 {
+			port = config.DefaultGlobalConfig().Server.Port
+		}
+		serverURL = fmt.Sprintf("http://localhost:%d", port)
+	}
+
+	return client.New(serverURL)
+}
+
+// promptLine prints a prompt and reads a line from stdin
+func promptLine(cmd *cobra.Command, in *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(cmd.OutOrStdout(), prompt)
+	line, err := in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readPassword reads a password without echoing it when stdin is a terminal
+func readPassword(cmd *cobra.Command, in *bufio.Reader, prompt string, fromStdin bool) (string, error) {
+	if !fromStdin {
+		if f, ok := cmd.InOrStdin().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+			fmt.Fprint(cmd.OutOrStdout(), prompt)
+			password, err := term.ReadPassword(int(f.Fd()))
+			fmt.Fprintln(cmd.OutOrStdout())
+			if err != nil {
+				return "", fmt.Errorf("failed to read password: %w", err)
+			}
+			return string(password), nil
+		}
+	}
+
+	line, err := in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// formatOptionalTime formats a time, or returns fallback when it is unset
+func formatOptionalTime(t *time.Time, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -385,6 +385,12 @@ func validateServicesConfig(cfg *config.ServicesConfig) error {
 		return fmt.Errorf("at least one service must be defined")
 	}
 	for name, svc := range cfg.Services {
+		if svc.IsInline() {
+			if svc.ComposeFile != "" {
+				return fmt.Errorf("service %s: image and compose_file are mutually exclusive", name)
+			}
+			continue
+		}
 		if svc.ComposeFile == "" {
 			return fmt.Errorf("service %s: compose_file is required", name)
 		}
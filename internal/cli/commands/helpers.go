@@ -10,6 +10,7 @@ import (
 	"vibeman/internal/container"
 	"vibeman/internal/git"
 	"vibeman/internal/interfaces"
+	"vibeman/internal/operations"
 	"vibeman/internal/types"
 )
 
@@ -96,10 +97,38 @@ func (a *worktreeServiceAdapter) RemoveReference(serviceName, repositoryName str
 	return a.mgr.RemoveReference(serviceName, repositoryName)
 }
 
+func (a *worktreeServiceAdapter) ReleaseReferences(ctx context.Context, holder string) error {
+	if r, ok := a.mgr.(operations.ServiceReleaser); ok {
+		return r.ReleaseReferences(ctx, holder)
+	}
+	return nil
+}
+
 func (a *worktreeServiceAdapter) HealthCheck(ctx context.Context, name string) error {
 	return a.mgr.HealthCheck(ctx, name)
 }
 
+func (a *worktreeServiceAdapter) ProvisionWorktree(ctx context.Context, serviceName, worktreeID, baseName string) (map[string]string, error) {
+	if p, ok := a.mgr.(operations.ServiceProvisioner); ok {
+		return p.ProvisionWorktree(ctx, serviceName, worktreeID, baseName)
+	}
+	return nil, nil
+}
+
+func (a *worktreeServiceAdapter) DeprovisionWorktree(ctx context.Context, worktreeID string) error {
+	if p, ok := a.mgr.(operations.ServiceProvisioner); ok {
+		return p.DeprovisionWorktree(ctx, worktreeID)
+	}
+	return nil
+}
+
+func (a *worktreeServiceAdapter) ServiceEnv(ctx context.Context, worktreeID string, services []string, inContainer bool) (map[string]string, error) {
+	if d, ok := a.mgr.(operations.ServiceDiscoverer); ok {
+		return d.ServiceEnv(ctx, worktreeID, services, inContainer)
+	}
+	return nil, nil
+}
+
 // containerManagerAdapter adapts interfaces.ContainerManager to operations.ContainerManager
 type containerManagerAdapter struct {
 	mgr interfaces.ContainerManager
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"vibeman/internal/client"
+	"vibeman/internal/db"
+	"vibeman/internal/operations"
+)
+
+// operationPollInterval is how often remote operations are polled for progress
+const operationPollInterval = 500 * time.Millisecond
+
+// OperationsClient runs worktree operations on a remote vibeman server
+type OperationsClient interface {
+	ListRepositories(ctx context.Context) ([]*db.Repository, error)
+	ListRepositoryWorktrees(ctx context.Context, repositoryID string) ([]db.Worktree, error)
+	CreateWorktreeOperation(ctx context.Context, req client.CreateWorktreeOperationRequest) (*client.OperationAccepted, error)
+	StartWorktreeOperation(ctx context.Context, worktreeID string) (*client.OperationAccepted, error)
+	GetOperation(ctx context.Context, id string) (*db.Operation, error)
+	CancelOperation(ctx context.Context, id string) error
+}
+
+// progressPrinter renders operation progress events as lines of text
+type progressPrinter struct {
+	out io.Writer
+}
+
+// newProgressPrinter creates a progress printer writing to out
+func newProgressPrinter(out io.Writer) *progressPrinter {
+	return &progressPrinter{out: out}
+}
+
+// Report implements operations.ProgressReporter
+func (p *progressPrinter) Report(event operations.ProgressEvent) {
+	message := event.Message
+	if message == "" {
+		message = event.Step
+	}
+	if event.Total > 0 {
+		message = fmt.Sprintf("[%d/%d] %s", event.Current, event.Total, message)
+	}
+
+	switch event.Status {
+	case operations.StepRunning:
+		fmt.Fprintf(p.out, "  … %s\n", message)
+	case operations.StepCompleted:
+		fmt.Fprintf(p.out, "  ✓ %s\n", message)
+	case operations.StepFailed:
+		if event.Error != "" {
+			fmt.Fprintf(p.out, "  ✗ %s: %s\n", message, event.Error)
+		} else {
+			fmt.Fprintf(p.out, "  ✗ %s\n", message)
+		}
+	case operations.StepSkipped:
+		fmt.Fprintf(p.out, "  - %s (skipped)\n", message)
+	}
+}
+
+// runWithProgress runs a local operation, printing its progress to out
+func runWithProgress(ctx context.Context, out io.Writer, fn func(ctx context.Context) error) error {
+	return fn(operations.WithProgress(ctx, newProgressPrinter(out)))
+}
+
+// stepEvent converts a persisted operation step into a progress event
+func stepEvent(step db.OperationStep) operations.ProgressEvent {
+	return operations.ProgressEvent{
+		Step:    step.Name,
+		Message: step.Message,
+		Status:  operations.StepStatus(step.Status),
+		Current: step.Current,
+		Total:   step.Total,
+		Error:   step.Error,
+	}
+}
+
+// followOperation polls a remote operation until it finishes, printing its
+// progress to out the same way local operations are printed. Cancelling ctx
+// requests cancellation of the operation on the server.
+func followOperation(ctx context.Context, out io.Writer, oc OperationsClient, id string) (*db.Operation, error) {
+	printer := newProgressPrinter(out)
+	rendered := make(map[int]string)
+
+	// Keep polling after ctx is cancelled so the final state is reported
+	pollCtx := context.WithoutCancel(ctx)
+	done := ctx.Done()
+
+	ticker := time.NewTicker(operationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		op, err := oc.GetOperation(pollCtx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, step := range op.Steps {
+			if rendered[i] == step.Status {
+				continue
+			}
+			rendered[i] = step.Status
+			printer.Report(stepEvent(step))
+		}
+
+		if op.Status.IsFinished() {
+			return op, operationError(op)
+		}
+
+		select {
+		case <-done:
+			done = nil
+			fmt.Fprintln(out, "Cancelling operation...")
+			if err := oc.CancelOperation(pollCtx, id); err != nil {
+				return op, err
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// operationError returns the error of a finished operation, if any
+func operationError(op *db.Operation) error {
+	switch op.Status {
+	case db.OperationFailed:
+		return fmt.Errorf("%s", op.Error)
+	case db.OperationCancelled:
+		return fmt.Errorf("operation cancelled")
+	default:
+		return nil
+	}
+}
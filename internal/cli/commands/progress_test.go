@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"vibeman/internal/client"
+	"vibeman/internal/db"
+	"vibeman/internal/operations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOperationsClient replays a fixed sequence of operation states
+type fakeOperationsClient struct {
+	states    []*db.Operation
+	polls     int
+	cancelled bool
+}
+
+func (f *fakeOperationsClient) ListRepositories(ctx context.Context) ([]*db.Repository, error) {
+	return nil, nil
+}
+
+func (f *fakeOperationsClient) ListRepositoryWorktrees(ctx context.Context, repositoryID string) ([]db.Worktree, error) {
+	return nil, nil
+}
+
+func (f *fakeOperationsClient) CreateWorktreeOperation(ctx context.Context, req client.CreateWorktreeOperationRequest) (*client.OperationAccepted, error) {
+	return &client.OperationAccepted{OperationID: "op-1"}, nil
+}
+
+func (f *fakeOperationsClient) StartWorktreeOperation(ctx context.Context, worktreeID string) (*client.OperationAccepted, error) {
+	return &client.OperationAccepted{OperationID: "op-1"}, nil
+}
+
+func (f *fakeOperationsClient) GetOperation(ctx context.Context, id string) (*db.Operation, error) {
+	op := f.states[f.polls]
+	if f.polls < len(f.states)-1 {
+		f.polls++
+	}
+	return op, nil
+}
+
+func (f *fakeOperationsClient) CancelOperation(ctx context.Context, id string) error {
+	f.cancelled = true
+	return nil
+}
+
+func TestProgressPrinter(t *testing.T) {
+	var out bytes.Buffer
+	printer := newProgressPrinter(&out)
+
+	printer.Report(operations.ProgressEvent{Step: operations.StepGitWorktree, Message: "Created git worktree", Status: operations.StepCompleted})
+	printer.Report(operations.ProgressEvent{Step: operations.StepSetup, Message: "npm install", Status: operations.StepFailed, Current: 1, Total: 2, Error: "exit status 1"})
+	printer.Report(operations.ProgressEvent{Step: operations.StepAI, Message: "AI container disabled", Status: operations.StepSkipped})
+
+	assert.Equal(t, "  ✓ Created git worktree\n  ✗ [1/2] npm install: exit status 1\n  - AI container disabled (skipped)\n", out.String())
+}
+
+func TestFollowOperation_RendersStepsOnce(t *testing.T) {
+	running := db.OperationStep{Name: operations.StepGitWorktree, Message: "Creating git worktree", Status: string(operations.StepRunning)}
+	completed := db.OperationStep{Name: operations.StepGitWorktree, Message: "Created git worktree", Status: string(operations.StepCompleted)}
+
+	fake := &fakeOperationsClient{states: []*db.Operation{
+		{ID: "op-1", Status: db.OperationRunning, Steps: db.OperationSteps{running}},
+		{ID: "op-1", Status: db.OperationRunning, Steps: db.OperationSteps{running}},
+		{ID: "op-1", Status: db.OperationFailed, Steps: db.OperationSteps{completed}, Error: "setup failed"},
+	}}
+
+	var out bytes.Buffer
+	op, err := followOperation(context.Background(), &out, fake, "op-1")
+	require.Error(t, err)
+	assert.Equal(t, "setup failed", err.Error())
+	assert.Equal(t, db.OperationFailed, op.Status)
+	assert.Equal(t, "  … Creating git worktree\n  ✓ Created git worktree\n", out.String())
+	assert.False(t, fake.cancelled)
+}
+
+func TestFollowOperation_CancelsOnInterrupt(t *testing.T) {
+	fake := &fakeOperationsClient{states: []*db.Operation{
+		{ID: "op-1", Status: db.OperationRunning},
+		{ID: "op-1", Status: db.OperationCancelled},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	_, err := followOperation(ctx, &out, fake, "op-1")
+	require.Error(t, err)
+	assert.True(t, fake.cancelled)
+	assert.Contains(t, out.String(), "Cancelling operation")
+}
@@ -19,7 +19,7 @@ import (
 )
 
 // RepositoryCommands creates repository management commands
-func RepositoryCommands(cfg *config.Manager, cm ContainerManager, gm GitManager, sm ServiceManager, dbRepo db.RepositoryManager, database *db.DB) []*cobra.Command {
+func RepositoryCommands(cfg *config.Manager, cm ContainerManager, gm GitManager, sm ServiceManager, dbRepo db.RepositoryManager, database *db.DB, remote OperationsClient) []*cobra.Command {
 	commands := []*cobra.Command{}
 
 	// Create operations instances
@@ -84,6 +84,20 @@ func RepositoryCommands(cfg *config.Manager, cm ContainerManager, gm GitManager,
 				env = args[0]
 			}
 
+			if remote != nil {
+				wt, err := findRemoteWorktree(cmd.Context(), remote, repositoryName, env)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Starting worktree %s\n", wt.Name)
+				accepted, err := remote.StartWorktreeOperation(cmd.Context(), wt.ID)
+				if err != nil {
+					return err
+				}
+				_, err = followOperation(cmd.Context(), cmd.OutOrStdout(), remote, accepted.OperationID)
+				return err
+			}
+
 			if worktreeOps == nil {
 				return fmt.Errorf("operations not initialized")
 			}
@@ -94,7 +108,10 @@ func RepositoryCommands(cfg *config.Manager, cm ContainerManager, gm GitManager,
 			}
 			for _, wt := range worktrees {
 				if wt.Name == env || (env == "main" && wt.Name == repositoryName) {
-					return worktreeOps.StartWorktree(cmd.Context(), wt.ID)
+					fmt.Fprintf(cmd.OutOrStdout(), "Starting worktree %s\n", wt.Name)
+					return runWithProgress(cmd.Context(), cmd.OutOrStdout(), func(ctx context.Context) error {
+						return worktreeOps.StartWorktree(ctx, wt.ID)
+					})
 				}
 			}
 			return fmt.Errorf("worktree '%s' not found", env)
@@ -262,7 +279,8 @@ func createRepository(ctx context.Context, name string, cfg *config.Manager, cm
 	// Start required services
 	if len(cfg.Repository.Repository.Services) > 0 {
 		logger.Info("Starting required services...")
-		for serviceName, serviceReq := range cfg.Repository.Repository.Services {
+		for baseName, serviceReq := range cfg.Repository.Repository.Services {
+			serviceName := config.VersionedName(baseName, serviceReq.Version)
 			if serviceReq.Required {
 				if err := sm.StartService(ctx, serviceName); err != nil {
 					return fmt.Errorf("failed to start service %s: %w", serviceName, err)
@@ -332,7 +350,8 @@ func startRepository(ctx context.Context, name string, env string, cfg *config.M
 
 	// Start required services
 	if cfg.Repository.Repository.Name == name && len(cfg.Repository.Repository.Services) > 0 {
-		for serviceName, serviceReq := range cfg.Repository.Repository.Services {
+		for baseName, serviceReq := range cfg.Repository.Repository.Services {
+			serviceName := config.VersionedName(baseName, serviceReq.Version)
 			if serviceReq.Required {
 				if err := sm.StartService(ctx, serviceName); err != nil {
 					logger.WithFields(logger.Fields{"service": serviceName, "error": err}).Warn("Failed to start service")
@@ -416,7 +435,7 @@ func destroyRepository(ctx context.Context, name string, env string, cfg *config
 
 	// Remove service references
 	if cfg.Repository.Repository.Name == name {
-		for serviceName := range cfg.Repository.Repository.Services {
+		for _, serviceName := range cfg.Repository.Repository.Services.Names() {
 			_ = sm.RemoveReference(serviceName, name)
 		}
 	}
@@ -509,7 +528,7 @@ func repositoryStatus(ctx context.Context, name string, cfg *config.Manager, cm
 	// Service status
 	if cfg.Repository.Repository.Name == name && len(cfg.Repository.Repository.Services) > 0 {
 		logger.Info("\nServices:")
-		for serviceName := range cfg.Repository.Repository.Services {
+		for _, serviceName := range cfg.Repository.Repository.Services.Names() {
 			svcInterface, err := sm.GetService(serviceName)
 			if err != nil {
 				logger.WithFields(logger.Fields{"service": serviceName}).Info("Service not found")
@@ -623,3 +642,34 @@ func repositoryLogs(ctx context.Context, repositoryName, worktree string, follow
 	fmt.Print(string(logs))
 	return nil
 }
+
+// findRemoteWorktree looks up a worktree by repository and worktree name on the server
+func findRemoteWorktree(ctx context.Context, remote OperationsClient, repositoryName, worktreeName string) (*db.Worktree, error) {
+	repos, err := remote.ListRepositories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+
+	var repoID string
+	for _, repo := range repos {
+		if repo.Name == repositoryName {
+			repoID = repo.ID
+			break
+		}
+	}
+	if repoID == "" {
+		return nil, fmt.Errorf("repository '%s' not found", repositoryName)
+	}
+
+	worktrees, err := remote.ListRepositoryWorktrees(ctx, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	for i := range worktrees {
+		wt := &worktrees[i]
+		if wt.Name == worktreeName || (worktreeName == "main" && wt.Name == repositoryName) {
+			return wt, nil
+		}
+	}
+	return nil, fmt.Errorf("worktree '%s' not found", worktreeName)
+}
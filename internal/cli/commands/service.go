@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"vibeman/internal/config"
 	"vibeman/internal/logger"
 	"vibeman/internal/operations"
+	"vibeman/internal/service"
 	"vibeman/internal/types"
 
 	"github.com/spf13/cobra"
@@ -177,9 +179,125 @@ func ServiceCommands(cfg *config.Manager, cm ContainerManager, sm ServiceManager
 	}
 	commands = append(commands, execCmd)
 
+	// vibeman service snapshot <service-name>
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot <service-name>",
+		Short: "Save a copy of a service's data",
+		Long: `Save a copy of a running service's data under the vibeman data directory.
+
+A logical snapshot (the default) dumps a Postgres database with pg_dump or the
+Redis dataset as an RDB file. A volume snapshot stops the service briefly and
+archives each of its named volumes, whatever the service is.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			opts := service.SnapshotOptions{}
+			opts.Tag, _ = cmd.Flags().GetString("tag")
+			opts.Kind, _ = cmd.Flags().GetString("kind")
+			opts.Database, _ = cmd.Flags().GetString("database")
+			opts.Worktree, _ = cmd.Flags().GetString("worktree")
+
+			snapshot, err := serviceOps.CreateSnapshot(cmd.Context(), args[0], opts)
+			if err != nil {
+				return fmt.Errorf("failed to snapshot service %s: %w", args[0], err)
+			}
+			fmt.Printf("Created snapshot %s of %s (%s)\n", snapshot.ID, snapshot.Service, formatSize(snapshot.Size))
+			return nil
+		},
+	}
+	snapshotCmd.Flags().String("tag", "", "Name to restore the snapshot by")
+	snapshotCmd.Flags().String("kind", service.SnapshotLogical, "Snapshot kind: logical or volume")
+	snapshotCmd.Flags().String("database", "", "Postgres database to dump (default postgres)")
+	snapshotCmd.Flags().String("worktree", "", "Dump this worktree's provisioned database")
+	commands = append(commands, snapshotCmd)
+
+	// vibeman service restore <service-name> <snapshot>
+	restoreCmd := &cobra.Command{
+		Use:   "restore <service-name> <snapshot>",
+		Short: "Replace a service's data with a snapshot",
+		Long: `Replace a service's data with a snapshot, named by ID or by tag (the newest
+snapshot with that tag).
+
+A Postgres dump is restored into the database it was taken from, or into
+another one with --database. With --worktree it seeds that worktree's
+provisioned database.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			opts := service.SnapshotOptions{}
+			opts.Database, _ = cmd.Flags().GetString("database")
+			opts.Worktree, _ = cmd.Flags().GetString("worktree")
+
+			snapshot, err := serviceOps.RestoreSnapshot(cmd.Context(), args[0], args[1], opts)
+			if err != nil {
+				return fmt.Errorf("failed to restore service %s: %w", args[0], err)
+			}
+			fmt.Printf("Restored snapshot %s of %s\n", snapshot.ID, snapshot.Service)
+			return nil
+		},
+	}
+	restoreCmd.Flags().String("database", "", "Postgres database to restore into")
+	restoreCmd.Flags().String("worktree", "", "Restore into this worktree's provisioned database")
+	commands = append(commands, restoreCmd)
+
+	// vibeman service snapshots [service-name]
+	snapshotsCmd := &cobra.Command{
+		Use:   "snapshots [service-name]",
+		Short: "List service snapshots",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			snapshots, err := serviceOps.ListSnapshots(name)
+			if err != nil {
+				return fmt.Errorf("failed to list snapshots: %w", err)
+			}
+			showSnapshots(os.Stdout, snapshots)
+			return nil
+		},
+	}
+	commands = append(commands, snapshotsCmd)
+	commands = append(commands, serviceConfigCommands(cfg, serviceOps)...)
+
 	return commands
 }
 
+// showSnapshots prints snapshots as a table
+func showSnapshots(w io.Writer, snapshots []*service.Snapshot) {
+	if len(snapshots) == 0 {
+		fmt.Fprintln(w, "No snapshots")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tID\tTAG\tKIND\tSOURCE\tSIZE\tCREATED")
+	for _, snapshot := range snapshots {
+		source := snapshot.Database
+		if snapshot.Kind == service.SnapshotVolume {
+			source = strings.Join(snapshot.Volumes, ", ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			snapshot.Service,
+			snapshot.ID,
+			valueOr(snapshot.Tag, "-"),
+			snapshot.Kind,
+			valueOr(source, "-"),
+			formatSize(snapshot.Size),
+			snapshot.CreatedAt.Local().Format("2006-01-02 15:04"),
+		)
+	}
+	tw.Flush()
+}
+
 func listServicesWithOps(ctx context.Context, serviceOps *operations.ServiceOperations) error {
 	services, err := serviceOps.ListServices(ctx)
 	if err != nil {
@@ -235,6 +353,10 @@ func serviceStatusWithOps(ctx context.Context, serviceName string, serviceOps *o
 		logger.WithFields(logger.Fields{"health_error": svc.HealthError}).Warn("Service health check failed")
 	}
 
+	if svc.Restarts > 0 {
+		logger.WithFields(logger.Fields{"restarts": svc.Restarts}).Warn("Service restarted after crashing")
+	}
+
 	return nil
 }
 
@@ -262,7 +384,7 @@ func allServicesStatusWithOps(ctx context.Context, serviceOps *operations.Servic
 
 func serviceLogs(ctx context.Context, serviceName string, follow bool, tail int, cfg *config.Manager, sm ServiceManager, cm ContainerManager) error {
 	// Get service configuration
-	serviceConfig, exists := cfg.Services.Services[serviceName]
+	serviceConfig, exists := cfg.Services.Lookup(serviceName)
 	if !exists {
 		return fmt.Errorf("service '%s' not configured", serviceName)
 	}
@@ -292,6 +414,24 @@ func serviceLogs(ctx context.Context, serviceName string, follow bool, tail int,
 		return cmd.Run()
 	}
 
+	// Inline services have a container of their own
+	if serviceConfig.IsInline() {
+		args := []string{"logs"}
+		if follow {
+			args = append(args, "-f")
+		}
+		if tail > 0 {
+			args = append(args, "--tail", fmt.Sprintf("%d", tail))
+		}
+		args = append(args, serviceConfig.Service)
+
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		return cmd.Run()
+	}
+
 	// For traditional services, use container manager
 	svcInterface, err := sm.GetService(serviceName)
 	if err != nil {
@@ -555,7 +695,9 @@ func allServicesStatusAPI(ctx context.Context, apiClient *api.APIClient) error {
 		healthStatus := ""
 
 		if svc.Status == "running" {
-			if svc.HealthError != "" {
+			if svc.Health != "" {
+				healthStatus = " (" + svc.Health + ")"
+			} else if svc.HealthError != "" {
 				healthStatus = " (unhealthy)"
 			} else {
 				healthStatus = " (healthy)"
@@ -567,3 +709,25 @@ func allServicesStatusAPI(ctx context.Context, apiClient *api.APIClient) error {
 
 	return nil
 }
+
+// valueOr returns value, or fallback when it is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// formatSize formats a byte count for display
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"vibeman/internal/config"
+	"vibeman/internal/operations"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+)
+
+// serviceConfigCommands creates the commands that change services.toml
+func serviceConfigCommands(cfg *config.Manager, serviceOps *operations.ServiceOperations) []*cobra.Command {
+	// vibeman service add <service-name>
+	addCmd := &cobra.Command{
+		Use:   "add <service-name> [-- command...]",
+		Short: "Add a service to services.toml",
+		Long: `Add a service to services.toml, either from a compose file or defined inline.
+
+Examples:
+  vibeman service add postgres --compose-file ./docker-compose.yaml --service db
+  vibeman service add cache --image redis:7-alpine --port 6380:6379 --volume cache-data:/data -- redis-server --appendonly yes`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			if dash := cmd.ArgsLenAtDash(); dash > 1 || (dash == -1 && len(args) > 1) {
+				return fmt.Errorf("requires exactly 1 service name; put the container command after --")
+			}
+
+			var svc config.ServiceConfig
+			if err := applyServiceFlags(cmd, args, &svc); err != nil {
+				return err
+			}
+			if err := serviceOps.AddService(cmd.Context(), args[0], svc); err != nil {
+				return fmt.Errorf("failed to add service %s: %w", args[0], err)
+			}
+			fmt.Printf("Added service %s\n", args[0])
+			return nil
+		},
+	}
+	addServiceFlags(addCmd)
+
+	// vibeman service edit <service-name>
+	editCmd := &cobra.Command{
+		Use:   "edit <service-name> [-- command...]",
+		Short: "Change a service in services.toml",
+		Long: `Change a service in services.toml. Without flags, the service's configuration
+is opened in $EDITOR and checked before it is saved. The change takes effect the
+next time the service starts.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			if dash := cmd.ArgsLenAtDash(); dash > 1 || (dash == -1 && len(args) > 1) {
+				return fmt.Errorf("requires exactly 1 service name; put the container command after --")
+			}
+			name := args[0]
+			if cfg.Services == nil {
+				return fmt.Errorf("services configuration not available")
+			}
+			svc, exists := cfg.Services.Services[name]
+			if !exists {
+				return fmt.Errorf("service configuration not found: %s", name)
+			}
+
+			var err error
+			if cmd.Flags().NFlag() > 0 || len(args) > 1 {
+				err = applyServiceFlags(cmd, args, &svc)
+			} else {
+				svc, err = editServiceConfig(name, svc)
+			}
+			if err != nil {
+				return err
+			}
+			if err := serviceOps.UpdateService(cmd.Context(), name, svc); err != nil {
+				return fmt.Errorf("failed to update service %s: %w", name, err)
+			}
+			fmt.Printf("Updated service %s\n", name)
+			return nil
+		},
+	}
+	addServiceFlags(editCmd)
+
+	// vibeman service remove <service-name>
+	removeCmd := &cobra.Command{
+		Use:     "remove <service-name>",
+		Short:   "Remove a stopped service from services.toml",
+		Aliases: []string{"rm"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			if err := serviceOps.RemoveService(cmd.Context(), args[0]); err != nil {
+				return fmt.Errorf("failed to remove service %s: %w", args[0], err)
+			}
+			fmt.Printf("Removed service %s\n", args[0])
+			return nil
+		},
+	}
+
+	// vibeman service import <compose-file> [service-name...]
+	importCmd := &cobra.Command{
+		Use:   "import <compose-file> [service-name...]",
+		Short: "Add services from a docker-compose.yaml",
+		Long: `Add services from a docker-compose.yaml to services.toml, all of them unless
+some are named. Imported services run from the compose file; with --inline their
+image, ports, environment, volumes and command are copied into services.toml
+instead, so the compose file is no longer needed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			inline, _ := cmd.Flags().GetBool("inline")
+			imported, err := serviceOps.ImportServices(cmd.Context(), operations.ImportServicesRequest{
+				ComposeFile: args[0],
+				Services:    args[1:],
+				Inline:      inline,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to import services: %w", err)
+			}
+			fmt.Printf("Imported %s\n", strings.Join(imported, ", "))
+			return nil
+		},
+	}
+	importCmd.Flags().Bool("inline", false, "Copy the service definitions instead of referencing the compose file")
+
+	return []*cobra.Command{addCmd, editCmd, removeCmd, importCmd}
+}
+
+// addServiceFlags adds the flags that set a service's configuration
+func addServiceFlags(cmd *cobra.Command) {
+	cmd.Flags().String("compose-file", "", "Compose file the service is defined in")
+	cmd.Flags().String("service", "", "Service in the compose file, or container name of an inline service")
+	cmd.Flags().String("image", "", "Image of an inline service")
+	cmd.Flags().StringArray("port", nil, "Port to publish, as HOST:CONTAINER (repeatable)")
+	cmd.Flags().StringArray("env", nil, "Environment variable, as KEY=VALUE (repeatable)")
+	cmd.Flags().StringArray("volume", nil, "Volume to mount, as VOLUME:PATH or HOST_PATH:PATH (repeatable)")
+	cmd.Flags().StringSlice("depends-on", nil, "Services that must be ready first")
+	cmd.Flags().String("description", "", "Description of the service")
+}
+
+// applyServiceFlags sets the parts of svc given on the command line;
+// arguments after -- replace the container command
+func applyServiceFlags(cmd *cobra.Command, args []string, svc *config.ServiceConfig) error {
+	flags := cmd.Flags()
+	if flags.Changed("compose-file") {
+		svc.ComposeFile, _ = flags.GetString("compose-file")
+	}
+	if flags.Changed("service") {
+		svc.Service, _ = flags.GetString("service")
+	}
+	if flags.Changed("image") {
+		svc.Image, _ = flags.GetString("image")
+	}
+	if flags.Changed("port") {
+		svc.Ports, _ = flags.GetStringArray("port")
+	}
+	if flags.Changed("env") {
+		pairs, _ := flags.GetStringArray("env")
+		svc.Env = make(map[string]string, len(pairs))
+		for _, pair := range pairs {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid --env %q: must be KEY=VALUE", pair)
+			}
+			svc.Env[name] = value
+		}
+	}
+	if flags.Changed("volume") {
+		svc.Volumes, _ = flags.GetStringArray("volume")
+	}
+	if flags.Changed("depends-on") {
+		svc.DependsOn, _ = flags.GetStringSlice("depends-on")
+	}
+	if flags.Changed("description") {
+		svc.Description, _ = flags.GetString("description")
+	}
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		svc.Command = args[dash:]
+	}
+	return nil
+}
+
+// editServiceConfig opens a service's configuration in $EDITOR and returns
+// what was saved
+func editServiceConfig(name string, svc config.ServiceConfig) (config.ServiceConfig, error) {
+	data, err := toml.Marshal(svc)
+	if err != nil {
+		return svc, fmt.Errorf("failed to marshal service %s: %w", name, err)
+	}
+
+	file, err := os.CreateTemp("", "vibeman-service-"+name+"-*.toml")
+	if err != nil {
+		return svc, err
+	}
+	defer os.Remove(file.Name())
+	header := fmt.Sprintf("# Service %s; save and quit to apply, or leave unchanged to cancel\n", name)
+	if _, err := file.WriteString(header + string(data)); err != nil {
+		file.Close()
+		return svc, err
+	}
+	if err := file.Close(); err != nil {
+		return svc, err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command(editor, file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return svc, fmt.Errorf("failed to open editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return svc, err
+	}
+	if string(edited) == header+string(data) {
+		return svc, fmt.Errorf("no changes to service %s", name)
+	}
+
+	var updated config.ServiceConfig
+	decoder := toml.NewDecoder(bytes.NewReader(edited))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&updated); err != nil {
+		return svc, fmt.Errorf("invalid configuration for service %s: %w", name, err)
+	}
+	return updated, nil
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"fmt"
+	"io"
 
 	"vibeman/internal/config"
 	"vibeman/internal/logger"
@@ -61,40 +62,74 @@ func ServicesCommands(cfg *config.Manager, cm ContainerManager, sm ServiceManage
 	}
 	commands = append(commands, statusCmd)
 
+	// vibeman services graph
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Show the service dependency graph",
+		Long:  "Show which services depend on which, in start order, or as Graphviz DOT with --format dot",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serviceOps == nil {
+				return fmt.Errorf("operations not initialized")
+			}
+			format, _ := cmd.Flags().GetString("format")
+			return showServiceGraph(cmd.OutOrStdout(), serviceOps, format)
+		},
+	}
+	graphCmd.Flags().String("format", "text", "Output format: text or dot")
+	commands = append(commands, graphCmd)
+
 	return commands
 }
 
+func showServiceGraph(w io.Writer, serviceOps *operations.ServiceOperations, format string) error {
+	graph, err := serviceOps.ServiceGraph()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "text":
+		if len(graph.Services()) == 0 {
+			logger.Info("No services configured")
+			return nil
+		}
+		fmt.Fprint(w, graph.Text())
+	case "dot":
+		fmt.Fprint(w, graph.DOT())
+	default:
+		return fmt.Errorf("invalid format %q: must be text or dot", format)
+	}
+	return nil
+}
+
 func startAllServices(ctx context.Context, serviceOps *operations.ServiceOperations) error {
-	// Get all services
-	services, err := serviceOps.ListServices(ctx)
+	logger.Info("Starting all global services...")
+
+	// Services start in dependency order, independent ones in parallel
+	results, err := serviceOps.StartServices(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list services: %w", err)
+		return fmt.Errorf("failed to start services: %w", err)
 	}
 
-	if len(services) == 0 {
+	if len(results) == 0 {
 		logger.Info("No services configured")
 		return nil
 	}
 
-	logger.Info("Starting all global services...")
-	
-	// Start each service that's not already running
 	started := 0
 	failed := 0
-	for _, svc := range services {
-		if svc.Status == "running" {
-			logger.WithFields(logger.Fields{"service": svc.Name}).Info("Already running")
-			continue
-		}
-
-		logger.WithFields(logger.Fields{"service": svc.Name}).Info("Starting service")
-		if err := serviceOps.StartService(ctx, svc.Name); err != nil {
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			logger.WithFields(logger.Fields{"service": result.Name}).Info("Already running")
+		case result.Err != nil:
 			logger.WithFields(logger.Fields{
-				"service": svc.Name,
-				"error":   err,
+				"service": result.Name,
+				"error":   result.Err,
 			}).Error("Failed to start service")
 			failed++
-		} else {
+		default:
+			logger.WithFields(logger.Fields{"service": result.Name}).Info("Started service")
 			started++
 		}
 	}
@@ -138,23 +173,26 @@ func stopAllServices(ctx context.Context, serviceOps *operations.ServiceOperatio
 
 	logger.Info("Stopping all global services...")
 
-	// Stop each running service
+	// Services stop before the services they depend on
+	results, err := serviceOps.StopServices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to stop services: %w", err)
+	}
+
 	stopped := 0
 	failed := 0
-	for _, svc := range services {
-		if svc.Status != "running" {
-			logger.WithFields(logger.Fields{"service": svc.Name}).Info("Already stopped")
-			continue
-		}
-
-		logger.WithFields(logger.Fields{"service": svc.Name}).Info("Stopping service")
-		if err := serviceOps.StopService(ctx, svc.Name); err != nil {
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			logger.WithFields(logger.Fields{"service": result.Name}).Info("Already stopped")
+		case result.Err != nil:
 			logger.WithFields(logger.Fields{
-				"service": svc.Name,
-				"error":   err,
+				"service": result.Name,
+				"error":   result.Err,
 			}).Error("Failed to stop service")
 			failed++
-		} else {
+		default:
+			logger.WithFields(logger.Fields{"service": result.Name}).Info("Stopped service")
 			stopped++
 		}
 	}
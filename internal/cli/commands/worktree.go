@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"vibeman/internal/client"
 	"vibeman/internal/config"
 	"vibeman/internal/container"
 	"vibeman/internal/db"
@@ -21,7 +22,7 @@ import (
 )
 
 // WorktreeCommands creates worktree management commands
-func WorktreeCommands(cfg *config.Manager, cm ContainerManager, gm GitManager, sm ServiceManager, dbRepo db.RepositoryManager, database *db.DB) []*cobra.Command {
+func WorktreeCommands(cfg *config.Manager, cm ContainerManager, gm GitManager, sm ServiceManager, dbRepo db.RepositoryManager, database *db.DB, remote OperationsClient) []*cobra.Command {
 	commands := []*cobra.Command{}
 
 	// Create operations instance
@@ -75,12 +76,18 @@ If repo-name is not provided, it will be detected from the current git repositor
 			skipSetup, _ := cmd.Flags().GetBool("skip-setup")
 			containerImage, _ := cmd.Flags().GetString("image")
 
-			if worktreeOps == nil || repoOps == nil {
+			if remote == nil && (worktreeOps == nil || repoOps == nil) {
 				return fmt.Errorf("operations not initialized")
 			}
 			
 			// Get repository by name
-			repos, err := repoOps.ListRepositories(cmd.Context())
+			var repos []*db.Repository
+			var err error
+			if remote != nil {
+				repos, err = remote.ListRepositories(cmd.Context())
+			} else {
+				repos, err = repoOps.ListRepositories(cmd.Context())
+			}
 			if err != nil {
 				return fmt.Errorf("failed to list repositories: %w", err)
 			}
@@ -97,6 +104,25 @@ If repo-name is not provided, it will be detected from the current git repositor
 				return fmt.Errorf("repository '%s' not found", repoName)
 			}
 			
+			fmt.Fprintf(cmd.OutOrStdout(), "Creating worktree %s in %s\n", worktreeName, repoName)
+
+			if remote != nil {
+				accepted, err := remote.CreateWorktreeOperation(cmd.Context(), client.CreateWorktreeOperationRequest{
+					RepositoryID:   repoID,
+					Name:           worktreeName,
+					Branch:         worktreeName, // Use worktree name as branch name
+					BaseBranch:     baseBranch,
+					SkipSetup:      skipSetup,
+					ContainerImage: containerImage,
+					AutoStart:      true,
+				})
+				if err != nil {
+					return err
+				}
+				_, err = followOperation(cmd.Context(), cmd.OutOrStdout(), remote, accepted.OperationID)
+				return HandleError(err)
+			}
+
 			req := operations.CreateWorktreeRequest{
 				RepositoryID: repoID,
 				Name: worktreeName,
@@ -107,11 +133,10 @@ If repo-name is not provided, it will be detected from the current git repositor
 				AutoStart: true,
 			}
 			
-			_, err = worktreeOps.CreateWorktree(cmd.Context(), req)
-			if err != nil {
+			return runWithProgress(cmd.Context(), cmd.OutOrStdout(), func(ctx context.Context) error {
+				_, err := worktreeOps.CreateWorktree(ctx, req)
 				return HandleError(err)
-			}
-			return nil
+			})
 		},
 	}
 	addCmd.Flags().StringP("base", "b", "", "Base branch for the worktree (default: repository's default branch)")
@@ -533,7 +558,8 @@ func createWorktree(ctx context.Context, repoName, worktreeName, baseBranch stri
 	// Start required services if any
 	if projectConfig, err := loadProjectConfigFromWorktree(worktreeDir); err == nil && len(projectConfig.Repository.Services) > 0 {
 		logger.Info("Starting required services...")
-		for serviceName, serviceReq := range projectConfig.Repository.Services {
+		for baseName, serviceReq := range projectConfig.Repository.Services {
+			serviceName := config.VersionedName(baseName, serviceReq.Version)
 			if serviceReq.Required {
 				if err := sm.StartService(ctx, serviceName); err != nil {
 					logger.WithFields(logger.Fields{"service": serviceName, "error": err}).Warn("Failed to start service")
@@ -770,7 +796,7 @@ func removeWorktree(ctx context.Context, repoName, worktreeName string, force bo
 
 		// Remove service references
 		if projectConfig, err := loadProjectConfigFromContainer(foundContainer); err == nil {
-			for serviceName := range projectConfig.Repository.Services {
+			for _, serviceName := range projectConfig.Repository.Services.Names() {
 				_ = sm.RemoveReference(serviceName, foundContainer.Name)
 			}
 		}
@@ -25,6 +25,7 @@ type Manager struct {
 	service   ServiceManager
 	repoMgr   db.RepositoryManager
 	database  *db.DB
+	remote    commands.OperationsClient
 	rootCmd   *cobra.Command
 }
 
@@ -59,6 +60,12 @@ func (m *Manager) SetManagers(container interfaces.ContainerManager, git interfa
 	m.setupCommands()
 }
 
+// SetOperationsClient routes worktree operations through a vibeman server.
+// It must be called before SetManagers.
+func (m *Manager) SetOperationsClient(remote commands.OperationsClient) {
+	m.remote = remote
+}
+
 // SetupDefaultCommands sets up commands with nil managers (for testing)
 func (m *Manager) SetupDefaultCommands() {
 	m.setupCommands()
@@ -89,7 +96,7 @@ func (m *Manager) setupCommands() {
 		Short:   "Repository management commands",
 		Aliases: []string{"proj", "p"},
 	}
-	for _, cmd := range commands.RepositoryCommands(m.config, m.container, m.git, m.service, m.repoMgr, m.database) {
+	for _, cmd := range commands.RepositoryCommands(m.config, m.container, m.git, m.service, m.repoMgr, m.database, m.remote) {
 		projectCmd.AddCommand(cmd)
 		// Add top-level aliases for common commands
 		if cmd.Use == "list" || cmd.Use == "status [project-name]" {
@@ -143,7 +150,7 @@ func (m *Manager) setupCommands() {
 		Short:   "Worktree development environment commands",
 		Aliases: []string{"worktree", "feat"}, // Backward compatibility
 	}
-	for _, cmd := range commands.WorktreeCommands(m.config, m.container, m.git, m.service, m.repoMgr, m.database) {
+	for _, cmd := range commands.WorktreeCommands(m.config, m.container, m.git, m.service, m.repoMgr, m.database, m.remote) {
 		worktreeCmd.AddCommand(cmd)
 	}
 	m.rootCmd.AddCommand(worktreeCmd)
@@ -175,6 +182,19 @@ func (m *Manager) setupCommands() {
 	}
 	m.rootCmd.AddCommand(serverCmd)
 	
+	// Add authentication commands
+	authCmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Authenticate with a vibeman server",
+	}
+	for _, cmd := range commands.AuthCommands(m.database) {
+		authCmd.AddCommand(cmd)
+	}
+	m.rootCmd.AddCommand(authCmd)
+
+	// Add audit log commands
+	m.rootCmd.AddCommand(commands.CreateAuditCommand(m.database))
+
 	// Add AI container commands
 	aiCmd := commands.CreateAICommand(m.config, m.container, m.git, m.service, m.database)
 	m.rootCmd.AddCommand(aiCmd)
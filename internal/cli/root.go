@@ -21,5 +21,8 @@ web interfaces for managing your development workflow.`,
 		},
 	}
 
+	// Handled by the app before commands run; registered so cobra accepts it
+	rootCmd.PersistentFlags().String("server", "", "Vibeman server URL to run commands against (or set VIBEMAN_SERVER)")
+
 	return rootCmd
 }
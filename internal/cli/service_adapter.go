@@ -38,11 +38,46 @@ func (w *ServiceManagerWrapper) RemoveReference(serviceName, repositoryName stri
 	return w.manager.RemoveReference(serviceName, repositoryName)
 }
 
+// ReleaseReferences removes every service reference a worktree holds
+func (w *ServiceManagerWrapper) ReleaseReferences(ctx context.Context, holder string) error {
+	return w.manager.ReleaseReferences(ctx, holder)
+}
+
 // HealthCheck performs a health check on a service
 func (w *ServiceManagerWrapper) HealthCheck(ctx context.Context, name string) error {
 	return w.manager.HealthCheck(ctx, name)
 }
 
+// ProvisionWorktree creates a worktree's isolated resource on a shared service
+func (w *ServiceManagerWrapper) ProvisionWorktree(ctx context.Context, serviceName, worktreeID, baseName string) (map[string]string, error) {
+	return w.manager.ProvisionWorktree(ctx, serviceName, worktreeID, baseName)
+}
+
+// DeprovisionWorktree drops a worktree's resources on shared services
+func (w *ServiceManagerWrapper) DeprovisionWorktree(ctx context.Context, worktreeID string) error {
+	return w.manager.DeprovisionWorktree(ctx, worktreeID)
+}
+
+// ServiceEnv returns the environment a worktree uses to reach services
+func (w *ServiceManagerWrapper) ServiceEnv(ctx context.Context, worktreeID string, services []string, inContainer bool) (map[string]string, error) {
+	return w.manager.ServiceEnv(ctx, worktreeID, services, inContainer)
+}
+
+// CreateSnapshot saves a service's data
+func (w *ServiceManagerWrapper) CreateSnapshot(ctx context.Context, name string, opts service.SnapshotOptions) (*service.Snapshot, error) {
+	return w.manager.CreateSnapshot(ctx, name, opts)
+}
+
+// ListSnapshots lists the snapshots of a service, or of every service
+func (w *ServiceManagerWrapper) ListSnapshots(name string) ([]*service.Snapshot, error) {
+	return w.manager.ListSnapshots(name)
+}
+
+// RestoreSnapshot replaces a service's data with a snapshot
+func (w *ServiceManagerWrapper) RestoreSnapshot(ctx context.Context, name, ref string, opts service.SnapshotOptions) (*service.Snapshot, error) {
+	return w.manager.RestoreSnapshot(ctx, name, ref, opts)
+}
+
 // GetService retrieves service information
 func (w *ServiceManagerWrapper) GetService(name string) (interface{}, error) {
 	svc, err := w.manager.GetService(name)
@@ -66,6 +101,8 @@ func (w *ServiceManagerWrapper) GetService(name string) (interface{}, error) {
 		Config:      svcInstance.Config,
 		StartTime:   svcInstance.StartTime,
 		LastHealth:  svcInstance.LastHealth,
+		Health:      string(svcInstance.Health),
 		HealthError: svcInstance.HealthError,
+		Restarts:    svcInstance.Restarts,
 	}, nil
 }
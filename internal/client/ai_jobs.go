@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"vibeman/internal/db"
+)
+
+// CreateAIJobRequest is the request body for queueing a headless agent run
+type CreateAIJobRequest struct {
+	Prompt string `json:"prompt"`
+	Agent  string `json:"agent,omitempty"`
+}
+
+// CreateAIJob queues a headless agent run in a worktree's AI container
+func (c *Client) CreateAIJob(ctx context.Context, worktreeID string, req CreateAIJobRequest) (*db.AIJob, error) {
+	resp, err := c.doRequest(ctx, "POST", fmt.Sprintf("/api/worktrees/%s/ai/jobs", url.PathEscape(worktreeID)), req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeError(resp, "queue AI job")
+	}
+
+	var job db.AIJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &job, nil
+}
+
+// ListAIJobs lists AI jobs, newest first, optionally only those of a
+// worktree or in a status. Transcripts and diffs are not included.
+func (c *Client) ListAIJobs(ctx context.Context, worktreeID, status string) ([]*db.AIJob, error) {
+	path := "/api/ai/jobs"
+	if worktreeID != "" {
+		path = fmt.Sprintf("/api/worktrees/%s/ai/jobs", url.PathEscape(worktreeID))
+	}
+	if status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list AI jobs")
+	}
+
+	var result struct {
+		Jobs []*db.AIJob `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Jobs, nil
+}
+
+// GetAIJob gets an AI job with its transcript and diff
+func (c *Client) GetAIJob(ctx context.Context, id string) (*db.AIJob, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/ai/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "get AI job")
+	}
+
+	var job db.AIJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &job, nil
+}
+
+// CancelAIJob cancels a queued or running AI job
+func (c *Client) CancelAIJob(ctx context.Context, id string) error {
+	resp, err := c.doRequest(ctx, "DELETE", "/api/ai/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return decodeError(resp, "cancel AI job")
+	}
+
+	return nil
+}
+
+// AIFanoutRequest is the request body for running one prompt in several fresh
+// worktrees
+type AIFanoutRequest struct {
+	Count       int    `json:"count"`
+	Prompt      string `json:"prompt"`
+	Agent       string `json:"agent,omitempty"`
+	BaseBranch  string `json:"base_branch,omitempty"`
+	NamePrefix  string `json:"name_prefix,omitempty"`
+	TestCommand string `json:"test_command,omitempty"`
+}
+
+// CreateAIFanout starts an asynchronous fan-out of a prompt across fresh
+// worktrees of a repository
+func (c *Client) CreateAIFanout(ctx context.Context, repositoryID string, req AIFanoutRequest) (*OperationAccepted, error) {
+	resp, err := c.doRequest(ctx, "POST", fmt.Sprintf("/api/repositories/%s/ai/fanout", url.PathEscape(repositoryID)), req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeError(resp, "start AI fan-out")
+	}
+
+	var accepted OperationAccepted
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &accepted, nil
+}
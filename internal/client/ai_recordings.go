@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AIRecording is a recorded AI terminal session
+type AIRecording struct {
+	Name       string    `json:"name"`
+	Repository string    `json:"repository"`
+	Worktree   string    `json:"worktree"`
+	Session    string    `json:"session"`
+	Source     string    `json:"source"`
+	Size       int64     `json:"size"`
+	StartedAt  time.Time `json:"started_at"`
+	Duration   float64   `json:"duration"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+}
+
+// ListAIRecordings lists the recorded AI terminal sessions, newest first,
+// optionally only those of the named worktree
+func (c *Client) ListAIRecordings(ctx context.Context, worktree string) ([]AIRecording, error) {
+	path := "/api/ai/recordings"
+	if worktree != "" {
+		path += "?worktree=" + url.QueryEscape(worktree)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list AI recordings")
+	}
+
+	var result struct {
+		Recordings []AIRecording `json:"recordings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Recordings, nil
+}
+
+// DownloadAIRecording returns the asciicast recording of a worktree's AI
+// terminal session with the given name. The caller closes it.
+func (c *Client) DownloadAIRecording(ctx context.Context, worktree, name string) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/api/ai/recordings/%s/%s", url.PathEscape(worktree), url.PathEscape(name))
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, decodeError(resp, "download AI recording")
+	}
+
+	return resp.Body, nil
+}
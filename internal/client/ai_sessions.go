@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AISession is a persistent terminal session in an AI container
+type AISession struct {
+	Name       string    `json:"name"`
+	Worktree   string    `json:"worktree"`
+	WorktreeID string    `json:"worktree_id"`
+	Container  string    `json:"container"`
+	CreatedAt  time.Time `json:"created_at"`
+	Clients    int       `json:"clients"`
+	Command    string    `json:"command,omitempty"`
+	Viewers    int       `json:"viewers"`
+	HasWriter  bool      `json:"has_writer"`
+	Shares     int       `json:"shares"`
+	// What the agent in the session is doing, for sessions running an agent
+	Agent *AgentActivity `json:"agent,omitempty"`
+}
+
+// AgentActivity is what the server's watcher last made of an agent: working,
+// waiting for input or finished
+type AgentActivity struct {
+	State  string    `json:"state"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// AISessionShare is a read-only share link to an AI terminal session
+type AISessionShare struct {
+	Token     string    `json:"token"`
+	Path      string    `json:"path"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// aiSessionPath returns the API path of a worktree's session
+func aiSessionPath(worktree, session string) string {
+	return fmt.Sprintf("/api/ai/sessions/%s/%s", url.PathEscape(worktree), url.PathEscape(session))
+}
+
+// ListAISessions lists the persistent terminal sessions in AI containers,
+// optionally only those of the named worktree
+func (c *Client) ListAISessions(ctx context.Context, worktree string) ([]AISession, error) {
+	path := "/api/ai/sessions"
+	if worktree != "" {
+		path += "?worktree=" + url.QueryEscape(worktree)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list AI sessions")
+	}
+
+	var result struct {
+		Sessions []AISession `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Sessions, nil
+}
+
+// ShareAISession creates a read-only share link to a session. A zero ttl
+// uses the server's default lifetime.
+func (c *Client) ShareAISession(ctx context.Context, worktree, session string, ttl time.Duration) (*AISessionShare, error) {
+	var req struct {
+		TTL string `json:"ttl,omitempty"`
+	}
+	if ttl > 0 {
+		req.TTL = ttl.String()
+	}
+
+	resp, err := c.doRequest(ctx, "POST", aiSessionPath(worktree, session)+"/share", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, decodeError(resp, "share AI session")
+	}
+
+	var share AISessionShare
+	if err := json.NewDecoder(resp.Body).Decode(&share); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &share, nil
+}
+
+// UnshareAISession revokes the share links to a session
+func (c *Client) UnshareAISession(ctx context.Context, worktree, session string) error {
+	resp, err := c.doRequest(ctx, "DELETE", aiSessionPath(worktree, session)+"/share", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp, "revoke AI session share links")
+	}
+
+	return nil
+}
+
+// KillAISession ends a session and whatever runs in it
+func (c *Client) KillAISession(ctx context.Context, worktree, session string) error {
+	resp, err := c.doRequest(ctx, "DELETE", aiSessionPath(worktree, session), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp, "end AI session")
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"vibeman/internal/db"
+)
+
+// AuditQuery filters the audit log
+type AuditQuery struct {
+	Target string
+	Since  string // Duration such as 24h, a date or an RFC 3339 time
+	Actor  string
+	Action string
+	Limit  int
+}
+
+// ListAuditEvents queries the server's audit log, newest first
+func (c *Client) ListAuditEvents(ctx context.Context, query AuditQuery) ([]*db.AuditEvent, error) {
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"target": query.Target,
+		"since":  query.Since,
+		"actor":  query.Actor,
+		"action": query.Action,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	path := "/api/audit"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list audit events")
+	}
+
+	var result struct {
+		Events []*db.AuditEvent `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Events, nil
+}
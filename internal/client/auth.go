@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 // LoginRequest represents the login request payload
@@ -15,9 +17,10 @@ type LoginRequest struct {
 
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Token   string `json:"token"`
-	User    User   `json:"user"`
-	Message string `json:"message,omitempty"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+	Message   string    `json:"message,omitempty"`
 }
 
 // User represents user information
@@ -111,3 +114,93 @@ func (c *Client) WhoAmI(ctx context.Context) (*User, error) {
 
 	return &user, nil
 }
+
+// APIToken represents a personal API token
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPITokenRequest represents a request to create a personal API token
+type CreateAPITokenRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn string   `json:"expires_in,omitempty"`
+}
+
+// CreateAPIToken creates a personal API token. The returned token value is
+// only available at creation.
+func (c *Client) CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (string, *APIToken, error) {
+	if !c.IsAuthenticated() {
+		return "", nil, fmt.Errorf("not authenticated")
+	}
+
+	resp, err := c.doRequest(ctx, "POST", "/api/auth/tokens", req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", nil, decodeError(resp, "create token")
+	}
+
+	var result struct {
+		Token    string   `json:"token"`
+		APIToken APIToken `json:"api_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Token, &result.APIToken, nil
+}
+
+// ListAPITokens lists the personal API tokens of the current user
+func (c *Client) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	if !c.IsAuthenticated() {
+		return nil, fmt.Errorf("not authenticated")
+	}
+
+	resp, err := c.doRequest(ctx, "GET", "/api/auth/tokens", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list tokens")
+	}
+
+	var result struct {
+		Tokens []APIToken `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Tokens, nil
+}
+
+// RevokeAPIToken deletes a personal API token
+func (c *Client) RevokeAPIToken(ctx context.Context, id string) error {
+	if !c.IsAuthenticated() {
+		return fmt.Errorf("not authenticated")
+	}
+
+	resp, err := c.doRequest(ctx, "DELETE", "/api/auth/tokens/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return decodeError(resp, "revoke token")
+	}
+
+	return nil
+}
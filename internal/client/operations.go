@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"vibeman/internal/db"
+)
+
+// OperationAccepted is returned by the server when an asynchronous operation is started
+type OperationAccepted struct {
+	OperationID string `json:"operation_id"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	Location    string `json:"location"`
+}
+
+// CreateWorktreeOperationRequest is the request body for asynchronous worktree creation
+type CreateWorktreeOperationRequest struct {
+	RepositoryID    string   `json:"repository_id"`
+	Name            string   `json:"name"`
+	Branch          string   `json:"branch,omitempty"`
+	BaseBranch      string   `json:"base_branch,omitempty"`
+	SkipSetup       bool     `json:"skip_setup,omitempty"`
+	ContainerImage  string   `json:"container_image,omitempty"`
+	AutoStart       bool     `json:"auto_start,omitempty"`
+	ComposeFile     string   `json:"compose_file,omitempty"`
+	ComposeServices []string `json:"compose_services,omitempty"`
+	PostScripts     []string `json:"post_scripts,omitempty"`
+}
+
+// decodeError extracts the error message from an API error response
+func decodeError(resp *http.Response, action string) error {
+	var errResp struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		return fmt.Errorf("failed to %s: %s", action, resp.Status)
+	}
+	if errResp.Error == "" {
+		errResp.Error = errResp.Message
+	}
+	if errResp.Error == "" {
+		return fmt.Errorf("failed to %s: %s", action, resp.Status)
+	}
+	return fmt.Errorf("failed to %s: %s", action, errResp.Error)
+}
+
+// ListRepositories lists repositories tracked by the server
+func (c *Client) ListRepositories(ctx context.Context) ([]*db.Repository, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/repositories", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list repositories")
+	}
+
+	var result struct {
+		Repositories []*db.Repository `json:"repositories"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Repositories, nil
+}
+
+// ListRepositoryWorktrees lists the worktrees of a repository tracked by the server
+func (c *Client) ListRepositoryWorktrees(ctx context.Context, repositoryID string) ([]db.Worktree, error) {
+	path := "/api/worktrees"
+	if repositoryID != "" {
+		path += "?repository_id=" + url.QueryEscape(repositoryID)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "list worktrees")
+	}
+
+	var result struct {
+		Worktrees []db.Worktree `json:"worktrees"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Worktrees, nil
+}
+
+// CreateWorktreeOperation starts asynchronous creation of a worktree
+func (c *Client) CreateWorktreeOperation(ctx context.Context, req CreateWorktreeOperationRequest) (*OperationAccepted, error) {
+	resp, err := c.doRequest(ctx, "POST", "/api/worktrees", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeError(resp, "create worktree")
+	}
+
+	var accepted OperationAccepted
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &accepted, nil
+}
+
+// StartWorktreeOperation starts a worktree asynchronously
+func (c *Client) StartWorktreeOperation(ctx context.Context, worktreeID string) (*OperationAccepted, error) {
+	resp, err := c.doRequest(ctx, "POST", fmt.Sprintf("/api/worktrees/%s/start", url.PathEscape(worktreeID)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, decodeError(resp, "start worktree")
+	}
+
+	var accepted OperationAccepted
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &accepted, nil
+}
+
+// GetOperation returns the current state of an operation
+func (c *Client) GetOperation(ctx context.Context, id string) (*db.Operation, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/operations/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp, "get operation")
+	}
+
+	var op db.Operation
+	if err := json.NewDecoder(resp.Body).Decode(&op); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &op, nil
+}
+
+// CancelOperation requests cancellation of a running operation
+func (c *Client) CancelOperation(ctx context.Context, id string) error {
+	resp, err := c.doRequest(ctx, "DELETE", "/api/operations/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return decodeError(resp, "cancel operation")
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"vibeman/internal/validation"
+)
+
+// DefaultAgent is the agent provider run in AI containers when none is
+// configured
+const DefaultAgent = "claude"
+
+// DefaultAIShell is the shell attaching to an AI container opens when none
+// is configured
+const DefaultAIShell = "/bin/zsh"
+
+// AIContainerHome is the home directory of the user agents run as in the AI
+// container
+const AIContainerHome = "/home/vibeman"
+
+// AIMCPConfigPath is where the AI container's MCP configuration, which
+// points agents at vibeman's MCP server, is mounted
+const AIMCPConfigPath = AIContainerHome + "/.vibeman/mcp.json"
+
+// AgentConfig describes an AI coding agent: how to launch it, the
+// credentials it needs and where it keeps its configuration
+type AgentConfig struct {
+	Command         []string `toml:"command"`           // Command that starts the agent, e.g. ["claude"]
+	PermissionFlags []string `toml:"permission_flags"`  // Flags that let the agent act without asking, e.g. ["--dangerously-skip-permissions"]
+	Env             []string `toml:"env"`               // Credentials passed through from the host when set, e.g. ["ANTHROPIC_API_KEY"]; only the global configuration sets them
+	Config          []string `toml:"config"`            // Config files and directories, relative to the home directory, mounted read-only from the host when present; only the global configuration sets them
+	Headless        []string `toml:"headless"`          // Arguments that run the agent without a terminal, reading the prompt from stdin; unset if it cannot
+	MCP             []string `toml:"mcp"`               // Arguments that load the MCP servers in AIMCPConfigPath, e.g. ["--mcp-config", "/home/vibeman/.vibeman/mcp.json"]
+	Instructions    string   `toml:"instructions_file"` // File in the worktree the agent reads instructions from, e.g. "CLAUDE.md"
+}
+
+// AgentAccess is what of the host an agent provider reaches from AI
+// containers. Only the global configuration grants it, so a repository
+// cannot hand host secrets or config files to its agents.
+type AgentAccess struct {
+	Env    []string `toml:"env"`    // Credentials passed through from the host, replacing the provider's
+	Config []string `toml:"config"` // Config files and directories mounted from the home directory, replacing the provider's
+}
+
+// builtinAgents are the agent providers vibeman knows how to run
+var builtinAgents = map[string]AgentConfig{
+	"claude": {
+		Command:         []string{"claude"},
+		PermissionFlags: []string{"--dangerously-skip-permissions"},
+		Env:             []string{"ANTHROPIC_API_KEY", "CLAUDE_CODE_OAUTH_TOKEN"},
+		Config:          []string{".claude", ".claude.json"},
+		Headless:        []string{"-p"},
+		MCP:             []string{"--mcp-config", AIMCPConfigPath},
+		Instructions:    "CLAUDE.md",
+	},
+	"codex": {
+		Command:         []string{"codex"},
+		PermissionFlags: []string{"--dangerously-bypass-approvals-and-sandbox"},
+		Env:             []string{"OPENAI_API_KEY"},
+		Config:          []string{".codex"},
+		Headless:        []string{"exec"},
+		Instructions:    "AGENTS.md",
+	},
+	"aider": {
+		Command:         []string{"aider"},
+		PermissionFlags: []string{"--yes-always"},
+		Env:             []string{"OPENAI_API_KEY", "ANTHROPIC_API_KEY", "GEMINI_API_KEY"},
+		Config:          []string{".aider.conf.yml"},
+		Headless:        []string{"--message-file", "/dev/stdin"},
+		Instructions:    "CONVENTIONS.md",
+	},
+	"gemini": {
+		Command:         []string{"gemini"},
+		PermissionFlags: []string{"--yolo"},
+		Env:             []string{"GEMINI_API_KEY", "GOOGLE_API_KEY"},
+		Config:          []string{".gemini"},
+		Headless:        []string{}, // Runs headless whenever stdin is not a terminal
+		Instructions:    "GEMINI.md",
+	},
+}
+
+// AgentNames returns the agent providers available to a repository: the
+// built-in ones and those its AI configuration declares
+func (ai *AIConfig) AgentNames() []string {
+	names := slices.Collect(maps.Keys(builtinAgents))
+	for name := range ai.Agents {
+		if _, builtin := builtinAgents[name]; !builtin {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ResolveAgent returns the agent provider called name, or the configured
+// agent when name is empty. Settings declared under [ai.agents.<name>]
+// replace those of a built-in provider with the same name, except for the
+// credentials and config files, which only WithAccess changes.
+func (ai *AIConfig) ResolveAgent(name string) (string, AgentConfig, error) {
+	if name == "" {
+		name = ai.Agent
+	}
+	if name == "" {
+		name = DefaultAgent
+	}
+
+	agent, builtin := builtinAgents[name]
+	override, custom := ai.Agents[name]
+	if !builtin && !custom {
+		return "", AgentConfig{}, fmt.Errorf("unknown agent %q, must be one of: %s", name, strings.Join(ai.AgentNames(), ", "))
+	}
+	if override.Command != nil {
+		agent.Command = override.Command
+	}
+	if override.PermissionFlags != nil {
+		agent.PermissionFlags = override.PermissionFlags
+	}
+	if override.Headless != nil {
+		agent.Headless = override.Headless
+	}
+	if override.MCP != nil {
+		agent.MCP = override.MCP
+	}
+	if override.Instructions != "" {
+		agent.Instructions = override.Instructions
+	}
+	if len(agent.Command) == 0 {
+		return "", AgentConfig{}, fmt.Errorf("agent %q has no command", name)
+	}
+	return name, agent, nil
+}
+
+// Validate checks that the configured agent and every custom agent can be
+// launched, and that the security settings are sound
+func (ai *AIConfig) Validate() error {
+	if err := validation.ShellCommand(ai.Shell); err != nil {
+		return err
+	}
+	if _, _, err := ai.ResolveAgent(""); err != nil {
+		return err
+	}
+	if err := ai.Security.Validate(); err != nil {
+		return fmt.Errorf("security: %w", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(ai.Agents)) {
+		_, agent, err := ai.ResolveAgent(name)
+		if err != nil {
+			return err
+		}
+		if override := ai.Agents[name]; override.Env != nil || override.Config != nil {
+			return fmt.Errorf("agent %q: env and config can only be set in the global configuration's [ai.agents.%s]", name, name)
+		}
+		if agent.Instructions != "" && !filepath.IsLocal(agent.Instructions) {
+			return fmt.Errorf("agent %q: instructions file %q must be relative to the worktree", name, agent.Instructions)
+		}
+	}
+	return nil
+}
+
+// ShellCommand returns the command that starts the configured shell
+func (ai *AIConfig) ShellCommand() []string {
+	if ai.Shell == "" {
+		return []string{DefaultAIShell}
+	}
+	return []string{ai.Shell}
+}
+
+// Launch returns the command that starts the agent with vibeman's MCP
+// server, and with its permission flags unless the agent should ask before
+// acting
+func (a AgentConfig) Launch(askPermission bool) []string {
+	launch := slices.Concat(a.Command, a.MCP)
+	if !askPermission {
+		launch = append(launch, a.PermissionFlags...)
+	}
+	return launch
+}
+
+// HeadlessCommand returns the command that runs the agent without a
+// terminal, reading its prompt from stdin and acting without asking, and
+// whether the agent can run that way at all
+func (a AgentConfig) HeadlessCommand() ([]string, bool) {
+	if a.Headless == nil {
+		return nil, false
+	}
+	return slices.Concat(a.Command, a.MCP, a.Headless, a.PermissionFlags), true
+}
+
+// WithAccess returns the agent with the host credentials and config files
+// access grants in place of its own
+func (a AgentConfig) WithAccess(access AgentAccess) AgentConfig {
+	if access.Env != nil {
+		a.Env = access.Env
+	}
+	if access.Config != nil {
+		a.Config = access.Config
+	}
+	return a
+}
+
+// Validate checks the credentials are environment variable names and the
+// config files lie in the home directory
+func (access AgentAccess) Validate() error {
+	for _, name := range access.Env {
+		if err := validation.EnvironmentVariable(name + "="); err != nil {
+			return fmt.Errorf("env %q: %w", name, err)
+		}
+	}
+	for _, path := range access.Config {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("config path %q must be relative to the home directory", path)
+		}
+	}
+	return nil
+}
+
+// Credentials returns NAME=value for each of the agent's credentials set in
+// the environment lookup reads
+func (a AgentConfig) Credentials(lookup func(string) (string, bool)) []string {
+	var env []string
+	for _, name := range a.Env {
+		if value, ok := lookup(name); ok && value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// Mounts returns read-only host:container volume mounts for the agent's
+// config files that exist under the host home directory, so agents cannot
+// rewrite the host's credentials or settings
+func (a AgentConfig) Mounts(home string) []string {
+	var mounts []string
+	for _, path := range a.Config {
+		hostPath := filepath.Join(home, path)
+		if _, err := os.Stat(hostPath); err != nil {
+			continue
+		}
+		mounts = append(mounts, hostPath+":"+filepath.Join(AIContainerHome, path)+":ro")
+	}
+	return mounts
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAgent(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vibeman.toml"), []byte(`
+[repository]
+name = "app"
+
+[repository.ai]
+agent = "reviewer"
+
+[repository.ai.agents.reviewer]
+command = ["reviewbot", "--model", "large"]
+
+[repository.ai.agents.claude]
+permission_flags = []
+`), 0644))
+
+	repoConfig, err := ParseRepositoryConfig(dir)
+	require.NoError(t, err)
+	ai := &repoConfig.Repository.AI
+	require.NoError(t, ai.Validate())
+	assert.Equal(t, []string{"aider", "claude", "codex", "gemini", "reviewer"}, ai.AgentNames())
+
+	// The configured agent is the default
+	name, agent, err := ai.ResolveAgent("")
+	require.NoError(t, err)
+	assert.Equal(t, "reviewer", name)
+	assert.Equal(t, []string{"reviewbot", "--model", "large"}, agent.Launch(false))
+
+	// Overrides replace only the settings they declare
+	_, claude, err := ai.ResolveAgent("claude")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"claude", "--mcp-config", AIMCPConfigPath}, claude.Launch(false))
+	assert.Equal(t, []string{"ANTHROPIC_API_KEY", "CLAUDE_CODE_OAUTH_TOKEN"}, claude.Env)
+	assert.Equal(t, "CLAUDE.md", claude.Instructions)
+
+	_, codex, err := ai.ResolveAgent("codex")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"codex", "--dangerously-bypass-approvals-and-sandbox"}, codex.Launch(false))
+	assert.Equal(t, []string{"codex"}, codex.Launch(true))
+	assert.Equal(t, "AGENTS.md", codex.Instructions)
+
+	_, _, err = ai.ResolveAgent("copilot")
+	assert.ErrorContains(t, err, `unknown agent "copilot", must be one of: aider, claude, codex, gemini, reviewer`)
+
+	name, _, err = (&AIConfig{}).ResolveAgent("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAgent, name)
+}
+
+func TestValidateAgents(t *testing.T) {
+	tests := []struct {
+		name    string
+		ai      AIConfig
+		wantErr string
+	}{
+		{"unknown agent", AIConfig{Agent: "copilot"}, `unknown agent "copilot"`},
+		{"no command", AIConfig{Agents: map[string]AgentConfig{"bot": {Env: []string{"TOKEN"}}}}, `agent "bot" has no command`},
+		{"repository credentials", AIConfig{Agents: map[string]AgentConfig{"claude": {Env: []string{"AWS_SECRET_ACCESS_KEY"}}}}, `agent "claude": env and config can only be set in the global configuration's [ai.agents.claude]`},
+		{"repository config", AIConfig{Agents: map[string]AgentConfig{"bot": {Command: []string{"bot"}, Config: []string{".ssh"}}}}, `agent "bot": env and config can only be set in the global configuration`},
+		{"escaping instructions", AIConfig{Agents: map[string]AgentConfig{"bot": {Command: []string{"bot"}, Instructions: "../RULES.md"}}}, `instructions file "../RULES.md" must be relative`},
+		{"unknown shell", AIConfig{Shell: "/usr/bin/python3"}, "must be a valid shell path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.ai.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestAgentCredentialsAndMounts(t *testing.T) {
+	home := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".gemini"), 0755))
+
+	_, gemini, err := (&AIConfig{}).ResolveAgent("gemini")
+	require.NoError(t, err)
+
+	env := map[string]string{"GEMINI_API_KEY": "secret", "GOOGLE_API_KEY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	assert.Equal(t, []string{"GEMINI_API_KEY=secret"}, gemini.Credentials(lookup))
+	assert.Equal(t, []string{filepath.Join(home, ".gemini") + ":/home/vibeman/.gemini:ro"}, gemini.Mounts(home))
+
+	_, codex, err := (&AIConfig{}).ResolveAgent("codex")
+	require.NoError(t, err)
+	assert.Empty(t, codex.Mounts(home))
+
+	// A repository cannot widen what an agent reaches of the host
+	ai := &AIConfig{Agents: map[string]AgentConfig{"gemini": {Env: []string{"AWS_SECRET_ACCESS_KEY"}, Config: []string{".ssh"}}}}
+	_, gemini, err = ai.ResolveAgent("gemini")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GEMINI_API_KEY", "GOOGLE_API_KEY"}, gemini.Env)
+	assert.Equal(t, []string{".gemini"}, gemini.Config)
+
+	// The global configuration replaces it
+	gemini = gemini.WithAccess(AgentAccess{Env: []string{}})
+	assert.Empty(t, gemini.Credentials(lookup))
+	assert.Equal(t, []string{".gemini"}, gemini.Config)
+}
+
+func TestValidateAgentAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		access  AgentAccess
+		wantErr string
+	}{
+		{"absolute config", AgentAccess{Config: []string{"/etc/bot"}}, `config path "/etc/bot" must be relative`},
+		{"escaping config", AgentAccess{Config: []string{"../bot"}}, `config path "../bot" must be relative`},
+		{"bad env", AgentAccess{Env: []string{"BOT-TOKEN"}}, `env "BOT-TOKEN"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.access.Validate(), tt.wantErr)
+		})
+	}
+	assert.NoError(t, AgentAccess{Env: []string{"BOT_TOKEN"}, Config: []string{".config/bot"}}.Validate())
+}
+
+func TestAgentHeadlessCommand(t *testing.T) {
+	ai := &AIConfig{Agents: map[string]AgentConfig{
+		"reviewbot": {Command: []string{"reviewbot"}},
+	}}
+
+	_, claude, err := ai.ResolveAgent("claude")
+	require.NoError(t, err)
+	command, ok := claude.HeadlessCommand()
+	assert.True(t, ok)
+	assert.Equal(t, []string{"claude", "--mcp-config", AIMCPConfigPath, "-p", "--dangerously-skip-permissions"}, command)
+
+	_, gemini, err := ai.ResolveAgent("gemini")
+	require.NoError(t, err)
+	command, ok = gemini.HeadlessCommand()
+	assert.True(t, ok)
+	assert.Equal(t, []string{"gemini", "--yolo"}, command)
+
+	_, reviewbot, err := ai.ResolveAgent("reviewbot")
+	require.NoError(t, err)
+	_, ok = reviewbot.HeadlessCommand()
+	assert.False(t, ok)
+}
+
+func TestAIShellCommand(t *testing.T) {
+	assert.Equal(t, []string{DefaultAIShell}, (&AIConfig{}).ShellCommand())
+	assert.Equal(t, []string{"/bin/bash"}, (&AIConfig{Shell: "/bin/bash"}).ShellCommand())
+	assert.NoError(t, (&AIConfig{Shell: "fish"}).Validate())
+}
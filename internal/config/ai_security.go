@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AI container security profiles
+const (
+	// AISecurityStandard runs the AI container as configured, applying only
+	// the settings that are set
+	AISecurityStandard = "standard"
+	// AISecurityHardened sandboxes the AI container, with safe defaults for
+	// the settings that are not set
+	AISecurityHardened = "hardened"
+)
+
+// Defaults of the hardened profile
+const (
+	DefaultAISandboxCPUs      = "2"
+	DefaultAISandboxMemory    = "4g"
+	DefaultAISandboxPidsLimit = 1024
+)
+
+// DefaultAISandboxTmpfs are the writable tmpfs mounts of a hardened AI
+// container's read-only root filesystem
+var DefaultAISandboxTmpfs = []string{"/tmp", "/var/tmp", "/run", AIContainerHome + "/.cache"}
+
+// aiMemoryPattern matches the memory sizes docker accepts
+var aiMemoryPattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// aiUserPattern matches user[:group] by name or id
+var aiUserPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(:[A-Za-z0-9_.-]+)?$`)
+
+// aiCapabilityPattern matches capability names, with or without CAP_
+var aiCapabilityPattern = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+// AISecurityConfig is the security profile of a repository's AI containers,
+// which run agents that act without asking
+type AISecurityConfig struct {
+	Profile  string   `toml:"profile"`   // standard (default) or hardened
+	ReadOnly *bool    `toml:"read_only"` // Root filesystem read-only but for /workspace, tmpfs mounts and volumes (hardened default true)
+	Tmpfs    []string `toml:"tmpfs"`     // Writable tmpfs mounts of a read-only root filesystem (hardened default /tmp, /var/tmp, /run and ~/.cache)
+	CapAdd   []string `toml:"cap_add"`   // Capabilities kept when the hardened profile drops them all
+	Seccomp  string   `toml:"seccomp"`   // Seccomp profile, relative to the repository; Docker's default when unset
+	User     string   `toml:"user"`      // uid:gid agents run as (hardened default: yours, so files they write are owned by you)
+	UserNS   string   `toml:"userns"`    // User namespace mode, e.g. host or keep-id where the runtime supports it
+	// Resource limits (hardened default 2 CPUs, 4g memory and 1024 processes)
+	CPUs      string `toml:"cpus"`
+	Memory    string `toml:"memory"`
+	PidsLimit int    `toml:"pids_limit"`
+	// Host directories [repository.ai] volumes may mount from. Without any,
+	// volumes may mount anything but, in the hardened profile, the container
+	// runtime's socket or anything in your home directory.
+	AllowedVolumes []string `toml:"allowed_volumes"`
+}
+
+// Hardened reports whether the hardened profile applies
+func (s AISecurityConfig) Hardened() bool {
+	return s.Profile == AISecurityHardened
+}
+
+// Effective returns the settings with the profile's defaults filled in
+func (s AISecurityConfig) Effective() AISecurityConfig {
+	if !s.Hardened() {
+		return s
+	}
+	if s.ReadOnly == nil {
+		readOnly := true
+		s.ReadOnly = &readOnly
+	}
+	if s.Tmpfs == nil {
+		s.Tmpfs = DefaultAISandboxTmpfs
+	}
+	if s.CPUs == "" {
+		s.CPUs = DefaultAISandboxCPUs
+	}
+	if s.Memory == "" {
+		s.Memory = DefaultAISandboxMemory
+	}
+	if s.PidsLimit == 0 {
+		s.PidsLimit = DefaultAISandboxPidsLimit
+	}
+	return s
+}
+
+// Validate checks the security settings are well formed, and that the
+// hardened profile is not undone by them
+func (s AISecurityConfig) Validate() error {
+	switch s.Profile {
+	case "", AISecurityStandard, AISecurityHardened:
+	default:
+		return fmt.Errorf("unknown security profile %q: use %s or %s", s.Profile, AISecurityStandard, AISecurityHardened)
+	}
+
+	for _, path := range s.Tmpfs {
+		if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, ":,") {
+			return fmt.Errorf("tmpfs mount %q must be an absolute container path", path)
+		}
+	}
+	for _, capability := range s.CapAdd {
+		if !aiCapabilityPattern.MatchString(capability) {
+			return fmt.Errorf("invalid capability %q", capability)
+		}
+		if s.Hardened() && strings.TrimPrefix(strings.ToUpper(capability), "CAP_") == "SYS_ADMIN" {
+			return fmt.Errorf("the hardened profile cannot keep SYS_ADMIN")
+		}
+	}
+	if s.Seccomp != "" && s.Seccomp != "unconfined" && !filepath.IsLocal(s.Seccomp) {
+		return fmt.Errorf("seccomp profile %q must be relative to the repository", s.Seccomp)
+	}
+	if s.Hardened() && s.Seccomp == "unconfined" {
+		return fmt.Errorf("the hardened profile cannot run without seccomp")
+	}
+	if s.User != "" {
+		if !aiUserPattern.MatchString(s.User) {
+			return fmt.Errorf("invalid user %q: use uid:gid", s.User)
+		}
+		uid, _, _ := strings.Cut(s.User, ":")
+		if s.Hardened() && (uid == "0" || uid == "root") {
+			return fmt.Errorf("the hardened profile cannot run as root")
+		}
+	}
+	if s.CPUs != "" {
+		if cpus, err := strconv.ParseFloat(s.CPUs, 64); err != nil || cpus <= 0 {
+			return fmt.Errorf("invalid cpus %q", s.CPUs)
+		}
+	}
+	if s.Memory != "" && !aiMemoryPattern.MatchString(s.Memory) {
+		return fmt.Errorf("invalid memory %q: use a size such as 4g", s.Memory)
+	}
+	if s.PidsLimit < 0 {
+		return fmt.Errorf("invalid pids_limit %d", s.PidsLimit)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAISecurityEffective(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vibeman.toml"), []byte(`
+[repository]
+name = "app"
+
+[repository.ai.security]
+profile = "hardened"
+read_only = false
+cpus = "0.5"
+allowed_volumes = ["~/datasets"]
+`), 0644))
+
+	repoConfig, err := ParseRepositoryConfig(dir)
+	require.NoError(t, err)
+	require.NoError(t, repoConfig.Repository.AI.Validate())
+
+	// Settings given are kept, the others take the hardened defaults
+	security := repoConfig.Repository.AI.Security.Effective()
+	assert.False(t, *security.ReadOnly)
+	assert.Equal(t, "0.5", security.CPUs)
+	assert.Equal(t, DefaultAISandboxMemory, security.Memory)
+	assert.Equal(t, DefaultAISandboxPidsLimit, security.PidsLimit)
+	assert.Equal(t, DefaultAISandboxTmpfs, security.Tmpfs)
+	assert.Equal(t, []string{"~/datasets"}, security.AllowedVolumes)
+
+	// The standard profile has no defaults
+	assert.Equal(t, AISecurityConfig{Memory: "1g"}, AISecurityConfig{Memory: "1g"}.Effective())
+}
+
+func TestValidateAISecurity(t *testing.T) {
+	tests := []struct {
+		name     string
+		security AISecurityConfig
+		wantErr  string
+	}{
+		{"unknown profile", AISecurityConfig{Profile: "paranoid"}, `unknown security profile "paranoid"`},
+		{"relative tmpfs", AISecurityConfig{Tmpfs: []string{"tmp"}}, `tmpfs mount "tmp" must be an absolute container path`},
+		{"tmpfs options", AISecurityConfig{Tmpfs: []string{"/tmp:exec"}}, "must be an absolute container path"},
+		{"bad capability", AISecurityConfig{CapAdd: []string{"NET ADMIN"}}, `invalid capability "NET ADMIN"`},
+		{"hardened sys admin", AISecurityConfig{Profile: AISecurityHardened, CapAdd: []string{"CAP_SYS_ADMIN"}}, "cannot keep SYS_ADMIN"},
+		{"escaping seccomp", AISecurityConfig{Seccomp: "/etc/seccomp.json"}, "must be relative to the repository"},
+		{"hardened unconfined", AISecurityConfig{Profile: AISecurityHardened, Seccomp: "unconfined"}, "cannot run without seccomp"},
+		{"bad user", AISecurityConfig{User: "1000;id"}, `invalid user "1000;id"`},
+		{"hardened root", AISecurityConfig{Profile: AISecurityHardened, User: "0:0"}, "cannot run as root"},
+		{"bad cpus", AISecurityConfig{CPUs: "-1"}, `invalid cpus "-1"`},
+		{"bad memory", AISecurityConfig{Memory: "lots"}, `invalid memory "lots"`},
+		{"negative pids", AISecurityConfig{PidsLimit: -1}, "invalid pids_limit -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.security.Validate(), tt.wantErr)
+		})
+	}
+
+	assert.NoError(t, AISecurityConfig{Seccomp: "unconfined", User: "root"}.Validate())
+	assert.ErrorContains(t, (&AIConfig{Security: AISecurityConfig{Profile: "paranoid"}}).Validate(), "security: unknown security profile")
+}
@@ -2,11 +2,18 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
+	"vibeman/internal/constants"
 	"vibeman/internal/validation"
 )
 
@@ -20,10 +27,19 @@ type Manager struct {
 
 // AIConfig configures the AI assistant container
 type AIConfig struct {
-	Enabled bool              `toml:"enabled"` // Enable AI assistant container
-	Image   string            `toml:"image"`   // Container image for AI assistant (built from Dockerfile)
-	Env     map[string]string `toml:"env"`     // Additional environment variables
-	Volumes map[string]string `toml:"volumes"` // Additional volume mounts
+	Enabled bool                   `toml:"enabled"` // Enable AI assistant container
+	Image   string                 `toml:"image"`   // Container image for AI assistant (built from Dockerfile)
+	Env     map[string]string      `toml:"env"`     // Additional environment variables
+	Volumes map[string]string      `toml:"volumes"` // Additional volume mounts
+	Agent   string                 `toml:"agent"`   // Agent provider to run: claude (default), codex, aider, gemini or one of Agents
+	Agents  map[string]AgentConfig `toml:"agents"`  // Custom agent providers, or changes to built-in ones
+	Shell   string                 `toml:"shell"`   // Shell attaching opens: bash, sh, zsh (default), fish or dash
+	Test    string                 `toml:"test"`    // Command that runs the repository's tests, e.g. for agents through the MCP server
+	// Template agent instructions are generated from, relative to the
+	// repository; .vibeman/instructions.md.tmpl when unset
+	InstructionsTemplate string `toml:"instructions_template"`
+	// Security profile of the AI container
+	Security AISecurityConfig `toml:"security"`
 }
 
 // RepositoryConfig represents a repository configuration
@@ -49,8 +65,10 @@ type RepositoryConfig struct {
 			// Additional container configuration
 			Environment map[string]string `toml:"environment"` // Additional environment variables
 			AI          *AIConfig         `toml:"ai"`          // AI assistant configuration
+			// Optional: how to tell the container is ready for setup commands
+			Health *ProbeConfig `toml:"health"`
 		} `toml:"container"`
-		Services map[string]ServiceRequirement `toml:"-"` // Service requirements (custom unmarshal)
+		Services ServiceRequirements `toml:"-"` // Service requirements (custom unmarshal)
 		Runtime  struct {
 			Type string `toml:"type"` // "apple" or "docker"
 		} `toml:"runtime"`
@@ -106,6 +124,9 @@ func (s *ServicesConfig) UnmarshalTOML(data interface{}) error {
 						if err := remarshalServiceConfig(serviceMap, &sc); err != nil {
 							return fmt.Errorf("failed to parse service %s: %w", name, err)
 						}
+						if sc.IsInline() && sc.Service == "" {
+							sc.Service = InlineContainerName(name)
+						}
 						s.Services[name] = sc
 					}
 				}
@@ -129,20 +150,340 @@ func remarshalServiceConfig(data map[string]interface{}, sc *ServiceConfig) erro
 
 // ServiceConfig represents a service configuration
 type ServiceConfig struct {
-	// Docker Compose integration - the only supported approach
-	ComposeFile string `toml:"compose_file"`
-	Service     string `toml:"service"`
+	// Docker Compose integration: a service in an existing compose file
+	ComposeFile string `toml:"compose_file,omitempty"`
+	// The compose service, or the container name of an inline service
+	Service     string `toml:"service,omitempty"`
 	Description string `toml:"description,omitempty"`
+	// Inline definition: a container vibeman creates itself, without a
+	// compose file
+	Image   string            `toml:"image,omitempty"`
+	Ports   []string          `toml:"ports,omitempty"` // [IP:]HOST:CONTAINER[/PROTO], or CONTAINER for a free host port
+	Env     map[string]string `toml:"env,omitempty"`
+	Volumes []string          `toml:"volumes,omitempty"` // VOLUME:PATH or HOST_PATH:PATH
+	Command []string          `toml:"command,omitempty"` // Overrides the image's command
+	// Optional: give each worktree its own database, key space or bucket
+	Provision *ProvisionConfig `toml:"provision,omitempty"`
+	// Optional: how to tell the service is ready (default: compose healthcheck)
+	Health *ProbeConfig `toml:"health,omitempty"`
+	// Optional: services that must be ready before this one starts
+	DependsOn []string `toml:"depends_on,omitempty"`
+	// Optional: what the supervisor does when the container exits (default: nothing)
+	Restart *RestartPolicy `toml:"restart,omitempty"`
+	// Optional: versions that run side by side as <name>@<version>, each
+	// overriding some of the settings above
+	Versions map[string]ServiceConfig `toml:"versions,omitempty"`
 }
 
 // IsValid returns true if this service configuration is valid
 func (sc *ServiceConfig) IsValid() bool {
+	if sc.IsInline() {
+		return sc.ComposeFile == "" && sc.Service != ""
+	}
 	return sc.ComposeFile != "" && sc.Service != ""
 }
 
+// IsInline reports whether the service is defined in services.toml itself
+// rather than in a compose file
+func (sc *ServiceConfig) IsInline() bool {
+	return sc.Image != ""
+}
+
+// SplitPort splits a port of an inline service, [[IP:]HOST:]CONTAINER[/PROTO],
+// into its parts. The host port is empty when the port is published on a free
+// host port.
+func SplitPort(port string) (hostIP, hostPort, containerPort string) {
+	host, containerPort, published := cutLast(port, ":")
+	if !published {
+		return "", "", port
+	}
+	if ip, hostPort, ok := cutLast(host, ":"); ok {
+		return ip, hostPort, containerPort
+	}
+	return "", host, containerPort
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// InlineContainerName returns the default container name of the inline
+// service called name
+func InlineContainerName(name string) string {
+	return "vibeman-service-" + name
+}
+
+// Provisioning types
+const (
+	ProvisionPostgres = "postgres" // A database and role per worktree
+	ProvisionRedis    = "redis"    // A logical database or key prefix per worktree
+	ProvisionS3       = "s3"       // A bucket per worktree (LocalStack)
+)
+
+// Redis isolation modes
+const (
+	RedisModeDB     = "db"     // Logical database index
+	RedisModePrefix = "prefix" // Key prefix in database 0
+)
+
+// ProvisionConfig describes how to create an isolated resource for each
+// worktree on a shared service
+type ProvisionConfig struct {
+	Type      string `toml:"type"`                // postgres, redis or s3
+	Host      string `toml:"host,omitempty"`      // Host worktrees connect to (default localhost)
+	Port      int    `toml:"port,omitempty"`      // Port worktrees connect to (default per type)
+	User      string `toml:"user,omitempty"`      // Postgres admin user (default postgres)
+	Password  string `toml:"password,omitempty"`  // Postgres admin or Redis password
+	Mode      string `toml:"mode,omitempty"`      // Redis: db (default) or prefix
+	Databases int    `toml:"databases,omitempty"` // Redis: number of logical databases (default 16)
+	Region    string `toml:"region,omitempty"`    // S3: region (default us-east-1)
+	URLEnv    string `toml:"url_env,omitempty"`   // Also export the connection URL under this name, e.g. DATABASE_URL
+	Seed      string `toml:"seed,omitempty"`      // Postgres: snapshot (ID or tag) restored into each new database
+}
+
+// Validate checks the provisioning configuration
+func (p *ProvisionConfig) Validate() error {
+	switch p.Type {
+	case ProvisionPostgres, ProvisionS3:
+	case ProvisionRedis:
+		if p.Mode != "" && p.Mode != RedisModeDB && p.Mode != RedisModePrefix {
+			return fmt.Errorf("invalid redis provisioning mode %q: must be db or prefix", p.Mode)
+		}
+		if p.Databases < 0 || p.Databases == 1 {
+			return fmt.Errorf("redis provisioning needs at least 2 databases, got %d", p.Databases)
+		}
+	default:
+		return fmt.Errorf("invalid provisioning type %q: must be postgres, redis or s3", p.Type)
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid provisioning port %d", p.Port)
+	}
+	if p.Seed != "" && p.Type != ProvisionPostgres {
+		return fmt.Errorf("seeding from a snapshot is only supported for postgres provisioning")
+	}
+	return nil
+}
+
+// ProbeConfig describes a health probe. Exactly one of HTTP, TCP, Exec and
+// Log is set.
+type ProbeConfig struct {
+	HTTP     string   `toml:"http,omitempty"`     // URL that answers a GET with a 2xx or 3xx status
+	TCP      string   `toml:"tcp,omitempty"`      // host:port that accepts connections
+	Exec     []string `toml:"exec,omitempty"`     // Command run in the container that exits 0
+	Log      string   `toml:"log,omitempty"`      // Regular expression matched against the container's logs
+	Interval string   `toml:"interval,omitempty"` // Time between probes (default 2s)
+	Timeout  string   `toml:"timeout,omitempty"`  // Time a probe may take (default 5s)
+	Retries  int      `toml:"retries,omitempty"`  // Failed probes before giving up on readiness (default 30)
+}
+
+// Probe kinds
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeExec = "exec"
+	ProbeLog  = "log"
+)
+
+// Kind returns which kind of probe this is, or "" when none is set
+func (p *ProbeConfig) Kind() string {
+	switch {
+	case p == nil:
+		return ""
+	case p.HTTP != "":
+		return ProbeHTTP
+	case p.TCP != "":
+		return ProbeTCP
+	case len(p.Exec) > 0:
+		return ProbeExec
+	case p.Log != "":
+		return ProbeLog
+	}
+	return ""
+}
+
+// IntervalDuration returns the time between probes
+func (p *ProbeConfig) IntervalDuration() time.Duration {
+	if p != nil {
+		if d, err := time.ParseDuration(p.Interval); err == nil && d > 0 {
+			return d
+		}
+	}
+	return constants.DefaultProbeInterval
+}
+
+// TimeoutDuration returns the time a single probe may take
+func (p *ProbeConfig) TimeoutDuration() time.Duration {
+	if p != nil {
+		if d, err := time.ParseDuration(p.Timeout); err == nil && d > 0 {
+			return d
+		}
+	}
+	return constants.DefaultProbeTimeout
+}
+
+// RetryCount returns the number of failed probes before giving up
+func (p *ProbeConfig) RetryCount() int {
+	if p != nil && p.Retries > 0 {
+		return p.Retries
+	}
+	return constants.DefaultProbeRetries
+}
+
+// Validate checks the probe configuration
+func (p *ProbeConfig) Validate() error {
+	kinds := 0
+	for _, set := range []bool{p.HTTP != "", p.TCP != "", len(p.Exec) > 0, p.Log != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("health probe needs exactly one of http, tcp, exec or log")
+	}
+
+	if p.HTTP != "" && !strings.HasPrefix(p.HTTP, "http://") && !strings.HasPrefix(p.HTTP, "https://") {
+		return fmt.Errorf("invalid http probe %q: must be an http:// or https:// URL", p.HTTP)
+	}
+	if p.TCP != "" {
+		if _, _, err := net.SplitHostPort(p.TCP); err != nil {
+			return fmt.Errorf("invalid tcp probe %q: must be host:port", p.TCP)
+		}
+	}
+	if p.Log != "" {
+		if _, err := regexp.Compile(p.Log); err != nil {
+			return fmt.Errorf("invalid log probe %q: %w", p.Log, err)
+		}
+	}
+	for name, value := range map[string]string{"interval": p.Interval, "timeout": p.Timeout} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid health probe %s %q", name, value)
+		}
+	}
+	if p.Retries < 0 {
+		return fmt.Errorf("invalid health probe retries %d", p.Retries)
+	}
+	return nil
+}
+
+// Restart policies
+const (
+	RestartNo        = "no"         // Leave a crashed service down
+	RestartAlways    = "always"     // Restart whenever the container exits
+	RestartOnFailure = "on-failure" // Restart when it exits non-zero, up to MaxRetries times
+)
+
+// RestartPolicy describes how the supervisor restarts a service whose
+// container exited while it should be running
+type RestartPolicy struct {
+	Policy     string `toml:"policy"`                // no (default), always or on-failure
+	MaxRetries int    `toml:"max_retries,omitempty"` // Restarts before the service is crash-looping (default 5)
+	Backoff    string `toml:"backoff,omitempty"`     // Delay before the first restart, doubled each time (default 1s)
+	MaxBackoff string `toml:"max_backoff,omitempty"` // Longest delay between restarts (default 1m)
+}
+
+// ShouldRestart reports whether a container that exited with exitCode is
+// restarted at all
+func (r *RestartPolicy) ShouldRestart(exitCode int) bool {
+	if r == nil {
+		return false
+	}
+	switch r.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	}
+	return false
+}
+
+// RetryLimit returns the number of restarts in a row after which the
+// service is crash-looping. An on-failure service is then left down; an
+// always service keeps being restarted at the longest backoff.
+func (r *RestartPolicy) RetryLimit() int {
+	if r != nil && r.MaxRetries > 0 {
+		return r.MaxRetries
+	}
+	return constants.DefaultRestartMaxRetries
+}
+
+// BackoffDelay returns the delay before the given restart, counting from 1
+func (r *RestartPolicy) BackoffDelay(attempt int) time.Duration {
+	delay := constants.DefaultRestartBackoff
+	if r != nil {
+		if d, err := time.ParseDuration(r.Backoff); err == nil && d > 0 {
+			delay = d
+		}
+	}
+	limit := r.MaxBackoffDuration()
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// MaxBackoffDuration returns the longest delay between restarts. A service
+// that stays up this long is considered recovered.
+func (r *RestartPolicy) MaxBackoffDuration() time.Duration {
+	if r != nil {
+		if d, err := time.ParseDuration(r.MaxBackoff); err == nil && d > 0 {
+			return d
+		}
+	}
+	return constants.DefaultMaxRestartBackoff
+}
+
+// Validate checks the restart policy
+func (r *RestartPolicy) Validate() error {
+	switch r.Policy {
+	case "", RestartNo, RestartAlways, RestartOnFailure:
+	default:
+		return fmt.Errorf("invalid restart policy %q: must be no, always or on-failure", r.Policy)
+	}
+	if r.MaxRetries < 0 {
+		return fmt.Errorf("invalid restart max_retries %d", r.MaxRetries)
+	}
+	for name, value := range map[string]string{"backoff": r.Backoff, "max_backoff": r.MaxBackoff} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid restart %s %q", name, value)
+		}
+	}
+	return nil
+}
+
 // ServiceRequirement represents a service requirement in repository config
 type ServiceRequirement struct {
-	Required bool `toml:"required"`
+	Required bool   `toml:"required"`
+	Version  string `toml:"version,omitempty"` // One of the service's versions (default: the service itself)
+}
+
+// ServiceRequirements are the services a repository uses, keyed by service
+type ServiceRequirements map[string]ServiceRequirement
+
+// Names returns the names the required services run under, sorted
+func (r ServiceRequirements) Names() []string {
+	names := make([]string, 0, len(r))
+	for name, requirement := range r {
+		names = append(names, VersionedName(name, requirement.Version))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Uses reports whether the repository uses the service running under name
+func (r ServiceRequirements) Uses(name string) bool {
+	base, version := SplitVersionedName(name)
+	requirement, exists := r[base]
+	return exists && requirement.Version == version
 }
 
 // New creates a new configuration manager
@@ -255,9 +596,10 @@ func (m *Manager) loadRepositoryConfig() error {
 				Directory string `toml:"directory"`
 			} `toml:"worktrees"`
 			Container struct {
-				ComposeFile string   `toml:"compose_file"`
-				Services    []string `toml:"services"`
-				Setup       []string `toml:"setup"`
+				ComposeFile string       `toml:"compose_file"`
+				Services    []string     `toml:"services"`
+				Setup       []string     `toml:"setup"`
+				Health      *ProbeConfig `toml:"health"`
 			} `toml:"container"`
 			Runtime struct {
 				Type string `toml:"type"`
@@ -282,6 +624,7 @@ func (m *Manager) loadRepositoryConfig() error {
 	m.Repository.Repository.Container.ComposeFile = tempConfig.Repository.Container.ComposeFile
 	m.Repository.Repository.Container.Services = tempConfig.Repository.Container.Services
 	m.Repository.Repository.Container.Setup = tempConfig.Repository.Container.Setup
+	m.Repository.Repository.Container.Health = tempConfig.Repository.Container.Health
 	m.Repository.Repository.Runtime = tempConfig.Repository.Runtime
 	m.Repository.Repository.Setup = tempConfig.Repository.Setup
 	m.Repository.Repository.AI = tempConfig.Repository.AI
@@ -337,7 +680,7 @@ func (m *Manager) loadRepositoryConfig() error {
 	}
 
 	// Handle services - support both string and object formats
-	m.Repository.Repository.Services = make(map[string]ServiceRequirement)
+	m.Repository.Repository.Services = make(ServiceRequirements)
 	if services, ok := configSection["services"].(map[string]interface{}); ok {
 		for name, service := range services {
 			// Check if it's a string (simple format)
@@ -350,6 +693,9 @@ func (m *Manager) loadRepositoryConfig() error {
 				if required, ok := serviceMap["required"].(bool); ok {
 					sr.Required = required
 				}
+				if version, ok := serviceMap["version"].(string); ok {
+					sr.Version = version
+				}
 				m.Repository.Repository.Services[name] = sr
 			}
 		}
@@ -360,15 +706,33 @@ func (m *Manager) loadRepositoryConfig() error {
 	return nil
 }
 
+// ServicesPath returns the path of services.toml in the vibeman config
+// directory
+func ServicesPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "services.toml"), nil
+}
+
+// SaveServices writes the services configuration back to services.toml.
+// Comments in the file are not preserved.
+func (m *Manager) SaveServices() error {
+	configPath, err := ServicesPath()
+	if err != nil {
+		return err
+	}
+	return m.Services.Save(configPath)
+}
+
 // loadServicesConfig loads services configuration
 func (m *Manager) loadServicesConfig() error {
 	// Use XDG config directory
-	configDir, err := getConfigDir()
+	configPath, err := ServicesPath()
 	if err != nil {
-		return fmt.Errorf("failed to get config directory: %w", err)
+		return err
 	}
-
-	configPath := filepath.Join(configDir, "services.toml")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Create default services config if it doesn't exist
 		if err := m.createDefaultServicesConfig(configPath); err != nil {
@@ -400,7 +764,7 @@ func (m *Manager) loadServicesConfig() error {
 func (m *Manager) createDefaultServicesConfig(configPath string) error {
 	// Create default services config content
 	defaultContent := `# Vibeman Services Configuration
-# Services are defined by referencing docker-compose files
+# Services reference docker-compose files or define a container inline
 
 [services]
 
@@ -409,18 +773,63 @@ func (m *Manager) createDefaultServicesConfig(configPath string) error {
 # compose_file = "/path/to/docker-compose.yaml"
 # service = "postgres"
 # description = "PostgreSQL database for development"
+#
+# Give each worktree its own database and role
+# [services.postgres.provision]
+# type = "postgres"
+# port = 5432
+# url_env = "DATABASE_URL"
+# seed = "baseline"  # Snapshot restored into each new database (vibeman service snapshot postgres --tag baseline)
+#
+# Wait until it accepts connections before worktrees use it
+# [services.postgres.health]
+# exec = ["pg_isready", "-U", "postgres"]
+# interval = "2s"
+# retries = 30
+#
+# Restart it when it crashes (policy: no, always or on-failure)
+# [services.postgres.restart]
+# policy = "on-failure"
+# max_retries = 5
+# backoff = "1s"
+# max_backoff = "1m"
 
 # Example: Redis service from a docker-compose file
 # [services.redis]
 # compose_file = "/path/to/docker-compose.yaml"
 # service = "redis"
 # description = "Redis cache server"
+# depends_on = []  # Services started (and ready) before this one
+#
+# Give each worktree its own logical database (or mode = "prefix")
+# [services.redis.provision]
+# type = "redis"
+
+# Example: Redis defined here instead of in a compose file; vibeman creates
+# the container (named vibeman-service-<name> unless service is set)
+# [services.cache]
+# image = "redis:7-alpine"
+# ports = ["6380:6379"]
+# volumes = ["vibeman-cache-data:/data"]
+# command = ["redis-server", "--appendonly", "yes"]
+# env = { TZ = "UTC" }
+# [services.cache.health]
+# exec = ["redis-cli", "ping"]
+#
+# Run Redis 6 alongside as cache@6 for repositories that ask for
+# cache = { version = "6" }; unset ports are published on free host ports
+# [services.cache.versions.6]
+# image = "redis:6-alpine"
 
 # Example: LocalStack service
 # [services.localstack]
 # compose_file = "/path/to/docker-compose.yaml"
 # service = "localstack"
 # description = "AWS services emulation"
+#
+# Give each worktree its own S3 bucket
+# [services.localstack.provision]
+# type = "s3"
 `
 
 	// Create directory if it doesn't exist
@@ -463,8 +872,8 @@ func (m *Manager) Validate() error {
 	if m.Repository != nil && m.Repository.Repository.Name != "" {
 		// Validate services map
 		if m.Services != nil {
-			for serviceName := range m.Repository.Repository.Services {
-				if _, exists := m.Services.Services[serviceName]; !exists {
+			for _, serviceName := range m.Repository.Repository.Services.Names() {
+				if _, exists := m.Services.Lookup(serviceName); !exists {
 					return fmt.Errorf("service %q not found in services configuration", serviceName)
 				}
 			}
@@ -489,6 +898,11 @@ func (m *Manager) Validate() error {
 		if err := m.validateRuntime(); err != nil {
 			return fmt.Errorf("runtime configuration validation failed: %w", err)
 		}
+
+		// Validate AI agent configuration
+		if err := m.Repository.Repository.AI.Validate(); err != nil {
+			return fmt.Errorf("ai configuration validation failed: %w", err)
+		}
 	}
 
 	return nil
@@ -538,6 +952,12 @@ func (m *Manager) validateSetup() error {
 		}
 	}
 
+	if container.Health != nil {
+		if err := container.Health.Validate(); err != nil {
+			return err
+		}
+	}
+
 	// Note: SetupScript and Lifecycle are removed in simplified approach
 	// Setup is now just an array of commands to run after container starts
 
@@ -591,6 +1011,14 @@ setup = [
     "echo 'Container setup complete'"
 ]
 
+# Optional: Wait for the container to be ready before running setup
+# (default: the compose healthcheck, if any). One of http, tcp, exec or log.
+# [repository.container.health]
+# http = "http://localhost:3000/health"
+# interval = "2s"
+# timeout = "5s"
+# retries = 30
+
 # Service dependencies (defined in services.toml or docker-compose)
 [repository.services]
 postgres = { required = true }
@@ -660,9 +1088,10 @@ func ParseRepositoryConfigData(data []byte, cfg *RepositoryConfig) error {
 				Directory string `toml:"directory"`
 			} `toml:"worktrees"`
 			Container struct {
-				ComposeFile string   `toml:"compose_file"`
-				Services    []string `toml:"services"`
-				Setup       []string `toml:"setup"`
+				ComposeFile string       `toml:"compose_file"`
+				Services    []string     `toml:"services"`
+				Setup       []string     `toml:"setup"`
+				Health      *ProbeConfig `toml:"health"`
 			} `toml:"container"`
 			Runtime struct {
 				Type string `toml:"type"`
@@ -687,6 +1116,7 @@ func ParseRepositoryConfigData(data []byte, cfg *RepositoryConfig) error {
 	cfg.Repository.Container.ComposeFile = tempConfig.Repository.Container.ComposeFile
 	cfg.Repository.Container.Services = tempConfig.Repository.Container.Services
 	cfg.Repository.Container.Setup = tempConfig.Repository.Container.Setup
+	cfg.Repository.Container.Health = tempConfig.Repository.Container.Health
 	cfg.Repository.Runtime = tempConfig.Repository.Runtime
 	cfg.Repository.Setup = tempConfig.Repository.Setup
 	cfg.Repository.AI = tempConfig.Repository.AI
@@ -742,7 +1172,7 @@ func ParseRepositoryConfigData(data []byte, cfg *RepositoryConfig) error {
 	}
 
 	// Handle services - support both string and object formats
-	cfg.Repository.Services = make(map[string]ServiceRequirement)
+	cfg.Repository.Services = make(ServiceRequirements)
 	if services, ok := configSection["services"].(map[string]interface{}); ok {
 		for name, service := range services {
 			// Check if it's a string (simple format)
@@ -755,6 +1185,9 @@ func ParseRepositoryConfigData(data []byte, cfg *RepositoryConfig) error {
 				if required, ok := serviceMap["required"].(bool); ok {
 					sr.Required = required
 				}
+				if version, ok := serviceMap["version"].(string); ok {
+					sr.Version = version
+				}
 				cfg.Repository.Services[name] = sr
 			}
 		}
@@ -809,31 +1242,142 @@ func LoadServicesConfig(path string) (*ServicesConfig, error) {
 	return config, nil
 }
 
-// ValidateServiceConfig validates a service configuration
-func ValidateServiceConfig(service *ServiceConfig) error {
+// ValidateServiceConfig validates the configuration of the service called
+// name and of its versions. Its depends_on must name services among the
+// other configured services and must not lead back to it.
+func ValidateServiceConfig(name string, service *ServiceConfig, services map[string]ServiceConfig) error {
 	if service == nil {
 		return fmt.Errorf("service config cannot be nil")
 	}
 
-	if service.Service == "" {
-		return fmt.Errorf("service name cannot be empty")
+	if err := validateServiceDefinition(service); err != nil {
+		return err
+	}
+	if err := validateVersions(name, service); err != nil {
+		return err
+	}
+
+	all := maps.Clone(services)
+	if all == nil {
+		all = map[string]ServiceConfig{}
+	}
+	all[name] = *service
+	if _, err := NewServiceGraph(all); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateServiceDefinition checks how a service is run, provisioned,
+// probed and restarted
+func validateServiceDefinition(service *ServiceConfig) error {
+	if service.IsInline() {
+		if err := validateInlineService(service); err != nil {
+			return err
+		}
+	} else {
+		if service.Service == "" {
+			return fmt.Errorf("service name cannot be empty")
+		}
+
+		if service.ComposeFile == "" {
+			return fmt.Errorf("compose file cannot be empty")
+		}
+
+		// Check if compose file exists
+		if _, err := os.Stat(service.ComposeFile); os.IsNotExist(err) {
+			return fmt.Errorf("compose file not found: %s", service.ComposeFile)
+		}
+
+		// TODO: Validate that the service exists in the compose file
+		// This would require parsing the docker-compose.yaml file
+
+		if len(service.Ports) > 0 || len(service.Env) > 0 || len(service.Volumes) > 0 || len(service.Command) > 0 {
+			return fmt.Errorf("ports, env, volumes and command are only supported for inline services; set them in the compose file")
+		}
 	}
 
-	if service.ComposeFile == "" {
-		return fmt.Errorf("compose file cannot be empty")
+	if service.Provision != nil {
+		if err := service.Provision.Validate(); err != nil {
+			return err
+		}
 	}
 
-	// Check if compose file exists
-	if _, err := os.Stat(service.ComposeFile); os.IsNotExist(err) {
-		return fmt.Errorf("compose file not found: %s", service.ComposeFile)
+	if service.Health != nil {
+		if err := service.Health.Validate(); err != nil {
+			return err
+		}
 	}
 
-	// TODO: Validate that the service exists in the compose file
-	// This would require parsing the docker-compose.yaml file
+	if service.Restart != nil {
+		if err := service.Restart.Validate(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// validateInlinePort checks a port of an inline service
+func validateInlinePort(port string) error {
+	hostIP, hostPort, containerPort := SplitPort(port)
+	containerPort, protocol, _ := strings.Cut(containerPort, "/")
+	if protocol != "" && protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+		return fmt.Errorf("unknown protocol %q", protocol)
+	}
+	ports := []string{containerPort}
+	if hostPort != "" {
+		ports = append(ports, hostPort)
+	}
+	for _, p := range ports {
+		if number, err := strconv.Atoi(p); err != nil || validation.PortNumber(number) != nil {
+			return fmt.Errorf("must be [IP:]HOST:CONTAINER[/PROTO] or CONTAINER")
+		}
+	}
+	if hostIP != "" && net.ParseIP(strings.Trim(hostIP, "[]")) == nil {
+		return fmt.Errorf("invalid host IP %q", hostIP)
+	}
+	return nil
+}
+
+// validateInlineService checks the container definition of an inline service
+func validateInlineService(service *ServiceConfig) error {
+	if service.ComposeFile != "" {
+		return fmt.Errorf("a service has either an image or a compose file, not both")
+	}
+	for _, port := range service.Ports {
+		if err := validateInlinePort(port); err != nil {
+			return fmt.Errorf("invalid port %q: %w", port, err)
+		}
+	}
+	for name, value := range service.Env {
+		if err := validation.EnvironmentVariable(name + "=" + value); err != nil {
+			return fmt.Errorf("invalid env %s: %w", name, err)
+		}
+	}
+	for _, volume := range service.Volumes {
+		source, target, ok := strings.Cut(volume, ":")
+		if !ok || source == "" || !strings.HasPrefix(target, "/") {
+			return fmt.Errorf("invalid volume %q: must be in VOLUME:PATH or HOST_PATH:PATH format", volume)
+		}
+	}
+	return nil
+}
+
+// namedVolumePattern matches the names docker gives volumes
+var namedVolumePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// VolumeHostPath returns the host path a service volume mounts, or "" when it
+// mounts a named volume
+func VolumeHostPath(volume string) string {
+	source, _, _ := strings.Cut(volume, ":")
+	if namedVolumePattern.MatchString(source) {
+		return ""
+	}
+	return source
+}
+
 // SaveRepositoryConfig saves a repository configuration to vibeman.toml
 func SaveRepositoryConfig(path string, config *RepositoryConfig) error {
 	if config == nil {
@@ -266,4 +266,29 @@ func TestMissingConfigFile(t *testing.T) {
 	_, err := ParseRepositoryConfig(tmpDir)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no such file or directory")
-}
\ No newline at end of file
+}
+func TestParseContainerHealthProbe(t *testing.T) {
+	content := `
+[repository]
+name = "web"
+
+[repository.container]
+compose_file = "./docker-compose.yaml"
+setup = ["npm install"]
+
+[repository.container.health]
+tcp = "localhost:3000"
+retries = 5
+`
+
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "vibeman.toml"), []byte(content), 0644))
+
+	config, err := ParseRepositoryConfig(tmpDir)
+	require.NoError(t, err)
+
+	probe := config.Repository.Container.Health
+	require.NotNil(t, probe)
+	assert.Equal(t, ProbeTCP, probe.Kind())
+	assert.Equal(t, 5, probe.RetryCount())
+}
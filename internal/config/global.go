@@ -2,9 +2,14 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"vibeman/internal/constants"
 	"vibeman/internal/xdg"
@@ -14,14 +19,26 @@ import (
 
 // GlobalConfig represents the global vibeman configuration
 type GlobalConfig struct {
-	Server   ServerConfig        `toml:"server"`
-	Storage  StorageConfig       `toml:"storage"`
-	Services GlobalServicesConfig `toml:"services"`
+	Server    ServerConfig         `toml:"server"`
+	Storage   StorageConfig        `toml:"storage"`
+	Services  GlobalServicesConfig `toml:"services"`
+	Telemetry TelemetryConfig      `toml:"telemetry"`
+	Audit     AuditConfig          `toml:"audit"`
+	AI        GlobalAIConfig       `toml:"ai"`
 }
 
 type ServerConfig struct {
-	Port      int `toml:"port"`       // Server port (default 8080)
-	WebUIPort int `toml:"webui_port"` // Web UI port (default 8081)
+	Port      int        `toml:"port"`       // Server port (default 8080)
+	WebUIPort int        `toml:"webui_port"` // Web UI port (default 8081)
+	Auth      AuthConfig `toml:"auth"`
+}
+
+// AuthConfig configures server authentication
+type AuthConfig struct {
+	Enabled    bool   `toml:"enabled"`     // Require a session or API token on /api/*
+	JWTSecret  string `toml:"jwt_secret"`  // Secret used to sign session tokens (default: generated into the config dir)
+	SessionTTL string `toml:"session_ttl"` // Lifetime of a session token (default "15m")
+	RefreshTTL string `toml:"refresh_ttl"` // How long a login can be refreshed (default "168h")
 }
 
 type StorageConfig struct {
@@ -31,6 +48,82 @@ type StorageConfig struct {
 
 type GlobalServicesConfig struct {
 	ConfigPath string `toml:"config_path"` // Location of services.toml
+	IdleGrace  string `toml:"idle_grace"`  // How long an unused service keeps running (default "15m", "0" never stops it)
+}
+
+// IdleGraceDuration returns the parsed idle grace period
+func (s GlobalServicesConfig) IdleGraceDuration() time.Duration {
+	d, err := time.ParseDuration(s.IdleGrace)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// GlobalAIConfig configures headless AI jobs on this host
+type GlobalAIConfig struct {
+	MaxJobs         int            `toml:"max_jobs"`         // How many AI jobs run at once (default 2)
+	JobTimeout      string         `toml:"job_timeout"`      // How long an AI job may run before it is cancelled (default "1h", "0" never)
+	SecurityProfile string         `toml:"security_profile"` // Security profile every AI container gets at least: standard (default) or hardened
+	Notify          AINotifyConfig `toml:"notify"`
+	// Host credentials and config files agent providers get, replacing the
+	// built-in ones; repositories cannot set these
+	Agents map[string]AgentAccess `toml:"agents"`
+}
+
+// JobTimeoutDuration returns the parsed AI job timeout
+func (a GlobalAIConfig) JobTimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(a.JobTimeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// AINotifyConfig configures how agents in AI terminal sessions are watched
+// and who is told when one waits for input or finishes
+type AINotifyConfig struct {
+	Quiet   string   `toml:"quiet"`   // How long an agent's terminal must be still before it counts as waiting (default "2m", "0" stops watching)
+	Prompts []string `toml:"prompts"` // Regular expressions, besides the built-in ones, that mark an agent asking a question
+	Webhook string   `toml:"webhook"` // URL agent events are POSTed to as JSON
+	// Include the last lines of the agent's terminal in webhook events. They
+	// can hold secrets or source code, so they are left out by default.
+	WebhookScreen bool `toml:"webhook_screen"`
+	Command string   `toml:"command"` // Shell command run for each agent event, e.g. to show a desktop notification
+	Bell    bool     `toml:"bell"`    // Ring the bell in terminals attached to the agent's session
+}
+
+// QuietDuration returns the parsed quiet period
+func (n AINotifyConfig) QuietDuration() time.Duration {
+	d, err := time.ParseDuration(n.Quiet)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// TelemetryConfig configures OpenTelemetry tracing
+type TelemetryConfig struct {
+	Enabled     bool              `toml:"enabled"`      // Enable trace export
+	Exporter    string            `toml:"exporter"`     // "otlp" or "file" (default "otlp")
+	Endpoint    string            `toml:"endpoint"`     // OTLP/HTTP endpoint URL (default from OTEL_EXPORTER_OTLP_ENDPOINT)
+	Headers     map[string]string `toml:"headers"`      // Extra headers sent to the OTLP endpoint
+	FilePath    string            `toml:"file_path"`    // Output file for the file exporter (default logs dir/traces.jsonl)
+	SampleRatio float64           `toml:"sample_ratio"` // Fraction of traces to sample, 0 means 1.0
+}
+
+// AuditConfig configures the audit log
+type AuditConfig struct {
+	Retention string `toml:"retention"` // How long audit events are kept (default "2160h", "0" keeps them forever)
+}
+
+// RetentionDuration returns the parsed retention period
+func (a AuditConfig) RetentionDuration() time.Duration {
+	d, err := time.ParseDuration(a.Retention)
+	if err != nil {
+		return 0
+	}
+	return d
 }
 
 // DefaultGlobalConfig returns the default global configuration
@@ -39,6 +132,10 @@ func DefaultGlobalConfig() *GlobalConfig {
 		Server: ServerConfig{
 			Port:      constants.DefaultServerPort,
 			WebUIPort: constants.DefaultWebUIPort,
+			Auth: AuthConfig{
+				SessionTTL: "15m",
+				RefreshTTL: "168h",
+			},
 		},
 		Storage: StorageConfig{
 			RepositoriesPath: "~/vibeman/repos",
@@ -46,6 +143,21 @@ func DefaultGlobalConfig() *GlobalConfig {
 		},
 		Services: GlobalServicesConfig{
 			ConfigPath: "", // Will use XDG default
+			IdleGrace:  "15m",
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:  false,
+			Exporter: "otlp",
+		},
+		Audit: AuditConfig{
+			Retention: "2160h",
+		},
+		AI: GlobalAIConfig{
+			MaxJobs:    2,
+			JobTimeout: "1h",
+			Notify: AINotifyConfig{
+				Quiet: "2m",
+			},
 		},
 	}
 }
@@ -111,6 +223,30 @@ func LoadGlobalConfig() (*GlobalConfig, error) {
 		// Default to XDG config dir + services.toml
 		config.Services.ConfigPath = filepath.Join(configDir, "services.toml")
 	}
+	if config.Telemetry.Exporter == "" {
+		config.Telemetry.Exporter = defaults.Telemetry.Exporter
+	}
+	if config.Server.Auth.SessionTTL == "" {
+		config.Server.Auth.SessionTTL = defaults.Server.Auth.SessionTTL
+	}
+	if config.Server.Auth.RefreshTTL == "" {
+		config.Server.Auth.RefreshTTL = defaults.Server.Auth.RefreshTTL
+	}
+	if config.Audit.Retention == "" {
+		config.Audit.Retention = defaults.Audit.Retention
+	}
+	if config.Services.IdleGrace == "" {
+		config.Services.IdleGrace = defaults.Services.IdleGrace
+	}
+	if config.AI.MaxJobs == 0 {
+		config.AI.MaxJobs = defaults.AI.MaxJobs
+	}
+	if config.AI.JobTimeout == "" {
+		config.AI.JobTimeout = defaults.AI.JobTimeout
+	}
+	if config.AI.Notify.Quiet == "" {
+		config.AI.Notify.Quiet = defaults.AI.Notify.Quiet
+	}
 
 	// Expand tilde paths
 	if err := expandPaths(&config); err != nil {
@@ -178,6 +314,78 @@ func ValidateGlobalConfig(config *GlobalConfig) error {
 		return fmt.Errorf("worktrees path cannot be empty")
 	}
 
+	// Validate telemetry settings
+	switch config.Telemetry.Exporter {
+	case "", "otlp", "file":
+	default:
+		return fmt.Errorf("invalid telemetry exporter: %s", config.Telemetry.Exporter)
+	}
+	if config.Telemetry.SampleRatio < 0 || config.Telemetry.SampleRatio > 1 {
+		return fmt.Errorf("invalid telemetry sample ratio: %v", config.Telemetry.SampleRatio)
+	}
+
+	// Validate auth settings
+	for name, value := range map[string]string{
+		"session_ttl": config.Server.Auth.SessionTTL,
+		"refresh_ttl": config.Server.Auth.RefreshTTL,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid auth %s: %q", name, value)
+		}
+	}
+
+	// Validate audit settings
+	if config.Audit.Retention != "" {
+		if d, err := time.ParseDuration(config.Audit.Retention); err != nil || d < 0 {
+			return fmt.Errorf("invalid audit retention: %q", config.Audit.Retention)
+		}
+	}
+
+	// Validate service settings
+	if config.Services.IdleGrace != "" {
+		if d, err := time.ParseDuration(config.Services.IdleGrace); err != nil || d < 0 {
+			return fmt.Errorf("invalid services idle grace: %q", config.Services.IdleGrace)
+		}
+	}
+
+	// Validate AI job settings
+	if config.AI.MaxJobs < 0 {
+		return fmt.Errorf("invalid AI max jobs: %d", config.AI.MaxJobs)
+	}
+	if config.AI.JobTimeout != "" {
+		if d, err := time.ParseDuration(config.AI.JobTimeout); err != nil || d < 0 {
+			return fmt.Errorf("invalid AI job timeout: %q", config.AI.JobTimeout)
+		}
+	}
+	switch config.AI.SecurityProfile {
+	case "", AISecurityStandard, AISecurityHardened:
+	default:
+		return fmt.Errorf("invalid AI security profile: %q", config.AI.SecurityProfile)
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.AI.Agents)) {
+		if err := config.AI.Agents[name].Validate(); err != nil {
+			return fmt.Errorf("invalid AI agent %s: %w", name, err)
+		}
+	}
+	if config.AI.Notify.Quiet != "" {
+		if d, err := time.ParseDuration(config.AI.Notify.Quiet); err != nil || d < 0 {
+			return fmt.Errorf("invalid AI notify quiet period: %q", config.AI.Notify.Quiet)
+		}
+	}
+	for _, prompt := range config.AI.Notify.Prompts {
+		if _, err := regexp.Compile(prompt); err != nil {
+			return fmt.Errorf("invalid AI notify prompt %q: %w", prompt, err)
+		}
+	}
+	if config.AI.Notify.Webhook != "" {
+		if u, err := url.Parse(config.AI.Notify.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid AI notify webhook: %q", config.AI.Notify.Webhook)
+		}
+	}
+
 	return nil
 }
 
@@ -202,6 +410,11 @@ func expandPaths(config *GlobalConfig) error {
 	if strings.HasPrefix(config.Services.ConfigPath, "~/") {
 		config.Services.ConfigPath = filepath.Join(homeDir, config.Services.ConfigPath[2:])
 	}
+
+	// Expand telemetry file path if needed
+	if strings.HasPrefix(config.Telemetry.FilePath, "~/") {
+		config.Telemetry.FilePath = filepath.Join(homeDir, config.Telemetry.FilePath[2:])
+	}
 	
 	return nil
 }
@@ -178,6 +178,115 @@ func TestConfigFileValidationWithInvalidValues(t *testing.T) {
 			shouldError: true,
 			errorMsg:    "repositories path cannot be empty",
 		},
+		{
+			name: "unknown telemetry exporter",
+			config: &GlobalConfig{
+				Server: ServerConfig{
+					Port:      8080,
+					WebUIPort: 8081,
+				},
+				Storage: StorageConfig{
+					RepositoriesPath: "/tmp/repos",
+					WorktreesPath:    "/tmp/worktrees",
+				},
+				Telemetry: TelemetryConfig{
+					Enabled:  true,
+					Exporter: "zipkin",
+				},
+			},
+			shouldError: true,
+			errorMsg:    "invalid telemetry exporter",
+		},
+		{
+			name: "invalid services idle grace",
+			config: &GlobalConfig{
+				Server: ServerConfig{
+					Port:      8080,
+					WebUIPort: 8081,
+				},
+				Storage: StorageConfig{
+					RepositoriesPath: "/tmp/repos",
+					WorktreesPath:    "/tmp/worktrees",
+				},
+				Services: GlobalServicesConfig{
+					IdleGrace: "soon",
+				},
+			},
+			shouldError: true,
+			errorMsg:    "invalid services idle grace",
+		},
+		{
+			name: "invalid AI notify prompt",
+			config: &GlobalConfig{
+				Server: ServerConfig{
+					Port:      8080,
+					WebUIPort: 8081,
+				},
+				Storage: StorageConfig{
+					RepositoriesPath: "/tmp/repos",
+					WorktreesPath:    "/tmp/worktrees",
+				},
+				AI: GlobalAIConfig{
+					Notify: AINotifyConfig{Prompts: []string{"(unclosed"}},
+				},
+			},
+			shouldError: true,
+			errorMsg:    "invalid AI notify prompt",
+		},
+		{
+			name: "invalid AI notify webhook",
+			config: &GlobalConfig{
+				Server: ServerConfig{
+					Port:      8080,
+					WebUIPort: 8081,
+				},
+				Storage: StorageConfig{
+					RepositoriesPath: "/tmp/repos",
+					WorktreesPath:    "/tmp/worktrees",
+				},
+				AI: GlobalAIConfig{
+					Notify: AINotifyConfig{Webhook: "hooks.example.com/agent"},
+				},
+			},
+			shouldError: true,
+			errorMsg:    "invalid AI notify webhook",
+		},
+		{
+			name: "invalid AI security profile",
+			config: &GlobalConfig{
+				Server: ServerConfig{
+					Port:      8080,
+					WebUIPort: 8081,
+				},
+				Storage: StorageConfig{
+					RepositoriesPath: "/tmp/repos",
+					WorktreesPath:    "/tmp/worktrees",
+				},
+				AI: GlobalAIConfig{
+					SecurityProfile: "paranoid",
+				},
+			},
+			shouldError: true,
+			errorMsg:    "invalid AI security profile",
+		},
+		{
+			name: "invalid AI agent access",
+			config: &GlobalConfig{
+				Server: ServerConfig{
+					Port:      8080,
+					WebUIPort: 8081,
+				},
+				Storage: StorageConfig{
+					RepositoriesPath: "/tmp/repos",
+					WorktreesPath:    "/tmp/worktrees",
+				},
+				AI: GlobalAIConfig{
+					Agents: map[string]AgentAccess{"claude": {Config: []string{"/etc"}}},
+				},
+			},
+			shouldError: true,
+			errorMsg:    "invalid AI agent claude",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ServiceGraph is the dependency graph of the services in services.toml,
+// built from their depends_on lists. It has no cycles.
+type ServiceGraph struct {
+	dependencies map[string][]string // Direct dependencies, sorted
+	dependents   map[string][]string // Direct dependents, sorted
+}
+
+// NewServiceGraph builds the dependency graph of services and their
+// versions. It fails when a service depends on itself, on a service that is
+// not configured, or on a service that depends back on it.
+func NewServiceGraph(services map[string]ServiceConfig) (*ServiceGraph, error) {
+	services = ExpandVersions(services)
+	g := &ServiceGraph{
+		dependencies: make(map[string][]string, len(services)),
+		dependents:   make(map[string][]string, len(services)),
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(services)) {
+		dependencies := slices.Compact(slices.Sorted(slices.Values(services[name].DependsOn)))
+		for _, dependency := range dependencies {
+			if dependency == name {
+				return nil, fmt.Errorf("service %s depends on itself", name)
+			}
+			if _, ok := services[dependency]; !ok {
+				return nil, fmt.Errorf("service %s depends on unknown service %s", name, dependency)
+			}
+			g.dependents[dependency] = append(g.dependents[dependency], name)
+		}
+		g.dependencies[name] = dependencies
+	}
+
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("service dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return g, nil
+}
+
+// Services returns the names of all services in the graph, sorted
+func (g *ServiceGraph) Services() []string {
+	return slices.Sorted(maps.Keys(g.dependencies))
+}
+
+// Dependencies returns the services a service depends on directly
+func (g *ServiceGraph) Dependencies(name string) []string {
+	return g.dependencies[name]
+}
+
+// Dependents returns the services that depend on a service directly
+func (g *ServiceGraph) Dependents(name string) []string {
+	return g.dependents[name]
+}
+
+// StartLayers returns the named services (all when none are named) and
+// everything they depend on, in the order they must start: each layer
+// depends only on earlier layers, so the services in a layer can start in
+// parallel.
+func (g *ServiceGraph) StartLayers(names ...string) [][]string {
+	return layers(g.closure(names, g.Dependencies), g.Dependencies)
+}
+
+// StopLayers returns the named services (all when none are named) and
+// everything that depends on them, in the order they must stop: dependents
+// come before the services they depend on.
+func (g *ServiceGraph) StopLayers(names ...string) [][]string {
+	return layers(g.closure(names, g.Dependents), g.Dependents)
+}
+
+// Text renders the graph as one line per service in start order, followed
+// by the services it depends on
+func (g *ServiceGraph) Text() string {
+	var b strings.Builder
+	for _, layer := range g.StartLayers() {
+		for _, name := range layer {
+			b.WriteString(name)
+			if dependencies := g.dependencies[name]; len(dependencies) > 0 {
+				b.WriteString(" -> " + strings.Join(dependencies, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// DOT renders the graph in Graphviz DOT format, with edges pointing from a
+// service to the services it depends on
+func (g *ServiceGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph services {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, name := range g.Services() {
+		fmt.Fprintf(&b, "  %q;\n", name)
+	}
+	for _, name := range g.Services() {
+		for _, dependency := range g.dependencies[name] {
+			fmt.Fprintf(&b, "  %q -> %q;\n", name, dependency)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// closure returns the named services and everything reachable from them
+// through next, or every service when none are named
+func (g *ServiceGraph) closure(names []string, next func(string) []string) map[string]bool {
+	if len(names) == 0 {
+		names = g.Services()
+	}
+
+	set := map[string]bool{}
+	var visit func(string)
+	visit = func(name string) {
+		if set[name] {
+			return
+		}
+		set[name] = true
+		for _, other := range next(name) {
+			visit(other)
+		}
+	}
+	for _, name := range names {
+		if _, ok := g.dependencies[name]; ok {
+			visit(name)
+		}
+	}
+	return set
+}
+
+// layers groups services by the length of the longest path to a service
+// without edges through before, so every service comes after everything it
+// has an edge to
+func layers(set map[string]bool, before func(string) []string) [][]string {
+	depth := map[string]int{}
+	var measure func(string) int
+	measure = func(name string) int {
+		if d, ok := depth[name]; ok {
+			return d
+		}
+		d := 0
+		for _, other := range before(name) {
+			d = max(d, measure(other)+1)
+		}
+		depth[name] = d
+		return d
+	}
+
+	var result [][]string
+	for _, name := range slices.Sorted(maps.Keys(set)) {
+		d := measure(name)
+		for len(result) <= d {
+			result = append(result, nil)
+		}
+		result[d] = append(result[d], name)
+	}
+	return result
+}
+
+// findCycle returns a dependency cycle as a path that starts and ends with
+// the same service, or nil when there is none
+func (g *ServiceGraph) findCycle() []string {
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := map[string]int{}
+	var path []string
+
+	var visit func(string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(path, name)
+			return append(slices.Clone(path[start:]), name)
+		case done:
+			return nil
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range g.dependencies[name] {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range g.Services() {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
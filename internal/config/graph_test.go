@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServiceGraph is an app stack: api needs postgres and redis, worker
+// needs api, and postgres, redis and mailhog need nothing
+func testServiceGraph(t *testing.T) *ServiceGraph {
+	t.Helper()
+
+	graph, err := NewServiceGraph(map[string]ServiceConfig{
+		"postgres": {},
+		"redis":    {},
+		"mailhog":  {},
+		"api":      {DependsOn: []string{"redis", "postgres", "redis"}},
+		"worker":   {DependsOn: []string{"api"}},
+	})
+	require.NoError(t, err)
+	return graph
+}
+
+func TestServiceGraph_StartLayers(t *testing.T) {
+	graph := testServiceGraph(t)
+
+	assert.Equal(t, [][]string{
+		{"mailhog", "postgres", "redis"},
+		{"api"},
+		{"worker"},
+	}, graph.StartLayers())
+
+	// Only what the named services need
+	assert.Equal(t, [][]string{{"postgres", "redis"}, {"api"}}, graph.StartLayers("api"))
+	assert.Equal(t, []string{"postgres", "redis"}, graph.Dependencies("api"))
+}
+
+func TestServiceGraph_StopLayers(t *testing.T) {
+	graph := testServiceGraph(t)
+
+	// Dependents stop first
+	assert.Equal(t, [][]string{{"worker"}, {"api"}, {"redis"}}, graph.StopLayers("redis"))
+	assert.Equal(t, [][]string{
+		{"mailhog", "worker"},
+		{"api"},
+		{"postgres", "redis"},
+	}, graph.StopLayers())
+}
+
+func TestServiceGraph_Errors(t *testing.T) {
+	_, err := NewServiceGraph(map[string]ServiceConfig{
+		"a": {DependsOn: []string{"b"}},
+		"b": {DependsOn: []string{"c"}},
+		"c": {DependsOn: []string{"a"}},
+	})
+	assert.EqualError(t, err, "service dependency cycle: a -> b -> c -> a")
+
+	_, err = NewServiceGraph(map[string]ServiceConfig{"a": {DependsOn: []string{"a"}}})
+	assert.EqualError(t, err, "service a depends on itself")
+
+	_, err = NewServiceGraph(map[string]ServiceConfig{"a": {DependsOn: []string{"missing"}}})
+	assert.EqualError(t, err, "service a depends on unknown service missing")
+}
+
+func TestServiceGraph_Render(t *testing.T) {
+	graph := testServiceGraph(t)
+
+	assert.Equal(t, "mailhog\npostgres\nredis\napi -> postgres, redis\nworker -> api\n", graph.Text())
+	assert.Equal(t, `digraph services {
+  rankdir=LR;
+  "api";
+  "mailhog";
+  "postgres";
+  "redis";
+  "worker";
+  "api" -> "postgres";
+  "api" -> "redis";
+  "worker" -> "api";
+}
+`, graph.DOT())
+}
+
+func TestValidateServiceConfig_DependencyCycle(t *testing.T) {
+	composeFile := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	require.NoError(t, os.WriteFile(composeFile, nil, 0644))
+
+	services := map[string]ServiceConfig{
+		"postgres": {ComposeFile: composeFile, Service: "postgres"},
+		"api":      {ComposeFile: composeFile, Service: "api", DependsOn: []string{"postgres"}},
+	}
+
+	ok := ServiceConfig{ComposeFile: composeFile, Service: "worker", DependsOn: []string{"api"}}
+	assert.NoError(t, ValidateServiceConfig("worker", &ok, services))
+
+	cycle := ServiceConfig{ComposeFile: composeFile, Service: "postgres", DependsOn: []string{"api"}}
+	assert.ErrorContains(t, ValidateServiceConfig("postgres", &cycle, services), "service dependency cycle: api -> postgres -> api")
+
+	unknown := ServiceConfig{ComposeFile: composeFile, Service: "worker", DependsOn: []string{"queue"}}
+	assert.ErrorContains(t, ValidateServiceConfig("worker", &unknown, services), "unknown service queue")
+}
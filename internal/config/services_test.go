@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -136,7 +137,7 @@ services:
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := ValidateServiceConfig(&tt.service)
+			err := ValidateServiceConfig("test", &tt.service, nil)
 			if tt.shouldError {
 				assert.Error(t, err)
 				if tt.errorMsg != "" {
@@ -257,4 +258,208 @@ func GetRequiredServices(repoConfig *RepositoryConfig, globalServices *ServicesC
 	}
 	
 	return required
-}
\ No newline at end of file
+}
+
+// TestParsingServiceProvisioning tests parsing and validation of per-worktree provisioning
+func TestParsingServiceProvisioning(t *testing.T) {
+	content := `
+[services.postgres]
+compose_file = "/path/to/docker-compose.yaml"
+service = "postgres"
+
+[services.postgres.provision]
+type = "postgres"
+port = 5433
+password = "secret"
+url_env = "DATABASE_URL"
+
+[services.redis]
+compose_file = "/path/to/docker-compose.yaml"
+service = "redis"
+
+[services.redis.provision]
+type = "redis"
+mode = "keys"
+`
+
+	tmpDir := t.TempDir()
+	servicesPath := filepath.Join(tmpDir, "services.toml")
+	require.NoError(t, os.WriteFile(servicesPath, []byte(content), 0644))
+
+	services, err := LoadServicesConfig(servicesPath)
+	require.NoError(t, err)
+
+	postgres := services.Services["postgres"].Provision
+	require.NotNil(t, postgres)
+	assert.Equal(t, ProvisionPostgres, postgres.Type)
+	assert.Equal(t, 5433, postgres.Port)
+	assert.Equal(t, "secret", postgres.Password)
+	assert.Equal(t, "DATABASE_URL", postgres.URLEnv)
+	assert.NoError(t, postgres.Validate())
+
+	redis := services.Services["redis"].Provision
+	require.NotNil(t, redis)
+	assert.ErrorContains(t, redis.Validate(), "invalid redis provisioning mode")
+
+	assert.ErrorContains(t, (&ProvisionConfig{Type: "mysql"}).Validate(), "invalid provisioning type")
+	assert.NoError(t, (&ProvisionConfig{Type: ProvisionPostgres, Seed: "baseline"}).Validate())
+	assert.ErrorContains(t, (&ProvisionConfig{Type: ProvisionRedis, Seed: "baseline"}).Validate(), "only supported for postgres")
+	assert.Nil(t, services.Services["missing"].Provision)
+}
+
+func TestParsingHealthProbes(t *testing.T) {
+	content := `
+[services.postgres]
+compose_file = "/path/to/docker-compose.yaml"
+service = "postgres"
+
+[services.postgres.health]
+exec = ["pg_isready", "-U", "postgres"]
+interval = "500ms"
+retries = 10
+
+[services.api]
+compose_file = "/path/to/docker-compose.yaml"
+service = "api"
+health = { http = "http://localhost:8080/healthz", timeout = "1s" }
+
+[services.kafka]
+compose_file = "/path/to/docker-compose.yaml"
+service = "kafka"
+`
+
+	tmpDir := t.TempDir()
+	servicesPath := filepath.Join(tmpDir, "services.toml")
+	require.NoError(t, os.WriteFile(servicesPath, []byte(content), 0644))
+
+	services, err := LoadServicesConfig(servicesPath)
+	require.NoError(t, err)
+
+	postgres := services.Services["postgres"].Health
+	require.NotNil(t, postgres)
+	assert.Equal(t, ProbeExec, postgres.Kind())
+	assert.Equal(t, 500*time.Millisecond, postgres.IntervalDuration())
+	assert.Equal(t, 5*time.Second, postgres.TimeoutDuration())
+	assert.Equal(t, 10, postgres.RetryCount())
+	assert.NoError(t, postgres.Validate())
+
+	api := services.Services["api"].Health
+	require.NotNil(t, api)
+	assert.Equal(t, ProbeHTTP, api.Kind())
+	assert.Equal(t, time.Second, api.TimeoutDuration())
+
+	// Services without a probe fall back to the defaults
+	kafka := services.Services["kafka"].Health
+	assert.Nil(t, kafka)
+	assert.Equal(t, "", kafka.Kind())
+	assert.Equal(t, 30, kafka.RetryCount())
+}
+
+func TestProbeConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe ProbeConfig
+		err   string
+	}{
+		{"tcp", ProbeConfig{TCP: "localhost:5432"}, ""},
+		{"log", ProbeConfig{Log: `ready to accept connections`}, ""},
+		{"none", ProbeConfig{Interval: "1s"}, "exactly one of"},
+		{"two kinds", ProbeConfig{TCP: "localhost:5432", Log: "ready"}, "exactly one of"},
+		{"http without scheme", ProbeConfig{HTTP: "localhost:8080"}, "invalid http probe"},
+		{"tcp without port", ProbeConfig{TCP: "localhost"}, "invalid tcp probe"},
+		{"bad regexp", ProbeConfig{Log: "ready("}, "invalid log probe"},
+		{"bad interval", ProbeConfig{TCP: "localhost:5432", Interval: "soon"}, "invalid health probe interval"},
+		{"negative retries", ProbeConfig{TCP: "localhost:5432", Retries: -1}, "invalid health probe retries"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.probe.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRestartPolicy(t *testing.T) {
+	var none *RestartPolicy
+	assert.False(t, none.ShouldRestart(1))
+	assert.False(t, (&RestartPolicy{Policy: RestartNo}).ShouldRestart(1))
+	assert.True(t, (&RestartPolicy{Policy: RestartAlways}).ShouldRestart(0))
+	assert.False(t, (&RestartPolicy{Policy: RestartOnFailure}).ShouldRestart(0))
+	assert.True(t, (&RestartPolicy{Policy: RestartOnFailure}).ShouldRestart(137))
+
+	policy := &RestartPolicy{Policy: RestartAlways, Backoff: "1s", MaxBackoff: "5s"}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		delays = append(delays, policy.BackoffDelay(attempt))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+	assert.Equal(t, 5, none.RetryLimit())
+
+	assert.NoError(t, policy.Validate())
+	assert.ErrorContains(t, (&RestartPolicy{Policy: "sometimes"}).Validate(), "invalid restart policy")
+	assert.ErrorContains(t, (&RestartPolicy{Policy: RestartAlways, Backoff: "later"}).Validate(), "invalid restart backoff")
+	assert.ErrorContains(t, (&RestartPolicy{Policy: RestartOnFailure, MaxRetries: -1}).Validate(), "invalid restart max_retries")
+}
+
+func TestParsingInlineServices(t *testing.T) {
+	content := `
+[services.cache]
+image = "redis:7-alpine"
+ports = ["6380:6379"]
+volumes = ["cache-data:/data"]
+command = ["redis-server", "--appendonly", "yes"]
+env = { TZ = "UTC" }
+
+[services.named]
+image = "mailhog/mailhog"
+service = "mailhog"
+`
+
+	servicesPath := filepath.Join(t.TempDir(), "services.toml")
+	require.NoError(t, os.WriteFile(servicesPath, []byte(content), 0644))
+
+	services, err := LoadServicesConfig(servicesPath)
+	require.NoError(t, err)
+
+	cache := services.Services["cache"]
+	assert.True(t, cache.IsInline())
+	assert.True(t, cache.IsValid())
+	assert.Equal(t, "vibeman-service-cache", cache.Service)
+	assert.Equal(t, []string{"6380:6379"}, cache.Ports)
+	assert.Equal(t, map[string]string{"TZ": "UTC"}, cache.Env)
+	assert.Equal(t, []string{"redis-server", "--appendonly", "yes"}, cache.Command)
+	assert.NoError(t, ValidateServiceConfig("cache", &cache, services.Services))
+
+	// An explicit service names the container
+	assert.Equal(t, "mailhog", services.Services["named"].Service)
+}
+
+func TestValidateServiceConfig_Inline(t *testing.T) {
+	composeFile := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	require.NoError(t, os.WriteFile(composeFile, nil, 0644))
+
+	tests := []struct {
+		name    string
+		service ServiceConfig
+		err     string
+	}{
+		{"both kinds", ServiceConfig{Image: "redis", ComposeFile: composeFile, Service: "redis"}, "either an image or a compose file"},
+		{"bad port", ServiceConfig{Image: "redis", Ports: []string{"70000"}}, `invalid port "70000"`},
+		{"bad host IP", ServiceConfig{Image: "redis", Ports: []string{"localhost:6379:6379"}}, `invalid port "localhost:6379:6379"`},
+		{"bad protocol", ServiceConfig{Image: "redis", Ports: []string{"6379:6379/http"}}, `invalid port "6379:6379/http"`},
+		{"bad volume", ServiceConfig{Image: "redis", Volumes: []string{"data"}}, `invalid volume "data"`},
+		{"relative target", ServiceConfig{Image: "redis", Volumes: []string{"data:data"}}, `invalid volume "data:data"`},
+		{"bad env", ServiceConfig{Image: "redis", Env: map[string]string{"MY-VAR": "x"}}, "invalid env MY-VAR"},
+		{"compose with ports", ServiceConfig{ComposeFile: composeFile, Service: "redis", Ports: []string{"6379:6379"}}, "only supported for inline services"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, ValidateServiceConfig("redis", &tt.service, nil), tt.err)
+		})
+	}
+}
@@ -24,7 +24,7 @@ type SimplifiedRepositoryConfig struct {
 			// Optional: Setup commands that run inside the container
 			Setup []string `toml:"setup"` // Commands to run after container starts
 		} `toml:"container"`
-		Services map[string]ServiceRequirement `toml:"-"` // Service requirements (custom unmarshal)
+		Services ServiceRequirements `toml:"-"` // Service requirements (custom unmarshal)
 		Runtime  struct {
 			Type string `toml:"type"` // "apple" or "docker"
 		} `toml:"runtime"`
@@ -74,6 +74,7 @@ func (c *SimplifiedRepositoryConfig) Validate() error {
 // [repository.ai]
 // enabled = true  # Default is true, set to false to disable AI container
 // # image = "custom/ai-image:latest"  # Optional custom image
+// # agent = "codex"  # claude (default), codex, aider, gemini or one of [repository.ai.agents]
 // # [repository.ai.env]
 // # CUSTOM_VAR = "value"
 // # [repository.ai.volumes]
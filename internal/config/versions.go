@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// VersionSeparator joins a service and one of its versions into the name the
+// version runs under, e.g. postgres@16
+const VersionSeparator = "@"
+
+// versionRegex matches version names, which also end up in container and
+// volume names
+var versionRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// VersionedName returns the name a version of a service runs under, or the
+// service's own name when version is empty
+func VersionedName(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + VersionSeparator + version
+}
+
+// SplitVersionedName splits the name of a service version into the service
+// and the version
+func SplitVersionedName(name string) (service, version string) {
+	service, version, _ = strings.Cut(name, VersionSeparator)
+	return service, version
+}
+
+// Lookup returns the configuration of a service, or of one of its versions
+// when name is versioned
+func (s *ServicesConfig) Lookup(name string) (ServiceConfig, bool) {
+	return LookupService(s.Services, name)
+}
+
+// All returns every service and every version of a service, keyed by the
+// name each runs under
+func (s *ServicesConfig) All() map[string]ServiceConfig {
+	return ExpandVersions(s.Services)
+}
+
+// LookupService returns the configuration of a service in services, or of
+// one of its versions when name is versioned
+func LookupService(services map[string]ServiceConfig, name string) (ServiceConfig, bool) {
+	base, version := SplitVersionedName(name)
+	service, exists := services[base]
+	if !exists || version == "" {
+		return service, exists
+	}
+	override, exists := service.Versions[version]
+	if !exists {
+		return ServiceConfig{}, false
+	}
+	return service.withVersion(base, version, override), true
+}
+
+// ExpandVersions returns services plus the versions each declares, keyed by
+// the name each runs under
+func ExpandVersions(services map[string]ServiceConfig) map[string]ServiceConfig {
+	expanded := make(map[string]ServiceConfig, len(services))
+	for name, service := range services {
+		expanded[name] = service
+		for version, override := range service.Versions {
+			expanded[VersionedName(name, version)] = service.withVersion(name, version, override)
+		}
+	}
+	return expanded
+}
+
+// withVersion returns the configuration of one version of a service: the
+// service's own settings with those the version sets replacing them. An
+// inline version gets a container and named volumes of its own, and unless
+// it sets its own ports it publishes the service's container ports on free
+// host ports, so versions never clash with each other.
+func (sc ServiceConfig) withVersion(name, version string, override ServiceConfig) ServiceConfig {
+	resolved := sc
+	resolved.Versions = nil
+
+	if override.ComposeFile != "" {
+		resolved.ComposeFile = override.ComposeFile
+	}
+	if override.Description != "" {
+		resolved.Description = override.Description
+	}
+	if override.Image != "" {
+		resolved.Image = override.Image
+	}
+	if len(override.Env) > 0 {
+		resolved.Env = maps.Clone(sc.Env)
+		if resolved.Env == nil {
+			resolved.Env = map[string]string{}
+		}
+		maps.Copy(resolved.Env, override.Env)
+	}
+	if override.Command != nil {
+		resolved.Command = override.Command
+	}
+	if override.Provision != nil {
+		resolved.Provision = override.Provision
+	}
+	if override.Health != nil {
+		resolved.Health = override.Health
+	}
+	if override.DependsOn != nil {
+		resolved.DependsOn = override.DependsOn
+	}
+	if override.Restart != nil {
+		resolved.Restart = override.Restart
+	}
+
+	switch {
+	case override.Service != "":
+		resolved.Service = override.Service
+	case resolved.IsInline():
+		container := sc.Service
+		if !sc.IsInline() || container == "" {
+			container = InlineContainerName(name)
+		}
+		resolved.Service = container + "-" + version
+	}
+
+	if !resolved.IsInline() {
+		// A compose version must name its own compose service
+		return resolved
+	}
+
+	resolved.Ports = override.Ports
+	if resolved.Ports == nil {
+		resolved.Ports = make([]string, 0, len(sc.Ports))
+		for _, port := range sc.Ports {
+			_, _, containerPort := SplitPort(port)
+			resolved.Ports = append(resolved.Ports, containerPort)
+		}
+	}
+	resolved.Volumes = override.Volumes
+	if resolved.Volumes == nil {
+		resolved.Volumes = make([]string, 0, len(sc.Volumes))
+		for _, volume := range sc.Volumes {
+			source, target, _ := strings.Cut(volume, ":")
+			if !isHostPath(source) {
+				source += "-" + version
+			}
+			resolved.Volumes = append(resolved.Volumes, source+":"+target)
+		}
+	}
+	return resolved
+}
+
+// validateVersions checks the versions a service declares and that none of
+// them clashes with the service or another version on a host port
+func validateVersions(name string, service *ServiceConfig) error {
+	if len(service.Versions) == 0 {
+		return nil
+	}
+
+	hostPorts := map[string]string{}
+	claimPorts := func(owner string, ports []string) error {
+		for _, port := range ports {
+			_, hostPort, containerPort := SplitPort(port)
+			if hostPort == "" {
+				continue // Published on a free port
+			}
+			key := hostPort
+			if _, protocol, _ := strings.Cut(containerPort, "/"); protocol != "" && protocol != "tcp" {
+				key += "/" + protocol
+			}
+			if other, clash := hostPorts[key]; clash {
+				return fmt.Errorf("%s and %s both publish host port %s", other, owner, key)
+			}
+			hostPorts[key] = owner
+		}
+		return nil
+	}
+	if service.IsInline() {
+		if err := claimPorts(name, service.Ports); err != nil {
+			return err
+		}
+	}
+
+	for _, version := range slices.Sorted(maps.Keys(service.Versions)) {
+		override := service.Versions[version]
+		versioned := VersionedName(name, version)
+		if !versionRegex.MatchString(version) {
+			return fmt.Errorf("invalid version %q of service %s: must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", version, name)
+		}
+		if len(override.Versions) > 0 {
+			return fmt.Errorf("version %s cannot declare versions of its own", versioned)
+		}
+
+		resolved := service.withVersion(name, version, override)
+		if !resolved.IsInline() && override.Service == "" && override.ComposeFile == "" {
+			return fmt.Errorf("version %s must set the compose service or compose file it runs", versioned)
+		}
+		if err := validateServiceDefinition(&resolved); err != nil {
+			return fmt.Errorf("invalid version %s: %w", versioned, err)
+		}
+		if resolved.IsInline() {
+			if err := claimPorts(versioned, resolved.Ports); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isHostPath reports whether the source of a volume mount is a path on the
+// host rather than a named volume
+func isHostPath(source string) bool {
+	return strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~")
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceVersions(t *testing.T) {
+	content := `
+[services.postgres]
+image = "postgres:16"
+ports = ["5432:5432"]
+env = { POSTGRES_PASSWORD = "postgres", TZ = "UTC" }
+volumes = ["pgdata:/var/lib/postgresql/data", "./init:/docker-entrypoint-initdb.d"]
+
+[services.postgres.versions.14]
+image = "postgres:14"
+env = { TZ = "Europe/Berlin" }
+
+[services.postgres.versions.15]
+image = "postgres:15"
+ports = ["5415:5432"]
+volumes = ["pg15:/var/lib/postgresql/data"]
+`
+
+	servicesPath := filepath.Join(t.TempDir(), "services.toml")
+	require.NoError(t, os.WriteFile(servicesPath, []byte(content), 0644))
+	services, err := LoadServicesConfig(servicesPath)
+	require.NoError(t, err)
+
+	postgres := services.Services["postgres"]
+	require.NoError(t, ValidateServiceConfig("postgres", &postgres, services.Services))
+
+	// A version inherits what it does not set, with a container, volumes and
+	// host ports of its own
+	v14, ok := services.Lookup("postgres@14")
+	require.True(t, ok)
+	assert.Equal(t, "postgres:14", v14.Image)
+	assert.Equal(t, "vibeman-service-postgres-14", v14.Service)
+	assert.Equal(t, []string{"5432"}, v14.Ports)
+	assert.Equal(t, []string{"pgdata-14:/var/lib/postgresql/data", "./init:/docker-entrypoint-initdb.d"}, v14.Volumes)
+	assert.Equal(t, map[string]string{"POSTGRES_PASSWORD": "postgres", "TZ": "Europe/Berlin"}, v14.Env)
+	assert.Nil(t, v14.Versions)
+
+	v15, ok := services.Lookup("postgres@15")
+	require.True(t, ok)
+	assert.Equal(t, []string{"5415:5432"}, v15.Ports)
+	assert.Equal(t, []string{"pg15:/var/lib/postgresql/data"}, v15.Volumes)
+
+	base, ok := services.Lookup("postgres")
+	require.True(t, ok)
+	assert.Equal(t, "postgres:16", base.Image)
+	_, ok = services.Lookup("postgres@13")
+	assert.False(t, ok)
+
+	assert.ElementsMatch(t, []string{"postgres", "postgres@14", "postgres@15"}, slices.Collect(maps.Keys(services.All())))
+	graph, err := NewServiceGraph(services.Services)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"postgres", "postgres@14", "postgres@15"}, graph.Services())
+}
+
+func TestServiceVersions_HostIPPorts(t *testing.T) {
+	postgres := ServiceConfig{
+		Image: "postgres:16",
+		Ports: []string{"127.0.0.1:5432:5432", "[::1]:5433:5432", "9000:9000/udp"},
+		Versions: map[string]ServiceConfig{
+			"14": {Image: "postgres:14"},
+			"15": {Image: "postgres:15", Ports: []string{"127.0.0.1:5415:5432"}},
+		},
+	}
+	require.NoError(t, ValidateServiceConfig("postgres", &postgres, nil))
+
+	// A version publishes the container ports on free host ports
+	services := &ServicesConfig{Services: map[string]ServiceConfig{"postgres": postgres}}
+	v14, ok := services.Lookup("postgres@14")
+	require.True(t, ok)
+	assert.Equal(t, []string{"5432", "5432", "9000/udp"}, v14.Ports)
+
+	v15, ok := services.Lookup("postgres@15")
+	require.True(t, ok)
+	assert.Equal(t, []string{"127.0.0.1:5415:5432"}, v15.Ports)
+}
+
+func TestValidateServiceVersions(t *testing.T) {
+	composeFile := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	require.NoError(t, os.WriteFile(composeFile, []byte("services: {}\n"), 0644))
+
+	tests := []struct {
+		name    string
+		service ServiceConfig
+		wantErr string
+	}{
+		{
+			"port clash",
+			ServiceConfig{Image: "postgres:16", Service: "pg", Ports: []string{"5432:5432"}, Versions: map[string]ServiceConfig{
+				"14": {Image: "postgres:14", Ports: []string{"5432:5432"}},
+			}},
+			"postgres and postgres@14 both publish host port 5432",
+		},
+		{
+			"port clash on a host IP",
+			ServiceConfig{Image: "postgres:16", Service: "pg", Ports: []string{"127.0.0.1:5432:5432"}, Versions: map[string]ServiceConfig{
+				"14": {Image: "postgres:14", Ports: []string{"5432:5432"}},
+			}},
+			"postgres and postgres@14 both publish host port 5432",
+		},
+		{
+			"bad name",
+			ServiceConfig{Image: "postgres:16", Service: "pg", Versions: map[string]ServiceConfig{"1 4": {Image: "postgres:14"}}},
+			`invalid version "1 4" of service postgres`,
+		},
+		{
+			"nested",
+			ServiceConfig{Image: "postgres:16", Service: "pg", Versions: map[string]ServiceConfig{
+				"14": {Versions: map[string]ServiceConfig{"x": {}}},
+			}},
+			"version postgres@14 cannot declare versions of its own",
+		},
+		{
+			"compose version without its own service",
+			ServiceConfig{ComposeFile: composeFile, Service: "postgres", Versions: map[string]ServiceConfig{"14": {Description: "old"}}},
+			"version postgres@14 must set the compose service or compose file it runs",
+		},
+		{
+			"invalid override",
+			ServiceConfig{Image: "postgres:16", Service: "pg", Versions: map[string]ServiceConfig{"14": {Ports: []string{"nope"}}}},
+			`invalid version postgres@14: invalid port "nope"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, ValidateServiceConfig("postgres", &tt.service, nil), tt.wantErr)
+		})
+	}
+
+	compose := ServiceConfig{ComposeFile: composeFile, Service: "postgres", Versions: map[string]ServiceConfig{"14": {Service: "postgres14"}}}
+	assert.NoError(t, ValidateServiceConfig("postgres", &compose, nil))
+}
+
+func TestServiceRequirementVersions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vibeman.toml"), []byte(`
+[repository]
+name = "legacy-app"
+
+[repository.services]
+postgres = { required = true, version = "14" }
+redis = { required = true }
+`), 0644))
+
+	repoConfig, err := ParseRepositoryConfig(dir)
+	require.NoError(t, err)
+	services := repoConfig.Repository.Services
+	assert.Equal(t, "14", services["postgres"].Version)
+	assert.Equal(t, []string{"postgres@14", "redis"}, services.Names())
+	assert.True(t, services.Uses("postgres@14"))
+	assert.True(t, services.Uses("redis"))
+	assert.False(t, services.Uses("postgres"))
+	assert.False(t, services.Uses("redis@6"))
+}
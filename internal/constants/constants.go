@@ -13,6 +13,10 @@ const (
 	
 	// DefaultDevPort is the default port used in development environments
 	DefaultDevPort = 3000
+
+	// ContainerHostGateway is the host name containers use to reach ports
+	// published on the Docker host
+	ContainerHostGateway = "host.docker.internal"
 )
 
 // File System Permissions
@@ -58,6 +62,21 @@ const (
 	
 	// DefaultServerShutdownTimeout is the default server graceful shutdown timeout
 	DefaultServerShutdownTimeout = 30 * time.Second
+
+	// DefaultAuditPruneInterval is how often the server prunes expired audit events
+	DefaultAuditPruneInterval = 24 * time.Hour
+
+	// DefaultServiceIdleCheckInterval is how often the server looks for unused services to stop
+	DefaultServiceIdleCheckInterval = time.Minute
+
+	// DefaultServiceSupervisorInterval is how often the server checks that running services are still up
+	DefaultServiceSupervisorInterval = 5 * time.Second
+
+	// DefaultAgentWatchInterval is how often the server looks at the agents in AI terminal sessions
+	DefaultAgentWatchInterval = 10 * time.Second
+
+	// DefaultAIRecordInterval is how often the server looks for AI terminal sessions to record
+	DefaultAIRecordInterval = 10 * time.Second
 )
 
 // Pagination Constants
@@ -79,6 +98,31 @@ const (
 	
 	// DefaultServiceOperationTimeout is the default timeout for service operations
 	DefaultServiceOperationTimeout = 30 * time.Second
+
+	// DefaultProbeInterval is the default time between health probes
+	DefaultProbeInterval = 2 * time.Second
+
+	// DefaultProbeTimeout is the default time a single health probe may take
+	DefaultProbeTimeout = 5 * time.Second
+
+	// DefaultProbeRetries is the default number of failed probes after which
+	// a starting service or container is considered unhealthy
+	DefaultProbeRetries = 30
+
+	// DefaultRestartBackoff is the default delay before the first restart of
+	// a crashed service; it doubles with each further restart
+	DefaultRestartBackoff = time.Second
+
+	// DefaultMaxRestartBackoff is the default longest delay between restarts
+	DefaultMaxRestartBackoff = time.Minute
+
+	// DefaultRestartMaxRetries is the default number of restarts after which
+	// a crashing service is considered crash-looping
+	DefaultRestartMaxRetries = 5
+
+	// SnapshotHelperImage runs tar against the named volumes of a service
+	// for volume snapshots
+	SnapshotHelperImage = "alpine:3"
 )
 
 // Logging and Output Limits
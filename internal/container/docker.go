@@ -4,9 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"vibeman/internal/constants"
+	"vibeman/internal/telemetry"
 )
 
 // DockerRuntime implements ContainerRuntime for Docker
@@ -32,13 +39,13 @@ func (r *DockerRuntime) GetType() RuntimeType {
 // IsAvailable checks if Docker is available on the system
 func (r *DockerRuntime) IsAvailable(ctx context.Context) bool {
 	cmd := r.executor.CommandContext(ctx, "docker", "--version")
-	return cmd.Run() == nil
+	return telemetry.Run(ctx, cmd) == nil
 }
 
 // List returns all containers
 func (r *DockerRuntime) List(ctx context.Context) ([]*Container, error) {
 	cmd := r.executor.CommandContext(ctx, "docker", "ps", "-a", "--format", "json")
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -162,15 +169,26 @@ func (r *DockerRuntime) Create(ctx context.Context, config *CreateConfig) (*Cont
 		"--label", "vibeman.managed=true",
 		"--label", fmt.Sprintf("vibeman.type=%s", config.Type),
 	)
+	for _, name := range slices.Sorted(maps.Keys(config.Labels)) {
+		args = append(args, "--label", name+"="+config.Labels[name])
+	}
 
 	// Set working directory if specified
 	if config.WorkingDir != "" {
 		args = append(args, "-w", config.WorkingDir)
 	}
 
-	// Add environment variables
+	// Add environment variables by name; docker reads the values, which
+	// may be credentials, from our environment rather than the command line
+	usesHostGateway := false
 	for _, env := range config.EnvVars {
-		args = append(args, "-e", env)
+		name, value, _ := strings.Cut(env, "=")
+		args = append(args, "-e", name)
+		usesHostGateway = usesHostGateway || strings.Contains(value, constants.ContainerHostGateway)
+	}
+	if usesHostGateway {
+		// Docker Desktop resolves the gateway itself; Linux needs the mapping
+		args = append(args, "--add-host", constants.ContainerHostGateway+":host-gateway")
 	}
 
 	// Add volume mounts
@@ -183,16 +201,21 @@ func (r *DockerRuntime) Create(ctx context.Context, config *CreateConfig) (*Cont
 		args = append(args, "-p", port)
 	}
 
+	args = append(args, sandboxArgs(config)...)
+
 	// Add image
 	args = append(args, config.Image)
 	
 	// Add default command for alpine to keep it running
-	if config.Image == "alpine:latest" || config.Image == "alpine" {
+	if len(config.Command) > 0 {
+		args = append(args, config.Command...)
+	} else if config.Image == "alpine:latest" || config.Image == "alpine" {
 		args = append(args, "sh", "-c", "while true; do sleep 30; done")
 	}
 
 	cmd := r.executor.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd.Env = append(os.Environ(), config.EnvVars...)
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		// Check for specific error types
 		outputStr := string(output)
@@ -234,6 +257,43 @@ func (r *DockerRuntime) Create(ctx context.Context, config *CreateConfig) (*Cont
 	}, nil
 }
 
+// sandboxArgs returns the docker run flags for the sandboxing a container
+// config asks for
+func sandboxArgs(config *CreateConfig) []string {
+	var args []string
+	if config.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	for _, path := range config.Tmpfs {
+		args = append(args, "--tmpfs", path)
+	}
+	for _, capability := range config.CapDrop {
+		args = append(args, "--cap-drop", capability)
+	}
+	for _, capability := range config.CapAdd {
+		args = append(args, "--cap-add", capability)
+	}
+	for _, opt := range config.SecurityOpts {
+		args = append(args, "--security-opt", opt)
+	}
+	if config.User != "" {
+		args = append(args, "--user", config.User)
+	}
+	if config.UserNS != "" {
+		args = append(args, "--userns", config.UserNS)
+	}
+	if config.CPUs != "" {
+		args = append(args, "--cpus", config.CPUs)
+	}
+	if config.Memory != "" {
+		args = append(args, "--memory", config.Memory)
+	}
+	if config.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(config.PidsLimit))
+	}
+	return args
+}
+
 // createFromCompose creates a container using docker-compose
 func (r *DockerRuntime) createFromCompose(ctx context.Context, config *CreateConfig) (*Container, error) {
 	// Ensure compose file exists
@@ -278,8 +338,11 @@ func (r *DockerRuntime) createFromCompose(ctx context.Context, config *CreateCon
 	}
 	// If no services specified, start all services (no --no-deps flag)
 
+	// Compose interpolates the environment into the compose file, so
+	// services can use e.g. ${VIBEMAN_SERVICE_POSTGRES_URL}
 	cmd := r.executor.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd.Env = append(os.Environ(), config.EnvVars...)
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start compose service: %w, output: %s", err, string(output))
 	}
@@ -302,7 +365,7 @@ func (r *DockerRuntime) createFromCompose(ctx context.Context, config *CreateCon
 	// Otherwise get all containers for the compose project
 
 	cmd = r.executor.CommandContext(ctx, "docker", args...)
-	output, err = cmd.Output()
+	output, err = telemetry.Output(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container IDs: %w", err)
 	}
@@ -331,7 +394,7 @@ func (r *DockerRuntime) createFromCompose(ctx context.Context, config *CreateCon
 // Start starts a container
 func (r *DockerRuntime) Start(ctx context.Context, containerID string) error {
 	cmd := r.executor.CommandContext(ctx, "docker", "start", containerID)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		outputStr := string(output)
 		if strings.Contains(outputStr, "No such container") {
@@ -355,7 +418,7 @@ func (r *DockerRuntime) Start(ctx context.Context, containerID string) error {
 // Stop stops a container
 func (r *DockerRuntime) Stop(ctx context.Context, containerID string) error {
 	cmd := r.executor.CommandContext(ctx, "docker", "stop", containerID)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to stop container: %w, output: %s", err, string(output))
 	}
@@ -365,7 +428,7 @@ func (r *DockerRuntime) Stop(ctx context.Context, containerID string) error {
 // Remove removes a container
 func (r *DockerRuntime) Remove(ctx context.Context, containerID string) error {
 	cmd := r.executor.CommandContext(ctx, "docker", "rm", containerID)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to remove container: %w, output: %s", err, string(output))
 	}
@@ -376,7 +439,7 @@ func (r *DockerRuntime) Remove(ctx context.Context, containerID string) error {
 func (r *DockerRuntime) Exec(ctx context.Context, containerID string, command []string) ([]byte, error) {
 	args := append([]string{"exec", containerID}, command...)
 	cmd := r.executor.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return nil, &ContainerError{
 			Type:        parseDockerError(string(output), err),
@@ -399,7 +462,7 @@ func (r *DockerRuntime) Logs(ctx context.Context, containerID string, follow boo
 	args = append(args, containerID)
 
 	cmd := r.executor.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container logs: %w, output: %s", err, string(output))
 	}
@@ -409,7 +472,7 @@ func (r *DockerRuntime) Logs(ctx context.Context, containerID string, follow boo
 // GetInfo returns detailed information about a container
 func (r *DockerRuntime) GetInfo(ctx context.Context, containerID string) (*Container, error) {
 	cmd := r.executor.CommandContext(ctx, "docker", "inspect", containerID)
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
@@ -436,6 +499,14 @@ func (r *DockerRuntime) GetInfo(ctx context.Context, containerID string) (*Conta
 	// Get config section for image and command
 	if config, ok := dockerContainer["Config"].(map[string]interface{}); ok {
 		container.Image = getStringField(config, "Image")
+		if labels, ok := config["Labels"].(map[string]interface{}); ok {
+			container.Labels = make(map[string]string, len(labels))
+			for name, value := range labels {
+				if str, ok := value.(string); ok {
+					container.Labels[name] = str
+				}
+			}
+		}
 		if cmd, ok := config["Cmd"].([]interface{}); ok && len(cmd) > 0 {
 			cmdParts := make([]string, len(cmd))
 			for i, part := range cmd {
@@ -535,7 +606,7 @@ func parseDockerPorts(portsStr string) map[string]string {
 // getContainerLabels gets labels from a container using docker inspect
 func (r *DockerRuntime) getContainerLabels(ctx context.Context, containerID string) (map[string]string, error) {
 	cmd := r.executor.CommandContext(ctx, "docker", "inspect", containerID, "--format", "{{json .Config.Labels}}")
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container labels: %w", err)
 	}
@@ -551,7 +622,7 @@ func (r *DockerRuntime) getContainerLabels(ctx context.Context, containerID stri
 // getContainerEnvVars gets environment variables for a container using docker inspect
 func (r *DockerRuntime) getContainerEnvVars(ctx context.Context, containerID string) (map[string]string, error) {
 	cmd := r.executor.CommandContext(ctx, "docker", "inspect", containerID, "--format", "{{json .Config.Env}}")
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container env vars: %w", err)
 	}
@@ -271,4 +271,34 @@ func mustMarshalJSON(v interface{}) string {
 		panic(err)
 	}
 	return string(data)
-}
\ No newline at end of file
+}
+
+// TestSandboxArgs tests the docker run flags for sandboxed containers
+func TestSandboxArgs(t *testing.T) {
+	assert.Empty(t, sandboxArgs(&CreateConfig{Name: "plain"}))
+
+	args := sandboxArgs(&CreateConfig{
+		ReadOnly:     true,
+		Tmpfs:        []string{"/tmp", "/run"},
+		CapDrop:      []string{"ALL"},
+		CapAdd:       []string{"NET_BIND_SERVICE"},
+		SecurityOpts: []string{"no-new-privileges", "seccomp=/etc/vibeman/seccomp.json"},
+		User:         "1000:1000",
+		UserNS:       "host",
+		CPUs:         "2",
+		Memory:       "4g",
+		PidsLimit:    1024,
+	})
+	assert.Equal(t, []string{
+		"--read-only",
+		"--tmpfs", "/tmp", "--tmpfs", "/run",
+		"--cap-drop", "ALL",
+		"--cap-add", "NET_BIND_SERVICE",
+		"--security-opt", "no-new-privileges", "--security-opt", "seccomp=/etc/vibeman/seccomp.json",
+		"--user", "1000:1000",
+		"--userns", "host",
+		"--cpus", "2",
+		"--memory", "4g",
+		"--pids-limit", "1024",
+	}, args)
+}
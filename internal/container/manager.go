@@ -3,16 +3,21 @@ package container
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
-	"time"
 
 	"vibeman/internal/config"
+	"vibeman/internal/health"
 	"vibeman/internal/interfaces"
 	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
 	"vibeman/internal/types"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CommandExecutor interface for executing commands (allows mocking in tests)
@@ -36,6 +41,7 @@ type Manager struct {
 	runtimeMutex sync.RWMutex
 	factory      *RuntimeFactory
 	executor     CommandExecutor // Hold executor for cleanup
+	checker      *health.Checker // Readiness probes before setup
 }
 
 // Type aliases for backward compatibility
@@ -55,6 +61,7 @@ func New(cfg *config.Manager) *Manager {
 		config:   cfg,
 		factory:  NewRuntimeFactory(executor),
 		executor: executor,
+		checker:  health.NewChecker(nil),
 	}
 }
 
@@ -93,7 +100,10 @@ func (m *Manager) Close() error {
 }
 
 // CreateWithConfig creates a new container with the given configuration
-func (m *Manager) CreateWithConfig(ctx context.Context, config *CreateConfig) (*Container, error) {
+func (m *Manager) CreateWithConfig(ctx context.Context, config *CreateConfig) (ctr *Container, err error) {
+	ctx, span := telemetry.Start(ctx, "container.CreateWithConfig", attribute.String("vibeman.container_name", config.Name), attribute.String("vibeman.image", config.Image))
+	defer func() { telemetry.End(span, err) }()
+
 	runtime, err := m.getRuntime(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container runtime: %w", err)
@@ -113,7 +123,10 @@ func (m *Manager) List(ctx context.Context) ([]*Container, error) {
 }
 
 // Create creates a new container
-func (m *Manager) Create(ctx context.Context, repositoryName, environment, image string) (*Container, error) {
+func (m *Manager) Create(ctx context.Context, repositoryName, environment, image string) (ctr *Container, err error) {
+	ctx, span := telemetry.Start(ctx, "container.Create", attribute.String("vibeman.repository", repositoryName), attribute.String("vibeman.environment", environment))
+	defer func() { telemetry.End(span, err) }()
+
 	if repositoryName == "" {
 		return nil, fmt.Errorf("repository name is required")
 	}
@@ -157,6 +170,19 @@ func (m *Manager) Create(ctx context.Context, repositoryName, environment, image
 		fmt.Sprintf("VIBEMAN_REPOSITORY=%s", repositoryName),
 	}
 
+	// Tell the containers how to reach the services the repository uses.
+	// Compose files can use these, e.g. ${VIBEMAN_SERVICE_POSTGRES_URL}.
+	if provider, ok := m.service.(interfaces.ServiceEnvProvider); ok && len(m.config.Repository.Repository.Services) > 0 {
+		services := slices.Collect(maps.Keys(m.config.Repository.Repository.Services))
+		serviceEnv, err := provider.ServiceEnv(ctx, "", services, true)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to resolve service connection details")
+		}
+		for _, key := range slices.Sorted(maps.Keys(serviceEnv)) {
+			validatedEnvVars = append(validatedEnvVars, key+"="+serviceEnv[key])
+		}
+	}
+
 	// Note: Ports, volumes, working directory etc. are handled by docker-compose
 
 	config := &CreateConfig{
@@ -199,7 +225,10 @@ func (m *Manager) Create(ctx context.Context, repositoryName, environment, image
 }
 
 // Start starts a container
-func (m *Manager) Start(ctx context.Context, containerID string) error {
+func (m *Manager) Start(ctx context.Context, containerID string) (err error) {
+	ctx, span := telemetry.Start(ctx, "container.Start", attribute.String("vibeman.container_id", containerID))
+	defer func() { telemetry.End(span, err) }()
+
 	// Validate container ID
 	if err := validateContainerID(containerID); err != nil {
 		return fmt.Errorf("invalid container ID: %w", err)
@@ -221,7 +250,10 @@ func (m *Manager) Start(ctx context.Context, containerID string) error {
 }
 
 // Stop stops a container
-func (m *Manager) Stop(ctx context.Context, containerID string) error {
+func (m *Manager) Stop(ctx context.Context, containerID string) (err error) {
+	ctx, span := telemetry.Start(ctx, "container.Stop", attribute.String("vibeman.container_id", containerID))
+	defer func() { telemetry.End(span, err) }()
+
 	// Validate container ID
 	if err := validateContainerID(containerID); err != nil {
 		return fmt.Errorf("invalid container ID: %w", err)
@@ -236,7 +268,10 @@ func (m *Manager) Stop(ctx context.Context, containerID string) error {
 }
 
 // Remove removes a container
-func (m *Manager) Remove(ctx context.Context, containerID string) error {
+func (m *Manager) Remove(ctx context.Context, containerID string) (err error) {
+	ctx, span := telemetry.Start(ctx, "container.Remove", attribute.String("vibeman.container_id", containerID))
+	defer func() { telemetry.End(span, err) }()
+
 	// Validate container ID
 	if err := validateContainerID(containerID); err != nil {
 		return fmt.Errorf("invalid container ID: %w", err)
@@ -275,7 +310,10 @@ func (m *Manager) Remove(ctx context.Context, containerID string) error {
 }
 
 // Exec executes a command in a container
-func (m *Manager) Exec(ctx context.Context, containerID string, command []string) ([]byte, error) {
+func (m *Manager) Exec(ctx context.Context, containerID string, command []string) (output []byte, err error) {
+	ctx, span := telemetry.Start(ctx, "container.Exec", attribute.String("vibeman.container_id", containerID), attribute.StringSlice("vibeman.command", command))
+	defer func() { telemetry.End(span, err) }()
+
 	// Validate container ID
 	if err := validateContainerID(containerID); err != nil {
 		return nil, fmt.Errorf("invalid container ID: %w", err)
@@ -410,7 +448,7 @@ func (m *Manager) SSH(ctx context.Context, containerID string, user string) erro
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return telemetry.Run(ctx, cmd)
 }
 
 // Shell opens an interactive shell in a container
@@ -443,7 +481,7 @@ func (m *Manager) Shell(ctx context.Context, containerID string, shell string) e
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
-		return cmd.Run()
+		return telemetry.Run(ctx, cmd)
 	default:
 		return fmt.Errorf("interactive shell not supported for runtime type: %s", runtime.GetType())
 	}
@@ -468,7 +506,7 @@ func (m *Manager) Attach(ctx context.Context, containerID string) error {
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
-		return cmd.Run()
+		return telemetry.Run(ctx, cmd)
 	default:
 		return fmt.Errorf("attach not supported for runtime type: %s", runtime.GetType())
 	}
@@ -502,7 +540,7 @@ func (m *Manager) CopyToContainer(ctx context.Context, containerID, srcPath, dst
 	case RuntimeTypeDocker:
 		args := []string{"cp", cleanSrcPath, fmt.Sprintf("%s:%s", containerID, cleanDstPath)}
 		cmd := m.factory.executor.CommandContext(ctx, "docker", args...)
-		output, err := cmd.CombinedOutput()
+		output, err := telemetry.CombinedOutput(ctx, cmd)
 		if err != nil {
 			return fmt.Errorf("failed to copy to container: %w, output: %s", err, string(output))
 		}
@@ -524,7 +562,7 @@ func (m *Manager) CopyFromContainer(ctx context.Context, containerID, srcPath, d
 	case RuntimeTypeDocker:
 		args := []string{"cp", fmt.Sprintf("%s:%s", containerID, srcPath), dstPath}
 		cmd := m.factory.executor.CommandContext(ctx, "docker", args...)
-		output, err := cmd.CombinedOutput()
+		output, err := telemetry.CombinedOutput(ctx, cmd)
 		if err != nil {
 			return fmt.Errorf("failed to copy from container: %w, output: %s", err, string(output))
 		}
@@ -545,7 +583,7 @@ func (m *Manager) Top(ctx context.Context, containerID string) ([]byte, error) {
 	switch runtime.GetType() {
 	case RuntimeTypeDocker:
 		cmd := m.factory.executor.CommandContext(ctx, "docker", "top", containerID)
-		return cmd.Output()
+		return telemetry.Output(ctx, cmd)
 	default:
 		return nil, fmt.Errorf("top not supported for runtime type: %s", runtime.GetType())
 	}
@@ -566,14 +604,17 @@ func (m *Manager) Port(ctx context.Context, containerID string, port string) ([]
 			args = append(args, port)
 		}
 		cmd := m.factory.executor.CommandContext(ctx, "docker", args...)
-		return cmd.Output()
+		return telemetry.Output(ctx, cmd)
 	default:
 		return nil, fmt.Errorf("port not supported for runtime type: %s", runtime.GetType())
 	}
 }
 
 // RunSetup executes setup commands or script in a container
-func (m *Manager) RunSetup(ctx context.Context, containerID string, projectPath string) error {
+func (m *Manager) RunSetup(ctx context.Context, containerID string, projectPath string) (err error) {
+	ctx, span := telemetry.Start(ctx, "container.RunSetup", attribute.String("vibeman.container_id", containerID))
+	defer func() { telemetry.End(span, err) }()
+
 	// Validate container ID
 	if err := validateContainerID(containerID); err != nil {
 		return fmt.Errorf("invalid container ID: %w", err)
@@ -623,8 +664,11 @@ func (m *Manager) RunSetup(ctx context.Context, containerID string, projectPath
 		if err := m.Start(ctx, containerID); err != nil {
 			return fmt.Errorf("failed to start container for setup: %w", err)
 		}
-		// Wait a moment for container to be ready
-		time.Sleep(2 * time.Second)
+	}
+
+	// Setup commands need the container to be ready, not just started
+	if err := m.checker.WaitReady(ctx, container.Health, containerID); err != nil {
+		return fmt.Errorf("container is not ready for setup: %w", err)
 	}
 
 	// Note: SetupScript removed in simplified approach - only inline setup commands supported
@@ -54,11 +54,25 @@ type CreateConfig struct {
 	WorkingDir  string
 	Repository  string
 	Environment string
-	Type        string   // Container type: "worktree", "service", "ai"
-	EnvVars     []string // Environment variables in KEY=VALUE format
-	Volumes     []string // Volume mounts in HOST:CONTAINER format
-	Ports       []string // Port mappings in HOST:CONTAINER format
-	Interactive bool     // Run container with -it flags
+	Type        string            // Container type: "worktree", "service", "ai"
+	EnvVars     []string          // Environment variables in KEY=VALUE format
+	Volumes     []string          // Volume mounts in HOST:CONTAINER format
+	Ports       []string          // Port mappings in HOST:CONTAINER format
+	Interactive bool              // Run container with -it flags
+	Command     []string          // Command to run instead of the image's default
+	Labels      map[string]string // Labels added to the vibeman labels
+
+	// Sandboxing, for containers that run untrusted code
+	ReadOnly     bool     // Mount the root filesystem read-only
+	Tmpfs        []string // Writable tmpfs mounts, e.g. /tmp
+	CapDrop      []string // Capabilities to drop, e.g. ALL
+	CapAdd       []string // Capabilities to keep after dropping
+	SecurityOpts []string // e.g. no-new-privileges or seccomp=<profile>
+	User         string   // uid:gid to run as instead of the image's user
+	UserNS       string   // User namespace mode
+	CPUs         string   // CPU limit, e.g. 2
+	Memory       string   // Memory limit, e.g. 4g
+	PidsLimit    int      // Process limit, 0 for none
 
 	// Docker Compose support
 	ComposeFile     string   // Path to docker-compose.yaml
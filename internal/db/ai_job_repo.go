@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AIJobRepository handles database operations for headless AI jobs
+type AIJobRepository struct {
+	db *DB
+}
+
+// NewAIJobRepository creates a new AI job repository
+func NewAIJobRepository(db *DB) *AIJobRepository {
+	return &AIJobRepository{db: db}
+}
+
+const aiJobColumns = `id, worktree_id, agent, prompt, status, exit_code, transcript, diff, error, created_at, updated_at, started_at, finished_at`
+
+// aiJobSummaryColumns leaves out the transcript and diff, which can be large
+const aiJobSummaryColumns = `id, worktree_id, agent, prompt, status, exit_code, '', '', error, created_at, updated_at, started_at, finished_at`
+
+// scanAIJob scans a single AI job row
+func scanAIJob(scanner interface{ Scan(...interface{}) error }) (*AIJob, error) {
+	var job AIJob
+	var exitCode sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+	err := scanner.Scan(
+		&job.ID,
+		&job.WorktreeID,
+		&job.Agent,
+		&job.Prompt,
+		&job.Status,
+		&exitCode,
+		&job.Transcript,
+		&job.Diff,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&startedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		job.ExitCode = &code
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// List returns AI jobs, newest first, with optional filtering by worktree and
+// status. The transcript and diff of each job are left out.
+func (r *AIJobRepository) List(ctx context.Context, worktreeID, status string) ([]*AIJob, error) {
+	query := `SELECT ` + aiJobSummaryColumns + ` FROM ai_jobs WHERE 1=1`
+	args := []interface{}{}
+
+	if worktreeID != "" {
+		query += " AND worktree_id = ?"
+		args = append(args, worktreeID)
+	}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	query += " ORDER BY created_at DESC, rowid DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AI jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*AIJob
+	for rows.Next() {
+		job, err := scanAIJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan AI job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating AI jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Get returns an AI job by ID, with its transcript and diff
+func (r *AIJobRepository) Get(ctx context.Context, id string) (*AIJob, error) {
+	query := `SELECT ` + aiJobColumns + ` FROM ai_jobs WHERE id = ?`
+
+	job, err := scanAIJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("AI job not found")
+		}
+		return nil, fmt.Errorf("failed to get AI job: %w", err)
+	}
+
+	return job, nil
+}
+
+// Create records a new AI job
+func (r *AIJobRepository) Create(ctx context.Context, job *AIJob) error {
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	job.UpdatedAt = job.CreatedAt
+
+	query := `
+		INSERT INTO ai_jobs (id, worktree_id, agent, prompt, status, error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		job.ID,
+		job.WorktreeID,
+		job.Agent,
+		job.Prompt,
+		job.Status,
+		job.Error,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create AI job: %w", err)
+	}
+
+	return nil
+}
+
+// Update persists the mutable fields of an AI job
+func (r *AIJobRepository) Update(ctx context.Context, job *AIJob) error {
+	query := `
+		UPDATE ai_jobs
+		SET status = ?, exit_code = ?, transcript = ?, diff = ?, error = ?, started_at = ?, finished_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`
+
+	var exitCode, startedAt, finishedAt interface{}
+	if job.ExitCode != nil {
+		exitCode = *job.ExitCode
+	}
+	if job.StartedAt != nil {
+		startedAt = *job.StartedAt
+	}
+	if job.FinishedAt != nil {
+		finishedAt = *job.FinishedAt
+	}
+
+	result, err := r.db.ExecContext(ctx, query,
+		job.Status,
+		exitCode,
+		job.Transcript,
+		job.Diff,
+		job.Error,
+		startedAt,
+		finishedAt,
+		job.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update AI job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("AI job not found")
+	}
+
+	return nil
+}
+
+// FailRunning marks all running AI jobs as failed. It is used at startup,
+// since a job's agent does not survive a server restart; queued jobs are
+// left to run.
+func (r *AIJobRepository) FailRunning(ctx context.Context, reason string) (int64, error) {
+	query := `
+		UPDATE ai_jobs
+		SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE status = ?`
+
+	result, err := r.db.ExecContext(ctx, query, AIJobFailed, reason, AIJobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update running AI jobs: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AuditRepository handles database operations for audit events
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditFilter selects audit events. Zero values match everything.
+type AuditFilter struct {
+	Target string    // Matches the target ID or name
+	Actor  string    // Matches the actor exactly
+	Action string    // Matches the action exactly, or a prefix ending in "." (e.g. "worktree.")
+	Since  time.Time // Only events at or after this time
+	Limit  int       // Maximum number of events; 0 means no limit
+}
+
+const auditEventColumns = `id, actor, source, action, target_type, target_id, target_name, parameters, outcome, error, created_at`
+
+// scanAuditEvent scans a single audit event row
+func scanAuditEvent(scanner interface{ Scan(...interface{}) error }) (*AuditEvent, error) {
+	event := &AuditEvent{}
+	err := scanner.Scan(
+		&event.ID,
+		&event.Actor,
+		&event.Source,
+		&event.Action,
+		&event.TargetType,
+		&event.TargetID,
+		&event.TargetName,
+		&event.Parameters,
+		&event.Outcome,
+		&event.Error,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// List returns audit events matching filter, newest first
+func (r *AuditRepository) List(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.Target != "" {
+		query += " AND (target_id = ? OR target_name = ?)"
+		args = append(args, filter.Target, filter.Target)
+	}
+
+	if filter.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, filter.Actor)
+	}
+
+	if filter.Action != "" {
+		if filter.Action[len(filter.Action)-1] == '.' {
+			query += " AND action LIKE ?"
+			args = append(args, filter.Action+"%")
+		} else {
+			query += " AND action = ?"
+			args = append(args, filter.Action)
+		}
+	}
+
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+
+	query += " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Create records an audit event. Timestamps are stored in UTC so events can
+// be compared by time.
+func (r *AuditRepository) Create(ctx context.Context, event *AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+
+	query := `
+		INSERT INTO audit_events (id, actor, source, action, target_type, target_id, target_name, parameters, outcome, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		event.ID,
+		event.Actor,
+		event.Source,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.TargetName,
+		event.Parameters,
+		event.Outcome,
+		event.Error,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteBefore deletes audit events older than cutoff and returns how many
+// were removed
+func (r *AuditRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_events WHERE created_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit events: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MCPTokenRepository handles database operations for the tokens agents call
+// vibeman's MCP server with
+type MCPTokenRepository struct {
+	db *DB
+}
+
+// NewMCPTokenRepository creates a new MCP token repository
+func NewMCPTokenRepository(db *DB) *MCPTokenRepository {
+	return &MCPTokenRepository{db: db}
+}
+
+// Set replaces the token of a worktree with the one hashing to tokenHash,
+// whose tools are limited to scope
+func (r *MCPTokenRepository) Set(ctx context.Context, worktreeID, tokenHash string, scope MCPScope) error {
+	query := `
+		INSERT INTO mcp_tokens (worktree_id, token_hash, services, test_command, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (worktree_id) DO UPDATE SET
+			token_hash = excluded.token_hash,
+			services = excluded.services,
+			test_command = excluded.test_command,
+			created_at = excluded.created_at
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, worktreeID, tokenHash, scope.Services, scope.TestCommand); err != nil {
+		return fmt.Errorf("failed to set mcp token: %w", err)
+	}
+
+	return nil
+}
+
+// GetWorktreeID returns the ID of the worktree whose token hashes to
+// tokenHash
+func (r *MCPTokenRepository) GetWorktreeID(ctx context.Context, tokenHash string) (string, error) {
+	var worktreeID string
+	err := r.db.QueryRowContext(ctx, `SELECT worktree_id FROM mcp_tokens WHERE token_hash = ?`, tokenHash).Scan(&worktreeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("mcp token not found")
+		}
+		return "", fmt.Errorf("failed to get mcp token: %w", err)
+	}
+
+	return worktreeID, nil
+}
+
+// GetScope returns what the tools of a worktree's token may touch
+func (r *MCPTokenRepository) GetScope(ctx context.Context, worktreeID string) (*MCPScope, error) {
+	var scope MCPScope
+	err := r.db.GetContext(ctx, &scope, `SELECT services, test_command FROM mcp_tokens WHERE worktree_id = ?`, worktreeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("mcp token not found")
+		}
+		return nil, fmt.Errorf("failed to get mcp token: %w", err)
+	}
+
+	return &scope, nil
+}
+
+// Delete revokes the token of a worktree
+func (r *MCPTokenRepository) Delete(ctx context.Context, worktreeID string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mcp_tokens WHERE worktree_id = ?`, worktreeID); err != nil {
+		return fmt.Errorf("failed to delete mcp token: %w", err)
+	}
+
+	return nil
+}
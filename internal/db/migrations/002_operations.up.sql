@@ -0,0 +1,23 @@
+-- Asynchronous operations with step-by-step progress
+
+CREATE TABLE IF NOT EXISTS operations (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,                -- e.g. create_worktree, start_worktree
+    target_id TEXT NOT NULL DEFAULT '', -- ID of the resource the operation acts on
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'cancelled')),
+    current_step TEXT NOT NULL DEFAULT '',
+    steps TEXT NOT NULL DEFAULT '[]',  -- JSON array of step progress
+    error TEXT NOT NULL DEFAULT '',
+    result TEXT,                       -- JSON result payload
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_operations_status ON operations(status);
+CREATE INDEX idx_operations_target_id ON operations(target_id);
+
+CREATE TRIGGER update_operations_updated_at AFTER UPDATE ON operations
+BEGIN
+    UPDATE operations SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
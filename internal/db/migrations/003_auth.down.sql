@@ -0,0 +1,5 @@
+-- Drop auth tables
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS api_tokens;
+DROP TRIGGER IF EXISTS update_users_updated_at;
+DROP TABLE IF EXISTS users;
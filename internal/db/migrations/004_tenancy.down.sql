@@ -0,0 +1,8 @@
+-- Drop tenancy tables and ownership columns
+DROP INDEX IF EXISTS idx_repositories_team_id;
+DROP INDEX IF EXISTS idx_team_members_user_id;
+ALTER TABLE worktrees DROP COLUMN owner_id;
+ALTER TABLE repositories DROP COLUMN team_id;
+ALTER TABLE repositories DROP COLUMN owner_id;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,25 @@
+-- Teams, team membership and ownership of repositories and worktrees
+
+CREATE TABLE IF NOT EXISTS teams (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'developer' CHECK (role IN ('viewer', 'developer', 'admin')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Empty owner/team means the row predates tenancy and is shared
+ALTER TABLE repositories ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE repositories ADD COLUMN team_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE worktrees ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_team_members_user_id ON team_members(user_id);
+CREATE INDEX idx_repositories_team_id ON repositories(team_id);
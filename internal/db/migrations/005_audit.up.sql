@@ -0,0 +1,19 @@
+-- Audit log of mutating actions
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id TEXT PRIMARY KEY,
+    actor TEXT NOT NULL DEFAULT '',      -- Username, OS user or component
+    source TEXT NOT NULL CHECK (source IN ('cli', 'api', 'scheduler')),
+    action TEXT NOT NULL,                -- e.g. worktree.delete, service.start
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    target_name TEXT NOT NULL DEFAULT '',
+    parameters TEXT,                     -- JSON object
+    outcome TEXT NOT NULL CHECK (outcome IN ('success', 'failure')),
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX idx_audit_events_target_id ON audit_events(target_id);
+CREATE INDEX idx_audit_events_target_name ON audit_events(target_name);
@@ -0,0 +1,2 @@
+-- Drop per-worktree service resources
+DROP TABLE IF EXISTS service_resources;
@@ -0,0 +1,15 @@
+-- Isolated resources provisioned for worktrees on shared services
+
+CREATE TABLE IF NOT EXISTS service_resources (
+    id TEXT PRIMARY KEY,
+    worktree_id TEXT NOT NULL,
+    service TEXT NOT NULL,                -- Service name from services.toml
+    type TEXT NOT NULL,                   -- postgres, redis or s3
+    name TEXT NOT NULL,                   -- Database, key prefix or bucket
+    db_index INTEGER NOT NULL DEFAULT -1, -- Redis logical database, -1 when unused
+    env TEXT NOT NULL DEFAULT '{}',       -- Connection details injected into the worktree
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (worktree_id, service)
+);
+
+CREATE INDEX idx_service_resources_service ON service_resources(service);
@@ -0,0 +1,2 @@
+-- Drop persistent service references
+DROP TABLE IF EXISTS service_references;
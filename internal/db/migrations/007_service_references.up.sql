@@ -0,0 +1,10 @@
+-- Persistent service reference counts: who is using each shared service
+
+CREATE TABLE IF NOT EXISTS service_references (
+    service TEXT NOT NULL, -- Service name from services.toml
+    holder TEXT NOT NULL,  -- Worktree ID, or repository name for repository-wide references
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (service, holder)
+);
+
+CREATE INDEX idx_service_references_holder ON service_references(holder);
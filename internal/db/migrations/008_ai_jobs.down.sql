@@ -0,0 +1,2 @@
+-- Drop AI jobs
+DROP TABLE IF EXISTS ai_jobs;
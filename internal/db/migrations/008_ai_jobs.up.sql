@@ -0,0 +1,26 @@
+-- Headless AI agent runs queued against worktrees
+
+CREATE TABLE IF NOT EXISTS ai_jobs (
+    id TEXT PRIMARY KEY,
+    worktree_id TEXT NOT NULL,
+    agent TEXT NOT NULL,                  -- Agent provider, e.g. claude
+    prompt TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+    exit_code INTEGER,                    -- Exit code of the agent, once it ran
+    transcript TEXT NOT NULL DEFAULT '', -- Combined output of the agent
+    diff TEXT NOT NULL DEFAULT '',       -- Changes the agent made to the worktree
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    FOREIGN KEY (worktree_id) REFERENCES worktrees(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ai_jobs_worktree_id ON ai_jobs(worktree_id);
+CREATE INDEX idx_ai_jobs_status ON ai_jobs(status);
+
+CREATE TRIGGER update_ai_jobs_updated_at AFTER UPDATE ON ai_jobs
+BEGIN
+    UPDATE ai_jobs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
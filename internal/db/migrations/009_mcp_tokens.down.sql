@@ -0,0 +1,2 @@
+-- Drop MCP tokens
+DROP TABLE IF EXISTS mcp_tokens;
@@ -0,0 +1,12 @@
+-- Tokens the agents in AI containers call vibeman's MCP server with, one per
+-- worktree. Only the hash of a token is stored, with what the token's tools
+-- may touch as the host saw it when the token was issued.
+
+CREATE TABLE IF NOT EXISTS mcp_tokens (
+    worktree_id TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    services TEXT NOT NULL DEFAULT '[]', -- JSON array of service names
+    test_command TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (worktree_id) REFERENCES worktrees(id) ON DELETE CASCADE
+);
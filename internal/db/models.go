@@ -50,6 +50,8 @@ type Repository struct {
 	Path        string    `json:"path" db:"path"` // Local filesystem path
 	Name        string    `json:"name" db:"name"`
 	Description string    `json:"description" db:"description"`
+	OwnerID     string    `json:"owner_id,omitempty" db:"owner_id"` // User who added the repository
+	TeamID      string    `json:"team_id,omitempty" db:"team_id"`   // Team the repository is shared with
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -78,6 +80,7 @@ type Worktree struct {
 	Branch       string         `json:"branch" db:"branch"`
 	Path         string         `json:"path" db:"path"` // Filesystem path to worktree
 	Status       WorktreeStatus `json:"status" db:"status"`
+	OwnerID      string         `json:"owner_id,omitempty" db:"owner_id"` // User who created the worktree
 	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
 }
@@ -86,3 +89,384 @@ type Worktree struct {
 func (Worktree) TableName() string {
 	return "worktrees"
 }
+
+// OperationStatus represents the state of an asynchronous operation
+type OperationStatus string
+
+const (
+	OperationPending   OperationStatus = "pending"
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+	OperationCancelled OperationStatus = "cancelled"
+)
+
+// IsFinished reports whether the operation has reached a terminal state
+func (s OperationStatus) IsFinished() bool {
+	return s == OperationSucceeded || s == OperationFailed || s == OperationCancelled
+}
+
+// OperationStep records the progress of a single step of an operation
+type OperationStep struct {
+	Name       string     `json:"name"`
+	Message    string     `json:"message,omitempty"`
+	Status     string     `json:"status"` // running, completed, failed, skipped
+	Current    int        `json:"current,omitempty"`
+	Total      int        `json:"total,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// OperationSteps is a JSON column holding the steps of an operation
+type OperationSteps []OperationStep
+
+// Value implements the driver.Valuer interface
+func (s OperationSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (s *OperationSteps) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("type assertion to []byte or string failed")
+	}
+	if len(data) == 0 {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(data, s)
+}
+
+// Operation represents a long-running operation executed in the background
+type Operation struct {
+	ID          string          `json:"id" db:"id"`
+	Type        string          `json:"type" db:"type"`
+	TargetID    string          `json:"target_id" db:"target_id"`
+	Status      OperationStatus `json:"status" db:"status"`
+	CurrentStep string          `json:"current_step" db:"current_step"`
+	Steps       OperationSteps  `json:"steps" db:"steps"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	Result      JSONB           `json:"result,omitempty" db:"result"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// TableName returns the table name for Operation
+func (Operation) TableName() string {
+	return "operations"
+}
+
+// StringList is a JSON column holding a list of strings
+type StringList []string
+
+// Value implements the driver.Valuer interface
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("type assertion to []byte or string failed")
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, l)
+}
+
+// StringMap is a JSON column holding string key/value pairs
+type StringMap map[string]string
+
+// Value implements the driver.Valuer interface
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (m *StringMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("type assertion to []byte or string failed")
+	}
+	if len(data) == 0 {
+		*m = nil
+		return nil
+	}
+	return json.Unmarshal(data, m)
+}
+
+// UserRole represents the role of a user
+type UserRole string
+
+const (
+	RoleAdmin UserRole = "admin"
+	RoleUser  UserRole = "user"
+)
+
+// User represents a local user account
+type User struct {
+	ID           string    `json:"id" db:"id"`
+	Username     string    `json:"username" db:"username"`
+	Email        string    `json:"email,omitempty" db:"email"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	Role         UserRole  `json:"role" db:"role"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TableName returns the table name for User
+func (User) TableName() string {
+	return "users"
+}
+
+// APIToken represents a personal API token. Only the hash of the token is stored.
+type APIToken struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     StringList `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for APIToken
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// Session represents a login session backing short-lived session tokens
+type Session struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for Session
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// TeamRole represents the role of a user within a team
+type TeamRole string
+
+const (
+	TeamRoleViewer    TeamRole = "viewer"
+	TeamRoleDeveloper TeamRole = "developer"
+	TeamRoleAdmin     TeamRole = "admin"
+)
+
+// teamRoleRank orders team roles; a role can do everything a lower one can
+var teamRoleRank = map[TeamRole]int{
+	TeamRoleViewer:    1,
+	TeamRoleDeveloper: 2,
+	TeamRoleAdmin:     3,
+}
+
+// IsValid reports whether the role is a known team role
+func (r TeamRole) IsValid() bool {
+	_, ok := teamRoleRank[r]
+	return ok
+}
+
+// AtLeast reports whether the role grants everything min does
+func (r TeamRole) AtLeast(min TeamRole) bool {
+	return r.IsValid() && teamRoleRank[r] >= teamRoleRank[min]
+}
+
+// Team represents a group of users sharing repositories
+type Team struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for Team
+func (Team) TableName() string {
+	return "teams"
+}
+
+// TeamMember represents a user's membership in a team
+type TeamMember struct {
+	TeamID    string    `json:"team_id" db:"team_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Username  string    `json:"username,omitempty" db:"username"`
+	Role      TeamRole  `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for TeamMember
+func (TeamMember) TableName() string {
+	return "team_members"
+}
+
+// AuditSource identifies where an audited action originated
+type AuditSource string
+
+const (
+	AuditSourceCLI       AuditSource = "cli"
+	AuditSourceAPI       AuditSource = "api"
+	AuditSourceScheduler AuditSource = "scheduler"
+)
+
+// AuditOutcome is the result of an audited action
+type AuditOutcome string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEvent records a mutating action: who did what to which resource, and
+// whether it succeeded
+type AuditEvent struct {
+	ID         string       `json:"id" db:"id"`
+	Actor      string       `json:"actor" db:"actor"`
+	Source     AuditSource  `json:"source" db:"source"`
+	Action     string       `json:"action" db:"action"`
+	TargetType string       `json:"target_type" db:"target_type"`
+	TargetID   string       `json:"target_id,omitempty" db:"target_id"`
+	TargetName string       `json:"target_name,omitempty" db:"target_name"`
+	Parameters JSONB        `json:"parameters,omitempty" db:"parameters"`
+	Outcome    AuditOutcome `json:"outcome" db:"outcome"`
+	Error      string       `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for AuditEvent
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// ServiceResource is an isolated resource, such as a database, provisioned
+// for one worktree on a shared service
+type ServiceResource struct {
+	ID         string    `json:"id" db:"id"`
+	WorktreeID string    `json:"worktree_id" db:"worktree_id"`
+	Service    string    `json:"service" db:"service"`
+	Type       string    `json:"type" db:"type"`
+	Name       string    `json:"name" db:"name"`      // Database, key prefix or bucket
+	Index      int       `json:"index" db:"db_index"` // Redis logical database; -1 when unused
+	Env        StringMap `json:"env" db:"env"`        // Connection details injected into the worktree
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for ServiceResource
+func (ServiceResource) TableName() string {
+	return "service_resources"
+}
+
+// MCPScope is what the MCP tools of a worktree's agents may touch. It is
+// taken from the worktree's configuration when its token is issued, on the
+// host, so agents cannot widen it by editing the configuration they run next
+// to.
+type MCPScope struct {
+	Services    StringList `json:"services" db:"services"`
+	TestCommand string     `json:"test_command" db:"test_command"`
+}
+
+// ServiceReference records that a worktree or repository uses a shared
+// service. Services with no references can be stopped.
+type ServiceReference struct {
+	Service   string    `json:"service" db:"service"`
+	Holder    string    `json:"holder" db:"holder"` // Worktree ID or repository name
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table name for ServiceReference
+func (ServiceReference) TableName() string {
+	return "service_references"
+}
+
+// AIJobStatus represents the state of a headless AI job
+type AIJobStatus string
+
+const (
+	AIJobQueued    AIJobStatus = "queued"
+	AIJobRunning   AIJobStatus = "running"
+	AIJobSucceeded AIJobStatus = "succeeded"
+	AIJobFailed    AIJobStatus = "failed"
+	AIJobCancelled AIJobStatus = "cancelled"
+)
+
+// IsFinished reports whether the job has reached a terminal state
+func (s AIJobStatus) IsFinished() bool {
+	return s == AIJobSucceeded || s == AIJobFailed || s == AIJobCancelled
+}
+
+// AIJob is a non-interactive agent run against a worktree: the agent is
+// handed a prompt in the worktree's AI container and left to work
+type AIJob struct {
+	ID         string      `json:"id" db:"id"`
+	WorktreeID string      `json:"worktree_id" db:"worktree_id"`
+	Agent      string      `json:"agent" db:"agent"`
+	Prompt     string      `json:"prompt" db:"prompt"`
+	Status     AIJobStatus `json:"status" db:"status"`
+	ExitCode   *int        `json:"exit_code,omitempty" db:"exit_code"`
+	Transcript string      `json:"transcript,omitempty" db:"transcript"` // Combined output of the agent
+	Diff       string      `json:"diff,omitempty" db:"diff"`             // Changes the agent made to the worktree
+	Error      string      `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at" db:"updated_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// TableName returns the table name for AIJob
+func (AIJob) TableName() string {
+	return "ai_jobs"
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// OperationRepository handles database operations for asynchronous operations
+type OperationRepository struct {
+	db *DB
+}
+
+// NewOperationRepository creates a new operation repository
+func NewOperationRepository(db *DB) *OperationRepository {
+	return &OperationRepository{db: db}
+}
+
+const operationColumns = `id, type, target_id, status, current_step, steps, error, result, created_at, updated_at, finished_at`
+
+// scanOperation scans a single operation row
+func scanOperation(scanner interface{ Scan(...interface{}) error }) (*Operation, error) {
+	var op Operation
+	var finishedAt sql.NullTime
+	err := scanner.Scan(
+		&op.ID,
+		&op.Type,
+		&op.TargetID,
+		&op.Status,
+		&op.CurrentStep,
+		&op.Steps,
+		&op.Error,
+		&op.Result,
+		&op.CreatedAt,
+		&op.UpdatedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		op.FinishedAt = &finishedAt.Time
+	}
+	return &op, nil
+}
+
+// List returns operations with optional filtering by status and target
+func (r *OperationRepository) List(ctx context.Context, status, targetID string) ([]*Operation, error) {
+	query := `SELECT ` + operationColumns + ` FROM operations WHERE 1=1`
+	args := []interface{}{}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	if targetID != "" {
+		query += " AND target_id = ?"
+		args = append(args, targetID)
+	}
+
+	query += " ORDER BY created_at DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query operations: %w", err)
+	}
+	defer rows.Close()
+
+	var operations []*Operation
+	for rows.Next() {
+		op, err := scanOperation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan operation: %w", err)
+		}
+		operations = append(operations, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating operations: %w", err)
+	}
+
+	return operations, nil
+}
+
+// Get returns an operation by ID
+func (r *OperationRepository) Get(ctx context.Context, id string) (*Operation, error) {
+	query := `SELECT ` + operationColumns + ` FROM operations WHERE id = ?`
+
+	op, err := scanOperation(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("operation not found")
+		}
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+
+	return op, nil
+}
+
+// Create creates a new operation
+func (r *OperationRepository) Create(ctx context.Context, op *Operation) error {
+	query := `
+		INSERT INTO operations (id, type, target_id, status, current_step, steps, error, result, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		op.ID,
+		op.Type,
+		op.TargetID,
+		op.Status,
+		op.CurrentStep,
+		op.Steps,
+		op.Error,
+		op.Result,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	return nil
+}
+
+// Update persists the mutable fields of an operation
+func (r *OperationRepository) Update(ctx context.Context, op *Operation) error {
+	query := `
+		UPDATE operations
+		SET target_id = ?, status = ?, current_step = ?, steps = ?, error = ?, result = ?, finished_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`
+
+	var finishedAt interface{}
+	if op.FinishedAt != nil {
+		finishedAt = *op.FinishedAt
+	}
+
+	result, err := r.db.ExecContext(ctx, query,
+		op.TargetID,
+		op.Status,
+		op.CurrentStep,
+		op.Steps,
+		op.Error,
+		op.Result,
+		finishedAt,
+		op.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update operation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("operation not found")
+	}
+
+	return nil
+}
+
+// FailUnfinished marks all pending or running operations as failed. It is
+// used at startup, since operations do not survive a server restart.
+func (r *OperationRepository) FailUnfinished(ctx context.Context, reason string) (int64, error) {
+	query := `
+		UPDATE operations
+		SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE status IN (?, ?)`
+
+	result, err := r.db.ExecContext(ctx, query, OperationFailed, reason, OperationPending, OperationRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update unfinished operations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
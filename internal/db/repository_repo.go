@@ -31,7 +31,7 @@ func NewRepositoryRepository(db *DB) *RepositoryRepository {
 // List returns all tracked repositories
 func (r *RepositoryRepository) List(ctx context.Context) ([]*Repository, error) {
 	query := `
-		SELECT id, path, name, description, created_at, updated_at
+		SELECT id, path, name, description, owner_id, team_id, created_at, updated_at
 		FROM repositories
 		ORDER BY name ASC
 	`
@@ -50,6 +50,8 @@ func (r *RepositoryRepository) List(ctx context.Context) ([]*Repository, error)
 			&repo.Path,
 			&repo.Name,
 			&repo.Description,
+			&repo.OwnerID,
+			&repo.TeamID,
 			&repo.CreatedAt,
 			&repo.UpdatedAt,
 		)
@@ -69,7 +71,7 @@ func (r *RepositoryRepository) List(ctx context.Context) ([]*Repository, error)
 // GetByID returns a repository by ID
 func (r *RepositoryRepository) GetByID(ctx context.Context, id string) (*Repository, error) {
 	query := `
-		SELECT id, path, name, description, created_at, updated_at
+		SELECT id, path, name, description, owner_id, team_id, created_at, updated_at
 		FROM repositories
 		WHERE id = ?
 	`
@@ -80,6 +82,8 @@ func (r *RepositoryRepository) GetByID(ctx context.Context, id string) (*Reposit
 		&repo.Path,
 		&repo.Name,
 		&repo.Description,
+		&repo.OwnerID,
+		&repo.TeamID,
 		&repo.CreatedAt,
 		&repo.UpdatedAt,
 	)
@@ -96,7 +100,7 @@ func (r *RepositoryRepository) GetByID(ctx context.Context, id string) (*Reposit
 // GetByPath returns a repository by path
 func (r *RepositoryRepository) GetByPath(ctx context.Context, path string) (*Repository, error) {
 	query := `
-		SELECT id, path, name, description, created_at, updated_at
+		SELECT id, path, name, description, owner_id, team_id, created_at, updated_at
 		FROM repositories
 		WHERE path = ?
 	`
@@ -107,6 +111,8 @@ func (r *RepositoryRepository) GetByPath(ctx context.Context, path string) (*Rep
 		&repo.Path,
 		&repo.Name,
 		&repo.Description,
+		&repo.OwnerID,
+		&repo.TeamID,
 		&repo.CreatedAt,
 		&repo.UpdatedAt,
 	)
@@ -136,11 +142,11 @@ func (r *RepositoryRepository) ExistsWithPath(ctx context.Context, path string)
 // Create creates a new repository
 func (r *RepositoryRepository) Create(ctx context.Context, repo *Repository) error {
 	query := `
-		INSERT INTO repositories (id, path, name, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO repositories (id, path, name, description, owner_id, team_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
 
-	_, err := r.db.ExecContext(ctx, query, repo.ID, repo.Path, repo.Name, repo.Description)
+	_, err := r.db.ExecContext(ctx, query, repo.ID, repo.Path, repo.Name, repo.Description, repo.OwnerID, repo.TeamID)
 	if err != nil {
 		return fmt.Errorf("failed to create repository: %w", err)
 	}
@@ -172,6 +178,30 @@ func (r *RepositoryRepository) Update(ctx context.Context, repo *Repository) err
 	return nil
 }
 
+// SetTeam assigns a repository to a team; an empty team ID clears it
+func (r *RepositoryRepository) SetTeam(ctx context.Context, id, teamID string) error {
+	query := `
+		UPDATE repositories
+		SET team_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	result, err := r.db.ExecContext(ctx, query, teamID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update repository team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository not found")
+	}
+
+	return nil
+}
+
 // Delete deletes a repository
 func (r *RepositoryRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM repositories WHERE id = ?`
@@ -211,7 +241,7 @@ func (r *RepositoryRepository) ListRepositories(ctx context.Context) ([]*Reposit
 // GetRepositoryByName returns a repository by name
 func (r *RepositoryRepository) GetRepositoryByName(ctx context.Context, name string) (*Repository, error) {
 	query := `
-		SELECT id, path, name, description, created_at, updated_at
+		SELECT id, path, name, description, owner_id, team_id, created_at, updated_at
 		FROM repositories
 		WHERE name = ?
 	`
@@ -222,6 +252,8 @@ func (r *RepositoryRepository) GetRepositoryByName(ctx context.Context, name str
 		&repo.Path,
 		&repo.Name,
 		&repo.Description,
+		&repo.OwnerID,
+		&repo.TeamID,
 		&repo.CreatedAt,
 		&repo.UpdatedAt,
 	)
@@ -253,7 +285,7 @@ func (r *RepositoryRepository) DeleteRepository(ctx context.Context, id string)
 // GetWorktreesByRepository returns all worktrees for a repository
 func (r *RepositoryRepository) GetWorktreesByRepository(ctx context.Context, repoID string) ([]*Worktree, error) {
 	query := `
-		SELECT id, repository_id, name, branch, path, created_at, updated_at
+		SELECT id, repository_id, name, branch, path, owner_id, created_at, updated_at
 		FROM worktrees
 		WHERE repository_id = ?
 		ORDER BY name ASC
@@ -274,6 +306,7 @@ func (r *RepositoryRepository) GetWorktreesByRepository(ctx context.Context, rep
 			&wt.Name,
 			&wt.Branch,
 			&wt.Path,
+			&wt.OwnerID,
 			&wt.CreatedAt,
 			&wt.UpdatedAt,
 		)
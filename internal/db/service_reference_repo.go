@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServiceReferenceRepository handles database operations for service
+// references
+type ServiceReferenceRepository struct {
+	db *DB
+}
+
+// NewServiceReferenceRepository creates a new service reference repository
+func NewServiceReferenceRepository(db *DB) *ServiceReferenceRepository {
+	return &ServiceReferenceRepository{db: db}
+}
+
+// Add records that holder uses a service. Adding an existing reference is a
+// no-op.
+func (r *ServiceReferenceRepository) Add(ctx context.Context, service, holder string) error {
+	query := `INSERT OR IGNORE INTO service_references (service, holder) VALUES (?, ?)`
+
+	if _, err := r.db.ExecContext(ctx, query, service, holder); err != nil {
+		return fmt.Errorf("failed to add service reference: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes a reference and reports whether it existed
+func (r *ServiceReferenceRepository) Remove(ctx context.Context, service, holder string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM service_references WHERE service = ? AND holder = ?`, service, holder)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove service reference: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// RemoveHolder deletes every reference holder has, whichever services they
+// are to
+func (r *ServiceReferenceRepository) RemoveHolder(ctx context.Context, holder string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM service_references WHERE holder = ?`, holder); err != nil {
+		return fmt.Errorf("failed to remove service references: %w", err)
+	}
+	return nil
+}
+
+// ListHolders returns who uses a service, oldest first
+func (r *ServiceReferenceRepository) ListHolders(ctx context.Context, service string) ([]string, error) {
+	var holders []string
+	query := `SELECT holder FROM service_references WHERE service = ? ORDER BY created_at, holder`
+
+	if err := r.db.SelectContext(ctx, &holders, query, service); err != nil {
+		return nil, fmt.Errorf("failed to list service references: %w", err)
+	}
+	return holders, nil
+}
+
+// List returns every service reference
+func (r *ServiceReferenceRepository) List(ctx context.Context) ([]*ServiceReference, error) {
+	var references []*ServiceReference
+	query := `SELECT service, holder, created_at FROM service_references ORDER BY service, created_at, holder`
+
+	if err := r.db.SelectContext(ctx, &references, query); err != nil {
+		return nil, fmt.Errorf("failed to list service references: %w", err)
+	}
+	return references, nil
+}
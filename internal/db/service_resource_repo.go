@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ServiceResourceRepository handles database operations for per-worktree
+// service resources
+type ServiceResourceRepository struct {
+	db *DB
+}
+
+// NewServiceResourceRepository creates a new service resource repository
+func NewServiceResourceRepository(db *DB) *ServiceResourceRepository {
+	return &ServiceResourceRepository{db: db}
+}
+
+const serviceResourceColumns = `id, worktree_id, service, type, name, db_index, env, created_at`
+
+// scanServiceResource scans a single service resource row
+func scanServiceResource(scanner interface{ Scan(...interface{}) error }) (*ServiceResource, error) {
+	resource := &ServiceResource{}
+	err := scanner.Scan(
+		&resource.ID,
+		&resource.WorktreeID,
+		&resource.Service,
+		&resource.Type,
+		&resource.Name,
+		&resource.Index,
+		&resource.Env,
+		&resource.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// Get returns the resource provisioned for a worktree on a service
+func (r *ServiceResourceRepository) Get(ctx context.Context, worktreeID, service string) (*ServiceResource, error) {
+	query := `SELECT ` + serviceResourceColumns + ` FROM service_resources WHERE worktree_id = ? AND service = ?`
+
+	resource, err := scanServiceResource(r.db.QueryRowContext(ctx, query, worktreeID, service))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("service resource not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service resource: %w", err)
+	}
+
+	return resource, nil
+}
+
+// ListByWorktree returns the resources provisioned for a worktree
+func (r *ServiceResourceRepository) ListByWorktree(ctx context.Context, worktreeID string) ([]*ServiceResource, error) {
+	return r.list(ctx, `WHERE worktree_id = ? ORDER BY service`, worktreeID)
+}
+
+// ListByService returns the resources provisioned on a service
+func (r *ServiceResourceRepository) ListByService(ctx context.Context, service string) ([]*ServiceResource, error) {
+	return r.list(ctx, `WHERE service = ? ORDER BY created_at`, service)
+}
+
+// list returns the service resources matching a WHERE clause
+func (r *ServiceResourceRepository) list(ctx context.Context, where string, args ...interface{}) ([]*ServiceResource, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+serviceResourceColumns+` FROM service_resources `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service resources: %w", err)
+	}
+	defer rows.Close()
+
+	var resources []*ServiceResource
+	for rows.Next() {
+		resource, err := scanServiceResource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service resource: %w", err)
+		}
+		resources = append(resources, resource)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating service resources: %w", err)
+	}
+
+	return resources, nil
+}
+
+// Create records a provisioned service resource
+func (r *ServiceResourceRepository) Create(ctx context.Context, resource *ServiceResource) error {
+	if resource.CreatedAt.IsZero() {
+		resource.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO service_resources (id, worktree_id, service, type, name, db_index, env, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		resource.ID,
+		resource.WorktreeID,
+		resource.Service,
+		resource.Type,
+		resource.Name,
+		resource.Index,
+		resource.Env,
+		resource.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create service resource: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a service resource record
+func (r *ServiceResourceRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM service_resources WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete service resource: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("service resource not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TeamRepository handles database operations for teams and their members
+type TeamRepository struct {
+	db *DB
+}
+
+// NewTeamRepository creates a new team repository
+func NewTeamRepository(db *DB) *TeamRepository {
+	return &TeamRepository{db: db}
+}
+
+// List returns all teams
+func (r *TeamRepository) List(ctx context.Context) ([]*Team, error) {
+	return r.queryTeams(ctx, `SELECT id, name, created_at FROM teams ORDER BY name ASC`)
+}
+
+// ListByUser returns the teams a user belongs to
+func (r *TeamRepository) ListByUser(ctx context.Context, userID string) ([]*Team, error) {
+	query := `
+		SELECT t.id, t.name, t.created_at
+		FROM teams t
+		JOIN team_members m ON m.team_id = t.id
+		WHERE m.user_id = ?
+		ORDER BY t.name ASC
+	`
+	return r.queryTeams(ctx, query, userID)
+}
+
+// queryTeams runs a query returning team rows
+func (r *TeamRepository) queryTeams(ctx context.Context, query string, args ...interface{}) ([]*Team, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query teams: %w", err)
+	}
+	defer rows.Close()
+
+	var teams []*Team
+	for rows.Next() {
+		team := &Team{}
+		if err := rows.Scan(&team.ID, &team.Name, &team.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan team: %w", err)
+		}
+		teams = append(teams, team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating teams: %w", err)
+	}
+
+	return teams, nil
+}
+
+// Get returns a team by ID
+func (r *TeamRepository) Get(ctx context.Context, id string) (*Team, error) {
+	query := `SELECT id, name, created_at FROM teams WHERE id = ?`
+
+	team := &Team{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&team.ID, &team.Name, &team.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("team not found")
+		}
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+
+	return team, nil
+}
+
+// Create creates a new team
+func (r *TeamRepository) Create(ctx context.Context, team *Team) error {
+	query := `INSERT INTO teams (id, name, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
+
+	if _, err := r.db.ExecContext(ctx, query, team.ID, team.Name); err != nil {
+		return fmt.Errorf("failed to create team: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a team and its memberships
+func (r *TeamRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM teams WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("team not found")
+	}
+
+	return nil
+}
+
+// ListMembers returns the members of a team
+func (r *TeamRepository) ListMembers(ctx context.Context, teamID string) ([]*TeamMember, error) {
+	query := `
+		SELECT m.team_id, m.user_id, u.username, m.role, m.created_at
+		FROM team_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = ?
+		ORDER BY u.username ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*TeamMember
+	for rows.Next() {
+		member := &TeamMember{}
+		if err := rows.Scan(&member.TeamID, &member.UserID, &member.Username, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan team member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating team members: %w", err)
+	}
+
+	return members, nil
+}
+
+// GetMemberRole returns the role of a user in a team
+func (r *TeamRepository) GetMemberRole(ctx context.Context, teamID, userID string) (TeamRole, error) {
+	query := `SELECT role FROM team_members WHERE team_id = ? AND user_id = ?`
+
+	var role TeamRole
+	err := r.db.QueryRowContext(ctx, query, teamID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("team member not found")
+		}
+		return "", fmt.Errorf("failed to get team member: %w", err)
+	}
+
+	return role, nil
+}
+
+// ListMemberships returns a map of team ID to role for a user
+func (r *TeamRepository) ListMemberships(ctx context.Context, userID string) (map[string]TeamRole, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT team_id, role FROM team_members WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team memberships: %w", err)
+	}
+	defer rows.Close()
+
+	memberships := make(map[string]TeamRole)
+	for rows.Next() {
+		var teamID string
+		var role TeamRole
+		if err := rows.Scan(&teamID, &role); err != nil {
+			return nil, fmt.Errorf("failed to scan team membership: %w", err)
+		}
+		memberships[teamID] = role
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating team memberships: %w", err)
+	}
+
+	return memberships, nil
+}
+
+// SetMember adds a user to a team or updates their role
+func (r *TeamRepository) SetMember(ctx context.Context, teamID, userID string, role TeamRole) error {
+	query := `
+		INSERT INTO team_members (team_id, user_id, role, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (team_id, user_id) DO UPDATE SET role = excluded.role
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, teamID, userID, role); err != nil {
+		return fmt.Errorf("failed to set team member: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveMember removes a user from a team
+func (r *TeamRepository) RemoveMember(ctx context.Context, teamID, userID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove team member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("team member not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// APITokenRepository handles database operations for personal API tokens
+type APITokenRepository struct {
+	db *DB
+}
+
+// NewAPITokenRepository creates a new API token repository
+func NewAPITokenRepository(db *DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+const apiTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at`
+
+// scanAPIToken scans a single API token row
+func scanAPIToken(scanner interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	token := &APIToken{}
+	var expiresAt, lastUsedAt sql.NullTime
+	err := scanner.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
+
+// ListByUser returns the API tokens of a user
+func (r *APITokenRepository) ListByUser(ctx context.Context, userID string) ([]*APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// GetByHash returns an API token by the hash of its value
+func (r *APITokenRepository) GetByHash(ctx context.Context, tokenHash string) (*APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE token_hash = ?`
+
+	token, err := scanAPIToken(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("api token not found")
+		}
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Create creates a new API token
+func (r *APITokenRepository) Create(ctx context.Context, token *APIToken) error {
+	query := `
+		INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`
+
+	var expiresAt interface{}
+	if token.ExpiresAt != nil {
+		expiresAt = *token.ExpiresAt
+	}
+
+	_, err := r.db.ExecContext(ctx, query, token.ID, token.UserID, token.Name, token.TokenHash, token.Scopes, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api token: %w", err)
+	}
+
+	return nil
+}
+
+// TouchLastUsed records that a token was used
+func (r *APITokenRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, at, id); err != nil {
+		return fmt.Errorf("failed to update api token: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes an API token owned by a user
+func (r *APITokenRepository) Delete(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete api token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("api token not found")
+	}
+
+	return nil
+}
@@ -19,7 +19,7 @@ func NewWorktreeRepository(db *DB) *WorktreeRepository {
 // List returns worktrees with optional filtering
 func (r *WorktreeRepository) List(ctx context.Context, repositoryID, status string) ([]Worktree, error) {
 	query := `
-		SELECT id, repository_id, name, branch, path, status, created_at, updated_at
+		SELECT id, repository_id, name, branch, path, status, owner_id, created_at, updated_at
 		FROM worktrees 
 		WHERE 1=1`
 	args := []interface{}{}
@@ -52,6 +52,7 @@ func (r *WorktreeRepository) List(ctx context.Context, repositoryID, status stri
 			&w.Branch,
 			&w.Path,
 			&w.Status,
+			&w.OwnerID,
 			&w.CreatedAt,
 			&w.UpdatedAt,
 		)
@@ -71,7 +72,7 @@ func (r *WorktreeRepository) List(ctx context.Context, repositoryID, status stri
 // Get returns a worktree by ID
 func (r *WorktreeRepository) Get(ctx context.Context, id string) (*Worktree, error) {
 	query := `
-		SELECT id, repository_id, name, branch, path, status, created_at, updated_at
+		SELECT id, repository_id, name, branch, path, status, owner_id, created_at, updated_at
 		FROM worktrees 
 		WHERE id = ?`
 
@@ -83,6 +84,7 @@ func (r *WorktreeRepository) Get(ctx context.Context, id string) (*Worktree, err
 		&w.Branch,
 		&w.Path,
 		&w.Status,
+		&w.OwnerID,
 		&w.CreatedAt,
 		&w.UpdatedAt,
 	)
@@ -99,7 +101,7 @@ func (r *WorktreeRepository) Get(ctx context.Context, id string) (*Worktree, err
 // GetByPath returns a worktree by its filesystem path
 func (r *WorktreeRepository) GetByPath(ctx context.Context, path string) (*Worktree, error) {
 	query := `
-		SELECT id, repository_id, name, branch, path, status, created_at, updated_at
+		SELECT id, repository_id, name, branch, path, status, owner_id, created_at, updated_at
 		FROM worktrees 
 		WHERE path = ?`
 
@@ -111,6 +113,7 @@ func (r *WorktreeRepository) GetByPath(ctx context.Context, path string) (*Workt
 		&w.Branch,
 		&w.Path,
 		&w.Status,
+		&w.OwnerID,
 		&w.CreatedAt,
 		&w.UpdatedAt,
 	)
@@ -127,8 +130,8 @@ func (r *WorktreeRepository) GetByPath(ctx context.Context, path string) (*Workt
 // Create creates a new worktree
 func (r *WorktreeRepository) Create(ctx context.Context, worktree *Worktree) error {
 	query := `
-		INSERT INTO worktrees (id, repository_id, name, branch, path, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+		INSERT INTO worktrees (id, repository_id, name, branch, path, status, owner_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
 
 	_, err := r.db.ExecContext(ctx, query,
 		worktree.ID,
@@ -137,6 +140,7 @@ func (r *WorktreeRepository) Create(ctx context.Context, worktree *Worktree) err
 		worktree.Branch,
 		worktree.Path,
 		worktree.Status,
+		worktree.OwnerID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create worktree: %w", err)
@@ -143,13 +143,13 @@ func (e *VibemanError) GetHTTPStatus() int {
 	switch e.Code {
 	case ErrConfigNotFound, ErrContainerNotFound, ErrServiceNotFound, ErrGitRepoNotFound, ErrGitBranchNotFound:
 		return http.StatusNotFound
-	case ErrAuthFailed:
+	case ErrAuthFailed, ErrUnauthorized:
 		return http.StatusUnauthorized
-	case ErrPermissionDenied:
+	case ErrPermissionDenied, ErrForbidden:
 		return http.StatusForbidden
 	case ErrValidationFailed, ErrInvalidInput, ErrInvalidPath, ErrInvalidPort, ErrContainerInvalidID:
 		return http.StatusBadRequest
-	case ErrServiceAlreadyRunning:
+	case ErrServiceAlreadyRunning, ErrInvalidState:
 		return http.StatusConflict
 	case ErrNotImplemented:
 		return http.StatusNotImplemented
@@ -15,9 +15,11 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"go.opentelemetry.io/otel/attribute"
 
 	configpkg "vibeman/internal/config"
 	containerpkg "vibeman/internal/container"
+	"vibeman/internal/telemetry"
 	"vibeman/internal/xdg"
 )
 
@@ -59,7 +61,10 @@ func New(cfg *configpkg.Manager) *Manager {
 }
 
 // CreateWorktree creates a new git worktree
-func (m *Manager) CreateWorktree(ctx context.Context, repoURL, branch, path string) error {
+func (m *Manager) CreateWorktree(ctx context.Context, repoURL, branch, path string) (err error) {
+	ctx, span := telemetry.Start(ctx, "git.CreateWorktree", attribute.String("vibeman.branch", branch), attribute.String("vibeman.path", path))
+	defer func() { telemetry.End(span, err) }()
+
 	// Ensure the path is absolute
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -148,7 +153,10 @@ func (m *Manager) ListWorktrees(ctx context.Context, repoPath string) ([]contain
 }
 
 // RemoveWorktree removes a git worktree
-func (m *Manager) RemoveWorktree(ctx context.Context, path string) error {
+func (m *Manager) RemoveWorktree(ctx context.Context, path string) (err error) {
+	ctx, span := telemetry.Start(ctx, "git.RemoveWorktree", attribute.String("vibeman.path", path))
+	defer func() { telemetry.End(span, err) }()
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %w", err)
@@ -177,7 +185,10 @@ func (m *Manager) RemoveWorktree(ctx context.Context, path string) error {
 }
 
 // SwitchBranch switches to a different branch in the worktree
-func (m *Manager) SwitchBranch(ctx context.Context, path, branch string) error {
+func (m *Manager) SwitchBranch(ctx context.Context, path, branch string) (err error) {
+	ctx, span := telemetry.Start(ctx, "git.SwitchBranch", attribute.String("vibeman.branch", branch), attribute.String("vibeman.path", path))
+	defer func() { telemetry.End(span, err) }()
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %w", err)
@@ -236,7 +247,10 @@ func (m *Manager) SwitchBranch(ctx context.Context, path, branch string) error {
 }
 
 // UpdateWorktree updates a worktree by pulling latest changes
-func (m *Manager) UpdateWorktree(ctx context.Context, path string) error {
+func (m *Manager) UpdateWorktree(ctx context.Context, path string) (err error) {
+	ctx, span := telemetry.Start(ctx, "git.UpdateWorktree", attribute.String("vibeman.path", path))
+	defer func() { telemetry.End(span, err) }()
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %w", err)
@@ -287,7 +301,10 @@ func (m *Manager) UpdateWorktree(ctx context.Context, path string) error {
 }
 
 // CloneRepository clones a repository to the specified path
-func (m *Manager) CloneRepository(ctx context.Context, repoURL, path string) error {
+func (m *Manager) CloneRepository(ctx context.Context, repoURL, path string) (err error) {
+	ctx, span := telemetry.Start(ctx, "git.CloneRepository", attribute.String("vibeman.repo_url", repoURL), attribute.String("vibeman.path", path))
+	defer func() { telemetry.End(span, err) }()
+
 	// Validate repository URL
 	if repoURL == "" {
 		return fmt.Errorf("repository URL cannot be empty")
@@ -603,7 +620,7 @@ func (m *Manager) IsRepository(path string) bool {
 func (m *Manager) InitRepository(ctx context.Context, path string) error {
 	cmd := exec.CommandContext(ctx, "git", "init")
 	cmd.Dir = path
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := telemetry.CombinedOutput(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to initialize repository: %w, output: %s", err, string(output))
 	}
 	return nil
@@ -614,14 +631,14 @@ func (m *Manager) AddAndCommit(ctx context.Context, path, message string) error
 	// Stage all changes
 	cmd := exec.CommandContext(ctx, "git", "add", "-A")
 	cmd.Dir = path
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := telemetry.CombinedOutput(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to stage changes: %w, output: %s", err, string(output))
 	}
 	
 	// Create commit
 	cmd = exec.CommandContext(ctx, "git", "commit", "-m", message)
 	cmd.Dir = path
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := telemetry.CombinedOutput(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to commit: %w, output: %s", err, string(output))
 	}
 	
@@ -697,7 +714,7 @@ func (m *Manager) createWorktreeWithCmd(ctx context.Context, path, branch, mainR
 	cmd := exec.CommandContext(ctx, "git", "-C", mainRepoPath, "worktree", "add", path, branch)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := telemetry.Run(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to create worktree with git command: %w", err)
 	}
 	return nil
@@ -707,7 +724,7 @@ func (m *Manager) createWorktreeWithCmd(ctx context.Context, path, branch, mainR
 func (m *Manager) listWorktreesWithCmd(ctx context.Context, repoPath string) ([]containerpkg.GitWorktree, error) {
 	// Use os/exec to run git worktree list command
 	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "worktree", "list", "--porcelain")
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list worktrees with git command: %w", err)
 	}
@@ -721,7 +738,7 @@ func (m *Manager) removeWorktreeWithCmd(ctx context.Context, path, mainRepoPath
 	cmd := exec.CommandContext(ctx, "git", "-C", mainRepoPath, "worktree", "remove", path)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := telemetry.Run(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to remove worktree with git command: %w", err)
 	}
 	return nil
@@ -733,7 +750,7 @@ func (m *Manager) forceRemoveWorktree(ctx context.Context, path, mainRepoPath st
 	cmd := exec.CommandContext(ctx, "git", "-C", mainRepoPath, "worktree", "remove", "--force", path)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := telemetry.Run(ctx, cmd); err != nil {
 		// As a last resort, try to remove the directory manually
 		if removeErr := os.RemoveAll(path); removeErr != nil {
 			return fmt.Errorf("failed to force remove worktree: git error: %w, fs error: %v", err, removeErr)
@@ -1039,12 +1056,12 @@ func (m *Manager) HasUnpushedCommits(ctx context.Context, path string) (bool, er
 
 	// Check if we have a remote tracking branch
 	cmd := exec.CommandContext(ctx, "git", "-C", path, "rev-list", "--count", fmt.Sprintf("origin/%s..HEAD", branchName))
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		// If command fails, it might be because there's no remote tracking branch
 		// Try alternative approach
 		cmd = exec.CommandContext(ctx, "git", "-C", path, "cherry", "-v")
-		output, err = cmd.Output()
+		output, err = telemetry.Output(ctx, cmd)
 		if err != nil {
 			return false, nil // Assume no unpushed commits if we can't check
 		}
@@ -1084,7 +1101,7 @@ func (m *Manager) IsBranchMerged(ctx context.Context, path, branch string) (bool
 
 	// Check if branch is merged into default branch
 	cmd := exec.CommandContext(ctx, "git", "-C", path, "branch", "--merged", defaultBranch)
-	output, err := cmd.Output()
+	output, err := telemetry.Output(ctx, cmd)
 	if err != nil {
 		return false, fmt.Errorf("failed to check merged branches: %w", err)
 	}
@@ -0,0 +1,219 @@
+// Package health probes services and worktree containers to tell when they
+// are ready to use and whether they stay healthy.
+//
+// A probe is an HTTP GET, a TCP connect, a command run in the container or a
+// regular expression matched against the container's logs. Without a probe
+// the container must be running and, when its image or compose file defines
+// a healthcheck, reported healthy by Docker.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"vibeman/internal/config"
+	"vibeman/internal/telemetry"
+)
+
+// Status is the health of a service or container
+type Status string
+
+const (
+	StatusHealthy   Status = "healthy"
+	StatusUnhealthy Status = "unhealthy"
+	StatusStarting  Status = "starting"
+	StatusUnknown   Status = "unknown"
+)
+
+// ErrStarting is returned while Docker is still running a container's
+// healthcheck for the first time
+var ErrStarting = errors.New("healthcheck is starting")
+
+// StatusOf returns the status a probe result stands for
+func StatusOf(err error) Status {
+	switch {
+	case err == nil:
+		return StatusHealthy
+	case errors.Is(err, ErrStarting):
+		return StatusStarting
+	default:
+		return StatusUnhealthy
+	}
+}
+
+// DockerFunc runs a docker command and returns its combined output
+type DockerFunc func(ctx context.Context, args ...string) ([]byte, error)
+
+// docker runs a docker command on the host
+func docker(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	return telemetry.CombinedOutput(ctx, cmd)
+}
+
+// Checker runs probes against containers
+type Checker struct {
+	docker DockerFunc
+	client *http.Client
+}
+
+// NewChecker creates a checker that runs docker commands with run, or on
+// the host when run is nil
+func NewChecker(run DockerFunc) *Checker {
+	if run == nil {
+		run = docker
+	}
+	return &Checker{
+		docker: run,
+		client: &http.Client{
+			// A redirect is an answer; whatever it points to may not be reachable
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Check runs a probe once against a container
+func (c *Checker) Check(ctx context.Context, probe *config.ProbeConfig, containerID string) error {
+	ctx, cancel := context.WithTimeout(ctx, probe.TimeoutDuration())
+	defer cancel()
+
+	switch probe.Kind() {
+	case config.ProbeHTTP:
+		return c.checkHTTP(ctx, probe.HTTP)
+	case config.ProbeTCP:
+		return checkTCP(ctx, probe.TCP)
+	case config.ProbeExec:
+		return c.checkExec(ctx, containerID, probe.Exec)
+	case config.ProbeLog:
+		return c.checkLog(ctx, containerID, probe.Log)
+	default:
+		return c.checkContainer(ctx, containerID)
+	}
+}
+
+// WaitReady runs a probe every interval until it succeeds. It gives up once
+// the probe has failed the configured number of times.
+func (c *Checker) WaitReady(ctx context.Context, probe *config.ProbeConfig, containerID string) error {
+	interval := probe.IntervalDuration()
+	retries := probe.RetryCount()
+
+	for attempt := 1; ; attempt++ {
+		err := c.Check(ctx, probe, containerID)
+		if err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return fmt.Errorf("not ready after %d probes: %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkHTTP expects a 2xx or 3xx answer to a GET
+func (c *Checker) checkHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// checkTCP expects the address to accept a connection
+func checkTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkExec expects the command to exit 0 in the container
+func (c *Checker) checkExec(ctx context.Context, containerID string, command []string) error {
+	if containerID == "" {
+		return fmt.Errorf("no container to run %q in", strings.Join(command, " "))
+	}
+	args := append([]string{"exec", containerID}, command...)
+	if output, err := c.docker(ctx, args...); err != nil {
+		return fmt.Errorf("%q failed: %w, output: %s", strings.Join(command, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// checkLog expects a line logged since the container last started to match
+// the pattern
+func (c *Checker) checkLog(ctx context.Context, containerID, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	if containerID == "" {
+		return fmt.Errorf("no container to read logs from")
+	}
+
+	// Lines from before a restart say nothing about the current run
+	startedAt, err := c.docker(ctx, "inspect", "--format", "{{.State.StartedAt}}", containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w, output: %s", err, strings.TrimSpace(string(startedAt)))
+	}
+	logs, err := c.docker(ctx, "logs", "--since", strings.TrimSpace(string(startedAt)), containerID)
+	if err != nil {
+		return fmt.Errorf("failed to read container logs: %w", err)
+	}
+
+	for _, line := range strings.Split(string(logs), "\n") {
+		if re.MatchString(line) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no log line matches %q", pattern)
+}
+
+// checkContainer expects the container to be running and its Docker
+// healthcheck, if it has one, to pass
+func (c *Checker) checkContainer(ctx context.Context, containerID string) error {
+	if containerID == "" {
+		return fmt.Errorf("no container ID")
+	}
+
+	output, err := c.docker(ctx, "inspect", "--format", "{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}", containerID)
+	if err != nil {
+		return fmt.Errorf("failed to check container status: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 || fields[0] != "running" {
+		return fmt.Errorf("container is not running")
+	}
+	if len(fields) < 2 {
+		return nil
+	}
+	switch Status(fields[1]) {
+	case StatusHealthy:
+		return nil
+	case StatusStarting:
+		return ErrStarting
+	default:
+		return fmt.Errorf("container healthcheck reports %s", fields[1])
+	}
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vibeman/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocker answers docker commands by their first argument
+type fakeDocker struct {
+	outputs map[string][]string // Successive outputs per command
+	fail    map[string]bool
+	calls   [][]string
+}
+
+func (f *fakeDocker) run(ctx context.Context, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, args)
+	outputs := f.outputs[args[0]]
+	output := ""
+	if len(outputs) > 0 {
+		output = outputs[0]
+		if len(outputs) > 1 {
+			f.outputs[args[0]] = outputs[1:]
+		}
+	}
+	if f.fail[args[0]] {
+		return []byte(output), fmt.Errorf("exit status 1")
+	}
+	return []byte(output), nil
+}
+
+func TestCheck_HTTP(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(nil)
+	probe := &config.ProbeConfig{HTTP: server.URL + "/health"}
+
+	assert.ErrorContains(t, checker.Check(context.Background(), probe, ""), "503")
+
+	status = http.StatusFound
+	assert.NoError(t, checker.Check(context.Background(), probe, ""))
+}
+
+func TestCheck_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	checker := NewChecker(nil)
+	assert.NoError(t, checker.Check(context.Background(), &config.ProbeConfig{TCP: address}, ""))
+
+	listener.Close()
+	assert.Error(t, checker.Check(context.Background(), &config.ProbeConfig{TCP: address}, ""))
+}
+
+func TestCheck_Exec(t *testing.T) {
+	docker := &fakeDocker{fail: map[string]bool{"exec": true}, outputs: map[string][]string{"exec": {"no response"}}}
+	checker := NewChecker(docker.run)
+	probe := &config.ProbeConfig{Exec: []string{"pg_isready", "-U", "postgres"}}
+
+	err := checker.Check(context.Background(), probe, "abc123")
+	assert.ErrorContains(t, err, "no response")
+	assert.Equal(t, []string{"exec", "abc123", "pg_isready", "-U", "postgres"}, docker.calls[0])
+
+	docker.fail = nil
+	assert.NoError(t, checker.Check(context.Background(), probe, "abc123"))
+}
+
+func TestCheck_Log(t *testing.T) {
+	docker := &fakeDocker{outputs: map[string][]string{
+		"inspect": {"2025-01-01T12:00:00Z\n"},
+		"logs":    {"booting\n", "booting\nready to accept connections\n"},
+	}}
+	checker := NewChecker(docker.run)
+	probe := &config.ProbeConfig{Log: `ready to accept connections$`}
+
+	assert.ErrorContains(t, checker.Check(context.Background(), probe, "abc123"), "no log line matches")
+	assert.NoError(t, checker.Check(context.Background(), probe, "abc123"))
+
+	// Only lines from the current run count
+	assert.Equal(t, []string{"logs", "--since", "2025-01-01T12:00:00Z", "abc123"}, docker.calls[1])
+}
+
+func TestCheck_ContainerHealthcheck(t *testing.T) {
+	tests := []struct {
+		output string
+		status Status
+	}{
+		{"running \n", StatusHealthy},
+		{"running healthy\n", StatusHealthy},
+		{"running starting\n", StatusStarting},
+		{"running unhealthy\n", StatusUnhealthy},
+		{"exited \n", StatusUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.output), func(t *testing.T) {
+			docker := &fakeDocker{outputs: map[string][]string{"inspect": {tt.output}}}
+			err := NewChecker(docker.run).Check(context.Background(), nil, "abc123")
+			assert.Equal(t, tt.status, StatusOf(err))
+		})
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	docker := &fakeDocker{outputs: map[string][]string{
+		"inspect": {"running starting", "running starting", "running healthy"},
+	}}
+	checker := NewChecker(docker.run)
+	probe := &config.ProbeConfig{Interval: "1ms", Retries: 5}
+
+	require.NoError(t, checker.WaitReady(context.Background(), probe, "abc123"))
+	assert.Len(t, docker.calls, 3)
+}
+
+func TestWaitReady_GivesUp(t *testing.T) {
+	docker := &fakeDocker{outputs: map[string][]string{"inspect": {"running unhealthy"}}}
+	checker := NewChecker(docker.run)
+	probe := &config.ProbeConfig{Interval: "1ms", Retries: 3}
+
+	err := checker.WaitReady(context.Background(), probe, "abc123")
+	assert.ErrorContains(t, err, "not ready after 3 probes")
+	assert.Len(t, docker.calls, 3)
+}
@@ -143,6 +143,12 @@ type MinimalServiceManager interface {
 	HealthCheck(ctx context.Context, name string) error
 }
 
+// ServiceEnvProvider is implemented by service managers that can tell
+// containers how to reach running services
+type ServiceEnvProvider interface {
+	ServiceEnv(ctx context.Context, worktreeID string, services []string, inContainer bool) (map[string]string, error)
+}
+
 // DatabaseInterface for database operations
 type DatabaseInterface interface {
 	// Add database methods as needed
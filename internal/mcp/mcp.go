@@ -0,0 +1,344 @@
+// Package mcp implements the server side of the Model Context Protocol, over
+// which AI agents discover and call tools: JSON-RPC 2.0 messages exchanged
+// over stdio, one per line, or as HTTP POST bodies.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ProtocolVersion is the newest protocol revision the server speaks
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the protocol revisions the server can speak, newest
+// first
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// maxMessage bounds the size of a message read from stdio
+const maxMessage = 16 << 20
+
+// Tool is a tool agents can call
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema map[string]any // JSON Schema of the arguments object
+	// Handler runs the tool with its arguments and returns text for the
+	// agent. Errors are reported to the agent as failed calls, not
+	// protocol errors, so it can react to them.
+	Handler func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Server answers MCP requests with a fixed set of tools
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+}
+
+// NewServer creates a server that introduces itself as name and version
+func NewServer(name, version string, tools ...Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+// request is a JSON-RPC request, or a notification when ID is absent
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// content is a block of a tool's result
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// toolResult is the result of a tool call
+type toolResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Handle answers a message: a request, a notification or a batch of them.
+// It returns the response to send, or nil when there is none.
+func (s *Server) Handle(ctx context.Context, message []byte) []byte {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(message, &batch); err == nil {
+		var responses []*response
+		for _, item := range batch {
+			if resp := s.handleOne(ctx, item); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		out, _ := json.Marshal(responses)
+		return out
+	}
+
+	resp := s.handleOne(ctx, message)
+	if resp == nil {
+		return nil
+	}
+	out, _ := json.Marshal(resp)
+	return out
+}
+
+// handleOne answers a single request, returning nil for notifications
+func (s *Server) handleOne(ctx context.Context, message []byte) *response {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: CodeParseError, Message: "invalid JSON"}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &response{JSONRPC: "2.0", ID: idOrNull(req.ID), Error: &rpcError{Code: CodeInvalidRequest, Message: "not a JSON-RPC 2.0 request"}}
+	}
+
+	result, rpcErr := s.dispatch(ctx, req)
+	if req.ID == nil {
+		// Notifications, such as notifications/initialized, get no answer
+		return nil
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+}
+
+// dispatch runs the method a request names
+func (s *Server) dispatch(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]map[string]any, 0, len(s.tools))
+		for _, tool := range s.tools {
+			schema := tool.InputSchema
+			if schema == nil {
+				schema = map[string]any{"type": "object"}
+			}
+			tools = append(tools, map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": schema,
+			})
+		}
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: CodeInvalidParams, Message: "invalid tool call"}
+		}
+		i := slices.IndexFunc(s.tools, func(tool Tool) bool { return tool.Name == params.Name })
+		if i < 0 {
+			return nil, &rpcError{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+		}
+		if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+			params.Arguments = json.RawMessage("{}")
+		}
+		text, err := s.tools[i].Handler(ctx, params.Arguments)
+		if err != nil {
+			return toolResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return toolResult{Content: []content{{Type: "text", Text: text}}}, nil
+	default:
+		if req.ID == nil {
+			return nil, nil
+		}
+		return nil, &rpcError{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+// ServeStdio answers messages read from r, one per line, writing responses
+// to w until r ends or ctx is cancelled. Requests are answered concurrently,
+// so a slow tool does not hold up pings.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	return serveStdio(ctx, s.Handle, r, w)
+}
+
+// serveStdio answers messages read from r with handle, one per line
+func serveStdio(ctx context.Context, handle func(context.Context, []byte) []byte, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessage)
+
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		message := slices.Clone(scanner.Bytes())
+		if len(message) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := handle(ctx, message)
+			if out == nil {
+				return
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			w.Write(append(out, '\n'))
+		}()
+	}
+	return scanner.Err()
+}
+
+// ServeHTTP answers a message POSTed with the streamable HTTP transport.
+// Responses are sent as JSON rather than event streams, since the server
+// never sends requests of its own.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "MCP messages must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	message, err := io.ReadAll(io.LimitReader(r.Body, maxMessage))
+	if err != nil {
+		http.Error(w, "failed to read message", http.StatusBadRequest)
+		return
+	}
+	out := s.Handle(r.Context(), message)
+	if out == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// idOrNull returns id, or JSON null when a request has none
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// DecodeArgs decodes a tool's arguments into v, rejecting unknown ones
+func DecodeArgs(args json.RawMessage, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(args))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// Forwarder passes messages on to an MCP server over HTTP, e.g. to serve
+// agents that only speak stdio
+type Forwarder struct {
+	URL    string
+	Token  string // Sent as a bearer token when set
+	Client *http.Client
+}
+
+// ServeStdio forwards messages read from r, one per line, writing the
+// server's responses to w until r ends or ctx is cancelled
+func (f *Forwarder) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	return serveStdio(ctx, f.Handle, r, w)
+}
+
+// Handle forwards a message and returns the server's response, or nil when
+// there is none. Failures to reach the server are answered as internal
+// errors.
+func (f *Forwarder) Handle(ctx context.Context, message []byte) []byte {
+	out, err := f.post(ctx, message)
+	if err == nil {
+		return out
+	}
+
+	var req request
+	if json.Unmarshal(message, &req) != nil || req.ID == nil {
+		return nil
+	}
+	out, _ = json.Marshal(&response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: CodeInternalError, Message: err.Error()}})
+	return out
+}
+
+// post sends a message to the server
+func (f *Forwarder) post(ctx context.Context, message []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.URL, bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if f.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.Token)
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach MCP server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessage))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP server response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("MCP server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return bytes.TrimSpace(body), nil
+}
@@ -0,0 +1,180 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testServer() *Server {
+	return NewServer("vibeman", "test",
+		Tool{
+			Name:        "echo",
+			Description: "Repeat a message",
+			InputSchema: map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "string"}}},
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var params struct {
+					Message string `json:"message"`
+				}
+				if err := DecodeArgs(args, &params); err != nil {
+					return "", err
+				}
+				return params.Message, nil
+			},
+		},
+		Tool{
+			Name: "fail",
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return "", errors.New("service not found")
+			},
+		},
+	)
+}
+
+// call sends a message and decodes the response
+func call(t *testing.T, s *Server, message string) map[string]any {
+	t.Helper()
+	out := s.Handle(context.Background(), []byte(message))
+	require.NotNil(t, out)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(out, &resp))
+	assert.Equal(t, "2.0", resp["jsonrpc"])
+	return resp
+}
+
+func TestServer_Initialize(t *testing.T) {
+	s := testServer()
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"claude","version":"1"}}}`)
+	assert.Equal(t, float64(1), resp["id"])
+	result := resp["result"].(map[string]any)
+	assert.Equal(t, "2025-03-26", result["protocolVersion"], "a supported version is echoed")
+	assert.Equal(t, "vibeman", result["serverInfo"].(map[string]any)["name"])
+	assert.Contains(t, result["capabilities"], "tools")
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":"a","method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	assert.Equal(t, ProtocolVersion, resp["result"].(map[string]any)["protocolVersion"])
+
+	// Notifications get no answer
+	assert.Nil(t, s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	assert.Equal(t, map[string]any{}, resp["result"])
+}
+
+func TestServer_Tools(t *testing.T) {
+	s := testServer()
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	tools := resp["result"].(map[string]any)["tools"].([]any)
+	require.Len(t, tools, 2)
+	assert.Equal(t, "echo", tools[0].(map[string]any)["name"])
+	assert.Equal(t, map[string]any{"type": "object"}, tools[1].(map[string]any)["inputSchema"])
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}}}`)
+	result := resp["result"].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"type": "text", "text": "hi"}}, result["content"])
+	assert.NotContains(t, result, "isError")
+
+	// Failing tools are failed calls the agent sees, not protocol errors
+	resp = call(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"fail"}}`)
+	result = resp["result"].(map[string]any)
+	assert.Equal(t, true, result["isError"])
+	assert.Equal(t, "service not found", result["content"].([]any)[0].(map[string]any)["text"])
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{"other":1}}}`)
+	assert.Equal(t, true, resp["result"].(map[string]any)["isError"])
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`)
+	assert.Equal(t, float64(CodeInvalidParams), resp["error"].(map[string]any)["code"])
+}
+
+func TestServer_Errors(t *testing.T) {
+	s := testServer()
+
+	resp := call(t, s, `{not json`)
+	assert.Equal(t, float64(CodeParseError), resp["error"].(map[string]any)["code"])
+	assert.Nil(t, resp["id"])
+
+	resp = call(t, s, `{"id":1,"method":"ping"}`)
+	assert.Equal(t, float64(CodeInvalidRequest), resp["error"].(map[string]any)["code"])
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)
+	assert.Equal(t, float64(CodeMethodNotFound), resp["error"].(map[string]any)["code"])
+
+	// Batches are answered with a batch of the requests' responses
+	out := s.Handle(context.Background(), []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`))
+	var batch []map[string]any
+	require.NoError(t, json.Unmarshal(out, &batch))
+	assert.Len(t, batch, 1)
+}
+
+func TestServer_ServeStdio(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}}}` + "\n")
+	var out strings.Builder
+	require.NoError(t, testServer().ServeStdio(context.Background(), in, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, out.String(), `"id":1`)
+	assert.Contains(t, out.String(), `"text":"hi"`)
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(testServer())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, string(body))
+
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestForwarder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "invalid mcp token", http.StatusUnauthorized)
+			return
+		}
+		testServer().ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	in := `{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}}}` + "\n"
+	var out strings.Builder
+	forwarder := &Forwarder{URL: srv.URL, Token: "secret"}
+	require.NoError(t, forwarder.ServeStdio(context.Background(), strings.NewReader(in), &out))
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"hi"}]}}`+"\n", out.String())
+
+	// Requests the server rejects are answered with errors
+	forwarder.Token = "wrong"
+	resp := forwarder.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(resp, &decoded))
+	assert.Equal(t, float64(2), decoded["id"])
+	assert.Contains(t, decoded["error"].(map[string]any)["message"], "invalid mcp token")
+}
@@ -0,0 +1,301 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/errors"
+)
+
+// OperationAIFanout runs one prompt in several fresh worktrees
+const OperationAIFanout = "ai_fanout"
+
+// Progress step names reported by AI fan-outs
+const (
+	StepFanoutWorktrees = "fanout_worktrees"
+	StepFanoutAgents    = "fanout_agents"
+	StepFanoutTests     = "fanout_tests"
+)
+
+// MaxAIFanout is the most worktrees a single fan-out creates
+const MaxAIFanout = 10
+
+// maxAITestOutput bounds the test output kept for each fan-out attempt and
+// returned to agents running tests
+const maxAITestOutput = 8 << 10
+
+// aiFanoutPollInterval is how often a fan-out checks on its jobs
+var aiFanoutPollInterval = time.Second
+
+// WorktreeCreator creates worktrees, e.g. *WorktreeOperations
+type WorktreeCreator interface {
+	CreateWorktree(ctx context.Context, req CreateWorktreeRequest) (*CreateWorktreeResponse, error)
+}
+
+// AIFanoutRequest describes a prompt to run in several fresh worktrees
+type AIFanoutRequest struct {
+	RepositoryID string
+	Count        int
+	Prompt       string
+	Agent        string
+	BaseBranch   string // Branch the worktrees start from, the default branch if empty
+	NamePrefix   string // Worktrees are named <prefix>-1 to <prefix>-N
+	TestCommand  string // Run in each AI container once its agent finishes
+	OwnerID      string
+}
+
+// AIFanoutAttempt is the outcome of one agent run in a fan-out
+type AIFanoutAttempt struct {
+	Worktree     string         `json:"worktree"`
+	WorktreeID   string         `json:"worktree_id,omitempty"`
+	JobID        string         `json:"job_id,omitempty"`
+	Status       db.AIJobStatus `json:"status,omitempty"`
+	ExitCode     *int           `json:"exit_code,omitempty"`
+	Duration     float64        `json:"duration_seconds"`
+	FilesChanged int            `json:"files_changed"`
+	Insertions   int            `json:"insertions"`
+	Deletions    int            `json:"deletions"`
+	TestExitCode *int           `json:"test_exit_code,omitempty"`
+	TestOutput   string         `json:"test_output,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// AIFanoutReport compares the attempts of a fan-out
+type AIFanoutReport struct {
+	Agent       string            `json:"agent"`
+	TestCommand string            `json:"test_command,omitempty"`
+	Attempts    []AIFanoutAttempt `json:"attempts"`
+}
+
+// TestsPassed reports whether the attempt's test command succeeded
+func (a AIFanoutAttempt) TestsPassed() bool {
+	return a.TestExitCode != nil && *a.TestExitCode == 0
+}
+
+// Fanout creates req.Count worktrees from the same base, runs the prompt as a
+// headless job in each, runs the test command against each result and
+// reports how the attempts compare. Attempts that fail do not stop the
+// others; cancelling ctx cancels the jobs still running.
+func (r *AIJobRunner) Fanout(ctx context.Context, worktrees WorktreeCreator, req AIFanoutRequest) (*AIFanoutReport, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, errors.New(errors.ErrInvalidInput, "prompt is required")
+	}
+	if req.Count < 1 || req.Count > MaxAIFanout {
+		return nil, errors.New(errors.ErrInvalidInput, fmt.Sprintf("count must be between 1 and %d", MaxAIFanout))
+	}
+	if req.NamePrefix == "" {
+		req.NamePrefix = "fanout-" + generateID()[:8]
+	}
+
+	report := &AIFanoutReport{Agent: req.Agent, TestCommand: req.TestCommand}
+	attempts := make([]AIFanoutAttempt, req.Count)
+
+	// Worktrees are created one at a time since they share a repository.
+	// Their own progress would drown out the fan-out's.
+	quiet := WithProgress(ctx, nil)
+	for i := range attempts {
+		attempt := &attempts[i]
+		attempt.Worktree = fmt.Sprintf("%s-%d", req.NamePrefix, i+1)
+		reportProgress(ctx, ProgressEvent{Step: StepFanoutWorktrees, Message: "Creating worktree " + attempt.Worktree, Status: StepRunning, Current: i + 1, Total: req.Count})
+
+		created, err := worktrees.CreateWorktree(quiet, CreateWorktreeRequest{
+			RepositoryID: req.RepositoryID,
+			Name:         attempt.Worktree,
+			BaseBranch:   req.BaseBranch,
+			AutoStart:    true,
+			OwnerID:      req.OwnerID,
+		})
+		if err == nil {
+			attempt.WorktreeID = created.Worktree.ID
+			var job *db.AIJob
+			if job, err = r.Enqueue(ctx, AIJobRequest{WorktreeID: attempt.WorktreeID, Prompt: req.Prompt, Agent: req.Agent}); err == nil {
+				attempt.JobID = job.ID
+				report.Agent = job.Agent
+			}
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+			reportProgress(ctx, ProgressEvent{Step: StepFanoutWorktrees, Message: "Creating worktree " + attempt.Worktree, Status: StepFailed, Current: i + 1, Total: req.Count, Error: err.Error()})
+			continue
+		}
+		reportProgress(ctx, ProgressEvent{Step: StepFanoutWorktrees, Message: "Created worktree " + attempt.Worktree, Status: StepCompleted, Current: i + 1, Total: req.Count})
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	r.waitForFanout(ctx, attempts)
+
+	if req.TestCommand != "" {
+		r.testFanout(ctx, req.TestCommand, attempts)
+	}
+
+	report.Attempts = attempts
+	return report, nil
+}
+
+// waitForFanout waits for the jobs of a fan-out to finish and records their
+// outcomes. Once ctx is cancelled the jobs still running are cancelled.
+func (r *AIJobRunner) waitForFanout(ctx context.Context, attempts []AIFanoutAttempt) {
+	// Keep polling after ctx is cancelled so the final state is recorded
+	pollCtx := context.WithoutCancel(ctx)
+	done := ctx.Done()
+
+	total := 0
+	for _, attempt := range attempts {
+		if attempt.JobID != "" {
+			total++
+		}
+	}
+	if total == 0 {
+		return
+	}
+	stepStarted(ctx, StepFanoutAgents, fmt.Sprintf("Waiting for %d agents", total))
+
+	ticker := time.NewTicker(aiFanoutPollInterval)
+	defer ticker.Stop()
+
+	for {
+		finished := 0
+		for i := range attempts {
+			attempt := &attempts[i]
+			if attempt.JobID == "" {
+				continue
+			}
+			job, err := r.Get(pollCtx, attempt.JobID)
+			if err != nil {
+				attempt.Error = err.Error()
+				attempt.JobID = ""
+				total--
+				continue
+			}
+			if !job.Status.IsFinished() {
+				continue
+			}
+			finished++
+			recordFanoutJob(attempt, job)
+		}
+
+		if finished == total {
+			stepCompleted(ctx, StepFanoutAgents, fmt.Sprintf("%d agents finished", total))
+			return
+		}
+
+		select {
+		case <-done:
+			done = nil
+			for _, attempt := range attempts {
+				if attempt.JobID != "" && !attempt.Status.IsFinished() {
+					_, _ = r.Cancel(pollCtx, attempt.JobID)
+				}
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordFanoutJob copies the outcome of a finished job into its attempt
+func recordFanoutJob(attempt *AIFanoutAttempt, job *db.AIJob) {
+	attempt.Status = job.Status
+	attempt.ExitCode = job.ExitCode
+	attempt.Error = job.Error
+	if job.StartedAt != nil && job.FinishedAt != nil {
+		attempt.Duration = job.FinishedAt.Sub(*job.StartedAt).Seconds()
+	}
+	attempt.FilesChanged, attempt.Insertions, attempt.Deletions = diffStat(job.Diff)
+}
+
+// testFanout runs the test command in the AI container of each attempt whose
+// agent succeeded
+func (r *AIJobRunner) testFanout(ctx context.Context, testCommand string, attempts []AIFanoutAttempt) {
+	for i := range attempts {
+		attempt := &attempts[i]
+		if attempt.Status != db.AIJobSucceeded || ctx.Err() != nil {
+			continue
+		}
+
+		event := ProgressEvent{Step: StepFanoutTests, Message: "Testing " + attempt.Worktree, Current: i + 1, Total: len(attempts)}
+		reportProgress(ctx, ProgressEvent{Step: event.Step, Message: event.Message, Status: StepRunning, Current: event.Current, Total: event.Total})
+
+		exitCode, output, err := r.runFanoutTest(ctx, attempt.WorktreeID, testCommand)
+		attempt.TestExitCode = exitCode
+		attempt.TestOutput = output
+		switch {
+		case err != nil:
+			attempt.Error = "tests: " + err.Error()
+			event.Status, event.Error = StepFailed, err.Error()
+		case *exitCode != 0:
+			event.Status, event.Error = StepFailed, fmt.Sprintf("exit code %d", *exitCode)
+		default:
+			event.Status = StepCompleted
+		}
+		reportProgress(ctx, event)
+	}
+}
+
+// runFanoutTest runs a test command in a worktree's AI container, returning
+// its exit code and the tail of its output
+func (r *AIJobRunner) runFanoutTest(ctx context.Context, worktreeID, testCommand string) (*int, string, error) {
+	worktree, err := db.NewWorktreeRepository(r.db).Get(ctx, worktreeID)
+	if err != nil {
+		return nil, "", err
+	}
+	repository, err := db.NewRepositoryRepository(r.db).GetByID(ctx, worktree.RepositoryID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return runInAIContainer(ctx, r.command, AIContainerName(repository.Name, worktree.Name), "test-"+worktreeID, testCommand)
+}
+
+// runInAIContainer runs a shell command in an AI container, returning its
+// exit code and the tail of its output. The exit code is nil when the
+// command could not be run at all.
+func runInAIContainer(ctx context.Context, command AIJobCommandFunc, container, id, shellCommand string) (*int, string, error) {
+	var output strings.Builder
+	cmd := command(ctx, container, id, []string{"sh", "-c", shellCommand})
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+
+	if _, exited := err.(*exec.ExitError); exited || err == nil {
+		code := cmd.ProcessState.ExitCode()
+		return &code, truncateTail(output.String(), maxAITestOutput), nil
+	}
+	return nil, truncateTail(output.String(), maxAITestOutput), err
+}
+
+// truncateTail keeps the last max bytes of s, marking the cut
+func truncateTail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return "[earlier output truncated]\n" + s[len(s)-max:]
+}
+
+// diffStat counts the files, inserted lines and deleted lines of a unified
+// diff. Only lines in hunks count, so the ---/+++ headers of each file are
+// skipped while added or removed lines that look like them are not.
+func diffStat(diff string) (files, insertions, deletions int) {
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files++
+			inHunk = false
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			insertions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return files, insertions, deletions
+}
@@ -0,0 +1,127 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorktreeCreator creates worktrees as separate git repositories
+type fakeWorktreeCreator struct {
+	t        *testing.T
+	database *db.DB
+	dirs     map[string]string // AI container name to worktree path
+	fail     string            // Name of a worktree that cannot be created
+}
+
+func (f *fakeWorktreeCreator) CreateWorktree(ctx context.Context, req CreateWorktreeRequest) (*CreateWorktreeResponse, error) {
+	if req.Name == f.fail {
+		return nil, fmt.Errorf("branch %s already exists", req.Name)
+	}
+	dir := initAIJobRepo(f.t, fakeAgentConfig)
+	worktree := &db.Worktree{ID: "wt-" + req.Name, RepositoryID: req.RepositoryID, Name: req.Name, Branch: req.Name, Path: dir, Status: db.StatusRunning}
+	if err := db.NewWorktreeRepository(f.database).Create(ctx, worktree); err != nil {
+		return nil, err
+	}
+	f.dirs[AIContainerName("app", req.Name)] = dir
+	return &CreateWorktreeResponse{Worktree: worktree, Path: dir}, nil
+}
+
+func TestAIJobRunner_Fanout(t *testing.T) {
+	interval := aiFanoutPollInterval
+	aiFanoutPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { aiFanoutPollInterval = interval })
+
+	database := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "app", Path: t.TempDir(), Name: "app"}))
+	creator := &fakeWorktreeCreator{t: t, database: database, dirs: make(map[string]string), fail: "try-3"}
+
+	// The agent in try-1 documents usage, the one in try-2 gives up
+	runner := NewAIJobRunner(database, 2, time.Minute)
+	runner.SetCommand(func(ctx context.Context, container, jobID string, command []string) *exec.Cmd {
+		script := `echo "## usage" >> README.md; echo new > notes.txt`
+		if strings.Contains(container, "try-2") {
+			script = `echo "giving up"; exit 1`
+		}
+		if strings.HasPrefix(jobID, "test-") {
+			script = strings.Join(command[2:], " ")
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", script)
+		cmd.Dir = creator.dirs[container]
+		return cmd
+	})
+
+	report, err := runner.Fanout(ctx, creator, AIFanoutRequest{
+		RepositoryID: "app",
+		Count:        3,
+		Prompt:       "document usage",
+		Agent:        "fake",
+		NamePrefix:   "try",
+		TestCommand:  "grep -q usage README.md",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "fake", report.Agent)
+	require.Len(t, report.Attempts, 3)
+
+	best := report.Attempts[0]
+	assert.Equal(t, "try-1", best.Worktree)
+	assert.Equal(t, db.AIJobSucceeded, best.Status)
+	assert.Equal(t, 2, best.FilesChanged)
+	assert.Equal(t, 2, best.Insertions)
+	assert.Zero(t, best.Deletions)
+	assert.True(t, best.TestsPassed())
+
+	gaveUp := report.Attempts[1]
+	assert.Equal(t, db.AIJobFailed, gaveUp.Status)
+	require.NotNil(t, gaveUp.ExitCode)
+	assert.Equal(t, 1, *gaveUp.ExitCode)
+	assert.Nil(t, gaveUp.TestExitCode, "tests only run against successful attempts")
+
+	missing := report.Attempts[2]
+	assert.Empty(t, missing.JobID)
+	assert.Contains(t, missing.Error, "already exists")
+
+	require.NoError(t, runner.Shutdown(ctx))
+}
+
+func TestAIJobRunner_Fanout_Validation(t *testing.T) {
+	runner := NewAIJobRunner(testutil.SetupTestDB(t), 1, 0)
+
+	_, err := runner.Fanout(context.Background(), nil, AIFanoutRequest{Count: 0, Prompt: "hi"})
+	assert.ErrorContains(t, err, "count must be between 1 and 10")
+	_, err = runner.Fanout(context.Background(), nil, AIFanoutRequest{Count: 2})
+	assert.ErrorContains(t, err, "prompt is required")
+}
+
+func TestDiffStat(t *testing.T) {
+	diff := `diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1,2 +1,3 @@
+-# app
++# App
++usage
+--- 
++++ counter
+diff --git a/notes.txt b/notes.txt
+new file mode 100644
+--- /dev/null
++++ b/notes.txt
+@@ -0,0 +1 @@
++new
+`
+	files, insertions, deletions := diffStat(diff)
+	assert.Equal(t, 2, files)
+	assert.Equal(t, 4, insertions, "added lines starting with ++ count")
+	assert.Equal(t, 2, deletions, "removed lines starting with -- count")
+}
@@ -0,0 +1,584 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/errors"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+)
+
+const (
+	// aiJobFlushInterval is how often a running job's transcript is saved, so
+	// it can be followed while the agent works
+	aiJobFlushInterval = 2 * time.Second
+
+	// maxAIJobTranscript and maxAIJobDiff bound what is stored of a job's
+	// output and changes
+	maxAIJobTranscript = 1 << 20
+	maxAIJobDiff       = 4 << 20
+)
+
+// AIJobRequest describes a headless agent run to queue
+type AIJobRequest struct {
+	WorktreeID string
+	Prompt     string
+	Agent      string // Agent to run instead of the one the repository configures
+}
+
+// AIJobCommandFunc returns the command that runs an agent in an AI container
+type AIJobCommandFunc func(ctx context.Context, container, jobID string, command []string) *exec.Cmd
+
+// AIJobRunner runs headless AI jobs in the AI containers of worktrees. Jobs
+// wait in a queue until fewer than maxJobs are running and no other job runs
+// in their worktree, and are persisted to the ai_jobs table with their
+// transcript and the diff they produced.
+type AIJobRunner struct {
+	db      *db.DB
+	maxJobs int
+	timeout time.Duration
+	command AIJobCommandFunc
+
+	mu        sync.Mutex
+	queue     []string
+	cancels   map[string]context.CancelFunc
+	worktrees map[string]string // Worktree of each queued or running job
+	stopping  bool
+	wg        sync.WaitGroup
+}
+
+// NewAIJobRunner creates a runner that runs up to maxJobs jobs at once, each
+// for at most timeout (0 for no limit)
+func NewAIJobRunner(database *db.DB, maxJobs int, timeout time.Duration) *AIJobRunner {
+	if maxJobs <= 0 {
+		maxJobs = 1
+	}
+	return &AIJobRunner{
+		db:        database,
+		maxJobs:   maxJobs,
+		timeout:   timeout,
+		command:   aiJobCommand,
+		cancels:   make(map[string]context.CancelFunc),
+		worktrees: make(map[string]string),
+	}
+}
+
+// SetCommand replaces how agents are run, e.g. in tests
+func (r *AIJobRunner) SetCommand(command AIJobCommandFunc) {
+	r.command = command
+}
+
+// AIContainerName returns the name of a worktree's AI container
+func AIContainerName(repositoryName, worktreeName string) string {
+	return fmt.Sprintf("%s-%s-ai", repositoryName, worktreeName)
+}
+
+// AIContainerRepository returns the repository name in the name of a
+// worktree's AI container, reporting whether it was named by AIContainerName
+func AIContainerRepository(containerName, worktreeName string) (string, bool) {
+	repositoryName, ok := strings.CutSuffix(containerName, "-"+worktreeName+"-ai")
+	return repositoryName, ok && repositoryName != ""
+}
+
+// Enqueue records a new job and runs it once a slot is free and no other
+// job runs in its worktree
+func (r *AIJobRunner) Enqueue(ctx context.Context, req AIJobRequest) (job *db.AIJob, err error) {
+	target := audit.Target{Type: audit.TargetWorktree, ID: req.WorktreeID}
+	defer func() {
+		params := audit.Params{"agent": req.Agent}
+		if job != nil {
+			params = audit.Params{"agent": job.Agent, "job_id": job.ID}
+		}
+		audit.Record(ctx, audit.ActionAIJobRun, target, params, err)
+	}()
+
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, errors.New(errors.ErrInvalidInput, "prompt is required")
+	}
+
+	worktree, err := db.NewWorktreeRepository(r.db).Get(ctx, req.WorktreeID)
+	if err != nil {
+		return nil, err
+	}
+	target.Name = worktree.Name
+
+	repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrConfigParse, "failed to load repository config", err)
+	}
+	if !repoConfig.Repository.AI.Enabled {
+		return nil, errors.New(errors.ErrInvalidState, "the AI container is disabled for this repository")
+	}
+	agentName, agent, err := repoConfig.Repository.AI.ResolveAgent(req.Agent)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalidInput, "invalid agent", err)
+	}
+	if _, ok := agent.HeadlessCommand(); !ok {
+		return nil, errors.New(errors.ErrInvalidInput, fmt.Sprintf("agent %q cannot run headless", agentName))
+	}
+
+	job = &db.AIJob{
+		ID:         generateID(),
+		WorktreeID: worktree.ID,
+		Agent:      agentName,
+		Prompt:     req.Prompt,
+		Status:     db.AIJobQueued,
+	}
+	if err := db.NewAIJobRepository(r.db).Create(ctx, job); err != nil {
+		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to create AI job", err)
+	}
+
+	r.mu.Lock()
+	r.queue = append(r.queue, job.ID)
+	r.worktrees[job.ID] = job.WorktreeID
+	r.mu.Unlock()
+	r.dispatch()
+
+	return job, nil
+}
+
+// Cancel removes a queued job from the queue, or stops a running one
+func (r *AIJobRunner) Cancel(ctx context.Context, id string) (job *db.AIJob, err error) {
+	repo := db.NewAIJobRepository(r.db)
+	job, err = repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	target := audit.Target{Type: audit.TargetWorktree, ID: job.WorktreeID}
+	defer func() {
+		audit.Record(ctx, audit.ActionAIJobCancel, target, audit.Params{"job_id": id}, err)
+	}()
+
+	if job.Status.IsFinished() {
+		return nil, errors.New(errors.ErrInvalidState, "AI job has already finished").WithContext("job_id", id)
+	}
+
+	r.mu.Lock()
+	cancel, running := r.cancels[id]
+	queued := slices.Index(r.queue, id)
+	if queued >= 0 {
+		r.queue = slices.Delete(r.queue, queued, queued+1)
+		delete(r.worktrees, id)
+	}
+	r.mu.Unlock()
+
+	switch {
+	case running:
+		cancel()
+	case queued >= 0 || job.Status == db.AIJobQueued:
+		now := time.Now()
+		job.Status = db.AIJobCancelled
+		job.Error = "job cancelled"
+		job.FinishedAt = &now
+		if err := repo.Update(ctx, job); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New(errors.ErrInvalidState, "AI job is not running on this server").WithContext("job_id", id)
+	}
+	return job, nil
+}
+
+// Get returns a job with its transcript and diff
+func (r *AIJobRunner) Get(ctx context.Context, id string) (*db.AIJob, error) {
+	return db.NewAIJobRepository(r.db).Get(ctx, id)
+}
+
+// List returns jobs filtered by worktree and status, without transcripts
+// and diffs
+func (r *AIJobRunner) List(ctx context.Context, worktreeID, status string) ([]*db.AIJob, error) {
+	return db.NewAIJobRepository(r.db).List(ctx, worktreeID, status)
+}
+
+// Resume marks jobs left running by a previous process as failed and queues
+// the jobs still waiting to run
+func (r *AIJobRunner) Resume(ctx context.Context) error {
+	repo := db.NewAIJobRepository(r.db)
+	count, err := repo.FailRunning(ctx, "interrupted by server restart")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.WithField("count", count).Warn("Marked interrupted AI jobs as failed")
+	}
+
+	queued, err := repo.List(ctx, "", string(db.AIJobQueued))
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	for i := len(queued) - 1; i >= 0; i-- { // Oldest first
+		if !slices.Contains(r.queue, queued[i].ID) {
+			r.queue = append(r.queue, queued[i].ID)
+			r.worktrees[queued[i].ID] = queued[i].WorktreeID
+		}
+	}
+	r.mu.Unlock()
+	r.dispatch()
+	return nil
+}
+
+// Shutdown stops running jobs, leaving queued ones for the next start, and
+// waits for them to finish
+func (r *AIJobRunner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopping = true
+	for _, cancel := range r.cancels {
+		cancel()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatch starts queued jobs while slots are free. Jobs in a worktree
+// another job runs in stay queued: they would share its /workspace and mix
+// each other's changes into their diffs.
+func (r *AIJobRunner) dispatch() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := 0; !r.stopping && i < len(r.queue) && len(r.cancels) < r.maxJobs; {
+		id := r.queue[i]
+		if r.worktreeBusy(r.worktrees[id]) {
+			i++
+			continue
+		}
+		r.queue = slices.Delete(r.queue, i, i+1)
+
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if r.timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), r.timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		r.cancels[id] = cancel
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.run(ctx, id)
+
+			r.mu.Lock()
+			delete(r.cancels, id)
+			delete(r.worktrees, id)
+			r.mu.Unlock()
+			cancel()
+			r.dispatch()
+		}()
+	}
+}
+
+// worktreeBusy reports whether a job runs in the worktree. The caller holds
+// r.mu.
+func (r *AIJobRunner) worktreeBusy(worktreeID string) bool {
+	for running := range r.cancels {
+		if r.worktrees[running] == worktreeID {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs a job to completion and records its outcome
+func (r *AIJobRunner) run(ctx context.Context, id string) {
+	repo := db.NewAIJobRepository(r.db)
+
+	// Loaded detached, so a job cancelled before it started is still
+	// recorded as cancelled
+	job, err := repo.Get(context.WithoutCancel(ctx), id)
+	if err != nil {
+		logger.WithError(err).WithField("job_id", id).Warn("Failed to load AI job")
+		return
+	}
+	if job.Status != db.AIJobQueued {
+		return // Cancelled while it waited
+	}
+
+	tracker := &aiJobTracker{repo: repo, job: *job}
+	if ctx.Err() != nil {
+		// A job the server stopped before it started stays queued, to be
+		// resumed
+		r.mu.Lock()
+		stopping := r.stopping
+		r.mu.Unlock()
+		if !stopping {
+			tracker.finish(nil, "", ctx.Err(), true)
+		}
+		return
+	}
+	tracker.start()
+
+	exitCode, diff, err := r.execute(ctx, &tracker.job, tracker)
+
+	r.mu.Lock()
+	stopping := r.stopping
+	r.mu.Unlock()
+	switch {
+	case err == nil && exitCode != nil && *exitCode != 0:
+		err = fmt.Errorf("agent exited with code %d", *exitCode)
+	case err == nil:
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("job timed out after %s", r.timeout)
+	case ctx.Err() != nil && stopping:
+		err = fmt.Errorf("interrupted by server shutdown")
+	}
+	tracker.finish(exitCode, diff, err, ctx.Err() == context.Canceled && !stopping)
+}
+
+// execute runs a job's agent in the worktree's AI container, returning the
+// agent's exit code, if it ran, and the changes it made
+func (r *AIJobRunner) execute(ctx context.Context, job *db.AIJob, transcript *aiJobTracker) (*int, string, error) {
+	worktree, err := db.NewWorktreeRepository(r.db).Get(ctx, job.WorktreeID)
+	if err != nil {
+		return nil, "", err
+	}
+	repository, err := db.NewRepositoryRepository(r.db).GetByID(ctx, worktree.RepositoryID)
+	if err != nil {
+		return nil, "", err
+	}
+	repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load repository config: %w", err)
+	}
+	_, agent, err := repoConfig.Repository.AI.ResolveAgent(job.Agent)
+	if err != nil {
+		return nil, "", err
+	}
+	command, ok := agent.HeadlessCommand()
+	if !ok {
+		return nil, "", fmt.Errorf("agent %q cannot run headless", job.Agent)
+	}
+
+	// The diff covers commits the agent makes as well as uncommitted changes
+	base, _ := gitOutput(ctx, worktree.Path, "rev-parse", "HEAD")
+	base = strings.TrimSpace(base)
+
+	logger.WithFields(logger.Fields{
+		"job_id":   job.ID,
+		"worktree": worktree.Name,
+		"agent":    job.Agent,
+	}).Info("Starting AI job")
+
+	cmd := r.command(ctx, AIContainerName(repository.Name, worktree.Name), job.ID, command)
+	cmd.Stdin = strings.NewReader(job.Prompt)
+	cmd.Stdout = transcript
+	cmd.Stderr = transcript
+
+	flushCtx, stopFlushing := context.WithCancel(ctx)
+	go transcript.flushEvery(flushCtx, aiJobFlushInterval)
+
+	err = telemetry.Run(ctx, cmd)
+	stopFlushing()
+
+	var exitCode *int
+	if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() >= 0 {
+		code := cmd.ProcessState.ExitCode()
+		exitCode = &code
+		if _, exited := err.(*exec.ExitError); exited {
+			err = nil // Reported through the exit code
+		}
+	}
+
+	// Record what the agent changed even when it failed or was cancelled
+	diffCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, instructions := instructionsFile(repoConfig)
+	diff, diffErr := worktreeDiff(diffCtx, worktree.Path, base, instructions)
+	if diffErr != nil {
+		logger.WithError(diffErr).WithField("job_id", job.ID).Warn("Failed to capture AI job diff")
+	}
+
+	return exitCode, diff, err
+}
+
+// aiJobCommand runs an agent in an AI container with docker exec. The agent
+// records its PID in the container, so cancelling the job stops the agent
+// itself and not only the docker client.
+func aiJobCommand(ctx context.Context, container, jobID string, command []string) *exec.Cmd {
+	pidFile := "/tmp/vibeman-ai-job-" + jobID + ".pid"
+	args := append([]string{"exec", "-i", "-w", "/workspace", container, "sh", "-c", `echo $$ > "$0"; exec "$@"`, pidFile}, command...)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Cancel = func() error {
+		stop := exec.Command("docker", "exec", container, "sh", "-c", `kill -TERM "$(cat "$0")"`, pidFile)
+		if err := stop.Run(); err != nil {
+			logger.WithError(err).WithField("job_id", jobID).Warn("Failed to stop AI job agent")
+		}
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = 10 * time.Second
+	return cmd
+}
+
+// worktreeDiff returns the changes in a worktree since base: commits, edits
+// and new files alike, except for the new files vibeman generates. Without a
+// base only uncommitted edits are included. generated names further files
+// vibeman wrote, such as the agent's instructions.
+func worktreeDiff(ctx context.Context, path, base string, generated ...string) (string, error) {
+	args := []string{"diff", "--no-color"}
+	if base != "" {
+		args = append(args, base)
+	}
+	diff, err := gitOutput(ctx, path, args...)
+	if err != nil {
+		return "", err
+	}
+
+	untracked, err := gitOutput(ctx, path, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString(diff)
+	for _, file := range strings.Split(untracked, "\x00") {
+		// The service env file holds credentials, so it never goes into a
+		// diff, even where git does not ignore it, and neither do the other
+		// files vibeman generates
+		if file == "" || file == ServiceEnvFile || slices.Contains(generated, file) {
+			continue
+		}
+		// git diff --no-index exits with 1 when the files differ
+		added, err := gitOutput(ctx, path, "diff", "--no-color", "--no-index", "--", "/dev/null", file)
+		if exitErr, ok := err.(*exec.ExitError); err != nil && (!ok || exitErr.ExitCode() != 1) {
+			return "", err
+		}
+		out.WriteString(added)
+	}
+	return truncateHead(out.String(), maxAIJobDiff, "\n[diff truncated]\n"), nil
+}
+
+// gitOutput runs git in a directory and returns its output
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	out, err := telemetry.Output(ctx, cmd)
+	return string(out), err
+}
+
+// truncateHead keeps the first max bytes of s, marking the cut
+func truncateHead(s string, max int, marker string) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + marker
+}
+
+// aiJobTracker persists the progress of a single job and collects its
+// transcript, keeping the most recent output when it grows too long
+type aiJobTracker struct {
+	repo      *db.AIJobRepository
+	mu        sync.Mutex
+	job       db.AIJob
+	output    bytes.Buffer
+	truncated bool
+	dirty     bool
+}
+
+// Write implements io.Writer for the agent's output
+func (t *aiJobTracker) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.output.Write(p)
+	if excess := t.output.Len() - maxAIJobTranscript; excess > 0 {
+		t.output.Next(excess)
+		t.truncated = true
+	}
+	t.dirty = true
+	return len(p), nil
+}
+
+// flushEvery saves the transcript whenever it changed, until ctx is done
+func (t *aiJobTracker) flushEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			if t.dirty {
+				t.save()
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// start marks the job running
+func (t *aiJobTracker) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.job.Status = db.AIJobRunning
+	t.job.StartedAt = &now
+	t.save()
+}
+
+// finish records the outcome of the job
+func (t *aiJobTracker) finish(exitCode *int, diff string, err error, cancelled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.job.FinishedAt = &now
+	t.job.ExitCode = exitCode
+	t.job.Diff = diff
+
+	switch {
+	case cancelled:
+		t.job.Status = db.AIJobCancelled
+		t.job.Error = "job cancelled"
+	case err != nil:
+		t.job.Status = db.AIJobFailed
+		t.job.Error = err.Error()
+	default:
+		t.job.Status = db.AIJobSucceeded
+	}
+	t.save()
+
+	logger.WithFields(logger.Fields{
+		"job_id": t.job.ID,
+		"status": t.job.Status,
+	}).Info("AI job finished")
+}
+
+// save persists the tracked job, detached from cancellation so the final
+// state is written even when the job was cancelled. Callers must hold t.mu.
+func (t *aiJobTracker) save() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.job.Transcript = t.output.String()
+	if t.truncated {
+		t.job.Transcript = "[earlier output truncated]\n" + t.job.Transcript
+	}
+	t.dirty = false
+	if err := t.repo.Update(ctx, &t.job); err != nil {
+		logger.WithError(err).WithField("job_id", t.job.ID).Warn("Failed to persist AI job progress")
+	}
+}
@@ -0,0 +1,263 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/errors"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAIJobWorktree creates a tracked worktree backed by a git repository
+// whose AI config declares a headless "fake" agent
+func setupAIJobWorktree(t *testing.T, database *db.DB, aiConfig string) *db.Worktree {
+	t.Helper()
+
+	dir := initAIJobRepo(t, aiConfig)
+	ctx := context.Background()
+	worktree := &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: dir, Status: db.StatusRunning}
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "app", Path: dir, Name: "app"}))
+	require.NoError(t, db.NewWorktreeRepository(database).Create(ctx, worktree))
+	return worktree
+}
+
+// initAIJobRepo creates a git repository with a committed README and
+// vibeman.toml
+func initAIJobRepo(t *testing.T, aiConfig string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vibeman.toml"), []byte(`[repository]
+name = "app"
+
+`+aiConfig), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# app\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return dir
+}
+
+const fakeAgentConfig = `[repository.ai.agents.fake]
+command = ["fake-agent"]
+headless = []
+`
+
+// newTestAIJobRunner creates a runner that runs script with sh in the
+// worktree instead of the agent in its AI container
+func newTestAIJobRunner(database *db.DB, worktree *db.Worktree, maxJobs int, script string) *AIJobRunner {
+	runner := NewAIJobRunner(database, maxJobs, time.Minute)
+	runner.SetCommand(func(ctx context.Context, container, jobID string, command []string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "sh", "-c", script)
+		cmd.Dir = worktree.Path
+		return cmd
+	})
+	return runner
+}
+
+// waitForAIJob polls until the job has reached status
+func waitForAIJob(t *testing.T, runner *AIJobRunner, id string, status db.AIJobStatus) *db.AIJob {
+	t.Helper()
+
+	var job *db.AIJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = runner.Get(context.Background(), id)
+		require.NoError(t, err)
+		return job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return job
+}
+
+func TestAIJobRunner_RecordsTranscriptAndDiff(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+	runner := newTestAIJobRunner(database, worktree, 2, `cat; echo "## usage" >> README.md; echo new > notes.txt; echo PASSWORD=s3cret > .env.vibeman; echo generated > CLAUDE.md`)
+
+	job, err := runner.Enqueue(context.Background(), AIJobRequest{WorktreeID: worktree.ID, Prompt: "document usage\n", Agent: "fake"})
+	require.NoError(t, err)
+	assert.Equal(t, "fake", job.Agent)
+
+	final := waitForAIJob(t, runner, job.ID, db.AIJobSucceeded)
+	require.NotNil(t, final.ExitCode)
+	assert.Equal(t, 0, *final.ExitCode)
+	assert.Equal(t, "document usage\n", final.Transcript)
+	assert.Contains(t, final.Diff, "+## usage")
+	assert.Contains(t, final.Diff, "notes.txt")
+	assert.NotContains(t, final.Diff, "s3cret", "service credentials stay out of diffs")
+	assert.NotContains(t, final.Diff, "CLAUDE.md", "generated instructions stay out of diffs")
+	assert.NotNil(t, final.StartedAt)
+	assert.NotNil(t, final.FinishedAt)
+
+	require.NoError(t, runner.Shutdown(context.Background()))
+}
+
+func TestAIJobRunner_FailedAgent(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+	runner := newTestAIJobRunner(database, worktree, 1, `echo oops >&2; exit 3`)
+
+	job, err := runner.Enqueue(context.Background(), AIJobRequest{WorktreeID: worktree.ID, Prompt: "break", Agent: "fake"})
+	require.NoError(t, err)
+
+	final := waitForAIJob(t, runner, job.ID, db.AIJobFailed)
+	require.NotNil(t, final.ExitCode)
+	assert.Equal(t, 3, *final.ExitCode)
+	assert.Equal(t, "oops\n", final.Transcript)
+	assert.Contains(t, final.Error, "exited with code 3")
+
+	require.NoError(t, runner.Shutdown(context.Background()))
+}
+
+func TestAIJobRunner_ConcurrencyAndCancel(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+	runner := newTestAIJobRunner(database, worktree, 1, `exec sleep 30`)
+	ctx := context.Background()
+
+	first, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "one", Agent: "fake"})
+	require.NoError(t, err)
+	second, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "two", Agent: "fake"})
+	require.NoError(t, err)
+
+	// Only one job runs at a time
+	waitForAIJob(t, runner, first.ID, db.AIJobRunning)
+	queued, err := runner.Get(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.AIJobQueued, queued.Status)
+
+	cancelled, err := runner.Cancel(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.AIJobCancelled, cancelled.Status)
+
+	_, err = runner.Cancel(ctx, first.ID)
+	require.NoError(t, err)
+	final := waitForAIJob(t, runner, first.ID, db.AIJobCancelled)
+	assert.NotNil(t, final.FinishedAt)
+
+	// Finished jobs cannot be cancelled again
+	_, err = runner.Cancel(ctx, first.ID)
+	assert.True(t, errors.HasCode(err, errors.ErrInvalidState))
+
+	jobs, err := runner.List(ctx, worktree.ID, "")
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	require.NoError(t, runner.Shutdown(ctx))
+}
+
+func TestAIJobRunner_CancelledBeforeStart(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+	runner := newTestAIJobRunner(database, worktree, 1, `echo ran`)
+	ctx := context.Background()
+	require.NoError(t, db.NewAIJobRepository(database).Create(ctx, &db.AIJob{ID: "job", WorktreeID: worktree.ID, Agent: "fake", Prompt: "a", Status: db.AIJobQueued}))
+
+	// Cancelled once dispatched, before the job was loaded
+	jobCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	runner.run(jobCtx, "job")
+
+	job, err := runner.Get(ctx, "job")
+	require.NoError(t, err)
+	assert.Equal(t, db.AIJobCancelled, job.Status)
+	assert.Empty(t, job.Transcript)
+	assert.NotNil(t, job.FinishedAt)
+}
+
+func TestAIJobRunner_OneJobPerWorktree(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+	other := &db.Worktree{ID: "wt-other", RepositoryID: "app", Name: "other", Branch: "other", Path: worktree.Path, Status: db.StatusRunning}
+	require.NoError(t, db.NewWorktreeRepository(database).Create(context.Background(), other))
+	runner := newTestAIJobRunner(database, worktree, 3, `exec sleep 30`)
+	ctx := context.Background()
+
+	first, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "one", Agent: "fake"})
+	require.NoError(t, err)
+	second, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "two", Agent: "fake"})
+	require.NoError(t, err)
+	elsewhere, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: other.ID, Prompt: "three", Agent: "fake"})
+	require.NoError(t, err)
+
+	// The second job waits for the first, though slots are free, while a
+	// job in another worktree runs alongside it
+	waitForAIJob(t, runner, first.ID, db.AIJobRunning)
+	waitForAIJob(t, runner, elsewhere.ID, db.AIJobRunning)
+	queued, err := runner.Get(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.AIJobQueued, queued.Status)
+
+	_, err = runner.Cancel(ctx, first.ID)
+	require.NoError(t, err)
+	waitForAIJob(t, runner, second.ID, db.AIJobRunning)
+
+	require.NoError(t, runner.Shutdown(ctx))
+}
+
+func TestAIJobRunner_Enqueue_Validation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("requires a prompt", func(t *testing.T) {
+		database := testutil.SetupTestDB(t)
+		worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+		runner := newTestAIJobRunner(database, worktree, 1, "true")
+
+		_, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "  \n"})
+		assert.True(t, errors.HasCode(err, errors.ErrInvalidInput))
+	})
+
+	t.Run("rejects agents that cannot run headless", func(t *testing.T) {
+		database := testutil.SetupTestDB(t)
+		worktree := setupAIJobWorktree(t, database, `[repository.ai.agents.interactive]
+command = ["interactive-agent"]
+`)
+		runner := newTestAIJobRunner(database, worktree, 1, "true")
+
+		_, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "hi", Agent: "interactive"})
+		assert.True(t, errors.HasCode(err, errors.ErrInvalidInput))
+	})
+
+	t.Run("requires the AI container", func(t *testing.T) {
+		database := testutil.SetupTestDB(t)
+		worktree := setupAIJobWorktree(t, database, "[repository.ai]\nenabled = false\n")
+		runner := newTestAIJobRunner(database, worktree, 1, "true")
+
+		_, err := runner.Enqueue(ctx, AIJobRequest{WorktreeID: worktree.ID, Prompt: "hi"})
+		assert.True(t, errors.HasCode(err, errors.ErrInvalidState))
+	})
+}
+
+func TestAIJobRunner_Resume(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	worktree := setupAIJobWorktree(t, database, fakeAgentConfig)
+	ctx := context.Background()
+
+	repo := db.NewAIJobRepository(database)
+	require.NoError(t, repo.Create(ctx, &db.AIJob{ID: "interrupted", WorktreeID: worktree.ID, Agent: "fake", Prompt: "a", Status: db.AIJobRunning}))
+	require.NoError(t, repo.Create(ctx, &db.AIJob{ID: "waiting", WorktreeID: worktree.ID, Agent: "fake", Prompt: "b", Status: db.AIJobQueued}))
+
+	runner := newTestAIJobRunner(database, worktree, 1, "cat")
+	require.NoError(t, runner.Resume(ctx))
+
+	interrupted := waitForAIJob(t, runner, "interrupted", db.AIJobFailed)
+	assert.Contains(t, interrupted.Error, "interrupted")
+	resumed := waitForAIJob(t, runner, "waiting", db.AIJobSucceeded)
+	assert.Equal(t, "b", resumed.Transcript)
+
+	require.NoError(t, runner.Shutdown(ctx))
+}
@@ -0,0 +1,466 @@
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"vibeman/internal/config"
+	"vibeman/internal/constants"
+	"vibeman/internal/db"
+	"vibeman/internal/mcp"
+	"vibeman/internal/service"
+	"vibeman/internal/xdg"
+)
+
+// MCPPath is the API path of vibeman's MCP server
+const MCPPath = "/api/mcp"
+
+const (
+	// defaultMCPLogLines and maxMCPLogLines bound how much of a container's
+	// log tail_logs returns
+	defaultMCPLogLines = 100
+	maxMCPLogLines     = 2000
+
+	// defaultMCPOperations is how many setup operations setup_history
+	// returns when not asked for a number
+	defaultMCPOperations = 5
+)
+
+// IssueMCPToken creates the token a worktree's agents call the MCP server
+// with, replacing any earlier one, and limits its tools to scope. Only its
+// hash is stored.
+func IssueMCPToken(ctx context.Context, database *db.DB, worktreeID string, scope db.MCPScope) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate mcp token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	if err := db.NewMCPTokenRepository(database).Set(ctx, worktreeID, HashMCPToken(token), scope); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// HashMCPToken returns the hash an MCP token is stored as
+func HashMCPToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// mcpScope returns what the MCP tools of a worktree with repoConfig may
+// touch: the services it uses and its test command
+func mcpScope(repoConfig *config.RepositoryConfig) db.MCPScope {
+	return db.MCPScope{
+		Services:    repoConfig.Repository.Services.Names(),
+		TestCommand: repoConfig.Repository.AI.Test,
+	}
+}
+
+// MCPWorktree returns the ID of the worktree an MCP token was issued to
+func MCPWorktree(ctx context.Context, database *db.DB, token string) (string, error) {
+	return db.NewMCPTokenRepository(database).GetWorktreeID(ctx, HashMCPToken(token))
+}
+
+// aiMCPConfigPath returns where the MCP configuration of a worktree's AI
+// container is kept on the host
+func aiMCPConfigPath(worktreeID string) (string, error) {
+	stateDir, err := xdg.StateDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get state directory: %w", err)
+	}
+	return filepath.Join(stateDir, "mcp", worktreeID+".json"), nil
+}
+
+// WriteAIMCPConfig writes the MCP configuration mounted into a worktree's AI
+// container at config.AIMCPConfigPath, which points agents at the MCP
+// server at url, and returns its path on the host
+func WriteAIMCPConfig(worktreeID, url, token string) (string, error) {
+	path, err := aiMCPConfigPath(worktreeID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create mcp directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"mcpServers": map[string]any{
+			"vibeman": map[string]any{
+				"type":    "http",
+				"url":     url,
+				"headers": map[string]string{"Authorization": "Bearer " + token},
+			},
+		},
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	// The file holds a live token, so only its owner may read it. WriteFile
+	// keeps the mode of a file left by an earlier start.
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", fmt.Errorf("failed to write mcp config: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return "", fmt.Errorf("failed to restrict mcp config: %w", err)
+	}
+	return path, nil
+}
+
+// RevokeAIMCPAccess revokes a worktree's MCP token and removes the MCP
+// configuration of its AI container
+func RevokeAIMCPAccess(ctx context.Context, database *db.DB, worktreeID string) error {
+	if err := db.NewMCPTokenRepository(database).Delete(ctx, worktreeID); err != nil {
+		return err
+	}
+	path, err := aiMCPConfigPath(worktreeID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove mcp config: %w", err)
+	}
+	return nil
+}
+
+// aiMCPAccess issues a worktree a new MCP token limited to scope and writes
+// the MCP configuration of its AI container, returning the environment
+// variables and volume mount that give its agents access to the MCP server
+func aiMCPAccess(ctx context.Context, database *db.DB, worktreeID string, scope db.MCPScope) ([]string, string, error) {
+	port := constants.DefaultServerPort
+	if globalCfg, err := config.LoadGlobalConfig(); err == nil && globalCfg.Server.Port != 0 {
+		port = globalCfg.Server.Port
+	}
+	url := fmt.Sprintf("http://%s:%d%s", constants.ContainerHostGateway, port, MCPPath)
+
+	token, err := IssueMCPToken(ctx, database, worktreeID, scope)
+	if err != nil {
+		return nil, "", err
+	}
+	path, err := WriteAIMCPConfig(worktreeID, url, token)
+	if err != nil {
+		return nil, "", err
+	}
+	env := []string{"VIBEMAN_MCP_URL=" + url, "VIBEMAN_MCP_TOKEN=" + token}
+	return env, path + ":" + config.AIMCPConfigPath + ":ro", nil
+}
+
+// AgentTools are the MCP tools that let the agents in a worktree's AI
+// container inspect and manage their environment
+type AgentTools struct {
+	db           *db.DB
+	containerMgr ContainerManager
+	serviceMgr   ServiceManager
+	services     *ServiceOperations
+	command      AIJobCommandFunc
+}
+
+// NewAgentTools creates the tools agents call through the MCP server
+func NewAgentTools(database *db.DB, cm ContainerManager, sm ServiceManager, cfg *config.Manager) *AgentTools {
+	return &AgentTools{
+		db:           database,
+		containerMgr: cm,
+		serviceMgr:   sm,
+		services:     NewServiceOperations(cfg, sm),
+		command:      aiJobCommand,
+	}
+}
+
+// SetCommand replaces how commands are run in AI containers, e.g. in tests
+func (t *AgentTools) SetCommand(command AIJobCommandFunc) {
+	t.command = command
+}
+
+// Server returns the MCP server the agents of a worktree call
+func (t *AgentTools) Server(worktreeID string) *mcp.Server {
+	return mcp.NewServer("vibeman", "1.0.0", t.Tools(worktreeID)...)
+}
+
+// Tools returns the tools for the agents of a worktree, each limited to
+// that worktree's containers and the scope of its token
+func (t *AgentTools) Tools(worktreeID string) []mcp.Tool {
+	noArgs := map[string]any{"type": "object", "properties": map[string]any{}}
+	return []mcp.Tool{
+		{
+			Name:        "list_services",
+			Description: "List the services this worktree uses, such as databases and caches, with their status and the environment variables to connect to them from this container.",
+			InputSchema: noArgs,
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return t.listServices(ctx, worktreeID)
+			},
+		},
+		{
+			Name:        "tail_logs",
+			Description: "Show the last lines of the log of one of this worktree's containers, or of a service it uses.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"container": map[string]any{"type": "string", "description": "Container name, or the name of a service from list_services"},
+					"lines":     map[string]any{"type": "integer", "description": fmt.Sprintf("Number of lines to show (default %d, at most %d)", defaultMCPLogLines, maxMCPLogLines)},
+				},
+				"required": []string{"container"},
+			},
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var params struct {
+					Container string `json:"container"`
+					Lines     int    `json:"lines"`
+				}
+				if err := mcp.DecodeArgs(args, &params); err != nil {
+					return "", err
+				}
+				return t.tailLogs(ctx, worktreeID, params.Container, params.Lines)
+			},
+		},
+		{
+			Name:        "restart_service",
+			Description: "Restart a service only this worktree uses, e.g. after changing its configuration or when it stopped responding. Services other worktrees use too are left to users to restart.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"service": map[string]any{"type": "string", "description": "Name of a service from list_services"},
+				},
+				"required": []string{"service"},
+			},
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var params struct {
+					Service string `json:"service"`
+				}
+				if err := mcp.DecodeArgs(args, &params); err != nil {
+					return "", err
+				}
+				return t.restartService(ctx, worktreeID, params.Service)
+			},
+		},
+		{
+			Name:        "run_tests",
+			Description: "Run the repository's configured test command in this container and return its exit code and the end of its output.",
+			InputSchema: noArgs,
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return t.runTests(ctx, worktreeID)
+			},
+		},
+		{
+			Name:        "setup_history",
+			Description: "Show the most recent operations that created, started and set up this worktree, with the status and errors of each step.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"limit": map[string]any{"type": "integer", "description": fmt.Sprintf("Number of operations to show (default %d)", defaultMCPOperations)},
+				},
+			},
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				var params struct {
+					Limit int `json:"limit"`
+				}
+				if err := mcp.DecodeArgs(args, &params); err != nil {
+					return "", err
+				}
+				return t.setupHistory(ctx, worktreeID, params.Limit)
+			},
+		},
+	}
+}
+
+// mcpService is a service as list_services reports it
+type mcpService struct {
+	Name    string                `json:"name"`
+	Status  service.ServiceStatus `json:"status"`
+	Health  string                `json:"health,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Restart int                   `json:"restarts,omitempty"`
+}
+
+// listServices reports the services the worktree uses and how to reach
+// them from its AI container
+func (t *AgentTools) listServices(ctx context.Context, worktreeID string) (string, error) {
+	_, _, scope, err := t.worktree(ctx, worktreeID)
+	if err != nil {
+		return "", err
+	}
+	names := []string(scope.Services)
+
+	services := make([]mcpService, 0, len(names))
+	for _, name := range names {
+		info := mcpService{Name: name, Status: service.StatusStopped}
+		if instanceInterface, err := t.serviceMgr.GetService(name); err == nil {
+			if instance, ok := instanceInterface.(*service.ServiceInstance); ok {
+				info.Status = instance.Status
+				info.Health = string(instance.Health)
+				info.Error = instance.HealthError
+				info.Restart = instance.Restarts
+			}
+		}
+		services = append(services, info)
+	}
+
+	var env map[string]string
+	if discoverer, ok := t.serviceMgr.(ServiceDiscoverer); ok && len(names) > 0 {
+		if env, err = discoverer.ServiceEnv(ctx, worktreeID, names, true); err != nil {
+			return "", fmt.Errorf("failed to resolve service connection details: %w", err)
+		}
+	}
+
+	return toolJSON(map[string]any{"services": services, "env": env})
+}
+
+// tailLogs returns the last lines of the log of a container of the
+// worktree, or of a service it uses
+func (t *AgentTools) tailLogs(ctx context.Context, worktreeID, name string, lines int) (string, error) {
+	if lines <= 0 {
+		lines = defaultMCPLogLines
+	}
+	lines = min(lines, maxMCPLogLines)
+
+	worktree, repo, scope, err := t.worktree(ctx, worktreeID)
+	if err != nil {
+		return "", err
+	}
+
+	var containerID string
+	if slices.Contains(scope.Services, name) {
+		instanceInterface, err := t.serviceMgr.GetService(name)
+		if err != nil {
+			return "", fmt.Errorf("service %s is not running", name)
+		}
+		instance, ok := instanceInterface.(*service.ServiceInstance)
+		if !ok || instance.ContainerID == "" {
+			return "", fmt.Errorf("service %s has no container", name)
+		}
+		containerID = instance.ContainerID
+	} else {
+		containers, err := t.containerMgr.List(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, c := range containers {
+			if c.Name == name && isWorktreeContainer(c, repo.Name, worktree.Name) {
+				containerID = c.ID
+				break
+			}
+		}
+		if containerID == "" {
+			return "", fmt.Errorf("container %s not found: expected one of this worktree's containers or services", name)
+		}
+	}
+
+	logs, err := t.containerMgr.Logs(ctx, containerID, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs: %w", err)
+	}
+	return lastLines(string(logs), lines), nil
+}
+
+// restartService restarts a service the worktree uses, as long as nothing
+// else holds a reference to it: restarting a shared service would disrupt
+// every other worktree using it
+func (t *AgentTools) restartService(ctx context.Context, worktreeID, name string) (string, error) {
+	_, _, scope, err := t.worktree(ctx, worktreeID)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(scope.Services, name) {
+		return "", fmt.Errorf("service %s not found: this worktree uses %s", name, formatList(scope.Services))
+	}
+	holders, err := db.NewServiceReferenceRepository(t.db).ListHolders(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if len(holders) != 1 || holders[0] != worktreeID {
+		return "", fmt.Errorf("service %s is not used by this worktree alone, so only a user may restart it", name)
+	}
+	if err := t.services.RestartService(ctx, name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Restarted service %s", name), nil
+}
+
+// runTests runs the repository's test command in the worktree's AI
+// container
+func (t *AgentTools) runTests(ctx context.Context, worktreeID string) (string, error) {
+	worktree, repo, scope, err := t.worktree(ctx, worktreeID)
+	if err != nil {
+		return "", err
+	}
+	testCommand := scope.TestCommand
+	if testCommand == "" {
+		return "", fmt.Errorf("no test command configured: set test under [repository.ai] in vibeman.toml and restart the worktree")
+	}
+
+	exitCode, output, err := runInAIContainer(ctx, t.command, AIContainerName(repo.Name, worktree.Name), "mcp-test-"+worktreeID, testCommand)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$ %s\nexit code: %d\n\n%s", testCommand, *exitCode, output), nil
+}
+
+// setupHistory returns the worktree's most recent operations with their
+// steps
+func (t *AgentTools) setupHistory(ctx context.Context, worktreeID string, limit int) (string, error) {
+	if limit <= 0 {
+		limit = defaultMCPOperations
+	}
+	operations, err := db.NewOperationRepository(t.db).List(ctx, "", worktreeID)
+	if err != nil {
+		return "", err
+	}
+	if len(operations) > limit {
+		operations = operations[:limit]
+	}
+	if len(operations) == 0 {
+		return "No operations recorded for this worktree", nil
+	}
+	return toolJSON(operations)
+}
+
+// worktree loads a worktree, its repository and the scope of its token. The
+// scope comes from the database rather than the worktree's vibeman.toml,
+// which the agents can edit.
+func (t *AgentTools) worktree(ctx context.Context, worktreeID string) (*db.Worktree, *db.Repository, *db.MCPScope, error) {
+	worktree, err := db.NewWorktreeRepository(t.db).Get(ctx, worktreeID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	repo, err := db.NewRepositoryRepository(t.db).GetByID(ctx, worktree.RepositoryID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	scope, err := db.NewMCPTokenRepository(t.db).GetScope(ctx, worktreeID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return worktree, repo, scope, nil
+}
+
+// toolJSON formats a tool's result as indented JSON
+func toolJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// lastLines returns the last n lines of s
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatList joins names for a message, or says there are none
+func formatList(names []string) string {
+	if len(names) == 0 {
+		return "no services"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,206 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vibeman/internal/config"
+	"vibeman/internal/container"
+	"vibeman/internal/db"
+	"vibeman/internal/mcp"
+	"vibeman/internal/service"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// agentToolsServiceManager runs postgres and tells worktrees how to reach
+// it
+type agentToolsServiceManager struct {
+	*testutil.MockServiceManager
+}
+
+func (m *agentToolsServiceManager) GetService(name string) (interface{}, error) {
+	if name != "postgres" {
+		return nil, fmt.Errorf("service not found: %s", name)
+	}
+	return &service.ServiceInstance{Name: name, Status: service.StatusRunning, ContainerID: "pg-1"}, nil
+}
+
+func (m *agentToolsServiceManager) ServiceEnv(ctx context.Context, worktreeID string, services []string, inContainer bool) (map[string]string, error) {
+	return map[string]string{"DATABASE_URL": "postgres://host.docker.internal/" + worktreeID}, nil
+}
+
+// callTool calls one of the tools by name
+func callTool(t *testing.T, tools []mcp.Tool, name, args string) (string, error) {
+	t.Helper()
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool.Handler(context.Background(), json.RawMessage(args))
+		}
+	}
+	t.Fatalf("tool %s not found", name)
+	return "", nil
+}
+
+func TestAgentTools(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	worktreePath := t.TempDir()
+	configPath := filepath.Join(worktreePath, "vibeman.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+[repository]
+name = "app"
+
+[repository.ai]
+test = "echo ok; exit 3"
+
+[repository.services]
+postgres = { required = true }
+`), 0644))
+	repoConfig, err := config.ParseRepositoryConfig(worktreePath)
+	require.NoError(t, err)
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "repo-1", Name: "app", Path: "/tmp/app"}))
+	require.NoError(t, db.NewWorktreeRepository(database).Create(ctx, &db.Worktree{
+		ID: "wt-1", RepositoryID: "repo-1", Name: "feature", Branch: "feature", Path: worktreePath, Status: db.StatusRunning,
+	}))
+	_, err = IssueMCPToken(ctx, database, "wt-1", mcpScope(repoConfig))
+	require.NoError(t, err)
+	references := db.NewServiceReferenceRepository(database)
+	require.NoError(t, references.Add(ctx, "postgres", "wt-1"))
+	require.NoError(t, db.NewOperationRepository(database).Create(ctx, &db.Operation{
+		ID: "op-1", Type: "worktree.start", TargetID: "wt-1", Status: db.OperationFailed, Error: "setup failed",
+	}))
+
+	containerMgr := &testutil.MockContainerManager{
+		ListReturn: []*container.Container{
+			{ID: "ai-1", Name: "app-feature-ai"},
+			{ID: "other-1", Name: "other-main-ai"},
+		},
+		LogsFn: func(ctx context.Context, containerID string, follow bool) ([]byte, error) {
+			return []byte(containerID + " line 1\n" + containerID + " line 2\n"), nil
+		},
+	}
+	serviceMgr := &agentToolsServiceManager{MockServiceManager: testutil.NewMockServiceManager()}
+	serviceMgr.On("StartService", mock.Anything, "postgres").Return(nil)
+
+	agentTools := NewAgentTools(database, containerMgr, serviceMgr, &config.Manager{})
+	var ran []string
+	agentTools.SetCommand(func(ctx context.Context, container, jobID string, command []string) *exec.Cmd {
+		ran = append(ran, container)
+		return exec.CommandContext(ctx, command[0], command[1:]...)
+	})
+	tools := agentTools.Tools("wt-1")
+
+	out, err := callTool(t, tools, "list_services", `{}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"name": "postgres"`)
+	assert.Contains(t, out, `"status": "running"`)
+	assert.Contains(t, out, `"DATABASE_URL": "postgres://host.docker.internal/wt-1"`)
+
+	// Logs are limited to the worktree's own containers and services
+	out, err = callTool(t, tools, "tail_logs", `{"container": "app-feature-ai", "lines": 1}`)
+	require.NoError(t, err)
+	assert.Equal(t, "ai-1 line 2", out)
+	out, err = callTool(t, tools, "tail_logs", `{"container": "postgres"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "pg-1 line 1\npg-1 line 2", out)
+	_, err = callTool(t, tools, "tail_logs", `{"container": "other-main-ai"}`)
+	assert.ErrorContains(t, err, "not found")
+
+	_, err = callTool(t, tools, "restart_service", `{"service": "redis"}`)
+	assert.ErrorContains(t, err, "this worktree uses postgres")
+	out, err = callTool(t, tools, "restart_service", `{"service": "postgres"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Restarted service postgres", out)
+
+	// Services other worktrees use are shared, so agents may not restart them
+	require.NoError(t, references.Add(ctx, "postgres", "wt-2"))
+	_, err = callTool(t, tools, "restart_service", `{"service": "postgres"}`)
+	assert.ErrorContains(t, err, "not used by this worktree alone")
+	serviceMgr.AssertNumberOfCalls(t, "StartService", 1)
+
+	out, err = callTool(t, tools, "run_tests", `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app-feature-ai"}, ran)
+	assert.True(t, strings.HasPrefix(out, "$ echo ok; exit 3\nexit code: 3\n"), out)
+	assert.Contains(t, out, "ok")
+
+	out, err = callTool(t, tools, "setup_history", `{}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"error": "setup failed"`)
+
+	_, err = callTool(t, tools, "setup_history", `{"unknown": 1}`)
+	assert.ErrorContains(t, err, "invalid arguments")
+
+	// Agents can edit vibeman.toml, but the scope stays what the host saw
+	// when the token was issued
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+[repository]
+name = "app"
+
+[repository.ai]
+test = "cat /etc/passwd"
+
+[repository.services]
+postgres = { required = true }
+redis = { required = true }
+`), 0644))
+	_, err = callTool(t, tools, "restart_service", `{"service": "redis"}`)
+	assert.ErrorContains(t, err, "this worktree uses postgres")
+	out, err = callTool(t, tools, "run_tests", `{}`)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out, "$ echo ok; exit 3\n"), out)
+}
+
+func TestMCPTokens(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	database := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "repo-1", Name: "app", Path: "/tmp/app"}))
+	require.NoError(t, db.NewWorktreeRepository(database).Create(ctx, &db.Worktree{
+		ID: "wt-1", RepositoryID: "repo-1", Name: "feature", Branch: "feature", Path: "/tmp/app-feature", Status: db.StatusRunning,
+	}))
+
+	env, mount, err := aiMCPAccess(ctx, database, "wt-1", db.MCPScope{Services: []string{"postgres"}})
+	require.NoError(t, err)
+	require.Len(t, env, 2)
+	assert.Contains(t, env[0], "VIBEMAN_MCP_URL=http://host.docker.internal:")
+	token := strings.TrimPrefix(env[1], "VIBEMAN_MCP_TOKEN=")
+
+	worktreeID, err := MCPWorktree(ctx, database, token)
+	require.NoError(t, err)
+	assert.Equal(t, "wt-1", worktreeID)
+
+	hostPath, containerPath, _ := strings.Cut(mount, ":")
+	assert.Equal(t, config.AIMCPConfigPath+":ro", containerPath)
+	data, err := os.ReadFile(hostPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Authorization": "Bearer `+token+`"`)
+	info, err := os.Stat(hostPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the token is readable only by its owner")
+
+	// Restarting the worktree replaces its token
+	env, _, err = aiMCPAccess(ctx, database, "wt-1", db.MCPScope{})
+	require.NoError(t, err)
+	_, err = MCPWorktree(ctx, database, token)
+	assert.ErrorContains(t, err, "not found")
+
+	// Removing the worktree revokes it
+	token = strings.TrimPrefix(env[1], "VIBEMAN_MCP_TOKEN=")
+	require.NoError(t, RevokeAIMCPAccess(ctx, database, "wt-1"))
+	_, err = MCPWorktree(ctx, database, token)
+	assert.ErrorContains(t, err, "not found")
+	assert.NoFileExists(t, hostPath)
+	assert.NoError(t, RevokeAIMCPAccess(ctx, database, "wt-1"), "nothing left to revoke")
+}
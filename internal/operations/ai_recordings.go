@@ -0,0 +1,350 @@
+package operations
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibeman/internal/asciicast"
+	"vibeman/internal/errors"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+	"vibeman/internal/xdg"
+)
+
+// Where a recorded AI terminal session was attached from
+const (
+	AIRecordingSourceWeb      = "web"      // A WebSocket connection of a logged in user
+	AIRecordingSourceShared   = "shared"   // A WebSocket connection through a share link
+	AIRecordingSourceCLI      = "cli"      // 'vibeman ai' or 'vibeman ai attach'
+	AIRecordingSourceDetached = "detached" // Nobody was attached; the server found the session running
+)
+
+// aiRecordingTimeFormat starts recording file names, so they sort by time
+const aiRecordingTimeFormat = "20060102-150405.000000"
+
+// aiRecordingNamePattern matches the file names of recordings:
+// <time>-<session>-<source>.cast
+var aiRecordingNamePattern = regexp.MustCompile(`^(\d{8}-\d{6}\.\d{6})-([A-Za-z0-9_-]{1,64})-(web|shared|cli|detached)\.cast$`)
+
+// AIRecording is a recorded AI terminal session
+type AIRecording struct {
+	Name       string    `json:"name"`
+	Repository string    `json:"repository"`
+	Worktree   string    `json:"worktree"`
+	Session    string    `json:"session"`
+	Source     string    `json:"source"`
+	Size       int64     `json:"size"`
+	StartedAt  time.Time `json:"started_at"`
+	Duration   float64   `json:"duration"` // Seconds until the last recorded event
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Path       string    `json:"-"`
+}
+
+// AIRecordingsDir returns the directory holding the recorded AI terminal
+// sessions of a worktree. It is not under the logs directory, which AI
+// containers mount, since recordings hold what users type.
+func AIRecordingsDir(repoName, worktreeName string) string {
+	return filepath.Join(xdg.RecordingsDir(), repoName, worktreeName)
+}
+
+// NewAIRecording starts recording an AI terminal session of a worktree that
+// runs command in a cols x rows terminal. Recordings hold everything typed
+// into the terminal, so only the user may read them.
+func NewAIRecording(repoName, worktreeName, session, source string, cols, rows int, command []string) (*asciicast.Recorder, string, error) {
+	if err := ValidateAISessionName(session); err != nil {
+		return nil, "", err
+	}
+	switch source {
+	case AIRecordingSourceWeb, AIRecordingSourceShared, AIRecordingSourceCLI, AIRecordingSourceDetached:
+	default:
+		return nil, "", fmt.Errorf("invalid recording source %q", source)
+	}
+
+	dir := AIRecordingsDir(repoName, worktreeName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, "", fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	started := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s-%s.cast", started.UTC().Format(aiRecordingTimeFormat), session, source))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	recorder, err := asciicast.NewRecorder(file, asciicast.Header{
+		Width:     cols,
+		Height:    rows,
+		Timestamp: started.Unix(),
+		Command:   strings.Join(command, " "),
+		Title:     fmt.Sprintf("%s/%s %s", repoName, worktreeName, session),
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, "", fmt.Errorf("failed to write recording: %w", err)
+	}
+	return recorder, path, nil
+}
+
+// aiPipeScript streams what the pane of the session named $0 outputs from
+// now on. tmux pipes the pane into a FIFO that the script reads, so the
+// stream keeps going whether or not any client is attached, and ends with
+// the session. The script first waits for the session, which the attach
+// being recorded may still be starting, then prints the pane's size and
+// command on one line. It exits with aiPipeBusy if the pane is already piped,
+// since tmux pipes a pane to one place at a time.
+const aiPipeScript = `i=0
+until tmux has-session -t "=$0" 2>/dev/null; do
+	i=$((i+1)); [ $i -gt 50 ] && exit 1; sleep 0.1
+done
+[ "$(tmux display-message -p -t "=$0:" '#{pane_pipe}')" = 1 ] && exit 3
+f=$(mktemp -u /tmp/vibeman-recording-XXXXXX) && mkfifo -m 600 "$f" || exit 1
+tmux pipe-pane -o -t "=$0:" "cat > $f; rm -f $f" || exit 1
+tmux display-message -p -t "=$0:" '#{pane_width} #{pane_height} #{pane_start_command}'
+exec cat "$f"`
+
+// aiPipeBusy is the exit status of aiPipeScript for a pane that is already
+// piped
+const aiPipeBusy = 3
+
+// aiRecordingStopTimeout bounds how long stopping a recording waits for the
+// rest of the stream
+const aiRecordingStopTimeout = 5 * time.Second
+
+// aiPipeCommand returns the command that runs aiPipeScript for a session in
+// an AI container, replaced in tests
+var aiPipeCommand = func(ctx context.Context, container, session string) *exec.Cmd {
+	return exec.CommandContext(ctx, "docker", "exec", container, "sh", "-c", aiPipeScript, session)
+}
+
+// AISessionRecording records a persistent AI terminal session once, however
+// many clients attach to it: what its pane outputs, streamed by tmux even
+// while nobody is attached, and what is typed into it, which the clients
+// report. Its methods may be called on a nil AISessionRecording, which
+// records nothing.
+type AISessionRecording struct {
+	Path      string
+	container string
+	session   string
+	recorder  *asciicast.Recorder
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// RecordAISession starts recording a session of a worktree's AI container
+// until the session ends, Stop is called or ctx is done. It returns nil if
+// the session is already being recorded elsewhere.
+func RecordAISession(ctx context.Context, repoName, worktreeName, container, session, source string) (*AISessionRecording, error) {
+	if err := ValidateAISessionName(session); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := aiPipeCommand(ctx, container, session)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, errors.Wrap(errors.ErrContainerExecFailed, "failed to record terminal session", err).WithContext("container", container)
+	}
+
+	stream := bufio.NewReader(stdout)
+	var cols, rows int
+	var command string
+	line, err := stream.ReadString('\n')
+	if err == nil {
+		fields := strings.SplitN(strings.TrimSuffix(line, "\n"), " ", 3)
+		if len(fields) < 2 {
+			err = fmt.Errorf("unexpected pane description %q", line)
+		} else if cols, err = strconv.Atoi(fields[0]); err == nil {
+			rows, err = strconv.Atoi(fields[1])
+		}
+		if len(fields) == 3 {
+			command = fields[2]
+		}
+	}
+	if err != nil {
+		waitErr := cmd.Wait()
+		cancel()
+		if exitErr, ok := waitErr.(*exec.ExitError); ok && exitErr.ExitCode() == aiPipeBusy {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.ErrContainerExecFailed, "failed to record terminal session", err).WithContext("container", container).WithContext("session", session)
+	}
+
+	recorder, path, err := NewAIRecording(repoName, worktreeName, session, source, cols, rows, []string{command})
+	if err != nil {
+		cancel()
+		cmd.Wait()
+		return nil, err
+	}
+	recording := &AISessionRecording{
+		Path:      path,
+		container: container,
+		session:   session,
+		recorder:  recorder,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	// The stream only has what the pane outputs from now on, so the
+	// recording starts with what the pane already shows
+	if screen, err := telemetry.Output(ctx, aiSessionCommand(ctx, container, "capture-pane", "-e", "-p", "-t", "="+session+":")); err == nil {
+		recorder.Output([]byte("\x1b[H\x1b[2J" + strings.ReplaceAll(strings.TrimRight(string(screen), "\n"), "\n", "\r\n")))
+	}
+
+	go func() {
+		defer close(recording.done)
+		defer cancel()
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := stream.Read(buffer)
+			recorder.Output(buffer[:n])
+			if err != nil {
+				break
+			}
+		}
+		cmd.Wait()
+		if err := recorder.Close(); err != nil {
+			logger.WithError(err).WithField("path", path).Warn("Failed to write AI terminal recording")
+		}
+	}()
+	return recording, nil
+}
+
+// Input records data typed into the session
+func (r *AISessionRecording) Input(data []byte) {
+	if r != nil {
+		r.recorder.Input(data)
+	}
+}
+
+// Resize records a new size of the session's terminal
+func (r *AISessionRecording) Resize(cols, rows int) {
+	if r != nil {
+		r.recorder.Resize(cols, rows)
+	}
+}
+
+// Done is closed when the recording has ended
+func (r *AISessionRecording) Done() <-chan struct{} {
+	if r == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return r.done
+}
+
+// Stop stops recording the session. Closing the pane's pipe ends the stream,
+// so what tmux already piped is still recorded.
+func (r *AISessionRecording) Stop() {
+	if r == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), aiRecordingStopTimeout)
+	defer cancel()
+
+	if err := aiSessionCommand(ctx, r.container, "pipe-pane", "-t", "="+r.session+":").Run(); err != nil {
+		logger.WithError(err).WithField("session", r.session).Debug("Failed to close terminal session pipe")
+	}
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		r.cancel()
+		<-r.done
+	}
+}
+
+// ListAIRecordings lists the recorded AI terminal sessions of a worktree,
+// newest first
+func ListAIRecordings(repoName, worktreeName string) ([]AIRecording, error) {
+	entries, err := os.ReadDir(AIRecordingsDir(repoName, worktreeName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recordings directory: %w", err)
+	}
+
+	var recordings []AIRecording
+	for _, entry := range entries {
+		if entry.IsDir() || !aiRecordingNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		recording, err := readAIRecording(repoName, worktreeName, entry.Name())
+		if err != nil {
+			continue
+		}
+		recordings = append(recordings, *recording)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].Name > recordings[j].Name
+	})
+	return recordings, nil
+}
+
+// GetAIRecording returns the recorded AI terminal session of a worktree with
+// the given file name
+func GetAIRecording(repoName, worktreeName, name string) (*AIRecording, error) {
+	if !aiRecordingNamePattern.MatchString(name) {
+		return nil, errors.New(errors.ErrInvalidInput, "invalid recording name").WithContext("recording", name)
+	}
+	recording, err := readAIRecording(repoName, worktreeName, name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("recording %q not found", name)
+	}
+	return recording, err
+}
+
+// readAIRecording describes a recording from its file name and header
+func readAIRecording(repoName, worktreeName, name string) (*AIRecording, error) {
+	match := aiRecordingNamePattern.FindStringSubmatch(name)
+	recording := &AIRecording{
+		Name:       name,
+		Repository: repoName,
+		Worktree:   worktreeName,
+		Session:    match[2],
+		Source:     match[3],
+		Path:       filepath.Join(AIRecordingsDir(repoName, worktreeName), name),
+	}
+	if started, err := time.Parse(aiRecordingTimeFormat, match[1]); err == nil {
+		recording.StartedAt = started
+	}
+
+	file, err := os.Open(recording.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	recording.Size = info.Size()
+
+	decoder, err := asciicast.NewDecoder(file)
+	if err != nil {
+		return nil, err
+	}
+	recording.Width, recording.Height = decoder.Header().Width, decoder.Header().Height
+	recording.Duration, _ = asciicast.Duration(file, recording.Size)
+	return recording, nil
+}
@@ -0,0 +1,193 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vibeman/internal/asciicast"
+	"vibeman/internal/xdg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIRecordings(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	// Worktrees that were never attached to have no recordings
+	recordings, err := ListAIRecordings("app", "feature")
+	require.NoError(t, err)
+	assert.Empty(t, recordings)
+
+	recorder, path, err := NewAIRecording("app", "feature", "agent", AIRecordingSourceWeb, 100, 30, []string{"tmux", "new-session"})
+	require.NoError(t, err)
+	assert.Equal(t, AIRecordingsDir("app", "feature"), filepath.Dir(path))
+	assert.False(t, strings.HasPrefix(path, xdg.LogsDir()), "AI containers mount the logs directory")
+	recorder.Output([]byte("hello"))
+	recorder.Input([]byte("exit\r"))
+	require.NoError(t, recorder.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "recordings hold typed input")
+
+	time.Sleep(time.Millisecond) // Recording names have microsecond precision
+	recorder, _, err = NewAIRecording("app", "feature", "shell", AIRecordingSourceCLI, 80, 24, []string{"zsh"})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	// Stray files in the directory are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(AIRecordingsDir("app", "feature"), "notes.txt"), nil, 0600))
+
+	recordings, err = ListAIRecordings("app", "feature")
+	require.NoError(t, err)
+	require.Len(t, recordings, 2)
+	assert.Equal(t, "shell", recordings[0].Session, "newest first")
+	assert.Equal(t, AIRecordingSourceCLI, recordings[0].Source)
+
+	web := recordings[1]
+	assert.Equal(t, filepath.Base(path), web.Name)
+	assert.Equal(t, "app", web.Repository)
+	assert.Equal(t, "feature", web.Worktree)
+	assert.Equal(t, "agent", web.Session)
+	assert.Equal(t, AIRecordingSourceWeb, web.Source)
+	assert.Equal(t, 100, web.Width)
+	assert.Equal(t, 30, web.Height)
+	assert.Equal(t, info.Size(), web.Size)
+	assert.WithinDuration(t, time.Now(), web.StartedAt, time.Minute)
+
+	found, err := GetAIRecording("app", "feature", web.Name)
+	require.NoError(t, err)
+	assert.Equal(t, path, found.Path)
+
+	_, err = GetAIRecording("app", "feature", "20260101-000000.000000-agent-web.cast")
+	assert.ErrorContains(t, err, "not found")
+	for _, name := range []string{"../../secrets.cast", "notes.txt", "20260101-000000.000000-agent-other.cast"} {
+		_, err = GetAIRecording("app", "feature", name)
+		assert.ErrorContains(t, err, "invalid recording name", name)
+	}
+
+	_, _, err = NewAIRecording("app", "feature", "a;b", AIRecordingSourceWeb, 80, 24, nil)
+	assert.Error(t, err)
+	_, _, err = NewAIRecording("app", "feature", "agent", "email", 80, 24, nil)
+	assert.Error(t, err)
+}
+
+// fakePipe replaces the command that streams a session's pane with script
+func fakePipe(t *testing.T, script string) {
+	t.Helper()
+
+	original := aiPipeCommand
+	aiPipeCommand = func(ctx context.Context, container, session string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	}
+	t.Cleanup(func() { aiPipeCommand = original })
+}
+
+func TestRecordAISession(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	fakeTmux(t, `printf 'on screen\n'`)
+
+	// The pane's output is recorded until the session ends, whether or not
+	// anyone is attached, after what it showed when recording started
+	fakePipe(t, `printf '100 30 claude --dangerously-skip-permissions\n'; printf 'working'; sleep 0.1; printf ' done'`)
+	recording, err := RecordAISession(context.Background(), "app", "feature", "app-feature-ai", "agent", AIRecordingSourceDetached)
+	require.NoError(t, err)
+	require.NotNil(t, recording)
+	recording.Input([]byte("y\r"))
+	recording.Resize(120, 40)
+	select {
+	case <-recording.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording did not end with the session")
+	}
+
+	recordings, err := ListAIRecordings("app", "feature")
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+	assert.Equal(t, AIRecordingSourceDetached, recordings[0].Source)
+	assert.Equal(t, 100, recordings[0].Width)
+	assert.Equal(t, 30, recordings[0].Height)
+
+	file, err := os.Open(recording.Path)
+	require.NoError(t, err)
+	defer file.Close()
+	decoder, err := asciicast.NewDecoder(file)
+	require.NoError(t, err)
+	assert.Equal(t, "claude --dangerously-skip-permissions", decoder.Header().Command)
+	var output, input strings.Builder
+	for {
+		event, err := decoder.Next()
+		if err != nil {
+			break
+		}
+		switch event.Type {
+		case asciicast.EventOutput:
+			output.WriteString(event.Data)
+		case asciicast.EventInput:
+			input.WriteString(event.Data)
+		}
+	}
+	assert.Contains(t, output.String(), "on screen")
+	assert.Contains(t, output.String(), "working done")
+	assert.Less(t, strings.Index(output.String(), "on screen"), strings.Index(output.String(), "working"))
+	assert.Equal(t, "y\r", input.String())
+
+	// Panes that are already piped are recorded elsewhere
+	fakePipe(t, `exit 3`)
+	recording, err = RecordAISession(context.Background(), "app", "feature", "app-feature-ai", "agent", AIRecordingSourceWeb)
+	require.NoError(t, err)
+	assert.Nil(t, recording)
+	recording.Input([]byte("ignored"))
+	recording.Stop()
+
+	fakePipe(t, `echo 'no session' >&2; exit 1`)
+	_, err = RecordAISession(context.Background(), "app", "feature", "app-feature-ai", "agent", AIRecordingSourceWeb)
+	assert.Error(t, err)
+
+	recordings, err = ListAIRecordings("app", "feature")
+	require.NoError(t, err)
+	assert.Len(t, recordings, 1, "sessions that are not recorded leave no file")
+}
+
+func TestAISessionRecording_Stop(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	calls := fakeTmux(t, `true`)
+
+	// A stream that would last forever ends once its pipe is closed, or is
+	// cut off
+	fakePipe(t, `printf '80 24 zsh\n'; exec sleep 60`)
+	recording, err := RecordAISession(context.Background(), "app", "feature", "app-feature-ai", "shell", AIRecordingSourceCLI)
+	require.NoError(t, err)
+	require.NotNil(t, recording)
+
+	stopped := make(chan struct{})
+	go func() {
+		recording.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * aiRecordingStopTimeout):
+		t.Fatal("Stop did not return")
+	}
+	assert.Contains(t, *calls, "app-feature-ai pipe-pane")
+	_, err = os.Stat(recording.Path)
+	assert.NoError(t, err)
+}
+
+func TestAIContainerRepository(t *testing.T) {
+	repo, ok := AIContainerRepository(AIContainerName("my-app", "feature-x"), "feature-x")
+	assert.True(t, ok)
+	assert.Equal(t, "my-app", repo)
+
+	_, ok = AIContainerRepository("my-app-feature-x-worktree", "feature-x")
+	assert.False(t, ok)
+	_, ok = AIContainerRepository("-feature-x-ai", "feature-x")
+	assert.False(t, ok)
+}
@@ -0,0 +1,233 @@
+package operations
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"vibeman/internal/config"
+	"vibeman/internal/container"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+)
+
+// containerSocketPaths are where container runtimes listen. Whoever can
+// reach one controls the host, so AI containers never mount them, whatever
+// their security profile.
+var containerSocketPaths = []string{
+	"/var/run/docker.sock",
+	"/run/docker.sock",
+	"/var/run/podman/podman.sock",
+	"/run/podman/podman.sock",
+}
+
+// aiSecurity returns the security profile of a repository's AI containers.
+// It is read from the repository's checkout on the host, not from the
+// worktree, which AI containers can write to, so agents cannot weaken it,
+// and it is never weaker than the global [ai] security_profile. Without a
+// configuration in the checkout the hardened profile applies.
+func aiSecurity(repo *db.Repository) (config.AISecurityConfig, error) {
+	security := config.AISecurityConfig{Profile: config.AISecurityHardened}
+	if repoConfig, err := config.ParseRepositoryConfig(repo.Path); err == nil {
+		security = repoConfig.Repository.AI.Security
+	} else {
+		logger.WithError(err).WithField("repository", repo.Name).Warn("No repository configuration to take the AI security profile from, using the hardened profile")
+	}
+
+	globalCfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		return config.AISecurityConfig{}, fmt.Errorf("failed to load the global security profile: %w", err)
+	}
+	switch globalCfg.AI.SecurityProfile {
+	case "", config.AISecurityStandard:
+	case config.AISecurityHardened:
+		security.Profile = config.AISecurityHardened
+	default:
+		return config.AISecurityConfig{}, fmt.Errorf("unknown global security profile %q", globalCfg.AI.SecurityProfile)
+	}
+	return security, nil
+}
+
+// aiAgent returns the agent a repository's AI containers run, as the
+// repository's checkout on the host configures it, with the credentials and
+// config files the global configuration grants it. Like the security
+// profile, it is not read from the worktree, which agents can edit.
+func aiAgent(repo *db.Repository) (string, config.AgentConfig) {
+	agentName, agent, err := aiCheckoutConfig(repo).ResolveAgent("")
+	if err != nil {
+		logger.WithError(err).Warn("Failed to resolve AI agent, using the default")
+		agentName, agent, _ = (&config.AIConfig{}).ResolveAgent("")
+	}
+
+	globalCfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to load the global agent settings, passing no credentials")
+		return agentName, agent.WithAccess(config.AgentAccess{Env: []string{}, Config: []string{}})
+	}
+	return agentName, agent.WithAccess(globalCfg.AI.Agents[agentName])
+}
+
+// aiCheckoutConfig returns the [repository.ai] configuration of a
+// repository's checkout on the host, or an empty one when the checkout has
+// none. The image, environment and volumes of AI containers come from it
+// rather than from the worktree, where agents could add a host path to
+// mount on the next start.
+func aiCheckoutConfig(repo *db.Repository) *config.AIConfig {
+	if repoConfig, err := config.ParseRepositoryConfig(repo.Path); err == nil {
+		return &repoConfig.Repository.AI
+	}
+	return &config.AIConfig{}
+}
+
+// aiAgentConfig returns the config files of an agent, relative to the host
+// home directory, that the security profile lets the AI container mount.
+// The hardened profile keeps the home directory out, so it mounts them only
+// where allowed_volumes lists them. The others are left out with a warning.
+func aiAgentConfig(paths []string, security config.AISecurityConfig, home string) []string {
+	allowed := aiAllowedVolumes(security, home)
+	kept := []string{}
+	for _, path := range paths {
+		if err := checkAIVolume(filepath.Join(home, path), security.Hardened(), allowed, home); err != nil {
+			logger.WithError(err).Warn("Not mounting the agent's config into the AI container")
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept
+}
+
+// aiAllowedVolumes returns the host paths the security profile's
+// allowed_volumes lists
+func aiAllowedVolumes(security config.AISecurityConfig, home string) []string {
+	allowed := make([]string, 0, len(security.AllowedVolumes))
+	for _, path := range security.AllowedVolumes {
+		allowed = append(allowed, filepath.Clean(expandHome(path, home)))
+	}
+	return allowed
+}
+
+// aiCustomVolumes returns the mounts volumes, the [repository.ai] volumes,
+// ask for, refusing those the security profile does not allow. home is the
+// host home directory, which "~/" in volumes refers to.
+func aiCustomVolumes(volumes map[string]string, security config.AISecurityConfig, home string) ([]string, error) {
+	allowed := aiAllowedVolumes(security, home)
+
+	var mounts []string
+	for _, host := range slices.Sorted(maps.Keys(volumes)) {
+		source := expandHome(host, home)
+		if filepath.IsAbs(source) {
+			if err := checkAIVolume(filepath.Clean(source), security.Hardened(), allowed, home); err != nil {
+				return nil, err
+			}
+		}
+		mounts = append(mounts, source+":"+volumes[host])
+	}
+	return mounts, nil
+}
+
+// checkAIVolume checks a host path may be mounted into the AI container.
+// Paths are checked as given and with symlinks resolved. Container runtime
+// sockets are always refused. The hardened profile also refuses anything in
+// the home directory, which holds credentials such as ~/.ssh, unless
+// allowed lists it.
+func checkAIVolume(source string, hardened bool, allowed []string, home string) error {
+	paths := []string{source}
+	if resolved, err := filepath.EvalSymlinks(source); err == nil && resolved != source {
+		paths = append(paths, resolved)
+	}
+
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if base == "docker.sock" || base == "podman.sock" || slices.ContainsFunc(containerSocketPaths, func(socket string) bool { return isWithin(socket, path) }) {
+			return fmt.Errorf("volume %s would give the AI container the container runtime's socket", source)
+		}
+		if hardened {
+			if home != "" && isWithin(filepath.Clean(home), path) {
+				return fmt.Errorf("volume %s would mount your home directory into the AI container", source)
+			}
+		}
+		isAllowed := slices.ContainsFunc(allowed, func(dir string) bool { return isWithin(path, dir) })
+		if hardened && home != "" && isWithin(path, filepath.Clean(home)) && !isAllowed {
+			return fmt.Errorf("volume %s is in your home directory: list it in allowed_volumes to mount it into the hardened AI container", source)
+		}
+		if len(allowed) > 0 && !isAllowed {
+			return fmt.Errorf("volume %s is outside the allowed_volumes of the security profile", source)
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// expandHome replaces a leading "~/" in path with the home directory
+func expandHome(path, home string) string {
+	if home != "" && (path == "~" || strings.HasPrefix(path, "~/")) {
+		return filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	return path
+}
+
+// applyAISecurity applies a repository's security profile to the config of
+// its AI container. Seccomp profiles are relative to the repository's
+// checkout at repoPath, which, unlike the worktree, agents cannot edit.
+func applyAISecurity(createConfig *container.CreateConfig, security config.AISecurityConfig, repoPath string) error {
+	if err := security.Validate(); err != nil {
+		return err
+	}
+	security = security.Effective()
+
+	createConfig.ReadOnly = security.ReadOnly != nil && *security.ReadOnly
+	createConfig.Tmpfs = security.Tmpfs
+	if security.Hardened() {
+		createConfig.CapDrop = []string{"ALL"}
+		createConfig.SecurityOpts = append(createConfig.SecurityOpts, "no-new-privileges")
+	}
+	createConfig.CapAdd = security.CapAdd
+
+	switch security.Seccomp {
+	case "":
+		// Docker's default profile
+	case "unconfined":
+		createConfig.SecurityOpts = append(createConfig.SecurityOpts, "seccomp=unconfined")
+	default:
+		profile := filepath.Join(repoPath, security.Seccomp)
+		if _, err := os.Stat(profile); err != nil {
+			return fmt.Errorf("seccomp profile %s not found: %w", security.Seccomp, err)
+		}
+		createConfig.SecurityOpts = append(createConfig.SecurityOpts, "seccomp="+profile)
+	}
+
+	// Agents run as you by default, so what they write in /workspace is
+	// yours. Users without a passwd entry in the image have no home, so
+	// theirs is set explicitly.
+	createConfig.User = security.User
+	if createConfig.User == "" && security.Hardened() {
+		createConfig.User = hostUser()
+	}
+	if createConfig.User != "" {
+		createConfig.EnvVars = append(createConfig.EnvVars, "HOME="+config.AIContainerHome)
+	}
+	createConfig.UserNS = security.UserNS
+
+	createConfig.CPUs = security.CPUs
+	createConfig.Memory = security.Memory
+	createConfig.PidsLimit = security.PidsLimit
+	return nil
+}
+
+// hostUser returns the uid:gid of the user vibeman runs as, or nothing when
+// that is root or the platform has no uids
+func hostUser() string {
+	uid := os.Getuid()
+	if uid <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", uid, os.Getgid())
+}
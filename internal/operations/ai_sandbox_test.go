@@ -0,0 +1,167 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vibeman/internal/config"
+	"vibeman/internal/container"
+	"vibeman/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAICustomVolumes(t *testing.T) {
+	home := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "datasets"), 0755))
+	ai := &config.AIConfig{Volumes: map[string]string{
+		"~/datasets": "/data",
+		"cache":      "/cache", // Named volumes are not host paths
+	}}
+
+	volumes, err := aiCustomVolumes(ai.Volumes, ai.Security, home)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cache:/cache", filepath.Join(home, "datasets") + ":/data"}, volumes)
+
+	// No profile mounts the runtime socket
+	ai.Volumes["/var/run/docker.sock"] = "/var/run/docker.sock"
+	_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+	assert.ErrorContains(t, err, "container runtime's socket")
+	ai.Security.Profile = config.AISecurityHardened
+	_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+	assert.ErrorContains(t, err, "container runtime's socket")
+
+	// Hardened ones also refuse the home directory
+
+	for _, source := range []string{"/var/run", "/", "~", filepath.Dir(home)} {
+		ai.Volumes = map[string]string{source: "/mnt"}
+		_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+		assert.Error(t, err, source)
+	}
+	ai.Volumes = map[string]string{"~": "/mnt"}
+	_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+	assert.ErrorContains(t, err, "home directory")
+
+	// Nor anything in the home directory, such as credentials
+	for _, source := range []string{"~/.ssh", "~/.aws", "~/.config/gh", "~/datasets"} {
+		ai.Volumes = map[string]string{source: "/mnt"}
+		_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+		assert.ErrorContains(t, err, "in your home directory", source)
+	}
+
+	// Allowed volumes restrict host paths to the directories listed
+	ai.Volumes = map[string]string{"~/datasets/images": "/images", "/etc": "/host-etc"}
+	ai.Security.AllowedVolumes = []string{"~/datasets"}
+	_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+	assert.ErrorContains(t, err, "volume /etc is outside the allowed_volumes")
+	delete(ai.Volumes, "/etc")
+	_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+	assert.NoError(t, err, "allowed_volumes lets hardened containers mount from the home directory")
+	ai.Volumes = map[string]string{"~/.ssh": "/ssh"}
+	_, err = aiCustomVolumes(ai.Volumes, ai.Security, home)
+	assert.Error(t, err)
+}
+
+func TestAISecurity(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	// The profile comes from the repository's checkout, whatever the
+	// worktree's copy of vibeman.toml says
+	repoPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "vibeman.toml"), []byte(`
+[repository]
+name = "app"
+
+[repository.ai.security]
+profile = "hardened"
+`), 0644))
+	security, err := aiSecurity(&db.Repository{Name: "app", Path: repoPath})
+	require.NoError(t, err)
+	assert.True(t, security.Hardened())
+
+	security, err = aiSecurity(&db.Repository{Name: "app", Path: t.TempDir()})
+	require.NoError(t, err)
+	assert.True(t, security.Hardened(), "without the repository's configuration there is no profile to trust")
+
+	// The global profile is the weakest a repository gets
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "vibeman.toml"), []byte("[repository]\nname = \"app\"\n"), 0644))
+	security, err = aiSecurity(&db.Repository{Name: "app", Path: repoPath})
+	require.NoError(t, err)
+	assert.False(t, security.Hardened())
+
+	require.NoError(t, os.MkdirAll(filepath.Join(configHome, "vibeman"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configHome, "vibeman", "config.toml"), []byte("[ai]\nsecurity_profile = \"hardened\"\n"), 0644))
+	security, err = aiSecurity(&db.Repository{Name: "app", Path: repoPath})
+	require.NoError(t, err)
+	assert.True(t, security.Hardened())
+}
+
+func TestAIAgent(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	// The agent comes from the repository's checkout, with the access the
+	// global configuration grants
+	repoPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "vibeman.toml"), []byte(`
+[repository]
+name = "app"
+
+[repository.ai]
+agent = "codex"
+`), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(configHome, "vibeman"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configHome, "vibeman", "config.toml"), []byte(`
+[ai.agents.codex]
+env = ["CODEX_TOKEN"]
+`), 0644))
+	name, agent := aiAgent(&db.Repository{Name: "app", Path: repoPath})
+	assert.Equal(t, "codex", name)
+	assert.Equal(t, []string{"CODEX_TOKEN"}, agent.Env)
+	assert.Equal(t, []string{".codex"}, agent.Config)
+
+	name, _ = aiAgent(&db.Repository{Name: "app", Path: t.TempDir()})
+	assert.Equal(t, config.DefaultAgent, name)
+}
+
+func TestAIAgentConfig(t *testing.T) {
+	home := t.TempDir()
+	paths := []string{".claude", ".claude.json"}
+
+	assert.Equal(t, paths, aiAgentConfig(paths, config.AISecurityConfig{}, home))
+	assert.Empty(t, aiAgentConfig(paths, config.AISecurityConfig{Profile: config.AISecurityHardened}, home),
+		"the hardened profile keeps the home directory out")
+	assert.Equal(t, []string{".claude"}, aiAgentConfig(paths, config.AISecurityConfig{Profile: config.AISecurityHardened, AllowedVolumes: []string{"~/.claude"}}, home))
+}
+
+func TestApplyAISecurity(t *testing.T) {
+	// The standard profile only applies what is set
+	createConfig := &container.CreateConfig{}
+	require.NoError(t, applyAISecurity(createConfig, config.AISecurityConfig{PidsLimit: 256}, t.TempDir()))
+	assert.Equal(t, &container.CreateConfig{PidsLimit: 256}, createConfig)
+
+	worktree := t.TempDir()
+	security := config.AISecurityConfig{Profile: config.AISecurityHardened, CapAdd: []string{"NET_BIND_SERVICE"}, Seccomp: "seccomp.json", User: "1000:1000"}
+	assert.ErrorContains(t, applyAISecurity(&container.CreateConfig{}, security, worktree), "seccomp profile seccomp.json not found")
+
+	require.NoError(t, os.WriteFile(filepath.Join(worktree, "seccomp.json"), []byte(`{"defaultAction":"SCMP_ACT_ERRNO"}`), 0644))
+	createConfig = &container.CreateConfig{}
+	require.NoError(t, applyAISecurity(createConfig, security, worktree))
+	assert.True(t, createConfig.ReadOnly)
+	assert.Equal(t, config.DefaultAISandboxTmpfs, createConfig.Tmpfs)
+	assert.Equal(t, []string{"ALL"}, createConfig.CapDrop)
+	assert.Equal(t, []string{"NET_BIND_SERVICE"}, createConfig.CapAdd)
+	assert.Equal(t, []string{"no-new-privileges", "seccomp=" + filepath.Join(worktree, "seccomp.json")}, createConfig.SecurityOpts)
+	assert.Equal(t, "1000:1000", createConfig.User)
+	assert.Contains(t, createConfig.EnvVars, "HOME="+config.AIContainerHome)
+	assert.Equal(t, config.DefaultAISandboxCPUs, createConfig.CPUs)
+	assert.Equal(t, config.DefaultAISandboxMemory, createConfig.Memory)
+	assert.Equal(t, config.DefaultAISandboxPidsLimit, createConfig.PidsLimit)
+
+	// Settings that would undo the profile are refused
+	security = config.AISecurityConfig{Profile: config.AISecurityHardened, Seccomp: "unconfined"}
+	assert.Error(t, applyAISecurity(&container.CreateConfig{}, security, worktree))
+}
@@ -0,0 +1,134 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibeman/internal/errors"
+	"vibeman/internal/telemetry"
+)
+
+// Persistent AI terminal sessions are tmux sessions inside the AI container.
+// Clients attach to them rather than running commands directly, so the
+// command keeps running when they disconnect and anyone attaching to the
+// same session name sees the same terminal.
+
+// aiSessionNamePattern matches the session names tmux accepts as targets
+var aiSessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// aiSessionCommand returns the command that runs tmux with args in an AI
+// container, replaced in tests
+var aiSessionCommand = func(ctx context.Context, container string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "docker", append([]string{"exec", container, "tmux"}, args...)...)
+}
+
+// AISession is a persistent terminal session in an AI container
+type AISession struct {
+	Name       string    `json:"name"`
+	Worktree   string    `json:"worktree"`
+	WorktreeID string    `json:"worktree_id"`
+	Container  string    `json:"container"`
+	CreatedAt  time.Time `json:"created_at"`
+	Clients    int       `json:"clients"`           // tmux clients attached, including CLI ones
+	Command    string    `json:"command,omitempty"` // What the session's first window runs
+}
+
+// ValidateAISessionName checks a session name is safe to pass to tmux
+func ValidateAISessionName(name string) error {
+	if !aiSessionNamePattern.MatchString(name) {
+		return errors.New(errors.ErrInvalidInput, "session names must be 1 to 64 letters, digits, dashes or underscores").WithContext("session", name)
+	}
+	return nil
+}
+
+// DefaultAISessionName returns the session an attach uses when none is named:
+// the agent's name when one is requested, "agent" for the configured agent
+// and "shell" for a shell
+func DefaultAISessionName(mode, agent string) string {
+	switch {
+	case agent != "":
+		return agent
+	case mode == "agent":
+		return "agent"
+	default:
+		return "shell"
+	}
+}
+
+// AISessionCommand returns the command that attaches to the named session,
+// first starting command in it if the session does not exist yet
+func AISessionCommand(name string, command []string) []string {
+	return append([]string{"tmux", "new-session", "-A", "-s", name}, command...)
+}
+
+// AISessionViewCommand returns the command that attaches to an existing
+// session without being able to type in it. tmux ignores the viewer's
+// size, so viewers cannot shrink the window the writer works in.
+func AISessionViewCommand(name string) []string {
+	return []string{"tmux", "attach-session", "-f", "read-only,ignore-size", "-t", "=" + name}
+}
+
+// ListAISessions lists the sessions running in an AI container. A container
+// without a tmux server has none.
+func ListAISessions(ctx context.Context, container string) ([]AISession, error) {
+	cmd := aiSessionCommand(ctx, container, "list-sessions", "-F", "#{session_name}\t#{session_created}\t#{session_attached}\t#{pane_start_command}")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := telemetry.Output(ctx, cmd)
+	if err != nil {
+		if strings.Contains(stderr.String(), "no server running") || strings.Contains(stderr.String(), "error connecting") {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.ErrContainerExecFailed, "failed to list terminal sessions", err).WithContext("container", container)
+	}
+
+	sessions := parseAISessions(string(output))
+	for i := range sessions {
+		sessions[i].Container = container
+	}
+	return sessions, nil
+}
+
+// parseAISessions parses the output of tmux list-sessions in the format
+// ListAISessions requests
+func parseAISessions(output string) []AISession {
+	var sessions []AISession
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 3 || fields[0] == "" {
+			continue
+		}
+		session := AISession{Name: fields[0]}
+		if created, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			session.CreatedAt = time.Unix(created, 0).UTC()
+		}
+		session.Clients, _ = strconv.Atoi(fields[2])
+		if len(fields) == 4 {
+			session.Command = strings.TrimSpace(fields[3])
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// KillAISession ends a session and whatever runs in it
+func KillAISession(ctx context.Context, container, name string) error {
+	if err := ValidateAISessionName(name); err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd := aiSessionCommand(ctx, container, "kill-session", "-t", "="+name)
+	cmd.Stderr = &stderr
+	if err := telemetry.Run(ctx, cmd); err != nil {
+		if strings.Contains(stderr.String(), "can't find session") || strings.Contains(stderr.String(), "no server running") {
+			return fmt.Errorf("terminal session %q not found", name)
+		}
+		return errors.Wrap(errors.ErrContainerExecFailed, "failed to end terminal session", err).WithContext("session", name)
+	}
+	return nil
+}
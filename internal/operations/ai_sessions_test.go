@@ -0,0 +1,89 @@
+package operations
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTmux replaces the tmux command run in AI containers with a shell
+// script, recording the arguments it was called with
+func fakeTmux(t *testing.T, script string) *[]string {
+	t.Helper()
+
+	var calls []string
+	original := aiSessionCommand
+	aiSessionCommand = func(ctx context.Context, container string, args ...string) *exec.Cmd {
+		calls = append(calls, container+" "+args[0])
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	}
+	t.Cleanup(func() { aiSessionCommand = original })
+	return &calls
+}
+
+func TestParseAISessions(t *testing.T) {
+	sessions := parseAISessions("claude\t1700000000\t2\tclaude --dangerously-skip-permissions\nshell\t1700000100\t0\t/bin/zsh\n\n")
+	require.Len(t, sessions, 2)
+
+	assert.Equal(t, "claude", sessions[0].Name)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), sessions[0].CreatedAt)
+	assert.Equal(t, 2, sessions[0].Clients)
+	assert.Equal(t, "claude --dangerously-skip-permissions", sessions[0].Command)
+	assert.Equal(t, "shell", sessions[1].Name)
+	assert.Equal(t, 0, sessions[1].Clients)
+
+	assert.Empty(t, parseAISessions(""))
+}
+
+func TestListAISessions(t *testing.T) {
+	calls := fakeTmux(t, `printf 'agent\t1700000000\t1\tcodex\n'`)
+	sessions, err := ListAISessions(context.Background(), "app-feature-ai")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "agent", sessions[0].Name)
+	assert.Equal(t, "app-feature-ai", sessions[0].Container)
+	assert.Equal(t, []string{"app-feature-ai list-sessions"}, *calls)
+
+	// A container whose tmux server has not started has no sessions
+	fakeTmux(t, `echo "no server running on /tmp/tmux-1000/default" >&2; exit 1`)
+	sessions, err = ListAISessions(context.Background(), "app-feature-ai")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	fakeTmux(t, `echo "Error: No such container: app-feature-ai" >&2; exit 1`)
+	_, err = ListAISessions(context.Background(), "app-feature-ai")
+	assert.Error(t, err)
+}
+
+func TestKillAISession(t *testing.T) {
+	calls := fakeTmux(t, `exit 0`)
+	require.NoError(t, KillAISession(context.Background(), "app-feature-ai", "shell"))
+	assert.Equal(t, []string{"app-feature-ai kill-session"}, *calls)
+
+	fakeTmux(t, `echo "can't find session: shell" >&2; exit 1`)
+	err := KillAISession(context.Background(), "app-feature-ai", "shell")
+	assert.ErrorContains(t, err, "not found")
+
+	assert.Error(t, KillAISession(context.Background(), "app-feature-ai", "a;b"))
+}
+
+func TestAISessionNames(t *testing.T) {
+	assert.NoError(t, ValidateAISessionName("claude"))
+	assert.NoError(t, ValidateAISessionName("review_2-b"))
+	for _, name := range []string{"", "a.b", "a:b", "a b", "$(id)", string(make([]byte, 65))} {
+		assert.Error(t, ValidateAISessionName(name), name)
+	}
+
+	assert.Equal(t, "shell", DefaultAISessionName("", ""))
+	assert.Equal(t, "shell", DefaultAISessionName("shell", ""))
+	assert.Equal(t, "agent", DefaultAISessionName("agent", ""))
+	assert.Equal(t, "codex", DefaultAISessionName("agent", "codex"))
+	assert.Equal(t, "codex", DefaultAISessionName("", "codex"))
+
+	assert.Equal(t, []string{"tmux", "new-session", "-A", "-s", "agent", "claude", "-c"}, AISessionCommand("agent", []string{"claude", "-c"}))
+	assert.Equal(t, []string{"tmux", "attach-session", "-f", "read-only,ignore-size", "-t", "=agent"}, AISessionViewCommand("agent"))
+}
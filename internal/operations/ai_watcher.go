@@ -0,0 +1,455 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+)
+
+// Agents run in persistent terminal sessions nobody may be looking at. The
+// watcher polls the screens of those sessions and decides from what it sees
+// whether each agent is working, waiting for an answer or finished, telling
+// the configured notifiers when that changes.
+
+// AgentState is what an agent in a terminal session is doing
+type AgentState string
+
+const (
+	AgentWorking  AgentState = "working"  // Its terminal is changing
+	AgentWaiting  AgentState = "waiting"  // It asked a question
+	AgentFinished AgentState = "finished" // Its terminal went quiet, or it exited
+)
+
+// Reasons an agent is considered waiting or finished
+const (
+	AgentReasonPrompt = "prompt" // The screen ends in a question
+	AgentReasonQuiet  = "quiet"  // The screen has not changed for the quiet period
+	AgentReasonExited = "exited" // The session, and so the agent, ended
+)
+
+// defaultAgentPrompts match the questions agents stop to ask. They are
+// matched against the last lines of the screen.
+var defaultAgentPrompts = []string{
+	`(?i)do you want to (proceed|continue|make this edit|create|allow)`,
+	`(?i)\[y/n\]|\(y/n\)|\[yes/no\]`,
+	`(?i)allow (once|always|this)`,
+	`(?i)waiting for (your )?(input|approval|confirmation)`,
+	`(?i)press enter to (continue|confirm)`,
+	`(?i)approve\?|apply (these|this) (changes|edit)\?`,
+}
+
+// agentPromptLines is how many of the last non-empty lines of a screen are
+// searched for prompts
+const agentPromptLines = 8
+
+// agentNotifyTimeout is how long one notifier may take to deliver an event
+const agentNotifyTimeout = 10 * time.Second
+
+// aiScreenCommand returns the command that prints the visible screen of a
+// session, replaced in tests
+var aiScreenCommand = func(ctx context.Context, container, session string) *exec.Cmd {
+	return exec.CommandContext(ctx, "docker", "exec", container, "tmux", "capture-pane", "-p", "-t", "="+session+":")
+}
+
+// aiBellCommand returns the command that rings the bell in every terminal
+// attached to a session, replaced in tests
+var aiBellCommand = func(ctx context.Context, container, session string) *exec.Cmd {
+	script := `tmux list-clients -t "=$0" -F '#{client_tty}' | while read -r tty; do printf '\a' > "$tty"; done`
+	return exec.CommandContext(ctx, "docker", "exec", container, "sh", "-c", script, session)
+}
+
+// AgentActivity is what the watcher last made of an agent
+type AgentActivity struct {
+	State  AgentState `json:"state" example:"waiting"`
+	Reason string     `json:"reason,omitempty" example:"prompt"` // Why it is waiting or finished
+	Since  time.Time  `json:"since"`                             // When it entered State
+}
+
+// AgentEvent is sent to notifiers when an agent starts waiting or finishes
+type AgentEvent struct {
+	Event      string    `json:"event"` // agent.waiting or agent.finished
+	Repository string    `json:"repository"`
+	Worktree   string    `json:"worktree"`
+	WorktreeID string    `json:"worktree_id"`
+	Container  string    `json:"container"`
+	Session    string    `json:"session"`
+	Reason     string    `json:"reason"`           // prompt, quiet or exited
+	Screen     string    `json:"screen,omitempty"` // Last lines of the terminal
+	Time       time.Time `json:"time"`
+}
+
+// Message describes the event in a sentence, for notifications meant to be
+// read by people
+func (e *AgentEvent) Message() string {
+	where := fmt.Sprintf("%s/%s (%s)", e.Repository, e.Worktree, e.Session)
+	switch {
+	case e.Event == audit.ActionAgentWaiting:
+		return "Agent in " + where + " is waiting for input"
+	case e.Reason == AgentReasonExited:
+		return "Agent in " + where + " exited"
+	default:
+		return "Agent in " + where + " finished"
+	}
+}
+
+// AgentNotifier delivers agent events to someone
+type AgentNotifier interface {
+	Notify(ctx context.Context, event *AgentEvent) error
+}
+
+// WebhookNotifier POSTs agent events as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // http.DefaultClient if nil
+	Screen bool         // Send the last lines of the terminal, which can hold secrets
+}
+
+// Notify implements AgentNotifier
+func (n *WebhookNotifier) Notify(ctx context.Context, event *AgentEvent) error {
+	if !n.Screen && event.Screen != "" {
+		withoutScreen := *event
+		withoutScreen.Screen = ""
+		event = &withoutScreen
+	}
+	body, err := json.Marshal(struct {
+		*AgentEvent
+		Message string `json:"message"`
+	}{event, event.Message()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send agent event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("agent event webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// CommandNotifier runs a shell command on the host for each agent event,
+// such as notify-send or osascript to show a desktop notification. The
+// event is passed in VIBEMAN_* environment variables.
+type CommandNotifier struct {
+	Command string
+}
+
+// Notify implements AgentNotifier
+func (n *CommandNotifier) Notify(ctx context.Context, event *AgentEvent) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+	cmd.Env = append(os.Environ(),
+		"VIBEMAN_EVENT="+event.Event,
+		"VIBEMAN_REPOSITORY="+event.Repository,
+		"VIBEMAN_WORKTREE="+event.Worktree,
+		"VIBEMAN_SESSION="+event.Session,
+		"VIBEMAN_REASON="+event.Reason,
+		"VIBEMAN_MESSAGE="+event.Message(),
+	)
+	if output, err := telemetry.CombinedOutput(ctx, cmd); err != nil {
+		return fmt.Errorf("agent notification command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// BellNotifier rings the bell in the terminals attached to the agent's
+// session, from the CLI or the web UI
+type BellNotifier struct{}
+
+// Notify implements AgentNotifier
+func (BellNotifier) Notify(ctx context.Context, event *AgentEvent) error {
+	if err := telemetry.Run(ctx, aiBellCommand(ctx, event.Container, event.Session)); err != nil {
+		return fmt.Errorf("failed to ring terminal bell: %w", err)
+	}
+	return nil
+}
+
+// NewAgentNotifiers returns the notifiers cfg configures
+func NewAgentNotifiers(cfg config.AINotifyConfig) []AgentNotifier {
+	var notifiers []AgentNotifier
+	if cfg.Webhook != "" {
+		notifiers = append(notifiers, &WebhookNotifier{URL: cfg.Webhook, Client: &http.Client{Timeout: agentNotifyTimeout}, Screen: cfg.WebhookScreen})
+	}
+	if cfg.Command != "" {
+		notifiers = append(notifiers, &CommandNotifier{Command: cfg.Command})
+	}
+	if cfg.Bell {
+		notifiers = append(notifiers, BellNotifier{})
+	}
+	return notifiers
+}
+
+// AgentWatcher watches the agents in the AI terminal sessions of running
+// worktrees
+type AgentWatcher struct {
+	db        *db.DB
+	quiet     time.Duration
+	prompts   []*regexp.Regexp
+	notifiers []AgentNotifier
+
+	mu     sync.Mutex
+	agents map[string]*watchedAgent // Keyed by container and session
+}
+
+// watchedAgent is an agent session the watcher has seen
+type watchedAgent struct {
+	event    AgentEvent // Where the agent runs, as events about it report
+	screen   string     // Screen when it was last looked at
+	changed  time.Time  // When the screen last changed
+	activity AgentActivity
+}
+
+// NewAgentWatcher creates a watcher that delivers events to notifiers. The
+// prompts configured are matched besides the built-in ones.
+func NewAgentWatcher(database *db.DB, cfg config.AINotifyConfig, notifiers ...AgentNotifier) (*AgentWatcher, error) {
+	w := &AgentWatcher{
+		db:        database,
+		quiet:     cfg.QuietDuration(),
+		notifiers: notifiers,
+		agents:    make(map[string]*watchedAgent),
+	}
+	for _, pattern := range slices.Concat(defaultAgentPrompts, cfg.Prompts) {
+		prompt, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid agent prompt %q: %w", pattern, err)
+		}
+		w.prompts = append(w.prompts, prompt)
+	}
+	return w, nil
+}
+
+// Run checks the agents every interval until ctx is cancelled. A zero quiet
+// period disables the watcher.
+func (w *AgentWatcher) Run(ctx context.Context, interval time.Duration) {
+	if w.quiet <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check(ctx, time.Now())
+		}
+	}
+}
+
+// Activity returns what the watcher last made of the agent in a session, or
+// nil if the session runs no agent it watches
+func (w *AgentWatcher) Activity(container, session string) *AgentActivity {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	agent, ok := w.agents[aiAgentKey(container, session)]
+	if !ok {
+		return nil
+	}
+	activity := agent.activity
+	return &activity
+}
+
+// aiAgentKey identifies an agent session across AI containers
+func aiAgentKey(container, session string) string {
+	return container + "\x00" + session
+}
+
+// check looks at every agent session once and notifies of the changes
+func (w *AgentWatcher) check(ctx context.Context, now time.Time) {
+	worktrees, err := db.NewWorktreeRepository(w.db).List(ctx, "", string(db.StatusRunning))
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list worktrees to watch agents in")
+		return
+	}
+
+	repos := db.NewRepositoryRepository(w.db)
+	checked := map[string]bool{} // Containers whose sessions were listed
+	present := map[string]bool{} // Agent sessions seen
+	var events []*AgentEvent
+	for i := range worktrees {
+		worktree := &worktrees[i]
+		repo, err := repos.GetByID(ctx, worktree.RepositoryID)
+		if err != nil {
+			continue
+		}
+		container := AIContainerName(repo.Name, worktree.Name)
+		sessions, err := ListAISessions(ctx, container)
+		if err != nil {
+			// Worktrees without an AI container have no agents
+			continue
+		}
+		checked[container] = true
+
+		for _, session := range sessions {
+			if !isAgentCommand(session.Command) {
+				continue
+			}
+			output, err := telemetry.Output(ctx, aiScreenCommand(ctx, container, session.Name))
+			if err != nil {
+				logger.WithError(err).WithField("session", session.Name).Debug("Failed to capture agent screen")
+				continue
+			}
+			key := aiAgentKey(container, session.Name)
+			present[key] = true
+			where := AgentEvent{Repository: repo.Name, Worktree: worktree.Name, WorktreeID: worktree.ID, Container: container, Session: session.Name}
+			if event := w.observe(key, where, string(output), now); event != nil {
+				events = append(events, event)
+			}
+		}
+	}
+
+	// Agents whose session ended exited; those whose container went away
+	// with their worktree are forgotten
+	w.mu.Lock()
+	for key, agent := range w.agents {
+		if present[key] {
+			continue
+		}
+		if checked[agent.event.Container] {
+			events = append(events, w.eventLocked(agent, AgentFinished, AgentReasonExited, now))
+		}
+		delete(w.agents, key)
+	}
+	w.mu.Unlock()
+
+	for _, event := range events {
+		w.notify(ctx, event)
+	}
+}
+
+// observe records the screen of an agent session and returns the event its
+// change of state calls for, if any. Agents seen for the first time only
+// have their state recorded, so restarting the server does not repeat
+// notifications.
+func (w *AgentWatcher) observe(key string, where AgentEvent, screen string, now time.Time) *AgentEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	agent, seen := w.agents[key]
+	if !seen {
+		agent = &watchedAgent{event: where, screen: screen, changed: now}
+		w.agents[key] = agent
+	} else if screen != agent.screen {
+		agent.screen, agent.changed = screen, now
+	}
+
+	state, reason := AgentWorking, ""
+	switch {
+	case w.matchesPrompt(screen):
+		state, reason = AgentWaiting, AgentReasonPrompt
+	case now.Sub(agent.changed) >= w.quiet:
+		state, reason = AgentFinished, AgentReasonQuiet
+	}
+
+	if !seen {
+		agent.activity = AgentActivity{State: state, Reason: reason, Since: now}
+		return nil
+	}
+	if state == agent.activity.State {
+		return nil
+	}
+	if state == AgentWorking {
+		agent.activity = AgentActivity{State: state, Since: now}
+		return nil
+	}
+	return w.eventLocked(agent, state, reason, now)
+}
+
+// eventLocked moves an agent into a waiting or finished state and returns
+// the event announcing it. w.mu must be held.
+func (w *AgentWatcher) eventLocked(agent *watchedAgent, state AgentState, reason string, now time.Time) *AgentEvent {
+	agent.activity = AgentActivity{State: state, Reason: reason, Since: now}
+
+	event := agent.event
+	event.Event = audit.ActionAgentFinished
+	if state == AgentWaiting {
+		event.Event = audit.ActionAgentWaiting
+	}
+	event.Reason = reason
+	event.Screen = lastLines(agent.screen, agentPromptLines)
+	event.Time = now
+	return &event
+}
+
+// matchesPrompt reports whether the bottom of a screen asks a question
+func (w *AgentWatcher) matchesPrompt(screen string) bool {
+	var lines []string
+	for _, line := range strings.Split(screen, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	bottom := strings.Join(lines[max(0, len(lines)-agentPromptLines):], "\n")
+	for _, prompt := range w.prompts {
+		if prompt.MatchString(bottom) {
+			return true
+		}
+	}
+	return false
+}
+
+// notify records an event in the worktree's audit trail and hands it to
+// every notifier. Failures are logged, so one broken notifier does not keep
+// the others from being told.
+func (w *AgentWatcher) notify(ctx context.Context, event *AgentEvent) {
+	logger.WithFields(logger.Fields{
+		"event":    event.Event,
+		"worktree": event.Worktree,
+		"session":  event.Session,
+		"reason":   event.Reason,
+	}).Info(event.Message())
+
+	params := audit.Params{"session": event.Session, "reason": event.Reason}
+	audit.Record(ctx, event.Event, audit.Target{Type: audit.TargetWorktree, ID: event.WorktreeID, Name: event.Worktree}, params, nil)
+
+	for _, notifier := range w.notifiers {
+		notifyCtx, cancel := context.WithTimeout(ctx, agentNotifyTimeout)
+		if err := notifier.Notify(notifyCtx, event); err != nil {
+			logger.WithError(err).WithField("event", event.Event).Warn("Failed to deliver agent event")
+		}
+		cancel()
+	}
+}
+
+// isAgentCommand reports whether a session was started with something other
+// than a shell, which is how agents are started in sessions
+func isAgentCommand(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+	switch filepath.Base(strings.Trim(fields[0], `"'`)) {
+	case "sh", "bash", "zsh", "fish", "dash", "-sh", "-bash", "-zsh":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier keeps the events it is given
+type recordingNotifier struct {
+	events []*AgentEvent
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event *AgentEvent) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+// fakeAIScreen replaces the screens of sessions with the contents of a file
+func fakeAIScreen(t *testing.T, path string) {
+	t.Helper()
+	original := aiScreenCommand
+	aiScreenCommand = func(ctx context.Context, container, session string) *exec.Cmd {
+		return exec.CommandContext(ctx, "cat", path)
+	}
+	t.Cleanup(func() { aiScreenCommand = original })
+}
+
+func TestAgentWatcher(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "app", Path: t.TempDir(), Name: "app"}))
+	require.NoError(t, db.NewWorktreeRepository(database).Create(ctx, &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: t.TempDir(), Status: db.StatusRunning}))
+
+	dir := t.TempDir()
+	sessions, screen := filepath.Join(dir, "sessions"), filepath.Join(dir, "screen")
+	fakeTmux(t, `cat "`+sessions+`"`)
+	fakeAIScreen(t, screen)
+	setScreen := func(content string) {
+		require.NoError(t, os.WriteFile(screen, []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(sessions, []byte("claude\t1700000000\t1\tclaude --dangerously-skip-permissions\nshell\t1700000000\t0\tzsh\n"), 0644))
+
+	notifier := &recordingNotifier{}
+	watcher, err := NewAgentWatcher(database, config.AINotifyConfig{Quiet: "1m", Prompts: []string{`Shall I go on\?`}}, notifier)
+	require.NoError(t, err)
+	start := time.Now()
+
+	// Agents seen for the first time are only recorded; shells are not agents
+	setScreen("Thinking...\n")
+	watcher.check(ctx, start)
+	assert.Empty(t, notifier.events)
+	require.NotNil(t, watcher.Activity("app-feature-ai", "claude"))
+	assert.Equal(t, AgentWorking, watcher.Activity("app-feature-ai", "claude").State)
+	assert.Nil(t, watcher.Activity("app-feature-ai", "shell"))
+
+	setScreen("Reading main.go\n")
+	watcher.check(ctx, start.Add(30*time.Second))
+	assert.Empty(t, notifier.events)
+
+	// A screen that stays the same for the quiet period means the agent is done
+	watcher.check(ctx, start.Add(2*time.Minute))
+	require.Len(t, notifier.events, 1)
+	assert.Equal(t, "agent.finished", notifier.events[0].Event)
+	assert.Equal(t, AgentReasonQuiet, notifier.events[0].Reason)
+	assert.Equal(t, "app", notifier.events[0].Repository)
+	assert.Equal(t, "claude", notifier.events[0].Session)
+	assert.Equal(t, "Reading main.go", notifier.events[0].Screen)
+	assert.Equal(t, "Agent in app/feature (claude) finished", notifier.events[0].Message())
+
+	// Being told again about the same state is not news
+	watcher.check(ctx, start.Add(3*time.Minute))
+	assert.Len(t, notifier.events, 1)
+
+	// A question at the bottom of the screen means it waits for an answer
+	setScreen("Edit main.go\n\nDo you want to make this edit to main.go?\n> 1. Yes\n  2. No\n\n")
+	watcher.check(ctx, start.Add(4*time.Minute))
+	require.Len(t, notifier.events, 2)
+	assert.Equal(t, "agent.waiting", notifier.events[1].Event)
+	assert.Equal(t, AgentReasonPrompt, notifier.events[1].Reason)
+	assert.Equal(t, AgentWaiting, watcher.Activity("app-feature-ai", "claude").State)
+
+	setScreen("Editing main.go\n")
+	watcher.check(ctx, start.Add(5*time.Minute))
+	assert.Len(t, notifier.events, 2)
+	assert.Equal(t, AgentWorking, watcher.Activity("app-feature-ai", "claude").State)
+
+	// Configured prompts are matched too
+	setScreen("Tests pass. Shall I go on?\n")
+	watcher.check(ctx, start.Add(5*time.Minute+10*time.Second))
+	require.Len(t, notifier.events, 3)
+	assert.Equal(t, "agent.waiting", notifier.events[2].Event)
+
+	// The session ending means the agent exited
+	require.NoError(t, os.WriteFile(sessions, []byte("shell\t1700000000\t0\tzsh\n"), 0644))
+	watcher.check(ctx, start.Add(6*time.Minute))
+	require.Len(t, notifier.events, 4)
+	assert.Equal(t, "agent.finished", notifier.events[3].Event)
+	assert.Equal(t, AgentReasonExited, notifier.events[3].Reason)
+	assert.Nil(t, watcher.Activity("app-feature-ai", "claude"))
+
+	_, err = NewAgentWatcher(database, config.AINotifyConfig{Prompts: []string{"(unclosed"}})
+	assert.Error(t, err)
+}
+
+func TestAgentNotifiers(t *testing.T) {
+	event := &AgentEvent{Event: "agent.waiting", Repository: "app", Worktree: "feature", Session: "claude", Reason: AgentReasonPrompt, Screen: "export TOKEN=s3cret", Time: time.Now()}
+
+	var received map[string]interface{}
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer hook.Close()
+	require.NoError(t, (&WebhookNotifier{URL: hook.URL}).Notify(context.Background(), event))
+	assert.Equal(t, "agent.waiting", received["event"])
+	assert.Equal(t, "Agent in app/feature (claude) is waiting for input", received["message"])
+	assert.NotContains(t, received, "screen", "the terminal stays private unless asked for")
+	assert.Equal(t, "export TOKEN=s3cret", event.Screen)
+
+	require.NoError(t, (&WebhookNotifier{URL: hook.URL, Screen: true}).Notify(context.Background(), event))
+	assert.Equal(t, "export TOKEN=s3cret", received["screen"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.ErrorContains(t, (&WebhookNotifier{URL: failing.URL}).Notify(context.Background(), event), "500")
+
+	out := filepath.Join(t.TempDir(), "notified")
+	command := &CommandNotifier{Command: `echo "$VIBEMAN_EVENT $VIBEMAN_WORKTREE: $VIBEMAN_MESSAGE" > "` + out + `"`}
+	require.NoError(t, command.Notify(context.Background(), event))
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "agent.waiting feature: Agent in app/feature (claude) is waiting for input\n", string(content))
+	assert.ErrorContains(t, (&CommandNotifier{Command: "echo broken >&2; exit 1"}).Notify(context.Background(), event), "broken")
+
+	assert.Empty(t, NewAgentNotifiers(config.AINotifyConfig{}))
+	assert.Len(t, NewAgentNotifiers(config.AINotifyConfig{Webhook: hook.URL, Command: "true", Bell: true}), 3)
+}
+
+func TestIsAgentCommand(t *testing.T) {
+	assert.True(t, isAgentCommand("claude --dangerously-skip-permissions"))
+	assert.True(t, isAgentCommand(`"codex"`))
+	assert.False(t, isAgentCommand(""))
+	assert.False(t, isAgentCommand("zsh"))
+	assert.False(t, isAgentCommand("/bin/bash -l"))
+}
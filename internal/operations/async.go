@@ -0,0 +1,273 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/errors"
+	"vibeman/internal/logger"
+)
+
+// Operation types run by the AsyncRunner
+const (
+	OperationCreateWorktree = "create_worktree"
+	OperationStartWorktree  = "start_worktree"
+)
+
+// AsyncFunc is the body of an asynchronous operation. It returns the result
+// payload stored on the operation, or an error.
+type AsyncFunc func(ctx context.Context) (interface{}, error)
+
+// AsyncRunner runs long operations in the background, persisting their
+// progress to the operations table
+type AsyncRunner struct {
+	db      *db.DB
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewAsyncRunner creates a new asynchronous operation runner
+func NewAsyncRunner(database *db.DB) *AsyncRunner {
+	return &AsyncRunner{
+		db:      database,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start records a new operation and runs fn in the background. The operation
+// keeps the values of ctx (such as the trace span) but not its cancellation,
+// so it outlives the request that started it.
+func (r *AsyncRunner) Start(ctx context.Context, opType, targetID string, fn AsyncFunc) (*db.Operation, error) {
+	op := &db.Operation{
+		ID:       generateID(),
+		Type:     opType,
+		TargetID: targetID,
+		Status:   db.OperationPending,
+		Steps:    db.OperationSteps{},
+	}
+
+	repo := db.NewOperationRepository(r.db)
+	if err := repo.Create(ctx, op); err != nil {
+		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to create operation", err)
+	}
+
+	opCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.mu.Lock()
+	r.cancels[op.ID] = cancel
+	r.mu.Unlock()
+
+	tracker := &operationTracker{repo: repo, op: *op}
+	opCtx = WithProgress(opCtx, tracker)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() {
+			r.mu.Lock()
+			delete(r.cancels, op.ID)
+			r.mu.Unlock()
+			cancel()
+		}()
+
+		tracker.setStatus(db.OperationRunning)
+		result, err := fn(opCtx)
+		tracker.finish(opCtx, result, err)
+	}()
+
+	return op, nil
+}
+
+// Cancel requests cancellation of a running operation
+func (r *AsyncRunner) Cancel(ctx context.Context, id string) (*db.Operation, error) {
+	repo := db.NewOperationRepository(r.db)
+	op, err := repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.Status.IsFinished() {
+		return nil, errors.New(errors.ErrInvalidState, "operation has already finished").WithContext("operation_id", id)
+	}
+
+	r.mu.Lock()
+	cancel, ok := r.cancels[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, errors.New(errors.ErrInvalidState, "operation is not running on this server").WithContext("operation_id", id)
+	}
+
+	cancel()
+	return op, nil
+}
+
+// Get returns an operation by ID
+func (r *AsyncRunner) Get(ctx context.Context, id string) (*db.Operation, error) {
+	return db.NewOperationRepository(r.db).Get(ctx, id)
+}
+
+// List returns operations filtered by status and target
+func (r *AsyncRunner) List(ctx context.Context, status, targetID string) ([]*db.Operation, error) {
+	return db.NewOperationRepository(r.db).List(ctx, status, targetID)
+}
+
+// RecoverInterrupted marks operations left pending or running by a previous
+// process as failed, and worktrees they left starting as stopped
+func (r *AsyncRunner) RecoverInterrupted(ctx context.Context) error {
+	count, err := db.NewOperationRepository(r.db).FailUnfinished(ctx, "interrupted by server restart")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.WithField("count", count).Warn("Marked interrupted operations as failed")
+	}
+
+	worktreeRepo := db.NewWorktreeRepository(r.db)
+	starting, err := worktreeRepo.List(ctx, "", string(db.StatusStarting))
+	if err != nil {
+		return err
+	}
+	for _, worktree := range starting {
+		if err := worktreeRepo.UpdateStatus(ctx, worktree.ID, db.StatusStopped); err != nil {
+			return err
+		}
+	}
+	if len(starting) > 0 {
+		logger.WithField("count", len(starting)).Warn("Marked interrupted worktree starts as stopped")
+	}
+	return nil
+}
+
+// Shutdown cancels all running operations and waits for them to finish
+func (r *AsyncRunner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	for _, cancel := range r.cancels {
+		cancel()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// operationTracker persists progress events for a single operation
+type operationTracker struct {
+	repo *db.OperationRepository
+	mu   sync.Mutex
+	op   db.Operation
+}
+
+// Report implements ProgressReporter
+func (t *operationTracker) Report(event ProgressEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	step := db.OperationStep{
+		Name:      event.Step,
+		Message:   event.Message,
+		Status:    string(event.Status),
+		Current:   event.Current,
+		Total:     event.Total,
+		Error:     event.Error,
+		StartedAt: now,
+	}
+	if event.Status != StepRunning {
+		step.FinishedAt = &now
+	}
+
+	// Update the matching step in place, otherwise append a new one
+	updated := false
+	for i := len(t.op.Steps) - 1; i >= 0; i-- {
+		existing := &t.op.Steps[i]
+		if existing.Name == step.Name && existing.Current == step.Current && existing.FinishedAt == nil {
+			step.StartedAt = existing.StartedAt
+			*existing = step
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		t.op.Steps = append(t.op.Steps, step)
+	}
+	t.op.CurrentStep = event.Step
+
+	t.save()
+}
+
+// setStatus updates the operation status
+func (t *operationTracker) setStatus(status db.OperationStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.op.Status = status
+	t.save()
+}
+
+// finish records the outcome of the operation
+func (t *operationTracker) finish(ctx context.Context, result interface{}, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.op.FinishedAt = &now
+
+	switch {
+	case err != nil && ctx.Err() == context.Canceled:
+		t.op.Status = db.OperationCancelled
+		t.op.Error = "operation cancelled"
+	case err != nil:
+		t.op.Status = db.OperationFailed
+		t.op.Error = err.Error()
+	default:
+		t.op.Status = db.OperationSucceeded
+		if result != nil {
+			payload, marshalErr := toJSONB(result)
+			if marshalErr != nil {
+				logger.WithError(marshalErr).WithField("operation_id", t.op.ID).Warn("Failed to encode operation result")
+			}
+			t.op.Result = payload
+		}
+	}
+
+	t.save()
+}
+
+// save persists the tracked operation, detached from cancellation so the
+// final state is written even when the operation was cancelled. Callers must
+// hold t.mu.
+func (t *operationTracker) save() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := t.repo.Update(ctx, &t.op); err != nil {
+		logger.WithError(err).WithField("operation_id", t.op.ID).Warn("Failed to persist operation progress")
+	}
+}
+
+// toJSONB converts a result value into a JSON object column
+func toJSONB(value interface{}) (db.JSONB, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var payload db.JSONB
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("result is not a JSON object: %w", err)
+	}
+	return payload, nil
+}
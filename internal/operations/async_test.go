@@ -0,0 +1,142 @@
+package operations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForOperation polls until the operation has finished
+func waitForOperation(t *testing.T, runner *AsyncRunner, id string) *db.Operation {
+	t.Helper()
+
+	var op *db.Operation
+	require.Eventually(t, func() bool {
+		var err error
+		op, err = runner.Get(context.Background(), id)
+		require.NoError(t, err)
+		return op.Status.IsFinished()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return op
+}
+
+func TestAsyncRunner_RecordsProgressAndResult(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	runner := NewAsyncRunner(database)
+
+	op, err := runner.Start(context.Background(), OperationCreateWorktree, "repo-123", func(ctx context.Context) (interface{}, error) {
+		stepStarted(ctx, StepGitWorktree, "Creating git worktree")
+		stepCompleted(ctx, StepGitWorktree, "Created git worktree")
+		reportProgress(ctx, ProgressEvent{Step: StepSetup, Message: "npm install", Status: StepRunning, Current: 1, Total: 2})
+		reportProgress(ctx, ProgressEvent{Step: StepSetup, Message: "npm install", Status: StepCompleted, Current: 1, Total: 2})
+		reportProgress(ctx, ProgressEvent{Step: StepSetup, Message: "npm run build", Status: StepCompleted, Current: 2, Total: 2})
+		return map[string]string{"id": "wt-123"}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, db.OperationPending, op.Status)
+
+	final := waitForOperation(t, runner, op.ID)
+	assert.Equal(t, db.OperationSucceeded, final.Status)
+	assert.Equal(t, "repo-123", final.TargetID)
+	assert.Equal(t, StepSetup, final.CurrentStep)
+	assert.Equal(t, "wt-123", final.Result["id"])
+	assert.NotNil(t, final.FinishedAt)
+
+	// Running events are updated in place when the step finishes
+	require.Len(t, final.Steps, 3)
+	assert.Equal(t, StepGitWorktree, final.Steps[0].Name)
+	assert.Equal(t, string(StepCompleted), final.Steps[0].Status)
+	assert.Equal(t, 1, final.Steps[1].Current)
+	assert.Equal(t, 2, final.Steps[2].Current)
+	assert.Equal(t, 2, final.Steps[2].Total)
+
+	require.NoError(t, runner.Shutdown(context.Background()))
+}
+
+func TestAsyncRunner_Failure(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	runner := NewAsyncRunner(database)
+
+	op, err := runner.Start(context.Background(), OperationStartWorktree, "wt-123", func(ctx context.Context) (interface{}, error) {
+		return nil, assert.AnError
+	})
+	require.NoError(t, err)
+
+	final := waitForOperation(t, runner, op.ID)
+	assert.Equal(t, db.OperationFailed, final.Status)
+	assert.Equal(t, assert.AnError.Error(), final.Error)
+}
+
+func TestAsyncRunner_Cancel(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	runner := NewAsyncRunner(database)
+
+	started := make(chan struct{})
+	op, err := runner.Start(context.Background(), OperationCreateWorktree, "repo-123", func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	require.NoError(t, err)
+	<-started
+
+	_, err = runner.Cancel(context.Background(), op.ID)
+	require.NoError(t, err)
+
+	final := waitForOperation(t, runner, op.ID)
+	assert.Equal(t, db.OperationCancelled, final.Status)
+
+	// Cancelling a finished operation is rejected
+	_, err = runner.Cancel(context.Background(), op.ID)
+	assert.Error(t, err)
+}
+
+func TestAsyncRunner_OutlivesRequestContext(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	runner := NewAsyncRunner(database)
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	op, err := runner.Start(reqCtx, OperationStartWorktree, "wt-123", func(ctx context.Context) (interface{}, error) {
+		<-release
+		return nil, ctx.Err()
+	})
+	require.NoError(t, err)
+
+	cancel()
+	close(release)
+
+	final := waitForOperation(t, runner, op.ID)
+	assert.Equal(t, db.OperationSucceeded, final.Status)
+}
+
+func TestAsyncRunner_RecoverInterrupted(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	repo := db.NewOperationRepository(database)
+
+	interrupted := &db.Operation{ID: "op-1", Type: OperationCreateWorktree, Status: db.OperationRunning}
+	require.NoError(t, repo.Create(context.Background(), interrupted))
+	require.NoError(t, db.NewRepositoryRepository(database).Create(context.Background(), &db.Repository{ID: "app", Name: "app", Path: "/tmp/app"}))
+	worktreeRepo := db.NewWorktreeRepository(database)
+	require.NoError(t, worktreeRepo.Create(context.Background(), &db.Worktree{ID: "wt-1", RepositoryID: "app", Name: "feature", Branch: "feature", Path: "/tmp/app-feature", Status: db.StatusStarting}))
+
+	runner := NewAsyncRunner(database)
+	require.NoError(t, runner.RecoverInterrupted(context.Background()))
+
+	op, err := runner.Get(context.Background(), "op-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.OperationFailed, op.Status)
+	assert.NotEmpty(t, op.Error)
+
+	// The worktree the interrupted start left starting can be started again
+	worktree, err := worktreeRepo.Get(context.Background(), "wt-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusStopped, worktree.Status)
+}
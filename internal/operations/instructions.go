@@ -0,0 +1,270 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/service"
+	"vibeman/internal/telemetry"
+	"vibeman/internal/xdg"
+)
+
+// InstructionsTemplateFile is the template agent instructions are generated
+// from, relative to a repository or the global config directory
+const InstructionsTemplateFile = ".vibeman/instructions.md.tmpl"
+
+// defaultInstructionsFile is where instructions are written for agents that
+// do not name a file of their own
+const defaultInstructionsFile = "CLAUDE.md"
+
+// defaultInstructionsTemplate is used when neither the repository nor the
+// global config directory has a template
+const defaultInstructionsTemplate = `# {{.Repository}} - {{.Worktree}} Worktree
+
+This is a development worktree for the {{.Repository}} repository.
+
+## Worktree Information
+- Repository: {{.Repository}}
+- Worktree Name: {{.Worktree}}
+- Branch: {{.Branch}}
+- Directory: {{.Path}}
+- Logs: {{.LogDir}} ({{.ContainerLogDir}} in the AI container)
+{{- if .Services}}
+
+## Services
+{{range .Services}}
+- {{.Name}}: {{if .URL}}{{.URL}}{{else}}{{.Host}}:{{.Port}}{{end}}
+{{- end}}
+
+Connection details are also in the VIBEMAN_SERVICE_* environment variables.
+{{- end}}
+{{- if or .Commands.Setup .Commands.Test}}
+
+## Commands
+{{- if .Commands.Setup}}
+- Setup: ` + "`{{.Commands.Setup}}`" + `
+{{- end}}
+{{- if .Commands.Test}}
+- Tests: ` + "`{{.Commands.Test}}`" + `
+{{- end}}
+{{- end}}
+
+## Getting Started
+
+1. Your development environment is containerized
+2. All changes should be made within this worktree
+3. Use git commands as normal within this directory
+
+## Container Commands
+
+Start container:
+vibeman start
+
+Stop container:
+vibeman stop
+
+View logs:
+vibeman logs
+
+## Notes
+
+This file was automatically generated by Vibeman.
+`
+
+// InstructionsData is what instruction templates are executed with
+type InstructionsData struct {
+	Repository      string
+	Worktree        string
+	Branch          string
+	Path            string // Worktree directory on the host
+	Agent           string // Agent provider the instructions are for
+	LogDir          string // Worktree's log directory on the host
+	ContainerLogDir string // Worktree's log directory in the AI container
+	// Services the worktree uses, as reached from the AI container, with
+	// their published ports. Passwords are left out.
+	Services []*service.Endpoint
+	Env      map[string]string // Service connection environment variables, without passwords
+	Commands InstructionsCommands
+}
+
+// InstructionsCommands are the commands a repository configures
+type InstructionsCommands struct {
+	Setup          string   // [repository.setup] worktree_init
+	ContainerInit  []string // [repository.setup] container_init
+	ContainerSetup []string // [repository.container] setup
+	Test           string   // [repository.ai] test
+}
+
+// instructionsFile returns the configured agent and the file, relative to
+// the worktree, it reads its instructions from
+func instructionsFile(repoConfig *config.RepositoryConfig) (string, string) {
+	agentName, agent, err := repoConfig.Repository.AI.ResolveAgent("")
+	if err != nil {
+		agentName, agent, _ = (&config.AIConfig{}).ResolveAgent("")
+	}
+	if agent.Instructions == "" {
+		return agentName, defaultInstructionsFile
+	}
+	return agentName, agent.Instructions
+}
+
+// writeInstructions renders the agent instructions of a worktree into the
+// file its agent reads them from. The file is only rewritten when what was
+// rendered changed since it was last written, so edits made to it while
+// nothing it is generated from changed are kept. A file the repository
+// tracks is the repository's own and is never written, and neither is one
+// the worktree, which agents can edit, turned into a symlink. The template
+// is taken from the repository's checkout for the same reason. serviceEnv
+// holds the service connection environment as KEY=value pairs, if known.
+func writeInstructions(ctx context.Context, repo *db.Repository, worktree *db.Worktree, repoConfig *config.RepositoryConfig, serviceEnv []string) error {
+	agentName, file := instructionsFile(repoConfig)
+	if !filepath.IsLocal(file) {
+		return fmt.Errorf("instructions file %q must be relative to the worktree", file)
+	}
+	if telemetry.Run(ctx, exec.CommandContext(ctx, "git", "-C", worktree.Path, "ls-files", "--error-unmatch", "--", file)) == nil {
+		return nil
+	}
+
+	tmpl, err := loadInstructionsTemplate(repo.Path, aiCheckoutConfig(repo))
+	if err != nil {
+		return err
+	}
+	data := newInstructionsData(repo, worktree, repoConfig, agentName, serviceEnv)
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return fmt.Errorf("failed to render agent instructions: %w", err)
+	}
+
+	stateDir, err := xdg.StateDir()
+	if err != nil {
+		return fmt.Errorf("failed to get state directory: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(file+"\x00"), rendered.Bytes()...))
+	hash := hex.EncodeToString(sum[:])
+	hashPath := filepath.Join(stateDir, "instructions", worktree.ID)
+	if previous, err := os.ReadFile(hashPath); err == nil && string(previous) == hash {
+		return nil
+	}
+
+	// The file is generated, so it is kept out of commits
+	if err := service.ExcludeFromGit(ctx, worktree.Path, file); err != nil {
+		return err
+	}
+	if err := service.WriteWorktreeFile(worktree.Path, file, rendered.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(hashPath), 0755); err != nil {
+		return fmt.Errorf("failed to create instructions state directory: %w", err)
+	}
+	return os.WriteFile(hashPath, []byte(hash), 0644)
+}
+
+// loadInstructionsTemplate loads the instructions template of the
+// repository checked out at repoDir, falling back to the one in the global
+// config directory and then the built-in one. A template the repository's
+// [repository.ai] configures must exist.
+func loadInstructionsTemplate(repoDir string, ai *config.AIConfig) (*template.Template, error) {
+	path, required := filepath.Join(repoDir, InstructionsTemplateFile), false
+	if configured := ai.InstructionsTemplate; configured != "" {
+		if !filepath.IsLocal(configured) {
+			return nil, fmt.Errorf("instructions template %q must be relative to the repository", configured)
+		}
+		path, required = filepath.Join(repoDir, configured), true
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		path = ""
+		if configDir, dirErr := config.GetConfigDir(); dirErr == nil {
+			path = filepath.Join(configDir, filepath.Base(InstructionsTemplateFile))
+			data, err = os.ReadFile(path)
+		}
+	}
+	text, name := string(data), filepath.Base(path)
+	switch {
+	case path == "" || (os.IsNotExist(err) && !required):
+		text, name = defaultInstructionsTemplate, "default"
+	case err != nil:
+		return nil, fmt.Errorf("failed to read instructions template: %w", err)
+	}
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"join": strings.Join}).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse instructions template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// newInstructionsData collects what instruction templates can refer to.
+// Instructions end up in files agents and users share, so passwords are
+// left out: agents read them from the environment instead.
+func newInstructionsData(repo *db.Repository, worktree *db.Worktree, repoConfig *config.RepositoryConfig, agent string, serviceEnv []string) *InstructionsData {
+	env := map[string]string{}
+	var document string
+	for _, pair := range serviceEnv {
+		key, value, ok := strings.Cut(pair, "=")
+		switch {
+		case !ok || strings.HasSuffix(key, "PASSWORD"):
+		case key == "VIBEMAN_SERVICES":
+			document = value
+		default:
+			env[key] = redactURL(value)
+		}
+	}
+
+	// VIBEMAN_SERVICES describes every resolved service, keyed by name
+	var endpoints map[string]*service.Endpoint
+	json.Unmarshal([]byte(document), &endpoints)
+	services := make([]*service.Endpoint, 0, len(endpoints))
+	for _, name := range slices.Sorted(maps.Keys(endpoints)) {
+		endpoint := endpoints[name]
+		endpoint.Password = ""
+		endpoint.URL = redactURL(endpoint.URL)
+		services = append(services, endpoint)
+	}
+
+	return &InstructionsData{
+		Repository:      repo.Name,
+		Worktree:        worktree.Name,
+		Branch:          worktree.Branch,
+		Path:            worktree.Path,
+		Agent:           agent,
+		LogDir:          filepath.Join(xdg.LogsDir(), repo.Name, worktree.Name),
+		ContainerLogDir: "/logs",
+		Services:        services,
+		Env:             env,
+		Commands: InstructionsCommands{
+			Setup:          repoConfig.Repository.Setup.WorktreeInit,
+			ContainerInit:  repoConfig.Repository.Setup.ContainerInit,
+			ContainerSetup: repoConfig.Repository.Container.Setup,
+			Test:           repoConfig.Repository.AI.Test,
+		},
+	}
+}
+
+// redactURL replaces the password in a URL with xxxxx, leaving values that
+// are not URLs with a password as they are
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	return u.Redacted()
+}
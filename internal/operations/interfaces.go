@@ -4,6 +4,7 @@ import (
 	"context"
 	
 	"vibeman/internal/container"
+	"vibeman/internal/service"
 )
 
 // GitManager defines the interface for git operations used by operations
@@ -42,3 +43,29 @@ type ServiceManager interface {
 	RemoveReference(serviceName, repoName string) error
 }
 
+// ServiceProvisioner is implemented by service managers that give each
+// worktree its own resource, such as a database, on shared services
+type ServiceProvisioner interface {
+	ProvisionWorktree(ctx context.Context, serviceName, worktreeID, baseName string) (map[string]string, error)
+	DeprovisionWorktree(ctx context.Context, worktreeID string) error
+}
+
+// ServiceReleaser is implemented by service managers that can drop every
+// reference a worktree holds, whatever its configuration lists now
+type ServiceReleaser interface {
+	ReleaseReferences(ctx context.Context, holder string) error
+}
+
+// ServiceDiscoverer is implemented by service managers that can tell a
+// worktree how to reach the services it uses
+type ServiceDiscoverer interface {
+	ServiceEnv(ctx context.Context, worktreeID string, services []string, inContainer bool) (map[string]string, error)
+}
+
+// ServiceSnapshotter is implemented by service managers that can save a
+// service's data and restore it later
+type ServiceSnapshotter interface {
+	CreateSnapshot(ctx context.Context, name string, opts service.SnapshotOptions) (*service.Snapshot, error)
+	ListSnapshots(name string) ([]*service.Snapshot, error)
+	RestoreSnapshot(ctx context.Context, name, ref string, opts service.SnapshotOptions) (*service.Snapshot, error)
+}
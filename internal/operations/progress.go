@@ -0,0 +1,85 @@
+package operations
+
+import (
+	"context"
+)
+
+// StepStatus represents the state of a progress step
+type StepStatus string
+
+const (
+	StepRunning   StepStatus = "running"
+	StepCompleted StepStatus = "completed"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped"
+)
+
+// Progress step names reported by worktree operations
+const (
+	StepGitWorktree = "git_worktree"
+	StepConfig      = "config"
+	StepServices    = "services"
+	StepSetup       = "setup"
+	StepAI          = "ai"
+)
+
+// ProgressEvent describes a change in the progress of an operation
+type ProgressEvent struct {
+	Step    string     `json:"step"`
+	Message string     `json:"message,omitempty"`
+	Status  StepStatus `json:"status"`
+	Current int        `json:"current,omitempty"` // Sub-step number, e.g. setup step N of M
+	Total   int        `json:"total,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// ProgressReporter receives progress events from long-running operations
+type ProgressReporter interface {
+	Report(event ProgressEvent)
+}
+
+// ProgressFunc adapts a function to the ProgressReporter interface
+type ProgressFunc func(event ProgressEvent)
+
+// Report calls f(event)
+func (f ProgressFunc) Report(event ProgressEvent) {
+	f(event)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context that delivers operation progress to reporter
+func WithProgress(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// reportProgress sends an event to the reporter attached to ctx, if any
+func reportProgress(ctx context.Context, event ProgressEvent) {
+	if reporter, ok := ctx.Value(progressKey{}).(ProgressReporter); ok && reporter != nil {
+		reporter.Report(event)
+	}
+}
+
+// stepStarted reports that a step has started
+func stepStarted(ctx context.Context, step, message string) {
+	reportProgress(ctx, ProgressEvent{Step: step, Message: message, Status: StepRunning})
+}
+
+// stepCompleted reports that a step has completed
+func stepCompleted(ctx context.Context, step, message string) {
+	reportProgress(ctx, ProgressEvent{Step: step, Message: message, Status: StepCompleted})
+}
+
+// stepFailed reports that a step has failed
+func stepFailed(ctx context.Context, step, message string, err error) {
+	event := ProgressEvent{Step: step, Message: message, Status: StepFailed}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	reportProgress(ctx, event)
+}
+
+// stepSkipped reports that a step was skipped
+func stepSkipped(ctx context.Context, step, message string) {
+	reportProgress(ctx, ProgressEvent{Step: step, Message: message, Status: StepSkipped})
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"vibeman/internal/audit"
 	"vibeman/internal/config"
 	"vibeman/internal/constants"
 	"vibeman/internal/db"
@@ -38,10 +39,20 @@ type AddRepositoryRequest struct {
 	Path        string // Can be local path or git URL
 	Name        string // Optional, will be detected if not provided
 	Description string // Optional description
+	OwnerID     string // User adding the repository, if authenticated
+	TeamID      string // Optional team to share the repository with
 }
 
 // AddRepository adds a new repository to tracking
-func (ro *RepositoryOperations) AddRepository(ctx context.Context, req AddRepositoryRequest) (*db.Repository, error) {
+func (ro *RepositoryOperations) AddRepository(ctx context.Context, req AddRepositoryRequest) (repo *db.Repository, err error) {
+	defer func() {
+		target := audit.Target{Type: audit.TargetRepository, Name: req.Name}
+		if repo != nil {
+			target.ID, target.Name = repo.ID, repo.Name
+		}
+		audit.Record(ctx, audit.ActionRepositoryAdd, target, audit.Params{"path": req.Path, "team_id": req.TeamID}, err)
+	}()
+
 	var repoPath string
 	var isClone bool
 
@@ -128,11 +139,13 @@ func (ro *RepositoryOperations) AddRepository(ctx context.Context, req AddReposi
 	}
 
 	// Create database record
-	repo := &db.Repository{
+	repo = &db.Repository{
 		ID:          uuid.New().String(),
 		Path:        repoPath,
 		Name:        repoConfig.Repository.Name,
 		Description: repoConfig.Repository.Description,
+		OwnerID:     req.OwnerID,
+		TeamID:      req.TeamID,
 	}
 
 	if repo.Name == "" {
@@ -157,13 +170,17 @@ func (ro *RepositoryOperations) AddRepository(ctx context.Context, req AddReposi
 }
 
 // RemoveRepository removes a repository from tracking (does not delete files)
-func (ro *RepositoryOperations) RemoveRepository(ctx context.Context, repositoryID string) error {
+func (ro *RepositoryOperations) RemoveRepository(ctx context.Context, repositoryID string) (err error) {
+	target := audit.Target{Type: audit.TargetRepository, ID: repositoryID}
+	defer func() { audit.Record(ctx, audit.ActionRepositoryRemove, target, nil, err) }()
+
 	// Get repository
 	repoRepo := db.NewRepositoryRepository(ro.db)
 	repo, err := repoRepo.GetByID(ctx, repositoryID)
 	if err != nil {
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to get repository", err).WithContext("repository_id", repositoryID)
 	}
+	target.Name = repo.Name
 
 	// Check for active worktrees
 	worktreeRepo := db.NewWorktreeRepository(ro.db)
@@ -3,9 +3,13 @@ package operations
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
+	"vibeman/internal/audit"
 	"vibeman/internal/config"
+	"vibeman/internal/health"
 	"vibeman/internal/logger"
 	"vibeman/internal/service"
 )
@@ -19,7 +23,9 @@ type ServiceInfo struct {
 	Repositories []string            `json:"repositories"`
 	StartTime    *time.Time          `json:"start_time,omitempty"`
 	Uptime       string              `json:"uptime,omitempty"`
+	Health       health.Status       `json:"health,omitempty"`
 	HealthError  string              `json:"health_error,omitempty"`
+	Restarts     int                 `json:"restarts,omitempty"`
 	Config       config.ServiceConfig `json:"config"`
 }
 
@@ -46,8 +52,8 @@ func (so *ServiceOperations) ListServices(ctx context.Context) ([]*ServiceInfo,
 
 	var services []*ServiceInfo
 
-	// Get all configured services
-	for name, serviceConfig := range so.cfg.Services.Services {
+	// Get all configured services and their versions
+	for name, serviceConfig := range so.cfg.Services.All() {
 		// Get the service instance if it exists
 		instanceInterface, err := so.serviceMgr.GetService(name)
 		if err != nil {
@@ -84,7 +90,9 @@ func (so *ServiceOperations) ListServices(ctx context.Context) ([]*ServiceInfo,
 			Repositories: instance.Repositories,
 			StartTime:    startTime,
 			Uptime:       uptime,
+			Health:       instance.Health,
 			HealthError:  instance.HealthError,
+			Restarts:     instance.Restarts,
 			Config:       instance.Config,
 		})
 	}
@@ -99,7 +107,7 @@ func (so *ServiceOperations) GetService(ctx context.Context, name string) (*Serv
 	}
 
 	// Check if service configuration exists
-	serviceConfig, exists := so.cfg.Services.Services[name]
+	serviceConfig, exists := so.cfg.Services.Lookup(name)
 	if !exists {
 		return nil, fmt.Errorf("service configuration not found: %s", name)
 	}
@@ -138,6 +146,7 @@ func (so *ServiceOperations) GetService(ctx context.Context, name string) (*Serv
 		Repositories: instance.Repositories,
 		StartTime:    startTime,
 		Uptime:       uptime,
+		Health:       instance.Health,
 		HealthError:  instance.HealthError,
 		Config:       instance.Config,
 	}, nil
@@ -145,20 +154,40 @@ func (so *ServiceOperations) GetService(ctx context.Context, name string) (*Serv
 
 // StartService starts a service
 func (so *ServiceOperations) StartService(ctx context.Context, name string) error {
+	return so.startService(ctx, name, true)
+}
+
+// startService starts a service. Only a service started by hand is kept
+// running when no worktree or dependent uses it; one started as a
+// dependency is released with its dependents.
+func (so *ServiceOperations) startService(ctx context.Context, name string, manual bool) (err error) {
+	defer func() { audit.Record(ctx, audit.ActionServiceStart, serviceTarget(name), nil, err) }()
+
 	logger.WithField("service", name).Info("Starting service")
 
 	if err := so.serviceMgr.StartService(ctx, name); err != nil {
 		return fmt.Errorf("failed to start service %s: %w", name, err)
 	}
 
+	if manual {
+		if err := so.serviceMgr.AddReference(name, service.ManualHolder); err != nil {
+			logger.WithError(err).Debug("Failed to add service reference")
+		}
+	}
+
 	logger.WithField("service", name).Info("Service started successfully")
 	return nil
 }
 
 // StopService stops a service
-func (so *ServiceOperations) StopService(ctx context.Context, name string) error {
+func (so *ServiceOperations) StopService(ctx context.Context, name string) (err error) {
+	defer func() { audit.Record(ctx, audit.ActionServiceStop, serviceTarget(name), nil, err) }()
+
 	logger.WithField("service", name).Info("Stopping service")
 
+	// Not every service was started by hand
+	_ = so.serviceMgr.RemoveReference(name, service.ManualHolder)
+
 	if err := so.serviceMgr.StopService(ctx, name); err != nil {
 		return fmt.Errorf("failed to stop service %s: %w", name, err)
 	}
@@ -168,7 +197,9 @@ func (so *ServiceOperations) StopService(ctx context.Context, name string) error
 }
 
 // RestartService restarts a service
-func (so *ServiceOperations) RestartService(ctx context.Context, name string) error {
+func (so *ServiceOperations) RestartService(ctx context.Context, name string) (err error) {
+	defer func() { audit.Record(ctx, audit.ActionServiceRestart, serviceTarget(name), nil, err) }()
+
 	logger.WithField("service", name).Info("Restarting service")
 
 	// Stop the service first
@@ -189,6 +220,115 @@ func (so *ServiceOperations) RestartService(ctx context.Context, name string) er
 	return nil
 }
 
+// CreateSnapshot saves a service's data
+func (so *ServiceOperations) CreateSnapshot(ctx context.Context, name string, opts service.SnapshotOptions) (snapshot *service.Snapshot, err error) {
+	params := audit.Params{"tag": opts.Tag, "kind": opts.Kind, "database": opts.Database, "worktree": opts.Worktree}
+	defer func() {
+		if snapshot != nil {
+			params["snapshot"] = snapshot.ID
+		}
+		audit.Record(ctx, audit.ActionServiceSnapshot, serviceTarget(name), params, err)
+	}()
+
+	snapshotter, err := so.snapshotter()
+	if err != nil {
+		return nil, err
+	}
+	return snapshotter.CreateSnapshot(ctx, name, opts)
+}
+
+// ListSnapshots returns the snapshots of a service, or of every service when
+// name is empty, newest first
+func (so *ServiceOperations) ListSnapshots(name string) ([]*service.Snapshot, error) {
+	snapshotter, err := so.snapshotter()
+	if err != nil {
+		return nil, err
+	}
+	return snapshotter.ListSnapshots(name)
+}
+
+// RestoreSnapshot replaces a service's data with a snapshot, named by ID or
+// tag
+func (so *ServiceOperations) RestoreSnapshot(ctx context.Context, name, ref string, opts service.SnapshotOptions) (snapshot *service.Snapshot, err error) {
+	params := audit.Params{"snapshot": ref, "database": opts.Database, "worktree": opts.Worktree}
+	defer func() { audit.Record(ctx, audit.ActionServiceRestore, serviceTarget(name), params, err) }()
+
+	snapshotter, err := so.snapshotter()
+	if err != nil {
+		return nil, err
+	}
+	return snapshotter.RestoreSnapshot(ctx, name, ref, opts)
+}
+
+// snapshotter returns the service manager if it supports snapshots
+func (so *ServiceOperations) snapshotter() (ServiceSnapshotter, error) {
+	snapshotter, ok := so.serviceMgr.(ServiceSnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("service snapshots are not supported")
+	}
+	return snapshotter, nil
+}
+
+// ServiceResult is the outcome for one service of starting or stopping
+// several
+type ServiceResult struct {
+	Name    string
+	Skipped bool  // Already running or stopped
+	Err     error // Why the service failed, or was not attempted
+}
+
+// StartServices starts the named services, or every configured service when
+// none are named, together with everything they depend on. Services start a
+// layer of the dependency graph at a time, the services of a layer in
+// parallel; services whose dependencies failed are not started.
+func (so *ServiceOperations) StartServices(ctx context.Context, names ...string) ([]*ServiceResult, error) {
+	graph, err := so.ServiceGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	return runLayers(graph.StartLayers(names...), graph.Dependencies, func(name string) *ServiceResult {
+		if status := so.serviceStatus(name); status == service.StatusRunning || status == service.StatusDegraded {
+			return &ServiceResult{Name: name, Skipped: true}
+		}
+		// Only the services asked for are held as started by hand
+		manual := len(names) == 0 || slices.Contains(names, name)
+		return &ServiceResult{Name: name, Err: so.startService(ctx, name, manual)}
+	}), nil
+}
+
+// StopServices stops the named services, or every configured service when
+// none are named, after everything that depends on them. Services whose
+// dependents failed to stop keep running.
+func (so *ServiceOperations) StopServices(ctx context.Context, names ...string) ([]*ServiceResult, error) {
+	graph, err := so.ServiceGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	return runLayers(graph.StopLayers(names...), graph.Dependents, func(name string) *ServiceResult {
+		if !so.serviceStatus(name).Up() {
+			return &ServiceResult{Name: name, Skipped: true}
+		}
+		if err := so.StopService(ctx, name); err != nil {
+			return &ServiceResult{Name: name, Err: err}
+		}
+		// A service something else still references keeps running
+		if so.serviceStatus(name).Up() {
+			return &ServiceResult{Name: name, Err: fmt.Errorf("service %s is still in use", name)}
+		}
+		return &ServiceResult{Name: name}
+	}), nil
+}
+
+// ServiceGraph builds the dependency graph of the configured services
+func (so *ServiceOperations) ServiceGraph() (*config.ServiceGraph, error) {
+	if so.cfg.Services == nil {
+		return nil, fmt.Errorf("services configuration not available")
+	}
+	return config.NewServiceGraph(so.cfg.Services.Services)
+}
+
 // HealthCheckService performs a health check on a service
 func (so *ServiceOperations) HealthCheckService(ctx context.Context, name string) error {
 	return so.serviceMgr.HealthCheck(ctx, name)
@@ -196,6 +336,56 @@ func (so *ServiceOperations) HealthCheckService(ctx context.Context, name string
 
 // Helper functions
 
+// serviceStatus returns the status of a service, or stopped when it is
+// unknown
+func (so *ServiceOperations) serviceStatus(name string) service.ServiceStatus {
+	instance, err := so.serviceMgr.GetService(name)
+	if err != nil {
+		return service.StatusStopped
+	}
+	if svc, ok := instance.(*service.ServiceInstance); ok {
+		return svc.Status
+	}
+	return service.StatusUnknown
+}
+
+// runLayers runs fn for the services of each layer in parallel, one layer
+// after another. A service is not run when one of blockers(service) failed.
+func runLayers(layers [][]string, blockers func(string) []string, fn func(name string) *ServiceResult) []*ServiceResult {
+	var results []*ServiceResult
+	failed := map[string]bool{}
+
+	for _, layer := range layers {
+		layerResults := make([]*ServiceResult, len(layer))
+		var wg sync.WaitGroup
+		for i, name := range layer {
+			if blocker := slices.IndexFunc(blockers(name), func(other string) bool { return failed[other] }); blocker >= 0 {
+				layerResults[i] = &ServiceResult{Name: name, Err: fmt.Errorf("not attempted because %s failed", blockers(name)[blocker])}
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				layerResults[i] = fn(name)
+			}()
+		}
+		wg.Wait()
+
+		for _, result := range layerResults {
+			if result.Err != nil {
+				failed[result.Name] = true
+			}
+		}
+		results = append(results, layerResults...)
+	}
+	return results
+}
+
+// serviceTarget returns the audit target for a global service
+func serviceTarget(name string) audit.Target {
+	return audit.Target{Type: audit.TargetService, ID: name, Name: name}
+}
+
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	hours := int(d.Hours()) % 24
@@ -0,0 +1,248 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/compose"
+	"vibeman/internal/config"
+	"vibeman/internal/logger"
+)
+
+// servicesFileMu serializes changes to services.toml
+var servicesFileMu sync.Mutex
+
+// ImportServicesRequest selects the services to copy out of a compose file
+type ImportServicesRequest struct {
+	ComposeFile string   // Path of the docker-compose.yaml
+	Services    []string // Compose services to import; empty imports all of them
+	Inline      bool     // Copy each definition instead of referencing the compose file
+}
+
+// AddService adds a service to services.toml
+func (so *ServiceOperations) AddService(ctx context.Context, name string, svc config.ServiceConfig) (err error) {
+	defer func() { audit.Record(ctx, audit.ActionServiceAdd, serviceTarget(name), serviceParams(svc), err) }()
+
+	return so.changeServices(func(services map[string]config.ServiceConfig) error {
+		if _, exists := services[name]; exists {
+			return fmt.Errorf("service %s already exists", name)
+		}
+		return putService(services, name, svc)
+	})
+}
+
+// UpdateService replaces the configuration of a service in services.toml.
+// A running service picks up the change when it is next started.
+func (so *ServiceOperations) UpdateService(ctx context.Context, name string, svc config.ServiceConfig) (err error) {
+	defer func() { audit.Record(ctx, audit.ActionServiceEdit, serviceTarget(name), serviceParams(svc), err) }()
+
+	return so.changeServices(func(services map[string]config.ServiceConfig) error {
+		if _, exists := services[name]; !exists {
+			return fmt.Errorf("service configuration not found: %s", name)
+		}
+		return putService(services, name, svc)
+	})
+}
+
+// RemoveService removes a stopped service that nothing depends on from
+// services.toml
+func (so *ServiceOperations) RemoveService(ctx context.Context, name string) (err error) {
+	defer func() { audit.Record(ctx, audit.ActionServiceRemove, serviceTarget(name), nil, err) }()
+
+	if so.cfg.Services != nil {
+		for _, version := range slices.Sorted(maps.Keys(so.cfg.Services.Services[name].Versions)) {
+			if versioned := config.VersionedName(name, version); so.serviceStatus(versioned).Up() {
+				return fmt.Errorf("service %s is running; stop it first", versioned)
+			}
+		}
+	}
+	if so.serviceStatus(name).Up() {
+		return fmt.Errorf("service %s is running; stop it first", name)
+	}
+
+	return so.changeServices(func(services map[string]config.ServiceConfig) error {
+		if _, exists := services[name]; !exists {
+			return fmt.Errorf("service configuration not found: %s", name)
+		}
+		graph, err := config.NewServiceGraph(services)
+		if err != nil {
+			return err
+		}
+		if dependents := graph.Dependents(name); len(dependents) > 0 {
+			return fmt.Errorf("service %s is needed by %s", name, strings.Join(dependents, ", "))
+		}
+		delete(services, name)
+		return nil
+	})
+}
+
+// ImportServices adds services from a docker-compose.yaml to services.toml,
+// either referencing the compose file or copying each definition inline,
+// and returns the names of the imported services. Dependencies between
+// imported services are kept. Nothing is imported if any service fails.
+func (so *ServiceOperations) ImportServices(ctx context.Context, req ImportServicesRequest) (imported []string, err error) {
+	composePath, err := filepath.Abs(req.ComposeFile)
+	if err != nil {
+		return nil, err
+	}
+	composeFile, err := compose.ParseComposeFile(composePath)
+	if err != nil {
+		return nil, err
+	}
+
+	names := req.Services
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(composeFile.Services))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no services in %s", composePath)
+	}
+
+	imports := make(map[string]config.ServiceConfig, len(names))
+	for _, name := range names {
+		svc, err := importService(composeFile, composePath, name, req.Inline)
+		if err != nil {
+			return nil, err
+		}
+		imports[name] = svc
+	}
+
+	err = so.changeServices(func(services map[string]config.ServiceConfig) error {
+		for _, name := range names {
+			if _, exists := services[name]; exists {
+				return fmt.Errorf("service %s already exists", name)
+			}
+		}
+		for _, name := range names {
+			// Keep dependencies on services that exist once the import is done
+			svc := imports[name]
+			svc.DependsOn = slices.DeleteFunc(svc.DependsOn, func(dep string) bool {
+				_, known := services[dep]
+				_, importing := imports[dep]
+				return !known && !importing
+			})
+			services[name] = svc
+		}
+		for _, name := range names {
+			svc := services[name]
+			if err := config.ValidateServiceConfig(name, &svc, services); err != nil {
+				return fmt.Errorf("invalid service %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+
+	for _, name := range names {
+		params := serviceParams(imports[name])
+		params["imported_from"] = composePath
+		audit.Record(ctx, audit.ActionServiceAdd, serviceTarget(name), params, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// importService converts a compose service into a service configuration
+func importService(composeFile *compose.ComposeFile, composePath, name string, inline bool) (config.ServiceConfig, error) {
+	source, ok := composeFile.Services[name]
+	if !ok {
+		return config.ServiceConfig{}, fmt.Errorf("service %s not found in %s", name, composePath)
+	}
+
+	svc := config.ServiceConfig{
+		DependsOn: slices.Clone(source.DependsOn),
+	}
+	if !inline {
+		svc.ComposeFile = composePath
+		svc.Service = name
+		return svc, nil
+	}
+
+	if source.Image == "" {
+		return config.ServiceConfig{}, fmt.Errorf("service %s is built from source and can only be imported from the compose file", name)
+	}
+	parsed, err := composeFile.ParseService(name, filepath.Dir(composePath))
+	if err != nil {
+		return config.ServiceConfig{}, err
+	}
+
+	svc.Image = parsed.Image
+	svc.Service = config.InlineContainerName(name)
+	if source.ContainerName != "" {
+		svc.Service = source.ContainerName
+	}
+	svc.Command = parsed.Command
+	if len(parsed.Environment) > 0 {
+		svc.Env = parsed.Environment
+	}
+	for _, port := range parsed.Ports {
+		svc.Ports = append(svc.Ports, fmt.Sprintf("%d:%d", port.HostPort, port.ContainerPort))
+	}
+	for _, volume := range parsed.Volumes {
+		mount := volume.HostPath + ":" + volume.ContainerPath
+		if volume.ReadOnly {
+			mount += ":ro"
+		}
+		svc.Volumes = append(svc.Volumes, mount)
+	}
+	return svc, nil
+}
+
+// changeServices applies change to a copy of the configured services and,
+// if it succeeds, saves the result to services.toml
+func (so *ServiceOperations) changeServices(change func(services map[string]config.ServiceConfig) error) error {
+	servicesFileMu.Lock()
+	defer servicesFileMu.Unlock()
+
+	if so.cfg.Services == nil {
+		return fmt.Errorf("services configuration not available")
+	}
+
+	services := maps.Clone(so.cfg.Services.Services)
+	if services == nil {
+		services = map[string]config.ServiceConfig{}
+	}
+	if err := change(services); err != nil {
+		return err
+	}
+
+	previous := so.cfg.Services.Services
+	so.cfg.Services.Services = services
+	if err := so.cfg.SaveServices(); err != nil {
+		so.cfg.Services.Services = previous
+		return fmt.Errorf("failed to save services configuration: %w", err)
+	}
+
+	logger.WithField("services", len(services)).Info("Saved services configuration")
+	return nil
+}
+
+// putService validates a service and sets it in services
+func putService(services map[string]config.ServiceConfig, name string, svc config.ServiceConfig) error {
+	if name == "" {
+		return fmt.Errorf("service name cannot be empty")
+	}
+	if svc.IsInline() && svc.Service == "" {
+		svc.Service = config.InlineContainerName(name)
+	}
+	if err := config.ValidateServiceConfig(name, &svc, services); err != nil {
+		return err
+	}
+	services[name] = svc
+	return nil
+}
+
+// serviceParams returns the audit parameters describing a service
+func serviceParams(svc config.ServiceConfig) audit.Params {
+	if svc.IsInline() {
+		return audit.Params{"image": svc.Image}
+	}
+	return audit.Params{"compose_file": svc.ComposeFile, "service": svc.Service}
+}
@@ -0,0 +1,149 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vibeman/internal/config"
+	"vibeman/internal/service"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runningServices reports the named services as running
+type runningServices struct {
+	*testutil.MockServiceManager
+	running map[string]bool
+}
+
+func (r *runningServices) GetService(name string) (interface{}, error) {
+	status := service.StatusStopped
+	if r.running[name] {
+		status = service.StatusRunning
+	}
+	return &service.ServiceInstance{Name: name, Status: status}, nil
+}
+
+// newServiceConfigTestOps creates service operations whose services.toml is
+// in a temporary config directory
+func newServiceConfigTestOps(t *testing.T) (*ServiceOperations, *runningServices) {
+	t.Helper()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.New()
+	cfg.Services.Services = map[string]config.ServiceConfig{
+		"postgres": {ComposeFile: "/srv/docker-compose.yaml", Service: "db"},
+	}
+	sm := &runningServices{MockServiceManager: testutil.NewMockServiceManager(), running: map[string]bool{}}
+	return NewServiceOperations(cfg, sm), sm
+}
+
+func savedServices(t *testing.T) map[string]config.ServiceConfig {
+	t.Helper()
+
+	path, err := config.ServicesPath()
+	require.NoError(t, err)
+	saved, err := config.LoadServicesConfig(path)
+	require.NoError(t, err)
+	return saved.Services
+}
+
+func TestAddUpdateRemoveService(t *testing.T) {
+	ops, sm := newServiceConfigTestOps(t)
+	ctx := context.Background()
+
+	cache := config.ServiceConfig{
+		Image:     "redis:7-alpine",
+		Ports:     []string{"6380:6379"},
+		DependsOn: []string{"postgres"},
+	}
+	require.NoError(t, ops.AddService(ctx, "cache", cache))
+	saved := savedServices(t)
+	require.Contains(t, saved, "cache")
+	assert.Equal(t, "vibeman-service-cache", saved["cache"].Service)
+	assert.Equal(t, []string{"6380:6379"}, saved["cache"].Ports)
+	assert.Contains(t, saved, "postgres")
+
+	assert.ErrorContains(t, ops.AddService(ctx, "cache", cache), "service cache already exists")
+	assert.ErrorContains(t, ops.AddService(ctx, "bad", config.ServiceConfig{Image: "redis", Ports: []string{"nope"}}), `invalid port "nope"`)
+	assert.NotContains(t, savedServices(t), "bad")
+
+	cache.Image = "redis:8-alpine"
+	require.NoError(t, ops.UpdateService(ctx, "cache", cache))
+	assert.Equal(t, "redis:8-alpine", savedServices(t)["cache"].Image)
+	assert.ErrorContains(t, ops.UpdateService(ctx, "missing", cache), "service configuration not found: missing")
+
+	// A service can only be removed once it is stopped and nothing needs it
+	assert.ErrorContains(t, ops.RemoveService(ctx, "postgres"), "service postgres is needed by cache")
+	sm.running["cache"] = true
+	assert.ErrorContains(t, ops.RemoveService(ctx, "cache"), "service cache is running; stop it first")
+	sm.running["cache"] = false
+	require.NoError(t, ops.RemoveService(ctx, "cache"))
+	require.NoError(t, ops.RemoveService(ctx, "postgres"))
+	assert.Empty(t, savedServices(t))
+}
+
+func TestImportServices(t *testing.T) {
+	composePath := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	require.NoError(t, os.WriteFile(composePath, []byte(`services:
+  db:
+    image: postgres:16
+    ports:
+      - "5433:5432"
+    environment:
+      POSTGRES_PASSWORD: secret
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+  api:
+    build:
+      context: .
+    depends_on:
+      - db
+      - queue
+volumes:
+  pgdata:
+`), 0644))
+	ctx := context.Background()
+
+	t.Run("reference", func(t *testing.T) {
+		ops, _ := newServiceConfigTestOps(t)
+
+		imported, err := ops.ImportServices(ctx, ImportServicesRequest{ComposeFile: composePath})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"api", "db"}, imported)
+
+		saved := savedServices(t)
+		assert.Equal(t, config.ServiceConfig{ComposeFile: composePath, Service: "db"}, saved["db"])
+		// Dependencies outside services.toml are dropped
+		assert.Equal(t, []string{"db"}, saved["api"].DependsOn)
+
+		_, err = ops.ImportServices(ctx, ImportServicesRequest{ComposeFile: composePath, Services: []string{"db"}})
+		assert.ErrorContains(t, err, "service db already exists")
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		ops, _ := newServiceConfigTestOps(t)
+
+		imported, err := ops.ImportServices(ctx, ImportServicesRequest{ComposeFile: composePath, Services: []string{"db"}, Inline: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db"}, imported)
+
+		db := savedServices(t)["db"]
+		assert.Equal(t, "postgres:16", db.Image)
+		assert.Equal(t, "vibeman-service-db", db.Service)
+		assert.Empty(t, db.ComposeFile)
+		assert.Equal(t, []string{"5433:5432"}, db.Ports)
+		assert.Equal(t, "secret", db.Env["POSTGRES_PASSWORD"])
+		assert.Equal(t, []string{"pgdata:/var/lib/postgresql/data"}, db.Volumes)
+
+		// Services built from source need their compose file
+		_, err = ops.ImportServices(ctx, ImportServicesRequest{ComposeFile: composePath, Services: []string{"api"}, Inline: true})
+		assert.ErrorContains(t, err, "service api is built from source")
+		_, err = ops.ImportServices(ctx, ImportServicesRequest{ComposeFile: composePath, Services: []string{"missing"}})
+		assert.ErrorContains(t, err, "service missing not found")
+	})
+}
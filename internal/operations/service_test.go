@@ -0,0 +1,191 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"vibeman/internal/config"
+	"vibeman/internal/service"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLayers(t *testing.T) {
+	layers := [][]string{{"postgres", "redis"}, {"api", "cache-warmer"}, {"worker"}}
+	dependencies := map[string][]string{
+		"api":          {"postgres", "redis"},
+		"cache-warmer": {"redis"},
+		"worker":       {"api"},
+	}
+
+	var mu sync.Mutex
+	var ran []string
+	results := runLayers(layers, func(name string) []string { return dependencies[name] }, func(name string) *ServiceResult {
+		mu.Lock()
+		ran = append(ran, name)
+		mu.Unlock()
+		if name == "postgres" {
+			return &ServiceResult{Name: name, Err: fmt.Errorf("port in use")}
+		}
+		return &ServiceResult{Name: name}
+	})
+
+	// Everything downstream of the failure is skipped
+	assert.ElementsMatch(t, []string{"postgres", "redis", "cache-warmer"}, ran)
+	require.Len(t, results, 5)
+	byName := map[string]*ServiceResult{}
+	for _, result := range results {
+		byName[result.Name] = result
+	}
+	assert.NoError(t, byName["cache-warmer"].Err)
+	assert.EqualError(t, byName["api"].Err, "not attempted because postgres failed")
+	assert.EqualError(t, byName["worker"].Err, "not attempted because api failed")
+}
+
+// countedServices counts references the way the service manager does: a
+// start, a dependent's start and each holder take one, and a service stops
+// when the last is given back
+type countedServices struct {
+	*testutil.MockServiceManager
+	mu           sync.Mutex
+	dependencies map[string][]string
+	running      map[string]bool
+	refs         map[string]int
+	holders      map[string][]string
+}
+
+func (c *countedServices) StartService(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start(name)
+	return nil
+}
+
+func (c *countedServices) start(name string) {
+	if c.running[name] {
+		c.refs[name]++
+		return
+	}
+	for _, dep := range c.dependencies[name] {
+		c.start(dep)
+	}
+	c.running[name] = true
+	c.refs[name] = 1
+}
+
+func (c *countedServices) StopService(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running[name] {
+		return fmt.Errorf("service is not running: %s", name)
+	}
+	if c.refs[name]--; c.refs[name] > 0 {
+		return nil
+	}
+	c.running[name] = false
+	for _, dep := range c.dependencies[name] {
+		c.refs[dep] = max(c.refs[dep]-1, 0)
+	}
+	return nil
+}
+
+func (c *countedServices) AddReference(serviceName, holder string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holders[serviceName] = append(c.holders[serviceName], holder)
+	c.refs[serviceName]++
+	return nil
+}
+
+func (c *countedServices) RemoveReference(serviceName, holder string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := slices.Index(c.holders[serviceName], holder)
+	if i < 0 {
+		return fmt.Errorf("repository reference not found: %s", holder)
+	}
+	c.holders[serviceName] = slices.Delete(c.holders[serviceName], i, i+1)
+	c.refs[serviceName]--
+	return nil
+}
+
+func (c *countedServices) GetService(name string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := service.StatusStopped
+	if c.running[name] {
+		status = service.StatusRunning
+	}
+	return &service.ServiceInstance{Name: name, Status: status, RefCount: c.refs[name]}, nil
+}
+
+func newCountedServiceOps() (*ServiceOperations, *countedServices) {
+	cfg := &config.Manager{Services: &config.ServicesConfig{Services: map[string]config.ServiceConfig{
+		"postgres": {ComposeFile: "docker-compose.yml", Service: "postgres"},
+		"api":      {ComposeFile: "docker-compose.yml", Service: "api", DependsOn: []string{"postgres"}},
+	}}}
+	sm := &countedServices{
+		MockServiceManager: testutil.NewMockServiceManager(),
+		dependencies:       map[string][]string{"api": {"postgres"}},
+		running:            map[string]bool{},
+		refs:               map[string]int{},
+		holders:            map[string][]string{},
+	}
+	return NewServiceOperations(cfg, sm), sm
+}
+
+func assertServiceResults(t *testing.T, results []*ServiceResult, err error) {
+	t.Helper()
+
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.Err, result.Name)
+	}
+}
+
+func TestStopServices_StopsDependencies(t *testing.T) {
+	ops, sm := newCountedServiceOps()
+	ctx := context.Background()
+
+	// The api starts on a postgres that is already running
+	results, err := ops.StartServices(ctx, "postgres")
+	assertServiceResults(t, results, err)
+	results, err = ops.StartServices(ctx, "api")
+	assertServiceResults(t, results, err)
+
+	results, err = ops.StopServices(ctx, "postgres")
+	assertServiceResults(t, results, err)
+	assert.False(t, sm.running["api"])
+	assert.False(t, sm.running["postgres"])
+}
+
+func TestStartServices_HoldsOnlyNamedServices(t *testing.T) {
+	ops, sm := newCountedServiceOps()
+	ctx := context.Background()
+
+	results, err := ops.StartServices(ctx, "api")
+	assertServiceResults(t, results, err)
+	assert.Equal(t, []string{service.ManualHolder}, sm.holders["api"])
+	assert.Empty(t, sm.holders["postgres"])
+}
+
+func TestStopServices_ReportsServicesStillInUse(t *testing.T) {
+	ops, sm := newCountedServiceOps()
+	ctx := context.Background()
+
+	results, err := ops.StartServices(ctx, "postgres")
+	assertServiceResults(t, results, err)
+	require.NoError(t, sm.AddReference("postgres", "wt-1"))
+
+	results, err = ops.StopServices(ctx, "postgres")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Skipped) // The api was not running
+	assert.EqualError(t, results[1].Err, "service postgres is still in use")
+	assert.True(t, sm.running["postgres"])
+}
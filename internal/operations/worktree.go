@@ -3,22 +3,28 @@ package operations
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"vibeman/internal/audit"
 	"vibeman/internal/config"
 	"vibeman/internal/constants"
 	"vibeman/internal/container"
 	"vibeman/internal/db"
 	"vibeman/internal/errors"
 	"vibeman/internal/logger"
+	"vibeman/internal/service"
+	"vibeman/internal/telemetry"
 	"vibeman/internal/validation"
 	"vibeman/internal/xdg"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // WorktreeOperations provides shared backend functions for worktree management
@@ -55,6 +61,7 @@ type CreateWorktreeRequest struct {
 	ComposeFile     string   // Override default compose file
 	Services []string // Override default compose services
 	PostScripts     []string // Additional setup scripts to run after worktree creation
+	OwnerID         string   // User creating the worktree, if authenticated
 }
 
 // CreateWorktreeResponse contains the result of creating a worktree
@@ -64,7 +71,22 @@ type CreateWorktreeResponse struct {
 }
 
 // CreateWorktree creates a new worktree with all associated resources
-func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWorktreeRequest) (*CreateWorktreeResponse, error) {
+func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWorktreeRequest) (resp *CreateWorktreeResponse, err error) {
+	ctx, span := telemetry.Start(ctx, "operations.CreateWorktree", attribute.String("vibeman.repository_id", req.RepositoryID), attribute.String("vibeman.worktree", req.Name))
+	defer func() { telemetry.End(span, err) }()
+	defer func() {
+		target := audit.Target{Type: audit.TargetWorktree, Name: req.Name}
+		if resp != nil && resp.Worktree != nil {
+			target.ID = resp.Worktree.ID
+		}
+		audit.Record(ctx, audit.ActionWorktreeCreate, target, audit.Params{
+			"repository_id": req.RepositoryID,
+			"branch":        req.Branch,
+			"base_branch":   req.BaseBranch,
+			"auto_start":    req.AutoStart,
+		}, err)
+	}()
+
 	// Validate worktree name
 	if err := validateWorktreeName(req.Name); err != nil {
 		return nil, errors.Wrap(errors.ErrInvalidInput, "invalid worktree name", err)
@@ -158,7 +180,9 @@ func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWork
 	}).Info("Creating worktree")
 
 	// Create git worktree (this will create the directory)
+	stepStarted(ctx, StepGitWorktree, fmt.Sprintf("Creating git worktree on branch %s", branchName))
 	if err := wo.gitMgr.CreateWorktree(ctx, repo.Path, branchName, worktreeDir); err != nil {
+		stepFailed(ctx, StepGitWorktree, "Failed to create git worktree", err)
 		return nil, errors.Wrap(errors.ErrGitWorktreeFailed, "failed to create git worktree", err).WithContext("branch", branchName).WithContext("path", worktreeDir)
 	}
 
@@ -170,6 +194,7 @@ func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWork
 		Branch:       branchName,
 		Path:         worktreeDir,
 		Status:       db.StatusStopped,
+		OwnerID:      req.OwnerID,
 	}
 
 	worktreeRepo := db.NewWorktreeRepository(wo.db)
@@ -177,8 +202,10 @@ func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWork
 		// Clean up on failure
 		wo.gitMgr.RemoveWorktree(ctx, worktreeDir)
 		os.RemoveAll(worktreeDir)
+		stepFailed(ctx, StepGitWorktree, "Failed to record worktree", err)
 		return nil, errors.Wrap(errors.ErrDatabaseQuery, "failed to create worktree record", err)
 	}
+	stepCompleted(ctx, StepGitWorktree, "Created git worktree")
 
 	// Create logs directory
 	logsDir := xdg.LogsDir()
@@ -187,11 +214,7 @@ func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWork
 		logger.WithError(err).Warn("Failed to create logs directory")
 	}
 
-	// Create CLAUDE.md file
-	claudePath := filepath.Join(worktreeDir, "CLAUDE.md")
-	if err := createClaudeFile(claudePath, repo.Name, req.Name, worktreeDir); err != nil {
-		logger.WithError(err).Warn("Failed to create CLAUDE.md")
-	}
+	stepStarted(ctx, StepConfig, "Copying configuration")
 
 	// Copy vibeman.toml to worktree and update with overrides if specified
 	srcConfig := filepath.Join(repo.Path, "vibeman.toml")
@@ -219,36 +242,68 @@ func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWork
 		}
 	}
 
+	// Generate the agent's instructions, e.g. CLAUDE.md
+	if err := writeInstructions(ctx, repo, worktree, repoConfig, nil); err != nil {
+		logger.WithError(err).Warn("Failed to generate agent instructions")
+	}
+	stepCompleted(ctx, StepConfig, "Copied configuration")
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(errors.ErrCancelled, "worktree creation cancelled", err).WithContext("worktree_id", worktree.ID)
+	}
+
 	// Start required services before running setup
+	var serviceEnv []string
 	if !req.SkipSetup && len(repoConfig.Repository.Services) > 0 {
 		logger.Info("Starting required services")
-		for serviceName, serviceReq := range repoConfig.Repository.Services {
+		stepStarted(ctx, StepServices, "Starting required services")
+		for baseName, serviceReq := range repoConfig.Repository.Services {
 			if serviceReq.Required {
+				serviceName := config.VersionedName(baseName, serviceReq.Version)
 				logger.WithField("service", serviceName).Info("Starting required service")
 				if err := wo.serviceMgr.StartService(ctx, serviceName); err != nil {
 					logger.WithError(err).WithField("service", serviceName).Warn("Failed to start required service")
 				}
 			}
 		}
+		serviceEnv, _ = wo.serviceEnvironment(ctx, repo, worktree, repoConfig)
+		stepCompleted(ctx, StepServices, "Started required services")
+	} else {
+		stepSkipped(ctx, StepServices, "No required services")
 	}
 
 	// Run setup commands if not skipped
 	if !req.SkipSetup {
-		// Run repository setup command first
+		// Repository setup command runs first, followed by additional post-scripts
+		var setupCommands []string
 		if repoConfig.Repository.Setup.WorktreeInit != "" {
-			logger.Info("Running worktree setup command")
-			if err := runCommand(ctx, worktreeDir, repoConfig.Repository.Setup.WorktreeInit); err != nil {
-				logger.WithError(err).WithField("command", repoConfig.Repository.Setup.WorktreeInit).Warn("Setup command failed")
-			}
+			setupCommands = append(setupCommands, repoConfig.Repository.Setup.WorktreeInit)
 		}
-		
-		// Run additional post-scripts
-		for _, script := range req.PostScripts {
-			logger.WithField("script", script).Info("Running post-script")
-			if err := runCommand(ctx, worktreeDir, script); err != nil {
-				logger.WithError(err).WithField("script", script).Warn("Post-script failed")
+		setupCommands = append(setupCommands, req.PostScripts...)
+
+		for i, command := range setupCommands {
+			if err := ctx.Err(); err != nil {
+				return nil, errors.Wrap(errors.ErrCancelled, "worktree creation cancelled", err).WithContext("worktree_id", worktree.ID)
 			}
+
+			step := ProgressEvent{Step: StepSetup, Message: command, Status: StepRunning, Current: i + 1, Total: len(setupCommands)}
+			reportProgress(ctx, step)
+
+			logger.WithField("command", command).Info("Running setup command")
+			if err := runCommand(ctx, worktreeDir, command, serviceEnv); err != nil {
+				logger.WithError(err).WithField("command", command).Warn("Setup command failed")
+				step.Status = StepFailed
+				step.Error = err.Error()
+			} else {
+				step.Status = StepCompleted
+			}
+			reportProgress(ctx, step)
+		}
+		if len(setupCommands) == 0 {
+			stepSkipped(ctx, StepSetup, "No setup commands")
 		}
+	} else {
+		stepSkipped(ctx, StepSetup, "Setup skipped")
 	}
 
 	// Auto-start container if requested
@@ -267,13 +322,19 @@ func (wo *WorktreeOperations) CreateWorktree(ctx context.Context, req CreateWork
 }
 
 // RemoveWorktree removes a worktree and all associated resources
-func (wo *WorktreeOperations) RemoveWorktree(ctx context.Context, worktreeID string, force bool) error {
+func (wo *WorktreeOperations) RemoveWorktree(ctx context.Context, worktreeID string, force bool) (err error) {
+	ctx, span := telemetry.Start(ctx, "operations.RemoveWorktree", attribute.String("vibeman.worktree_id", worktreeID), attribute.Bool("vibeman.force", force))
+	defer func() { telemetry.End(span, err) }()
+	target := audit.Target{Type: audit.TargetWorktree, ID: worktreeID}
+	defer func() { audit.Record(ctx, audit.ActionWorktreeDelete, target, audit.Params{"force": force}, err) }()
+
 	// Get worktree from database
 	worktreeRepo := db.NewWorktreeRepository(wo.db)
 	worktree, err := worktreeRepo.Get(ctx, worktreeID)
 	if err != nil {
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to get worktree", err).WithContext("worktree_id", worktreeID)
 	}
+	target.Name = worktree.Name
 
 	// Get repository
 	repoRepo := db.NewRepositoryRepository(wo.db)
@@ -314,7 +375,7 @@ func (wo *WorktreeOperations) RemoveWorktree(ctx context.Context, worktreeID str
 	}).Info("Removing worktree")
 
 	// Remove AI container if it exists
-	aiContainerName := fmt.Sprintf("%s-%s-ai", repo.Name, worktree.Name)
+	aiContainerName := AIContainerName(repo.Name, worktree.Name)
 	if aiContainer, err := wo.containerMgr.GetByName(ctx, aiContainerName); err == nil {
 		logger.WithFields(logger.Fields{
 			"container_id":   aiContainer.ID,
@@ -330,6 +391,15 @@ func (wo *WorktreeOperations) RemoveWorktree(ctx context.Context, worktreeID str
 		}
 	}
 
+	// Stop using shared services, then drop the worktree's databases and
+	// other resources on them
+	wo.releaseServices(ctx, worktree)
+	if provisioner, ok := wo.serviceMgr.(ServiceProvisioner); ok {
+		if err := provisioner.DeprovisionWorktree(ctx, worktree.ID); err != nil {
+			logger.WithError(err).Warn("Failed to drop service resources")
+		}
+	}
+
 	// Remove git worktree
 	if err := wo.gitMgr.RemoveWorktree(ctx, worktree.Path); err != nil {
 		logger.WithError(err).Warn("Failed to remove git worktree")
@@ -347,6 +417,17 @@ func (wo *WorktreeOperations) RemoveWorktree(ctx context.Context, worktreeID str
 		logger.WithError(err).Warn("Failed to remove logs directory")
 	}
 
+	// Remove the recorded AI terminal sessions, which a worktree created
+	// later under the same name must not show
+	if err := os.RemoveAll(AIRecordingsDir(repo.Name, worktree.Name)); err != nil {
+		logger.WithError(err).Warn("Failed to remove AI terminal recordings")
+	}
+
+	// Revoke the token its agents called the MCP server with
+	if err := RevokeAIMCPAccess(ctx, wo.db, worktree.ID); err != nil {
+		logger.WithError(err).Warn("Failed to revoke MCP access")
+	}
+
 	// Remove database record
 	if err := worktreeRepo.Delete(ctx, worktreeID); err != nil {
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to delete worktree record", err).WithContext("worktree_id", worktreeID)
@@ -356,13 +437,19 @@ func (wo *WorktreeOperations) RemoveWorktree(ctx context.Context, worktreeID str
 }
 
 // StartWorktree starts a worktree's container and services
-func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID string) error {
+func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID string) (err error) {
+	ctx, span := telemetry.Start(ctx, "operations.StartWorktree", attribute.String("vibeman.worktree_id", worktreeID))
+	defer func() { telemetry.End(span, err) }()
+	target := audit.Target{Type: audit.TargetWorktree, ID: worktreeID}
+	defer func() { audit.Record(ctx, audit.ActionWorktreeStart, target, nil, err) }()
+
 	// Get worktree from database
 	worktreeRepo := db.NewWorktreeRepository(wo.db)
 	worktree, err := worktreeRepo.Get(ctx, worktreeID)
 	if err != nil {
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to get worktree", err).WithContext("worktree_id", worktreeID)
 	}
+	target.Name = worktree.Name
 
 	// Check if already running
 	if worktree.Status == db.StatusRunning {
@@ -374,38 +461,69 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to update worktree status", err).WithContext("worktree_id", worktreeID)
 	}
 
+	// Revert the status when the start fails or is cancelled, so the
+	// worktree can be started again
+	defer func() {
+		if err != nil {
+			if revertErr := worktreeRepo.UpdateStatus(context.WithoutCancel(ctx), worktreeID, db.StatusStopped); revertErr != nil {
+				logger.WithError(revertErr).WithField("worktree_id", worktreeID).Warn("Failed to revert worktree status")
+			}
+		}
+	}()
+
 	// Get repository for config
 	repoRepo := db.NewRepositoryRepository(wo.db)
 	repo, err := repoRepo.GetByID(ctx, worktree.RepositoryID)
 	if err != nil {
-		// Revert status on error
-		worktreeRepo.UpdateStatus(ctx, worktreeID, db.StatusStopped)
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to get repository", err).WithContext("repository_id", worktree.RepositoryID)
 	}
 
 	// Load repository configuration
 	repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
 	if err != nil {
-		// Revert status on error
-		worktreeRepo.UpdateStatus(ctx, worktreeID, db.StatusStopped)
 		return errors.Wrap(errors.ErrConfigParse, "failed to load repository config", err).WithContext("path", worktree.Path)
 	}
 
+	// Start required services and hold a reference to every service the
+	// worktree uses, so they keep running while it does
+	if len(repoConfig.Repository.Services) > 0 {
+		stepStarted(ctx, StepServices, "Starting required services")
+		wo.acquireServices(ctx, worktree, repoConfig)
+		stepCompleted(ctx, StepServices, "Started required services")
+	} else {
+		stepSkipped(ctx, StepServices, "No required services")
+	}
+
+	// Connection details for the services the worktree uses, including its
+	// own databases on shared services
+	_, serviceEnv := wo.serviceEnvironment(ctx, repo, worktree, repoConfig)
+
+	// Regenerate the agent's instructions when what they describe changed
+	if err := writeInstructions(ctx, repo, worktree, repoConfig, serviceEnv); err != nil {
+		logger.WithError(err).Warn("Failed to generate agent instructions")
+	}
+
 	// Start AI container if enabled
 	if repoConfig.Repository.AI.Enabled {
 		logger.WithFields(logger.Fields{
 			"worktree":   worktree.Name,
 			"repository": repo.Name,
 		}).Info("Starting AI container for worktree")
+		stepStarted(ctx, StepAI, "Starting AI container")
+
+		// The image, environment and volumes, like the security profile
+		// and the agent, come from the host, not the worktree agents can
+		// edit
+		checkoutAI := aiCheckoutConfig(repo)
 
 		// Determine AI image
-		aiImage := repoConfig.Repository.AI.Image
+		aiImage := checkoutAI.Image
 		if aiImage == "" {
 			aiImage = "vibeman/ai-assistant:latest"
 		}
 
 		// Build container name
-		aiContainerName := fmt.Sprintf("%s-%s-ai", repo.Name, worktree.Name)
+		aiContainerName := AIContainerName(repo.Name, worktree.Name)
 
 		// Prepare environment variables
 		envVars := []string{
@@ -417,14 +535,19 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 			fmt.Sprintf("VIBEMAN_ALL_LOGS_DIR=/all-logs"),
 		}
 
-		// Add service endpoints if any services are running
-		if len(repoConfig.Repository.Services) > 0 {
-			// TODO: Get actual service endpoints from service manager
-			envVars = append(envVars, "VIBEMAN_SERVICES={}")
-		}
+		// Add service endpoints
+		envVars = append(envVars, serviceEnv...)
+
+		security, sandboxErr := aiSecurity(repo)
+		agentName, agent := aiAgent(repo)
+
+		// Pass the agent's credentials through from the host, as far as the
+		// global configuration allows
+		envVars = append(envVars, fmt.Sprintf("VIBEMAN_AGENT=%s", agentName))
+		envVars = append(envVars, agent.Credentials(os.LookupEnv)...)
 
 		// Add custom environment variables
-		for k, v := range repoConfig.Repository.AI.Env {
+		for k, v := range checkoutAI.Env {
 			envVars = append(envVars, fmt.Sprintf("%s=%s", k, v))
 		}
 
@@ -437,9 +560,27 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 			fmt.Sprintf("%s:/all-logs:ro", logsDir),         // All logs directory (read-only)
 		}
 
-		// Add custom volumes
-		for host, container := range repoConfig.Repository.AI.Volumes {
-			volumes = append(volumes, fmt.Sprintf("%s:%s", host, container))
+		// Share the agent's configuration from the host, as far as the
+		// security profile allows
+		home, err := os.UserHomeDir()
+		if err == nil && sandboxErr == nil {
+			agent.Config = aiAgentConfig(agent.Config, security, home)
+			volumes = append(volumes, agent.Mounts(home)...)
+		}
+
+		// Let agents reach vibeman's MCP server
+		if mcpEnv, mcpMount, err := aiMCPAccess(ctx, wo.db, worktree.ID, mcpScope(repoConfig)); err != nil {
+			logger.WithError(err).Warn("Failed to give AI container access to the MCP server")
+		} else {
+			envVars = append(envVars, mcpEnv...)
+			volumes = append(volumes, mcpMount)
+		}
+
+		// Add custom volumes, as far as the security profile allows them
+		if sandboxErr == nil {
+			var customVolumes []string
+			customVolumes, sandboxErr = aiCustomVolumes(checkoutAI.Volumes, security, home)
+			volumes = append(volumes, customVolumes...)
 		}
 
 		// Create AI container configuration
@@ -455,15 +596,24 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 			Interactive: false, // Run detached, users can attach later
 		}
 
+		// Sandbox it as the security profile asks
+		if sandboxErr == nil {
+			sandboxErr = applyAISecurity(createConfig, security, repo.Path)
+		}
+
 		// Create the AI container
-		aiContainer, err := wo.containerMgr.CreateWithConfig(ctx, createConfig)
-		if err != nil {
+		if sandboxErr != nil {
+			logger.WithError(sandboxErr).Warn("AI container refused by its security profile")
+			stepFailed(ctx, StepAI, "AI container refused by its security profile", sandboxErr)
+		} else if aiContainer, err := wo.containerMgr.CreateWithConfig(ctx, createConfig); err != nil {
 			logger.WithError(err).Warn("Failed to create AI container")
+			stepFailed(ctx, StepAI, "Failed to create AI container", err)
 			// Don't fail the entire operation if AI container fails
 		} else {
 			// Start the AI container
 			if err := wo.containerMgr.Start(ctx, aiContainer.ID); err != nil {
 				logger.WithError(err).Warn("Failed to start AI container")
+				stepFailed(ctx, StepAI, "Failed to start AI container", err)
 				// Clean up the created container
 				wo.containerMgr.Remove(ctx, aiContainer.ID)
 			} else {
@@ -471,6 +621,7 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 					"container_id": aiContainer.ID,
 					"container_name": aiContainerName,
 				}).Info("AI container started successfully")
+				stepCompleted(ctx, StepAI, fmt.Sprintf("Started AI container %s", aiContainerName))
 				
 				// Start log aggregation for the AI container
 				if err := wo.logAggregator.StartLogAggregation(ctx, worktree.ID); err != nil {
@@ -483,10 +634,10 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 				}
 			}
 		}
+	} else {
+		stepSkipped(ctx, StepAI, "AI container disabled")
 	}
 
-	// TODO: Start associated services based on repository config
-
 	// Update status to running
 	if err := worktreeRepo.UpdateStatus(ctx, worktreeID, db.StatusRunning); err != nil {
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to update worktree status", err).WithContext("worktree_id", worktreeID)
@@ -496,13 +647,19 @@ func (wo *WorktreeOperations) StartWorktree(ctx context.Context, worktreeID stri
 }
 
 // StopWorktree stops a worktree's container and services
-func (wo *WorktreeOperations) StopWorktree(ctx context.Context, worktreeID string) error {
+func (wo *WorktreeOperations) StopWorktree(ctx context.Context, worktreeID string) (err error) {
+	ctx, span := telemetry.Start(ctx, "operations.StopWorktree", attribute.String("vibeman.worktree_id", worktreeID))
+	defer func() { telemetry.End(span, err) }()
+	target := audit.Target{Type: audit.TargetWorktree, ID: worktreeID}
+	defer func() { audit.Record(ctx, audit.ActionWorktreeStop, target, nil, err) }()
+
 	// Get worktree from database
 	worktreeRepo := db.NewWorktreeRepository(wo.db)
 	worktree, err := worktreeRepo.Get(ctx, worktreeID)
 	if err != nil {
 		return errors.Wrap(errors.ErrDatabaseQuery, "failed to get worktree", err).WithContext("worktree_id", worktreeID)
 	}
+	target.Name = worktree.Name
 
 	// Check if already stopped
 	if worktree.Status == db.StatusStopped {
@@ -524,7 +681,7 @@ func (wo *WorktreeOperations) StopWorktree(ctx context.Context, worktreeID strin
 		}).Warn("Failed to get repository")
 	} else {
 		// Stop AI container if it exists
-		aiContainerName := fmt.Sprintf("%s-%s-ai", repo.Name, worktree.Name)
+		aiContainerName := AIContainerName(repo.Name, worktree.Name)
 		if aiContainer, err := wo.containerMgr.GetByName(ctx, aiContainerName); err == nil {
 			logger.WithFields(logger.Fields{
 				"container_id":   aiContainer.ID,
@@ -540,7 +697,8 @@ func (wo *WorktreeOperations) StopWorktree(ctx context.Context, worktreeID strin
 		}
 	}
 
-	// TODO: Stop associated services based on repository config
+	// Release shared services; unused ones stop after their grace period
+	wo.releaseServices(ctx, worktree)
 
 	// Update status to stopped
 	if err := worktreeRepo.UpdateStatus(ctx, worktreeID, db.StatusStopped); err != nil {
@@ -550,6 +708,108 @@ func (wo *WorktreeOperations) StopWorktree(ctx context.Context, worktreeID strin
 	return nil
 }
 
+// acquireServices starts the worktree's required services and adds a
+// reference from the worktree to every service it uses. Failures are logged
+// rather than returned, like failures to start services on creation.
+func (wo *WorktreeOperations) acquireServices(ctx context.Context, worktree *db.Worktree, repoConfig *config.RepositoryConfig) {
+	for _, baseName := range slices.Sorted(maps.Keys(repoConfig.Repository.Services)) {
+		serviceReq := repoConfig.Repository.Services[baseName]
+		serviceName := config.VersionedName(baseName, serviceReq.Version)
+		if serviceReq.Required {
+			if err := wo.serviceMgr.StartService(ctx, serviceName); err != nil {
+				logger.WithError(err).WithField("service", serviceName).Warn("Failed to start required service")
+			}
+		}
+		if err := wo.serviceMgr.AddReference(serviceName, worktree.ID); err != nil {
+			logger.WithError(err).WithField("service", serviceName).Debug("Failed to add service reference")
+		}
+	}
+}
+
+// releaseServices removes the worktree's references to the services it
+// uses. Service managers that can drop them all at once do, so services
+// taken out of the configuration since the worktree started are released
+// too.
+func (wo *WorktreeOperations) releaseServices(ctx context.Context, worktree *db.Worktree) {
+	if releaser, ok := wo.serviceMgr.(ServiceReleaser); ok {
+		if err := releaser.ReleaseReferences(ctx, worktree.ID); err != nil {
+			logger.WithError(err).WithField("worktree", worktree.Name).Warn("Failed to release service references")
+		}
+		return
+	}
+
+	repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
+	if err != nil {
+		return
+	}
+	for _, serviceName := range repoConfig.Repository.Services.Names() {
+		// Not every service was referenced, e.g. when it failed to start
+		_ = wo.serviceMgr.RemoveReference(serviceName, worktree.ID)
+	}
+}
+
+// ServiceEnvFile is written to each worktree with the connection details of
+// the services it uses, e.g. for use as a compose env_file
+const ServiceEnvFile = service.EnvFile
+
+// serviceEnvironment gives the worktree its own resource on each service it
+// references that supports provisioning, resolves how to reach those
+// services and writes the result to ServiceEnvFile. It returns the
+// environment for processes on the host and for containers as KEY=value
+// pairs. Failures are logged rather than returned, like failures to start
+// services.
+func (wo *WorktreeOperations) serviceEnvironment(ctx context.Context, repo *db.Repository, worktree *db.Worktree, repoConfig *config.RepositoryConfig) (hostEnv, containerEnv []string) {
+	if len(repoConfig.Repository.Services) == 0 {
+		return nil, nil
+	}
+	services := repoConfig.Repository.Services.Names()
+
+	provisioned := map[string]string{}
+	if provisioner, ok := wo.serviceMgr.(ServiceProvisioner); ok {
+		for _, serviceName := range services {
+			vars, err := provisioner.ProvisionWorktree(ctx, serviceName, worktree.ID, repo.Name+"-"+worktree.Name)
+			if err != nil {
+				logger.WithError(err).WithField("service", serviceName).Warn("Failed to provision service resource")
+				continue
+			}
+			maps.Copy(provisioned, vars)
+		}
+	}
+
+	hostVars, containerVars := provisioned, provisioned
+	if discoverer, ok := wo.serviceMgr.(ServiceDiscoverer); ok {
+		env, err := discoverer.ServiceEnv(ctx, worktree.ID, services, false)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to resolve service connection details")
+		}
+		if env != nil {
+			hostVars = env
+		}
+		// Resolving again fails the same way, so only the first error is logged
+		if env, _ := discoverer.ServiceEnv(ctx, worktree.ID, services, true); env != nil {
+			containerVars = env
+		}
+	}
+	if len(containerVars) == 0 {
+		return nil, nil
+	}
+
+	if err := service.WriteEnvFile(ctx, worktree.Path, containerVars); err != nil {
+		logger.WithError(err).Warn("Failed to write service environment file")
+	}
+
+	return envPairs(hostVars), envPairs(containerVars)
+}
+
+// envPairs returns env as KEY=value pairs sorted by key
+func envPairs(env map[string]string) []string {
+	pairs := make([]string, 0, len(env))
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		pairs = append(pairs, key+"="+env[key])
+	}
+	return pairs
+}
+
 // Helper functions
 
 func validateWorktreeName(name string) error {
@@ -570,41 +830,6 @@ func generateID() string {
 	return uuid.New().String()
 }
 
-func createClaudeFile(path, repoName, worktreeName, worktreeDir string) error {
-	content := fmt.Sprintf(`# %s - %s Worktree
-
-This is a development worktree for the %s repository.
-
-## Worktree Information
-- Repository: %s
-- Worktree Name: %s
-- Directory: %s
-
-## Getting Started
-
-1. Your development environment is containerized
-2. All changes should be made within this worktree
-3. Use git commands as normal within this directory
-
-## Container Commands
-
-Start container:
-vibeman start
-
-Stop container:
-vibeman stop
-
-View logs:
-vibeman logs
-
-## Notes
-
-This file was automatically generated by Vibeman.
-`, repoName, worktreeName, repoName, repoName, worktreeName, worktreeDir)
-
-	return os.WriteFile(path, []byte(content), 0644)
-}
-
 func copyFile(src, dst string) error {
 	data, err := os.ReadFile(src)
 	if err != nil {
@@ -613,7 +838,7 @@ func copyFile(src, dst string) error {
 	return os.WriteFile(dst, data, 0644)
 }
 
-func runCommand(ctx context.Context, dir, command string) error {
+func runCommand(ctx context.Context, dir, command string, env []string) error {
 	logger.WithFields(logger.Fields{
 		"directory": dir,
 		"command":   command,
@@ -697,8 +922,13 @@ func runCommand(ctx context.Context, dir, command string) error {
 		cmd.Dir = dir
 	}
 	
+	// Expose the worktree's service connection details
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
 	// Capture output for logging
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		logger.WithError(err).WithFields(logger.Fields{
 			"command": command,
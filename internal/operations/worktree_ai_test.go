@@ -4,6 +4,8 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"vibeman/internal/config"
@@ -101,7 +103,7 @@ name = "test-repo"
 	assert.NoError(t, err)
 
 	// Verify mocks were called
-	mockContainerMgr.AssertExpectations(t)
+	mockContainerMgr.AssertExpectationsEventually(t)
 
 	// Verify worktree status was updated
 	updatedWorktree, err := worktreeRepo.Get(context.Background(), worktree.ID)
@@ -238,10 +240,25 @@ API_KEY = "test-key"
 [repository.ai.volumes]
 "/host/custom" = "/container/custom"
 `
-	configPath := filepath.Join(worktreePath, "vibeman.toml")
-	err = os.WriteFile(configPath, []byte(configContent), constants.FilePermissions)
+	require.NoError(t, os.MkdirAll(repo.Path, constants.DirPermissions))
+	err = os.WriteFile(filepath.Join(repo.Path, "vibeman.toml"), []byte(configContent), constants.FilePermissions)
 	require.NoError(t, err)
 
+	// The image, environment and volumes come from the repository's
+	// checkout, whatever the worktree's copy says
+	configPath := filepath.Join(worktreePath, "vibeman.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+[repository]
+name = "test-repo"
+
+[repository.ai]
+enabled = true
+image = "attacker/ai:latest"
+
+[repository.ai.volumes]
+"/etc" = "/host-etc"
+`), constants.FilePermissions))
+
 	// Create mocks
 	mockGitMgr := new(testutil.MockGitManager)
 	mockContainerMgr := new(testutil.MockContainerManager)
@@ -282,7 +299,7 @@ API_KEY = "test-key"
 			}
 		}
 
-		return hasCustomVar && hasAPIKey && hasCustomVolume
+		return hasCustomVar && hasAPIKey && hasCustomVolume && !slices.Contains(config.Volumes, "/etc:/host-etc")
 	})).Return(mockAIContainer, nil)
 
 	// Expect AI container start (use StartContainer which is what Start calls)
@@ -304,7 +321,7 @@ API_KEY = "test-key"
 	assert.NoError(t, err)
 
 	// Verify mocks were called
-	mockContainerMgr.AssertExpectations(t)
+	mockContainerMgr.AssertExpectationsEventually(t)
 }
 
 // TestStopWorktree_WithAIContainer tests that AI container is stopped when worktree stops
@@ -372,6 +389,8 @@ func TestStopWorktree_WithAIContainer(t *testing.T) {
 
 // TestRemoveWorktree_WithAIContainer tests that AI container is removed when worktree is removed
 func TestRemoveWorktree_WithAIContainer(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
 	// Setup test database
 	database := testutil.SetupTestDB(t)
 	defer database.Close()
@@ -430,6 +449,16 @@ func TestRemoveWorktree_WithAIContainer(t *testing.T) {
 	mockGitMgr.On("HasUnpushedCommits", mock.Anything, worktreePath).Return(false, nil)
 	mockGitMgr.On("RemoveWorktree", mock.Anything, worktreePath).Return(nil)
 
+	// A recorded AI terminal session
+	recordings := AIRecordingsDir(repo.Name, worktree.Name)
+	require.NoError(t, os.MkdirAll(recordings, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(recordings, "20260101-000000.000000-agent-web.cast"), []byte("{}\n"), 0600))
+
+	// An MCP token its agents call the MCP server with
+	_, mcpMount, err := aiMCPAccess(context.Background(), database, worktree.ID, db.MCPScope{})
+	require.NoError(t, err)
+	mcpConfig, _, _ := strings.Cut(mcpMount, ":")
+
 	// Create operations instance
 	ops := NewWorktreeOperations(database, mockGitMgr, mockContainerMgr, mockServiceMgr, cfg)
 
@@ -446,6 +475,14 @@ func TestRemoveWorktree_WithAIContainer(t *testing.T) {
 	// Verify worktree was removed from database
 	_, err = worktreeRepo.Get(context.Background(), worktree.ID)
 	assert.Error(t, err)
+
+	// Verify its recordings are gone
+	assert.NoDirExists(t, recordings)
+
+	// Verify its MCP access is revoked
+	_, err = db.NewMCPTokenRepository(database).GetScope(context.Background(), worktree.ID)
+	assert.ErrorContains(t, err, "not found")
+	assert.NoFileExists(t, mcpConfig)
 }
 
 // TestStartWorktree_AIContainerCreationFailure tests graceful handling of AI container creation failure
@@ -522,4 +559,91 @@ enabled = true
 	updatedWorktree, err := worktreeRepo.Get(context.Background(), worktree.ID)
 	require.NoError(t, err)
 	assert.Equal(t, db.StatusRunning, updatedWorktree.Status)
-}
\ No newline at end of file
+}
+
+// TestStartWorktree_HardenedAIContainer tests the hardened security profile,
+// agent and volumes of the repository's checkout reach the AI container's
+// config, whatever the worktree's copy says, that the agent's config stays in the
+// home directory and that volumes it refuses keep the container from being
+// created
+func TestStartWorktree_HardenedAIContainer(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".claude"), 0700))
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".gemini"), 0700))
+	t.Setenv("GEMINI_API_KEY", "gemini-secret")
+	database := testutil.SetupTestDB(t)
+	defer database.Close()
+
+	worktreePath := filepath.Join(t.TempDir(), "worktrees", "feature-test")
+	require.NoError(t, os.MkdirAll(worktreePath, constants.DirPermissions))
+
+	repo := &db.Repository{ID: "repo-123", Name: "test-repo", Path: t.TempDir()}
+	require.NoError(t, db.NewRepositoryRepository(database).Create(context.Background(), repo))
+	worktree := &db.Worktree{ID: "wt-123", RepositoryID: repo.ID, Name: "feature-test", Branch: "feature/test", Path: worktreePath, Status: db.StatusStopped}
+	worktreeRepo := db.NewWorktreeRepository(database)
+	require.NoError(t, worktreeRepo.Create(context.Background(), worktree))
+
+	writeConfig := func(volumes string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repo.Path, "vibeman.toml"), []byte(`
+[repository]
+name = "test-repo"
+
+[repository.ai.volumes]
+`+volumes+`
+
+[repository.ai.security]
+profile = "hardened"
+memory = "8g"
+`), constants.FilePermissions))
+	}
+
+	// Agents can edit the worktree's copy, so only whether AI is enabled is
+	// taken from it
+	require.NoError(t, os.WriteFile(filepath.Join(worktreePath, "vibeman.toml"), []byte(`
+[repository]
+name = "test-repo"
+
+[repository.ai]
+enabled = true
+agent = "gemini"
+
+[repository.ai.volumes]
+"/etc" = "/host-etc"
+
+[repository.ai.security]
+profile = "standard"
+memory = "64g"
+`), constants.FilePermissions))
+	writeConfig(`"/srv/datasets" = "/data"`)
+
+	mockContainerMgr := new(testutil.MockContainerManager)
+	mockContainerMgr.On("CreateWithConfig", mock.Anything, mock.MatchedBy(func(config *container.CreateConfig) bool {
+		return config.ReadOnly &&
+			slices.Contains(config.Tmpfs, "/tmp") &&
+			slices.Equal(config.CapDrop, []string{"ALL"}) &&
+			slices.Contains(config.SecurityOpts, "no-new-privileges") &&
+			config.Memory == "8g" && config.CPUs == "2" && config.PidsLimit == 1024 &&
+			slices.Contains(config.Volumes, "/srv/datasets:/data") &&
+			!slices.Contains(config.Volumes, "/etc:/host-etc") &&
+			slices.Contains(config.EnvVars, "VIBEMAN_AGENT=claude") &&
+			!slices.Contains(config.EnvVars, "GEMINI_API_KEY=gemini-secret") &&
+			!slices.ContainsFunc(config.Volumes, func(volume string) bool { return strings.HasPrefix(volume, home) })
+	})).Return(nil, assert.AnError).Once()
+
+	ops := NewWorktreeOperations(database, new(testutil.MockGitManager), mockContainerMgr, new(testutil.MockServiceManager), &config.Manager{})
+	require.NoError(t, ops.StartWorktree(context.Background(), worktree.ID))
+	mockContainerMgr.AssertExpectations(t)
+
+	// The docker socket is never mounted into an AI container
+	require.NoError(t, worktreeRepo.UpdateStatus(context.Background(), worktree.ID, db.StatusStopped))
+	writeConfig(`"/var/run/docker.sock" = "/var/run/docker.sock"`)
+	require.NoError(t, ops.StartWorktree(context.Background(), worktree.ID))
+	mockContainerMgr.AssertNumberOfCalls(t, "CreateWithConfig", 1)
+
+	updatedWorktree, err := worktreeRepo.Get(context.Background(), worktree.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusRunning, updatedWorktree.Status)
+}
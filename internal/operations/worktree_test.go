@@ -532,6 +532,10 @@ redis = { required = true }
 					t.Fatalf("Failed to create worktree: %v", err)
 				}
 
+				// Required services are started with the worktree
+				sm.On("StartService", mock.Anything, "postgres").Return(nil)
+				sm.On("StartService", mock.Anything, "redis").Return(nil)
+
 				// Mock AI container creation
 				cm.On("CreateWithConfig", mock.Anything, mock.AnythingOfType("*container.CreateConfig")).
 					Return(&container.Container{
@@ -679,8 +683,115 @@ name = "test-repo"`
 			}
 
 			// Verify mocks
-			mockCM.AssertExpectations(t)
+			mockCM.AssertExpectationsEventually(t)
 			mockSM.AssertExpectations(t)
 		})
 	}
-}
\ No newline at end of file
+}
+
+// provisioningServiceManager adds per-worktree provisioning to the mock
+// service manager
+type provisioningServiceManager struct {
+	*testutil.MockServiceManager
+	provisioned   []string
+	deprovisioned []string
+}
+
+func (p *provisioningServiceManager) ProvisionWorktree(ctx context.Context, serviceName, worktreeID, baseName string) (map[string]string, error) {
+	p.provisioned = append(p.provisioned, serviceName+":"+baseName)
+	if serviceName != "postgres" {
+		return nil, nil
+	}
+	return map[string]string{"POSTGRES_DB": "test_repo_feature_wt", "DATABASE_URL": "postgres://localhost/test_repo_feature_wt"}, nil
+}
+
+func (p *provisioningServiceManager) DeprovisionWorktree(ctx context.Context, worktreeID string) error {
+	p.deprovisioned = append(p.deprovisioned, worktreeID)
+	return nil
+}
+
+func TestWorktreeOperations_ProvisionedServices(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	worktreePath := filepath.Join(t.TempDir(), "feature")
+	require.NoError(t, os.MkdirAll(worktreePath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(worktreePath, "vibeman.toml"), []byte(`
+[repository]
+name = "test-repo"
+
+[repository.ai]
+enabled = false
+
+[repository.services]
+postgres = { required = true }
+redis = { required = false }
+`), 0644))
+
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "repo-1", Name: "test-repo", Path: "/tmp/test-repo"}))
+	require.NoError(t, db.NewWorktreeRepository(database).Create(ctx, &db.Worktree{
+		ID: "wt-1", RepositoryID: "repo-1", Name: "feature", Branch: "feature", Path: worktreePath, Status: db.StatusStopped,
+	}))
+
+	gitMgr := new(testutil.MockGitManager)
+	gitMgr.On("RemoveWorktree", mock.Anything, worktreePath).Return(nil)
+	containerMgr := new(testutil.MockContainerManager)
+	containerMgr.On("GetByName", mock.Anything, "test-repo-feature-ai").Return(nil, errors.New("not found"))
+	serviceMgr := &provisioningServiceManager{MockServiceManager: testutil.NewMockServiceManager()}
+	serviceMgr.On("StartService", mock.Anything, "postgres").Return(nil)
+
+	ops := operations.NewWorktreeOperations(database, gitMgr, containerMgr, serviceMgr, &config.Manager{})
+
+	// Starting the worktree provisions every referenced service and writes
+	// the connection details into the worktree
+	require.NoError(t, ops.StartWorktree(ctx, "wt-1"))
+	assert.Equal(t, []string{"postgres:test-repo-feature", "redis:test-repo-feature"}, serviceMgr.provisioned)
+
+	envFile, err := os.ReadFile(filepath.Join(worktreePath, operations.ServiceEnvFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(envFile), "DATABASE_URL=postgres://localhost/test_repo_feature_wt\nPOSTGRES_DB=test_repo_feature_wt\n")
+
+	// Removing it drops them
+	require.NoError(t, ops.RemoveWorktree(ctx, "wt-1", true))
+	assert.Equal(t, []string{"wt-1"}, serviceMgr.deprovisioned)
+}
+
+func TestWorktreeOperations_CancelledStart(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	worktreePath := filepath.Join(t.TempDir(), "feature")
+	require.NoError(t, os.MkdirAll(worktreePath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(worktreePath, "vibeman.toml"), []byte(`
+[repository]
+name = "test-repo"
+
+[repository.services]
+postgres = { required = true }
+
+[repository.ai]
+enabled = false
+`), 0644))
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "repo-1", Name: "test-repo", Path: worktreePath}))
+	worktreeRepo := db.NewWorktreeRepository(database)
+	require.NoError(t, worktreeRepo.Create(ctx, &db.Worktree{ID: "wt-1", RepositoryID: "repo-1", Name: "feature", Branch: "feature", Path: worktreePath, Status: db.StatusStopped}))
+
+	mockSM := testutil.NewMockServiceManager()
+	ops := operations.NewWorktreeOperations(database, new(testutil.MockGitManager), new(testutil.MockContainerManager), mockSM, &config.Manager{})
+
+	// The start is cancelled while its services start
+	startCtx, cancel := context.WithCancel(ctx)
+	mockSM.On("StartService", mock.Anything, "postgres").Run(func(mock.Arguments) { cancel() }).Return(context.Canceled).Once()
+	assert.Error(t, ops.StartWorktree(startCtx, "wt-1"))
+
+	worktree, err := worktreeRepo.Get(ctx, "wt-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusStopped, worktree.Status)
+
+	// so it can be started again
+	mockSM.On("StartService", mock.Anything, "postgres").Return(nil)
+	require.NoError(t, ops.StartWorktree(ctx, "wt-1"))
+	worktree, err = worktreeRepo.Get(ctx, "wt-1")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusRunning, worktree.Status)
+}
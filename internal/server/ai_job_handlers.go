@@ -0,0 +1,332 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"vibeman/internal/auth"
+	"vibeman/internal/db"
+	"vibeman/internal/operations"
+
+	"github.com/labstack/echo/v4"
+)
+
+// authorizeAIJob checks the caller may perform action on the worktree an AI
+// job runs in
+func (s *Server) authorizeAIJob(c echo.Context, access *auth.Access, job *db.AIJob, action auth.Action) error {
+	if access.Unrestricted() {
+		return nil
+	}
+
+	ctx := c.Request().Context()
+	worktree, err := db.NewWorktreeRepository(s.db).Get(ctx, job.WorktreeID)
+	if err != nil {
+		return fmt.Errorf("AI job not found")
+	}
+	repo, err := db.NewRepositoryRepository(s.db).GetByID(ctx, worktree.RepositoryID)
+	if err != nil {
+		return fmt.Errorf("AI job not found")
+	}
+	return access.CheckWorktree(repo, worktree, action)
+}
+
+// handleCreateAIJob godoc
+// @Summary Queue an AI job
+// @Description Queue a non-interactive agent run in the worktree's AI container. The job runs once fewer than the configured maximum are running.
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Worktree ID"
+// @Param request body CreateAIJobRequest true "Job prompt and agent"
+// @Success 202 {object} db.AIJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/worktrees/{id}/ai/jobs [post]
+func (s *Server) handleCreateAIJob(c echo.Context) error {
+	runner, err := s.getAIJobRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI job runner not available",
+		})
+	}
+
+	var req CreateAIJobRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Prompt) == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Prompt is required",
+		})
+	}
+
+	worktree, err := s.authorizeWorktree(c, s.db, c.Param("id"), auth.ActionAttach)
+	if err != nil {
+		return err
+	}
+
+	job, err := runner.Enqueue(c.Request().Context(), operations.AIJobRequest{
+		WorktreeID: worktree.ID,
+		Prompt:     req.Prompt,
+		Agent:      req.Agent,
+	})
+	if err != nil {
+		return handleError(c, err, "Failed to queue AI job")
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, "/api/ai/jobs/"+job.ID)
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// handleListWorktreeAIJobs godoc
+// @Summary List a worktree's AI jobs
+// @Description Get the AI jobs of a worktree, newest first, without transcripts and diffs
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Worktree ID"
+// @Param status query string false "Filter by status" Enums(queued, running, succeeded, failed, cancelled)
+// @Success 200 {object} AIJobsResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/worktrees/{id}/ai/jobs [get]
+func (s *Server) handleListWorktreeAIJobs(c echo.Context) error {
+	runner, err := s.getAIJobRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI job runner not available",
+		})
+	}
+
+	worktree, err := s.authorizeWorktree(c, s.db, c.Param("id"), auth.ActionView)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := runner.List(c.Request().Context(), worktree.ID, c.QueryParam("status"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list AI jobs",
+		})
+	}
+
+	return c.JSON(http.StatusOK, AIJobsResponse{
+		Jobs:  jobs,
+		Total: len(jobs),
+	})
+}
+
+// handleListAIJobs godoc
+// @Summary List AI jobs
+// @Description Get AI jobs across all worktrees, newest first, without transcripts and diffs
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Filter by status" Enums(queued, running, succeeded, failed, cancelled)
+// @Success 200 {object} AIJobsResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/ai/jobs [get]
+func (s *Server) handleListAIJobs(c echo.Context) error {
+	runner, err := s.getAIJobRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI job runner not available",
+		})
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	all, err := runner.List(c.Request().Context(), "", c.QueryParam("status"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list AI jobs",
+		})
+	}
+
+	// Only return jobs in worktrees the caller may see
+	jobs := make([]*db.AIJob, 0, len(all))
+	for _, job := range all {
+		if s.authorizeAIJob(c, access, job, auth.ActionView) == nil {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return c.JSON(http.StatusOK, AIJobsResponse{
+		Jobs:  jobs,
+		Total: len(jobs),
+	})
+}
+
+// handleGetAIJob godoc
+// @Summary Get AI job by ID
+// @Description Get the status, transcript and resulting diff of an AI job
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "AI job ID"
+// @Success 200 {object} db.AIJob
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/ai/jobs/{id} [get]
+func (s *Server) handleGetAIJob(c echo.Context) error {
+	runner, err := s.getAIJobRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI job runner not available",
+		})
+	}
+
+	job, err := runner.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return handleError(c, err, "Failed to get AI job")
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeAIJob(c, access, job, auth.ActionView); err != nil {
+		return handleError(c, err, "Failed to get AI job")
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// handleCancelAIJob godoc
+// @Summary Cancel an AI job
+// @Description Remove a queued AI job from the queue, or stop a running one
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "AI job ID"
+// @Success 202 {object} db.AIJob
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/ai/jobs/{id} [delete]
+func (s *Server) handleCancelAIJob(c echo.Context) error {
+	runner, err := s.getAIJobRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI job runner not available",
+		})
+	}
+
+	existing, err := runner.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return handleError(c, err, "Failed to cancel AI job")
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeAIJob(c, access, existing, auth.ActionStop); err != nil {
+		return handleError(c, err, "Failed to cancel AI job")
+	}
+
+	job, err := runner.Cancel(c.Request().Context(), existing.ID)
+	if err != nil {
+		return handleError(c, err, "Failed to cancel AI job")
+	}
+
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// handleAIFanout godoc
+// @Summary Run a prompt in several fresh worktrees
+// @Description Create count worktrees from the same base, run the prompt as a headless AI job in each and run the test command against each result. Runs asynchronously; the finished operation's result compares the attempts.
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Repository ID"
+// @Param request body AIFanoutRequest true "Fan-out request"
+// @Success 202 {object} OperationAcceptedResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/repositories/{id}/ai/fanout [post]
+func (s *Server) handleAIFanout(c echo.Context) error {
+	aiRunner, err := s.getAIJobRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI job runner not available",
+		})
+	}
+
+	runner, err := s.getAsyncRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Operation runner not available",
+		})
+	}
+
+	var req AIFanoutRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Prompt) == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Prompt is required",
+		})
+	}
+	if req.Count < 1 || req.Count > operations.MaxAIFanout {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("count must be between 1 and %d", operations.MaxAIFanout),
+		})
+	}
+
+	repo, err := s.authorizeRepository(c, s.db, c.Param("id"), auth.ActionCreate)
+	if err != nil {
+		return err
+	}
+
+	containerMgr, err := s.getContainerManagerInterface()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Container manager not available",
+		})
+	}
+	gitMgr, err := s.getGitManager()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Git manager not available",
+		})
+	}
+	serviceMgr, err := s.getServiceManager()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+	worktreeOps := operations.NewWorktreeOperations(s.db, gitMgr, &containerManagerAdapter{mgr: containerMgr}, serviceMgr, s.configMgr)
+
+	fanoutReq := operations.AIFanoutRequest{
+		RepositoryID: repo.ID,
+		Count:        req.Count,
+		Prompt:       req.Prompt,
+		Agent:        req.Agent,
+		BaseBranch:   req.BaseBranch,
+		NamePrefix:   req.NamePrefix,
+		TestCommand:  req.TestCommand,
+		OwnerID:      GetUserID(c.Request().Context()),
+	}
+
+	op, err := runner.Start(c.Request().Context(), operations.OperationAIFanout, repo.ID, func(ctx context.Context) (interface{}, error) {
+		return aiRunner.Fanout(ctx, worktreeOps, fanoutReq)
+	})
+	if err != nil {
+		return handleError(c, err, "Failed to start AI fan-out")
+	}
+
+	return acceptOperation(c, op)
+}
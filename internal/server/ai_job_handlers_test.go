@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vibeman/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIJobHandlers(t *testing.T) {
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	alice := loginAs(t, s, "alice")
+	bob := loginAs(t, s, "bob")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vibeman.toml"), []byte(`
+[repository]
+name = "app"
+
+[repository.ai]
+enabled = false
+`), 0644))
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(s.db).Create(ctx, &db.Repository{ID: "app", Path: dir, Name: "app", OwnerID: userID(t, s, "alice")}))
+	require.NoError(t, db.NewWorktreeRepository(s.db).Create(ctx, &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: dir, Status: db.StatusRunning}))
+	jobs := db.NewAIJobRepository(s.db)
+	finished := &db.AIJob{ID: "job-1", WorktreeID: "wt", Agent: "claude", Prompt: "fix it", Status: db.AIJobQueued}
+	require.NoError(t, jobs.Create(ctx, finished))
+	exitCode := 0
+	finished.Status, finished.ExitCode, finished.Transcript, finished.Diff = db.AIJobSucceeded, &exitCode, "done\n", "+fixed\n"
+	require.NoError(t, jobs.Update(ctx, finished))
+
+	// Jobs are only visible to callers who may see their worktree
+	rec := doRequest(s, http.MethodGet, "/api/ai/jobs", "", bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list AIJobsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, 0, list.Total)
+
+	rec = doRequest(s, http.MethodGet, "/api/ai/jobs/job-1", "", bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = doRequest(s, http.MethodPost, "/api/worktrees/wt/ai/jobs", `{"prompt":"hi"}`, bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(s, http.MethodGet, "/api/worktrees/wt/ai/jobs", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, 1, list.Total)
+	assert.Empty(t, list.Jobs[0].Transcript)
+
+	rec = doRequest(s, http.MethodGet, "/api/ai/jobs/job-1", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var job db.AIJob
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, "done\n", job.Transcript)
+	assert.Equal(t, "+fixed\n", job.Diff)
+
+	rec = doRequest(s, http.MethodDelete, "/api/ai/jobs/job-1", "", alice)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/ai/jobs/missing", "", alice)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(s, http.MethodPost, "/api/worktrees/wt/ai/jobs", `{"prompt":" "}`, alice)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(s, http.MethodPost, "/api/worktrees/wt/ai/jobs", `{"prompt":"hi"}`, alice)
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"vibeman/internal/auth"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+	"vibeman/internal/operations"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleListAIRecordings godoc
+// @Summary List recorded AI terminal sessions
+// @Description Get the asciicast recordings of the AI terminal sessions of the worktrees the caller may see, newest first. Every connection to a session is recorded separately.
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param worktree query string false "Only list the recordings of the named worktree"
+// @Success 200 {object} AIRecordingsResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/ai/recordings [get]
+func (s *Server) handleListAIRecordings(c echo.Context) error {
+	database, err := s.getDB()
+	if err != nil {
+		return err
+	}
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	worktrees, err := db.NewWorktreeRepository(database).List(ctx, "", "")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list worktrees",
+		})
+	}
+
+	filter := c.QueryParam("worktree")
+	repos := db.NewRepositoryRepository(database)
+	recordings := []operations.AIRecording{}
+	for i := range worktrees {
+		worktree := &worktrees[i]
+		if filter != "" && worktree.Name != filter {
+			continue
+		}
+		repo, err := repos.GetByID(ctx, worktree.RepositoryID)
+		if err != nil || access.CheckWorktree(repo, worktree, auth.ActionView) != nil {
+			continue
+		}
+
+		found, err := operations.ListAIRecordings(repo.Name, worktree.Name)
+		if err != nil {
+			logger.WithError(err).WithField("worktree", worktree.Name).Warn("Failed to list AI terminal recordings")
+			continue
+		}
+		recordings = append(recordings, found...)
+	}
+
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt.After(recordings[j].StartedAt)
+	})
+	return c.JSON(http.StatusOK, AIRecordingsResponse{
+		Recordings: recordings,
+		Total:      len(recordings),
+	})
+}
+
+// handleGetAIRecording godoc
+// @Summary Download a recorded AI terminal session
+// @Description Download an asciicast v2 recording of an AI terminal session. Recordings hold everything typed into the terminal, so downloading one needs permission to attach to the worktree.
+// @Tags ai
+// @Produce application/x-asciicast
+// @Security Bearer
+// @Param worktree path string true "Worktree ID or name"
+// @Param repository query string false "Repository ID or name, needed when several repositories have a worktree of that name"
+// @Param name path string true "Recording file name"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/ai/recordings/{worktree}/{name} [get]
+func (s *Server) handleGetAIRecording(c echo.Context) error {
+	worktree, repo, err := s.findAISessionWorktree(c, c.Param("worktree"), auth.ActionAttach)
+	if err != nil {
+		return err
+	}
+
+	recording, err := operations.GetAIRecording(repo.Name, worktree.Name, c.Param("name"))
+	if err != nil {
+		return handleError(c, err, "Failed to find recording")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-asciicast")
+	return c.Attachment(recording.Path, recording.Name)
+}
+
+// aiRecordingSet keeps one recording running per persistent AI terminal
+// session, from the first attach, or the first sweep that finds the session,
+// until the session ends
+type aiRecordingSet struct {
+	mu         sync.Mutex
+	recordings map[string]*aiRecordingEntry // Keyed by aiSessionKey
+}
+
+// aiRecordingEntry is the recording of a session, once ready is closed
+type aiRecordingEntry struct {
+	ready     chan struct{}
+	recording *operations.AISessionRecording
+}
+
+func newAIRecordingSet() *aiRecordingSet {
+	return &aiRecordingSet{recordings: make(map[string]*aiRecordingEntry)}
+}
+
+// record returns the recording of a session, calling start to start one if
+// there is none and waiting for it if another call is starting one. It
+// returns nil when the session cannot be recorded.
+func (r *aiRecordingSet) record(key string, start func() (*operations.AISessionRecording, error)) *operations.AISessionRecording {
+	r.mu.Lock()
+	if entry, ok := r.recordings[key]; ok {
+		r.mu.Unlock()
+		<-entry.ready
+		return entry.recording
+	}
+	entry := &aiRecordingEntry{ready: make(chan struct{})}
+	r.recordings[key] = entry
+	r.mu.Unlock()
+	defer close(entry.ready)
+
+	recording, err := start()
+	if err != nil || recording == nil {
+		if err != nil {
+			logger.WithError(err).WithField("session", key).Warn("Failed to record AI terminal session")
+		}
+		r.forget(key, entry)
+		return nil
+	}
+	logger.WithField("path", recording.Path).Debug("Recording AI terminal session")
+	entry.recording = recording
+
+	go func() {
+		<-recording.Done()
+		r.forget(key, entry)
+	}()
+	return recording
+}
+
+// forget drops the entry of a session, unless a newer one replaced it
+func (r *aiRecordingSet) forget(key string, entry *aiRecordingEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recordings[key] == entry {
+		delete(r.recordings, key)
+	}
+}
+
+// stopAll stops every recording, so the sessions can be recorded again by
+// whatever runs next
+func (r *aiRecordingSet) stopAll() {
+	r.mu.Lock()
+	entries := make([]*aiRecordingEntry, 0, len(r.recordings))
+	for _, entry := range r.recordings {
+		entries = append(entries, entry)
+	}
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-entry.ready
+			entry.recording.Stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// runAIRecordings records the AI terminal sessions of running worktrees
+// every interval until ctx is done, so sessions nobody attached to since the
+// server started are recorded too
+func (s *Server) runAIRecordings(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.recordAISessions(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordAISessions starts recording the AI terminal sessions of running
+// worktrees that are not being recorded
+func (s *Server) recordAISessions(ctx context.Context) {
+	worktrees, err := db.NewWorktreeRepository(s.db).List(ctx, "", string(db.StatusRunning))
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list worktrees to record AI terminal sessions of")
+		return
+	}
+
+	repos := db.NewRepositoryRepository(s.db)
+	for i := range worktrees {
+		worktree := &worktrees[i]
+		repo, err := repos.GetByID(ctx, worktree.RepositoryID)
+		if err != nil {
+			continue
+		}
+		container := operations.AIContainerName(repo.Name, worktree.Name)
+		sessions, err := operations.ListAISessions(ctx, container)
+		if err != nil {
+			// Worktrees without an AI container have no sessions
+			continue
+		}
+		for _, session := range sessions {
+			s.aiRecordings.record(aiSessionKey(container, session.Name), func() (*operations.AISessionRecording, error) {
+				return operations.RecordAISession(context.Background(), repo.Name, worktree.Name, container, session.Name, operations.AIRecordingSourceDetached)
+			})
+		}
+	}
+}
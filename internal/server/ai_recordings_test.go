@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+	"vibeman/internal/operations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIRecordingHandlers(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	alice := loginAs(t, s, "alice")
+	bob := loginAs(t, s, "bob")
+
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(s.db).Create(ctx, &db.Repository{ID: "app", Path: t.TempDir(), Name: "app", OwnerID: userID(t, s, "alice")}))
+	require.NoError(t, db.NewWorktreeRepository(s.db).Create(ctx, &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: t.TempDir(), Status: db.StatusStopped}))
+
+	recorder, _, err := operations.NewAIRecording("app", "feature", "agent", operations.AIRecordingSourceWeb, 80, 24, []string{"claude"})
+	require.NoError(t, err)
+	recorder.Output([]byte("hello"))
+	require.NoError(t, recorder.Close())
+
+	// Recordings outlive their containers, so stopped worktrees list theirs
+	rec := doRequest(s, http.MethodGet, "/api/ai/recordings", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list AIRecordingsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, 1, list.Total)
+	recording := list.Recordings[0]
+	assert.Equal(t, "feature", recording.Worktree)
+	assert.Equal(t, "agent", recording.Session)
+	assert.Equal(t, operations.AIRecordingSourceWeb, recording.Source)
+
+	rec = doRequest(s, http.MethodGet, "/api/ai/recordings?worktree=other", "", alice)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, 0, list.Total)
+
+	// Recordings of worktrees the caller may not see are hidden
+	rec = doRequest(s, http.MethodGet, "/api/ai/recordings", "", bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, 0, list.Total)
+	rec = doRequest(s, http.MethodGet, "/api/ai/recordings/feature/"+recording.Name, "", bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(s, http.MethodGet, "/api/ai/recordings/feature/"+recording.Name, "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-asciicast", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), recording.Name)
+	assert.Contains(t, rec.Body.String(), `"o","hello"`)
+
+	rec = doRequest(s, http.MethodGet, "/api/ai/recordings/feature/notes.txt", "", alice)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/ai/recordings/feature/20260101-000000.000000-agent-web.cast", "", alice)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAIRecordingSet_StartsOnce(t *testing.T) {
+	set := newAIRecordingSet()
+
+	// Connections and the sweep that ask while a recording is starting wait
+	// for it rather than starting another
+	var starts atomic.Int32
+	release := make(chan struct{})
+	start := func() (*operations.AISessionRecording, error) {
+		starts.Add(1)
+		<-release
+		return nil, nil
+	}
+
+	first := make(chan struct{})
+	go func() {
+		defer close(first)
+		assert.Nil(t, set.record("app-feature-ai/agent", start))
+	}()
+	require.Eventually(t, func() bool { return starts.Load() == 1 }, time.Second, time.Millisecond)
+	second := make(chan struct{})
+	go func() {
+		defer close(second)
+		assert.Nil(t, set.record("app-feature-ai/agent", start))
+	}()
+	select {
+	case <-second:
+		t.Fatal("record returned while the recording was starting")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-first
+	<-second
+	assert.Equal(t, int32(1), starts.Load())
+
+	// Sessions that could not be recorded are tried again
+	set.record("app-feature-ai/agent", start)
+	assert.Equal(t, int32(2), starts.Load())
+	set.stopAll()
+}
@@ -0,0 +1,455 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+	"vibeman/internal/operations"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultAIShareTTL and maxAIShareTTL bound how long share links work
+	defaultAIShareTTL = 24 * time.Hour
+	maxAIShareTTL     = 7 * 24 * time.Hour
+)
+
+// aiSessionHub tracks the WebSocket connections to persistent AI terminal
+// sessions and their share links. The sessions themselves live in tmux in
+// the AI containers, so they outlive connections and server restarts; share
+// links do not survive a restart.
+type aiSessionHub struct {
+	mu       sync.Mutex
+	sessions map[string]*aiSessionClients // Keyed by aiSessionKey
+	shares   map[string]*aiSessionShare   // Keyed by token
+}
+
+// aiSessionClients are the connections to one session. At most one of them
+// may type; the others watch.
+type aiSessionClients struct {
+	writer  string
+	viewers map[string]bool
+}
+
+// aiSessionShare is a read-only share link to a session
+type aiSessionShare struct {
+	token     string
+	container string
+	worktree  string
+	session   string
+	expiresAt time.Time
+}
+
+func newAISessionHub() *aiSessionHub {
+	return &aiSessionHub{
+		sessions: make(map[string]*aiSessionClients),
+		shares:   make(map[string]*aiSessionShare),
+	}
+}
+
+// aiSessionKey identifies a session across AI containers
+func aiSessionKey(container, session string) string {
+	return container + "/" + session
+}
+
+// join registers a connection to a session. The connection becomes the
+// session's writer if it asks to and no other connection is.
+func (h *aiSessionHub) join(key string, wantWrite bool) (id string, writer bool, err error) {
+	id, err = randomToken(9)
+	if err != nil {
+		return "", false, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients := h.sessions[key]
+	if clients == nil {
+		clients = &aiSessionClients{viewers: make(map[string]bool)}
+		h.sessions[key] = clients
+	}
+
+	clients.viewers[id] = true
+	if wantWrite && clients.writer == "" {
+		clients.writer = id
+		writer = true
+	}
+	return id, writer, nil
+}
+
+// leave unregisters a connection, freeing the writer slot if it held it
+func (h *aiSessionHub) leave(key, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients := h.sessions[key]
+	if clients == nil {
+		return
+	}
+	delete(clients.viewers, id)
+	if clients.writer == id {
+		clients.writer = ""
+	}
+	if len(clients.viewers) == 0 {
+		delete(h.sessions, key)
+	}
+}
+
+// stats reports the connections to and active share links of a session
+func (h *aiSessionHub) stats(key string) (viewers int, hasWriter bool, shares int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if clients := h.sessions[key]; clients != nil {
+		viewers, hasWriter = len(clients.viewers), clients.writer != ""
+	}
+	now := time.Now()
+	for _, share := range h.shares {
+		if aiSessionKey(share.container, share.session) == key && now.Before(share.expiresAt) {
+			shares++
+		}
+	}
+	return viewers, hasWriter, shares
+}
+
+// share creates a read-only share link to a session
+func (h *aiSessionHub) share(container, worktree, session string, ttl time.Duration) (*aiSessionShare, error) {
+	token, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	share := &aiSessionShare{
+		token:     token,
+		container: container,
+		worktree:  worktree,
+		session:   session,
+		expiresAt: time.Now().Add(ttl),
+	}
+	h.shares[share.token] = share
+	return share, nil
+}
+
+// lookupShare returns the share link with token unless it has expired
+func (h *aiSessionHub) lookupShare(token string) (*aiSessionShare, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	share, ok := h.shares[token]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(share.expiresAt) {
+		delete(h.shares, token)
+		return nil, false
+	}
+	return share, true
+}
+
+// revokeShares removes the share links to a session, returning how many
+// there were
+func (h *aiSessionHub) revokeShares(key string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	revoked := 0
+	for token, share := range h.shares {
+		if aiSessionKey(share.container, share.session) == key {
+			delete(h.shares, token)
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// randomToken returns an unguessable URL-safe token of n random bytes
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// findAISessionWorktree finds the worktree the caller may perform action on,
+// with its repository. The worktree is given by ID, or by name together with
+// the repository query parameter (an ID or name) when several repositories
+// have worktrees of that name.
+func (s *Server) findAISessionWorktree(c echo.Context, worktreeRef string, action auth.Action) (*db.Worktree, *db.Repository, error) {
+	database, err := s.getDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := c.Request().Context()
+	worktrees, err := db.NewWorktreeRepository(database).List(ctx, "", "")
+	if err != nil {
+		return nil, nil, handleError(c, err, "Failed to list worktrees")
+	}
+	repoRef := c.QueryParam("repository")
+
+	type candidate struct {
+		worktree *db.Worktree
+		repo     *db.Repository
+	}
+	var byName []candidate
+	var denied error
+	repos := db.NewRepositoryRepository(database)
+	for i := range worktrees {
+		if worktrees[i].ID != worktreeRef && worktrees[i].Name != worktreeRef {
+			continue
+		}
+		repo, err := repos.GetByID(ctx, worktrees[i].RepositoryID)
+		if err != nil {
+			continue
+		}
+		if repoRef != "" && repo.ID != repoRef && repo.Name != repoRef {
+			continue
+		}
+		if err := access.CheckWorktree(repo, &worktrees[i], action); err != nil {
+			denied = err
+			continue
+		}
+		if worktrees[i].ID == worktreeRef {
+			return &worktrees[i], repo, nil
+		}
+		byName = append(byName, candidate{&worktrees[i], repo})
+	}
+
+	switch {
+	case len(byName) == 1:
+		return byName[0].worktree, byName[0].repo, nil
+	case len(byName) > 1:
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("several repositories have a worktree named %s, choose one with the repository parameter", worktreeRef))
+	case denied != nil:
+		return nil, nil, handleError(c, denied, "Failed to find worktree")
+	}
+	return nil, nil, echo.NewHTTPError(http.StatusNotFound, "worktree not found")
+}
+
+// handleListAISessions godoc
+// @Summary List AI terminal sessions
+// @Description Get the persistent terminal sessions running in the AI containers of the worktrees the caller may see, with their WebSocket connections, share links and what the agents in them are doing
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param worktree query string false "Only list the sessions of the named worktree"
+// @Success 200 {object} AISessionsResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/ai/sessions [get]
+func (s *Server) handleListAISessions(c echo.Context) error {
+	database, err := s.getDB()
+	if err != nil {
+		return err
+	}
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	worktrees, err := db.NewWorktreeRepository(database).List(ctx, "", string(db.StatusRunning))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list worktrees",
+		})
+	}
+
+	filter := c.QueryParam("worktree")
+	repos := db.NewRepositoryRepository(database)
+	sessions := []AISessionInfo{}
+	for i := range worktrees {
+		worktree := &worktrees[i]
+		if filter != "" && worktree.Name != filter {
+			continue
+		}
+		repo, err := repos.GetByID(ctx, worktree.RepositoryID)
+		if err != nil || access.CheckWorktree(repo, worktree, auth.ActionView) != nil {
+			continue
+		}
+
+		container := operations.AIContainerName(repo.Name, worktree.Name)
+		found, err := operations.ListAISessions(ctx, container)
+		if err != nil {
+			// Worktrees without an AI container have no sessions
+			logger.WithError(err).WithField("container", container).Debug("Failed to list AI terminal sessions")
+			continue
+		}
+		for _, session := range found {
+			session.Worktree, session.WorktreeID = worktree.Name, worktree.ID
+			info := AISessionInfo{AISession: session}
+			info.Viewers, info.HasWriter, info.Shares = s.aiSessions.stats(aiSessionKey(container, session.Name))
+			if s.agentWatcher != nil {
+				info.Agent = s.agentWatcher.Activity(container, session.Name)
+			}
+			sessions = append(sessions, info)
+		}
+	}
+
+	return c.JSON(http.StatusOK, AISessionsResponse{
+		Sessions: sessions,
+		Total:    len(sessions),
+	})
+}
+
+// handleShareAISession godoc
+// @Summary Share an AI terminal session
+// @Description Create a link anyone can use to watch a session without logging in. The link cannot type in the session and stops working when it expires, is revoked or the server restarts.
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param worktree path string true "Worktree ID or name"
+// @Param repository query string false "Repository ID or name, needed when several repositories have a worktree of that name"
+// @Param session path string true "Session name"
+// @Param request body ShareAISessionRequest false "Link lifetime"
+// @Success 201 {object} AISessionShareResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/ai/sessions/{worktree}/{session}/share [post]
+func (s *Server) handleShareAISession(c echo.Context) error {
+	session := c.Param("session")
+	if err := operations.ValidateAISessionName(session); err != nil {
+		return handleError(c, err, "Invalid session name")
+	}
+
+	var req ShareAISessionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+	ttl := defaultAIShareTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 || parsed > maxAIShareTTL {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: fmt.Sprintf("ttl must be a duration up to %s", maxAIShareTTL),
+			})
+		}
+		ttl = parsed
+	}
+
+	// Watching is what attaching grants, so sharing needs the same permission
+	worktree, repo, err := s.findAISessionWorktree(c, c.Param("worktree"), auth.ActionAttach)
+	if err != nil {
+		return err
+	}
+
+	container := operations.AIContainerName(repo.Name, worktree.Name)
+	share, err := s.aiSessions.share(container, worktree.Name, session, ttl)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to create share link",
+		})
+	}
+	audit.Record(c.Request().Context(), audit.ActionAISessionShare,
+		audit.Target{Type: audit.TargetWorktree, ID: worktree.ID, Name: worktree.Name},
+		audit.Params{"session": session, "expires_at": share.expiresAt}, nil)
+
+	path := "/api/ai/shared/" + share.token
+	scheme := "ws"
+	if c.Scheme() == "https" {
+		scheme = "wss"
+	}
+	return c.JSON(http.StatusCreated, AISessionShareResponse{
+		Token:     share.token,
+		Path:      path,
+		URL:       fmt.Sprintf("%s://%s%s", scheme, c.Request().Host, path),
+		ExpiresAt: share.expiresAt,
+	})
+}
+
+// handleUnshareAISession godoc
+// @Summary Revoke an AI terminal session's share links
+// @Description Stop all read-only share links to a session from working. Viewers already connected stay connected.
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param worktree path string true "Worktree ID or name"
+// @Param repository query string false "Repository ID or name, needed when several repositories have a worktree of that name"
+// @Param session path string true "Session name"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/ai/sessions/{worktree}/{session}/share [delete]
+func (s *Server) handleUnshareAISession(c echo.Context) error {
+	session := c.Param("session")
+	if err := operations.ValidateAISessionName(session); err != nil {
+		return handleError(c, err, "Invalid session name")
+	}
+
+	worktree, repo, err := s.findAISessionWorktree(c, c.Param("worktree"), auth.ActionAttach)
+	if err != nil {
+		return err
+	}
+
+	revoked := s.aiSessions.revokeShares(aiSessionKey(operations.AIContainerName(repo.Name, worktree.Name), session))
+	audit.Record(c.Request().Context(), audit.ActionAISessionUnshare,
+		audit.Target{Type: audit.TargetWorktree, ID: worktree.ID, Name: worktree.Name},
+		audit.Params{"session": session, "revoked": revoked}, nil)
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: fmt.Sprintf("Revoked %d share links", revoked),
+	})
+}
+
+// handleKillAISession godoc
+// @Summary End an AI terminal session
+// @Description End a persistent terminal session and whatever runs in it, disconnecting its viewers and revoking its share links
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param worktree path string true "Worktree ID or name"
+// @Param repository query string false "Repository ID or name, needed when several repositories have a worktree of that name"
+// @Param session path string true "Session name"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/ai/sessions/{worktree}/{session} [delete]
+func (s *Server) handleKillAISession(c echo.Context) error {
+	session := c.Param("session")
+	if err := operations.ValidateAISessionName(session); err != nil {
+		return handleError(c, err, "Invalid session name")
+	}
+
+	worktree, repo, err := s.findAISessionWorktree(c, c.Param("worktree"), auth.ActionStop)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	container := operations.AIContainerName(repo.Name, worktree.Name)
+	err = operations.KillAISession(ctx, container, session)
+	audit.Record(ctx, audit.ActionAISessionKill,
+		audit.Target{Type: audit.TargetWorktree, ID: worktree.ID, Name: worktree.Name},
+		audit.Params{"session": session}, err)
+	if err != nil {
+		return handleError(c, err, "Failed to end terminal session")
+	}
+	s.aiSessions.revokeShares(aiSessionKey(container, session))
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: fmt.Sprintf("Terminal session %s ended", session),
+	})
+}
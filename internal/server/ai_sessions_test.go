@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAISessionHub_OneWriter(t *testing.T) {
+	hub := newAISessionHub()
+	key := aiSessionKey("app-feature-ai", "agent")
+
+	first, writer, err := hub.join(key, true)
+	require.NoError(t, err)
+	assert.True(t, writer)
+	second, writer, err := hub.join(key, true)
+	require.NoError(t, err)
+	assert.False(t, writer, "a second connection watches while the first may type")
+	_, writer, err = hub.join(key, false)
+	require.NoError(t, err)
+	assert.False(t, writer)
+
+	viewers, hasWriter, _ := hub.stats(key)
+	assert.Equal(t, 3, viewers)
+	assert.True(t, hasWriter)
+
+	// The writer slot frees up when the writer leaves
+	hub.leave(key, first)
+	_, hasWriter, _ = hub.stats(key)
+	assert.False(t, hasWriter)
+	_, writer, err = hub.join(key, true)
+	require.NoError(t, err)
+	assert.True(t, writer)
+
+	hub.leave(key, second)
+	viewers, _, _ = hub.stats(key)
+	assert.Equal(t, 2, viewers)
+}
+
+func TestAISessionHub_Shares(t *testing.T) {
+	hub := newAISessionHub()
+	key := aiSessionKey("app-feature-ai", "agent")
+
+	share, err := hub.share("app-feature-ai", "feature", "agent", time.Hour)
+	require.NoError(t, err)
+	found, ok := hub.lookupShare(share.token)
+	require.True(t, ok)
+	assert.Equal(t, "agent", found.session)
+	_, _, shares := hub.stats(key)
+	assert.Equal(t, 1, shares)
+
+	expired, err := hub.share("app-feature-ai", "feature", "agent", time.Hour)
+	require.NoError(t, err)
+	expired.expiresAt = time.Now().Add(-time.Second)
+	_, ok = hub.lookupShare(expired.token)
+	assert.False(t, ok)
+	_, ok = hub.lookupShare("unknown")
+	assert.False(t, ok)
+
+	hub.share("app-other-ai", "other", "agent", time.Hour)
+	assert.Equal(t, 1, hub.revokeShares(key))
+	_, ok = hub.lookupShare(share.token)
+	assert.False(t, ok)
+}
+
+func TestAISessionHandlers(t *testing.T) {
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	alice := loginAs(t, s, "alice")
+	bob := loginAs(t, s, "bob")
+
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(s.db).Create(ctx, &db.Repository{ID: "app", Path: t.TempDir(), Name: "app", OwnerID: userID(t, s, "alice")}))
+	require.NoError(t, db.NewWorktreeRepository(s.db).Create(ctx, &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: t.TempDir(), Status: db.StatusStopped}))
+
+	// Stopped worktrees have no sessions to list
+	rec := doRequest(s, http.MethodGet, "/api/ai/sessions", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list AISessionsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, 0, list.Total)
+
+	// Sessions of worktrees the caller may not attach to cannot be shared
+	rec = doRequest(s, http.MethodPost, "/api/ai/sessions/feature/agent/share", `{}`, bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = doRequest(s, http.MethodDelete, "/api/ai/sessions/feature/agent", "", bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(s, http.MethodPost, "/api/ai/sessions/feature/a.b/share", `{}`, alice)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(s, http.MethodPost, "/api/ai/sessions/feature/agent/share", `{"ttl":"1000h"}`, alice)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(s, http.MethodPost, "/api/ai/sessions/missing/agent/share", `{}`, alice)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(s, http.MethodPost, "/api/ai/sessions/feature/agent/share", `{"ttl":"2h"}`, alice)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var share AISessionShareResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &share))
+	assert.Equal(t, "/api/ai/shared/"+share.Token, share.Path)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), share.ExpiresAt, time.Minute)
+
+	found, ok := s.aiSessions.lookupShare(share.Token)
+	require.True(t, ok)
+	assert.Equal(t, "app-feature-ai", found.container)
+	assert.Equal(t, "agent", found.session)
+
+	// Share links need no login, but unknown ones are rejected before the
+	// WebSocket upgrade
+	rec = doRequest(s, http.MethodGet, "/api/ai/shared/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(s, http.MethodDelete, "/api/ai/sessions/feature/agent/share", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	_, ok = s.aiSessions.lookupShare(share.Token)
+	assert.False(t, ok)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
+	"vibeman/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Audit query limits
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// handleListAuditEvents godoc
+// @Summary Query the audit log
+// @Description List audited actions, newest first. Requires the admin scope when authentication is enabled.
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param target query string false "Filter by target ID or name"
+// @Param since query string false "Only events after this time: a duration such as 24h, a date or an RFC 3339 time"
+// @Param actor query string false "Filter by actor"
+// @Param action query string false "Filter by action, or by prefix ending in '.' (e.g. worktree.)"
+// @Param limit query int false "Maximum number of events (default 100, max 1000)"
+// @Success 200 {object} AuditEventsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/audit [get]
+func (s *Server) handleListAuditEvents(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	if principal := auth.PrincipalFromContext(c.Request().Context()); principal != nil && !principal.HasScope(auth.ScopeAdmin) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "the audit log requires the admin scope",
+		})
+	}
+
+	since, err := audit.ParseSince(c.QueryParam("since"), time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	limit := defaultAuditLimit
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "limit must be a positive number",
+			})
+		}
+		limit = min(limit, maxAuditLimit)
+	}
+
+	events, err := db.NewAuditRepository(dbInstance).List(c.Request().Context(), db.AuditFilter{
+		Target: c.QueryParam("target"),
+		Actor:  c.QueryParam("actor"),
+		Action: c.QueryParam("action"),
+		Since:  since,
+		Limit:  limit,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list audit events",
+		})
+	}
+
+	return c.JSON(http.StatusOK, AuditEventsResponse{
+		Events: events,
+		Total:  len(events),
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog_RecordsAPIActions(t *testing.T) {
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	audit.Init(s.db)
+	t.Cleanup(func() { audit.Init(nil) })
+
+	alice := loginAs(t, s, "alice")
+	bob := loginAs(t, s, "bob")
+
+	rec := doRequest(s, http.MethodPost, "/api/teams", `{"name":"platform"}`, bob)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doRequest(s, http.MethodPost, "/api/teams", `{"name":"platform"}`, bob)
+	require.NotEqual(t, http.StatusCreated, rec.Code)
+
+	// Only admins may read the audit log
+	rec = doRequest(s, http.MethodGet, "/api/audit", "", bob)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = doRequest(s, http.MethodGet, "/api/audit?target=platform&action=team.", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp AuditEventsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, 2, resp.Total)
+
+	failed, created := resp.Events[0], resp.Events[1]
+	assert.Equal(t, audit.ActionTeamCreate, created.Action)
+	assert.Equal(t, "bob", created.Actor)
+	assert.Equal(t, db.AuditSourceAPI, created.Source)
+	assert.Equal(t, db.AuditSuccess, created.Outcome)
+	assert.Equal(t, db.AuditFailure, failed.Outcome)
+
+	rec = doRequest(s, http.MethodGet, "/api/audit?since=yesterday", "", alice)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAuditLog_RecordsAuthActions(t *testing.T) {
+	s := newAuthTestServer(t)
+	audit.Init(s.db)
+	t.Cleanup(func() { audit.Init(nil) })
+
+	rec := doRequest(s, http.MethodPost, "/api/auth/login", `{"username":"alice","password":"wrong"}`, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	alice := loginAs(t, s, "alice")
+
+	rec = doRequest(s, http.MethodPost, "/api/auth/tokens", `{"name":"ci","scopes":["read"]}`, alice)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created CreateAPITokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	rec = doRequest(s, http.MethodDelete, "/api/auth/tokens/"+created.APIToken.ID, "", alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	events, err := db.NewAuditRepository(s.db).List(context.Background(), db.AuditFilter{})
+	require.NoError(t, err)
+	var got []string
+	for _, event := range events {
+		got = append(got, event.Action+" "+string(event.Outcome)+" "+event.Actor)
+	}
+	assert.ElementsMatch(t, []string{
+		audit.ActionLogin + " failure anonymous@192.0.2.1",
+		audit.ActionLogin + " success anonymous@192.0.2.1",
+		audit.ActionTokenCreate + " success alice",
+		audit.ActionTokenRevoke + " success alice",
+	}, got)
+}
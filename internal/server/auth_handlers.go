@@ -0,0 +1,271 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
+
+	"github.com/labstack/echo/v4"
+)
+
+// requirePrincipal returns the authenticated principal of the request
+func requirePrincipal(c echo.Context) (*auth.Principal, error) {
+	principal := auth.PrincipalFromContext(c.Request().Context())
+	if principal == nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	return principal, nil
+}
+
+// handleLogin godoc
+// @Summary Log in
+// @Description Authenticate with a username and password and receive a short-lived session token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginRequest true "Credentials"
+// @Success 200 {object} SessionTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/auth/login [post]
+func (s *Server) handleLogin(c echo.Context) error {
+	svc, err := s.getAuthService()
+	if err != nil {
+		return err
+	}
+
+	var req LoginRequest
+	if err := c.Bind(&req); err != nil || req.Username == "" || req.Password == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "username and password are required",
+		})
+	}
+
+	ctx := c.Request().Context()
+	session, err := svc.Login(ctx, req.Username, req.Password)
+	target := audit.Target{Type: audit.TargetUser, Name: req.Username}
+	if err == nil {
+		target.ID = session.User.ID
+	}
+	audit.Record(ctx, audit.ActionLogin, target, nil, err)
+	if err != nil {
+		return handleError(c, err, "Login failed")
+	}
+
+	return c.JSON(http.StatusOK, SessionTokenResponse{
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt,
+		User:      session.User,
+	})
+}
+
+// handleRefresh godoc
+// @Summary Refresh a session token
+// @Description Exchange a session token, which may have expired, for a new one while the login session is still valid
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} SessionTokenResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/auth/refresh [post]
+func (s *Server) handleRefresh(c echo.Context) error {
+	svc, err := s.getAuthService()
+	if err != nil {
+		return err
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	session, err := svc.Refresh(c.Request().Context(), token)
+	if err != nil {
+		return handleError(c, err, "Refresh failed")
+	}
+
+	return c.JSON(http.StatusOK, SessionTokenResponse{
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt,
+		User:      session.User,
+	})
+}
+
+// handleLogout godoc
+// @Summary Log out
+// @Description Revoke the current login session
+// @Tags auth
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/logout [post]
+func (s *Server) handleLogout(c echo.Context) error {
+	svc, err := s.getAuthService()
+	if err != nil {
+		return err
+	}
+
+	principal, err := requirePrincipal(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	err = svc.Logout(ctx, principal)
+	audit.Record(ctx, audit.ActionLogout, audit.Target{Type: audit.TargetUser, ID: principal.User.ID, Name: principal.User.Username}, nil, err)
+	if err != nil {
+		return handleError(c, err, "Logout failed")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// handleWhoAmI godoc
+// @Summary Current user
+// @Description Get the user the request is authenticated as
+// @Tags auth
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} db.User
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/whoami [get]
+func (s *Server) handleWhoAmI(c echo.Context) error {
+	principal, err := requirePrincipal(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, principal.User)
+}
+
+// handleListAPITokens godoc
+// @Summary List API tokens
+// @Description List the personal API tokens of the current user
+// @Tags auth
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} APITokensResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/tokens [get]
+func (s *Server) handleListAPITokens(c echo.Context) error {
+	svc, err := s.getAuthService()
+	if err != nil {
+		return err
+	}
+
+	principal, err := requirePrincipal(c)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := svc.ListAPITokens(c.Request().Context(), principal.User.ID)
+	if err != nil {
+		return handleError(c, err, "Failed to list API tokens")
+	}
+
+	return c.JSON(http.StatusOK, APITokensResponse{
+		Tokens: tokens,
+		Total:  len(tokens),
+	})
+}
+
+// handleCreateAPIToken godoc
+// @Summary Create an API token
+// @Description Create a scoped personal API token for the current user. The token value is only returned once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CreateAPITokenRequest true "Token details"
+// @Success 201 {object} CreateAPITokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/auth/tokens [post]
+func (s *Server) handleCreateAPIToken(c echo.Context) error {
+	svc, err := s.getAuthService()
+	if err != nil {
+		return err
+	}
+
+	principal, err := requirePrincipal(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateAPITokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "expires_in must be a positive duration such as 720h",
+			})
+		}
+	}
+
+	ctx := c.Request().Context()
+	token, apiToken, err := svc.CreateAPIToken(ctx, principal, req.Name, req.Scopes, ttl)
+	target := audit.Target{Type: audit.TargetToken, Name: req.Name}
+	if err == nil {
+		target.ID = apiToken.ID
+	}
+	audit.Record(ctx, audit.ActionTokenCreate, target, audit.Params{"scopes": req.Scopes, "expires_in": req.ExpiresIn}, err)
+	if err != nil {
+		return handleError(c, err, "Failed to create API token")
+	}
+
+	return c.JSON(http.StatusCreated, CreateAPITokenResponse{
+		Token:    token,
+		APIToken: apiToken,
+	})
+}
+
+// handleRevokeAPIToken godoc
+// @Summary Revoke an API token
+// @Description Delete a personal API token of the current user
+// @Tags auth
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Token ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/auth/tokens/{id} [delete]
+func (s *Server) handleRevokeAPIToken(c echo.Context) error {
+	svc, err := s.getAuthService()
+	if err != nil {
+		return err
+	}
+
+	principal, err := requirePrincipal(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	err = svc.RevokeAPIToken(ctx, principal.User.ID, c.Param("id"))
+	audit.Record(ctx, audit.ActionTokenRevoke, audit.Target{Type: audit.TargetToken, ID: c.Param("id")}, nil, err)
+	if err != nil {
+		return handleError(c, err, "Failed to revoke API token")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "API token revoked",
+	})
+}
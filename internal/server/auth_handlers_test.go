@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vibeman/internal/auth"
+	"vibeman/internal/config"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthTestServer creates a server with authentication enabled and one user
+func newAuthTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Auth = config.AuthConfig{Enabled: true, JWTSecret: "test-secret"}
+	database := testutil.SetupTestDB(t)
+	s := NewWithDependencies(cfg, nil, nil, nil, database)
+
+	_, err := s.authService.CreateUser(context.Background(), "alice", "", "password123", "")
+	require.NoError(t, err)
+
+	s.setupRoutes()
+	return s
+}
+
+// doRequest sends a request to the server with an optional bearer token
+func doRequest(s *Server, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddleware_RequiresToken(t *testing.T) {
+	s := newAuthTestServer(t)
+
+	rec := doRequest(s, http.MethodGet, "/api/operations", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doRequest(s, http.MethodGet, "/api/operations", "", "not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Health stays public
+	rec = doRequest(s, http.MethodGet, "/health", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthFlow(t *testing.T) {
+	s := newAuthTestServer(t)
+
+	rec := doRequest(s, http.MethodPost, "/api/auth/login", `{"username":"alice","password":"wrong"}`, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doRequest(s, http.MethodPost, "/api/auth/login", `{"username":"alice","password":"password123"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login SessionTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	assert.Equal(t, "alice", login.User.Username)
+
+	rec = doRequest(s, http.MethodGet, "/api/auth/whoami", "", login.Token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"username":"alice"`)
+
+	// Create a read-only API token and use it
+	rec = doRequest(s, http.MethodPost, "/api/auth/tokens", `{"name":"ci","scopes":["read"]}`, login.Token)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created CreateAPITokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Token, auth.APITokenPrefix))
+
+	rec = doRequest(s, http.MethodGet, "/api/operations", "", created.Token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(s, http.MethodDelete, "/api/operations/op-1", "", created.Token)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "read-only tokens cannot modify resources")
+
+	// Refresh issues a new session token
+	rec = doRequest(s, http.MethodPost, "/api/auth/refresh", "", login.Token)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// After logout the session can no longer be used
+	rec = doRequest(s, http.MethodPost, "/api/auth/logout", "", login.Token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/auth/whoami", "", login.Token)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddleware_Disabled(t *testing.T) {
+	s := NewWithDependencies(DefaultConfig(), nil, nil, nil, testutil.SetupTestDB(t))
+	s.setupRoutes()
+
+	rec := doRequest(s, http.MethodGet, "/api/operations", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
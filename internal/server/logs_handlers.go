@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"vibeman/internal/auth"
 	"vibeman/internal/db"
 
 	"github.com/labstack/echo/v4"
@@ -43,10 +44,9 @@ func (s *Server) handleGetWorktreeLogs(c echo.Context) error {
 	}
 
 	// Get worktree details
-	worktreeRepo := db.NewWorktreeRepository(dbInstance)
-	worktree, err := worktreeRepo.Get(c.Request().Context(), id)
+	worktree, err := s.authorizeWorktree(c, dbInstance, id, auth.ActionView)
 	if err != nil {
-		return handleError(c, err, "Failed to get worktree")
+		return err
 	}
 
 	// Get repository info for log path construction
@@ -107,11 +107,17 @@ func (s *Server) handleGetWorktreeLogs(c echo.Context) error {
 // @Param lines query int false "Number of lines to retrieve" default(50)
 // @Param follow query bool false "Follow log output"
 // @Success 200 {object} LogsResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
 // @Router /api/services/{id}/logs [get]
 func (s *Server) handleGetServiceLogs(c echo.Context) error {
+	// Shared services serve every team
+	if err := s.requireAdmin(c, "reading a shared service's logs"); err != nil {
+		return err
+	}
+
 	if s.serviceMgr == nil {
 		return c.JSON(503, ErrorResponse{
 			Error: "Service manager not available",
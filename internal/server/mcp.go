@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+
+	"vibeman/internal/operations"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleMCP godoc
+// @Summary vibeman's MCP server
+// @Description Answer a Model Context Protocol message from an agent in an AI container. Agents authenticate with the MCP token of their worktree, issued when its AI container starts, and their tools are limited to that worktree: list_services, tail_logs, restart_service, run_tests and setup_history.
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <MCP token of the worktree>"
+// @Success 200 {object} map[string]interface{}
+// @Success 202 "Notification accepted"
+// @Failure 401 {object} ErrorResponse
+// @Failure 405 {object} ErrorResponse
+// @Router /api/mcp [post]
+func (s *Server) handleMCP(c echo.Context) error {
+	database, err := s.getDB()
+	if err != nil {
+		return err
+	}
+
+	// The worktree's MCP token is the credential, not a user token
+	token := bearerToken(c)
+	if token == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "mcp token required")
+	}
+	worktreeID, err := operations.MCPWorktree(c.Request().Context(), database, token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid mcp token")
+	}
+
+	containerMgr, err := s.getContainerManagerInterface()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Container manager not available",
+		})
+	}
+	if s.serviceMgr == nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+
+	tools := operations.NewAgentTools(database, &containerManagerAdapter{mgr: containerMgr}, s.serviceMgr, s.configMgr)
+	tools.Server(worktreeID).ServeHTTP(c.Response(), c.Request())
+	return nil
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vibeman/internal/db"
+	"vibeman/internal/operations"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPHandler(t *testing.T) {
+	s := newAuthTestServer(t)
+	s.containerMgr = &testutil.MockContainerManager{}
+	s.serviceMgr = testutil.NewMockServiceManager()
+	alice := loginAs(t, s, "alice")
+
+	ctx := context.Background()
+	worktreePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(worktreePath, "vibeman.toml"), []byte("[repository]\nname = \"app\"\n"), 0644))
+	require.NoError(t, db.NewRepositoryRepository(s.db).Create(ctx, &db.Repository{ID: "app", Path: t.TempDir(), Name: "app"}))
+	require.NoError(t, db.NewWorktreeRepository(s.db).Create(ctx, &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: worktreePath, Status: db.StatusRunning}))
+	token, err := operations.IssueMCPToken(ctx, s.db, "wt", db.MCPScope{})
+	require.NoError(t, err)
+
+	// User tokens are not MCP tokens, even with auth enabled
+	list := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	rec := doRequest(s, http.MethodPost, "/api/mcp", list, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = doRequest(s, http.MethodPost, "/api/mcp", list, alice)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doRequest(s, http.MethodPost, "/api/mcp", list, token)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"name":"restart_service"`)
+
+	// Tools act on the token's worktree
+	rec = doRequest(s, http.MethodPost, "/api/mcp", `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"restart_service","arguments":{"service":"postgres"}}}`, token)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"isError":true`)
+	assert.Contains(t, rec.Body.String(), "this worktree uses no services")
+
+	rec = doRequest(s, http.MethodPost, "/api/mcp", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, token)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/mcp", "", token)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
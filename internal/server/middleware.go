@@ -4,12 +4,22 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
 	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/telemetry"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/xid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // contextKey is a custom type for context keys to avoid collisions
@@ -109,25 +119,148 @@ func contextEnricher(configMgr *config.Manager) echo.MiddlewareFunc {
 	}
 }
 
-// AuthMiddleware validates JWT tokens and sets user context
-// This is a placeholder that will be implemented when auth is added
-func AuthMiddleware() echo.MiddlewareFunc {
+// tracingMiddleware starts a server span for each request, continuing any
+// trace propagated by the caller
+func tracingMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// Pass through - authentication not yet implemented
-			// When implemented, this will validate JWT tokens
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			if route == "" {
+				route = req.URL.Path
+			}
+
+			ctx, span := telemetry.Tracer().Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", req.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", req.URL.Path),
+					attribute.String("client.address", c.RealIP()),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				// Let echo resolve the final status before recording it
+				c.Error(err)
+				span.RecordError(err)
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return nil
+		}
+	}
+}
+
+// publicAuthPaths are the API routes reachable without a token
+var publicAuthPaths = map[string]bool{
+	"/api/auth/login":       true,
+	"/api/auth/refresh":     true,
+	"/api/ai/shared/:token": true, // The share link token is the credential
+	"/api/mcp":              true, // The worktree's MCP token is the credential
+}
+
+// bearerToken extracts the bearer token of a request. WebSocket upgrades may
+// pass it as the access_token query parameter, since browsers cannot set
+// headers on WebSocket connections.
+func bearerToken(c echo.Context) string {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if strings.EqualFold(c.Request().Header.Get(echo.HeaderUpgrade), "websocket") {
+		return c.QueryParam("access_token")
+	}
+	return ""
+}
+
+// AuthMiddleware authenticates requests with a session token or personal API
+// token and enforces token scopes. When auth is disabled, requests without a
+// valid token are let through unauthenticated.
+func AuthMiddleware(svc *auth.Service, enabled bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if publicAuthPaths[c.Path()] {
+				return next(c)
+			}
+
+			token := bearerToken(c)
+			if token == "" || svc == nil {
+				if !enabled {
+					return next(c)
+				}
+				if svc == nil {
+					return echo.NewHTTPError(http.StatusServiceUnavailable, "authentication not available")
+				}
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+			}
+
+			principal, err := svc.Authenticate(c.Request().Context(), token)
+			if err != nil {
+				if !enabled {
+					return next(c)
+				}
+				return handleError(c, err, "Authentication failed")
+			}
+
+			if required := auth.RequiredScope(c.Request().Method); !principal.HasScope(required) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("token lacks the %q scope", required))
+			}
+
+			ctx := auth.WithPrincipal(c.Request().Context(), principal)
+			ctx = context.WithValue(ctx, ContextKeyUserID, principal.User.ID)
+			c.SetRequest(c.Request().WithContext(ctx))
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", principal.User.ID))
+
 			return next(c)
 		}
 	}
 }
 
-// TenantMiddleware validates tenant access
-// This is a placeholder that will be implemented when multi-tenancy is added
+// TenantHeader selects the team a request operates in
+const TenantHeader = "X-Vibeman-Team"
+
+// TenantMiddleware records the team selected by the caller in the request
+// context. Membership is checked when the caller's permissions are resolved.
 func TenantMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// Pass through - multi-tenancy not yet implemented
-			// When implemented, this will validate tenant access
+			team := strings.TrimSpace(c.Request().Header.Get(TenantHeader))
+			if team == "" {
+				team = c.QueryParam("team")
+			}
+			if team != "" {
+				ctx := context.WithValue(c.Request().Context(), ContextKeyTenantID, team)
+				c.SetRequest(c.Request().WithContext(ctx))
+				trace.SpanFromContext(ctx).SetAttributes(attribute.String("vibeman.team_id", team))
+			}
+			return next(c)
+		}
+	}
+}
+
+// AuditMiddleware attributes audited actions to the authenticated user, or to
+// an anonymous caller identified by address when auth is disabled
+func AuditMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			actor := audit.Actor{Name: "anonymous@" + c.RealIP(), Source: db.AuditSourceAPI}
+			if principal := auth.PrincipalFromContext(ctx); principal != nil {
+				actor.Name = principal.User.Username
+			}
+			c.SetRequest(c.Request().WithContext(audit.WithActor(ctx, actor)))
 			return next(c)
 		}
 	}
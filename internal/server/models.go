@@ -4,6 +4,7 @@ import (
 	"time"
 	"vibeman/internal/config"
 	"vibeman/internal/db"
+	"vibeman/internal/operations"
 )
 
 // ErrorResponse represents an error response
@@ -43,6 +44,7 @@ type AddRepositoryRequest struct {
 	Path        string `json:"path" validate:"required" example:"/home/user/projects/myapp"`
 	Name        string `json:"name" validate:"required" example:"myapp"`
 	Description string `json:"description" example:"My awesome application"`
+	TeamID      string `json:"team_id,omitempty" example:"9b2f6c1e-4d3a-4f0e-8a57-2c1d9e0b7f14"` // Defaults to the team selected with X-Vibeman-Team
 }
 
 // RepositoriesResponse represents a list of repositories
@@ -91,6 +93,9 @@ type Service struct {
 	Status      string    `json:"status" example:"running"`
 	Port        int       `json:"port" example:"5432"`
 	ContainerID string    `json:"container_id,omitempty"`
+	Health      string    `json:"health,omitempty" example:"healthy"`
+	HealthError string    `json:"health_error,omitempty"`
+	Restarts    int       `json:"restarts,omitempty" example:"0"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
@@ -100,6 +105,64 @@ type ServicesResponse struct {
 	Total    int       `json:"total" example:"3"`
 }
 
+// ServiceSnapshot describes a saved copy of a service's data
+type ServiceSnapshot struct {
+	ID        string    `json:"id" example:"20250101-120000"`
+	Service   string    `json:"service" example:"postgres"`
+	Tag       string    `json:"tag,omitempty" example:"baseline"`
+	Kind      string    `json:"kind" example:"logical"`
+	Database  string    `json:"database,omitempty" example:"postgres"`
+	Volumes   []string  `json:"volumes,omitempty"`
+	Size      int64     `json:"size" example:"1048576"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ServiceSnapshotsResponse represents a list of service snapshots
+type ServiceSnapshotsResponse struct {
+	Snapshots []ServiceSnapshot `json:"snapshots"`
+	Total     int               `json:"total" example:"2"`
+}
+
+// ServiceDefinition is a service's entry in services.toml. Provisioning,
+// health probe and restart settings are kept when a service is updated.
+type ServiceDefinition struct {
+	ComposeFile string            `json:"compose_file,omitempty" example:"/home/user/project/docker-compose.yaml"`
+	Service     string            `json:"service,omitempty" example:"db"`
+	Image       string            `json:"image,omitempty" example:"redis:7-alpine"`
+	Ports       []string          `json:"ports,omitempty" example:"6380:6379"`
+	Env         map[string]string `json:"env,omitempty"`
+	Volumes     []string          `json:"volumes,omitempty" example:"cache-data:/data"`
+	Command     []string          `json:"command,omitempty"`
+	DependsOn   []string          `json:"depends_on,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+// CreateServiceRequest adds a service to services.toml
+type CreateServiceRequest struct {
+	Name string `json:"name" validate:"required" example:"cache"`
+	ServiceDefinition
+}
+
+// ImportServicesRequest adds services from a docker-compose.yaml to services.toml
+type ImportServicesRequest struct {
+	ComposeFile string   `json:"compose_file" validate:"required" example:"/home/user/project/docker-compose.yaml"`
+	Services    []string `json:"services,omitempty"`
+	Inline      bool     `json:"inline,omitempty"`
+}
+
+// ImportServicesResponse lists the imported services
+type ImportServicesResponse struct {
+	Services []string `json:"services"`
+}
+
+// ServiceHealthResponse reports the result of a service health probe
+type ServiceHealthResponse struct {
+	ID        string    `json:"id" example:"postgres"`
+	Health    string    `json:"health" example:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
 // ServiceStatusResponse represents a service status update response
 type ServiceStatusResponse struct {
 	Message string `json:"message" example:"Service started successfully"`
@@ -181,3 +244,154 @@ type LogsResponse struct {
 	Timestamp string   `json:"timestamp" example:"2023-01-01T12:00:00Z"`
 	Lines     int      `json:"lines" example:"50"`
 }
+
+// Operation API models
+
+// OperationAcceptedResponse is returned when a long-running operation is started
+type OperationAcceptedResponse struct {
+	OperationID string `json:"operation_id" example:"5f0c1e0a-2b4d-4f4e-9d7c-1a2b3c4d5e6f"`
+	Type        string `json:"type" example:"create_worktree"`
+	Status      string `json:"status" example:"pending"`
+	Location    string `json:"location" example:"/api/operations/5f0c1e0a-2b4d-4f4e-9d7c-1a2b3c4d5e6f"`
+}
+
+// OperationsResponse represents a list of operations
+type OperationsResponse struct {
+	Operations []*db.Operation `json:"operations"`
+	Total      int             `json:"total" example:"3"`
+}
+
+// CreateAIJobRequest represents a request to run an agent headless in a
+// worktree's AI container
+type CreateAIJobRequest struct {
+	Prompt string `json:"prompt" validate:"required" example:"Add tests for the config loader"`
+	Agent  string `json:"agent,omitempty" example:"claude"`
+}
+
+// AIFanoutRequest represents a request to run one prompt in several fresh
+// worktrees of a repository
+type AIFanoutRequest struct {
+	Count       int    `json:"count" validate:"required,min=1,max=10" example:"3"`
+	Prompt      string `json:"prompt" validate:"required" example:"Add tests for the config loader"`
+	Agent       string `json:"agent,omitempty" example:"claude"`
+	BaseBranch  string `json:"base_branch,omitempty" example:"main"`
+	NamePrefix  string `json:"name_prefix,omitempty" example:"config-tests"`
+	TestCommand string `json:"test_command,omitempty" example:"go test ./..."`
+}
+
+// AIJobsResponse represents a list of AI jobs, without their transcripts and
+// diffs
+type AIJobsResponse struct {
+	Jobs  []*db.AIJob `json:"jobs"`
+	Total int         `json:"total" example:"2"`
+}
+
+// AISessionInfo represents a persistent AI terminal session and who is
+// connected to it
+type AISessionInfo struct {
+	operations.AISession
+	Viewers   int  `json:"viewers" example:"2"`       // WebSocket connections, the writer included
+	HasWriter bool `json:"has_writer" example:"true"` // Whether a WebSocket connection may type
+	Shares    int  `json:"shares" example:"1"`        // Active read-only share links
+	// What the agent in the session is doing, for sessions running an agent
+	Agent *operations.AgentActivity `json:"agent,omitempty"`
+}
+
+// AISessionsResponse represents a list of persistent AI terminal sessions
+type AISessionsResponse struct {
+	Sessions []AISessionInfo `json:"sessions"`
+	Total    int             `json:"total" example:"1"`
+}
+
+// ShareAISessionRequest represents a request for a read-only share link
+type ShareAISessionRequest struct {
+	TTL string `json:"ttl,omitempty" example:"2h"` // How long the link works, 24h by default
+}
+
+// AISessionShareResponse represents a read-only share link to an AI
+// terminal session. Anyone with the link can watch without logging in.
+type AISessionShareResponse struct {
+	Token     string    `json:"token"`
+	Path      string    `json:"path" example:"/api/ai/shared/3q2-7wEvx"`
+	URL       string    `json:"url" example:"ws://localhost:8080/api/ai/shared/3q2-7wEvx"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AIRecordingsResponse represents a list of recorded AI terminal sessions
+type AIRecordingsResponse struct {
+	Recordings []operations.AIRecording `json:"recordings"`
+	Total      int                      `json:"total" example:"3"`
+}
+
+// Authentication API models
+
+// LoginRequest represents a login request
+type LoginRequest struct {
+	Username string `json:"username" validate:"required" example:"alice"`
+	Password string `json:"password" validate:"required" example:"correct-horse-battery"`
+}
+
+// SessionTokenResponse is returned when a session token is issued
+type SessionTokenResponse struct {
+	Token     string    `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	ExpiresAt time.Time `json:"expires_at" example:"2025-01-01T12:15:00Z"`
+	User      *db.User  `json:"user"`
+}
+
+// CreateAPITokenRequest represents a request to create a personal API token
+type CreateAPITokenRequest struct {
+	Name      string   `json:"name" validate:"required" example:"ci"`
+	Scopes    []string `json:"scopes" validate:"required" example:"read,write"`
+	ExpiresIn string   `json:"expires_in,omitempty" example:"720h"` // Go duration; empty means no expiry
+}
+
+// CreateAPITokenResponse contains a newly created API token. The token value
+// is only ever returned once.
+type CreateAPITokenResponse struct {
+	Token    string       `json:"token" example:"vbm_3f1c..."`
+	APIToken *db.APIToken `json:"api_token"`
+}
+
+// APITokensResponse represents a list of personal API tokens
+type APITokensResponse struct {
+	Tokens []*db.APIToken `json:"tokens"`
+	Total  int            `json:"total" example:"2"`
+}
+
+// Team API models
+
+// CreateTeamRequest represents a request to create a team
+type CreateTeamRequest struct {
+	Name string `json:"name" validate:"required" example:"platform"`
+}
+
+// TeamsResponse represents a list of teams
+type TeamsResponse struct {
+	Teams []*db.Team `json:"teams"`
+	Total int        `json:"total" example:"2"`
+}
+
+// SetTeamMemberRequest represents a request to add a user to a team or
+// change their role
+type SetTeamMemberRequest struct {
+	Username string      `json:"username" validate:"required" example:"alice"`
+	Role     db.TeamRole `json:"role" example:"developer" enums:"viewer,developer,admin"`
+}
+
+// TeamMembersResponse represents the members of a team
+type TeamMembersResponse struct {
+	Members []*db.TeamMember `json:"members"`
+	Total   int              `json:"total" example:"3"`
+}
+
+// SetRepositoryTeamRequest represents a request to share a repository with a
+// team. An empty team ID makes the repository private to its owner.
+type SetRepositoryTeamRequest struct {
+	TeamID string `json:"team_id" example:"9b2f6c1e-4d3a-4f0e-8a57-2c1d9e0b7f14"`
+}
+
+// AuditEventsResponse represents a page of the audit log
+type AuditEventsResponse struct {
+	Events []*db.AuditEvent `json:"events"`
+	Total  int              `json:"total" example:"25"`
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"net/http"
+
+	"vibeman/internal/auth"
+	"vibeman/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// acceptOperation responds with 202 Accepted and the location of the operation
+func acceptOperation(c echo.Context, op *db.Operation) error {
+	location := "/api/operations/" + op.ID
+	c.Response().Header().Set(echo.HeaderLocation, location)
+	return c.JSON(http.StatusAccepted, OperationAcceptedResponse{
+		OperationID: op.ID,
+		Type:        op.Type,
+		Status:      string(op.Status),
+		Location:    location,
+	})
+}
+
+// handleListOperations godoc
+// @Summary List operations
+// @Description Get a list of asynchronous operations with optional filters
+// @Tags operations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Filter by status" Enums(pending, running, succeeded, failed, cancelled)
+// @Param target_id query string false "Filter by target resource ID"
+// @Success 200 {object} OperationsResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/operations [get]
+func (s *Server) handleListOperations(c echo.Context) error {
+	runner, err := s.getAsyncRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Operation runner not available",
+		})
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	all, err := runner.List(c.Request().Context(), c.QueryParam("status"), c.QueryParam("target_id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list operations",
+		})
+	}
+
+	// Only return operations on resources the caller may see
+	operations := make([]*db.Operation, 0, len(all))
+	for _, op := range all {
+		if s.authorizeOperation(c, access, op, auth.ActionView) == nil {
+			operations = append(operations, op)
+		}
+	}
+
+	return c.JSON(http.StatusOK, OperationsResponse{
+		Operations: operations,
+		Total:      len(operations),
+	})
+}
+
+// handleGetOperation godoc
+// @Summary Get operation by ID
+// @Description Get the status and step-by-step progress of an operation
+// @Tags operations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Operation ID"
+// @Success 200 {object} db.Operation
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/operations/{id} [get]
+func (s *Server) handleGetOperation(c echo.Context) error {
+	runner, err := s.getAsyncRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Operation runner not available",
+		})
+	}
+
+	op, err := runner.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return handleError(c, err, "Failed to get operation")
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeOperation(c, access, op, auth.ActionView); err != nil {
+		return handleError(c, err, "Failed to get operation")
+	}
+
+	return c.JSON(http.StatusOK, op)
+}
+
+// handleCancelOperation godoc
+// @Summary Cancel an operation
+// @Description Request cancellation of a running operation
+// @Tags operations
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Operation ID"
+// @Success 202 {object} db.Operation
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/operations/{id} [delete]
+func (s *Server) handleCancelOperation(c echo.Context) error {
+	runner, err := s.getAsyncRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Operation runner not available",
+		})
+	}
+
+	existing, err := runner.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return handleError(c, err, "Failed to cancel operation")
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeOperation(c, access, existing, auth.ActionStop); err != nil {
+		return handleError(c, err, "Failed to cancel operation")
+	}
+
+	op, err := runner.Cancel(c.Request().Context(), existing.ID)
+	if err != nil {
+		return handleError(c, err, "Failed to cancel operation")
+	}
+
+	return c.JSON(http.StatusAccepted, op)
+}
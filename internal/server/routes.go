@@ -6,14 +6,18 @@ import (
 	"net/http"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
 	"vibeman/internal/compose"
 	"vibeman/internal/config"
 	"vibeman/internal/container"
 	"vibeman/internal/db"
 	"vibeman/internal/errors"
+	"vibeman/internal/health"
 	"vibeman/internal/interfaces"
 	"vibeman/internal/logger"
 	"vibeman/internal/operations"
@@ -60,14 +64,34 @@ func (s *Server) setupRoutes() {
 	// Health check
 	s.echo.GET("/health", s.handleHealth)
 
-	// API group
-	api := s.echo.Group("/api")
+	// API group; every route requires authentication when auth is enabled
+	api := s.echo.Group("/api", AuthMiddleware(s.authService, s.config.Auth.Enabled), TenantMiddleware(), AuditMiddleware())
+
+	// Authentication
+	authGroup := api.Group("/auth")
+	authGroup.POST("/login", s.handleLogin)
+	authGroup.POST("/refresh", s.handleRefresh)
+	authGroup.POST("/logout", s.handleLogout)
+	authGroup.GET("/whoami", s.handleWhoAmI)
+	authGroup.GET("/tokens", s.handleListAPITokens)
+	authGroup.POST("/tokens", s.handleCreateAPIToken)
+	authGroup.DELETE("/tokens/:id", s.handleRevokeAPIToken)
 
 	// Repositories (formerly projects)
 	repos := api.Group("/repositories")
 	repos.GET("", s.handleListRepositories)
 	repos.POST("", s.handleAddRepository)
 	repos.DELETE("/:id", s.handleRemoveRepository)
+	repos.PUT("/:id/team", s.handleSetRepositoryTeam)
+	repos.POST("/:id/ai/fanout", s.handleAIFanout)
+
+	// Team routes
+	teams := api.Group("/teams")
+	teams.GET("", s.handleListTeams)
+	teams.POST("", s.handleCreateTeam)
+	teams.GET("/:id/members", s.handleListTeamMembers)
+	teams.POST("/:id/members", s.handleSetTeamMember)
+	teams.DELETE("/:id/members/:user_id", s.handleRemoveTeamMember)
 
 	// Worktrees
 	worktrees := api.Group("/worktrees")
@@ -78,13 +102,21 @@ func (s *Server) setupRoutes() {
 	worktrees.POST("/:id/start", s.handleStartWorktree)
 	worktrees.POST("/:id/stop", s.handleStopWorktree)
 	worktrees.GET("/:id/logs", s.handleGetWorktreeLogs)
+	worktrees.GET("/:id/ai/jobs", s.handleListWorktreeAIJobs)
+	worktrees.POST("/:id/ai/jobs", s.handleCreateAIJob)
 
 	// Services
 	services := api.Group("/services")
 	services.GET("", s.handleListServices)
+	services.POST("", s.handleCreateService)
+	services.POST("/import", s.handleImportServices)
+	services.PUT("/:id", s.handleUpdateService)
+	services.DELETE("/:id", s.handleDeleteService)
 	services.POST("/:id/start", s.handleStartService)
 	services.POST("/:id/stop", s.handleStopService)
+	services.GET("/:id/health", s.handleServiceHealth)
 	services.GET("/:id/logs", s.handleGetServiceLogs)
+	services.GET("/:id/snapshots", s.handleListServiceSnapshots)
 
 	// System status endpoint
 	api.GET("/status", s.handleSystemStatus)
@@ -101,9 +133,36 @@ func (s *Server) setupRoutes() {
 	// Configuration endpoint (read-only)
 	api.GET("/config", s.handleGetConfig)
 
+	// Asynchronous operations
+	ops := api.Group("/operations")
+	ops.GET("", s.handleListOperations)
+	ops.GET("/:id", s.handleGetOperation)
+	ops.DELETE("/:id", s.handleCancelOperation)
+
+	// Audit log
+	api.GET("/audit", s.handleListAuditEvents)
+
 	// AI container WebSocket endpoint
 	ai := api.Group("/ai")
 	ai.GET("/attach/:worktree", s.handleAIWebSocket)
+	ai.GET("/shared/:token", s.handleSharedAIWebSocket)
+
+	// Persistent AI terminal sessions
+	ai.GET("/sessions", s.handleListAISessions)
+	ai.DELETE("/sessions/:worktree/:session", s.handleKillAISession)
+	ai.POST("/sessions/:worktree/:session/share", s.handleShareAISession)
+	ai.DELETE("/sessions/:worktree/:session/share", s.handleUnshareAISession)
+	ai.GET("/recordings", s.handleListAIRecordings)
+	ai.GET("/recordings/:worktree/:name", s.handleGetAIRecording)
+
+	// Headless AI jobs
+	ai.GET("/jobs", s.handleListAIJobs)
+	ai.GET("/jobs/:id", s.handleGetAIJob)
+	ai.DELETE("/jobs/:id", s.handleCancelAIJob)
+
+	// MCP server for agents in AI containers. Only POST carries messages;
+	// the other methods of the transport are answered with 405.
+	api.Match([]string{http.MethodGet, http.MethodPost, http.MethodDelete}, "/mcp", s.handleMCP)
 }
 
 // handleHealth godoc
@@ -235,14 +294,27 @@ func (s *Server) handleListRepositories(c echo.Context) error {
 	// Create operations instance
 	ops := operations.NewRepositoryOperations(s.configMgr, gitMgr, dbInstance)
 
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
 	// List repositories using shared operations
-	repositories, err := ops.ListRepositories(c.Request().Context())
+	all, err := ops.ListRepositories(c.Request().Context())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: fmt.Sprintf("Failed to list repositories: %v", err),
 		})
 	}
 
+	// Only return repositories the caller may see
+	repositories := make([]*db.Repository, 0, len(all))
+	for _, repo := range all {
+		if access.CanRepository(repo, auth.ActionView) {
+			repositories = append(repositories, repo)
+		}
+	}
+
 	return c.JSON(http.StatusOK, RepositoriesResponse{
 		Repositories: repositories,
 		Total:        len(repositories),
@@ -291,13 +363,31 @@ func (s *Server) handleAddRepository(c echo.Context) error {
 		})
 	}
 
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	// Share with the selected team unless another one was requested
+	teamID := req.TeamID
+	if teamID == "" {
+		teamID = access.Team()
+	}
+	if teamID != "" {
+		if err := s.requireTeamRole(c, teamID, db.TeamRoleDeveloper); err != nil {
+			return err
+		}
+	}
+
 	// Create operations instance
 	ops := operations.NewRepositoryOperations(s.configMgr, gitMgr, dbInstance)
 
 	// Add repository using shared operations
 	repository, err := ops.AddRepository(c.Request().Context(), operations.AddRepositoryRequest{
-		Path: req.Path,
-		Name: req.Name,
+		Path:    req.Path,
+		Name:    req.Name,
+		OwnerID: access.UserID(),
+		TeamID:  teamID,
 	})
 
 	if err != nil {
@@ -342,6 +432,10 @@ func (s *Server) handleRemoveRepository(c echo.Context) error {
 		})
 	}
 
+	if _, err := s.authorizeRepository(c, dbInstance, id, auth.ActionDelete); err != nil {
+		return err
+	}
+
 	// Create operations instance
 	ops := operations.NewRepositoryOperations(s.configMgr, gitMgr, dbInstance)
 
@@ -385,13 +479,37 @@ func (s *Server) handleListWorktrees(c echo.Context) error {
 	}
 	repo := db.NewWorktreeRepository(dbInstance)
 
-	worktrees, err := repo.List(c.Request().Context(), repositoryID, status)
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	all, err := repo.List(c.Request().Context(), repositoryID, status)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: "Failed to list worktrees",
 		})
 	}
 
+	repositories, err := db.NewRepositoryRepository(dbInstance).List(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list worktrees",
+		})
+	}
+	visible := make(map[string]bool, len(repositories))
+	for _, r := range repositories {
+		visible[r.ID] = access.CanRepository(r, auth.ActionView)
+	}
+
+	// Only return worktrees of repositories the caller may see
+	worktrees := make([]db.Worktree, 0, len(all))
+	for _, wt := range all {
+		if visible[wt.RepositoryID] {
+			worktrees = append(worktrees, wt)
+		}
+	}
+
 	return c.JSON(http.StatusOK, WorktreesResponse{
 		Worktrees: worktrees,
 		Total:     len(worktrees),
@@ -400,13 +518,13 @@ func (s *Server) handleListWorktrees(c echo.Context) error {
 
 // handleCreateWorktree godoc
 // @Summary Create a new worktree
-// @Description Create a new development worktree for a repository
+// @Description Create a new development worktree for a repository. Creation runs asynchronously; poll the returned operation for progress.
 // @Tags worktrees
 // @Accept json
 // @Produce json
 // @Security Bearer
 // @Param request body CreateWorktreeRequest true "Worktree creation request"
-// @Success 201 {object} db.Worktree
+// @Success 202 {object} OperationAcceptedResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -454,25 +572,50 @@ func (s *Server) handleCreateWorktree(c echo.Context) error {
 	containerAdapter := &containerManagerAdapter{mgr: containerMgr}
 	ops := operations.NewWorktreeOperations(dbInstance, gitMgr, containerAdapter, serviceMgr, s.configMgr)
 
-	// Create worktree using shared operations
-	result, err := ops.CreateWorktree(c.Request().Context(), operations.CreateWorktreeRequest{
-		RepositoryID:    req.RepositoryID,
-		Name:            req.Name,
-		Branch:          req.Branch,
-		BaseBranch:      req.BaseBranch,
-		SkipSetup:       req.SkipSetup,
-		ContainerImage:  req.ContainerImage,
-		AutoStart:       req.AutoStart,
-		ComposeFile:     req.ComposeFile,
-		Services: req.ComposeServices,
-		PostScripts:     req.PostScripts,
-	})
+	runner, err := s.getAsyncRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Operation runner not available",
+		})
+	}
 
+	if req.RepositoryID == "" || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "repository_id and name are required",
+		})
+	}
+
+	if _, err := s.authorizeRepository(c, dbInstance, req.RepositoryID, auth.ActionCreate); err != nil {
+		return err
+	}
+
+	createReq := operations.CreateWorktreeRequest{
+		RepositoryID:   req.RepositoryID,
+		Name:           req.Name,
+		Branch:         req.Branch,
+		BaseBranch:     req.BaseBranch,
+		SkipSetup:      req.SkipSetup,
+		ContainerImage: req.ContainerImage,
+		AutoStart:      req.AutoStart,
+		ComposeFile:    req.ComposeFile,
+		Services:       req.ComposeServices,
+		PostScripts:    req.PostScripts,
+		OwnerID:        GetUserID(c.Request().Context()),
+	}
+
+	// Create worktree in the background; progress is tracked on the operation
+	op, err := runner.Start(c.Request().Context(), operations.OperationCreateWorktree, req.RepositoryID, func(ctx context.Context) (interface{}, error) {
+		result, err := ops.CreateWorktree(ctx, createReq)
+		if err != nil {
+			return nil, err
+		}
+		return result.Worktree, nil
+	})
 	if err != nil {
-		return handleError(c, err, "Failed to create worktree")
+		return handleError(c, err, "Failed to start worktree creation")
 	}
 
-	return c.JSON(http.StatusCreated, result.Worktree)
+	return acceptOperation(c, op)
 }
 
 // handleGetWorktree godoc
@@ -507,18 +650,10 @@ func (s *Server) handleGetWorktree(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	repo := db.NewWorktreeRepository(dbInstance)
 
-	worktree, err := repo.Get(c.Request().Context(), id)
+	worktree, err := s.authorizeWorktree(c, dbInstance, id, auth.ActionView)
 	if err != nil {
-		if err.Error() == "worktree not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "Worktree not found",
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to get worktree",
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, worktree)
@@ -554,6 +689,10 @@ func (s *Server) handleDeleteWorktree(c echo.Context) error {
 		})
 	}
 
+	if _, err := s.authorizeWorktree(c, dbInstance, id, auth.ActionDelete); err != nil {
+		return err
+	}
+
 	// Check for force flag
 	force := c.QueryParam("force") == "true"
 
@@ -580,15 +719,16 @@ func (s *Server) handleDeleteWorktree(c echo.Context) error {
 
 // handleStartWorktree godoc
 // @Summary Start a worktree
-// @Description Start a stopped worktree and its associated container
+// @Description Start a stopped worktree and its associated container. Startup runs asynchronously; poll the returned operation for progress.
 // @Tags worktrees
 // @Accept json
 // @Produce json
 // @Security Bearer
 // @Param id path string true "Worktree ID"
-// @Success 200 {object} WorktreeStatusResponse
+// @Success 202 {object} OperationAcceptedResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
 // @Router /api/worktrees/{id}/start [post]
@@ -633,16 +773,40 @@ func (s *Server) handleStartWorktree(c echo.Context) error {
 	containerAdapter := &containerManagerAdapter{mgr: containerMgr}
 	ops := operations.NewWorktreeOperations(dbInstance, gitMgr, containerAdapter, serviceMgr, s.configMgr)
 
-	// Start worktree using shared operations
-	if err := ops.StartWorktree(c.Request().Context(), id); err != nil {
-		return handleError(c, err, "Failed to start worktree")
+	runner, err := s.getAsyncRunner()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Operation runner not available",
+		})
 	}
 
-	return c.JSON(http.StatusOK, WorktreeStatusResponse{
-		Message: "Worktree started successfully",
-		ID:      id,
-		Status:  string(db.StatusRunning),
+	// Reject obviously invalid requests before accepting the operation
+	worktree, err := s.authorizeWorktree(c, dbInstance, id, auth.ActionStart)
+	if err != nil {
+		return err
+	}
+	if worktree.Status == db.StatusRunning || worktree.Status == db.StatusStarting {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error: fmt.Sprintf("Worktree is already %s", worktree.Status),
+		})
+	}
+
+	// Start worktree in the background; progress is tracked on the operation
+	op, err := runner.Start(c.Request().Context(), operations.OperationStartWorktree, id, func(ctx context.Context) (interface{}, error) {
+		if err := ops.StartWorktree(ctx, id); err != nil {
+			return nil, err
+		}
+		return WorktreeStatusResponse{
+			Message: "Worktree started successfully",
+			ID:      id,
+			Status:  string(db.StatusRunning),
+		}, nil
 	})
+	if err != nil {
+		return handleError(c, err, "Failed to start worktree")
+	}
+
+	return acceptOperation(c, op)
 }
 
 // handleStopWorktree godoc
@@ -700,6 +864,10 @@ func (s *Server) handleStopWorktree(c echo.Context) error {
 	containerAdapter := &containerManagerAdapter{mgr: containerMgr}
 	ops := operations.NewWorktreeOperations(dbInstance, gitMgr, containerAdapter, serviceMgr, s.configMgr)
 
+	if _, err := s.authorizeWorktree(c, dbInstance, id, auth.ActionStop); err != nil {
+		return err
+	}
+
 	// Stop worktree using shared operations
 	if err := ops.StopWorktree(c.Request().Context(), id); err != nil {
 		return handleError(c, err, "Failed to stop worktree")
@@ -771,9 +939,16 @@ func (s *Server) handleListServices(c echo.Context) error {
 			createdAt = *svc.StartTime
 		}
 
-		// Extract port from compose file
+		// Extract port from the inline definition or compose file
 		port := 0
-		if svc.Config.ComposeFile != "" && svc.Config.Service != "" {
+		if svc.Config.IsInline() {
+			// Ports published on a free host port are only known once running
+			if len(svc.Config.Ports) > 0 {
+				if _, host, _ := config.SplitPort(svc.Config.Ports[0]); host != "" {
+					port, _ = strconv.Atoi(host)
+				}
+			}
+		} else if svc.Config.ComposeFile != "" && svc.Config.Service != "" {
 			composeFile, err := compose.ParseComposeFile(svc.Config.ComposeFile)
 			if err != nil {
 				logger.WithError(err).WithFields(logger.Fields{
@@ -806,6 +981,9 @@ func (s *Server) handleListServices(c echo.Context) error {
 			Status:      string(svc.Status),
 			Port:        port,
 			ContainerID: svc.ContainerID,
+			Health:      string(svc.Health),
+			HealthError: svc.HealthError,
+			Restarts:    svc.Restarts,
 			CreatedAt:   createdAt,
 		})
 	}
@@ -824,6 +1002,7 @@ func (s *Server) handleListServices(c echo.Context) error {
 // @Produce json
 // @Param id path string true "Service ID"
 // @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/services/{id}/start [post]
@@ -835,6 +1014,11 @@ func (s *Server) handleStartService(c echo.Context) error {
 		})
 	}
 
+	// Shared services serve every team
+	if err := s.requireAdmin(c, "starting a shared service"); err != nil {
+		return err
+	}
+
 	// Check required dependencies
 	serviceMgr, err := s.getServiceManager()
 	if err != nil {
@@ -868,6 +1052,7 @@ func (s *Server) handleStartService(c echo.Context) error {
 // @Produce json
 // @Param id path string true "Service ID"
 // @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/services/{id}/stop [post]
@@ -879,6 +1064,11 @@ func (s *Server) handleStopService(c echo.Context) error {
 		})
 	}
 
+	// Shared services serve every team
+	if err := s.requireAdmin(c, "stopping a shared service"); err != nil {
+		return err
+	}
+
 	// Check required dependencies
 	serviceMgr, err := s.getServiceManager()
 	if err != nil {
@@ -904,6 +1094,103 @@ func (s *Server) handleStopService(c echo.Context) error {
 	})
 }
 
+// handleServiceHealth godoc
+// @Summary Check service health
+// @Description Run a service's health probe now and report the result
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 200 {object} ServiceHealthResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ServiceHealthResponse
+// @Router /api/services/{id}/health [get]
+func (s *Server) handleServiceHealth(c echo.Context) error {
+	id := c.Param("id")
+
+	// Check required dependencies
+	serviceMgr, err := s.getServiceManager()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+
+	ops := operations.NewServiceOperations(s.configMgr, serviceMgr)
+	if _, err := ops.GetService(c.Request().Context(), id); err != nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("Service not found: %s", id),
+		})
+	}
+
+	checkErr := ops.HealthCheckService(c.Request().Context(), id)
+	resp := ServiceHealthResponse{
+		ID:        id,
+		Health:    string(health.StatusOf(checkErr)),
+		CheckedAt: time.Now(),
+	}
+	if checkErr != nil {
+		resp.Error = checkErr.Error()
+		return c.JSON(http.StatusServiceUnavailable, resp)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// handleListServiceSnapshots godoc
+// @Summary List service snapshots
+// @Description List the saved snapshots of a service's data, newest first
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 200 {object} ServiceSnapshotsResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/services/{id}/snapshots [get]
+func (s *Server) handleListServiceSnapshots(c echo.Context) error {
+	id := c.Param("id")
+
+	// Check required dependencies
+	serviceMgr, err := s.getServiceManager()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+
+	ops := operations.NewServiceOperations(s.configMgr, serviceMgr)
+	if _, err := ops.GetService(c.Request().Context(), id); err != nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("Service not found: %s", id),
+		})
+	}
+
+	snapshots, err := ops.ListSnapshots(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: fmt.Sprintf("Failed to list snapshots: %v", err),
+		})
+	}
+
+	resp := ServiceSnapshotsResponse{
+		Snapshots: make([]ServiceSnapshot, 0, len(snapshots)),
+		Total:     len(snapshots),
+	}
+	for _, snapshot := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, ServiceSnapshot{
+			ID:        snapshot.ID,
+			Service:   snapshot.Service,
+			Tag:       snapshot.Tag,
+			Kind:      snapshot.Kind,
+			Database:  snapshot.Database,
+			Volumes:   snapshot.Volumes,
+			Size:      snapshot.Size,
+			CreatedAt: snapshot.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
 // isValidGitURL validates if the provided URL is a valid Git repository URL
 func isValidGitURL(url string) bool {
 	// Basic validation for common Git URL patterns
@@ -949,9 +1236,14 @@ type GlobalConfig struct {
 // @Produce json
 // @Security Bearer
 // @Success 200 {object} ConfigResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/config [get]
 func (s *Server) handleGetConfig(c echo.Context) error {
+	if err := s.requireAdmin(c, "the configuration"); err != nil {
+		return err
+	}
+
 	cfg, err := config.LoadGlobalConfig()
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -979,13 +1271,14 @@ func (s *Server) handleGetConfig(c echo.Context) error {
 
 // handleListContainers godoc
 // @Summary List containers
-// @Description Get a list of all containers
+// @Description Get a list of the containers of the worktrees the caller may see; admins see every container
 // @Tags containers
 // @Accept json
 // @Produce json
 // @Param repository query string false "Filter by repository"
 // @Param status query string false "Filter by status"
 // @Success 200 {object} ContainersResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
 // @Router /api/containers [get]
@@ -997,7 +1290,13 @@ func (s *Server) handleListContainers(c echo.Context) error {
 		})
 	}
 
-	containers, err := containerMgr.List(c.Request().Context())
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	containers, err := containerMgr.List(ctx)
 	if err != nil {
 		return handleError(c, err, "Failed to list containers")
 	}
@@ -1007,10 +1306,10 @@ func (s *Server) handleListContainers(c echo.Context) error {
 	statusFilter := c.QueryParam("status")
 	
 	filteredContainers := containers
-	if repositoryFilter != "" || statusFilter != "" {
+	if repositoryFilter != "" || statusFilter != "" || !access.Unrestricted() {
 		filteredContainers = make([]*container.Container, 0)
 		for _, cont := range containers {
-			include := true
+			include := s.canContainer(ctx, access, cont, auth.ActionView) == nil
 			if repositoryFilter != "" && cont.Repository != repositoryFilter {
 				include = false
 			}
@@ -1060,6 +1359,7 @@ func (s *Server) handleListContainers(c echo.Context) error {
 // @Produce json
 // @Param container body CreateContainerRequest true "Container configuration"
 // @Success 201 {object} ContainerResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
@@ -1091,7 +1391,27 @@ func (s *Server) handleCreateContainer(c echo.Context) error {
 		environmentName = "default"
 	}
 
+	// Containers may only be created for worktrees the caller may start
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+	owner := &container.Container{Repository: req.Repository, Environment: environmentName}
+	if err := s.canContainer(c.Request().Context(), access, owner, auth.ActionStart); err != nil {
+		return handleError(c, err, "Failed to create container")
+	}
+
 	container, err := containerMgr.Create(c.Request().Context(), req.Repository, environmentName, req.Image)
+	target := audit.Target{Type: audit.TargetContainer, Name: req.Repository + "-" + environmentName}
+	if container != nil {
+		target.ID, target.Name = container.ID, container.Name
+	}
+	audit.Record(c.Request().Context(), audit.ActionContainerCreate, target, audit.Params{
+		"repository": req.Repository,
+		"worktree":   req.Worktree,
+		"image":      req.Image,
+		"auto_start": req.AutoStart,
+	}, err)
 	if err != nil {
 		return handleError(c, err, "Failed to create container")
 	}
@@ -1135,6 +1455,7 @@ func (s *Server) handleCreateContainer(c echo.Context) error {
 // @Produce json
 // @Param id path string true "Container ID"
 // @Success 200 {object} ContainerResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
@@ -1154,6 +1475,10 @@ func (s *Server) handleGetContainer(c echo.Context) error {
 		})
 	}
 
+	if _, err := s.authorizeContainer(c, containerMgr, id, auth.ActionView); err != nil {
+		return err
+	}
+
 	// Get container by name (ID)
 	container, err := containerMgr.GetByName(c.Request().Context(), id)
 	if err != nil {
@@ -1191,6 +1516,7 @@ func (s *Server) handleGetContainer(c echo.Context) error {
 // @Produce json
 // @Param id path string true "Container ID"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
@@ -1210,6 +1536,11 @@ func (s *Server) handleDeleteContainer(c echo.Context) error {
 		})
 	}
 
+	id, err = s.authorizeContainer(c, containerMgr, id, auth.ActionDelete)
+	if err != nil {
+		return err
+	}
+
 	// Stop container first if running
 	if err := containerMgr.Stop(c.Request().Context(), id); err != nil {
 		// Log warning but continue with removal
@@ -1217,7 +1548,9 @@ func (s *Server) handleDeleteContainer(c echo.Context) error {
 	}
 
 	// Remove container
-	if err := containerMgr.Remove(c.Request().Context(), id); err != nil {
+	err = containerMgr.Remove(c.Request().Context(), id)
+	audit.Record(c.Request().Context(), audit.ActionContainerDelete, audit.Target{Type: audit.TargetContainer, ID: id, Name: id}, nil, err)
+	if err != nil {
 		return handleError(c, err, "Failed to delete container")
 	}
 
@@ -1235,6 +1568,7 @@ func (s *Server) handleDeleteContainer(c echo.Context) error {
 // @Param id path string true "Container ID"
 // @Param action body ContainerActionRequest true "Action to perform"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} ErrorResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -1262,28 +1596,38 @@ func (s *Server) handleContainerAction(c echo.Context) error {
 		})
 	}
 
+	action := auth.ActionStart
+	if req.Action == "stop" {
+		action = auth.ActionStop
+	}
+	id, err = s.authorizeContainer(c, containerMgr, id, action)
+	if err != nil {
+		return err
+	}
+
 	// Perform the requested action
+	ctx := c.Request().Context()
+	var actionErr error
 	switch req.Action {
 	case "start":
-		if err := containerMgr.Start(c.Request().Context(), id); err != nil {
-			return handleError(c, err, "Failed to start container")
-		}
+		actionErr = containerMgr.Start(ctx, id)
 	case "stop":
-		if err := containerMgr.Stop(c.Request().Context(), id); err != nil {
-			return handleError(c, err, "Failed to stop container")
-		}
+		actionErr = containerMgr.Stop(ctx, id)
 	case "restart":
 		// Stop then start
-		if err := containerMgr.Stop(c.Request().Context(), id); err != nil {
+		if err := containerMgr.Stop(ctx, id); err != nil {
 			logger.WithError(err).Warn("Failed to stop container during restart")
 		}
-		if err := containerMgr.Start(c.Request().Context(), id); err != nil {
-			return handleError(c, err, "Failed to restart container")
-		}
+		actionErr = containerMgr.Start(ctx, id)
 	default:
 		return handleError(c, errors.New(errors.ErrInvalidInput, "Invalid action. Must be 'start', 'stop', or 'restart'"), "Invalid action")
 	}
 
+	audit.Record(ctx, audit.ActionContainerAction+req.Action, audit.Target{Type: audit.TargetContainer, ID: id, Name: id}, nil, actionErr)
+	if actionErr != nil {
+		return handleError(c, actionErr, fmt.Sprintf("Failed to %s container", req.Action))
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": fmt.Sprintf("Container %s %sed successfully", req.Action, req.Action),
 	})
@@ -1299,6 +1643,7 @@ func (s *Server) handleContainerAction(c echo.Context) error {
 // @Param follow query bool false "Follow log output"
 // @Param tail query int false "Number of lines to show from end of logs"
 // @Success 200 {object} ContainerLogsResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
@@ -1318,6 +1663,11 @@ func (s *Server) handleGetContainerLogs(c echo.Context) error {
 		})
 	}
 
+	id, err = s.authorizeContainer(c, containerMgr, id, auth.ActionView)
+	if err != nil {
+		return err
+	}
+
 	// Get query parameters
 	follow := c.QueryParam("follow") == "true"
 
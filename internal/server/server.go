@@ -9,6 +9,8 @@ import (
 	"syscall"
 	"time"
 
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
 	"vibeman/internal/config"
 	"vibeman/internal/constants"
 	"vibeman/internal/container"
@@ -16,6 +18,7 @@ import (
 	"vibeman/internal/git"
 	"vibeman/internal/interfaces"
 	"vibeman/internal/logger"
+	"vibeman/internal/operations"
 	"vibeman/internal/service"
 
 	"github.com/labstack/echo/v4"
@@ -39,6 +42,18 @@ type Config struct {
 	LogLevel  string `toml:"log_level"`
 	LogFormat string `toml:"log_format"`
 
+	// Authentication
+	Auth config.AuthConfig `toml:"auth"`
+
+	// Audit log retention
+	Audit config.AuditConfig `toml:"audit"`
+
+	// Shared services, e.g. how long unused ones keep running
+	Services config.GlobalServicesConfig `toml:"services"`
+
+	// Headless AI job concurrency and timeout
+	AI config.GlobalAIConfig `toml:"ai"`
+
 	// Configuration file path (for compatibility with app.go)
 	ConfigPath string `toml:"-"`
 }
@@ -67,6 +82,12 @@ type Server struct {
 	gitMgr       interfaces.GitManager
 	serviceMgr   interfaces.ServiceManager
 	db           *db.DB
+	asyncRunner  *operations.AsyncRunner
+	aiJobRunner  *operations.AIJobRunner
+	aiSessions   *aiSessionHub
+	aiRecordings *aiRecordingSet
+	agentWatcher *operations.AgentWatcher
+	authService  *auth.Service
 	startTime    time.Time
 }
 
@@ -79,6 +100,24 @@ func (s *Server) getDB() (*db.DB, error) {
 	return s.db, nil
 }
 
+// getAsyncRunner safely retrieves the asynchronous operation runner
+func (s *Server) getAsyncRunner() (*operations.AsyncRunner, error) {
+	if s.asyncRunner == nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "operation runner not initialized")
+	}
+
+	return s.asyncRunner, nil
+}
+
+// getAIJobRunner safely retrieves the headless AI job runner
+func (s *Server) getAIJobRunner() (*operations.AIJobRunner, error) {
+	if s.aiJobRunner == nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "AI job runner not initialized")
+	}
+
+	return s.aiJobRunner, nil
+}
+
 // getServiceManager safely retrieves the service manager with type assertion
 func (s *Server) getServiceManager() (*service.Manager, error) {
 	if s.serviceMgr == nil {
@@ -145,10 +184,12 @@ func NewWithConfigManager(cfg *Config, configMgr *config.Manager) *Server {
 	e.HTTPErrorHandler = ErrorHandler
 
 	return &Server{
-		config:    cfg,
-		configMgr: configMgr,
-		echo:      e,
-		startTime: time.Now(),
+		config:       cfg,
+		configMgr:    configMgr,
+		echo:         e,
+		aiSessions:   newAISessionHub(),
+		aiRecordings: newAIRecordingSet(),
+		startTime:    time.Now(),
 	}
 }
 
@@ -165,15 +206,23 @@ func NewWithDependencies(cfg *Config, containerMgr interfaces.ContainerManager,
 	// Set custom error handler
 	e.HTTPErrorHandler = ErrorHandler
 
-	return &Server{
+	s := &Server{
 		config:       cfg,
 		echo:         e,
 		containerMgr: containerMgr,
 		gitMgr:       gitMgr,
 		serviceMgr:   serviceMgr,
 		db:           db,
+		aiSessions:   newAISessionHub(),
+		aiRecordings: newAIRecordingSet(),
 		startTime:    time.Now(),
 	}
+	if db != nil {
+		s.asyncRunner = operations.NewAsyncRunner(db)
+		s.aiJobRunner = operations.NewAIJobRunner(db, s.config.AI.MaxJobs, s.config.AI.JobTimeoutDuration())
+		s.initAuth()
+	}
+	return s
 }
 
 // Echo returns the Echo instance
@@ -187,6 +236,32 @@ func (s *Server) SetDependencies(containerMgr interfaces.ContainerManager, gitMg
 	s.gitMgr = gitMgr
 	s.serviceMgr = serviceMgr
 	s.db = db
+	if db != nil {
+		s.asyncRunner = operations.NewAsyncRunner(db)
+		s.aiJobRunner = operations.NewAIJobRunner(db, s.config.AI.MaxJobs, s.config.AI.JobTimeoutDuration())
+		s.initAuth()
+	}
+}
+
+// initAuth creates the auth service. Failures are logged; with auth enabled
+// the API then rejects all requests rather than running unprotected.
+func (s *Server) initAuth() {
+	cfg, err := auth.NewConfig(s.config.Auth)
+	if err != nil {
+		logger.WithError(err).Error("Failed to initialize authentication")
+		s.authService = nil
+		return
+	}
+	s.authService = auth.NewService(s.db, cfg)
+}
+
+// getAuthService safely retrieves the auth service
+func (s *Server) getAuthService() (*auth.Service, error) {
+	if s.authService == nil {
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "authentication not available")
+	}
+
+	return s.authService, nil
 }
 
 // Handler returns the HTTP handler
@@ -211,6 +286,52 @@ func (s *Server) Start(ctx ...context.Context) error {
 	// Setup routes
 	s.setupRoutes()
 
+	// Operations from a previous run cannot be resumed
+	if s.asyncRunner != nil {
+		if err := s.asyncRunner.RecoverInterrupted(shutdownCtx); err != nil {
+			logger.WithError(err).Warn("Failed to recover interrupted operations")
+		}
+	}
+	if s.aiJobRunner != nil {
+		if err := s.aiJobRunner.Resume(shutdownCtx); err != nil {
+			logger.WithError(err).Warn("Failed to resume queued AI jobs")
+		}
+	}
+
+	// Prune expired audit events while the server runs
+	pruneCtx, stopPruner := context.WithCancel(shutdownCtx)
+	defer stopPruner()
+	if s.db != nil {
+		go audit.RunPruner(pruneCtx, s.db, s.config.Audit.RetentionDuration(), constants.DefaultAuditPruneInterval)
+	}
+
+	// Drop references left by worktrees that stopped while the server was
+	// down, then stop services nothing uses once their grace period ends and
+	// restart the ones that crash
+	if serviceMgr, ok := s.serviceMgr.(*service.Manager); ok {
+		if err := serviceMgr.ReconcileReferences(shutdownCtx); err != nil {
+			logger.WithError(err).Warn("Failed to reconcile service references")
+		}
+		go serviceMgr.RunIdleStopper(pruneCtx, s.config.Services.IdleGraceDuration(), constants.DefaultServiceIdleCheckInterval)
+		go serviceMgr.RunSupervisor(pruneCtx, constants.DefaultServiceSupervisorInterval)
+	}
+
+	// Tell whoever is configured when an agent waits for input or finishes
+	if s.db != nil {
+		watcher, err := operations.NewAgentWatcher(s.db, s.config.AI.Notify, operations.NewAgentNotifiers(s.config.AI.Notify)...)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to start agent watcher")
+		} else {
+			s.agentWatcher = watcher
+			go watcher.Run(pruneCtx, constants.DefaultAgentWatchInterval)
+		}
+	}
+
+	// Record AI terminal sessions whether or not anyone is attached
+	if s.db != nil {
+		go s.runAIRecordings(pruneCtx, constants.DefaultAIRecordInterval)
+	}
+
 	// Start server
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
 	s.echo.Logger.Infof("Starting server on %s", addr)
@@ -252,6 +373,23 @@ func (s *Server) Start(ctx ...context.Context) error {
 		return fmt.Errorf("server shutdown failed: %w", err)
 	}
 
+	// Cancel operations still running in the background
+	if s.asyncRunner != nil {
+		if err := s.asyncRunner.Shutdown(shutdownTimeout); err != nil {
+			logger.WithError(err).Warn("Timed out waiting for operations to stop")
+		}
+	}
+	if s.aiJobRunner != nil {
+		if err := s.aiJobRunner.Shutdown(shutdownTimeout); err != nil {
+			logger.WithError(err).Warn("Timed out waiting for AI jobs to stop")
+		}
+	}
+
+	// Stop recording AI terminal sessions, so whatever runs next can
+	// take them up
+	stopPruner()
+	s.aiRecordings.stopAll()
+
 	s.echo.Logger.Info("Server stopped gracefully")
 	return nil
 }
@@ -272,6 +410,7 @@ func (s *Server) setupMiddleware() {
 	}))
 
 	// Custom middleware
+	s.echo.Use(tracingMiddleware())
 	s.echo.Use(contextEnricher(s.configMgr))
 }
 
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"vibeman/internal/config"
+	"vibeman/internal/operations"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleCreateService godoc
+// @Summary Add a service
+// @Description Add a service to services.toml, either from a compose file or defined inline
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param request body CreateServiceRequest true "Service definition"
+// @Success 201 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/services [post]
+func (s *Server) handleCreateService(c echo.Context) error {
+	// services.toml configures the services every team shares
+	if err := s.requireAdmin(c, "changing shared services"); err != nil {
+		return err
+	}
+
+	var req CreateServiceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "name is required",
+		})
+	}
+
+	ops, err := s.serviceConfigOperations()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+	if err := req.ServiceDefinition.checkVolumes(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	if _, exists := s.configuredService(req.Name); exists {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error: fmt.Sprintf("Service already exists: %s", req.Name),
+		})
+	}
+
+	if err := ops.AddService(c.Request().Context(), req.Name, req.ServiceDefinition.apply(config.ServiceConfig{})); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Failed to add service: %v", err),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, SuccessResponse{
+		Message: fmt.Sprintf("Service %s added", req.Name),
+	})
+}
+
+// handleUpdateService godoc
+// @Summary Update a service
+// @Description Replace a service's definition in services.toml. Provisioning, health probe and restart settings are kept. A running service picks up the change when it is next started.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Param request body ServiceDefinition true "Service definition"
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/services/{id} [put]
+func (s *Server) handleUpdateService(c echo.Context) error {
+	// services.toml configures the services every team shares
+	if err := s.requireAdmin(c, "changing shared services"); err != nil {
+		return err
+	}
+
+	id := c.Param("id")
+
+	var req ServiceDefinition
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	ops, err := s.serviceConfigOperations()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+	if err := req.checkVolumes(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	current, exists := s.configuredService(id)
+	if !exists {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("Service not found: %s", id),
+		})
+	}
+
+	if err := ops.UpdateService(c.Request().Context(), id, req.apply(current)); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Failed to update service: %v", err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: fmt.Sprintf("Service %s updated", id),
+	})
+}
+
+// handleDeleteService godoc
+// @Summary Remove a service
+// @Description Remove a stopped service that no other service depends on from services.toml
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/services/{id} [delete]
+func (s *Server) handleDeleteService(c echo.Context) error {
+	// services.toml configures the services every team shares
+	if err := s.requireAdmin(c, "changing shared services"); err != nil {
+		return err
+	}
+
+	id := c.Param("id")
+
+	ops, err := s.serviceConfigOperations()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+	if _, exists := s.configuredService(id); !exists {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("Service not found: %s", id),
+		})
+	}
+
+	if err := ops.RemoveService(c.Request().Context(), id); err != nil {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error: fmt.Sprintf("Failed to remove service: %v", err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: fmt.Sprintf("Service %s removed", id),
+	})
+}
+
+// handleImportServices godoc
+// @Summary Import services
+// @Description Add services from a docker-compose.yaml to services.toml, all of them unless some are named
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param request body ImportServicesRequest true "Import request"
+// @Success 201 {object} ImportServicesResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/services/import [post]
+func (s *Server) handleImportServices(c echo.Context) error {
+	// services.toml configures the services every team shares
+	if err := s.requireAdmin(c, "changing shared services"); err != nil {
+		return err
+	}
+
+	var req ImportServicesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+	if req.ComposeFile == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "compose_file is required",
+		})
+	}
+
+	ops, err := s.serviceConfigOperations()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Service manager not available",
+		})
+	}
+
+	imported, err := ops.ImportServices(c.Request().Context(), operations.ImportServicesRequest{
+		ComposeFile: req.ComposeFile,
+		Services:    req.Services,
+		Inline:      req.Inline,
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Failed to import services: %v", err),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, ImportServicesResponse{Services: imported})
+}
+
+// serviceConfigOperations returns the operations that change services.toml
+func (s *Server) serviceConfigOperations() (*operations.ServiceOperations, error) {
+	serviceMgr, err := s.getServiceManager()
+	if err != nil {
+		return nil, err
+	}
+	return operations.NewServiceOperations(s.configMgr, serviceMgr), nil
+}
+
+// configuredService returns a service's entry in services.toml
+func (s *Server) configuredService(name string) (config.ServiceConfig, bool) {
+	if s.configMgr == nil || s.configMgr.Services == nil {
+		return config.ServiceConfig{}, false
+	}
+	svc, exists := s.configMgr.Services.Services[name]
+	return svc, exists
+}
+
+// checkVolumes refuses volumes that mount host paths. Services run on the
+// host for everyone, so mounting its files is left to whoever edits
+// services.toml on it.
+func (d ServiceDefinition) checkVolumes() error {
+	for _, volume := range d.Volumes {
+		if path := config.VolumeHostPath(volume); path != "" {
+			return fmt.Errorf("volume %q mounts the host path %s; use a named volume, or add it to services.toml on the host", volume, path)
+		}
+	}
+	return nil
+}
+
+// apply returns svc with its definition replaced by d
+func (d ServiceDefinition) apply(svc config.ServiceConfig) config.ServiceConfig {
+	svc.ComposeFile = d.ComposeFile
+	svc.Service = d.Service
+	svc.Image = d.Image
+	svc.Ports = d.Ports
+	svc.Env = d.Env
+	svc.Volumes = d.Volumes
+	svc.Command = d.Command
+	svc.DependsOn = d.DependsOn
+	svc.Description = d.Description
+	return svc
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceConfigHandlers_RequireAdmin(t *testing.T) {
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	bob := loginAs(t, s, "bob")
+
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, "/api/services", `{"name":"root","image":"alpine","volumes":["/:/host"]}`},
+		{http.MethodPost, "/api/services/import", `{"compose_file":"/tmp/docker-compose.yaml"}`},
+		{http.MethodPut, "/api/services/postgres", `{"image":"postgres:16"}`},
+		{http.MethodDelete, "/api/services/postgres", ""},
+	} {
+		rec := doRequest(s, req.method, req.path, req.body, bob)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "%s %s", req.method, req.path)
+	}
+}
+
+func TestServiceDefinition_CheckVolumes(t *testing.T) {
+	assert.NoError(t, ServiceDefinition{Volumes: []string{"cache-data:/data", "pg_16.data:/var/lib/postgresql/data"}}.checkVolumes())
+	for _, volume := range []string{"/:/host", "./data:/data", "~/.ssh:/root/.ssh", "../x:/x"} {
+		assert.Error(t, ServiceDefinition{Volumes: []string{volume}}.checkVolumes(), volume)
+	}
+}
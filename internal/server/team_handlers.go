@@ -0,0 +1,491 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/auth"
+	"vibeman/internal/container"
+	"vibeman/internal/db"
+	"vibeman/internal/interfaces"
+	"vibeman/internal/operations"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// loadAccess resolves what the caller of a request may see and do
+func (s *Server) loadAccess(c echo.Context) (*auth.Access, error) {
+	ctx := c.Request().Context()
+	access, err := auth.LoadAccess(ctx, s.db, GetTenantID(ctx))
+	if err != nil {
+		return nil, handleError(c, err, "Failed to resolve permissions")
+	}
+	return access, nil
+}
+
+// authorizeRepository loads a repository and checks the caller may perform
+// action on it
+func (s *Server) authorizeRepository(c echo.Context, dbInstance *db.DB, id string, action auth.Action) (*db.Repository, error) {
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := db.NewRepositoryRepository(dbInstance).GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, handleError(c, err, "Failed to get repository")
+	}
+	if err := access.CheckRepository(repo, action); err != nil {
+		return nil, handleError(c, err, "Failed to get repository")
+	}
+
+	return repo, nil
+}
+
+// authorizeWorktree loads a worktree and checks the caller may perform action
+// on it
+func (s *Server) authorizeWorktree(c echo.Context, dbInstance *db.DB, id string, action auth.Action) (*db.Worktree, error) {
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := c.Request().Context()
+	worktree, err := db.NewWorktreeRepository(dbInstance).Get(ctx, id)
+	if err != nil {
+		return nil, handleError(c, err, "Failed to get worktree")
+	}
+	repo, err := db.NewRepositoryRepository(dbInstance).GetByID(ctx, worktree.RepositoryID)
+	if err != nil {
+		return nil, handleError(c, err, "Failed to get repository")
+	}
+	if err := access.CheckWorktree(repo, worktree, action); err != nil {
+		return nil, handleError(c, err, "Failed to get worktree")
+	}
+
+	return worktree, nil
+}
+
+// authorizeOperation checks the caller may perform action on the target of an
+// operation. Operations whose target no longer exists are only visible to
+// unrestricted callers.
+func (s *Server) authorizeOperation(c echo.Context, access *auth.Access, op *db.Operation, action auth.Action) error {
+	ctx := c.Request().Context()
+	repos := db.NewRepositoryRepository(s.db)
+
+	var repo *db.Repository
+	var worktree *db.Worktree
+	var err error
+	if op.Type == operations.OperationCreateWorktree || op.Type == operations.OperationAIFanout {
+		repo, err = repos.GetByID(ctx, op.TargetID)
+	} else if worktree, err = db.NewWorktreeRepository(s.db).Get(ctx, op.TargetID); err == nil {
+		repo, err = repos.GetByID(ctx, worktree.RepositoryID)
+	}
+
+	switch {
+	case access.Unrestricted():
+		return nil
+	case err != nil:
+		return fmt.Errorf("operation not found")
+	case worktree != nil:
+		return access.CheckWorktree(repo, worktree, action)
+	default:
+		return access.CheckRepository(repo, action)
+	}
+}
+
+// requireAdmin returns an error unless the caller is a global admin. Shared
+// services and the configuration serve every team, so only admins manage them.
+func (s *Server) requireAdmin(c echo.Context, what string) error {
+	if principal := auth.PrincipalFromContext(c.Request().Context()); principal != nil && !principal.HasScope(auth.ScopeAdmin) {
+		return echo.NewHTTPError(http.StatusForbidden, what+" requires the admin scope")
+	}
+	return nil
+}
+
+// containerWorktree finds the worktree a container belongs to by its
+// vibeman.repository and vibeman.environment labels
+func (s *Server) containerWorktree(ctx context.Context, cont *container.Container) (*db.Repository, *db.Worktree, error) {
+	if s.db == nil || cont.Repository == "" || cont.Environment == "" {
+		return nil, nil, fmt.Errorf("container not found")
+	}
+	repo, err := db.NewRepositoryRepository(s.db).GetRepositoryByName(ctx, cont.Repository)
+	if err != nil {
+		return nil, nil, fmt.Errorf("container not found")
+	}
+	worktrees, err := db.NewWorktreeRepository(s.db).List(ctx, repo.ID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range worktrees {
+		if worktrees[i].Name == cont.Environment {
+			return repo, &worktrees[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("container not found")
+}
+
+// canContainer reports whether the caller may perform action on a container.
+// Containers that belong to no tracked worktree, such as those of shared
+// services, are limited to unrestricted callers.
+func (s *Server) canContainer(ctx context.Context, access *auth.Access, cont *container.Container, action auth.Action) error {
+	if access.Unrestricted() {
+		return nil
+	}
+	repo, worktree, err := s.containerWorktree(ctx, cont)
+	if err != nil {
+		return err
+	}
+	return access.CheckWorktree(repo, worktree, action)
+}
+
+// authorizeContainer checks the caller may perform action on the container
+// with the given ID or name, and returns the ID to act on. Unrestricted
+// callers act on the ID as given; everyone else on the container it resolves
+// to, so the container checked is the one acted on.
+func (s *Server) authorizeContainer(c echo.Context, containerMgr interfaces.ContainerManager, id string, action auth.Action) (string, error) {
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return "", err
+	}
+	if access.Unrestricted() {
+		return id, nil
+	}
+
+	ctx := c.Request().Context()
+	containers, err := containerMgr.List(ctx)
+	if err != nil {
+		return "", handleError(c, err, "Failed to list containers")
+	}
+	for _, cont := range containers {
+		if cont.ID != id && cont.Name != id {
+			continue
+		}
+		if err := s.canContainer(ctx, access, cont, action); err != nil {
+			return "", handleError(c, err, "Failed to get container")
+		}
+		return cont.ID, nil
+	}
+	return "", echo.NewHTTPError(http.StatusNotFound, "container not found")
+}
+
+// handleListTeams godoc
+// @Summary List teams
+// @Description List the teams the caller belongs to; admins see every team
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} TeamsResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/teams [get]
+func (s *Server) handleListTeams(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	principal := auth.PrincipalFromContext(c.Request().Context())
+	teamRepo := db.NewTeamRepository(dbInstance)
+
+	var teams []*db.Team
+	if principal == nil || principal.User.Role == db.RoleAdmin {
+		teams, err = teamRepo.List(c.Request().Context())
+	} else {
+		teams, err = teamRepo.ListByUser(c.Request().Context(), principal.User.ID)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list teams",
+		})
+	}
+
+	return c.JSON(http.StatusOK, TeamsResponse{
+		Teams: teams,
+		Total: len(teams),
+	})
+}
+
+// handleCreateTeam godoc
+// @Summary Create a team
+// @Description Create a team. The caller becomes its first admin.
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CreateTeamRequest true "Team details"
+// @Success 201 {object} db.Team
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/teams [post]
+func (s *Server) handleCreateTeam(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	var req CreateTeamRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Team name is required",
+		})
+	}
+
+	ctx := c.Request().Context()
+	teamRepo := db.NewTeamRepository(dbInstance)
+	team := &db.Team{ID: uuid.New().String(), Name: strings.TrimSpace(req.Name)}
+	err = teamRepo.Create(ctx, team)
+	audit.Record(ctx, audit.ActionTeamCreate, audit.Target{Type: audit.TargetTeam, ID: team.ID, Name: team.Name}, nil, err)
+	if err != nil {
+		return handleError(c, err, "Failed to create team")
+	}
+
+	if principal := auth.PrincipalFromContext(ctx); principal != nil {
+		if err := teamRepo.SetMember(ctx, team.ID, principal.User.ID, db.TeamRoleAdmin); err != nil {
+			return handleError(c, err, "Failed to add team admin")
+		}
+	}
+
+	created, err := teamRepo.Get(ctx, team.ID)
+	if err != nil {
+		return handleError(c, err, "Failed to get team")
+	}
+	return c.JSON(http.StatusCreated, created)
+}
+
+// requireTeamRole checks the caller holds at least role in a team. Teams the
+// caller does not belong to are reported as not found.
+func (s *Server) requireTeamRole(c echo.Context, teamID string, role db.TeamRole) error {
+	if _, err := db.NewTeamRepository(s.db).Get(c.Request().Context(), teamID); err != nil {
+		return handleError(c, err, "Failed to get team")
+	}
+
+	access, err := s.loadAccess(c)
+	if err != nil {
+		return err
+	}
+	current, ok := access.MemberRole(teamID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "team not found")
+	}
+	if !current.AtLeast(role) {
+		return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("team %s role required", role))
+	}
+	return nil
+}
+
+// handleListTeamMembers godoc
+// @Summary List team members
+// @Description List the members of a team and their roles
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Team ID"
+// @Success 200 {object} TeamMembersResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/teams/{id}/members [get]
+func (s *Server) handleListTeamMembers(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	teamID := c.Param("id")
+	if err := s.requireTeamRole(c, teamID, db.TeamRoleViewer); err != nil {
+		return err
+	}
+
+	members, err := db.NewTeamRepository(dbInstance).ListMembers(c.Request().Context(), teamID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to list team members",
+		})
+	}
+
+	return c.JSON(http.StatusOK, TeamMembersResponse{
+		Members: members,
+		Total:   len(members),
+	})
+}
+
+// handleSetTeamMember godoc
+// @Summary Add or update a team member
+// @Description Add a user to a team or change their role. Requires the team admin role.
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Team ID"
+// @Param request body SetTeamMemberRequest true "Member details"
+// @Success 200 {object} TeamMembersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/teams/{id}/members [post]
+func (s *Server) handleSetTeamMember(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	var req SetTeamMemberRequest
+	if err := c.Bind(&req); err != nil || req.Username == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "username is required",
+		})
+	}
+	if req.Role == "" {
+		req.Role = db.TeamRoleDeveloper
+	}
+	if !req.Role.IsValid() {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("invalid role %q: must be viewer, developer or admin", req.Role),
+		})
+	}
+
+	teamID := c.Param("id")
+	if err := s.requireTeamRole(c, teamID, db.TeamRoleAdmin); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	user, err := db.NewUserRepository(dbInstance).GetByUsername(ctx, req.Username)
+	if err != nil {
+		return handleError(c, err, "Failed to get user")
+	}
+
+	teamRepo := db.NewTeamRepository(dbInstance)
+	err = teamRepo.SetMember(ctx, teamID, user.ID, req.Role)
+	audit.Record(ctx, audit.ActionTeamSetMember, audit.Target{Type: audit.TargetTeam, ID: teamID}, audit.Params{
+		"username": user.Username,
+		"role":     string(req.Role),
+	}, err)
+	if err != nil {
+		return handleError(c, err, "Failed to set team member")
+	}
+
+	members, err := teamRepo.ListMembers(ctx, teamID)
+	if err != nil {
+		return handleError(c, err, "Failed to list team members")
+	}
+	return c.JSON(http.StatusOK, TeamMembersResponse{
+		Members: members,
+		Total:   len(members),
+	})
+}
+
+// handleRemoveTeamMember godoc
+// @Summary Remove a team member
+// @Description Remove a user from a team. Requires the team admin role.
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Team ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/teams/{id}/members/{user_id} [delete]
+func (s *Server) handleRemoveTeamMember(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	teamID := c.Param("id")
+	if err := s.requireTeamRole(c, teamID, db.TeamRoleAdmin); err != nil {
+		return err
+	}
+
+	err = db.NewTeamRepository(dbInstance).RemoveMember(c.Request().Context(), teamID, c.Param("user_id"))
+	audit.Record(c.Request().Context(), audit.ActionTeamRemoveMember, audit.Target{Type: audit.TargetTeam, ID: teamID}, audit.Params{
+		"user_id": c.Param("user_id"),
+	}, err)
+	if err != nil {
+		return handleError(c, err, "Failed to remove team member")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Team member removed successfully",
+	})
+}
+
+// handleSetRepositoryTeam godoc
+// @Summary Share a repository with a team
+// @Description Assign a repository to a team, or clear the team to make it private to its owner. Requires the admin role on the repository and membership of the new team.
+// @Tags repositories
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Repository ID"
+// @Param request body SetRepositoryTeamRequest true "Team assignment"
+// @Success 200 {object} db.Repository
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/repositories/{id}/team [put]
+func (s *Server) handleSetRepositoryTeam(c echo.Context) error {
+	dbInstance, err := s.getDB()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Database not available",
+		})
+	}
+
+	var req SetRepositoryTeamRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+		})
+	}
+
+	repo, err := s.authorizeRepository(c, dbInstance, c.Param("id"), auth.ActionDelete)
+	if err != nil {
+		return err
+	}
+	if req.TeamID != "" {
+		if err := s.requireTeamRole(c, req.TeamID, db.TeamRoleDeveloper); err != nil {
+			return err
+		}
+	}
+
+	ctx := c.Request().Context()
+	repoRepo := db.NewRepositoryRepository(dbInstance)
+	err = repoRepo.SetTeam(ctx, repo.ID, req.TeamID)
+	audit.Record(ctx, audit.ActionRepositoryTeam, audit.Target{Type: audit.TargetRepository, ID: repo.ID, Name: repo.Name}, audit.Params{
+		"team_id":          req.TeamID,
+		"previous_team_id": repo.TeamID,
+	}, err)
+	if err != nil {
+		return handleError(c, err, "Failed to update repository")
+	}
+
+	updated, err := repoRepo.GetByID(ctx, repo.ID)
+	if err != nil {
+		return handleError(c, err, "Failed to get repository")
+	}
+	return c.JSON(http.StatusOK, updated)
+}
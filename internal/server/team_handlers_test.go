@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vibeman/internal/container"
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loginAs creates a user and returns a session token for them
+func loginAs(t *testing.T, s *Server, username string) string {
+	t.Helper()
+
+	if _, err := db.NewUserRepository(s.db).GetByUsername(context.Background(), username); err != nil {
+		_, err := s.authService.CreateUser(context.Background(), username, "", "password123", "")
+		require.NoError(t, err)
+	}
+
+	rec := doRequest(s, http.MethodPost, "/api/auth/login", `{"username":"`+username+`","password":"password123"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login SessionTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	return login.Token
+}
+
+// userID returns the ID of a user by name
+func userID(t *testing.T, s *Server, username string) string {
+	t.Helper()
+	user, err := db.NewUserRepository(s.db).GetByUsername(context.Background(), username)
+	require.NoError(t, err)
+	return user.ID
+}
+
+func TestTenancy_TeamScopedAccess(t *testing.T) {
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	ctx := context.Background()
+
+	bob := loginAs(t, s, "bob")
+	carol := loginAs(t, s, "carol")
+
+	repos := db.NewRepositoryRepository(s.db)
+	worktrees := db.NewWorktreeRepository(s.db)
+	require.NoError(t, repos.Create(ctx, &db.Repository{ID: "bob-repo", Path: "/repos/bob", Name: "bob-repo", OwnerID: userID(t, s, "bob")}))
+	require.NoError(t, repos.Create(ctx, &db.Repository{ID: "alice-repo", Path: "/repos/alice", Name: "alice-repo", OwnerID: userID(t, s, "alice")}))
+	require.NoError(t, repos.Create(ctx, &db.Repository{ID: "shared-repo", Path: "/repos/shared", Name: "shared-repo"}))
+	require.NoError(t, worktrees.Create(ctx, &db.Worktree{ID: "bob-wt", RepositoryID: "bob-repo", Name: "feature-bob", Branch: "feature-bob", Path: "/wt/bob", Status: db.StatusStopped}))
+	require.NoError(t, worktrees.Create(ctx, &db.Worktree{ID: "alice-wt", RepositoryID: "alice-repo", Name: "feature-alice", Branch: "feature-alice", Path: "/wt/alice", Status: db.StatusStopped}))
+
+	// Bob creates a team, becoming its admin, and adds Carol as a viewer
+	rec := doRequest(s, http.MethodPost, "/api/teams", `{"name":"platform"}`, bob)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var team db.Team
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &team))
+
+	rec = doRequest(s, http.MethodPost, "/api/teams/"+team.ID+"/members", `{"username":"carol","role":"viewer"}`, bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"username":"carol"`)
+
+	rec = doRequest(s, http.MethodPost, "/api/teams/"+team.ID+"/members", `{"username":"carol","role":"admin"}`, carol)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "viewers cannot manage members")
+
+	rec = doRequest(s, http.MethodPut, "/api/repositories/bob-repo/team", `{"team_id":"`+team.ID+`"}`, bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doRequest(s, http.MethodPut, "/api/repositories/bob-repo/team", `{"team_id":""}`, carol)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "viewers cannot reassign repositories")
+
+	// Carol sees worktrees of the team's repository, but not Alice's
+	rec = doRequest(s, http.MethodGet, "/api/worktrees", "", carol)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var wtList WorktreesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wtList))
+	require.Len(t, wtList.Worktrees, 1)
+	assert.Equal(t, "bob-wt", wtList.Worktrees[0].ID)
+
+	rec = doRequest(s, http.MethodGet, "/api/worktrees/bob-wt", "", carol)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/worktrees/alice-wt", "", carol)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Viewers cannot attach; the check happens before the WebSocket upgrade
+	rec = doRequest(s, http.MethodGet, "/api/ai/attach/feature-bob", "", carol)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Selecting the team limits results to its repositories
+	req := httptest.NewRequest(http.MethodGet, "/api/worktrees", nil)
+	req.Header.Set("Authorization", "Bearer "+bob)
+	req.Header.Set(TenantHeader, team.ID)
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wtList))
+	assert.Len(t, wtList.Worktrees, 1)
+
+	// Alice is a global admin and sees everything
+	rec = doRequest(s, http.MethodGet, "/api/worktrees", "", loginAs(t, s, "alice"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wtList))
+	assert.Len(t, wtList.Worktrees, 2)
+
+	// Selecting a team the caller does not belong to is rejected
+	req = httptest.NewRequest(http.MethodGet, "/api/worktrees", nil)
+	req.Header.Set("Authorization", "Bearer "+carol)
+	req.Header.Set(TenantHeader, "other-team")
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestTeams_ListScopedToMembership(t *testing.T) {
+	s := newAuthTestServer(t)
+	bob := loginAs(t, s, "bob")
+	carol := loginAs(t, s, "carol")
+
+	rec := doRequest(s, http.MethodPost, "/api/teams", `{"name":"platform"}`, bob)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var teams TeamsResponse
+	rec = doRequest(s, http.MethodGet, "/api/teams", "", bob)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &teams))
+	assert.Equal(t, 1, teams.Total)
+
+	rec = doRequest(s, http.MethodGet, "/api/teams", "", carol)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &teams))
+	assert.Equal(t, 0, teams.Total)
+
+	rec = doRequest(s, http.MethodGet, "/api/teams/missing/members", "", carol)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTenancy_ContainerAccess(t *testing.T) {
+	s := newAuthTestServer(t) // alice is the first user and a global admin
+	ctx := context.Background()
+	bob := loginAs(t, s, "bob")
+
+	repos := db.NewRepositoryRepository(s.db)
+	worktrees := db.NewWorktreeRepository(s.db)
+	require.NoError(t, repos.Create(ctx, &db.Repository{ID: "bob-repo", Path: "/repos/bob", Name: "bob-repo", OwnerID: userID(t, s, "bob")}))
+	require.NoError(t, repos.Create(ctx, &db.Repository{ID: "alice-repo", Path: "/repos/alice", Name: "alice-repo", OwnerID: userID(t, s, "alice")}))
+	require.NoError(t, worktrees.Create(ctx, &db.Worktree{ID: "bob-wt", RepositoryID: "bob-repo", Name: "feature", Branch: "feature", Path: "/wt/bob", Status: db.StatusRunning}))
+	require.NoError(t, worktrees.Create(ctx, &db.Worktree{ID: "alice-wt", RepositoryID: "alice-repo", Name: "feature", Branch: "feature", Path: "/wt/alice", Status: db.StatusRunning}))
+
+	var stopped []string
+	containerMgr := testutil.NewMockContainerManager()
+	containerMgr.ListReturn = []*container.Container{
+		{ID: "c-bob", Name: "bob-repo-feature", Repository: "bob-repo", Environment: "feature", Status: "running"},
+		{ID: "c-alice", Name: "alice-repo-feature", Repository: "alice-repo", Environment: "feature", Status: "running"},
+		{ID: "c-postgres", Name: "vibeman-postgres", Status: "running"},
+	}
+	containerMgr.StopFn = func(ctx context.Context, id string) error {
+		stopped = append(stopped, id)
+		return nil
+	}
+	s.containerMgr = containerMgr
+
+	// Bob only sees the containers of his worktrees
+	rec := doRequest(s, http.MethodGet, "/api/containers", "", bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list ContainersResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Containers, 1)
+	assert.Equal(t, "c-bob", list.Containers[0].ID)
+
+	rec = doRequest(s, http.MethodGet, "/api/containers", "", loginAs(t, s, "alice"))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Containers, 3)
+
+	// and cannot act on anyone else's
+	for _, id := range []string{"c-alice", "alice-repo-feature", "c-postgres"} {
+		rec = doRequest(s, http.MethodPost, "/api/containers/"+id+"/action", `{"action":"stop"}`, bob)
+		assert.Equal(t, http.StatusNotFound, rec.Code, id)
+		rec = doRequest(s, http.MethodGet, "/api/containers/"+id+"/logs", "", bob)
+		assert.Equal(t, http.StatusNotFound, rec.Code, id)
+		rec = doRequest(s, http.MethodDelete, "/api/containers/"+id, "", bob)
+		assert.Equal(t, http.StatusNotFound, rec.Code, id)
+	}
+	rec = doRequest(s, http.MethodPost, "/api/containers", `{"repository":"alice-repo","worktree":"feature","image":"alpine"}`, bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, stopped)
+
+	rec = doRequest(s, http.MethodPost, "/api/containers/bob-repo-feature/action", `{"action":"stop"}`, bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"c-bob"}, stopped)
+
+	// Shared services and the configuration are for admins
+	rec = doRequest(s, http.MethodPost, "/api/services/postgres/start", "", bob)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doRequest(s, http.MethodPost, "/api/services/postgres/stop", "", bob)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/services/postgres/logs", "", bob)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/api/config", "", bob)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Worktree names shared by repositories need the repository to tell them apart
+	rec = doRequest(s, http.MethodDelete, "/api/ai/sessions/feature/agent/share", "", loginAs(t, s, "alice"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(s, http.MethodDelete, "/api/ai/sessions/feature/agent/share?repository=alice-repo", "", loginAs(t, s, "alice"))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doRequest(s, http.MethodDelete, "/api/ai/sessions/feature/agent/share", "", bob)
+	assert.Equal(t, http.StatusOK, rec.Code, "only one of them is bob's")
+	rec = doRequest(s, http.MethodDelete, "/api/ai/sessions/alice-wt/agent/share", "", bob)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -2,14 +2,27 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"vibeman/internal/asciicast"
+	"vibeman/internal/auth"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
 	"vibeman/internal/logger"
+	"vibeman/internal/operations"
+	"vibeman/internal/telemetry"
 	"vibeman/internal/validation"
 
+	"github.com/creack/pty"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 )
@@ -53,7 +66,8 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
-// ClientMessage represents messages from client to server
+// ClientMessage represents messages from client to server, sent as text
+// frames. Binary frames carry raw terminal input.
 type ClientMessage struct {
 	Type string `json:"type"` // 'stdin', 'resize', 'ping'
 	Data string `json:"data,omitempty"`
@@ -63,9 +77,10 @@ type ClientMessage struct {
 
 // ServerMessage represents messages from server to client
 type ServerMessage struct {
-	Type     string `json:"type"` // 'stdout', 'stderr', 'exit', 'pong'
+	Type     string `json:"type"` // 'stdout', 'stderr', 'exit', 'pong', 'session'
 	Data     string `json:"data,omitempty"`
 	ExitCode *int   `json:"exitCode,omitempty"`
+	Role     string `json:"role,omitempty"` // 'writer' or 'viewer', sent with the session message
 }
 
 // TerminalSession manages a WebSocket terminal session
@@ -75,14 +90,40 @@ type TerminalSession struct {
 	cancel    context.CancelFunc
 	container string
 	worktree  string
+	command   []string // Command run in the container, a tmux client of the session
+	readOnly  bool     // Input from the client is ignored
+	binary    bool     // Output is sent as binary frames rather than stdout messages
+	size      *pty.Winsize
 	cmd       *exec.Cmd
+	tty       *os.File   // The PTY docker exec runs on
+	writeMu   sync.Mutex // Serializes writes to ws
+
+	recorderMu sync.Mutex
+	recorder   terminalRecorder // Set once the session's recording starts, for the client that may type
+}
+
+// terminalRecorder records what a client types into a terminal and how it
+// sizes it. What the terminal shows is recorded from the session itself.
+type terminalRecorder interface {
+	Input(data []byte)
+	Resize(cols, rows int)
 }
 
 // handleAIWebSocket handles WebSocket connections for AI container terminal access
 // @Summary WebSocket endpoint for AI container terminal
-// @Description Establish WebSocket connection for terminal access to AI containers
+// @Description Establish WebSocket connection for terminal access to AI containers. Connections attach to a persistent session that keeps running when they disconnect; the first connection that may type becomes the session's writer and later ones watch.
 // @Tags ai,websocket
-// @Param worktree path string true "Worktree name"
+// @Param worktree path string true "Worktree ID or name"
+// @Param repository query string false "Repository ID or name, needed when several repositories have a worktree of that name"
+// @Param mode query string false "What to run: shell (default) or agent, the agent the repository configures"
+// @Param agent query string false "Agent to run instead of the configured one, e.g. codex; implies mode=agent"
+// @Param ask query bool false "Let the agent ask before acting instead of passing its permission flags"
+// @Param session query string false "Session to attach to, started if it does not exist; defaults to the agent's name, agent or shell"
+// @Param readonly query bool false "Watch the session without typing in it"
+// @Param shell query string false "Shell to open instead of the repository's, e.g. bash; only for mode=shell"
+// @Param cols query int false "Initial terminal width, 80 by default"
+// @Param rows query int false "Initial terminal height, 24 by default"
+// @Param binary query bool false "Send output as binary frames of raw terminal bytes instead of stdout messages"
 // @Success 101 {string} string "Switching Protocols"
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
@@ -96,6 +137,29 @@ func (s *Server) handleAIWebSocket(c echo.Context) error {
 		})
 	}
 
+	worktree, repo, err := s.findAISessionWorktree(c, worktreeName, auth.ActionAttach)
+	if err != nil {
+		return err
+	}
+
+	command, err := s.attachCommand(c, worktree)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	sessionName := c.QueryParam("session")
+	if sessionName == "" {
+		sessionName = operations.DefaultAISessionName(c.QueryParam("mode"), c.QueryParam("agent"))
+	}
+	if err := operations.ValidateAISessionName(sessionName); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	readOnly, _ := strconv.ParseBool(c.QueryParam("readonly"))
+
 	// Upgrade HTTP connection to WebSocket
 	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
@@ -123,33 +187,81 @@ func (s *Server) handleAIWebSocket(c echo.Context) error {
 		return err
 	}
 
+	// Only the worktree's own AI container may be attached to
 	var aiContainerName string
+	expected := operations.AIContainerName(repo.Name, worktree.Name)
 	for _, container := range containers {
-		if container.Type == "ai" && strings.Contains(container.Name, worktreeName) {
+		if container.Name == expected {
 			// Check if container is running
 			status := strings.ToLower(container.Status)
 			if strings.Contains(status, "running") || strings.Contains(status, "up") {
 				aiContainerName = container.Name
-				break
 			}
+			break
 		}
 	}
 
 	if aiContainerName == "" {
 		ws.WriteJSON(ServerMessage{
 			Type: "stderr",
-			Data: fmt.Sprintf("No running AI container found for worktree: %s\r\n", worktreeName),
+			Data: fmt.Sprintf("No running AI container found for worktree: %s\r\n", worktree.Name),
 		})
-		return fmt.Errorf("no AI container found for worktree: %s", worktreeName)
+		return fmt.Errorf("no AI container found for worktree: %s", worktree.Name)
 	}
 
 	logger.WithFields(logger.Fields{
-		"worktree":  worktreeName,
+		"worktree":  worktree.Name,
 		"container": aiContainerName,
+		"session":   sessionName,
+		"command":   command[0],
 	}).Info("Starting WebSocket terminal session")
 
+	return s.serveAISession(c, ws, aiContainerName, worktree.Name, sessionName, command, !readOnly, operations.AIRecordingSourceWeb)
+}
+
+// handleSharedAIWebSocket handles WebSocket connections made with a share link
+// @Summary WebSocket endpoint for a shared AI terminal session
+// @Description Watch a persistent AI terminal session through a read-only share link. No login is needed and input is ignored.
+// @Tags ai,websocket
+// @Param token path string true "Share link token"
+// @Param cols query int false "Initial terminal width, 80 by default"
+// @Param rows query int false "Initial terminal height, 24 by default"
+// @Param binary query bool false "Send output as binary frames of raw terminal bytes instead of stdout messages"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 404 {object} ErrorResponse
+// @Router /api/ai/shared/{token} [get]
+func (s *Server) handleSharedAIWebSocket(c echo.Context) error {
+	share, ok := s.aiSessions.lookupShare(c.Param("token"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "share link not found or expired")
+	}
+
+	// Upgrade HTTP connection to WebSocket
+	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.WithError(err).Error("Failed to upgrade WebSocket connection")
+		return err
+	}
+	defer ws.Close()
+
+	logger.WithFields(logger.Fields{
+		"worktree":  share.worktree,
+		"container": share.container,
+		"session":   share.session,
+	}).Info("Starting shared WebSocket terminal session")
+
+	return s.serveAISession(c, ws, share.container, share.worktree, share.session, nil, false, operations.AIRecordingSourceShared)
+}
+
+// serveAISession bridges a WebSocket connection to a persistent session in an
+// AI container, starting command in it if the session does not exist. The
+// connection may type only if wantWrite is set and no other connection is the
+// session's writer. The session is recorded once, however many connections
+// it has, noting source as where the first came from; what the writer types
+// goes into that recording.
+func (s *Server) serveAISession(c echo.Context, ws *websocket.Conn, container, worktree, sessionName string, command []string, wantWrite bool, source string) error {
 	// Validate container name to prevent command injection
-	if err := validation.ContainerID(aiContainerName); err != nil {
+	if err := validation.ContainerID(container); err != nil {
 		ws.WriteJSON(ServerMessage{
 			Type: "stderr",
 			Data: "Invalid container name\r\n",
@@ -157,54 +269,164 @@ func (s *Server) handleAIWebSocket(c echo.Context) error {
 		return err
 	}
 
+	key := aiSessionKey(container, sessionName)
+	clientID, writer, err := s.aiSessions.join(key, wantWrite)
+	if err != nil {
+		ws.WriteJSON(ServerMessage{
+			Type: "stderr",
+			Data: "Failed to join session\r\n",
+		})
+		return err
+	}
+	defer s.aiSessions.leave(key, clientID)
+
+	role := "viewer"
+	tmuxCommand := operations.AISessionViewCommand(sessionName)
+	if writer {
+		role = "writer"
+		tmuxCommand = operations.AISessionCommand(sessionName, command)
+	}
+	binary, _ := strconv.ParseBool(c.QueryParam("binary"))
+
 	// Create terminal session
 	ctx, cancel := context.WithCancel(c.Request().Context())
 	session := &TerminalSession{
 		ws:        ws,
 		ctx:       ctx,
 		cancel:    cancel,
-		container: aiContainerName,
-		worktree:  worktreeName,
+		container: container,
+		worktree:  worktree,
+		command:   tmuxCommand,
+		readOnly:  !writer,
+		binary:    binary,
+		size:      terminalSize(c),
 	}
+	session.send(ServerMessage{Type: "session", Data: sessionName, Role: role})
+
+	// The recording waits for the session, which the writer's tmux client
+	// may still be starting
+	go func() {
+		recording := s.recordAISession(ctx, container, worktree, sessionName, source)
+		if writer && recording != nil {
+			session.setRecorder(recording)
+		}
+	}()
 
-	// Handle WebSocket terminal session
+	// Handle WebSocket terminal session. Disconnecting only ends this tmux
+	// client; the session and what runs in it keep going.
 	return session.handleSession()
 }
 
-// handleSession manages the WebSocket terminal session
-func (ts *TerminalSession) handleSession() error {
-	defer ts.cancel()
+// recordAISession returns the recording of an AI terminal session, starting
+// it if the session is not being recorded yet. Failing to record does not
+// keep anyone from the terminal, so errors are only logged and nil is
+// returned.
+func (s *Server) recordAISession(ctx context.Context, container, worktree, sessionName, source string) *operations.AISessionRecording {
+	repoName, ok := operations.AIContainerRepository(container, worktree)
+	if !ok && s.db != nil {
+		worktrees, _ := db.NewWorktreeRepository(s.db).List(ctx, "", "")
+		for i := range worktrees {
+			if worktrees[i].Name != worktree {
+				continue
+			}
+			if repo, err := db.NewRepositoryRepository(s.db).GetByID(ctx, worktrees[i].RepositoryID); err == nil {
+				repoName, ok = repo.Name, true
+			}
+			break
+		}
+	}
+	if !ok {
+		logger.WithField("container", container).Warn("Not recording AI terminal session of unknown repository")
+		return nil
+	}
+	return s.aiRecordings.record(aiSessionKey(container, sessionName), func() (*operations.AISessionRecording, error) {
+		// Recordings outlive the connection that starts them
+		return operations.RecordAISession(context.Background(), repoName, worktree, container, sessionName, source)
+	})
+}
 
-	// Start docker exec command for shell access
-	ts.cmd = exec.CommandContext(ts.ctx, "docker", "exec", "-it", ts.container, "/bin/zsh")
+// attachCommand returns what an attach request runs in the AI container: a
+// shell, or the agent the worktree's repository configures or the request
+// names
+func (s *Server) attachCommand(c echo.Context, worktree *db.Worktree) ([]string, error) {
+	mode := c.QueryParam("mode")
+	agentName := c.QueryParam("agent")
+	shell := false
+	if agentName == "" {
+		switch mode {
+		case "", "shell":
+			shell = true
+		case "agent":
+		default:
+			return nil, fmt.Errorf("invalid mode %q, must be shell or agent", mode)
+		}
+	}
 
-	// Create pseudo-terminal
-	stdin, err := ts.cmd.StdinPipe()
-	if err != nil {
-		ts.sendError("Failed to create stdin pipe")
-		return err
+	if override := c.QueryParam("shell"); shell && override != "" {
+		if err := validation.ShellCommand(override); err != nil {
+			return nil, err
+		}
+		return []string{override}, nil
 	}
-	defer stdin.Close()
 
-	stdout, err := ts.cmd.StdoutPipe()
-	if err != nil {
-		ts.sendError("Failed to create stdout pipe")
-		return err
+	aiConfig := &config.AIConfig{}
+	if repoConfig, err := config.ParseRepositoryConfig(worktree.Path); err == nil {
+		aiConfig = &repoConfig.Repository.AI
 	}
-	defer stdout.Close()
 
-	stderr, err := ts.cmd.StderrPipe()
+	if shell {
+		return aiConfig.ShellCommand(), nil
+	}
+	_, agent, err := aiConfig.ResolveAgent(agentName)
 	if err != nil {
-		ts.sendError("Failed to create stderr pipe")
-		return err
+		return nil, err
+	}
+	ask, _ := strconv.ParseBool(c.QueryParam("ask"))
+	return agent.Launch(ask), nil
+}
+
+// outputDrainTimeout bounds how long a session waits for the output of a
+// command that exited before reporting its exit
+const outputDrainTimeout = time.Second
+
+// terminalSize returns the initial terminal size a request asks for with the
+// cols and rows query parameters, 80x24 by default
+func terminalSize(c echo.Context) *pty.Winsize {
+	size := &pty.Winsize{Cols: 80, Rows: 24}
+	if cols, err := strconv.ParseUint(c.QueryParam("cols"), 10, 16); err == nil && cols > 0 {
+		size.Cols = uint16(cols)
+	}
+	if rows, err := strconv.ParseUint(c.QueryParam("rows"), 10, 16); err == nil && rows > 0 {
+		size.Rows = uint16(rows)
+	}
+	return size
+}
+
+// handleSession manages the WebSocket terminal session
+func (ts *TerminalSession) handleSession() error {
+	defer ts.cancel()
+
+	// docker exec -t gives the command a TTY in the container. Running docker
+	// itself on a PTY makes it a real terminal whose size docker forwards to
+	// that TTY whenever the PTY is resized.
+	args := append([]string{"exec", "-it", "-e", "TERM=xterm-256color", ts.container}, ts.command...)
+	ts.cmd = exec.CommandContext(ts.ctx, "docker", args...)
+
+	size := ts.size
+	if size == nil {
+		size = &pty.Winsize{Cols: 80, Rows: 24}
 	}
-	defer stderr.Close()
 
 	// Start the command
-	if err := ts.cmd.Start(); err != nil {
-		ts.sendError(fmt.Sprintf("Failed to start shell: %v", err))
+	endSpan := telemetry.TrackCommand(ts.ctx, ts.cmd)
+	tty, err := pty.StartWithSize(ts.cmd, size)
+	if err != nil {
+		endSpan(err)
+		ts.sendError(fmt.Sprintf("Failed to start %s: %v", ts.command[0], err))
 		return err
 	}
+	ts.tty = tty
+	defer tty.Close()
 
 	// Ensure command is killed if we exit early
 	defer func() {
@@ -213,19 +435,43 @@ func (ts *TerminalSession) handleSession() error {
 		}
 	}()
 
-	// Handle stdout/stderr output
-	go ts.handleOutput(stdout, "stdout")
-	go ts.handleOutput(stderr, "stderr")
+	// Handle terminal output
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		ts.handleOutput()
+	}()
 
-	// Handle WebSocket messages
-	go ts.handleWebSocketMessages(stdin)
+	// Handle WebSocket messages. They may be recorded, so the session only
+	// ends, and its recording is only closed, once they have been handled.
+	messagesDone := make(chan struct{})
+	go func() {
+		defer close(messagesDone)
+		ts.handleWebSocketMessages()
+	}()
+	defer func() {
+		// Reading blocks until the client sends something; an expired
+		// deadline ends it
+		ts.ws.SetReadDeadline(time.Now())
+		<-messagesDone
+	}()
 
 	// Wait for command to finish
 	go func() {
-		if err := ts.cmd.Wait(); err != nil {
+		err := ts.cmd.Wait()
+		endSpan(err)
+
+		// Send what the command wrote before it exited. Processes it left
+		// in the background may hold the terminal open, so don't wait long.
+		select {
+		case <-outputDone:
+		case <-time.After(outputDrainTimeout):
+		}
+
+		if err != nil {
 			if exitError, ok := err.(*exec.ExitError); ok {
 				exitCode := exitError.ExitCode()
-				ts.ws.WriteJSON(ServerMessage{
+				ts.send(ServerMessage{
 					Type:     "exit",
 					ExitCode: &exitCode,
 				})
@@ -234,7 +480,7 @@ func (ts *TerminalSession) handleSession() error {
 			}
 		} else {
 			exitCode := 0
-			ts.ws.WriteJSON(ServerMessage{
+			ts.send(ServerMessage{
 				Type:     "exit",
 				ExitCode: &exitCode,
 			})
@@ -247,75 +493,151 @@ func (ts *TerminalSession) handleSession() error {
 	return nil
 }
 
-// handleOutput reads from stdout/stderr and sends to WebSocket
-func (ts *TerminalSession) handleOutput(pipe interface{}, outputType string) {
-	buffer := make([]byte, 4096)
-	reader := pipe.(interface{ Read([]byte) (int, error) })
+// handleOutput reads from the terminal and sends it to the WebSocket, as
+// binary frames or stdout messages
+func (ts *TerminalSession) handleOutput() {
+	buffer := make([]byte, 32*1024)
+	var pending []byte
 
 	for {
-		select {
-		case <-ts.ctx.Done():
-			return
-		default:
-			n, err := reader.Read(buffer)
-			if err != nil {
-				return
-			}
-			if n > 0 {
-				ts.ws.WriteJSON(ServerMessage{
-					Type: outputType,
-					Data: string(buffer[:n]),
-				})
+		n, err := ts.tty.Read(buffer)
+		if n > 0 {
+			if ts.binary {
+				ts.sendBinary(buffer[:n])
+			} else {
+				// JSON strings must be valid UTF-8, so characters split
+				// across reads are held back until they are complete
+				var complete []byte
+				complete, pending = asciicast.SplitUTF8(append(pending, buffer[:n]...))
+				if len(complete) > 0 {
+					ts.send(ServerMessage{
+						Type: "stdout",
+						Data: string(complete),
+					})
+				}
 			}
 		}
+		if err != nil {
+			// Reading fails once the command exits and the PTY closes
+			return
+		}
 	}
 }
 
-// handleWebSocketMessages reads from WebSocket and handles client messages
-func (ts *TerminalSession) handleWebSocketMessages(stdin interface{ Write([]byte) (int, error) }) {
-	writer := stdin
-
+// handleWebSocketMessages reads from WebSocket and handles client messages.
+// Binary frames are terminal input; text frames are JSON client messages.
+func (ts *TerminalSession) handleWebSocketMessages() {
 	for {
-		select {
-		case <-ts.ctx.Done():
+		messageType, data, err := ts.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				logger.WithError(err).Error("WebSocket read error")
+			}
+			ts.cancel()
 			return
-		default:
-			var msg ClientMessage
-			if err := ts.ws.ReadJSON(&msg); err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					logger.WithError(err).Error("WebSocket read error")
-				}
-				ts.cancel()
+		}
+
+		if messageType == websocket.BinaryMessage {
+			if !ts.writeInput(data) {
 				return
 			}
+			continue
+		}
 
-			switch msg.Type {
-			case "stdin":
-				if _, err := writer.Write([]byte(msg.Data)); err != nil {
-					logger.WithError(err).Error("Failed to write to stdin")
-					ts.cancel()
-					return
-				}
-			case "resize":
-				// Handle terminal resize (docker exec doesn't support this directly)
-				// This would need a more sophisticated PTY implementation
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			logger.WithError(err).Warn("Invalid WebSocket message")
+			continue
+		}
+
+		switch msg.Type {
+		case "stdin":
+			if !ts.writeInput([]byte(msg.Data)) {
+				return
+			}
+		case "resize":
+			// Viewers watch at the writer's size rather than set it
+			if ts.readOnly {
+				continue
+			}
+			if msg.Cols <= 0 || msg.Rows <= 0 || msg.Cols > math.MaxUint16 || msg.Rows > math.MaxUint16 {
 				logger.WithFields(logger.Fields{
 					"cols": msg.Cols,
 					"rows": msg.Rows,
-				}).Debug("Terminal resize requested")
-			case "ping":
-				ts.ws.WriteJSON(ServerMessage{Type: "pong"})
-			default:
-				logger.WithField("type", msg.Type).Warn("Unknown message type")
+				}).Warn("Invalid terminal size")
+				continue
+			}
+			// docker notices the new size and resizes the TTY in the
+			// container, which signals the program running there
+			if err := pty.Setsize(ts.tty, &pty.Winsize{Cols: uint16(msg.Cols), Rows: uint16(msg.Rows)}); err != nil {
+				logger.WithError(err).Warn("Failed to resize terminal")
+				continue
+			}
+			if recorder := ts.currentRecorder(); recorder != nil {
+				recorder.Resize(msg.Cols, msg.Rows)
 			}
+		case "ping":
+			ts.send(ServerMessage{Type: "pong"})
+		default:
+			logger.WithField("type", msg.Type).Warn("Unknown message type")
 		}
 	}
 }
 
+// setRecorder records what the client types and how it sizes the terminal
+// from now on
+func (ts *TerminalSession) setRecorder(recorder terminalRecorder) {
+	ts.recorderMu.Lock()
+	defer ts.recorderMu.Unlock()
+	ts.recorder = recorder
+}
+
+// currentRecorder returns the recorder set for the client, if any
+func (ts *TerminalSession) currentRecorder() terminalRecorder {
+	ts.recorderMu.Lock()
+	defer ts.recorderMu.Unlock()
+	return ts.recorder
+}
+
+// writeInput writes client input to the terminal, dropping it for read-only
+// sessions. It reports whether the session should keep going.
+func (ts *TerminalSession) writeInput(data []byte) bool {
+	if ts.readOnly {
+		return true
+	}
+	// Recorded first: the program may consume the input and exit, ending
+	// the recording, before the write returns
+	if recorder := ts.currentRecorder(); recorder != nil {
+		recorder.Input(data)
+	}
+	if _, err := ts.tty.Write(data); err != nil {
+		logger.WithError(err).Error("Failed to write to terminal")
+		ts.cancel()
+		return false
+	}
+	return true
+}
+
+// send writes a message to the WebSocket client. Connections allow one
+// writer at a time, and output, exit and pong messages come from different
+// goroutines.
+func (ts *TerminalSession) send(msg ServerMessage) {
+	ts.writeMu.Lock()
+	defer ts.writeMu.Unlock()
+	ts.ws.WriteJSON(msg)
+}
+
+// sendBinary writes terminal output to the WebSocket client as a binary frame
+func (ts *TerminalSession) sendBinary(data []byte) {
+	ts.writeMu.Lock()
+	defer ts.writeMu.Unlock()
+	ts.ws.WriteMessage(websocket.BinaryMessage, data)
+}
+
 // sendError sends an error message to the WebSocket client
 func (ts *TerminalSession) sendError(message string) {
-	ts.ws.WriteJSON(ServerMessage{
+	ts.send(ServerMessage{
 		Type: "stderr",
 		Data: message + "\r\n",
 	})
-}
\ No newline at end of file
+}
@@ -5,14 +5,23 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"vibeman/internal/asciicast"
 	"vibeman/internal/container"
+	"vibeman/internal/db"
+	"vibeman/internal/testutil"
 
+	"github.com/creack/pty"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockContainerForWebSocket for WebSocket tests
@@ -209,4 +218,181 @@ func TestTerminalSessionContext(t *testing.T) {
 // Helper function for test
 func intPtr(i int) *int {
 	return &i
-}
\ No newline at end of file
+}
+func TestAttachCommand(t *testing.T) {
+	database := testutil.SetupTestDB(t)
+	s := NewWithDependencies(DefaultConfig(), nil, nil, nil, database)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vibeman.toml"), []byte(`
+[repository]
+name = "app"
+
+[repository.ai]
+agent = "codex"
+`), 0644))
+	ctx := context.Background()
+	require.NoError(t, db.NewRepositoryRepository(database).Create(ctx, &db.Repository{ID: "app", Path: dir, Name: "app"}))
+	worktree := &db.Worktree{ID: "wt", RepositoryID: "app", Name: "feature", Branch: "feature", Path: dir, Status: db.StatusRunning}
+	require.NoError(t, db.NewWorktreeRepository(database).Create(ctx, worktree))
+
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr string
+	}{
+		{"", []string{"/bin/zsh"}, ""},
+		{"?mode=shell", []string{"/bin/zsh"}, ""},
+		{"?mode=agent", []string{"codex", "--dangerously-bypass-approvals-and-sandbox"}, ""},
+		{"?mode=agent&ask=true", []string{"codex"}, ""},
+		{"?agent=gemini", []string{"gemini", "--yolo"}, ""},
+		{"?agent=copilot", nil, `unknown agent "copilot"`},
+		{"?mode=vnc", nil, `invalid mode "vnc"`},
+		{"?shell=bash", []string{"bash"}, ""},
+		{"?shell=python", nil, "must be a valid shell path"},
+		{"?mode=agent&shell=bash", []string{"codex", "--dangerously-bypass-approvals-and-sandbox"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/ai/attach/feature"+tt.query, nil)
+			c := s.echo.NewContext(req, httptest.NewRecorder())
+			command, err := s.attachCommand(c, worktree)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, command)
+		})
+	}
+}
+
+func TestTerminalSession_PTY(t *testing.T) {
+	// A fake docker that reports its terminal size before and after reading
+	// a line, as a full-screen program would
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker"), []byte("#!/bin/sh\nstty size\nread line\nstty size\necho \"got $line\"\n"), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	recording, err := os.Create(filepath.Join(dir, "session.cast"))
+	require.NoError(t, err)
+	recorder, err := asciicast.NewRecorder(recording, asciicast.Header{Width: 100, Height: 30})
+	require.NoError(t, err)
+
+	served := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(served)
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer ws.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		session := &TerminalSession{
+			ws:        ws,
+			ctx:       ctx,
+			cancel:    cancel,
+			container: "test-container",
+			command:   []string{"/bin/zsh"},
+			size:      &pty.Winsize{Cols: 100, Rows: 30},
+			recorder:  recorder,
+		}
+		session.handleSession()
+		recorder.Close()
+	}))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(10*time.Second)))
+
+	var output strings.Builder
+	readUntil := func(want string) *ServerMessage {
+		for {
+			var msg ServerMessage
+			require.NoError(t, ws.ReadJSON(&msg), "waiting for %q in %q", want, output.String())
+			output.WriteString(msg.Data)
+			if msg.Type == "exit" || strings.Contains(output.String(), want) {
+				return &msg
+			}
+		}
+	}
+
+	readUntil("30 100")
+	require.NoError(t, ws.WriteJSON(ClientMessage{Type: "resize", Cols: 120, Rows: 40}))
+	// Input may also be sent as binary frames
+	require.NoError(t, ws.WriteMessage(websocket.BinaryMessage, []byte("hello\r")))
+	readUntil("got hello")
+	assert.Contains(t, output.String(), "40 120")
+
+	exit := readUntil("\x00")
+	require.Equal(t, "exit", exit.Type)
+	require.NotNil(t, exit.ExitCode)
+	assert.Equal(t, 0, *exit.ExitCode)
+
+	// Connections record what is typed and how the terminal is sized; what
+	// it shows is recorded once from the session itself
+	<-served
+	data, err := os.ReadFile(filepath.Join(dir, "session.cast"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"r","120x40"`)
+	assert.Contains(t, string(data), `"i","hello\r"`)
+	assert.NotContains(t, string(data), `"o",`)
+}
+
+func TestTerminalSession_ReadOnlyResize(t *testing.T) {
+	// A fake docker that reports its terminal size twice, a while apart
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker"), []byte("#!/bin/sh\nstty size\nsleep 1\nstty size\necho done\n"), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer ws.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		session := &TerminalSession{
+			ws:        ws,
+			ctx:       ctx,
+			cancel:    cancel,
+			container: "test-container",
+			command:   []string{"/bin/zsh"},
+			readOnly:  true,
+			size:      &pty.Winsize{Cols: 100, Rows: 30},
+		}
+		session.handleSession()
+	}))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(10*time.Second)))
+
+	var output strings.Builder
+	readUntil := func(want string) {
+		for !strings.Contains(output.String(), want) {
+			var msg ServerMessage
+			require.NoError(t, ws.ReadJSON(&msg), "waiting for %q in %q", want, output.String())
+			output.WriteString(msg.Data)
+		}
+	}
+
+	// Viewers cannot resize the terminal
+	readUntil("30 100")
+	require.NoError(t, ws.WriteJSON(ClientMessage{Type: "resize", Cols: 120, Rows: 40}))
+	readUntil("done")
+	assert.Equal(t, 2, strings.Count(output.String(), "30 100"))
+	assert.NotContains(t, output.String(), "40 120")
+}
+
+func TestTerminalSize(t *testing.T) {
+	e := echo.New()
+	size := terminalSize(e.NewContext(httptest.NewRequest(http.MethodGet, "/?cols=132&rows=50", nil), httptest.NewRecorder()))
+	assert.Equal(t, pty.Winsize{Cols: 132, Rows: 50}, *size)
+
+	size = terminalSize(e.NewContext(httptest.NewRequest(http.MethodGet, "/?cols=0&rows=100000", nil), httptest.NewRecorder()))
+	assert.Equal(t, pty.Winsize{Cols: 80, Rows: 24}, *size)
+}
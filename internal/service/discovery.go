@@ -0,0 +1,577 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"vibeman/internal/config"
+	"vibeman/internal/constants"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EnvFile is written to each worktree with the connection details of the
+// services it uses, e.g. for use as a compose env_file
+const EnvFile = ".env.vibeman"
+
+// Service types discovery recognizes besides the provisionable ones
+const (
+	typeMySQL   = "mysql"
+	typeMongoDB = "mongodb"
+)
+
+// defaultServicePorts are the ports services of each type listen on inside
+// their container
+var defaultServicePorts = map[string]int{
+	config.ProvisionPostgres: defaultPostgresPort,
+	config.ProvisionRedis:    defaultRedisPort,
+	config.ProvisionS3:       defaultS3Port,
+	typeMySQL:                3306,
+	typeMongoDB:              27017,
+}
+
+// Endpoint describes how to reach a running service
+type Endpoint struct {
+	Name      string         `json:"name"`
+	Type      string         `json:"type,omitempty"`
+	Host      string         `json:"host"`
+	Port      int            `json:"port"`
+	Ports     map[string]int `json:"ports,omitempty"` // Container port -> published port
+	User      string         `json:"user,omitempty"`
+	Password  string         `json:"password,omitempty"`
+	Database  string         `json:"database,omitempty"`
+	Bucket    string         `json:"bucket,omitempty"`
+	KeyPrefix string         `json:"key_prefix,omitempty"`
+	Region    string         `json:"region,omitempty"`
+	URL       string         `json:"url,omitempty"`
+}
+
+// containerDetails is what discovery reads from a service's container
+type containerDetails struct {
+	Image   string
+	Env     map[string]string
+	Ports   map[int]int // Container port -> published host port
+	Volumes []string    // Named volumes mounted into the container
+}
+
+// inspectFunc inspects a service's running container
+type inspectFunc func(ctx context.Context, cfg config.ServiceConfig) (*containerDetails, error)
+
+// inspectContainer inspects a service's running container
+func inspectContainer(ctx context.Context, cfg config.ServiceConfig) (*containerDetails, error) {
+	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", cfg.ComposeFile, "ps", "-q", cfg.Service)
+	if cfg.IsInline() {
+		// Like compose ps, only list the container while it runs
+		cmd = exec.CommandContext(ctx, "docker", "ps", "-q", "--filter", "name=^"+cfg.Service+"$")
+	}
+	output, err := telemetry.CombinedOutput(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container ID: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	containerID := strings.TrimSpace(string(output))
+	if containerID == "" {
+		return nil, fmt.Errorf("service %s is not running", cfg.Service)
+	}
+
+	cmd = exec.CommandContext(ctx, "docker", "inspect", containerID)
+	output, err = telemetry.Output(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", containerID, err)
+	}
+	return parseInspect(output)
+}
+
+// parseInspect parses the output of docker inspect
+func parseInspect(output []byte) (*containerDetails, error) {
+	var inspected []struct {
+		Config struct {
+			Image string
+			Env   []string
+		}
+		NetworkSettings struct {
+			Ports map[string][]struct {
+				HostIp   string
+				HostPort string
+			}
+		}
+		Mounts []struct {
+			Type string
+			Name string
+		}
+	}
+	if err := json.Unmarshal(output, &inspected); err != nil {
+		return nil, fmt.Errorf("failed to parse docker inspect output: %w", err)
+	}
+	if len(inspected) == 0 {
+		return nil, fmt.Errorf("container not found")
+	}
+
+	details := &containerDetails{
+		Image: inspected[0].Config.Image,
+		Env:   make(map[string]string, len(inspected[0].Config.Env)),
+		Ports: map[int]int{},
+	}
+	for _, pair := range inspected[0].Config.Env {
+		if name, value, ok := strings.Cut(pair, "="); ok {
+			details.Env[name] = value
+		}
+	}
+	for port, bindings := range inspected[0].NetworkSettings.Ports {
+		containerPort, err := strconv.Atoi(strings.TrimSuffix(port, "/tcp"))
+		if err != nil || len(bindings) == 0 {
+			continue // UDP and unpublished ports
+		}
+		if hostPort, err := strconv.Atoi(bindings[0].HostPort); err == nil {
+			details.Ports[containerPort] = hostPort
+		}
+	}
+	for _, mount := range inspected[0].Mounts {
+		if mount.Type == "volume" {
+			details.Volumes = append(details.Volumes, mount.Name)
+		}
+	}
+	return details, nil
+}
+
+// ServiceEndpoints resolves how to reach each of the named services from
+// their running containers: the published port, credentials from the
+// container's environment and, when worktreeID is set, the worktree's own
+// provisioned database, key prefix or bucket. Services that cannot be
+// resolved, e.g. because they are not running, are left out and reported
+// in the returned error.
+func (m *Manager) ServiceEndpoints(ctx context.Context, worktreeID string, services []string) (endpoints []*Endpoint, err error) {
+	ctx, span := telemetry.Start(ctx, "service.ServiceEndpoints", attribute.String("vibeman.worktree_id", worktreeID))
+	defer func() { telemetry.End(span, err) }()
+
+	resources := map[string]*db.ServiceResource{}
+	if worktreeID != "" && m.database != nil {
+		list, err := db.NewServiceResourceRepository(m.database).ListByWorktree(ctx, worktreeID)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range list {
+			resources[resource.Service] = resource
+		}
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(slices.Values(services)) {
+		serviceConfig, err := m.serviceConfig(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		endpoint, err := m.resolveEndpoint(ctx, name, serviceConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to resolve service %s: %w", name, err))
+			continue
+		}
+		if resource, ok := resources[name]; ok {
+			endpoint.applyResource(resource)
+		}
+		endpoint.URL = endpoint.buildURL()
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, errors.Join(errs...)
+}
+
+// ServiceEnv returns the environment a worktree uses to reach the named
+// services: VIBEMAN_SERVICE_<NAME>_* variables, a JSON VIBEMAN_SERVICES
+// document and the variables of the worktree's provisioned resources. With
+// inContainer, services published on the loopback interface are addressed
+// through the Docker host gateway instead. Services that cannot be resolved
+// are left out and reported in the returned error.
+func (m *Manager) ServiceEnv(ctx context.Context, worktreeID string, services []string, inContainer bool) (map[string]string, error) {
+	env := map[string]string{}
+	if worktreeID != "" && m.database != nil {
+		resources, err := db.NewServiceResourceRepository(m.database).ListByWorktree(ctx, worktreeID)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			if slices.Contains(services, resource.Service) {
+				maps.Copy(env, resource.Env)
+			}
+		}
+	}
+
+	endpoints, err := m.ServiceEndpoints(ctx, worktreeID, services)
+	maps.Copy(env, EndpointEnv(endpoints, inContainer))
+	return env, err
+}
+
+// EndpointEnv converts endpoints into environment variables, e.g.
+// VIBEMAN_SERVICE_POSTGRES_URL, plus VIBEMAN_SERVICES holding all of them
+// as a JSON object keyed by service name. A version of a service is named
+// after the service.
+func EndpointEnv(endpoints []*Endpoint, inContainer bool) map[string]string {
+	env := map[string]string{}
+	document := make(map[string]*Endpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		if inContainer {
+			endpoint = endpoint.forContainer()
+		}
+		service, _ := config.SplitVersionedName(endpoint.Name)
+		document[service] = endpoint
+
+		prefix := "VIBEMAN_SERVICE_" + envName(endpoint.Name) + "_"
+		vars := map[string]string{
+			"TYPE":       endpoint.Type,
+			"HOST":       endpoint.Host,
+			"PORT":       strconv.Itoa(endpoint.Port),
+			"USER":       endpoint.User,
+			"PASSWORD":   endpoint.Password,
+			"DATABASE":   endpoint.Database,
+			"BUCKET":     endpoint.Bucket,
+			"KEY_PREFIX": endpoint.KeyPrefix,
+			"REGION":     endpoint.Region,
+			"URL":        endpoint.URL,
+		}
+		for key, value := range vars {
+			if value != "" {
+				env[prefix+key] = value
+			}
+		}
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to encode VIBEMAN_SERVICES")
+		return env
+	}
+	env["VIBEMAN_SERVICES"] = string(data)
+	return env
+}
+
+// WriteEnvFile writes env to the worktree's EnvFile. The file holds
+// credentials, so only the owner can read it, and git ignores it so it is
+// never committed.
+func WriteEnvFile(ctx context.Context, dir string, env map[string]string) error {
+	if err := ExcludeFromGit(ctx, dir, EnvFile); err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	content.WriteString("# Generated by vibeman: connection details for this worktree's services.\n")
+	content.WriteString("# Rewritten whenever they start or restart; do not edit.\n")
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		content.WriteString(key + "=" + quoteEnvValue(env[key]) + "\n")
+	}
+
+	return WriteWorktreeFile(dir, EnvFile, []byte(content.String()), constants.SecureFilePermissions)
+}
+
+// WriteWorktreeFile replaces the file at name, relative to the worktree dir,
+// with data. Agents can write to worktrees, so it refuses to write through a
+// symlink or over anything but a regular file, and data goes to a temporary
+// file renamed over the old one, leaving the file with perm whatever mode it
+// had before.
+func WriteWorktreeFile(dir, name string, data []byte, perm os.FileMode) error {
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("%s must be relative to the worktree", name)
+	}
+	path := filepath.Join(dir, name)
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", filepath.Dir(path), err)
+	}
+	if rel, err := filepath.Rel(root, parent); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("refusing to write %s: its directory is outside the worktree", path)
+	}
+	if info, err := os.Lstat(path); err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("refusing to write %s: it is not a regular file", path)
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check %s: %w", path, err)
+	}
+
+	f, err := os.CreateTemp(parent, "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(parent, filepath.Base(name)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ExcludeFromGit adds a file at the top of a checkout to the repository's
+// .git/info/exclude, unless it is there already. Directories that are not
+// git checkouts are left alone.
+func ExcludeFromGit(ctx context.Context, dir, name string) error {
+	out, err := telemetry.Output(ctx, exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--git-path", "info/exclude"))
+	if err != nil {
+		return nil
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	pattern := "/" + name
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line == pattern || line == name {
+			return nil
+		}
+	}
+
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		pattern = "\n" + pattern
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(pattern + "\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// refreshEnvFiles rewrites the env file of each worktree using a service
+// that was just (re)started, as its address may have changed. Worktrees
+// without an env file have not been started by vibeman and are skipped.
+func (m *Manager) refreshEnvFiles(ctx context.Context, serviceName string) {
+	if m.database == nil {
+		return
+	}
+
+	worktrees, err := db.NewWorktreeRepository(m.database).List(ctx, "", "")
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list worktrees to refresh service environment")
+		return
+	}
+
+	for _, worktree := range worktrees {
+		if _, err := os.Stat(filepath.Join(worktree.Path, EnvFile)); err != nil {
+			continue
+		}
+		repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
+		if err != nil {
+			continue
+		}
+		if !repoConfig.Repository.Services.Uses(serviceName) {
+			continue
+		}
+
+		env, err := m.ServiceEnv(ctx, worktree.ID, repoConfig.Repository.Services.Names(), true)
+		if err != nil {
+			logger.WithError(err).WithField("worktree", worktree.Name).Warn("Failed to resolve some services")
+		}
+		if err := WriteEnvFile(ctx, worktree.Path, env); err != nil {
+			logger.WithError(err).WithField("worktree", worktree.Name).Warn("Failed to refresh service environment")
+		}
+	}
+}
+
+// resolveEndpoint inspects a service's container
+func (m *Manager) resolveEndpoint(ctx context.Context, name string, cfg config.ServiceConfig) (*Endpoint, error) {
+	details, err := m.inspectService(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	provision := cfg.Provision
+	if provision == nil {
+		provision = &config.ProvisionConfig{}
+	}
+
+	endpoint := &Endpoint{
+		Name: name,
+		Type: provision.Type,
+		Host: provision.Host,
+		Port: provision.Port,
+	}
+	if endpoint.Type == "" {
+		endpoint.Type = imageType(details.Image)
+	}
+	if endpoint.Host == "" {
+		endpoint.Host = defaultProvisionHost
+	}
+
+	if len(details.Ports) > 0 {
+		endpoint.Ports = make(map[string]int, len(details.Ports))
+		for containerPort, hostPort := range details.Ports {
+			endpoint.Ports[strconv.Itoa(containerPort)] = hostPort
+		}
+	}
+	if endpoint.Port == 0 {
+		if hostPort, ok := details.Ports[defaultServicePorts[endpoint.Type]]; ok {
+			endpoint.Port = hostPort
+		} else if len(details.Ports) > 0 {
+			// Fall back to the lowest published port
+			endpoint.Port = details.Ports[slices.Min(slices.Collect(maps.Keys(details.Ports)))]
+		} else {
+			return nil, fmt.Errorf("no published ports")
+		}
+	}
+
+	endpoint.applyCredentials(details.Env, provision)
+	return endpoint, nil
+}
+
+// imageType infers a service's type from its image, e.g. postgres:16
+func imageType(image string) string {
+	name := strings.ToLower(image)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name, _, _ = strings.Cut(name, ":")
+
+	switch {
+	case strings.Contains(name, "postgres"), strings.Contains(name, "postgis"):
+		return config.ProvisionPostgres
+	case strings.Contains(name, "redis"), strings.Contains(name, "valkey"):
+		return config.ProvisionRedis
+	case strings.Contains(name, "localstack"):
+		return config.ProvisionS3
+	case strings.Contains(name, "mysql"), strings.Contains(name, "mariadb"):
+		return typeMySQL
+	case strings.Contains(name, "mongo"):
+		return typeMongoDB
+	}
+	return ""
+}
+
+// applyCredentials reads credentials from the variables the service's
+// image is configured with, preferring those in the provision section
+func (e *Endpoint) applyCredentials(env map[string]string, provision *config.ProvisionConfig) {
+	switch e.Type {
+	case config.ProvisionPostgres:
+		e.User = cmp.Or(provision.User, env["POSTGRES_USER"], defaultPostgresUser)
+		e.Password = cmp.Or(provision.Password, env["POSTGRES_PASSWORD"])
+		e.Database = cmp.Or(env["POSTGRES_DB"], e.User)
+	case config.ProvisionRedis:
+		e.Password = cmp.Or(provision.Password, env["REDIS_PASSWORD"])
+		e.Database = "0"
+	case config.ProvisionS3:
+		e.Region = cmp.Or(provision.Region, env["AWS_DEFAULT_REGION"], defaultS3Region)
+	case typeMySQL:
+		if user := cmp.Or(env["MYSQL_USER"], env["MARIADB_USER"]); user != "" {
+			e.User = user
+			e.Password = cmp.Or(env["MYSQL_PASSWORD"], env["MARIADB_PASSWORD"])
+		} else {
+			e.User = "root"
+			e.Password = cmp.Or(env["MYSQL_ROOT_PASSWORD"], env["MARIADB_ROOT_PASSWORD"])
+		}
+		e.Database = cmp.Or(env["MYSQL_DATABASE"], env["MARIADB_DATABASE"])
+	case typeMongoDB:
+		e.User = env["MONGO_INITDB_ROOT_USERNAME"]
+		e.Password = env["MONGO_INITDB_ROOT_PASSWORD"]
+		e.Database = env["MONGO_INITDB_DATABASE"]
+	}
+}
+
+// applyResource points the endpoint at a worktree's provisioned resource
+func (e *Endpoint) applyResource(resource *db.ServiceResource) {
+	prefix := envName(resource.Service) + "_"
+	set := func(field *string, key string) {
+		if value, ok := resource.Env[prefix+key]; ok {
+			*field = value
+		}
+	}
+	set(&e.User, "USER")
+	set(&e.Password, "PASSWORD")
+	set(&e.Database, "DB")
+	set(&e.KeyPrefix, "KEY_PREFIX")
+	set(&e.Bucket, "BUCKET")
+}
+
+// buildURL returns a connection URL for the endpoint's type
+func (e *Endpoint) buildURL() string {
+	address := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	u := &url.URL{Host: address}
+	if e.User != "" || e.Password != "" {
+		u.User = url.UserPassword(e.User, e.Password)
+	}
+
+	switch e.Type {
+	case config.ProvisionPostgres:
+		u.Scheme, u.Path, u.RawQuery = "postgres", "/"+e.Database, "sslmode=disable"
+	case config.ProvisionRedis:
+		u.Scheme, u.Path = "redis", "/"+e.Database
+	case typeMySQL:
+		u.Scheme, u.Path = "mysql", "/"+e.Database
+	case typeMongoDB:
+		u.Scheme, u.Path = "mongodb", "/"+e.Database
+	case config.ProvisionS3:
+		return "http://" + address
+	default:
+		return ""
+	}
+	return u.String()
+}
+
+// forContainer returns the endpoint as seen from inside a container, where
+// the loopback interface is the container's own
+func (e *Endpoint) forContainer() *Endpoint {
+	if e.Host != "localhost" && !net.ParseIP(e.Host).IsLoopback() {
+		return e
+	}
+	copied := *e
+	copied.Host = constants.ContainerHostGateway
+	if copied.URL != "" {
+		copied.URL = copied.buildURL()
+	}
+	return &copied
+}
+
+// quoteEnvValue quotes a value for an env file when it holds characters
+// that env file parsers treat specially
+func quoteEnvValue(value string) string {
+	safe := strings.IndexFunc(value, func(r rune) bool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return false
+		case strings.ContainsRune("_-.:/@?=&%+,", r):
+			return false
+		}
+		return true
+	}) < 0
+	if safe {
+		return value
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	return strconv.Quote(value)
+}
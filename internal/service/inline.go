@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"vibeman/internal/config"
+	"vibeman/internal/container"
+)
+
+// Labels vibeman puts on the containers of inline services
+const (
+	serviceLabel = "vibeman.service"      // Name of the service in services.toml
+	specLabel    = "vibeman.service-spec" // Hash of the definition the container was created from
+)
+
+// startInlineService starts the container of a service defined in
+// services.toml. The container is kept between runs, so data outside its
+// volumes survives a restart, and replaced once its definition changes.
+func (m *Manager) startInlineService(ctx context.Context, instance *ServiceInstance) error {
+	cfg := instance.Config
+	spec := inlineSpec(cfg)
+
+	if existing, err := m.runtime.GetInfo(ctx, cfg.Service); err == nil {
+		if existing.Labels[specLabel] == spec {
+			if err := m.runtime.Start(ctx, existing.ID); err != nil {
+				return fmt.Errorf("failed to start container %s: %w", cfg.Service, err)
+			}
+			instance.ContainerID = existing.ID
+			return nil
+		}
+
+		if existing.Status == "running" {
+			if err := m.runtime.Stop(ctx, existing.ID); err != nil {
+				return fmt.Errorf("failed to stop outdated container %s: %w", cfg.Service, err)
+			}
+		}
+		if err := m.runtime.Remove(ctx, existing.ID); err != nil {
+			return fmt.Errorf("failed to remove outdated container %s: %w", cfg.Service, err)
+		}
+	}
+
+	env := make([]string, 0, len(cfg.Env))
+	for _, name := range slices.Sorted(maps.Keys(cfg.Env)) {
+		env = append(env, name+"="+cfg.Env[name])
+	}
+	created, err := m.runtime.Create(ctx, &container.CreateConfig{
+		Name:    cfg.Service,
+		Image:   cfg.Image,
+		Type:    "service",
+		EnvVars: env,
+		Volumes: cfg.Volumes,
+		Ports:   cfg.Ports,
+		Command: cfg.Command,
+		Labels:  map[string]string{serviceLabel: instance.Name, specLabel: spec},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create container %s: %w", cfg.Service, err)
+	}
+
+	instance.ContainerID = created.ID
+	return nil
+}
+
+// stopInlineService stops the container of a service defined in
+// services.toml, keeping it for the next start
+func (m *Manager) stopInlineService(ctx context.Context, instance *ServiceInstance) error {
+	if err := m.runtime.Stop(ctx, instance.Config.Service); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", instance.Config.Service, err)
+	}
+	return nil
+}
+
+// inlineSpec returns a hash of what an inline service's container is
+// created from
+func inlineSpec(cfg config.ServiceConfig) string {
+	data, _ := json.Marshal(struct {
+		Image   string
+		Ports   []string
+		Env     map[string]string
+		Volumes []string
+		Command []string
+	}{cfg.Image, cfg.Ports, cfg.Env, cfg.Volumes, cfg.Command})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"vibeman/internal/config"
+	"vibeman/internal/container"
+	"vibeman/internal/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime keeps containers by name and records what was done to them
+type fakeRuntime struct {
+	container.ContainerRuntime
+	containers map[string]*container.Container
+	created    []*container.CreateConfig
+	calls      []string
+}
+
+func (f *fakeRuntime) GetInfo(ctx context.Context, containerID string) (*container.Container, error) {
+	for _, c := range f.containers {
+		if c.ID == containerID || c.Name == containerID {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to inspect container")
+}
+
+func (f *fakeRuntime) Create(ctx context.Context, cfg *container.CreateConfig) (*container.Container, error) {
+	f.created = append(f.created, cfg)
+	c := &container.Container{ID: fmt.Sprintf("id-%d", len(f.created)), Name: cfg.Name, Image: cfg.Image, Status: "running", Labels: cfg.Labels}
+	f.containers[cfg.Name] = c
+	f.calls = append(f.calls, "create "+c.ID)
+	return c, nil
+}
+
+func (f *fakeRuntime) Start(ctx context.Context, containerID string) error {
+	f.calls = append(f.calls, "start "+containerID)
+	c, err := f.GetInfo(ctx, containerID)
+	if err == nil {
+		c.Status = "running"
+	}
+	return err
+}
+
+func (f *fakeRuntime) Stop(ctx context.Context, containerID string) error {
+	f.calls = append(f.calls, "stop "+containerID)
+	c, err := f.GetInfo(ctx, containerID)
+	if err == nil {
+		c.Status = "exited"
+	}
+	return err
+}
+
+func (f *fakeRuntime) Remove(ctx context.Context, containerID string) error {
+	f.calls = append(f.calls, "remove "+containerID)
+	c, err := f.GetInfo(ctx, containerID)
+	if err == nil {
+		delete(f.containers, c.Name)
+	}
+	return err
+}
+
+func newInlineTestManager(t *testing.T) (*Manager, *fakeRuntime) {
+	t.Helper()
+
+	manager, _ := newProvisionTestManager(t)
+	manager.config.Services.Services["cache"] = config.ServiceConfig{
+		Service: config.InlineContainerName("cache"),
+		Image:   "redis:7-alpine",
+		Ports:   []string{"6380:6379"},
+		Env:     map[string]string{"TZ": "UTC", "LANG": "C"},
+		Volumes: []string{"cache-data:/data"},
+		Command: []string{"redis-server", "--appendonly", "yes"},
+	}
+
+	runtime := &fakeRuntime{containers: map[string]*container.Container{}}
+	manager.runtime = runtime
+	manager.checker = health.NewChecker(func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte("running healthy"), nil
+	})
+	manager.containerState = func(ctx context.Context, containerID string) (*containerState, error) {
+		c, err := runtime.GetInfo(ctx, containerID)
+		if err != nil {
+			return &containerState{ExitCode: -1}, nil
+		}
+		return &containerState{Running: c.Status == "running"}, nil
+	}
+	return manager, runtime
+}
+
+func TestInlineService_Lifecycle(t *testing.T) {
+	manager, runtime := newInlineTestManager(t)
+	ctx := context.Background()
+
+	require.NoError(t, manager.StartService(ctx, "cache"))
+	require.Len(t, runtime.created, 1)
+	created := runtime.created[0]
+	assert.Equal(t, "vibeman-service-cache", created.Name)
+	assert.Equal(t, "redis:7-alpine", created.Image)
+	assert.Equal(t, "service", created.Type)
+	assert.Equal(t, []string{"LANG=C", "TZ=UTC"}, created.EnvVars)
+	assert.Equal(t, []string{"6380:6379"}, created.Ports)
+	assert.Equal(t, []string{"cache-data:/data"}, created.Volumes)
+	assert.Equal(t, []string{"redis-server", "--appendonly", "yes"}, created.Command)
+	assert.Equal(t, "cache", created.Labels[serviceLabel])
+	assert.Len(t, created.Labels[specLabel], 12)
+	instance, err := manager.getService("cache")
+	require.NoError(t, err)
+	assert.Equal(t, "id-1", instance.ContainerID)
+
+	require.NoError(t, manager.StopService(ctx, "cache"))
+	status, err := manager.serviceStatus(ctx, manager.config.Services.Services["cache"])
+	require.NoError(t, err)
+	assert.Equal(t, StatusStopped, status)
+
+	// The container is kept while its definition is unchanged
+	require.NoError(t, manager.StartService(ctx, "cache"))
+	assert.Len(t, runtime.created, 1)
+	assert.Equal(t, []string{"create id-1", "stop vibeman-service-cache", "start id-1"}, runtime.calls)
+	require.NoError(t, manager.StopService(ctx, "cache"))
+
+	// and replaced once it changes
+	serviceConfig := manager.config.Services.Services["cache"]
+	serviceConfig.Image = "redis:8-alpine"
+	manager.config.Services.Services["cache"] = serviceConfig
+	require.NoError(t, manager.StartService(ctx, "cache"))
+	require.Len(t, runtime.created, 2)
+	assert.Equal(t, "redis:8-alpine", runtime.created[1].Image)
+	assert.Contains(t, runtime.calls, "remove id-1")
+	instance, err = manager.getService("cache")
+	require.NoError(t, err)
+	assert.Equal(t, "id-2", instance.ContainerID)
+}
+
+func TestInlineService_UntrackedStatus(t *testing.T) {
+	manager, runtime := newInlineTestManager(t)
+	runtime.containers["vibeman-service-cache"] = &container.Container{ID: "abc", Name: "vibeman-service-cache", Status: "running"}
+
+	instance, err := manager.getService("cache")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, instance.Status)
+	assert.Equal(t, "abc", instance.ContainerID)
+}
+
+func TestInlineService_Versions(t *testing.T) {
+	manager, runtime := newInlineTestManager(t)
+	ctx := context.Background()
+
+	serviceConfig := manager.config.Services.Services["cache"]
+	serviceConfig.Versions = map[string]config.ServiceConfig{"6": {Image: "redis:6-alpine"}}
+	manager.config.Services.Services["cache"] = serviceConfig
+
+	// Each version runs in its own container, on its own volume and host port
+	require.NoError(t, manager.StartService(ctx, "cache"))
+	require.NoError(t, manager.StartService(ctx, "cache@6"))
+	require.Len(t, runtime.created, 2)
+	assert.Equal(t, "vibeman-service-cache-6", runtime.created[1].Name)
+	assert.Equal(t, "redis:6-alpine", runtime.created[1].Image)
+	assert.Equal(t, []string{"6379"}, runtime.created[1].Ports)
+	assert.Equal(t, []string{"cache-data-6:/data"}, runtime.created[1].Volumes)
+	assert.Equal(t, "cache@6", runtime.created[1].Labels[serviceLabel])
+
+	// and is referenced and stopped independently
+	require.NoError(t, manager.AddReference("cache@6", "wt-legacy"))
+	require.NoError(t, manager.AddReference("cache", "wt-current"))
+	legacy, err := manager.getService("cache@6")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wt-legacy"}, legacy.Repositories)
+
+	require.NoError(t, manager.RemoveReference("cache@6", "wt-legacy"))
+	require.NoError(t, manager.StopService(ctx, "cache@6"))
+	current, err := manager.getService("cache")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, current.Status)
+	assert.Equal(t, []string{"wt-current"}, current.Repositories)
+	assert.Equal(t, "exited", runtime.containers["vibeman-service-cache-6"].Status)
+
+	assert.ErrorContains(t, manager.StartService(ctx, "cache@5"), "service configuration not found: cache@5")
+}
+
+func TestStopService_ReleasesDependencies(t *testing.T) {
+	manager, runtime := newInlineTestManager(t)
+	ctx := context.Background()
+	manager.config.Services.Services["api"] = config.ServiceConfig{
+		Service:   config.InlineContainerName("api"),
+		Image:     "api:latest",
+		DependsOn: []string{"cache"},
+	}
+
+	// The cache is already running when the api starts
+	require.NoError(t, manager.StartService(ctx, "cache"))
+	require.NoError(t, manager.AddReference("cache", ManualHolder))
+	require.NoError(t, manager.StartService(ctx, "api"))
+
+	// Stopping the api gives back its reference on the cache
+	require.NoError(t, manager.StopService(ctx, "api"))
+	require.NoError(t, manager.RemoveReference("cache", ManualHolder))
+	require.NoError(t, manager.StopService(ctx, "cache"))
+
+	instance, err := manager.getService("cache")
+	require.NoError(t, err)
+	assert.Equal(t, StatusStopped, instance.Status)
+	assert.Equal(t, "exited", runtime.containers["vibeman-service-cache"].Status)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -9,6 +10,12 @@ import (
 	"time"
 
 	"vibeman/internal/config"
+	"vibeman/internal/container"
+	"vibeman/internal/db"
+	"vibeman/internal/health"
+	"vibeman/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ServiceStatus represents the status of a service
@@ -21,8 +28,18 @@ const (
 	StatusStopping ServiceStatus = "stopping"
 	StatusError    ServiceStatus = "error"
 	StatusUnknown  ServiceStatus = "unknown"
+
+	// Set by the supervisor (see supervisor.go)
+	StatusDegraded  ServiceStatus = "degraded"      // Running but failing its health probe, or just restarted
+	StatusCrashLoop ServiceStatus = "crash-looping" // Restarted too many times in a row
 )
 
+// Up reports whether a service's container should be running: it is
+// running, degraded or crash-looping
+func (s ServiceStatus) Up() bool {
+	return s == StatusRunning || s == StatusDegraded || s == StatusCrashLoop
+}
+
 // ServiceInstance represents a running service instance
 type ServiceInstance struct {
 	Name        string               `json:"name"`
@@ -33,8 +50,14 @@ type ServiceInstance struct {
 	Config      config.ServiceConfig `json:"config"`
 	StartTime   time.Time            `json:"start_time"`
 	LastHealth  time.Time            `json:"last_health"`
+	Health      health.Status        `json:"health,omitempty"`
 	HealthError string               `json:"health_error,omitempty"`
+	Restarts    int                  `json:"restarts,omitempty"` // Restarts by the supervisor since the service last recovered
 	mutex       sync.RWMutex         `json:"-"`
+
+	// Supervisor bookkeeping
+	lastRestart time.Time
+	nextRestart time.Time
 }
 
 // Manager handles service lifecycle operations
@@ -42,18 +65,58 @@ type Manager struct {
 	config   *config.Manager
 	services map[string]*ServiceInstance
 	mutex    sync.RWMutex
+
+	// Per-worktree provisioning (see provision.go)
+	database      *db.DB
+	provisionMu   sync.Mutex
+	execInService execFunc
+
+	// Service discovery (see discovery.go)
+	inspectService inspectFunc
+
+	// Readiness and health probes
+	checker *health.Checker
+
+	// Creates the containers of inline services (see inline.go)
+	runtime container.ContainerRuntime
+
+	// Supervision (see supervisor.go)
+	containerState stateFunc
+	restartService restartFunc
+
+	// Snapshots (see snapshot.go); the directory defaults to
+	// xdg.DataDir()/snapshots
+	snapshotDir string
+	runDocker   dockerStreamFunc
 }
 
 // New creates a new service manager
 func New(cfg *config.Manager) *Manager {
-	return &Manager{
-		config:   cfg,
-		services: make(map[string]*ServiceInstance),
-	}
+	m := &Manager{
+		config:         cfg,
+		services:       make(map[string]*ServiceInstance),
+		execInService:  dockerExec,
+		inspectService: inspectContainer,
+		checker:        health.NewChecker(nil),
+		containerState: dockerContainerState,
+		runDocker:      dockerStream,
+		runtime:        container.NewDockerRuntime(nil),
+	}
+	m.restartService = m.startServiceContainer
+	return m
+}
+
+// SetDatabase sets the database that records per-worktree resources.
+// Provisioning is unavailable without one.
+func (m *Manager) SetDatabase(database *db.DB) {
+	m.database = database
 }
 
 // StartService starts a service with reference counting
-func (m *Manager) StartService(ctx context.Context, name string) error {
+func (m *Manager) StartService(ctx context.Context, name string) (err error) {
+	ctx, span := telemetry.Start(ctx, "service.StartService", attribute.String("vibeman.service", name))
+	defer func() { telemetry.End(span, err) }()
+
 	// Check if services configuration is available
 	m.mutex.Lock()
 	if m.config.Services == nil {
@@ -62,12 +125,18 @@ func (m *Manager) StartService(ctx context.Context, name string) error {
 	}
 
 	// Check if service configuration exists
-	serviceConfig, exists := m.config.Services.Services[name]
+	serviceConfig, exists := m.config.Services.Lookup(name)
 	if !exists {
 		m.mutex.Unlock()
 		return fmt.Errorf("service configuration not found: %s", name)
 	}
 
+	graph, err := config.NewServiceGraph(m.config.Services.Services)
+	if err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+
 	// Get or create service instance
 	instance, exists := m.services[name]
 	if !exists {
@@ -85,8 +154,9 @@ func (m *Manager) StartService(ctx context.Context, name string) error {
 	// Now handle the instance-specific logic
 	instance.mutex.Lock()
 
-	// If service is already running, just increment reference count
-	if instance.Status == StatusRunning {
+	// If service is already running, just increment reference count; the
+	// supervisor looks after a degraded one
+	if instance.Status == StatusRunning || instance.Status == StatusDegraded {
 		instance.RefCount++
 		instance.mutex.Unlock()
 		return nil
@@ -106,7 +176,7 @@ func (m *Manager) StartService(ctx context.Context, name string) error {
 				status := instance.Status
 				instance.mutex.RUnlock()
 
-				if status == StatusRunning {
+				if status == StatusRunning || status == StatusDegraded {
 					// Service is now running, increment ref count and return
 					instance.mutex.Lock()
 					instance.RefCount++
@@ -120,14 +190,13 @@ func (m *Manager) StartService(ctx context.Context, name string) error {
 		}
 	}
 
-	// Mark as starting
+	// Mark as starting, picking up any change to the configuration
 	instance.Status = StatusStarting
+	instance.Config = serviceConfig
 	instance.mutex.Unlock()
 
-	// Docker Compose handles dependencies automatically
-	var dependencies []string
-
 	// Start dependencies without holding any locks
+	dependencies := graph.Dependencies(name)
 	if err := m.startDependencies(ctx, dependencies); err != nil {
 		// Update status to error
 		instance.mutex.Lock()
@@ -141,36 +210,58 @@ func (m *Manager) StartService(ctx context.Context, name string) error {
 		instance.mutex.Lock()
 		instance.Status = StatusError
 		instance.mutex.Unlock()
+		m.releaseServices(dependencies)
 		return fmt.Errorf("failed to start service %s: %w", name, err)
 	}
 
+	// Nothing may use the service before it is ready
+	if err := m.checker.WaitReady(ctx, instance.Config.Health, instance.ContainerID); err != nil {
+		instance.mutex.Lock()
+		instance.Status = StatusError
+		instance.Health = health.StatusOf(err)
+		instance.HealthError = err.Error()
+		instance.LastHealth = time.Now()
+		instance.mutex.Unlock()
+		m.releaseServices(dependencies)
+		return fmt.Errorf("service %s did not become ready: %w", name, err)
+	}
+
 	// Update status to running
 	instance.mutex.Lock()
 	instance.Status = StatusRunning
 	instance.RefCount = 1
 	instance.StartTime = time.Now()
 	instance.LastHealth = time.Now()
+	instance.Health = health.StatusHealthy
+	instance.HealthError = ""
+	instance.Restarts = 0
 	instance.mutex.Unlock()
 
+	// Point worktrees at the service's new container
+	m.refreshEnvFiles(ctx, name)
+
 	return nil
 }
 
 // StopService stops a service with reference counting
-func (m *Manager) StopService(ctx context.Context, name string) error {
+func (m *Manager) StopService(ctx context.Context, name string) (err error) {
+	ctx, span := telemetry.Start(ctx, "service.StopService", attribute.String("vibeman.service", name))
+	defer func() { telemetry.End(span, err) }()
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	instance, exists := m.services[name]
 	if !exists {
 		// Check if it's a compose service we can stop directly
-		serviceConfig, configExists := m.config.Services.Services[name]
+		serviceConfig, configExists := m.config.Services.Lookup(name)
 		if !configExists {
 			return fmt.Errorf("service not found: %s", name)
 		}
 
 		if serviceConfig.IsValid() {
-			// For compose services, stop directly without tracking
-			return m.stopComposeService(ctx, &ServiceInstance{
+			// Stop directly without tracking
+			return m.stopServiceContainer(ctx, &ServiceInstance{
 				Name:   name,
 				Config: serviceConfig,
 			})
@@ -182,7 +273,7 @@ func (m *Manager) StopService(ctx context.Context, name string) error {
 	instance.mutex.Lock()
 	defer instance.mutex.Unlock()
 
-	if instance.Status != StatusRunning {
+	if !instance.Status.Up() {
 		return fmt.Errorf("service is not running: %s", name)
 	}
 
@@ -206,25 +297,41 @@ func (m *Manager) StopService(ctx context.Context, name string) error {
 	instance.ContainerID = ""
 	instance.Repositories = []string{}
 
+	// Give back the references taken on its dependencies when it started
+	if graph, err := config.NewServiceGraph(m.config.Services.Services); err == nil {
+		m.releaseServicesLocked(graph.Dependencies(name))
+	}
+
 	return nil
 }
 
 // GetService returns a service instance by name
 func (m *Manager) GetService(name string) (interface{}, error) {
+	instance, err := m.getService(name)
+	if err != nil {
+		return nil, err
+	}
+	m.applyReferences(instance)
+	return instance, nil
+}
+
+// getService returns a copy of a service instance, or the status of a
+// compose service this process has not started
+func (m *Manager) getService(name string) (*ServiceInstance, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
 	instance, exists := m.services[name]
 	if !exists {
-		// For compose services, create a minimal instance with actual status
-		serviceConfig, configExists := m.config.Services.Services[name]
+		// Create a minimal instance with the actual status
+		serviceConfig, configExists := m.config.Services.Lookup(name)
 		if !configExists {
 			return nil, fmt.Errorf("service not found: %s", name)
 		}
 
 		if serviceConfig.IsValid() {
-			// Create temporary instance to check compose status
-			status, err := m.getComposeServiceStatus(context.Background(), serviceConfig.ComposeFile, serviceConfig.Service)
+			// Create temporary instance to check the container's status
+			status, err := m.serviceStatus(context.Background(), serviceConfig)
 			if err != nil {
 				status = StatusUnknown
 			}
@@ -232,7 +339,7 @@ func (m *Manager) GetService(name string) (interface{}, error) {
 			// Get container ID if running
 			containerID := ""
 			if status == StatusRunning {
-				if id, err := m.getComposeContainerID(context.Background(), serviceConfig.ComposeFile, serviceConfig.Service); err == nil {
+				if id, err := m.serviceContainerID(context.Background(), serviceConfig); err == nil {
 					containerID = id
 				}
 			}
@@ -246,6 +353,7 @@ func (m *Manager) GetService(name string) (interface{}, error) {
 				Config:      serviceConfig,
 				StartTime:   time.Now(), // Use current time for uptime calculation
 				LastHealth:  time.Now(),
+				Health:      health.StatusUnknown,
 				HealthError: "",
 			}, nil
 		}
@@ -266,7 +374,9 @@ func (m *Manager) GetService(name string) (interface{}, error) {
 		Config:      instance.Config,
 		StartTime:   instance.StartTime,
 		LastHealth:  instance.LastHealth,
+		Health:      instance.Health,
 		HealthError: instance.HealthError,
+		Restarts:    instance.Restarts,
 	}, nil
 }
 
@@ -287,16 +397,24 @@ func (m *Manager) ListServices() []*ServiceInstance {
 			Config:      instance.Config,
 			StartTime:   instance.StartTime,
 			LastHealth:  instance.LastHealth,
+			Health:      instance.Health,
 			HealthError: instance.HealthError,
+			Restarts:    instance.Restarts,
 		})
 		instance.mutex.RUnlock()
 	}
 
+	for _, instance := range instances {
+		m.applyReferences(instance)
+	}
 	return instances
 }
 
 // HealthCheck performs a health check on a service
-func (m *Manager) HealthCheck(ctx context.Context, name string) error {
+func (m *Manager) HealthCheck(ctx context.Context, name string) (err error) {
+	ctx, span := telemetry.Start(ctx, "service.HealthCheck", attribute.String("vibeman.service", name))
+	defer func() { telemetry.End(span, err) }()
+
 	m.mutex.RLock()
 	instance, exists := m.services[name]
 	m.mutex.RUnlock()
@@ -308,30 +426,43 @@ func (m *Manager) HealthCheck(ctx context.Context, name string) error {
 	instance.mutex.Lock()
 	defer instance.mutex.Unlock()
 
-	if instance.Status != StatusRunning || instance.ContainerID == "" {
+	if (instance.Status != StatusRunning && instance.Status != StatusDegraded) || instance.ContainerID == "" {
 		return fmt.Errorf("service is not running: %s", name)
 	}
 
-	// For compose services, just check if container is running
-	// Docker compose handles health checks internally
-	if err := m.checkContainerStatus(ctx, instance.ContainerID); err != nil {
+	err = m.runHealthCheck(ctx, instance)
+	instance.Health = health.StatusOf(err)
+	instance.LastHealth = time.Now()
+	if err != nil {
 		instance.HealthError = err.Error()
-		instance.LastHealth = time.Now()
 		return err
 	}
 
 	instance.HealthError = ""
-	instance.LastHealth = time.Now()
 	return nil
 }
 
-// AddReference adds a repository reference to a service
+// AddReference adds a repository or worktree reference to a service. With a
+// database the reference is persisted, so it survives restarts and is seen
+// by the server and every CLI invocation.
 func (m *Manager) AddReference(serviceName, repositoryName string) error {
+	if m.database != nil {
+		if _, err := m.serviceConfig(serviceName); err != nil {
+			return err
+		}
+		if err := db.NewServiceReferenceRepository(m.database).Add(context.Background(), serviceName, repositoryName); err != nil {
+			return err
+		}
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	instance, exists := m.services[serviceName]
 	if !exists {
+		if m.database != nil {
+			return nil // Not started by this process
+		}
 		return fmt.Errorf("service not found: %s", serviceName)
 	}
 
@@ -351,13 +482,29 @@ func (m *Manager) AddReference(serviceName, repositoryName string) error {
 	return nil
 }
 
-// RemoveReference removes a repository reference from a service
+// RemoveReference removes a repository or worktree reference from a
+// service. A service left without references is stopped by the idle stopper
+// once its grace period runs out.
 func (m *Manager) RemoveReference(serviceName, repositoryName string) error {
+	removed := false
+	if m.database != nil {
+		var err error
+		if removed, err = db.NewServiceReferenceRepository(m.database).Remove(context.Background(), serviceName, repositoryName); err != nil {
+			return err
+		}
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	instance, exists := m.services[serviceName]
 	if !exists {
+		if removed {
+			return nil
+		}
+		if m.database != nil {
+			return fmt.Errorf("repository reference not found: %s", repositoryName)
+		}
 		return fmt.Errorf("service not found: %s", serviceName)
 	}
 
@@ -372,64 +519,129 @@ func (m *Manager) RemoveReference(serviceName, repositoryName string) error {
 			return nil
 		}
 	}
+	if removed {
+		return nil
+	}
 
 	return fmt.Errorf("repository reference not found: %s", repositoryName)
 }
 
-// startDependencies starts all service dependencies
+// startDependencies starts a service's dependencies in parallel. Each one
+// starts its own dependencies first, so independent branches of the
+// dependency graph come up side by side.
 func (m *Manager) startDependencies(ctx context.Context, dependencies []string) error {
-	for _, dep := range dependencies {
-		if err := m.StartService(ctx, dep); err != nil {
-			return fmt.Errorf("failed to start dependency %s: %w", dep, err)
+	errs := make([]error, len(dependencies))
+	var wg sync.WaitGroup
+	for i, dep := range dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.StartService(ctx, dep); err != nil {
+				errs[i] = fmt.Errorf("failed to start dependency %s: %w", dep, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err != nil {
+		// The service will not start, so it holds none of its dependencies
+		var started []string
+		for i, dep := range dependencies {
+			if errs[i] == nil {
+				started = append(started, dep)
+			}
 		}
+		m.releaseServices(started)
 	}
-	return nil
+	return err
+}
+
+// releaseServices gives back a reference on each of the services, taken by
+// a dependent when it started
+func (m *Manager) releaseServices(names []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.releaseServicesLocked(names)
 }
 
-// startServiceContainer starts the actual container for a service
+// releaseServicesLocked is releaseServices for callers holding m.mutex
+func (m *Manager) releaseServicesLocked(names []string) {
+	for _, name := range names {
+		instance, exists := m.services[name]
+		if !exists {
+			continue
+		}
+		instance.mutex.Lock()
+		if instance.RefCount > 0 {
+			instance.RefCount--
+		}
+		instance.mutex.Unlock()
+	}
+}
+
+// startServiceContainer starts the actual container for a service and
+// records its ID
 func (m *Manager) startServiceContainer(ctx context.Context, instance *ServiceInstance) error {
-	// All services must use Docker Compose
 	if !instance.Config.IsValid() {
-		return fmt.Errorf("invalid service configuration: compose_file and service name are required")
+		return fmt.Errorf("invalid service configuration: an image, or compose_file and service name, are required")
 	}
 
+	if instance.Config.IsInline() {
+		return m.startInlineService(ctx, instance)
+	}
 	return m.startComposeService(ctx, instance)
 }
 
 // stopServiceContainer stops the container for a service
 func (m *Manager) stopServiceContainer(ctx context.Context, instance *ServiceInstance) error {
-	// All services must use Docker Compose
 	if !instance.Config.IsValid() {
-		return fmt.Errorf("invalid service configuration: compose_file and service name are required")
+		return fmt.Errorf("invalid service configuration: an image, or compose_file and service name, are required")
 	}
 
+	if instance.Config.IsInline() {
+		return m.stopInlineService(ctx, instance)
+	}
 	return m.stopComposeService(ctx, instance)
 }
 
-// checkContainerStatus checks if a container is running
-func (m *Manager) checkContainerStatus(ctx context.Context, containerID string) error {
-	cmd := exec.CommandContext(ctx, "docker", "inspect", containerID, "--format", "{{.State.Running}}")
-	output, err := cmd.CombinedOutput()
+// serviceStatus reports whether a service's container is running
+func (m *Manager) serviceStatus(ctx context.Context, cfg config.ServiceConfig) (ServiceStatus, error) {
+	if !cfg.IsInline() {
+		return m.getComposeServiceStatus(ctx, cfg.ComposeFile, cfg.Service)
+	}
+
+	state, err := m.containerState(ctx, cfg.Service)
 	if err != nil {
-		return fmt.Errorf("failed to check container status: %w, output: %s", err, string(output))
+		return StatusUnknown, err
+	}
+	if state.Running {
+		return StatusRunning, nil
 	}
+	return StatusStopped, nil
+}
 
-	if strings.TrimSpace(string(output)) != "true" {
-		return fmt.Errorf("container is not running")
+// serviceContainerID returns the ID of a service's container
+func (m *Manager) serviceContainerID(ctx context.Context, cfg config.ServiceConfig) (string, error) {
+	if !cfg.IsInline() {
+		return m.getComposeContainerID(ctx, cfg.ComposeFile, cfg.Service)
 	}
 
-	return nil
+	info, err := m.runtime.GetInfo(ctx, cfg.Service)
+	if err != nil {
+		return "", err
+	}
+	return info.ID, nil
 }
 
-// runHealthCheck runs the configured health check for a service
+// runHealthCheck runs the configured health probe for a service, or checks
+// its container and compose healthcheck when it has none
 func (m *Manager) runHealthCheck(ctx context.Context, instance *ServiceInstance) error {
-	// For compose services, docker-compose handles health checks
-	// We just check if the container is running
 	if instance.ContainerID == "" {
 		return fmt.Errorf("no container ID for service %s", instance.Name)
 	}
 
-	return m.checkContainerStatus(ctx, instance.ContainerID)
+	return m.checker.Check(ctx, instance.Config.Health, instance.ContainerID)
 }
 
 // startComposeService starts a service using Docker Compose
@@ -448,7 +660,7 @@ func (m *Manager) startComposeService(ctx context.Context, instance *ServiceInst
 
 	// Execute docker compose up
 	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to start compose service: %w, output: %s", err, string(output))
 	}
@@ -478,7 +690,7 @@ func (m *Manager) stopComposeService(ctx context.Context, instance *ServiceInsta
 
 	// Execute docker compose stop
 	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to stop compose service: %w, output: %s", err, string(output))
 	}
@@ -497,7 +709,7 @@ func (m *Manager) getComposeContainerID(ctx context.Context, composeFile, servic
 	}
 
 	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get container ID: %w, output: %s", err, string(output))
 	}
@@ -521,7 +733,7 @@ func (m *Manager) getComposeServiceStatus(ctx context.Context, composeFile, serv
 	}
 
 	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := telemetry.CombinedOutput(ctx, cmd)
 	if err != nil {
 		return StatusUnknown, fmt.Errorf("failed to get service status: %w, output: %s", err, string(output))
 	}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"vibeman/internal/config"
+	"vibeman/internal/health"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -347,4 +348,65 @@ func TestManager_ThreadSafety(t *testing.T) {
 
 	// Verify all services were added
 	assert.GreaterOrEqual(t, len(manager.services), 5)
-}
\ No newline at end of file
+}
+func TestHealthCheck_Probe(t *testing.T) {
+	probe := &config.ProbeConfig{Exec: []string{"pg_isready"}}
+	cfg := &config.Manager{
+		Services: &config.ServicesConfig{
+			Services: map[string]config.ServiceConfig{
+				"postgres": {ComposeFile: "docker-compose.yml", Service: "postgres", Health: probe},
+			},
+		},
+	}
+	manager := New(cfg)
+
+	var commands [][]string
+	ready := false
+	manager.checker = health.NewChecker(func(ctx context.Context, args ...string) ([]byte, error) {
+		commands = append(commands, args)
+		if !ready {
+			return []byte("no response"), fmt.Errorf("exit status 2")
+		}
+		return nil, nil
+	})
+	manager.services["postgres"] = &ServiceInstance{
+		Name:        "postgres",
+		Status:      StatusRunning,
+		ContainerID: "container-123",
+		Config:      cfg.Services.Services["postgres"],
+	}
+
+	err := manager.HealthCheck(context.Background(), "postgres")
+	assert.ErrorContains(t, err, "no response")
+	assert.Equal(t, []string{"exec", "container-123", "pg_isready"}, commands[0])
+
+	result, err := manager.GetService("postgres")
+	require.NoError(t, err)
+	instance := result.(*ServiceInstance)
+	assert.Equal(t, health.StatusUnhealthy, instance.Health)
+	assert.Contains(t, instance.HealthError, "no response")
+
+	ready = true
+	require.NoError(t, manager.HealthCheck(context.Background(), "postgres"))
+	result, err = manager.GetService("postgres")
+	require.NoError(t, err)
+	instance = result.(*ServiceInstance)
+	assert.Equal(t, health.StatusHealthy, instance.Health)
+	assert.Empty(t, instance.HealthError)
+}
+
+func TestStartService_DependencyCycle(t *testing.T) {
+	cfg := &config.Manager{
+		Services: &config.ServicesConfig{
+			Services: map[string]config.ServiceConfig{
+				"api":      {ComposeFile: "docker-compose.yml", Service: "api", DependsOn: []string{"postgres"}},
+				"postgres": {ComposeFile: "docker-compose.yml", Service: "postgres", DependsOn: []string{"api"}},
+			},
+		},
+	}
+	manager := New(cfg)
+
+	err := manager.StartService(context.Background(), "api")
+	assert.ErrorContains(t, err, "service dependency cycle")
+	assert.Empty(t, manager.ListServices())
+}
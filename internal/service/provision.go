@@ -0,0 +1,495 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Provisioning defaults
+const (
+	defaultProvisionHost  = "localhost"
+	defaultPostgresPort   = 5432
+	defaultPostgresUser   = "postgres"
+	defaultRedisPort      = 6379
+	defaultRedisDatabases = 16
+	defaultS3Port         = 4566
+	defaultS3Region       = "us-east-1"
+	maxResourceNameLength = 63 // Postgres identifiers and S3 bucket names
+)
+
+// serviceCommand is a command to run inside a service's container. Secrets
+// are passed in Env and Stdin so they never appear in command arguments.
+// Large inputs and outputs, such as dumps, are streamed through Input and
+// Output instead; the returned output then holds only stderr.
+type serviceCommand struct {
+	Args   []string
+	Env    map[string]string
+	Stdin  string
+	Input  io.Reader
+	Output io.Writer
+}
+
+// execFunc runs a command inside a service's container
+type execFunc func(ctx context.Context, cfg config.ServiceConfig, command serviceCommand) ([]byte, error)
+
+// dockerExec runs a command in a service's container
+func dockerExec(ctx context.Context, cfg config.ServiceConfig, command serviceCommand) ([]byte, error) {
+	args := []string{"compose", "-f", cfg.ComposeFile, "exec", "-T"}
+	if cfg.IsInline() {
+		args = []string{"exec", "-i"}
+	}
+	env := os.Environ()
+	for name, value := range command.Env {
+		// Pass the variable by name; docker reads the value from our environment
+		args = append(args, "-e", name)
+		env = append(env, name+"="+value)
+	}
+	args = append(args, cfg.Service)
+	args = append(args, command.Args...)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = env
+	if command.Stdin != "" {
+		cmd.Stdin = strings.NewReader(command.Stdin)
+	} else if command.Input != nil {
+		cmd.Stdin = command.Input
+	}
+
+	var output []byte
+	var err error
+	if command.Output != nil {
+		var stderr bytes.Buffer
+		cmd.Stdout = command.Output
+		cmd.Stderr = &stderr
+		err = telemetry.Run(ctx, cmd)
+		output = stderr.Bytes()
+	} else {
+		output, err = telemetry.CombinedOutput(ctx, cmd)
+	}
+	if err != nil {
+		return output, fmt.Errorf("%w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+// ProvisionWorktree creates an isolated resource for a worktree on a shared
+// service — a Postgres database and role, a Redis database or key prefix, or
+// an S3 bucket — and returns its connection details as environment
+// variables. Services without a provision section return no variables.
+// Provisioning is idempotent: a worktree keeps the resource it was given
+// first. baseName names the resource and should identify the worktree, e.g.
+// "myapp-feature-x".
+func (m *Manager) ProvisionWorktree(ctx context.Context, serviceName, worktreeID, baseName string) (env map[string]string, err error) {
+	ctx, span := telemetry.Start(ctx, "service.ProvisionWorktree",
+		attribute.String("vibeman.service", serviceName),
+		attribute.String("vibeman.worktree_id", worktreeID))
+	defer func() { telemetry.End(span, err) }()
+
+	serviceConfig, err := m.serviceConfig(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	provision := serviceConfig.Provision
+	if provision == nil {
+		return nil, nil
+	}
+	if err := provision.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid provisioning for service %s: %w", serviceName, err)
+	}
+	if m.database == nil {
+		return nil, fmt.Errorf("provisioning service %s requires a database", serviceName)
+	}
+
+	m.provisionMu.Lock()
+	defer m.provisionMu.Unlock()
+
+	resources := db.NewServiceResourceRepository(m.database)
+	if existing, err := resources.Get(ctx, worktreeID, serviceName); err == nil {
+		return existing.Env, nil
+	}
+
+	resource := &db.ServiceResource{
+		ID:         uuid.New().String(),
+		WorktreeID: worktreeID,
+		Service:    serviceName,
+		Type:       provision.Type,
+		Index:      -1,
+	}
+	suffix := worktreeID
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+
+	switch provision.Type {
+	case config.ProvisionPostgres:
+		err = m.createPostgres(ctx, serviceName, serviceConfig, resource, resourceName(baseName, suffix, '_'))
+	case config.ProvisionRedis:
+		err = m.createRedis(ctx, serviceName, serviceConfig, resource, resources, resourceName(baseName, suffix, '-')+":")
+	case config.ProvisionS3:
+		err = m.createBucket(ctx, serviceName, serviceConfig, resource, resourceName(baseName, suffix, '-'))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision %s for worktree: %w", serviceName, err)
+	}
+
+	if err := resources.Create(ctx, resource); err != nil {
+		// Don't leave behind a resource nothing knows about
+		if dropErr := m.dropResource(ctx, serviceConfig, resource); dropErr != nil {
+			logger.WithError(dropErr).WithField("service", serviceName).Warn("Failed to drop unrecorded service resource")
+		}
+		return nil, err
+	}
+
+	logger.WithFields(logger.Fields{
+		"service":     serviceName,
+		"worktree_id": worktreeID,
+		"resource":    resource.Name,
+	}).Info("Provisioned service resource for worktree")
+
+	return resource.Env, nil
+}
+
+// DeprovisionWorktree drops every resource provisioned for a worktree.
+// Resources that cannot be dropped stay recorded and are reported in the
+// returned error.
+func (m *Manager) DeprovisionWorktree(ctx context.Context, worktreeID string) (err error) {
+	ctx, span := telemetry.Start(ctx, "service.DeprovisionWorktree", attribute.String("vibeman.worktree_id", worktreeID))
+	defer func() { telemetry.End(span, err) }()
+
+	if m.database == nil {
+		return nil
+	}
+
+	m.provisionMu.Lock()
+	defer m.provisionMu.Unlock()
+
+	resources := db.NewServiceResourceRepository(m.database)
+	list, err := resources.ListByWorktree(ctx, worktreeID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, resource := range list {
+		serviceConfig, err := m.serviceConfig(resource.Service)
+		if err == nil {
+			err = m.dropResource(ctx, serviceConfig, resource)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to drop %s %s on %s: %w", resource.Type, resource.Name, resource.Service, err))
+			continue
+		}
+		if err := resources.Delete(ctx, resource.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		logger.WithFields(logger.Fields{
+			"service":     resource.Service,
+			"worktree_id": worktreeID,
+			"resource":    resource.Name,
+		}).Info("Dropped service resource for worktree")
+	}
+
+	return errors.Join(errs...)
+}
+
+// serviceConfig returns the configuration of a service
+func (m *Manager) serviceConfig(name string) (config.ServiceConfig, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.config == nil || m.config.Services == nil {
+		return config.ServiceConfig{}, fmt.Errorf("services configuration not available")
+	}
+	serviceConfig, exists := m.config.Services.Lookup(name)
+	if !exists {
+		return config.ServiceConfig{}, fmt.Errorf("service configuration not found: %s", name)
+	}
+	return serviceConfig, nil
+}
+
+// dropResource removes a provisioned resource from its service
+func (m *Manager) dropResource(ctx context.Context, cfg config.ServiceConfig, resource *db.ServiceResource) error {
+	provision := cfg.Provision
+	if provision == nil {
+		provision = &config.ProvisionConfig{Type: resource.Type}
+	}
+
+	var command serviceCommand
+	switch resource.Type {
+	case config.ProvisionPostgres:
+		command = postgresCommand(provision, dropPostgresSQL(resource.Name))
+	case config.ProvisionRedis:
+		if resource.Index >= 0 {
+			command = redisCommand(provision, "-n", strconv.Itoa(resource.Index), "FLUSHDB")
+		} else {
+			command = redisCommand(provision, "EVAL", deleteKeysScript, "0", resource.Name+"*")
+		}
+	case config.ProvisionS3:
+		command = serviceCommand{Args: []string{"awslocal", "s3", "rb", "s3://" + resource.Name, "--force"}}
+	default:
+		return fmt.Errorf("unknown resource type %q", resource.Type)
+	}
+
+	output, err := m.execInService(ctx, cfg, command)
+	if err != nil && resource.Type == config.ProvisionS3 && strings.Contains(string(output), "NoSuchBucket") {
+		return nil
+	}
+	return err
+}
+
+// createPostgres creates a database owned by a new role
+func (m *Manager) createPostgres(ctx context.Context, serviceName string, cfg config.ServiceConfig, resource *db.ServiceResource, name string) error {
+	password, err := randomSecret()
+	if err != nil {
+		return err
+	}
+
+	// Clear out anything left by an earlier attempt for this worktree, then
+	// create the role and database. psql runs each statement on its own, as
+	// CREATE DATABASE cannot run in a transaction.
+	sql := dropPostgresSQL(name) + fmt.Sprintf("CREATE ROLE \"%s\" LOGIN PASSWORD '%s';\nCREATE DATABASE \"%s\" OWNER \"%s\";\n", name, password, name, name)
+	if _, err := m.execInService(ctx, cfg, postgresCommand(cfg.Provision, sql)); err != nil {
+		return err
+	}
+
+	// Start from a copy of known data instead of an empty database
+	if seed := cfg.Provision.Seed; seed != "" {
+		snapshot, err := m.findSnapshot(serviceName, seed)
+		if err != nil {
+			return fmt.Errorf("failed to find seed snapshot: %w", err)
+		}
+		root, err := m.snapshotRoot()
+		if err != nil {
+			return err
+		}
+		if err := m.restorePostgres(ctx, cfg, snapshot, filepath.Join(root, serviceName, snapshot.ID), name, name); err != nil {
+			return fmt.Errorf("failed to seed database from snapshot %s: %w", snapshot.ID, err)
+		}
+	}
+
+	host, port := provisionAddress(cfg.Provision, defaultPostgresPort)
+	url := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", name, password, net.JoinHostPort(host, port), name)
+
+	resource.Name = name
+	resource.Env = connectionEnv(serviceName, cfg.Provision, url, map[string]string{
+		"HOST":     host,
+		"PORT":     port,
+		"DB":       name,
+		"USER":     name,
+		"PASSWORD": password,
+	})
+	return nil
+}
+
+// createRedis claims a free logical database, or a key prefix in prefix mode
+func (m *Manager) createRedis(ctx context.Context, serviceName string, cfg config.ServiceConfig, resource *db.ServiceResource, resources *db.ServiceResourceRepository, prefix string) error {
+	provision := cfg.Provision
+	host, port := provisionAddress(provision, defaultRedisPort)
+	auth := ""
+	if provision.Password != "" {
+		auth = ":" + provision.Password + "@"
+	}
+	vars := map[string]string{"HOST": host, "PORT": port}
+
+	if provision.Mode == config.RedisModePrefix {
+		resource.Name = prefix
+		vars["DB"] = "0"
+		vars["KEY_PREFIX"] = prefix
+		resource.Env = connectionEnv(serviceName, provision, fmt.Sprintf("redis://%s%s/0", auth, net.JoinHostPort(host, port)), vars)
+		return nil
+	}
+
+	index, err := freeRedisDatabase(ctx, serviceName, provision, resources)
+	if err != nil {
+		return err
+	}
+
+	// Start from an empty database
+	if _, err := m.execInService(ctx, cfg, redisCommand(provision, "-n", strconv.Itoa(index), "FLUSHDB")); err != nil {
+		return err
+	}
+
+	resource.Name = fmt.Sprintf("db%d", index)
+	resource.Index = index
+	vars["DB"] = strconv.Itoa(index)
+	resource.Env = connectionEnv(serviceName, provision, fmt.Sprintf("redis://%s%s/%d", auth, net.JoinHostPort(host, port), index), vars)
+	return nil
+}
+
+// createBucket creates an S3 bucket on LocalStack
+func (m *Manager) createBucket(ctx context.Context, serviceName string, cfg config.ServiceConfig, resource *db.ServiceResource, bucket string) error {
+	provision := cfg.Provision
+	region := provision.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	command := serviceCommand{Args: []string{"awslocal", "s3", "mb", "s3://" + bucket, "--region", region}}
+	if output, err := m.execInService(ctx, cfg, command); err != nil && !strings.Contains(string(output), "BucketAlreadyOwnedByYou") {
+		return err
+	}
+
+	host, port := provisionAddress(provision, defaultS3Port)
+	endpoint := "http://" + net.JoinHostPort(host, port)
+
+	resource.Name = bucket
+	resource.Env = connectionEnv(serviceName, provision, endpoint, map[string]string{
+		"BUCKET":   bucket,
+		"ENDPOINT": endpoint,
+		"REGION":   region,
+	})
+	return nil
+}
+
+// freeRedisDatabase returns the lowest logical database not yet given to a
+// worktree. Database 0 stays shared.
+func freeRedisDatabase(ctx context.Context, serviceName string, provision *config.ProvisionConfig, resources *db.ServiceResourceRepository) (int, error) {
+	databases := provision.Databases
+	if databases == 0 {
+		databases = defaultRedisDatabases
+	}
+
+	existing, err := resources.ListByService(ctx, serviceName)
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[int]bool, len(existing))
+	for _, r := range existing {
+		used[r.Index] = true
+	}
+
+	for index := 1; index < databases; index++ {
+		if !used[index] {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("no free redis databases on %s: all %d are in use", serviceName, databases-1)
+}
+
+// deleteKeysScript deletes the keys matching a pattern
+const deleteKeysScript = `local n = 0 for _, k in ipairs(redis.call('KEYS', ARGV[1])) do redis.call('DEL', k) n = n + 1 end return n`
+
+// postgresCommand runs SQL as the admin user
+func postgresCommand(provision *config.ProvisionConfig, sql string) serviceCommand {
+	command := serviceCommand{
+		Args:  []string{"psql", "-v", "ON_ERROR_STOP=1", "-U", postgresUser(provision), "-d", "postgres", "-f", "-"},
+		Stdin: sql,
+	}
+	if provision.Password != "" {
+		command.Env = map[string]string{"PGPASSWORD": provision.Password}
+	}
+	return command
+}
+
+// dropPostgresSQL returns SQL that drops a worktree's database and role
+func dropPostgresSQL(name string) string {
+	return fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = '%s';\nDROP DATABASE IF EXISTS \"%s\";\nDROP ROLE IF EXISTS \"%s\";\n", name, name, name)
+}
+
+// redisCommand runs redis-cli with the configured password
+func redisCommand(provision *config.ProvisionConfig, args ...string) serviceCommand {
+	command := serviceCommand{Args: append([]string{"redis-cli"}, args...)}
+	if provision.Password != "" {
+		command.Env = map[string]string{"REDISCLI_AUTH": provision.Password}
+	}
+	return command
+}
+
+// provisionAddress returns the host and port worktrees connect to
+func provisionAddress(provision *config.ProvisionConfig, defaultPort int) (string, string) {
+	host := provision.Host
+	if host == "" {
+		host = defaultProvisionHost
+	}
+	port := provision.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	return host, strconv.Itoa(port)
+}
+
+// connectionEnv names connection variables after the service, e.g.
+// POSTGRES_URL and POSTGRES_DB for a service named postgres, and exports
+// the URL under url_env too when configured
+func connectionEnv(serviceName string, provision *config.ProvisionConfig, url string, vars map[string]string) map[string]string {
+	prefix := envName(serviceName)
+	env := make(map[string]string, len(vars)+2)
+	for key, value := range vars {
+		env[prefix+"_"+key] = value
+	}
+	env[prefix+"_URL"] = url
+	if provision.URLEnv != "" {
+		env[provision.URLEnv] = url
+	}
+	return env
+}
+
+// envName converts a service name into an environment variable prefix.
+// Versions share their service's prefix, as a worktree uses only one.
+func envName(serviceName string) string {
+	serviceName, _ = config.SplitVersionedName(serviceName)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, serviceName)
+}
+
+// resourceName builds a database or bucket name from the worktree's base
+// name and a unique suffix, using only lowercase letters, digits and sep
+func resourceName(baseName, suffix string, sep rune) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return sep
+		}
+	}, baseName)
+
+	// Names must start with a letter
+	if clean == "" || clean[0] < 'a' || clean[0] > 'z' {
+		clean = "wt" + string(sep) + clean
+	}
+	if limit := maxResourceNameLength - len(suffix) - 1; len(clean) > limit {
+		clean = clean[:limit]
+	}
+	return strings.TrimRight(clean, string(sep)) + string(sep) + suffix
+}
+
+// randomSecret returns a random password
+func randomSecret() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+)
+
+// ManualHolder holds the reference of a service started by hand, e.g. with
+// `vibeman service start`, so it is not stopped as unused
+const ManualHolder = "manual"
+
+// applyReferences reports the persisted references of a service, which
+// include those held by other processes
+func (m *Manager) applyReferences(instance *ServiceInstance) {
+	if m.database == nil {
+		return
+	}
+	holders, err := db.NewServiceReferenceRepository(m.database).ListHolders(context.Background(), instance.Name)
+	if err != nil {
+		logger.WithError(err).WithField("service", instance.Name).Warn("Failed to load service references")
+		return
+	}
+	instance.RefCount = len(holders)
+	instance.Repositories = append([]string{}, holders...)
+}
+
+// ReleaseReferences removes every reference holder has, including those to
+// services its configuration no longer lists, so they are stopped by the
+// idle stopper once nothing else uses them
+func (m *Manager) ReleaseReferences(ctx context.Context, holder string) error {
+	if m.database != nil {
+		if err := db.NewServiceReferenceRepository(m.database).RemoveHolder(ctx, holder); err != nil {
+			return err
+		}
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, instance := range m.services {
+		instance.mutex.Lock()
+		if i := slices.Index(instance.Repositories, holder); i >= 0 {
+			instance.Repositories = slices.Delete(instance.Repositories, i, i+1)
+			instance.RefCount--
+		}
+		instance.mutex.Unlock()
+	}
+	return nil
+}
+
+// ReconcileReferences brings persisted references in line with the
+// worktrees that are actually running: references held by worktrees that
+// stopped or no longer exist while nothing was watching are dropped, and
+// running worktrees get a reference to every service their configuration
+// uses. Run it at startup, before stopping unused services.
+func (m *Manager) ReconcileReferences(ctx context.Context) error {
+	if m.database == nil {
+		return nil
+	}
+
+	repositories, err := db.NewRepositoryRepository(m.database).List(ctx)
+	if err != nil {
+		return err
+	}
+	worktrees, err := db.NewWorktreeRepository(m.database).List(ctx, "", "")
+	if err != nil {
+		return err
+	}
+
+	// Holders that may keep their references: running worktrees, by ID or by
+	// container name, whole repositories and services started by hand
+	live := map[string]bool{ManualHolder: true}
+	repoNames := make(map[string]string, len(repositories))
+	for _, repo := range repositories {
+		live[repo.Name] = true
+		repoNames[repo.ID] = repo.Name
+	}
+	var running []db.Worktree
+	for _, worktree := range worktrees {
+		if worktree.Status != db.StatusRunning {
+			continue
+		}
+		running = append(running, worktree)
+		live[worktree.ID] = true
+		live[repoNames[worktree.RepositoryID]+"-"+worktree.Name] = true
+	}
+
+	references := db.NewServiceReferenceRepository(m.database)
+	list, err := references.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, reference := range list {
+		if live[reference.Holder] {
+			continue
+		}
+		if _, err := references.Remove(ctx, reference.Service, reference.Holder); err != nil {
+			return err
+		}
+		logger.WithFields(logger.Fields{
+			"service": reference.Service,
+			"holder":  reference.Holder,
+		}).Info("Dropped stale service reference")
+	}
+
+	for _, worktree := range running {
+		repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
+		if err != nil {
+			continue
+		}
+		for _, serviceName := range repoConfig.Repository.Services.Names() {
+			if _, err := m.serviceConfig(serviceName); err != nil {
+				continue
+			}
+			if err := references.Add(ctx, serviceName, worktree.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RunIdleStopper stops running services that nothing has referenced for
+// the grace period, checking every interval until ctx is cancelled. Stops
+// are recorded in the audit log.
+func (m *Manager) RunIdleStopper(ctx context.Context, grace, interval time.Duration) {
+	if grace <= 0 || m.database == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	idleSince := map[string]time.Time{}
+	for {
+		m.stopIdleServices(ctx, idleSince, grace, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopIdleServices stops the services whose grace period ran out
+func (m *Manager) stopIdleServices(ctx context.Context, idleSince map[string]time.Time, grace time.Duration, now time.Time) {
+	m.mutex.RLock()
+	var names []string
+	var graph *config.ServiceGraph
+	if m.config.Services != nil {
+		names = slices.Sorted(maps.Keys(m.config.Services.All()))
+		graph, _ = config.NewServiceGraph(m.config.Services.Services)
+	}
+	m.mutex.RUnlock()
+
+	references := db.NewServiceReferenceRepository(m.database)
+	for _, name := range names {
+		holders, err := references.ListHolders(ctx, name)
+		if err != nil {
+			logger.WithError(err).WithField("service", name).Warn("Failed to count service references")
+			continue
+		}
+
+		// A service is in use while anything that depends on it runs
+		inUse := len(holders) > 0
+		if !inUse && graph != nil {
+			for _, dependent := range graph.Dependents(name) {
+				if m.isRunning(ctx, dependent) {
+					inUse = true
+					break
+				}
+			}
+		}
+		if !idleExpired(idleSince, name, inUse, !inUse && m.isRunning(ctx, name), grace, now) {
+			continue
+		}
+
+		err = m.stopUnused(ctx, name)
+		params := audit.Params{"reason": "idle", "idle_for": now.Sub(idleSince[name]).Round(time.Second).String()}
+		audit.Record(ctx, audit.ActionServiceStop, audit.Target{Type: audit.TargetService, ID: name, Name: name}, params, err)
+		delete(idleSince, name)
+		if err != nil {
+			logger.WithError(err).WithField("service", name).Warn("Failed to stop unused service")
+			continue
+		}
+		logger.WithField("service", name).Info("Stopped unused service")
+	}
+}
+
+// idleExpired tracks when each service was first seen running unused and
+// reports whether it has now been unused for the grace period
+func idleExpired(idleSince map[string]time.Time, name string, inUse, running bool, grace time.Duration, now time.Time) bool {
+	if inUse || !running {
+		delete(idleSince, name)
+		return false
+	}
+	since, seen := idleSince[name]
+	if !seen {
+		idleSince[name] = now
+		return false
+	}
+	return now.Sub(since) >= grace
+}
+
+// isRunning reports whether a service's container is running, whether or
+// not this process started it
+func (m *Manager) isRunning(ctx context.Context, name string) bool {
+	m.mutex.RLock()
+	instance := m.services[name]
+	m.mutex.RUnlock()
+	if instance != nil {
+		instance.mutex.RLock()
+		status := instance.Status
+		instance.mutex.RUnlock()
+		if status.Up() {
+			return true
+		}
+	}
+
+	serviceConfig, err := m.serviceConfig(name)
+	if err != nil || !serviceConfig.IsValid() {
+		return false
+	}
+	status, err := m.serviceStatus(ctx, serviceConfig)
+	return err == nil && status == StatusRunning
+}
+
+// stopUnused stops a service regardless of its in-memory reference count
+func (m *Manager) stopUnused(ctx context.Context, name string) error {
+	serviceConfig, err := m.serviceConfig(name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.RLock()
+	instance := m.services[name]
+	m.mutex.RUnlock()
+	if instance == nil {
+		return m.stopServiceContainer(ctx, &ServiceInstance{Name: name, Config: serviceConfig})
+	}
+
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+
+	instance.Status = StatusStopping
+	if err := m.stopServiceContainer(ctx, instance); err != nil {
+		instance.Status = StatusError
+		return err
+	}
+	instance.Status = StatusStopped
+	instance.RefCount = 0
+	instance.ContainerID = ""
+	instance.Repositories = []string{}
+	return nil
+}
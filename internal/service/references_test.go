@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vibeman/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferences_Persisted(t *testing.T) {
+	manager, _ := newProvisionTestManager(t)
+	ctx := context.Background()
+
+	// References are recorded even when another process runs the service
+	require.NoError(t, manager.AddReference("postgres", "wt-1"))
+	require.NoError(t, manager.AddReference("postgres", "wt-1"))
+	require.NoError(t, manager.AddReference("postgres", ManualHolder))
+
+	other := New(manager.config)
+	other.SetDatabase(manager.database)
+	result, err := other.GetService("postgres")
+	require.NoError(t, err)
+	instance := result.(*ServiceInstance)
+	assert.Equal(t, 2, instance.RefCount)
+	assert.ElementsMatch(t, []string{"wt-1", ManualHolder}, instance.Repositories)
+
+	require.NoError(t, other.RemoveReference("postgres", "wt-1"))
+	assert.ErrorContains(t, other.RemoveReference("postgres", "wt-1"), "repository reference not found")
+
+	holders, err := db.NewServiceReferenceRepository(manager.database).ListHolders(ctx, "postgres")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ManualHolder}, holders)
+}
+
+func TestReleaseReferences(t *testing.T) {
+	manager, _ := newProvisionTestManager(t)
+	ctx := context.Background()
+
+	// Every reference the worktree holds goes, whatever it is to
+	require.NoError(t, manager.AddReference("postgres", "wt-1"))
+	require.NoError(t, manager.AddReference("redis", "wt-1"))
+	require.NoError(t, manager.AddReference("postgres", "wt-2"))
+	require.NoError(t, manager.ReleaseReferences(ctx, "wt-1"))
+
+	references := db.NewServiceReferenceRepository(manager.database)
+	list, err := references.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "postgres", list[0].Service)
+	assert.Equal(t, "wt-2", list[0].Holder)
+}
+
+func TestReconcileReferences(t *testing.T) {
+	manager, _ := newProvisionTestManager(t)
+	ctx := context.Background()
+
+	runningPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(runningPath, "vibeman.toml"), []byte(`
+[repository]
+name = "myapp"
+
+[repository.services]
+redis = { required = true }
+unknown = { required = false }
+`), 0644))
+
+	require.NoError(t, db.NewRepositoryRepository(manager.database).Create(ctx, &db.Repository{ID: "repo-1", Name: "myapp", Path: "/tmp/myapp"}))
+	worktrees := db.NewWorktreeRepository(manager.database)
+	require.NoError(t, worktrees.Create(ctx, &db.Worktree{
+		ID: "wt-running", RepositoryID: "repo-1", Name: "feature", Branch: "feature", Path: runningPath, Status: db.StatusRunning,
+	}))
+	require.NoError(t, worktrees.Create(ctx, &db.Worktree{
+		ID: "wt-stopped", RepositoryID: "repo-1", Name: "old", Branch: "old", Path: t.TempDir(), Status: db.StatusStopped,
+	}))
+
+	references := db.NewServiceReferenceRepository(manager.database)
+	require.NoError(t, references.Add(ctx, "postgres", "wt-stopped"))
+	require.NoError(t, references.Add(ctx, "postgres", "wt-deleted"))
+	require.NoError(t, references.Add(ctx, "postgres", "myapp-feature"))
+	require.NoError(t, references.Add(ctx, "postgres", "myapp"))
+	require.NoError(t, references.Add(ctx, "mailhog", ManualHolder))
+
+	require.NoError(t, manager.ReconcileReferences(ctx))
+
+	list, err := references.List(ctx)
+	require.NoError(t, err)
+	var got []string
+	for _, reference := range list {
+		got = append(got, reference.Service+"/"+reference.Holder)
+	}
+	assert.ElementsMatch(t, []string{
+		"postgres/myapp-feature",
+		"postgres/myapp",
+		"mailhog/" + ManualHolder,
+		"redis/wt-running",
+	}, got)
+}
+
+func TestIdleExpired(t *testing.T) {
+	idleSince := map[string]time.Time{}
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	grace := 15 * time.Minute
+
+	// The grace period starts when the service is first seen unused
+	assert.False(t, idleExpired(idleSince, "postgres", false, true, grace, start))
+	assert.False(t, idleExpired(idleSince, "postgres", false, true, grace, start.Add(10*time.Minute)))
+	assert.True(t, idleExpired(idleSince, "postgres", false, true, grace, start.Add(15*time.Minute)))
+
+	// A new reference resets it
+	assert.False(t, idleExpired(idleSince, "postgres", true, true, grace, start.Add(16*time.Minute)))
+	assert.False(t, idleExpired(idleSince, "postgres", false, true, grace, start.Add(17*time.Minute)))
+	assert.False(t, idleExpired(idleSince, "postgres", false, true, grace, start.Add(31*time.Minute)))
+	assert.True(t, idleExpired(idleSince, "postgres", false, true, grace, start.Add(32*time.Minute)))
+
+	// Stopped services are never stopped again
+	assert.False(t, idleExpired(idleSince, "redis", false, false, grace, start))
+	assert.NotContains(t, idleSince, "redis")
+}
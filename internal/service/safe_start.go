@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"time"
 
 	"vibeman/internal/config"
@@ -27,7 +26,7 @@ func (m *Manager) waitForServiceStart(ctx context.Context, name string) error {
 			}
 
 			switch status {
-			case StatusRunning:
+			case StatusRunning, StatusDegraded:
 				// Service started successfully, increment ref count
 				return m.incrementRefCount(name)
 			case StatusError, StatusStopped:
@@ -93,7 +92,7 @@ func (m *Manager) startServiceSafe(ctx context.Context, name string) error {
 		m.mutex.Lock()
 		// Double-check after acquiring write lock
 		if _, exists := m.services[name]; !exists {
-			serviceConfig, configExists := m.config.Services.Services[name]
+			serviceConfig, configExists := m.config.Services.Lookup(name)
 			if !configExists {
 				m.mutex.Unlock()
 				return fmt.Errorf("service configuration not found: %s", name)
@@ -118,13 +117,13 @@ func (m *Manager) startServiceSafe(ctx context.Context, name string) error {
 	}
 
 	switch status {
-	case StatusRunning:
+	case StatusRunning, StatusDegraded:
 		// Already running, just increment ref count
 		return m.incrementRefCount(name)
 	case StatusStarting:
 		// Wait for it to complete
 		return m.waitForServiceStart(ctx, name)
-	case StatusStopped, StatusError:
+	case StatusStopped, StatusError, StatusCrashLoop:
 		// Need to start the service
 		return m.doStartService(ctx, name)
 	default:
@@ -206,7 +205,7 @@ func (m *Manager) startServiceContainerSafe(ctx context.Context, name string) er
 	instance.mutex.RUnlock()
 
 	// Start the container
-	containerID, err := m.createServiceContainer(ctx, config)
+	containerID, err := m.createServiceContainer(ctx, name, config)
 	if err != nil {
 		return err
 	}
@@ -216,38 +215,16 @@ func (m *Manager) startServiceContainerSafe(ctx context.Context, name string) er
 	instance.ContainerID = containerID
 	instance.mutex.Unlock()
 
-	return nil
+	// Nothing may use the service before it is ready
+	return m.checker.WaitReady(ctx, config.Health, containerID)
 }
 
 // createServiceContainer creates and starts a service container
 // This is a separate method to keep the logic clean and testable
-func (m *Manager) createServiceContainer(ctx context.Context, config config.ServiceConfig) (string, error) {
-	// All services now use Docker Compose
-	if !config.IsValid() {
-		return "", fmt.Errorf("invalid service configuration: compose_file and service name required")
-	}
-
-	// Use docker compose to start the service
-	args := []string{
-		"compose",
-		"-f", config.ComposeFile,
-		"up",
-		"-d",
-		config.Service,
-	}
-
-	// Execute docker compose up
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to start compose service: %w, output: %s", err, string(output))
+func (m *Manager) createServiceContainer(ctx context.Context, name string, config config.ServiceConfig) (string, error) {
+	started := &ServiceInstance{Name: name, Config: config}
+	if err := m.startServiceContainer(ctx, started); err != nil {
+		return "", err
 	}
-
-	// Get container ID from docker compose
-	containerID, err := m.getComposeContainerID(ctx, config.ComposeFile, config.Service)
-	if err != nil {
-		return "", fmt.Errorf("failed to get container ID: %w", err)
-	}
-
-	return containerID, nil
+	return started.ContainerID, nil
 }
@@ -0,0 +1,571 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"vibeman/internal/config"
+	"vibeman/internal/constants"
+	"vibeman/internal/db"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+	"vibeman/internal/xdg"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Snapshot kinds
+const (
+	SnapshotLogical = "logical" // pg_dump or Redis RDB file, taken through the running service
+	SnapshotVolume  = "volume"  // Tarballs of the container's named volumes, taken while it is stopped
+)
+
+// snapshotMetadata is the file next to a snapshot's data that describes it
+const snapshotMetadata = "snapshot.json"
+
+// Snapshot describes a saved copy of a service's data. Snapshots live in
+// their own directory under xdg.DataDir()/snapshots/<service>.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Service   string    `json:"service"`
+	Tag       string    `json:"tag,omitempty"`
+	Kind      string    `json:"kind"`
+	Type      string    `json:"type,omitempty"`     // postgres or redis, for logical snapshots
+	Database  string    `json:"database,omitempty"` // Postgres database that was dumped
+	Volumes   []string  `json:"volumes,omitempty"`  // Volumes saved by a volume snapshot
+	Files     []string  `json:"files"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SnapshotOptions selects what a snapshot or restore covers
+type SnapshotOptions struct {
+	Tag      string // Name for a new snapshot
+	Kind     string // logical (default) or volume
+	Database string // Postgres database to dump or restore into
+	Worktree string // Worktree, by ID or name, whose provisioned database to dump or restore into
+}
+
+// dockerStreamFunc runs a docker command on the host with its input and
+// output streamed
+type dockerStreamFunc func(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error
+
+// dockerStream runs a docker command on the host
+func dockerStream(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := telemetry.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// CreateSnapshot saves the data of a running service. A logical snapshot
+// dumps one Postgres database (postgres unless another one or a worktree's
+// is chosen) or the whole Redis dataset; a volume snapshot stops the service
+// and archives each of its named volumes, whatever runs in it.
+func (m *Manager) CreateSnapshot(ctx context.Context, name string, opts SnapshotOptions) (snapshot *Snapshot, err error) {
+	ctx, span := telemetry.Start(ctx, "service.CreateSnapshot", attribute.String("vibeman.service", name))
+	defer func() { telemetry.End(span, err) }()
+
+	serviceConfig, err := m.serviceConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Kind == "" {
+		opts.Kind = SnapshotLogical
+	}
+	if opts.Kind != SnapshotLogical && opts.Kind != SnapshotVolume {
+		return nil, fmt.Errorf("invalid snapshot kind %q: must be logical or volume", opts.Kind)
+	}
+
+	details, err := m.inspectService(ctx, serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("service %s must be running to snapshot it: %w", name, err)
+	}
+
+	root, err := m.snapshotRoot()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	snapshot = &Snapshot{Service: name, Tag: opts.Tag, Kind: opts.Kind, CreatedAt: now}
+	dir, err := newSnapshotDir(filepath.Join(root, name), now, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Kind == SnapshotVolume {
+		err = m.saveVolumes(ctx, name, serviceConfig, details, snapshot, dir)
+	} else {
+		err = m.dumpService(ctx, name, serviceConfig, details, snapshot, dir, opts)
+	}
+	if err == nil {
+		err = writeSnapshotMetadata(dir, snapshot)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	logger.WithFields(logger.Fields{
+		"service":  name,
+		"snapshot": snapshot.ID,
+		"kind":     snapshot.Kind,
+		"size":     snapshot.Size,
+	}).Info("Created service snapshot")
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots of a service, or of every service when
+// name is empty, newest first
+func (m *Manager) ListSnapshots(name string) ([]*Snapshot, error) {
+	root, err := m.snapshotRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := filepath.Join(root, name, "*", snapshotMetadata)
+	if name == "" {
+		pattern = filepath.Join(root, "*", "*", snapshotMetadata)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			logger.WithError(err).WithField("path", path).Warn("Skipping unreadable snapshot metadata")
+			continue
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+
+	slices.SortFunc(snapshots, func(a, b *Snapshot) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// RestoreSnapshot replaces a service's data with a snapshot, named by ID or
+// by tag (the newest snapshot with that tag). A logical Postgres snapshot is
+// restored into the database it was taken from unless another one or a
+// worktree's is chosen, so a snapshot can seed a fresh worktree database.
+func (m *Manager) RestoreSnapshot(ctx context.Context, name, ref string, opts SnapshotOptions) (snapshot *Snapshot, err error) {
+	ctx, span := telemetry.Start(ctx, "service.RestoreSnapshot", attribute.String("vibeman.service", name))
+	defer func() { telemetry.End(span, err) }()
+
+	serviceConfig, err := m.serviceConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err = m.findSnapshot(name, ref)
+	if err != nil {
+		return nil, err
+	}
+	root, err := m.snapshotRoot()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, name, snapshot.ID)
+
+	switch {
+	case snapshot.Kind == SnapshotVolume:
+		if opts.Database != "" || opts.Worktree != "" {
+			return nil, fmt.Errorf("volume snapshots restore the whole service, not a database")
+		}
+		err = m.restoreVolumes(ctx, name, serviceConfig, snapshot, dir)
+	case snapshot.Type == config.ProvisionPostgres:
+		database, role, resolveErr := m.snapshotDatabase(ctx, name, opts)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		if database == "" {
+			database = snapshot.Database
+		}
+		err = m.restorePostgres(ctx, serviceConfig, snapshot, dir, database, role)
+	case snapshot.Type == config.ProvisionRedis:
+		if opts.Database != "" || opts.Worktree != "" {
+			return nil, fmt.Errorf("redis snapshots restore the whole dataset, not a database")
+		}
+		err = m.restoreRedis(ctx, name, serviceConfig, dir)
+	default:
+		err = fmt.Errorf("cannot restore %s snapshot of type %q", snapshot.Kind, snapshot.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore snapshot %s: %w", snapshot.ID, err)
+	}
+
+	logger.WithFields(logger.Fields{
+		"service":  name,
+		"snapshot": snapshot.ID,
+	}).Info("Restored service snapshot")
+	return snapshot, nil
+}
+
+// findSnapshot returns a service's snapshot with the given ID, or its newest
+// snapshot with the given tag
+func (m *Manager) findSnapshot(name, ref string) (*Snapshot, error) {
+	snapshots, err := m.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == ref {
+			return snapshot, nil
+		}
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Tag == ref {
+			return snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot not found for service %s: %s", name, ref)
+}
+
+// snapshotRoot returns the directory snapshots are stored in
+func (m *Manager) snapshotRoot() (string, error) {
+	if m.snapshotDir != "" {
+		return m.snapshotDir, nil
+	}
+	dataDir, err := xdg.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find data directory: %w", err)
+	}
+	return filepath.Join(dataDir, "snapshots"), nil
+}
+
+// dumpService takes a logical snapshot through the service's own tools
+func (m *Manager) dumpService(ctx context.Context, name string, cfg config.ServiceConfig, details *containerDetails, snapshot *Snapshot, dir string, opts SnapshotOptions) error {
+	provision := snapshotProvision(cfg, details)
+	snapshot.Type = provision.Type
+
+	switch provision.Type {
+	case config.ProvisionPostgres:
+		database, _, err := m.snapshotDatabase(ctx, name, opts)
+		if err != nil {
+			return err
+		}
+		if database == "" {
+			database = "postgres"
+		}
+		snapshot.Database = database
+		return writeSnapshotFile(dir, "dump.sql", snapshot, func(w io.Writer) error {
+			command := postgresCommand(provision, "")
+			command.Args = []string{"pg_dump", "-U", postgresUser(provision), "--clean", "--if-exists", "--no-owner", "--no-privileges", "-d", database}
+			command.Output = w
+			_, err := m.execInService(ctx, cfg, command)
+			return err
+		})
+	case config.ProvisionRedis:
+		if opts.Database != "" || opts.Worktree != "" {
+			return fmt.Errorf("redis snapshots cover the whole dataset, not a database")
+		}
+		return writeSnapshotFile(dir, "dump.rdb", snapshot, func(w io.Writer) error {
+			command := redisCommand(provision, "--rdb", "-")
+			command.Output = w
+			_, err := m.execInService(ctx, cfg, command)
+			return err
+		})
+	}
+	return fmt.Errorf("logical snapshots support postgres and redis services; use a volume snapshot for %s", name)
+}
+
+// restorePostgres loads a dump into a database. With a role, the restored
+// objects are owned by it, as a worktree's database must be.
+func (m *Manager) restorePostgres(ctx context.Context, cfg config.ServiceConfig, snapshot *Snapshot, dir, database, role string) error {
+	if snapshot.Kind != SnapshotLogical || snapshot.Type != config.ProvisionPostgres {
+		return fmt.Errorf("snapshot %s is not a postgres dump", snapshot.ID)
+	}
+	file, err := os.Open(filepath.Join(dir, "dump.sql"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var input io.Reader = file
+	if role != "" {
+		input = io.MultiReader(strings.NewReader(fmt.Sprintf("SET ROLE \"%s\";\n", role)), file)
+	}
+
+	provision := snapshotProvision(cfg, nil)
+	command := postgresCommand(provision, "")
+	command.Args = []string{"psql", "-v", "ON_ERROR_STOP=1", "-q", "-U", postgresUser(provision), "-d", database, "-f", "-"}
+	command.Input = input
+	_, err = m.execInService(ctx, cfg, command)
+	return err
+}
+
+// restoreRedis replaces Redis's dump file and restarts it without saving,
+// so it loads the snapshot. Redis only loads the dump file when append-only
+// persistence is off.
+func (m *Manager) restoreRedis(ctx context.Context, name string, cfg config.ServiceConfig, dir string) error {
+	provision := snapshotProvision(cfg, nil)
+
+	output, err := m.execInService(ctx, cfg, redisCommand(provision, "CONFIG", "GET", "appendonly"))
+	if err != nil {
+		return err
+	}
+	if slices.Contains(strings.Fields(string(output)), "yes") {
+		return fmt.Errorf("redis has appendonly enabled and would ignore the snapshot; use a volume snapshot instead")
+	}
+	output, err = m.execInService(ctx, cfg, redisCommand(provision, "CONFIG", "GET", "dir"))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return fmt.Errorf("unexpected redis data directory %q", strings.TrimSpace(string(output)))
+	}
+
+	file, err := os.Open(filepath.Join(dir, "dump.rdb"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Keep Redis from saving over the new file before it restarts
+	if _, err := m.execInService(ctx, cfg, redisCommand(provision, "CONFIG", "SET", "save", "")); err != nil {
+		return err
+	}
+	copyCommand := serviceCommand{Args: []string{"sh", "-c", `cat > "$1/dump.rdb"`, "sh", fields[1]}, Input: file}
+	if _, err := m.execInService(ctx, cfg, copyCommand); err != nil {
+		return err
+	}
+	// The connection drops as Redis shuts down
+	m.execInService(ctx, cfg, redisCommand(provision, "SHUTDOWN", "NOSAVE"))
+
+	return m.startAfterSnapshot(ctx, name, cfg)
+}
+
+// saveVolumes stops the service and archives each of its named volumes
+func (m *Manager) saveVolumes(ctx context.Context, name string, cfg config.ServiceConfig, details *containerDetails, snapshot *Snapshot, dir string) error {
+	if len(details.Volumes) == 0 {
+		return fmt.Errorf("service %s has no named volumes to snapshot", name)
+	}
+	snapshot.Volumes = details.Volumes
+
+	return m.whileStopped(ctx, name, cfg, func() error {
+		for _, volume := range details.Volumes {
+			err := writeSnapshotFile(dir, volume+".tar.gz", snapshot, func(w io.Writer) error {
+				return m.runDocker(ctx, nil, w, "run", "--rm", "-v", volume+":/volume:ro",
+					constants.SnapshotHelperImage, "tar", "-czf", "-", "-C", "/volume", ".")
+			})
+			if err != nil {
+				return fmt.Errorf("failed to archive volume %s: %w", volume, err)
+			}
+		}
+		return nil
+	})
+}
+
+// restoreVolumes stops the service and replaces the contents of each
+// volume in the snapshot
+func (m *Manager) restoreVolumes(ctx context.Context, name string, cfg config.ServiceConfig, snapshot *Snapshot, dir string) error {
+	return m.whileStopped(ctx, name, cfg, func() error {
+		for _, volume := range snapshot.Volumes {
+			file, err := os.Open(filepath.Join(dir, volume+".tar.gz"))
+			if err != nil {
+				return err
+			}
+			err = m.runDocker(ctx, file, nil, "run", "--rm", "-i", "-v", volume+":/volume",
+				constants.SnapshotHelperImage, "sh", "-c", "find /volume -mindepth 1 -delete && tar -xzf - -C /volume")
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("failed to restore volume %s: %w", volume, err)
+			}
+		}
+		return nil
+	})
+}
+
+// whileStopped stops a service, runs fn and starts the service again, even
+// when fn fails. The supervisor leaves the service alone meanwhile.
+func (m *Manager) whileStopped(ctx context.Context, name string, cfg config.ServiceConfig, fn func() error) error {
+	m.mutex.RLock()
+	tracked := m.services[name]
+	m.mutex.RUnlock()
+	if tracked != nil {
+		tracked.mutex.Lock()
+		previous := tracked.Status
+		tracked.Status = StatusStopping
+		tracked.mutex.Unlock()
+		defer func() {
+			tracked.mutex.Lock()
+			if tracked.Status == StatusStopping {
+				tracked.Status = previous
+			}
+			tracked.mutex.Unlock()
+		}()
+	}
+
+	stop := []string{"compose", "-f", cfg.ComposeFile, "stop", cfg.Service}
+	if cfg.IsInline() {
+		stop = []string{"stop", cfg.Service}
+	}
+	if err := m.runDocker(ctx, nil, nil, stop...); err != nil {
+		return fmt.Errorf("failed to stop service %s: %w", name, err)
+	}
+	err := fn()
+	return errors.Join(err, m.startAfterSnapshot(ctx, name, cfg))
+}
+
+// startAfterSnapshot starts a service stopped for a snapshot or restore and
+// waits until it is ready
+func (m *Manager) startAfterSnapshot(ctx context.Context, name string, cfg config.ServiceConfig) error {
+	started := &ServiceInstance{Name: name, Config: cfg}
+	if err := m.restartService(ctx, started); err != nil {
+		return err
+	}
+	if err := m.checker.WaitReady(ctx, cfg.Health, started.ContainerID); err != nil {
+		return fmt.Errorf("service %s did not become ready: %w", name, err)
+	}
+
+	m.mutex.RLock()
+	tracked := m.services[name]
+	m.mutex.RUnlock()
+	if tracked != nil {
+		tracked.mutex.Lock()
+		tracked.ContainerID = started.ContainerID
+		tracked.mutex.Unlock()
+	}
+
+	m.refreshEnvFiles(ctx, name)
+	return nil
+}
+
+// snapshotDatabase returns the Postgres database chosen for a snapshot or
+// restore and, for a worktree's database, the role that owns it
+func (m *Manager) snapshotDatabase(ctx context.Context, name string, opts SnapshotOptions) (database, role string, err error) {
+	if opts.Worktree == "" {
+		return opts.Database, "", nil
+	}
+	if opts.Database != "" {
+		return "", "", fmt.Errorf("choose either a database or a worktree, not both")
+	}
+	if m.database == nil {
+		return "", "", fmt.Errorf("worktree databases require a database")
+	}
+
+	resources, err := db.NewServiceResourceRepository(m.database).ListByService(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	worktrees := db.NewWorktreeRepository(m.database)
+
+	var matches []*db.ServiceResource
+	for _, resource := range resources {
+		if resource.WorktreeID == opts.Worktree {
+			return resource.Name, resource.Name, nil
+		}
+		if worktree, err := worktrees.Get(ctx, resource.WorktreeID); err == nil && worktree.Name == opts.Worktree {
+			matches = append(matches, resource)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("worktree %s has no database on %s", opts.Worktree, name)
+	case 1:
+		return matches[0].Name, matches[0].Name, nil
+	}
+	return "", "", fmt.Errorf("several worktrees are named %s; use the worktree ID", opts.Worktree)
+}
+
+// snapshotProvision returns the provisioning settings used to reach a
+// service's data, guessing its type from the image when it has none
+func snapshotProvision(cfg config.ServiceConfig, details *containerDetails) *config.ProvisionConfig {
+	if cfg.Provision != nil {
+		return cfg.Provision
+	}
+	provision := &config.ProvisionConfig{}
+	if details != nil {
+		provision.Type = imageType(details.Image)
+	}
+	return provision
+}
+
+// postgresUser returns the Postgres admin user
+func postgresUser(provision *config.ProvisionConfig) string {
+	if provision.User != "" {
+		return provision.User
+	}
+	return defaultPostgresUser
+}
+
+// newSnapshotDir creates the directory of a new snapshot, named after the
+// time it was taken, and sets the snapshot's ID
+func newSnapshotDir(serviceDir string, now time.Time, snapshot *Snapshot) (string, error) {
+	if err := os.MkdirAll(serviceDir, constants.SecureDirPermissions); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	base := now.Format("20060102-150405")
+	for i := 1; ; i++ {
+		id := base
+		if i > 1 {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		dir := filepath.Join(serviceDir, id)
+		err := os.Mkdir(dir, constants.SecureDirPermissions)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+		snapshot.ID = id
+		return dir, nil
+	}
+}
+
+// writeSnapshotFile writes one of a snapshot's data files and records it
+func writeSnapshotFile(dir, name string, snapshot *Snapshot, write func(io.Writer) error) error {
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, constants.SecureFilePermissions)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	snapshot.Files = append(snapshot.Files, name)
+	snapshot.Size += info.Size()
+	return nil
+}
+
+// writeSnapshotMetadata saves the description of a snapshot next to its data
+func writeSnapshotMetadata(dir string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, snapshotMetadata), data, constants.SecureFilePermissions)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vibeman/internal/config"
+	"vibeman/internal/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotExec stands in for the tools run in service containers: dumps
+// write canned data and restores record what they were given
+type snapshotExec struct {
+	commands []serviceCommand
+	inputs   []string
+}
+
+func (s *snapshotExec) exec(ctx context.Context, cfg config.ServiceConfig, command serviceCommand) ([]byte, error) {
+	s.commands = append(s.commands, command)
+	if command.Output != nil {
+		fmt.Fprintf(command.Output, "-- dump of %s", cfg.Service)
+	}
+	if command.Input != nil {
+		data, err := io.ReadAll(command.Input)
+		if err != nil {
+			return nil, err
+		}
+		s.inputs = append(s.inputs, string(data))
+	}
+	return nil, nil
+}
+
+// newSnapshotTestManager creates a manager whose postgres and redis services
+// are running and whose snapshots go to a temporary directory
+func newSnapshotTestManager(t *testing.T) (*Manager, fakeContainers, *snapshotExec) {
+	t.Helper()
+
+	manager, containers := newDiscoveryTestManager(t)
+	manager.snapshotDir = t.TempDir()
+	recorder := &snapshotExec{}
+	manager.execInService = recorder.exec
+	return manager, containers, recorder
+}
+
+func TestSnapshot_Postgres(t *testing.T) {
+	manager, _, recorder := newSnapshotTestManager(t)
+	ctx := context.Background()
+
+	first, err := manager.CreateSnapshot(ctx, "postgres", SnapshotOptions{Tag: "baseline"})
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotLogical, first.Kind)
+	assert.Equal(t, config.ProvisionPostgres, first.Type)
+	assert.Equal(t, "postgres", first.Database)
+	assert.Equal(t, []string{"dump.sql"}, first.Files)
+	assert.Equal(t, []string{"pg_dump", "-U", "postgres", "--clean", "--if-exists", "--no-owner", "--no-privileges", "-d", "postgres"}, recorder.commands[0].Args)
+
+	data, err := os.ReadFile(filepath.Join(manager.snapshotDir, "postgres", first.ID, "dump.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "-- dump of postgres", string(data))
+	assert.Equal(t, int64(len(data)), first.Size)
+
+	second, err := manager.CreateSnapshot(ctx, "postgres", SnapshotOptions{Tag: "baseline", Database: "app"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	snapshots, err := manager.ListSnapshots("postgres")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, second.ID, snapshots[0].ID)
+
+	// A tag names its newest snapshot, which restores into its own database
+	restored, err := manager.RestoreSnapshot(ctx, "postgres", "baseline", SnapshotOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, restored.ID)
+	restore := recorder.commands[len(recorder.commands)-1]
+	assert.Equal(t, []string{"psql", "-v", "ON_ERROR_STOP=1", "-q", "-U", "postgres", "-d", "app", "-f", "-"}, restore.Args)
+	assert.Equal(t, []string{"-- dump of postgres"}, recorder.inputs)
+
+	_, err = manager.RestoreSnapshot(ctx, "postgres", "missing", SnapshotOptions{})
+	assert.EqualError(t, err, "snapshot not found for service postgres: missing")
+}
+
+func TestSnapshot_WorktreeDatabase(t *testing.T) {
+	manager, _, recorder := newSnapshotTestManager(t)
+	ctx := context.Background()
+
+	_, err := manager.CreateSnapshot(ctx, "postgres", SnapshotOptions{Tag: "baseline"})
+	require.NoError(t, err)
+	env, err := manager.ProvisionWorktree(ctx, "postgres", "0123456789ab", "myapp-feature")
+	require.NoError(t, err)
+	database := env["POSTGRES_DB"]
+
+	// Restoring into a worktree's database keeps its role as the owner
+	_, err = manager.RestoreSnapshot(ctx, "postgres", "baseline", SnapshotOptions{Worktree: "0123456789ab"})
+	require.NoError(t, err)
+	restore := recorder.commands[len(recorder.commands)-1]
+	assert.Contains(t, restore.Args, database)
+	assert.Equal(t, fmt.Sprintf("SET ROLE \"%s\";\n-- dump of postgres", database), recorder.inputs[len(recorder.inputs)-1])
+
+	_, err = manager.RestoreSnapshot(ctx, "postgres", "baseline", SnapshotOptions{Worktree: "other"})
+	assert.ErrorContains(t, err, "worktree other has no database on postgres")
+
+	_, err = manager.RestoreSnapshot(ctx, "postgres", "baseline", SnapshotOptions{Worktree: "0123456789ab", Database: "app"})
+	assert.ErrorContains(t, err, "choose either a database or a worktree")
+}
+
+func TestSnapshot_SeedsProvisionedDatabase(t *testing.T) {
+	manager, _, recorder := newSnapshotTestManager(t)
+	ctx := context.Background()
+
+	serviceConfig := manager.config.Services.Services["postgres"]
+	provision := *serviceConfig.Provision
+	provision.Seed = "baseline"
+	serviceConfig.Provision = &provision
+	manager.config.Services.Services["postgres"] = serviceConfig
+
+	_, err := manager.ProvisionWorktree(ctx, "postgres", "wt-1", "myapp-one")
+	assert.ErrorContains(t, err, "failed to find seed snapshot")
+
+	_, err = manager.CreateSnapshot(ctx, "postgres", SnapshotOptions{Tag: "baseline"})
+	require.NoError(t, err)
+
+	env, err := manager.ProvisionWorktree(ctx, "postgres", "wt-1", "myapp-one")
+	require.NoError(t, err)
+	require.NotEmpty(t, recorder.inputs)
+	assert.Equal(t, fmt.Sprintf("SET ROLE \"%s\";\n-- dump of postgres", env["POSTGRES_DB"]), recorder.inputs[len(recorder.inputs)-1])
+}
+
+func TestSnapshot_Redis(t *testing.T) {
+	manager, _, recorder := newSnapshotTestManager(t)
+	ctx := context.Background()
+
+	snapshot, err := manager.CreateSnapshot(ctx, "redis", SnapshotOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, config.ProvisionRedis, snapshot.Type)
+	assert.Equal(t, []string{"dump.rdb"}, snapshot.Files)
+	assert.Equal(t, []string{"redis-cli", "--rdb", "-"}, recorder.commands[0].Args)
+
+	_, err = manager.CreateSnapshot(ctx, "redis", SnapshotOptions{Database: "1"})
+	assert.ErrorContains(t, err, "redis snapshots cover the whole dataset")
+	_, err = manager.CreateSnapshot(ctx, "mailhog", SnapshotOptions{})
+	assert.ErrorContains(t, err, "must be running")
+	_, err = manager.CreateSnapshot(ctx, "redis", SnapshotOptions{Kind: "full"})
+	assert.EqualError(t, err, `invalid snapshot kind "full": must be logical or volume`)
+}
+
+func TestSnapshot_Volumes(t *testing.T) {
+	manager, containers, _ := newSnapshotTestManager(t)
+	ctx := context.Background()
+	containers["postgres"].Volumes = []string{"myapp_pgdata"}
+
+	var docker [][]string
+	var restored string
+	manager.runDocker = func(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+		docker = append(docker, args)
+		if stdout != nil {
+			io.WriteString(stdout, "archive")
+		}
+		if stdin != nil {
+			data, _ := io.ReadAll(stdin)
+			restored = string(data)
+		}
+		return nil
+	}
+	restarts := 0
+	manager.restartService = func(ctx context.Context, instance *ServiceInstance) error {
+		restarts++
+		instance.ContainerID = fmt.Sprintf("c%d", restarts)
+		return nil
+	}
+	manager.checker = health.NewChecker(func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte("running healthy"), nil
+	})
+	manager.services["postgres"] = &ServiceInstance{Name: "postgres", Status: StatusRunning, ContainerID: "c0"}
+
+	snapshot, err := manager.CreateSnapshot(ctx, "postgres", SnapshotOptions{Kind: SnapshotVolume})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"myapp_pgdata"}, snapshot.Volumes)
+	assert.Equal(t, []string{"myapp_pgdata.tar.gz"}, snapshot.Files)
+	require.Len(t, docker, 2)
+	assert.Equal(t, []string{"compose", "-f", "docker-compose.yml", "stop", "postgres"}, docker[0])
+	assert.Contains(t, docker[1], "myapp_pgdata:/volume:ro")
+
+	// The service is started again and tracked under its new container
+	instance := supervised(t, manager)
+	assert.Equal(t, StatusRunning, instance.Status)
+	assert.Equal(t, "c1", instance.ContainerID)
+
+	_, err = manager.RestoreSnapshot(ctx, "postgres", snapshot.ID, SnapshotOptions{Database: "app"})
+	assert.ErrorContains(t, err, "volume snapshots restore the whole service")
+
+	_, err = manager.RestoreSnapshot(ctx, "postgres", snapshot.ID, SnapshotOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "archive", restored)
+	assert.Contains(t, docker[len(docker)-1], "myapp_pgdata:/volume")
+	assert.Equal(t, 2, restarts)
+	assert.Equal(t, "c2", supervised(t, manager).ContainerID)
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibeman/internal/audit"
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/health"
+	"vibeman/internal/logger"
+	"vibeman/internal/telemetry"
+)
+
+// containerState is what Docker reports about a service's container
+type containerState struct {
+	Running  bool
+	ExitCode int
+}
+
+// stateFunc reports the state of a container
+type stateFunc func(ctx context.Context, containerID string) (*containerState, error)
+
+// restartFunc starts a service's container again and records its new ID
+type restartFunc func(ctx context.Context, instance *ServiceInstance) error
+
+// dockerContainerState inspects a container on the host. A container that
+// no longer exists is reported as exited.
+func dockerContainerState(ctx context.Context, containerID string) (*containerState, error) {
+	cmd := exec.CommandContext(ctx, "docker", "inspect", "--format", "{{.State.Status}} {{.State.ExitCode}}", containerID)
+	output, err := telemetry.CombinedOutput(ctx, cmd)
+	if err != nil {
+		if strings.Contains(string(output), "No such") {
+			return &containerState{ExitCode: -1}, nil
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected container state %q", strings.TrimSpace(string(output)))
+	}
+	exitCode, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected exit code %q", fields[1])
+	}
+	return &containerState{
+		Running:  fields[0] != "exited" && fields[0] != "dead",
+		ExitCode: exitCode,
+	}, nil
+}
+
+// RunSupervisor watches the services this process started, checking every
+// interval until ctx is cancelled. A service failing its health probe is
+// marked degraded; one whose container exited is restarted as its restart
+// policy allows, backing off between attempts, and marked crash-looping
+// once it has been restarted too many times in a row. Worktrees using a
+// service are told about each change in its audit trail, and their env
+// files are refreshed after a restart.
+func (m *Manager) RunSupervisor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.superviseServices(ctx, time.Now())
+		}
+	}
+}
+
+// superviseServices checks every supervised service once
+func (m *Manager) superviseServices(ctx context.Context, now time.Time) {
+	m.mutex.RLock()
+	instances := slices.Collect(maps.Values(m.services))
+	m.mutex.RUnlock()
+
+	for _, instance := range instances {
+		m.supervise(ctx, instance, now)
+	}
+}
+
+// supervise checks one service and acts on what it finds
+func (m *Manager) supervise(ctx context.Context, instance *ServiceInstance, now time.Time) {
+	instance.mutex.RLock()
+	status := instance.Status
+	containerID := instance.ContainerID
+	probe := instance.Config.Health
+	instance.mutex.RUnlock()
+
+	if !status.Up() || containerID == "" {
+		return // Stopped, or being started or stopped by someone else
+	}
+
+	state, err := m.containerState(ctx, containerID)
+	if err != nil {
+		logger.WithError(err).WithField("service", instance.Name).Debug("Failed to check service container")
+		return
+	}
+	if state.Running {
+		m.recordProbe(ctx, instance, status, containerID, m.checker.Check(ctx, probe, containerID), now)
+		return
+	}
+	m.handleExit(ctx, instance, status, containerID, state.ExitCode, now)
+}
+
+// recordProbe updates a running service with the result of its health
+// probe. A restarted service counts as recovered once it has stayed up for
+// its longest restart backoff.
+func (m *Manager) recordProbe(ctx context.Context, instance *ServiceInstance, status ServiceStatus, containerID string, probeErr error, now time.Time) {
+	instance.mutex.Lock()
+	if instance.Status != status || instance.ContainerID != containerID {
+		instance.mutex.Unlock()
+		return // Changed while the probe ran
+	}
+
+	instance.Health = health.StatusOf(probeErr)
+	instance.LastHealth = now
+	instance.HealthError = ""
+	stable := now.Sub(instance.lastRestart) >= instance.Config.Restart.MaxBackoffDuration()
+
+	next := StatusRunning
+	message := "recovered"
+	switch {
+	case probeErr != nil:
+		instance.HealthError = probeErr.Error()
+		next = StatusDegraded
+		message = "health probe failing: " + probeErr.Error()
+	case status == StatusCrashLoop && !stable:
+		next = StatusCrashLoop
+	case stable:
+		instance.Restarts = 0
+	}
+	instance.Status = next
+	instance.mutex.Unlock()
+
+	if next != status {
+		m.notify(ctx, instance.Name, next, message)
+	}
+}
+
+// handleExit applies the restart policy of a service whose container
+// exited. The first check after a crash schedules the restart; a later one
+// performs it once the backoff has passed.
+func (m *Manager) handleExit(ctx context.Context, instance *ServiceInstance, status ServiceStatus, containerID string, exitCode int, now time.Time) {
+	instance.mutex.Lock()
+	if instance.Status != status || instance.ContainerID != containerID {
+		instance.mutex.Unlock()
+		return
+	}
+
+	name := instance.Name
+	policy := instance.Config.Restart
+	limit := policy.RetryLimit()
+	instance.Health = health.StatusUnhealthy
+	instance.LastHealth = now
+	instance.HealthError = fmt.Sprintf("container exited with code %d", exitCode)
+
+	if !policy.ShouldRestart(exitCode) {
+		instance.Status = StatusError
+		instance.mutex.Unlock()
+		m.notify(ctx, name, StatusError, fmt.Sprintf("exited with code %d", exitCode))
+		return
+	}
+
+	if policy.Policy == config.RestartOnFailure && instance.Restarts >= limit {
+		instance.Status = StatusCrashLoop
+		instance.mutex.Unlock()
+		if status != StatusCrashLoop {
+			m.notify(ctx, name, StatusCrashLoop, fmt.Sprintf("exited with code %d; gave up after %d restarts", exitCode, limit))
+		}
+		return
+	}
+
+	if instance.nextRestart.IsZero() {
+		delay := policy.BackoffDelay(instance.Restarts + 1)
+		instance.nextRestart = now.Add(delay)
+		instance.Status = StatusDegraded
+		if instance.Restarts >= limit {
+			instance.Status = StatusCrashLoop
+		}
+		next := instance.Status
+		instance.mutex.Unlock()
+		m.notify(ctx, name, next, fmt.Sprintf("exited with code %d; restarting in %s", exitCode, delay))
+		return
+	}
+	if now.Before(instance.nextRestart) {
+		instance.mutex.Unlock()
+		return
+	}
+
+	instance.Restarts++
+	attempt := instance.Restarts
+	instance.lastRestart = now
+	instance.nextRestart = time.Time{}
+	restarted := &ServiceInstance{Name: name, Config: instance.Config}
+	instance.mutex.Unlock()
+
+	// Restart without holding the lock, so the service can still be listed
+	err := m.restartService(ctx, restarted)
+	params := audit.Params{"reason": "crash", "exit_code": exitCode, "attempt": attempt}
+	audit.Record(ctx, audit.ActionServiceRestart, audit.Target{Type: audit.TargetService, ID: name, Name: name}, params, err)
+
+	instance.mutex.Lock()
+	if err != nil {
+		instance.HealthError = err.Error()
+		next := instance.Status
+		instance.mutex.Unlock()
+		m.notify(ctx, name, next, fmt.Sprintf("restart %d failed: %v", attempt, err))
+		return
+	}
+	instance.ContainerID = restarted.ContainerID
+	instance.StartTime = now
+	instance.Status = StatusDegraded
+	if attempt >= limit {
+		instance.Status = StatusCrashLoop
+	}
+	next := instance.Status
+	instance.mutex.Unlock()
+
+	// Point worktrees at the new container
+	m.refreshEnvFiles(ctx, name)
+	m.notify(ctx, name, next, fmt.Sprintf("restarted (attempt %d)", attempt))
+}
+
+// notify logs a change in a service's state and records it in the audit
+// trail of every running worktree that uses the service, directly or
+// through a service that depends on it
+func (m *Manager) notify(ctx context.Context, name string, status ServiceStatus, message string) {
+	worktrees := m.dependentWorktrees(ctx, name)
+
+	fields := logger.Fields{"service": name, "status": status, "worktrees": len(worktrees)}
+	if status == StatusRunning {
+		logger.WithFields(fields).Info("Service " + message)
+	} else {
+		logger.WithFields(fields).Warn("Service " + message)
+	}
+
+	params := audit.Params{"service": name, "status": string(status), "message": message}
+	for _, worktree := range worktrees {
+		audit.Record(ctx, audit.ActionWorktreeNotify, audit.Target{Type: audit.TargetWorktree, ID: worktree.ID, Name: worktree.Name}, params, nil)
+	}
+}
+
+// dependentWorktrees returns the running worktrees whose configuration uses
+// a service or one of the services that depend on it
+func (m *Manager) dependentWorktrees(ctx context.Context, name string) []db.Worktree {
+	if m.database == nil {
+		return nil
+	}
+
+	affected := map[string]bool{name: true}
+	m.mutex.RLock()
+	if m.config.Services != nil {
+		if graph, err := config.NewServiceGraph(m.config.Services.Services); err == nil {
+			for _, layer := range graph.StopLayers(name) {
+				for _, dependent := range layer {
+					affected[dependent] = true
+				}
+			}
+		}
+	}
+	m.mutex.RUnlock()
+
+	worktrees, err := db.NewWorktreeRepository(m.database).List(ctx, "", string(db.StatusRunning))
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list worktrees to notify")
+		return nil
+	}
+
+	var result []db.Worktree
+	for _, worktree := range worktrees {
+		repoConfig, err := config.ParseRepositoryConfig(worktree.Path)
+		if err != nil {
+			continue
+		}
+		for _, service := range repoConfig.Repository.Services.Names() {
+			if affected[service] {
+				result = append(result, worktree)
+				break
+			}
+		}
+	}
+	return result
+}
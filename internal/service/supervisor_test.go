@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vibeman/internal/config"
+	"vibeman/internal/db"
+	"vibeman/internal/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// supervisedDocker stands in for Docker: containers are running or have
+// exited with a code, and restarting a service creates a new container
+type supervisedDocker struct {
+	states   map[string]*containerState
+	healthy  bool
+	restarts int
+}
+
+func newSupervisedManager(t *testing.T, restart *config.RestartPolicy) (*Manager, *supervisedDocker) {
+	t.Helper()
+
+	manager, _ := newProvisionTestManager(t)
+	serviceConfig := manager.config.Services.Services["postgres"]
+	serviceConfig.Restart = restart
+	manager.config.Services.Services["postgres"] = serviceConfig
+
+	containers := &supervisedDocker{states: map[string]*containerState{"c0": {Running: true}}, healthy: true}
+	manager.containerState = func(ctx context.Context, containerID string) (*containerState, error) {
+		return containers.states[containerID], nil
+	}
+	manager.restartService = func(ctx context.Context, instance *ServiceInstance) error {
+		containers.restarts++
+		instance.ContainerID = fmt.Sprintf("c%d", containers.restarts)
+		containers.states[instance.ContainerID] = &containerState{Running: true}
+		return nil
+	}
+	manager.checker = health.NewChecker(func(ctx context.Context, args ...string) ([]byte, error) {
+		if containers.healthy {
+			return []byte("running healthy"), nil
+		}
+		return []byte("running unhealthy"), nil
+	})
+	manager.services["postgres"] = &ServiceInstance{
+		Name:        "postgres",
+		Status:      StatusRunning,
+		ContainerID: "c0",
+		Config:      serviceConfig,
+	}
+	return manager, containers
+}
+
+func supervised(t *testing.T, manager *Manager) *ServiceInstance {
+	t.Helper()
+	instance, err := manager.getService("postgres")
+	require.NoError(t, err)
+	return instance
+}
+
+func TestSupervise_RestartsWithBackoff(t *testing.T) {
+	policy := &config.RestartPolicy{Policy: config.RestartOnFailure, MaxRetries: 2, Backoff: "1s", MaxBackoff: "4s"}
+	manager, containers := newSupervisedManager(t, policy)
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	manager.superviseServices(ctx, now)
+	assert.Equal(t, StatusRunning, supervised(t, manager).Status)
+
+	// A crash is noticed first and restarted once the backoff has passed
+	containers.states["c0"] = &containerState{ExitCode: 1}
+	manager.superviseServices(ctx, now)
+	assert.Equal(t, StatusDegraded, supervised(t, manager).Status)
+	assert.Equal(t, 0, containers.restarts)
+
+	manager.superviseServices(ctx, now.Add(time.Second))
+	instance := supervised(t, manager)
+	assert.Equal(t, 1, containers.restarts)
+	assert.Equal(t, "c1", instance.ContainerID)
+	assert.Equal(t, 1, instance.Restarts)
+
+	manager.superviseServices(ctx, now.Add(2*time.Second))
+	assert.Equal(t, StatusRunning, supervised(t, manager).Status)
+
+	// The next restart waits twice as long
+	containers.states["c1"] = &containerState{ExitCode: 137}
+	manager.superviseServices(ctx, now.Add(3*time.Second))
+	manager.superviseServices(ctx, now.Add(4*time.Second))
+	assert.Equal(t, 1, containers.restarts)
+	manager.superviseServices(ctx, now.Add(5*time.Second))
+	assert.Equal(t, 2, containers.restarts)
+	assert.Equal(t, StatusCrashLoop, supervised(t, manager).Status)
+
+	// on-failure gives up after max_retries restarts
+	containers.states["c2"] = &containerState{ExitCode: 1}
+	for i := 6; i < 120; i++ {
+		manager.superviseServices(ctx, now.Add(time.Duration(i)*time.Second))
+	}
+	assert.Equal(t, 2, containers.restarts)
+	instance = supervised(t, manager)
+	assert.Equal(t, StatusCrashLoop, instance.Status)
+	assert.Equal(t, "container exited with code 1", instance.HealthError)
+}
+
+func TestSupervise_RecoveryResetsRestarts(t *testing.T) {
+	policy := &config.RestartPolicy{Policy: config.RestartAlways, Backoff: "1s", MaxBackoff: "10s"}
+	manager, containers := newSupervisedManager(t, policy)
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// always restarts clean exits too
+	containers.states["c0"] = &containerState{ExitCode: 0}
+	manager.superviseServices(ctx, now)
+	manager.superviseServices(ctx, now.Add(time.Second))
+	assert.Equal(t, 1, supervised(t, manager).Restarts)
+
+	manager.superviseServices(ctx, now.Add(5*time.Second))
+	assert.Equal(t, 1, supervised(t, manager).Restarts)
+
+	manager.superviseServices(ctx, now.Add(11*time.Second))
+	instance := supervised(t, manager)
+	assert.Equal(t, StatusRunning, instance.Status)
+	assert.Equal(t, 0, instance.Restarts)
+}
+
+func TestSupervise_WithoutPolicy(t *testing.T) {
+	manager, containers := newSupervisedManager(t, nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	// A failing probe degrades the service until it passes again
+	containers.healthy = false
+	manager.superviseServices(ctx, now)
+	instance := supervised(t, manager)
+	assert.Equal(t, StatusDegraded, instance.Status)
+	assert.Equal(t, health.StatusUnhealthy, instance.Health)
+
+	containers.healthy = true
+	manager.superviseServices(ctx, now)
+	assert.Equal(t, StatusRunning, supervised(t, manager).Status)
+
+	// Without a policy a crashed service is left down
+	containers.states["c0"] = &containerState{ExitCode: 1}
+	manager.superviseServices(ctx, now)
+	manager.superviseServices(ctx, now.Add(time.Minute))
+	assert.Equal(t, StatusError, supervised(t, manager).Status)
+	assert.Equal(t, 0, containers.restarts)
+}
+
+func TestDependentWorktrees(t *testing.T) {
+	manager, _ := newProvisionTestManager(t)
+	ctx := context.Background()
+	manager.config.Services.Services["api"] = config.ServiceConfig{ComposeFile: "docker-compose.yml", Service: "api", DependsOn: []string{"postgres"}}
+
+	worktreeWith := func(id string, status db.WorktreeStatus, service string) {
+		path := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(path, "vibeman.toml"), []byte(fmt.Sprintf(`
+[repository]
+name = "myapp"
+
+[repository.services]
+%s = { required = true }
+`, service)), 0644))
+		require.NoError(t, db.NewWorktreeRepository(manager.database).Create(ctx, &db.Worktree{
+			ID: id, RepositoryID: "repo-1", Name: id, Branch: id, Path: path, Status: status,
+		}))
+	}
+	require.NoError(t, db.NewRepositoryRepository(manager.database).Create(ctx, &db.Repository{ID: "repo-1", Name: "myapp", Path: "/tmp/myapp"}))
+	worktreeWith("wt-postgres", db.StatusRunning, "postgres")
+	worktreeWith("wt-api", db.StatusRunning, "api")
+	worktreeWith("wt-redis", db.StatusRunning, "redis")
+	worktreeWith("wt-stopped", db.StatusStopped, "postgres")
+
+	var ids []string
+	for _, worktree := range manager.dependentWorktrees(ctx, "postgres") {
+		ids = append(ids, worktree.ID)
+	}
+	assert.ElementsMatch(t, []string{"wt-postgres", "wt-api"}, ids)
+}
@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Run runs cmd inside a span recording its arguments and exit code
+func Run(ctx context.Context, cmd *exec.Cmd) error {
+	end := TrackCommand(ctx, cmd)
+	err := cmd.Run()
+	end(err)
+	return err
+}
+
+// Output runs cmd via cmd.Output inside a span
+func Output(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	end := TrackCommand(ctx, cmd)
+	output, err := cmd.Output()
+	end(err)
+	return output, err
+}
+
+// CombinedOutput runs cmd via cmd.CombinedOutput inside a span
+func CombinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	end := TrackCommand(ctx, cmd)
+	output, err := cmd.CombinedOutput()
+	end(err)
+	return output, err
+}
+
+// TrackCommand starts a span for a command that is driven with Start/Wait.
+// The returned function must be called with the result of cmd.Wait (or the
+// Start error) to record the exit code and end the span.
+func TrackCommand(ctx context.Context, cmd *exec.Cmd) func(err error) {
+	name := filepath.Base(cmd.Path)
+	if len(cmd.Args) > 0 {
+		name = filepath.Base(cmd.Args[0])
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("process.executable.name", name),
+		attribute.StringSlice("process.command_args", cmd.Args),
+	}
+	if cmd.Dir != "" {
+		attrs = append(attrs, attribute.String("process.working_directory", cmd.Dir))
+	}
+
+	_, span := Tracer().Start(ctx, "exec "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return func(err error) {
+		exitCode := -1
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+		span.SetAttributes(attribute.Int("process.exit.code", exitCode))
+		End(span, err)
+	}
+}
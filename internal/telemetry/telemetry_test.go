@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"vibeman/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func attrValue(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, attr := range attrs {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestInit_Disabled(t *testing.T) {
+	shutdown, err := Init(context.Background(), config.TelemetryConfig{Enabled: false}, "vibeman")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestInit_UnknownExporter(t *testing.T) {
+	_, err := Init(context.Background(), config.TelemetryConfig{Enabled: true, Exporter: "zipkin"}, "vibeman")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown telemetry exporter")
+}
+
+func TestInit_FileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces", "traces.jsonl")
+	shutdown, err := Init(context.Background(), config.TelemetryConfig{
+		Enabled:  true,
+		Exporter: "file",
+		FilePath: path,
+	}, "vibeman-test")
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "test.span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "test.span")
+	assert.Contains(t, string(data), "vibeman-test")
+}
+
+func TestRun_RecordsArgsAndExitCode(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	err := Run(context.Background(), exec.Command("sh", "-c", "exit 3"))
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "exec sh", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+
+	args, ok := attrValue(spans[0].Attributes(), "process.command_args")
+	require.True(t, ok)
+	assert.Equal(t, []string{"sh", "-c", "exit 3"}, args.AsStringSlice())
+
+	exitCode, ok := attrValue(spans[0].Attributes(), "process.exit.code")
+	require.True(t, ok)
+	assert.Equal(t, int64(3), exitCode.AsInt64())
+}
+
+func TestOutput_Success(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	output, err := Output(context.Background(), exec.Command("echo", "hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	exitCode, ok := attrValue(spans[0].Attributes(), "process.exit.code")
+	require.True(t, ok)
+	assert.Equal(t, int64(0), exitCode.AsInt64())
+}
+
+func TestCombinedOutput_MissingBinary(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	_, err := CombinedOutput(context.Background(), exec.Command("vibeman-definitely-missing-binary"))
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	exitCode, ok := attrValue(spans[0].Attributes(), "process.exit.code")
+	require.True(t, ok)
+	assert.Equal(t, int64(-1), exitCode.AsInt64())
+}
@@ -19,6 +19,9 @@ func SetupTestDB(t *testing.T) *db.DB {
 		t.Fatalf("Failed to create raw database: %v", err)
 	}
 	
+	// Every connection to :memory: is a separate database, so keep to one
+	rawDB.SetMaxOpenConns(1)
+
 	// Enable foreign keys
 	if _, err := rawDB.Exec("PRAGMA foreign_keys = ON"); err != nil {
 		t.Fatalf("Failed to enable foreign keys: %v", err)
@@ -31,6 +34,8 @@ func SetupTestDB(t *testing.T) *db.DB {
 			path TEXT NOT NULL UNIQUE,
 			name TEXT NOT NULL,
 			description TEXT DEFAULT '',
+			owner_id TEXT NOT NULL DEFAULT '',
+			team_id TEXT NOT NULL DEFAULT '',
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
@@ -42,6 +47,7 @@ func SetupTestDB(t *testing.T) *db.DB {
 			branch TEXT NOT NULL,
 			path TEXT NOT NULL,
 			status TEXT NOT NULL DEFAULT 'stopped' CHECK (status IN ('stopped', 'starting', 'running', 'stopping', 'error')),
+			owner_id TEXT NOT NULL DEFAULT '',
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
@@ -60,6 +66,126 @@ func SetupTestDB(t *testing.T) *db.DB {
 		BEGIN
 			UPDATE worktrees SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
 		END;
+
+		CREATE TABLE operations (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			target_id TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'cancelled')),
+			current_step TEXT NOT NULL DEFAULT '',
+			steps TEXT NOT NULL DEFAULT '[]',
+			error TEXT NOT NULL DEFAULT '',
+			result TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMP
+		);
+
+		CREATE TABLE users (
+			id TEXT PRIMARY KEY,
+			username TEXT NOT NULL UNIQUE,
+			email TEXT NOT NULL DEFAULT '',
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('admin', 'user')),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE api_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL DEFAULT '[]',
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE sessions (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE teams (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE team_members (
+			team_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'developer' CHECK (role IN ('viewer', 'developer', 'admin')),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (team_id, user_id),
+			FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE audit_events (
+			id TEXT PRIMARY KEY,
+			actor TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL CHECK (source IN ('cli', 'api', 'scheduler')),
+			action TEXT NOT NULL,
+			target_type TEXT NOT NULL DEFAULT '',
+			target_id TEXT NOT NULL DEFAULT '',
+			target_name TEXT NOT NULL DEFAULT '',
+			parameters TEXT,
+			outcome TEXT NOT NULL CHECK (outcome IN ('success', 'failure')),
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE service_resources (
+			id TEXT PRIMARY KEY,
+			worktree_id TEXT NOT NULL,
+			service TEXT NOT NULL,
+			type TEXT NOT NULL,
+			name TEXT NOT NULL,
+			db_index INTEGER NOT NULL DEFAULT -1,
+			env TEXT NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (worktree_id, service)
+		);
+
+		CREATE TABLE service_references (
+			service TEXT NOT NULL,
+			holder TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (service, holder)
+		);
+
+		CREATE TABLE ai_jobs (
+			id TEXT PRIMARY KEY,
+			worktree_id TEXT NOT NULL,
+			agent TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+			exit_code INTEGER,
+			transcript TEXT NOT NULL DEFAULT '',
+			diff TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			started_at TIMESTAMP,
+			finished_at TIMESTAMP,
+			FOREIGN KEY (worktree_id) REFERENCES worktrees(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE mcp_tokens (
+			worktree_id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			services TEXT NOT NULL DEFAULT '[]',
+			test_command TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (worktree_id) REFERENCES worktrees(id) ON DELETE CASCADE
+		);
 	`
 	
 	if _, err := rawDB.Exec(schema); err != nil {
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"testing"
+	"time"
 
 	"vibeman/internal/container"
 	"vibeman/internal/types"
 	
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -73,6 +76,23 @@ func (m *MockContainerManager) GetCalls(method string) []interface{} {
 	return m.calls[method]
 }
 
+// AssertExpectationsEventually asserts the expectations, giving calls made
+// from background goroutines (such as log aggregation) time to arrive
+func (m *MockContainerManager) AssertExpectationsEventually(t *testing.T) bool {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return m.AssertExpectations(quietT{})
+	}, time.Second, 10*time.Millisecond)
+	return m.AssertExpectations(t)
+}
+
+// quietT collects mock assertions without reporting them
+type quietT struct{}
+
+func (quietT) Logf(string, ...interface{})   {}
+func (quietT) Errorf(string, ...interface{}) {}
+func (quietT) FailNow()                      {}
+
 // recordCall records a method call
 func (m *MockContainerManager) recordCall(method string, args ...interface{}) {
 	m.mu.Lock()
@@ -377,6 +397,9 @@ func (m *MockServiceManager) SetError(method string, err error) {
 func (m *MockServiceManager) recordCall(method string, args ...interface{}) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.calls == nil {
+		m.calls = make(map[string][]interface{})
+	}
 	m.calls[method] = append(m.calls[method], args)
 }
 
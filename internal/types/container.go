@@ -24,4 +24,5 @@ type Container struct {
 	Command     string
 	EnvVars     map[string]string // All environment variables
 	Type        string            // Container type: "worktree", "service", "ai"
+	Labels      map[string]string // Labels, as reported by GetInfo
 }
@@ -10,11 +10,13 @@ import (
 type ServiceStatus string
 
 const (
-	ServiceStatusStopped  ServiceStatus = "stopped"
-	ServiceStatusStarting ServiceStatus = "starting"
-	ServiceStatusRunning  ServiceStatus = "running"
-	ServiceStatusStopping ServiceStatus = "stopping"
-	ServiceStatusError    ServiceStatus = "error"
+	ServiceStatusStopped   ServiceStatus = "stopped"
+	ServiceStatusStarting  ServiceStatus = "starting"
+	ServiceStatusRunning   ServiceStatus = "running"
+	ServiceStatusStopping  ServiceStatus = "stopping"
+	ServiceStatusError     ServiceStatus = "error"
+	ServiceStatusDegraded  ServiceStatus = "degraded"
+	ServiceStatusCrashLoop ServiceStatus = "crash-looping"
 )
 
 // ServiceInstance represents a running service instance
@@ -27,5 +29,7 @@ type ServiceInstance struct {
 	Config      config.ServiceConfig `json:"config"`
 	StartTime   time.Time            `json:"start_time"`
 	LastHealth  time.Time            `json:"last_health"`
+	Health      string               `json:"health,omitempty"`
 	HealthError string               `json:"health_error,omitempty"`
+	Restarts    int                  `json:"restarts,omitempty"`
 }
@@ -87,6 +87,18 @@ func LogsDir() string {
 	return filepath.Join(stateDir, "logs")
 }
 
+// RecordingsDir returns the directory for storing terminal recordings. It is
+// kept apart from LogsDir, which AI containers can read.
+func RecordingsDir() string {
+	stateDir, err := StateDir()
+	if err != nil {
+		// Fallback to data directory
+		dataDir, _ := DataDir()
+		return filepath.Join(dataDir, "recordings")
+	}
+	return filepath.Join(stateDir, "recordings")
+}
+
 // Legacy paths for migration
 
 // LegacyDir returns the old ~/.vibeman directory path for migration purposes